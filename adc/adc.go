@@ -0,0 +1,124 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package adc reads analog inputs: the BeagleBone's own ADC, through the
+// kernel's IIO interface, and anything else which implements AnalogPin, such
+// as a channel of an ADS1x15.  A Watcher samples an input and reports when it
+// crosses thresholds.
+package adc
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/metrics"
+	"io"
+	"os"
+	"sync"
+)
+
+// The BeagleBone's ADC is 12-bit, with a full-scale range of 1.8V.  Never
+// apply more than 1.8V to an AIN pin.
+const (
+	MAX_RAW = 4095
+	VREF    = 1.8
+)
+
+// DevicePath is the IIO device of the BeagleBone's ADC.
+var DevicePath = "/sys/bus/iio/devices/iio:device0"
+
+// An AnalogPin is an analog input which can be read in volts.
+type AnalogPin interface {
+	Voltage() (float64, error)
+}
+
+var _ AnalogPin = (*AIN)(nil)
+
+// An AIN is one of the BeagleBone's analog inputs, AIN0 to AIN6.
+type AIN struct {
+	N int
+
+	lock sync.Mutex
+	file *os.File
+}
+
+// Open opens the numbered analog input.  The ADC must be enabled, which it is
+// by default on recent kernels; otherwise load the BB-ADC overlay with the
+// capemgr package.
+func Open(n int) (ain *AIN, err error) {
+	if n < 0 || n > 6 {
+		err = fmt.Errorf("Invalid analog input: %d", n)
+		return
+	}
+
+	f, err := gopherbone.OpenFile(fmt.Sprintf("%s/in_voltage%d_raw", DevicePath, n), os.O_RDONLY, 0)
+	if err != nil {
+		return
+	}
+	ain = &AIN{N: n, file: f}
+
+	return
+}
+
+// Raw returns a single conversion, from 0 to MAX_RAW.
+func (ain *AIN) Raw() (raw int, err error) {
+	ain.lock.Lock()
+	defer ain.lock.Unlock()
+
+	if ain.file == nil {
+		err = os.ErrClosed
+		return
+	}
+
+	// Reading from offset 0 makes sysfs convert afresh each time
+	n, err := fmt.Fscanf(io.NewSectionReader(ain.file, 0, 16), "%d", &raw)
+	if n != 1 {
+		err = fmt.Errorf("Bad number of values read from AIN%d: %d", ain.N, n)
+		return
+	}
+	metrics.ObserveADC(ain.N, float64(raw)*VREF/MAX_RAW)
+
+	return
+}
+
+// Voltage returns a single conversion in volts.
+func (ain *AIN) Voltage() (volts float64, err error) {
+	raw, err := ain.Raw()
+	if err != nil {
+		return
+	}
+	volts = float64(raw) * VREF / MAX_RAW
+
+	return
+}
+
+// Close closes the input.
+func (ain *AIN) Close() (err error) {
+	ain.lock.Lock()
+	defer ain.lock.Unlock()
+
+	if ain.file != nil {
+		err = ain.file.Close()
+		ain.file = nil
+	}
+
+	return
+}
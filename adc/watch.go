@@ -0,0 +1,149 @@
+package adc
+
+import (
+	"context"
+	"fmt"
+	"github.com/Ratfink/gopherbone/sched"
+	"sort"
+	"time"
+)
+
+// An Event is a crossing of one of a Watcher's thresholds.
+type Event struct {
+	// Threshold is the threshold crossed, and Rising says in which
+	// direction.
+	Threshold float64
+	Rising    bool
+	// Level is the number of thresholds the value is now above, so that a
+	// Watcher with thresholds can sort its input into bands, such as a
+	// battery's charge.
+	Level int
+	// Value is the sample which crossed the threshold.
+	Value float64
+	Time  time.Time
+	// Err is set if sampling the input failed, in which case only Time and
+	// Level are meaningful.
+	Err error
+}
+
+// A Watcher samples an analog input at a regular interval and sends an Event
+// on its channel C each time the value crosses one of its thresholds.  To
+// keep noise from making a value near a threshold cross it repeatedly, the
+// value must rise hysteresis/2 above a threshold to cross it upwards, and
+// fall hysteresis/2 below it to cross it downwards.
+type Watcher struct {
+	C <-chan Event
+
+	c     chan Event
+	level int
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// Watch starts sampling pin every interval, reporting crossings of the given
+// thresholds, which are in volts.  The first sample sets the starting level
+// without sending any events; use Level to find it.
+func Watch(pin AnalogPin, interval time.Duration, hysteresis float64, thresholds ...float64) (w *Watcher, err error) {
+	return WatchContext(context.Background(), pin, interval, hysteresis, thresholds...)
+}
+
+// WatchContext is like Watch, but the Watcher also stops, closing C, when ctx
+// is done.
+func WatchContext(ctx context.Context, pin AnalogPin, interval time.Duration, hysteresis float64, thresholds ...float64) (w *Watcher, err error) {
+	if interval <= 0 {
+		err = fmt.Errorf("Invalid interval: %s", interval)
+		return
+	}
+	if hysteresis < 0 {
+		err = fmt.Errorf("Invalid hysteresis: %g", hysteresis)
+		return
+	}
+	if len(thresholds) == 0 {
+		err = fmt.Errorf("No thresholds given")
+		return
+	}
+	th := append([]float64(nil), thresholds...)
+	sort.Float64s(th)
+
+	value, err := pin.Voltage()
+	if err != nil {
+		return
+	}
+
+	w = &Watcher{
+		c:    make(chan Event, len(th)),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	w.C = w.c
+	for w.level < len(th) && value >= th[w.level] {
+		w.level++
+	}
+
+	go w.run(ctx, pin, interval, hysteresis/2, th, w.level)
+
+	return
+}
+
+// Level returns the number of thresholds the value was above when the
+// Watcher started.
+func (w *Watcher) Level() int {
+	return w.level
+}
+
+func (w *Watcher) run(ctx context.Context, pin AnalogPin, interval time.Duration, h float64, th []float64, level int) {
+	defer close(w.done)
+	defer close(w.c)
+
+	send := func(ev Event) bool {
+		select {
+		case w.c <- ev:
+			return true
+		case <-w.stop:
+		case <-ctx.Done():
+		}
+		return false
+	}
+
+	sample := func(sched.Tick) error {
+		value, err := pin.Voltage()
+		now := time.Now()
+		if err != nil {
+			if !send(Event{Level: level, Time: now, Err: err}) {
+				return sched.ErrStop
+			}
+			return nil
+		}
+
+		for level < len(th) && value >= th[level]+h {
+			level++
+			if !send(Event{Threshold: th[level-1], Rising: true, Level: level, Value: value, Time: now}) {
+				return sched.ErrStop
+			}
+		}
+		for level > 0 && value < th[level-1]-h {
+			level--
+			if !send(Event{Threshold: th[level], Level: level, Value: value, Time: now}) {
+				return sched.ErrStop
+			}
+		}
+		return nil
+	}
+
+	loop, err := sched.New(interval, sched.Options{}, sample)
+	if err != nil {
+		return
+	}
+	select {
+	case <-loop.Done():
+	case <-w.stop:
+	case <-ctx.Done():
+	}
+	loop.Stop()
+}
+
+// Close stops the Watcher and closes its channel.
+func (w *Watcher) Close() {
+	close(w.stop)
+	<-w.done
+}
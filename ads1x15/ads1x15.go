@@ -0,0 +1,300 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package ads1x15 drives the Texas Instruments ADS1015 and ADS1115 analog to
+// digital converters over I2C.  Unlike the BeagleBone's own 1.8V ADC, these
+// can measure up to the supply voltage, differentially if need be, with a
+// programmable gain amplifier in front.
+package ads1x15
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/adc"
+	"github.com/Ratfink/gopherbone/i2c"
+	"sync"
+	"time"
+)
+
+// ADDR is the I2C address with the ADDR pin tied to ground.  Tying it to VDD,
+// SDA or SCL gives 0x49, 0x4a or 0x4b.
+const ADDR = 0x48
+
+// Registers
+const (
+	CONVERSION = 0x00
+	CONFIG     = 0x01
+)
+
+// Config register bits
+const (
+	CONFIG_OS          = 0x8000 // Start a conversion; reads 1 when idle
+	CONFIG_MODE_SINGLE = 0x0100
+	CONFIG_COMP_QUE    = 0x0003 // Comparator disabled
+)
+
+// A Chip is a model of converter.
+type Chip int
+
+// Supported chips.  The ADS1015 is 12-bit and fast; the ADS1115 is 16-bit
+// and slow.
+const (
+	ADS1015 Chip = iota
+	ADS1115
+)
+
+// An Input selects what is measured.  The Diff inputs measure the first pin
+// relative to the second; the others measure a single pin relative to ground.
+type Input uint16
+
+// Inputs, as encoded in the config register's MUX field.
+const (
+	Diff01 Input = iota << 12
+	Diff03
+	Diff13
+	Diff23
+	AIN0
+	AIN1
+	AIN2
+	AIN3
+)
+
+// A Gain sets the full-scale range of the programmable gain amplifier.  No
+// input may go more than 0.3V beyond the supply rails, whatever the range.
+type Gain uint16
+
+// Gains, named for their full-scale range in volts.
+const (
+	Gain6_144 Gain = iota << 9
+	Gain4_096
+	Gain2_048
+	Gain1_024
+	Gain0_512
+	Gain0_256
+)
+
+// FullScale returns the voltage corresponding to the largest reading at this
+// gain.
+func (g Gain) FullScale() float64 {
+	if g == Gain6_144 {
+		return 6.144
+	}
+	return 8.192 / float64(int(1)<<(g>>9))
+}
+
+// Data rates in samples per second, indexed by the config register's DR
+// field.
+var dataRates = map[Chip][8]int{
+	ADS1015: {128, 250, 490, 920, 1600, 2400, 3300, 3300},
+	ADS1115: {8, 16, 32, 64, 128, 250, 475, 860},
+}
+
+// An ADS is an ADS1015 or ADS1115 on an I2C bus.  It is safe to use from
+// more than one goroutine; each conversion holds it from starting to reading
+// the result.
+type ADS struct {
+	lock sync.Mutex
+	dev  i2c.Conn
+	chip Chip
+	gain Gain
+	dr   uint16
+}
+
+// New returns the converter of the given model at addr on the given bus, set
+// to ±2.048V full scale and a data rate of 128 (ADS1115) or 1600 (ADS1015)
+// samples per second.
+func New(addr, bus byte, chip Chip) (ads *ADS, err error) {
+	if chip != ADS1015 && chip != ADS1115 {
+		err = fmt.Errorf("Invalid chip: %d", chip)
+		return
+	}
+	dev, err := i2c.NewDevice(addr, bus)
+	if err != nil {
+		return
+	}
+
+	return NewConn(dev, chip)
+}
+
+// NewConn is like New, but talks to the chip through conn, which may be a fake
+// for testing.
+func NewConn(conn i2c.Conn, chip Chip) (ads *ADS, err error) {
+	if chip != ADS1015 && chip != ADS1115 {
+		err = fmt.Errorf("Invalid chip: %d", chip)
+		return
+	}
+	ads = &ADS{dev: conn, chip: chip, gain: Gain2_048, dr: 4}
+
+	return
+}
+
+// SetGain sets the full-scale range used by subsequent conversions.
+func (ads *ADS) SetGain(g Gain) (err error) {
+	if g > Gain0_256 || g&^(7<<9) != 0 {
+		err = fmt.Errorf("Invalid gain: %#x", uint16(g))
+		return
+	}
+	ads.lock.Lock()
+	defer ads.lock.Unlock()
+	ads.gain = g
+
+	return
+}
+
+// SetDataRate sets the data rate to the slowest supported rate of at least
+// sps samples per second, and returns the rate chosen.
+func (ads *ADS) SetDataRate(sps int) (actual int, err error) {
+	ads.lock.Lock()
+	defer ads.lock.Unlock()
+	rates := dataRates[ads.chip]
+	for i, rate := range rates {
+		if rate >= sps {
+			ads.dr = uint16(i)
+			actual = rate
+			return
+		}
+	}
+
+	err = fmt.Errorf("Invalid data rate: %d", sps)
+	return
+}
+
+func (ads *ADS) config(input Input, mode uint16) uint16 {
+	return uint16(input) | uint16(ads.gain) | mode | ads.dr<<5 | CONFIG_COMP_QUE
+}
+
+func (ads *ADS) writeConfig(config uint16) error {
+	return ads.dev.Write(CONFIG, []byte{byte(config >> 8), byte(config)})
+}
+
+func (ads *ADS) readReg(reg byte) (value uint16, err error) {
+	data, err := ads.dev.Read(reg, 2)
+	if err != nil {
+		return
+	}
+	value = uint16(data[0])<<8 | uint16(data[1])
+
+	return
+}
+
+// convert turns the conversion register into counts and volts.
+func (ads *ADS) convert() (raw int, volts float64, err error) {
+	value, err := ads.readReg(CONVERSION)
+	if err != nil {
+		return
+	}
+	full := 32768.0
+	raw = int(int16(value))
+	if ads.chip == ADS1015 {
+		// 12-bit results are left-aligned
+		raw >>= 4
+		full = 2048
+	}
+	volts = float64(raw) / full * ads.gain.FullScale()
+
+	return
+}
+
+// Read performs a single-shot conversion of the given input and returns the
+// result both in counts and in volts.
+func (ads *ADS) Read(input Input) (raw int, volts float64, err error) {
+	if input > AIN3 || input&^(7<<12) != 0 {
+		err = fmt.Errorf("Invalid input: %#x", uint16(input))
+		return
+	}
+	ads.lock.Lock()
+	defer ads.lock.Unlock()
+	if err = ads.writeConfig(ads.config(input, CONFIG_MODE_SINGLE) | CONFIG_OS); err != nil {
+		return
+	}
+
+	// A conversion takes one sample period, so wait that long before
+	// polling for it to finish.  The internal oscillator is only good to
+	// 10% or so, but a conversion still running after ten periods never
+	// will finish.
+	period := time.Second / time.Duration(dataRates[ads.chip][ads.dr])
+	timeout := 10 * period
+	deadline := time.Now().Add(timeout)
+	time.Sleep(period)
+	for {
+		var config uint16
+		if config, err = ads.readReg(CONFIG); err != nil {
+			return
+		}
+		if config&CONFIG_OS != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("Conversion not finished after %s", timeout)
+			return
+		}
+		time.Sleep(period / 10)
+	}
+
+	return ads.convert()
+}
+
+// A Channel is one input of a converter, which implements adc.AnalogPin so
+// that it can be given to an adc.Watcher.
+type Channel struct {
+	ads   *ADS
+	input Input
+}
+
+var _ adc.AnalogPin = (*Channel)(nil)
+
+// Channel returns the given input as an adc.AnalogPin.
+func (ads *ADS) Channel(input Input) *Channel {
+	return &Channel{ads: ads, input: input}
+}
+
+// Voltage performs a single-shot conversion and returns the result in volts.
+func (ch *Channel) Voltage() (volts float64, err error) {
+	_, volts, err = ch.ads.Read(ch.input)
+	return
+}
+
+// StartContinuous starts converting the given input continuously, so that
+// ReadContinuous can fetch the latest result without waiting.
+func (ads *ADS) StartContinuous(input Input) (err error) {
+	if input > AIN3 || input&^(7<<12) != 0 {
+		err = fmt.Errorf("Invalid input: %#x", uint16(input))
+		return
+	}
+	ads.lock.Lock()
+	defer ads.lock.Unlock()
+	return ads.writeConfig(ads.config(input, 0))
+}
+
+// ReadContinuous returns the latest result of continuous conversion.
+func (ads *ADS) ReadContinuous() (raw int, volts float64, err error) {
+	ads.lock.Lock()
+	defer ads.lock.Unlock()
+	return ads.convert()
+}
+
+// Stop stops continuous conversion, returning the converter to its low power
+// single-shot mode.
+func (ads *ADS) Stop() error {
+	ads.lock.Lock()
+	defer ads.lock.Unlock()
+	return ads.writeConfig(ads.config(AIN0, CONFIG_MODE_SINGLE))
+}
@@ -0,0 +1,126 @@
+package ads1x15
+
+import (
+	"github.com/Ratfink/gopherbone/mock"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A converter simulates the 16-bit registers of an ADS1x15 on a mock
+// Device, whose registers are only a byte wide.
+type converter struct {
+	dev    *mock.Device
+	config uint16
+	result func(config uint16) uint16
+	conv   uint16
+	// stuck makes conversions never finish
+	stuck bool
+}
+
+func newConverter(result func(config uint16) uint16) *converter {
+	c := &converter{dev: mock.NewDevice(), config: 0x8583, result: result}
+	c.dev.OnWrite = func(reg byte, data []byte) {
+		if reg != CONFIG {
+			return
+		}
+		c.config = uint16(data[0])<<8 | uint16(data[1])
+		switch {
+		case c.stuck:
+			c.config &^= CONFIG_OS
+		case c.config&CONFIG_OS != 0:
+			c.conv = c.result(c.config)
+		}
+	}
+	c.dev.OnRead = func(reg byte, n int) {
+		v := c.conv
+		if reg == CONFIG {
+			v = c.config
+		}
+		c.dev.Regs[reg], c.dev.Regs[reg+1] = byte(v>>8), byte(v)
+	}
+	return c
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		chip  Chip
+		gain  Gain
+		input Input
+		conv  uint16
+		raw   int
+		volts float64
+	}{
+		{ADS1115, Gain2_048, AIN0, 0x4000, 16384, 1.024},
+		{ADS1115, Gain4_096, AIN3, 0xc000, -16384, -2.048},
+		{ADS1115, Gain0_256, Diff01, 0x7fff, 32767, 0.256 * 32767 / 32768},
+		{ADS1015, Gain6_144, AIN1, 0x4000, 1024, 3.072},
+		{ADS1015, Gain2_048, Diff23, 0xfff0, -1, -0.001},
+	}
+	for _, test := range tests {
+		var config uint16
+		c := newConverter(func(cfg uint16) uint16 {
+			config = cfg
+			return test.conv
+		})
+		ads, err := NewConn(c.dev, test.chip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ads.SetGain(test.gain); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = ads.SetDataRate(860); err != nil {
+			t.Fatal(err)
+		}
+		raw, volts, err := ads.Read(test.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if raw != test.raw || math.Abs(volts-test.volts) > 1e-9 {
+			t.Errorf("%#x at gain %#x: Read = %d, %g, want %d, %g", test.conv, test.gain, raw, volts, test.raw, test.volts)
+		}
+		// The MUX and PGA fields
+		if want := uint16(test.input) | uint16(test.gain); config&0x7e00 != want {
+			t.Errorf("Config %#04x, want input and gain %#04x", config, want)
+		}
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	c := newConverter(func(uint16) uint16 { return 0 })
+	c.stuck = true
+	ads, _ := NewConn(c.dev, ADS1015)
+	ads.SetDataRate(3300)
+	if _, _, err := ads.Read(AIN0); err == nil || !strings.Contains(err.Error(), "not finished") {
+		t.Errorf("Read of a stuck converter: %v", err)
+	}
+}
+
+func TestReadConcurrent(t *testing.T) {
+	// The gain each conversion was started with must be the one its
+	// result is scaled by
+	c := newConverter(func(cfg uint16) uint16 {
+		// 0.125V, in counts at the gain configured
+		raw := int(0.125/Gain(cfg&0x0e00).FullScale()*2048 + 0.5)
+		return uint16(raw << 4)
+	})
+	ads, _ := NewConn(c.dev, ADS1015)
+	ads.SetDataRate(3300)
+
+	var wg sync.WaitGroup
+	for _, g := range []Gain{Gain1_024, Gain0_512, Gain0_256} {
+		wg.Add(1)
+		go func(g Gain) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				ads.SetGain(g)
+				if _, volts, err := ads.Read(AIN0); err != nil || math.Abs(volts-0.125) > 1e-9 {
+					t.Errorf("Read = %g, %v, want 0.125", volts, err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,353 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package bmp drives the Bosch BMP180 and BMP280 barometric pressure sensors
+// over I2C.  The compensation formulas are the integer ones from the
+// datasheets, which give exactly the results Bosch specifies.
+package bmp
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/i2c"
+	"math"
+	"time"
+)
+
+// I2C addresses.  The BMP180 is always at ADDR; the BMP280 is at ADDR when its
+// SDO pin is high, and ADDR_ALT when it is low.
+const (
+	ADDR     = 0x77
+	ADDR_ALT = 0x76
+)
+
+// Registers common to both chips
+const (
+	CHIP_ID    = 0xd0
+	SOFT_RESET = 0xe0
+	CTRL_MEAS  = 0xf4
+)
+
+// BMP180 registers and commands
+const (
+	BMP180_CAL      = 0xaa
+	BMP180_OUT      = 0xf6
+	BMP180_CMD_TEMP = 0x2e
+	BMP180_CMD_PRES = 0x34 // OR with oversampling setting << 6
+)
+
+// BMP280 registers
+const (
+	BMP280_CAL    = 0x88
+	BMP280_STATUS = 0xf3
+	BMP280_CONFIG = 0xf5
+	BMP280_PRESS  = 0xf7
+)
+
+// A Chip is a model of sensor, identified by its chip ID.
+type Chip byte
+
+// Supported chips.
+const (
+	BMP180 Chip = 0x55
+	BMP280 Chip = 0x58
+)
+
+// An Oversampling setting trades speed and power for lower noise.
+type Oversampling int
+
+// Oversampling settings.  The BMP180 can't oversample more than 8 times, so
+// X16 is the same as X8 there.
+const (
+	X1 Oversampling = iota
+	X2
+	X4
+	X8
+	X16
+)
+
+// SeaLevel is standard atmospheric pressure at sea level, in pascals.
+const SeaLevel = 101325
+
+type bmp180Cal struct {
+	ac1, ac2, ac3 int64
+	ac4, ac5, ac6 int64
+	b1, b2        int64
+	mb, mc, md    int64
+}
+
+type bmp280Cal struct {
+	t1, t2, t3 int64
+	p1, p2, p3 int64
+	p4, p5, p6 int64
+	p7, p8, p9 int64
+}
+
+// A BMP is a BMP180 or BMP280 on an I2C bus.
+type BMP struct {
+	dev          i2c.Conn
+	chip         Chip
+	oversampling Oversampling
+	cal180       bmp180Cal
+	cal280       bmp280Cal
+}
+
+// New detects the chip at addr on the given bus and reads its calibration
+// coefficients.  Oversampling starts at X4.
+func New(addr, bus byte) (bmp *BMP, err error) {
+	dev, err := i2c.NewDevice(addr, bus)
+	if err != nil {
+		return
+	}
+
+	return NewConn(dev)
+}
+
+// NewConn is like New, but talks to the chip through conn, which may be a fake
+// for testing.
+func NewConn(conn i2c.Conn) (bmp *BMP, err error) {
+	bmp = &BMP{oversampling: X4, dev: conn}
+
+	id, err := bmp.dev.ReadReg(CHIP_ID)
+	if err != nil {
+		return nil, err
+	}
+	bmp.chip = Chip(id)
+
+	switch bmp.chip {
+	case BMP180:
+		err = bmp.readCal180()
+	case BMP280:
+		err = bmp.readCal280()
+	default:
+		err = fmt.Errorf("Unknown chip ID: %#02x", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Chip returns which chip was detected.
+func (bmp *BMP) Chip() Chip {
+	return bmp.chip
+}
+
+// SetOversampling sets the oversampling used for pressure readings.  On the
+// BMP280, temperature is oversampled at X1, which is all the pressure
+// compensation needs.
+func (bmp *BMP) SetOversampling(os Oversampling) (err error) {
+	if os < X1 || os > X16 {
+		err = fmt.Errorf("Invalid oversampling: %d", os)
+		return
+	}
+	bmp.oversampling = os
+
+	return
+}
+
+func (bmp *BMP) readCal180() (err error) {
+	data, err := bmp.dev.Read(BMP180_CAL, 22)
+	if err != nil {
+		return
+	}
+	s := func(i int) int64 { return int64(int16(uint16(data[2*i])<<8 | uint16(data[2*i+1]))) }
+	u := func(i int) int64 { return int64(uint16(data[2*i])<<8 | uint16(data[2*i+1])) }
+
+	bmp.cal180 = bmp180Cal{
+		ac1: s(0), ac2: s(1), ac3: s(2),
+		ac4: u(3), ac5: u(4), ac6: u(5),
+		b1: s(6), b2: s(7),
+		mb: s(8), mc: s(9), md: s(10),
+	}
+
+	return
+}
+
+func (bmp *BMP) readCal280() (err error) {
+	data, err := bmp.dev.Read(BMP280_CAL, 24)
+	if err != nil {
+		return
+	}
+	s := func(i int) int64 { return int64(int16(uint16(data[2*i+1])<<8 | uint16(data[2*i]))) }
+	u := func(i int) int64 { return int64(uint16(data[2*i+1])<<8 | uint16(data[2*i])) }
+
+	bmp.cal280 = bmp280Cal{
+		t1: u(0), t2: s(1), t3: s(2),
+		p1: u(3), p2: s(4), p3: s(5),
+		p4: s(6), p5: s(7), p6: s(8),
+		p7: s(9), p8: s(10), p9: s(11),
+	}
+
+	return
+}
+
+// Read returns the temperature in degrees Celsius and the pressure in pascals.
+func (bmp *BMP) Read() (temp, pressure float64, err error) {
+	if bmp.chip == BMP180 {
+		return bmp.read180()
+	}
+	return bmp.read280()
+}
+
+// Temperature returns the temperature in degrees Celsius.
+func (bmp *BMP) Temperature() (temp float64, err error) {
+	temp, _, err = bmp.Read()
+	return
+}
+
+// Pressure returns the pressure in pascals.
+func (bmp *BMP) Pressure() (pressure float64, err error) {
+	_, pressure, err = bmp.Read()
+	return
+}
+
+// Altitude returns the altitude in metres, given the current pressure at sea
+// level in pascals.  Pass SeaLevel if that isn't known, but expect the
+// result to be off by tens of metres as the weather changes.
+func (bmp *BMP) Altitude(seaLevel float64) (altitude float64, err error) {
+	pressure, err := bmp.Pressure()
+	if err != nil {
+		return
+	}
+	altitude = 44330 * (1 - math.Pow(pressure/seaLevel, 1/5.255))
+
+	return
+}
+
+func (bmp *BMP) read180() (temp, pressure float64, err error) {
+	oss := int64(bmp.oversampling)
+	if oss > 3 {
+		oss = 3
+	}
+
+	if err = bmp.dev.WriteReg(CTRL_MEAS, BMP180_CMD_TEMP); err != nil {
+		return
+	}
+	time.Sleep(4500 * time.Microsecond)
+	data, err := bmp.dev.Read(BMP180_OUT, 2)
+	if err != nil {
+		return
+	}
+	ut := int64(data[0])<<8 | int64(data[1])
+
+	if err = bmp.dev.WriteReg(CTRL_MEAS, BMP180_CMD_PRES|byte(oss<<6)); err != nil {
+		return
+	}
+	time.Sleep(time.Duration(1500+3000<<uint(oss)) * time.Microsecond)
+	data, err = bmp.dev.Read(BMP180_OUT, 3)
+	if err != nil {
+		return
+	}
+	up := (int64(data[0])<<16 | int64(data[1])<<8 | int64(data[2])) >> uint(8-oss)
+
+	temp, pressure = bmp.cal180.compensate(ut, up, oss)
+
+	return
+}
+
+// compensate converts raw BMP180 readings to degrees Celsius and pascals.
+func (c *bmp180Cal) compensate(ut, up, oss int64) (temp, pressure float64) {
+	x1 := (ut - c.ac6) * c.ac5 >> 15
+	x2 := c.mc * (1 << 11) / (x1 + c.md)
+	b5 := x1 + x2
+	temp = float64((b5+8)>>4) / 10
+
+	b6 := b5 - 4000
+	x1 = (c.b2 * (b6 * b6 >> 12)) >> 11
+	x2 = c.ac2 * b6 >> 11
+	x3 := x1 + x2
+	b3 := ((c.ac1*4+x3)<<uint(oss) + 2) >> 2
+	x1 = c.ac3 * b6 >> 13
+	x2 = (c.b1 * (b6 * b6 >> 12)) >> 16
+	x3 = (x1 + x2 + 2) >> 2
+	b4 := int64(uint32(c.ac4) * uint32(x3+32768) >> 15)
+	b7 := int64(uint32(up-b3) * uint32(50000>>uint(oss)))
+	var p int64
+	if b7 < 0x80000000 {
+		p = b7 * 2 / b4
+	} else {
+		p = b7 / b4 * 2
+	}
+	x1 = (p >> 8) * (p >> 8)
+	x1 = (x1 * 3038) >> 16
+	x2 = (-7357 * p) >> 16
+	p += (x1 + x2 + 3791) >> 4
+	pressure = float64(p)
+
+	return
+}
+
+func (bmp *BMP) read280() (temp, pressure float64, err error) {
+	osrsP := byte(bmp.oversampling) + 1
+
+	// Take a single measurement in forced mode
+	if err = bmp.dev.WriteReg(CTRL_MEAS, 1<<5|osrsP<<2|0x01); err != nil {
+		return
+	}
+	for {
+		time.Sleep(2 * time.Millisecond)
+		var status byte
+		if status, err = bmp.dev.ReadReg(BMP280_STATUS); err != nil {
+			return
+		}
+		if status&0x08 == 0 {
+			break
+		}
+	}
+
+	data, err := bmp.dev.Read(BMP280_PRESS, 6)
+	if err != nil {
+		return
+	}
+	adcP := int64(data[0])<<12 | int64(data[1])<<4 | int64(data[2])>>4
+	adcT := int64(data[3])<<12 | int64(data[4])<<4 | int64(data[5])>>4
+
+	return bmp.cal280.compensate(adcT, adcP)
+}
+
+// compensate converts raw BMP280 readings to degrees Celsius and pascals.
+func (c *bmp280Cal) compensate(adcT, adcP int64) (temp, pressure float64, err error) {
+	var1 := ((adcT>>3 - c.t1<<1) * c.t2) >> 11
+	var2 := (((adcT>>4 - c.t1) * (adcT>>4 - c.t1) >> 12) * c.t3) >> 14
+	tFine := var1 + var2
+	temp = float64((tFine*5+128)>>8) / 100
+
+	var1 = tFine - 128000
+	var2 = var1 * var1 * c.p6
+	var2 += (var1 * c.p5) << 17
+	var2 += c.p4 << 35
+	var1 = (var1*var1*c.p3)>>8 + (var1*c.p2)<<12
+	var1 = ((1<<47 + var1) * c.p1) >> 33
+	if var1 == 0 {
+		err = fmt.Errorf("Invalid calibration data")
+		return
+	}
+	p := 1048576 - adcP
+	p = ((p<<31 - var2) * 3125) / var1
+	var1 = (c.p9 * (p >> 13) * (p >> 13)) >> 25
+	var2 = (c.p8 * p) >> 19
+	p = (p+var1+var2)>>8 + c.p7<<4
+	pressure = float64(p) / 256
+
+	return
+}
@@ -0,0 +1,226 @@
+package bmp
+
+import (
+	"errors"
+	"github.com/Ratfink/gopherbone/i2c"
+	"github.com/Ratfink/gopherbone/mock"
+	"math"
+	"testing"
+)
+
+// The worked examples from the datasheets: calibrations, and the raw
+// readings which go with them
+var (
+	cal180 = bmp180Cal{
+		ac1: 408, ac2: -72, ac3: -14383,
+		ac4: 32741, ac5: 32757, ac6: 23153,
+		b1: 6190, b2: 4,
+		mb: -32768, mc: -8711, md: 2868,
+	}
+	cal280 = bmp280Cal{
+		t1: 27504, t2: 26435, t3: -1000,
+		p1: 36477, p2: -10685, p3: 3024,
+		p4: 2855, p5: 140, p6: -7,
+		p7: 15500, p8: -14600, p9: 6000,
+	}
+
+	ut180, up180     int64 = 27898, 23843
+	adcT280, adcP280 int64 = 519888, 415148
+)
+
+func TestCompensate(t *testing.T) {
+	// The datasheets give the BMP280's pressure to two places from the
+	// floating point formula, so pressures are only compared that closely
+	tests := []struct {
+		name           string
+		compensate     func() (float64, float64, error)
+		temp, pressure float64
+		fail           bool
+	}{
+		{"BMP180", func() (t, p float64, err error) {
+			t, p = cal180.compensate(ut180, up180, 0)
+			return
+		}, 15.0, 69964, false},
+		{"BMP280", func() (float64, float64, error) {
+			return cal280.compensate(adcT280, adcP280)
+		}, 25.08, 100653.27, false},
+		{"BMP280 blank", func() (float64, float64, error) {
+			return (&bmp280Cal{}).compensate(adcT280, adcP280)
+		}, 0, 0, true},
+	}
+	for _, test := range tests {
+		temp, pressure, err := test.compensate()
+		if (err != nil) != test.fail {
+			t.Errorf("%s: Error %v", test.name, err)
+			continue
+		}
+		if !test.fail && (temp != test.temp || math.Abs(pressure-test.pressure) > 0.05) {
+			t.Errorf("%s: %g°C, %gPa, want %g°C, %gPa", test.name, temp, pressure, test.temp, test.pressure)
+		}
+	}
+}
+
+// be16 and le16 put a calibration coefficient in the registers, big and
+// little endian.
+func be16(regs []byte, i int, v int64) {
+	regs[2*i], regs[2*i+1] = byte(v>>8), byte(v)
+}
+
+func le16(regs []byte, i int, v int64) {
+	regs[2*i], regs[2*i+1] = byte(v), byte(v>>8)
+}
+
+// newBMP180 simulates a BMP180 with the datasheet's calibration, which
+// measures the datasheet's raw values.
+func newBMP180() *mock.Device {
+	dev := mock.NewDevice()
+	dev.Regs[CHIP_ID] = byte(BMP180)
+	c := cal180
+	for i, v := range []int64{c.ac1, c.ac2, c.ac3, c.ac4, c.ac5, c.ac6, c.b1, c.b2, c.mb, c.mc, c.md} {
+		be16(dev.Regs[BMP180_CAL:], i, v)
+	}
+	dev.OnWrite = func(reg byte, data []byte) {
+		if reg != CTRL_MEAS {
+			return
+		}
+		out := dev.Regs[BMP180_OUT:]
+		switch cmd := data[0]; {
+		case cmd == BMP180_CMD_TEMP:
+			out[0], out[1] = byte(ut180>>8), byte(ut180)
+		case cmd&0x3f == BMP180_CMD_PRES:
+			raw := up180 << (8 - cmd>>6)
+			out[0], out[1], out[2] = byte(raw>>16), byte(raw>>8), byte(raw)
+		}
+	}
+	return dev
+}
+
+// newBMP280 simulates a BMP280 likewise, which is busy for one poll of its
+// status after each measurement is started.
+func newBMP280() *mock.Device {
+	dev := mock.NewDevice()
+	dev.Regs[CHIP_ID] = byte(BMP280)
+	c := cal280
+	for i, v := range []int64{c.t1, c.t2, c.t3, c.p1, c.p2, c.p3, c.p4, c.p5, c.p6, c.p7, c.p8, c.p9} {
+		le16(dev.Regs[BMP280_CAL:], i, v)
+	}
+	out := dev.Regs[BMP280_PRESS:]
+	out[0], out[1], out[2] = byte(adcP280>>12), byte(adcP280>>4), byte(adcP280<<4)
+	out[3], out[4], out[5] = byte(adcT280>>12), byte(adcT280>>4), byte(adcT280<<4)
+
+	busy := 0
+	dev.OnWrite = func(reg byte, data []byte) {
+		if reg == CTRL_MEAS && data[0]&0x03 == 0x01 {
+			dev.Regs[BMP280_STATUS] = 0x08
+			busy = 1
+		}
+	}
+	dev.OnRead = func(reg byte, n int) {
+		if reg == BMP280_STATUS {
+			if busy == 0 {
+				dev.Regs[BMP280_STATUS] = 0
+			}
+			busy--
+		}
+	}
+	return dev
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name           string
+		dev            *mock.Device
+		chip           Chip
+		os             Oversampling
+		ctrl           byte
+		temp, pressure float64
+	}{
+		{"BMP180", newBMP180(), BMP180, X1, BMP180_CMD_PRES, 15.0, 69964},
+		{"BMP280", newBMP280(), BMP280, X4, 0x2d, 25.08, 100653.27},
+		{"BMP280 X16", newBMP280(), BMP280, X16, 0x35, 25.08, 100653.27},
+	}
+	for _, test := range tests {
+		bmp, err := NewConn(test.dev)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if bmp.Chip() != test.chip {
+			t.Errorf("%s: Detected chip %#02x", test.name, bmp.Chip())
+		}
+		if err = bmp.SetOversampling(test.os); err != nil {
+			t.Fatal(err)
+		}
+
+		temp, pressure, err := bmp.Read()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if temp != test.temp || math.Abs(pressure-test.pressure) > 0.05 {
+			t.Errorf("%s: %g°C, %gPa, want %g°C, %gPa", test.name, temp, pressure, test.temp, test.pressure)
+		}
+		if ctrl := test.dev.Regs[CTRL_MEAS]; ctrl != test.ctrl {
+			t.Errorf("%s: Last wrote %#02x to CTRL_MEAS, want %#02x", test.name, ctrl, test.ctrl)
+		}
+	}
+}
+
+func TestAltitude(t *testing.T) {
+	bmp, err := NewConn(newBMP280())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pressure, err := bmp.Pressure()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		seaLevel, want float64
+	}{
+		{pressure, 0},
+		{SeaLevel, 56.1},
+		{102000, 112.0},
+	}
+	for _, test := range tests {
+		alt, err := bmp.Altitude(test.seaLevel)
+		if err != nil || math.Abs(alt-test.want) > 0.1 {
+			t.Errorf("Altitude(%g) = %g, %v, want %g", test.seaLevel, alt, err, test.want)
+		}
+	}
+}
+
+func TestNewConnErrors(t *testing.T) {
+	unknown := mock.NewDevice()
+	unknown.Regs[CHIP_ID] = 0x60
+	missing := mock.NewDevice()
+	missing.NAK = true
+
+	tests := []struct {
+		name string
+		dev  *mock.Device
+		want error
+	}{
+		{"unknown chip", unknown, nil},
+		{"missing", missing, i2c.ErrNAK},
+	}
+	for _, test := range tests {
+		_, err := NewConn(test.dev)
+		if err == nil || (test.want != nil && !errors.Is(err, test.want)) {
+			t.Errorf("%s: NewConn returned %v", test.name, err)
+		}
+	}
+}
+
+func TestSetOversampling(t *testing.T) {
+	bmp, err := NewConn(newBMP280())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, os := range []Oversampling{X1 - 1, X16 + 1} {
+		if err = bmp.SetOversampling(os); err == nil {
+			t.Errorf("SetOversampling(%d) succeeded", os)
+		}
+	}
+}
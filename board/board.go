@@ -0,0 +1,274 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package board identifies which member of the BeagleBone family the program
+// is running on, from the device tree and the baseboard EEPROM, and describes
+// its headers and on-board peripherals, so that the other packages and
+// programs using them can adapt to it.
+package board
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A Model is a member of the BeagleBone family.
+type Model int
+
+// Models.
+const (
+	Unknown Model = iota
+	White
+	Black
+	BlackWireless
+	Green
+	GreenWireless
+	Blue
+	PocketBeagle
+)
+
+var modelNames = map[Model]string{
+	Unknown:       "Unknown",
+	White:         "BeagleBone",
+	Black:         "BeagleBone Black",
+	BlackWireless: "BeagleBone Black Wireless",
+	Green:         "BeagleBone Green",
+	GreenWireless: "BeagleBone Green Wireless",
+	Blue:          "BeagleBone Blue",
+	PocketBeagle:  "PocketBeagle",
+}
+
+func (m Model) String() string {
+	if name, ok := modelNames[m]; ok {
+		return name
+	}
+	return modelNames[Unknown]
+}
+
+// Features are flags for the hardware present on a board.
+type Features uint32
+
+// Feature flags.
+const (
+	// CapeHeaders is set for boards with the P8 and P9 cape headers, and
+	// PocketHeaders for those with the PocketBeagle's P1 and P2.
+	CapeHeaders Features = 1 << iota
+	PocketHeaders
+	EMMC
+	HDMI
+	Ethernet
+	WiFi
+	Bluetooth
+	// Grove is set for boards with Grove connectors for I2C and UART.
+	Grove
+	// Battery is set for boards with a LiPo charger, Motors for those with
+	// DC motor drivers and servo outputs, and IMU and Barometer for those
+	// with an MPU-9250 and BMP280 on board; all are the BeagleBone Blue.
+	Battery
+	Motors
+	IMU
+	Barometer
+)
+
+// features gives the hardware present on each model.
+var features = map[Model]Features{
+	White:         CapeHeaders | Ethernet,
+	Black:         CapeHeaders | EMMC | HDMI | Ethernet,
+	BlackWireless: CapeHeaders | EMMC | HDMI | WiFi | Bluetooth,
+	Green:         CapeHeaders | EMMC | Ethernet | Grove,
+	GreenWireless: CapeHeaders | EMMC | WiFi | Bluetooth | Grove,
+	Blue:          EMMC | WiFi | Bluetooth | Battery | Motors | IMU | Barometer,
+	PocketBeagle:  PocketHeaders,
+}
+
+// An Info describes the board the program is running on.
+type Info struct {
+	Model Model
+	// DTModel is the model string from the device tree, such as "TI AM335x
+	// BeagleBone Black".
+	DTModel string
+	// EEPROMName, Revision and Serial are read from the baseboard EEPROM,
+	// if it could be read.  EEPROMName is "A335BONE" for the original
+	// BeagleBone, "A335BNLT" for the Black and its derivatives, and
+	// "A335PBGL" for the PocketBeagle.
+	EEPROMName string
+	Revision   string
+	Serial     string
+	Features   Features
+}
+
+// Has reports whether the board has all of the given features.
+func (info *Info) Has(f Features) bool {
+	return info.Features&f == f
+}
+
+// Headers returns the names of the board's expansion headers, whose pins
+// are found by gpio.LookupPin.  For the BeagleBone Blue, these are the
+// connectors with GPIOs on them.
+func (info *Info) Headers() []string {
+	switch {
+	case info.Has(CapeHeaders):
+		return []string{"P8", "P9"}
+	case info.Has(PocketHeaders):
+		return []string{"P1", "P2"}
+	case info.Model == Blue:
+		return []string{"GP0", "GP1", "S1.1", "S1.2"}
+	}
+	return nil
+}
+
+// DeviceTreePath is where the kernel exposes the device tree.
+var DeviceTreePath = "/proc/device-tree"
+
+// EEPROMPaths are the places the baseboard EEPROM may be found, which
+// depend on the kernel version.  The first which can be read is used.
+var EEPROMPaths = []string{
+	"/sys/bus/nvmem/devices/0-00500/nvmem",
+	"/sys/bus/i2c/devices/0-0050/eeprom",
+	"/sys/bus/i2c/devices/0-0050/at24-0/nvmem",
+}
+
+// ErrUnknownBoard is returned by Detect when neither the device tree nor the
+// EEPROM identify the board as a BeagleBone.
+var ErrUnknownBoard = errors.New("Not a known BeagleBone")
+
+// eepromMagic starts the baseboard EEPROM, as it does the cape EEPROM.
+const eepromMagic = 0xaa5533ee
+
+// Detect identifies the board.  The device tree is consulted first, then the
+// EEPROM, which tells apart models that older device trees don't.  If the
+// board is unknown, the Info is still returned along with ErrUnknownBoard.
+func Detect() (info *Info, err error) {
+	info = new(Info)
+
+	if model, e := os.ReadFile(DeviceTreePath + "/model"); e == nil {
+		info.DTModel = string(bytes.TrimRight(model, "\x00\n"))
+		info.Model = fromDTModel(info.DTModel)
+	}
+	for _, path := range EEPROMPaths {
+		if readEEPROM(info, path) {
+			break
+		}
+	}
+	if info.Model == Unknown && info.EEPROMName != "" {
+		info.Model = fromEEPROM(info.EEPROMName, info.Revision)
+	}
+
+	info.Features = features[info.Model]
+	if info.Model == Unknown {
+		err = ErrUnknownBoard
+	}
+
+	return
+}
+
+// fromDTModel identifies a board from its device tree model string.  The
+// original BeagleBone's is plain "TI AM335x BeagleBone", which older kernels
+// use for every model, so it isn't trusted.
+func fromDTModel(model string) Model {
+	for _, m := range []struct {
+		suffix string
+		model  Model
+	}{
+		{"PocketBeagle", PocketBeagle},
+		{"BeagleBone Blue", Blue},
+		{"BeagleBone Green Wireless", GreenWireless},
+		{"BeagleBone Green", Green},
+		{"BeagleBone Black Wireless", BlackWireless},
+		{"BeagleBone Black", Black},
+	} {
+		if strings.Contains(model, m.suffix) {
+			return m.model
+		}
+	}
+	return Unknown
+}
+
+// readEEPROM fills in info from the baseboard EEPROM at path, reporting
+// whether it could be read and had a valid header.
+func readEEPROM(info *Info, path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var data [28]byte
+	if _, err = f.ReadAt(data[:], 0); err != nil {
+		return false
+	}
+	if binary.BigEndian.Uint32(data[:]) != eepromMagic {
+		return false
+	}
+
+	field := func(b []byte) string {
+		return strings.TrimRight(string(b), "\x00\xff ")
+	}
+	info.EEPROMName = field(data[4:12])
+	info.Revision = field(data[12:16])
+	info.Serial = field(data[16:28])
+
+	return true
+}
+
+// fromEEPROM identifies a board from the name and revision in its EEPROM.
+// The Black's derivatives share its name, and are told apart by the first
+// letters of the revision.
+func fromEEPROM(name, revision string) Model {
+	switch name {
+	case "A335BONE":
+		return White
+	case "A335PBGL":
+		return PocketBeagle
+	case "A335BNLT":
+		switch {
+		case strings.HasPrefix(revision, "GW"):
+			return GreenWireless
+		case strings.HasPrefix(revision, "BBG"):
+			return Green
+		case strings.HasPrefix(revision, "BW"):
+			return BlackWireless
+		case strings.HasPrefix(revision, "BLA"):
+			return Blue
+		}
+		return Black
+	}
+	return Unknown
+}
+
+var current struct {
+	once sync.Once
+	info *Info
+	err  error
+}
+
+// Current returns the result of Detect, which is only run once.
+func Current() (*Info, error) {
+	current.once.Do(func() {
+		current.info, current.err = Detect()
+	})
+	return current.info, current.err
+}
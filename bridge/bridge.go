@@ -0,0 +1,280 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package bridge connects GPIO pins, PWM channels, analog inputs and other
+// sensors to an MQTT broker, making a BeagleBone a home automation node.
+// Each thing is given a name, and its topics are under the Bridge's prefix:
+//
+//	<prefix>/<name>        its state: a pin's value, a PWM duty cycle, or a
+//	                       reading; retained, so new subscribers see it
+//	<prefix>/<name>/set    published to, to set an output or duty cycle
+//	<prefix>/<name>/error  errors reading or setting it
+//	<prefix>/status        "online", or "offline" once the bridge is gone
+//
+// Pin values are "0" and "1"; outputs also accept "on", "off", "true",
+// "false" and "toggle".  The simplest use sets everything up from a config
+// file, whose mqtt table names the broker:
+//
+//	c, err := config.Load("/etc/node.toml")
+//	...
+//	hw, err := c.Open()
+//	...
+//	b, err := bridge.Setup(c, hw)
+package bridge
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/adc"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/mqtt"
+	"github.com/Ratfink/gopherbone/pwm"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often readings are published if no interval is
+// given.
+var DefaultInterval = 10 * time.Second
+
+// A Reading returns a sensor's current value, such as a temperature.
+type Reading func() (float64, error)
+
+type polled struct {
+	name string
+	read Reading
+}
+
+// A Bridge publishes and subscribes to topics for its inputs and outputs.
+type Bridge struct {
+	client    *mqtt.Client
+	ownClient bool
+	prefix    string
+	interval  time.Duration
+
+	lock     sync.Mutex
+	polled   []polled
+	watchers []*gpio.Watcher
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns a Bridge publishing through client under prefix, and
+// publishing readings every interval.  The client should have been dialled
+// with a will message of "offline" on StatusTopic(prefix), so that the
+// status shows if the bridge goes away without closing.
+func New(client *mqtt.Client, prefix string, interval time.Duration) (b *Bridge, err error) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	b = &Bridge{
+		client:   client,
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err = client.Publish(StatusTopic(b.prefix), []byte("online"), true); err != nil {
+		return nil, err
+	}
+	go b.poll()
+	return
+}
+
+// StatusTopic returns the status topic for a prefix.
+func StatusTopic(prefix string) string {
+	return strings.TrimSuffix(prefix, "/") + "/status"
+}
+
+func (b *Bridge) topic(name string) string {
+	return b.prefix + "/" + name
+}
+
+// publish publishes a thing's state, retained.
+func (b *Bridge) publish(name, value string) error {
+	return b.client.Publish(b.topic(name), []byte(value), true)
+}
+
+// report publishes an error to a thing's error topic.
+func (b *Bridge) report(name string, err error) {
+	b.client.Publish(b.topic(name)+"/error", []byte(err.Error()), false)
+}
+
+// AddInput publishes pin's value now and whenever it changes on edge.  The
+// pin must be an input.
+func (b *Bridge) AddInput(name string, pin gpio.DigitalPin, edge gpio.Edge) (err error) {
+	value, err := pin.Value()
+	if err != nil {
+		return
+	}
+	w, err := pin.Watch(edge)
+	if err != nil {
+		return
+	}
+	b.lock.Lock()
+	b.watchers = append(b.watchers, w)
+	b.lock.Unlock()
+
+	if err = b.publish(name, strconv.Itoa(value)); err != nil {
+		return
+	}
+	go func() {
+		for v := range w.C {
+			b.publish(name, strconv.Itoa(v))
+		}
+		if w.Err != nil {
+			b.report(name, w.Err)
+		}
+	}()
+	return
+}
+
+// AddOutput publishes pin's value, and sets it from messages on the set
+// topic.  The pin must be an output.
+func (b *Bridge) AddOutput(name string, pin gpio.DigitalPin) (err error) {
+	value, err := pin.Value()
+	if err != nil {
+		return
+	}
+	if err = b.publish(name, strconv.Itoa(value)); err != nil {
+		return
+	}
+	return b.client.Subscribe(b.topic(name)+"/set", func(topic string, payload []byte) {
+		v, err := parseValue(string(payload))
+		if err == nil && v < 0 {
+			if v, err = pin.Value(); err == nil {
+				v ^= 1
+			}
+		}
+		if err == nil {
+			err = pin.SetValue(v)
+		}
+		if err != nil {
+			b.report(name, err)
+			return
+		}
+		b.publish(name, strconv.Itoa(v))
+	})
+}
+
+// parseValue parses a pin value, returning -1 for "toggle".
+func parseValue(s string) (v int, err error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "0", "off", "false", "low":
+		return 0, nil
+	case "1", "on", "true", "high":
+		return 1, nil
+	case "toggle":
+		return -1, nil
+	}
+	return 0, fmt.Errorf("Invalid pin value: %q", s)
+}
+
+// AddPWM publishes p's duty cycle as a fraction, and sets it from messages
+// on the set topic.  The initial value published is duty, since the duty
+// cycle is not read back.
+func (b *Bridge) AddPWM(name string, p *pwm.PWM, duty float64) (err error) {
+	if err = b.publish(name, formatFloat(duty)); err != nil {
+		return
+	}
+	return b.client.Subscribe(b.topic(name)+"/set", func(topic string, payload []byte) {
+		duty, err := strconv.ParseFloat(strings.TrimSpace(string(payload)), 64)
+		if err == nil {
+			err = p.SetDuty(duty)
+		}
+		if err != nil {
+			b.report(name, err)
+			return
+		}
+		b.publish(name, formatFloat(duty))
+	})
+}
+
+// AddAnalog publishes pin's voltage every interval.
+func (b *Bridge) AddAnalog(name string, pin adc.AnalogPin) {
+	b.AddReading(name, pin.Voltage)
+}
+
+// AddReading publishes a sensor reading every interval.
+func (b *Bridge) AddReading(name string, read Reading) {
+	b.lock.Lock()
+	b.polled = append(b.polled, polled{name, read})
+	b.lock.Unlock()
+}
+
+// poll publishes the readings every interval until the Bridge closes.
+func (b *Bridge) poll() {
+	defer close(b.done)
+	t := time.NewTicker(b.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-b.client.Done():
+			return
+		case <-t.C:
+		}
+
+		b.lock.Lock()
+		ps := append([]polled(nil), b.polled...)
+		b.lock.Unlock()
+		for _, p := range ps {
+			v, err := p.read()
+			if err != nil {
+				b.report(p.name, err)
+				continue
+			}
+			b.publish(p.name, formatFloat(v))
+		}
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Close stops the Bridge and publishes its status as offline.  The hardware
+// is left open, as is the client unless the Bridge came from Setup.
+func (b *Bridge) Close() (err error) {
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+
+		b.lock.Lock()
+		for _, w := range b.watchers {
+			w.Close()
+		}
+		b.lock.Unlock()
+
+		err = b.client.Publish(StatusTopic(b.prefix), []byte("offline"), true)
+		if b.ownClient {
+			if e := b.client.Close(); err == nil {
+				err = e
+			}
+		}
+	})
+	return
+}
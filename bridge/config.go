@@ -0,0 +1,92 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/config"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/mqtt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNoBroker is returned by Setup when the configuration names no broker.
+var ErrNoBroker = errors.New("No MQTT broker configured")
+
+// Setup connects to the broker in c's mqtt table and bridges everything in
+// hw: output pins are controllable, input pins are published on both edges
+// or the edge configured, PWM channels are controllable and analog inputs
+// are published every interval.  The prefix defaults to "gopherbone/" and
+// the host name.  Closing the Bridge returned also closes its client.
+func Setup(c *config.Config, hw *config.Hardware) (b *Bridge, err error) {
+	mc := c.MQTT
+	if mc.Broker == "" {
+		err = ErrNoBroker
+		return
+	}
+	if mc.Prefix == "" {
+		host, _ := os.Hostname()
+		mc.Prefix = "gopherbone/" + host
+	}
+	client, err := mqtt.Dial(mc.Broker, mqtt.Options{
+		ClientID:    mc.ClientID,
+		Username:    mc.Username,
+		Password:    mc.Password,
+		KeepAlive:   time.Duration(mc.KeepAlive),
+		WillTopic:   StatusTopic(mc.Prefix),
+		WillMessage: []byte("offline"),
+		WillRetain:  true,
+	})
+	if err != nil {
+		return
+	}
+	br, err := New(client, mc.Prefix, time.Duration(mc.Interval))
+	if err != nil {
+		client.Close()
+		return
+	}
+	br.ownClient = true
+	defer func() {
+		if err != nil {
+			br.Close()
+		}
+	}()
+
+	for name, pc := range c.Pins {
+		pin, err := hw.Pin(name)
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(pc.Direction, "out") {
+			err = br.AddOutput(name, pin)
+		} else {
+			edge := gpio.Both
+			if pc.Edge != "" {
+				edge = gpio.Edge(strings.ToLower(pc.Edge))
+			}
+			err = br.AddInput(name, pin, edge)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Pin %s: %w", name, err)
+		}
+	}
+	for name, pc := range c.PWM {
+		p, err := hw.PWM(name)
+		if err != nil {
+			return nil, err
+		}
+		if err = br.AddPWM(name, p, pc.Duty); err != nil {
+			return nil, fmt.Errorf("PWM %s: %w", name, err)
+		}
+	}
+	for name := range c.ADC {
+		a, err := hw.Analog(name)
+		if err != nil {
+			return nil, err
+		}
+		br.AddAnalog(name, a)
+	}
+
+	return br, nil
+}
@@ -0,0 +1,321 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package bus passes events between the parts of a bigger program, so that
+// drivers such as buttons, encoders and sensors need not know what uses
+// them.  Drivers publish events on a Bus under topics like MQTT's, such as
+// "buttons/ok" or "sensors/temperature", and consumers such as widgets,
+// loggers and the MQTT bridge subscribe to the topics they want, with the
+// same + and # wildcards:
+//
+//	b := bus.New()
+//	b.Input("buttons", input.FromButton(btn, "ok", input.KEY_ENTER))
+//	b.Poll("sensors/light", light.Voltage, time.Second)
+//	b.Handle("sensors/#", bus.Options{}, func(ev bus.Event) {
+//		graph.Add(ev.Value)
+//	})
+//
+// Each subscription has a bounded queue, and a Policy saying what happens
+// when a slow subscriber lets it fill, so that one stuck consumer neither
+// blocks every publisher nor makes the program's memory grow without
+// limit.  The bus also keeps the last event on each topic, which Reader
+// makes into a reading for a datalog.Logger or bridge.Bridge.
+package bus
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/mqtt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoValue is returned by a Reader whose topic has not been published.
+var ErrNoValue = errors.New("Nothing published")
+
+// DefaultSize is the length of a subscription's queue if Options doesn't
+// give one.
+var DefaultSize = 16
+
+// An Event is something published on a topic.  Value holds a number, such
+// as a pin's value, an encoder's movement or a reading; Text holds any
+// words, such as the kind of an input event or an error.
+type Event struct {
+	Topic string
+	Value float64
+	Text  string
+	Time  time.Time
+}
+
+// A Policy says what a subscription does with an event when its queue is
+// full.
+type Policy int
+
+const (
+	// DropOldest throws away the oldest queued event to make room, so the
+	// subscriber always sees the latest.  It is the default.
+	DropOldest Policy = iota
+	// DropNewest throws away the new event, so the subscriber sees events
+	// in an unbroken run up to when it fell behind.
+	DropNewest
+	// Block makes the publisher wait for room, so that nothing is lost.
+	// Use it only for subscribers which keep up, as one which doesn't
+	// holds up everything publishing to it.
+	Block
+)
+
+func (p Policy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop oldest"
+	case DropNewest:
+		return "drop newest"
+	case Block:
+		return "block"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// Options configure a subscription.
+type Options struct {
+	// Size is the length of the queue, DefaultSize if zero.
+	Size int
+	// Policy is what to do when the queue is full.
+	Policy Policy
+}
+
+// A Bus passes published events to the subscriptions whose filters match
+// their topics.  It is safe to use from any number of goroutines.
+type Bus struct {
+	lock   sync.Mutex
+	subs   []*Subscription
+	last   map[string]Event
+	closed bool
+}
+
+// New returns an empty Bus.
+func New() *Bus {
+	return &Bus{last: make(map[string]Event)}
+}
+
+// Publish publishes a value on a topic.
+func (b *Bus) Publish(topic string, value float64) {
+	b.Send(Event{Topic: topic, Value: value})
+}
+
+// PublishText publishes some text on a topic.
+func (b *Bus) PublishText(topic, text string) {
+	b.Send(Event{Topic: topic, Text: text})
+}
+
+// Send publishes an event, timestamping it now if it has no time.  Events
+// sent after the bus is closed are ignored.
+func (b *Bus) Send(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return
+	}
+	b.last[ev.Topic] = ev
+	var subs []*Subscription
+	for _, s := range b.subs {
+		if mqtt.Match(s.filter, ev.Topic) {
+			subs = append(subs, s)
+		}
+	}
+	b.lock.Unlock()
+
+	for _, s := range subs {
+		s.deliver(ev)
+	}
+}
+
+// Last returns the last event published on a topic, and whether there has
+// been one.
+func (b *Bus) Last(topic string) (ev Event, ok bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	ev, ok = b.last[topic]
+	return
+}
+
+// Reader returns a function reading the last value published on a topic,
+// which may be used as a datalog.Source or a bridge.Reading.  It returns
+// ErrNoValue until something is published.
+func (b *Bus) Reader(topic string) func() (float64, error) {
+	return func() (float64, error) {
+		ev, ok := b.Last(topic)
+		if !ok {
+			return 0, fmt.Errorf("%w: %s", ErrNoValue, topic)
+		}
+		return ev.Value, nil
+	}
+}
+
+// Subscribe returns a subscription to the topics matching filter, which
+// may contain the wildcards + and # as for MQTT.  If the bus is closed, the
+// subscription's channel is already closed.
+func (b *Bus) Subscribe(filter string, opts Options) (s *Subscription) {
+	if opts.Size <= 0 {
+		opts.Size = DefaultSize
+	}
+	s = &Subscription{
+		bus:    b,
+		filter: filter,
+		policy: opts.Policy,
+		c:      make(chan Event, opts.Size),
+		stop:   make(chan struct{}),
+	}
+	s.C = s.c
+
+	b.lock.Lock()
+	closed := b.closed
+	if !closed {
+		b.subs = append(b.subs, s)
+	}
+	b.lock.Unlock()
+	if closed {
+		s.Close()
+	}
+	return
+}
+
+// Handle subscribes to filter and calls fn with each event from one
+// goroutine, until the subscription is closed.
+func (b *Bus) Handle(filter string, opts Options, fn func(Event)) (s *Subscription) {
+	s = b.Subscribe(filter, opts)
+	go func() {
+		for ev := range s.C {
+			fn(ev)
+		}
+	}()
+	return
+}
+
+// remove forgets a subscription.
+func (b *Bus) remove(s *Subscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for i, sub := range b.subs {
+		if sub == s {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Close closes every subscription.  Nothing more is delivered, and new
+// subscriptions are closed at once.
+func (b *Bus) Close() {
+	b.lock.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.closed = true
+	b.lock.Unlock()
+
+	for _, s := range subs {
+		s.Close()
+	}
+}
+
+// A Subscription receives events on C until it or its Bus is closed, when
+// C is closed.
+type Subscription struct {
+	C <-chan Event
+
+	bus     *Bus
+	filter  string
+	policy  Policy
+	c       chan Event
+	dropped uint64
+
+	// lock is held for reading while delivering, and for writing to
+	// close c once no delivery is under way.
+	lock sync.RWMutex
+	stop chan struct{}
+	once sync.Once
+}
+
+// deliver queues an event according to the subscription's policy.
+func (s *Subscription) deliver(ev Event) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	select {
+	case <-s.stop:
+		return
+	default:
+	}
+
+	switch s.policy {
+	case Block:
+		select {
+		case s.c <- ev:
+		case <-s.stop:
+		}
+	case DropNewest:
+		select {
+		case s.c <- ev:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	default:
+		for {
+			select {
+			case s.c <- ev:
+				return
+			default:
+			}
+			select {
+			case <-s.c:
+				atomic.AddUint64(&s.dropped, 1)
+			default:
+			}
+		}
+	}
+}
+
+// Filter returns the filter the subscription was made with.
+func (s *Subscription) Filter() string {
+	return s.filter
+}
+
+// Dropped returns how many events have been thrown away because the queue
+// was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close ends the subscription and closes C.  Events already queued may
+// still be received.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		s.bus.remove(s)
+		s.lock.Lock()
+		close(s.c)
+		s.lock.Unlock()
+	})
+}
@@ -0,0 +1,64 @@
+package bus
+
+import (
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/input"
+	"github.com/Ratfink/gopherbone/mqtt"
+	"github.com/Ratfink/gopherbone/sched"
+	"strconv"
+	"time"
+)
+
+// Input publishes the events of an input source until it is closed, each
+// on prefix/ followed by the name of what sent it.  The event's Text is its
+// type, such as "press" or "move", and its Value is the input event's.
+func (b *Bus) Input(prefix string, src input.Source) {
+	go func() {
+		for ev := range src.Events() {
+			b.Send(Event{
+				Topic: prefix + "/" + ev.Source,
+				Value: float64(ev.Value),
+				Text:  ev.Type.String(),
+				Time:  ev.Time,
+			})
+		}
+	}()
+}
+
+// Pin publishes each value a pin watcher sends, until it is closed.
+func (b *Bus) Pin(topic string, w *gpio.Watcher) {
+	go func() {
+		for v := range w.C {
+			b.Publish(topic, float64(v))
+		}
+	}()
+}
+
+// Poll publishes a reading on topic every interval, such as a sensor's
+// value, until the returned loop is stopped.  Errors reading are published
+// as text on topic/error, as the bridge package does.
+func (b *Bus) Poll(topic string, read func() (float64, error), interval time.Duration) (*sched.Loop, error) {
+	return sched.New(interval, sched.Options{}, func(sched.Tick) error {
+		if v, err := read(); err != nil {
+			b.PublishText(topic+"/error", err.Error())
+		} else {
+			b.Publish(topic, v)
+		}
+		return nil
+	})
+}
+
+// Forward publishes the events matching filter to an MQTT broker, each on
+// prefix/ followed by its topic and retained.  The payload is the event's
+// Text if it has any, or else its Value.  Publishing is done from the
+// subscription's goroutine, so a slow broker drops events as opts says
+// rather than holding up the bus.
+func (b *Bus) Forward(client *mqtt.Client, prefix, filter string, opts Options) *Subscription {
+	return b.Handle(filter, opts, func(ev Event) {
+		payload := ev.Text
+		if payload == "" {
+			payload = strconv.FormatFloat(ev.Value, 'g', -1, 64)
+		}
+		client.Publish(prefix+"/"+ev.Topic, []byte(payload), true)
+	})
+}
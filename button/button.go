@@ -0,0 +1,224 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package button turns a GPIO wired to a push button into a stream of
+// higher-level events, such as clicks and long presses.
+package button
+
+import (
+	"github.com/Ratfink/gopherbone/gpio"
+	"sync"
+	"time"
+)
+
+// An Event is something which happened to a Button.
+type Event int
+
+// Events sent by a Button.  Press and Release are sent for every debounced
+// change of the button's state.  Click is sent after a short press once the
+// double click time has passed without a second press, DoubleClick is sent
+// instead of Click when the button is clicked twice in quick succession, and
+// LongPress is sent when the button has been held for the long press time.
+const (
+	Press Event = iota
+	Release
+	Click
+	DoubleClick
+	LongPress
+)
+
+func (ev Event) String() string {
+	switch ev {
+	case Press:
+		return "Press"
+	case Release:
+		return "Release"
+	case Click:
+		return "Click"
+	case DoubleClick:
+		return "DoubleClick"
+	case LongPress:
+		return "LongPress"
+	}
+	return "Unknown"
+}
+
+// Default timings used by new Buttons.
+const (
+	DefaultDebounce    = 20 * time.Millisecond
+	DefaultDoubleClick = 300 * time.Millisecond
+	DefaultLongPress   = time.Second
+)
+
+// A Button watches a GPIO input and sends Events on the channel C.
+type Button struct {
+	C <-chan Event
+
+	c       chan Event
+	pin     gpio.DigitalPin
+	watcher *gpio.Watcher
+	done    chan struct{}
+
+	lock        sync.Mutex
+	doubleClick time.Duration
+	longPress   time.Duration
+}
+
+// New exports the given pin, configures it as a debounced input and starts
+// watching it.  If activeLow is true, the button is considered pressed when
+// the pin reads low, as it does for a button which shorts a pulled up pin to
+// ground.
+func New(pin int, activeLow bool) (button *Button, err error) {
+	g, err := gpio.Export(pin)
+	if err != nil {
+		return
+	}
+
+	return NewPin(g, activeLow)
+}
+
+// NewPin is like New, but uses a pin which has already been set up, such as
+// one on an I/O expander.
+func NewPin(pin gpio.DigitalPin, activeLow bool) (button *Button, err error) {
+	err = pin.SetDirection(gpio.In)
+	if err != nil {
+		return
+	}
+	err = pin.SetActiveLow(activeLow)
+	if err != nil {
+		return
+	}
+	pin.Debounce(DefaultDebounce)
+
+	button = &Button{
+		c:           make(chan Event, 16),
+		pin:         pin,
+		done:        make(chan struct{}),
+		doubleClick: DefaultDoubleClick,
+		longPress:   DefaultLongPress,
+	}
+	button.C = button.c
+
+	button.watcher, err = pin.Watch(gpio.Both)
+	if err != nil {
+		button = nil
+		return
+	}
+
+	go button.run()
+
+	return
+}
+
+// SetDoubleClick sets the longest time between two clicks for them to be
+// reported as a DoubleClick.  Setting it to zero disables double clicks, which
+// also means Click is sent as soon as the button is released.
+func (button *Button) SetDoubleClick(d time.Duration) {
+	button.lock.Lock()
+	defer button.lock.Unlock()
+	button.doubleClick = d
+}
+
+// SetLongPress sets how long the button must be held to send LongPress.
+func (button *Button) SetLongPress(d time.Duration) {
+	button.lock.Lock()
+	defer button.lock.Unlock()
+	button.longPress = d
+}
+
+func (button *Button) timings() (doubleClick, longPress time.Duration) {
+	button.lock.Lock()
+	defer button.lock.Unlock()
+	return button.doubleClick, button.longPress
+}
+
+func (button *Button) run() {
+	defer close(button.done)
+	defer close(button.c)
+
+	var holdC, clickC <-chan time.Time
+	var clicks int
+	var held bool
+
+	for {
+		doubleClick, longPress := button.timings()
+
+		select {
+		case value, ok := <-button.watcher.C:
+			if !ok {
+				return
+			}
+			if value == 1 {
+				button.c <- Press
+				held = false
+				clickC = nil
+				holdC = time.After(longPress)
+				continue
+			}
+
+			button.c <- Release
+			holdC = nil
+			if held {
+				continue
+			}
+			clicks++
+			if clicks == 2 {
+				button.c <- DoubleClick
+				clicks = 0
+			} else if doubleClick > 0 {
+				clickC = time.After(doubleClick)
+			} else {
+				button.c <- Click
+				clicks = 0
+			}
+
+		case <-holdC:
+			// A click waiting on a possible double click happened first
+			if clicks > 0 {
+				button.c <- Click
+				clicks = 0
+			}
+			button.c <- LongPress
+			held = true
+			holdC = nil
+
+		case <-clickC:
+			button.c <- Click
+			clicks = 0
+			clickC = nil
+		}
+	}
+}
+
+// Close stops watching the button, closes its channel and unexports its pin.
+func (button *Button) Close() (err error) {
+	err = button.watcher.Close()
+	// Drain the channel so that run can't block sending to it
+	for range button.c {
+	}
+	<-button.done
+	if err != nil {
+		return
+	}
+
+	return button.pin.Unexport()
+}
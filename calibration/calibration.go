@@ -0,0 +1,269 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package calibration converts raw sensor readings, such as ADC counts or
+// volts, to engineering units.  A Calibration is a polynomial, of which a
+// linear gain and offset is the usual case, or a table of points to
+// interpolate between.  Either can be fitted to points captured by reading
+// the sensor against known references, saved to disk with the others for a
+// board, and applied to an ADC input or any sensor reading function.
+package calibration
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/adc"
+	"math"
+	"sort"
+)
+
+// ErrPoints is returned when there are too few points to fit, or they don't
+// determine a calibration, such as two points with the same raw reading.
+var ErrPoints = errors.New("Not enough distinct points")
+
+// A Point pairs a raw reading with the true value it corresponds to.
+type Point struct {
+	Raw   float64 `json:"raw"`
+	Value float64 `json:"value"`
+}
+
+// A Calibration converts raw readings to values.  If Table is set, readings
+// are interpolated linearly between its points, and extrapolated from the
+// ends.  Otherwise Coefficients are those of a polynomial in the reading,
+// lowest order first, so a linear calibration is {offset, gain}.  With
+// neither, readings are passed through unchanged.
+type Calibration struct {
+	Unit         string    `json:"unit,omitempty"`
+	Coefficients []float64 `json:"coefficients,omitempty"`
+	Table        []Point   `json:"table,omitempty"`
+	// Points are those the calibration was fitted to, kept for reference.
+	Points []Point `json:"points,omitempty"`
+}
+
+// Linear returns a calibration computing gain*raw + offset.
+func Linear(gain, offset float64) *Calibration {
+	return &Calibration{Coefficients: []float64{offset, gain}}
+}
+
+// Polynomial returns a calibration computing a polynomial with the given
+// coefficients, lowest order first.
+func Polynomial(coefficients ...float64) *Calibration {
+	return &Calibration{Coefficients: append([]float64(nil), coefficients...)}
+}
+
+// Apply converts a raw reading.
+func (c *Calibration) Apply(raw float64) (value float64) {
+	if len(c.Table) > 0 {
+		return interpolate(c.Table, raw)
+	}
+	if len(c.Coefficients) == 0 {
+		return raw
+	}
+	for i := len(c.Coefficients) - 1; i >= 0; i-- {
+		value = value*raw + c.Coefficients[i]
+	}
+	return
+}
+
+// MaxError returns the largest difference between a point's value and the
+// calibration applied to its raw reading, a check on how well a fit worked.
+func (c *Calibration) MaxError(points []Point) (max float64) {
+	for _, p := range points {
+		max = math.Max(max, math.Abs(c.Apply(p.Raw)-p.Value))
+	}
+	return
+}
+
+func interpolate(table []Point, raw float64) float64 {
+	if len(table) == 1 {
+		return table[0].Value
+	}
+	i := sort.Search(len(table)-1, func(i int) bool { return table[i+1].Raw >= raw })
+	if i == len(table)-1 {
+		i--
+	}
+	a, b := table[i], table[i+1]
+	return a.Value + (raw-a.Raw)*(b.Value-a.Value)/(b.Raw-a.Raw)
+}
+
+// Interpolate returns a calibration interpolating between the points, for
+// sensors with no simple curve, such as thermistors read over a wide range.
+func Interpolate(points []Point) (c *Calibration, err error) {
+	table := append([]Point(nil), points...)
+	sort.Slice(table, func(i, j int) bool { return table[i].Raw < table[j].Raw })
+	for i := 1; i < len(table); i++ {
+		if table[i].Raw == table[i-1].Raw {
+			err = fmt.Errorf("%w: two points at %g", ErrPoints, table[i].Raw)
+			return
+		}
+	}
+	if len(table) < 2 {
+		err = fmt.Errorf("%w: need 2, have %d", ErrPoints, len(table))
+		return
+	}
+	c = &Calibration{Table: table, Points: append([]Point(nil), points...)}
+	return
+}
+
+// Fit returns the polynomial of the given degree which fits the points best,
+// by least squares.  Degree 1 is a linear calibration; two points give an
+// exact one.
+func Fit(points []Point, degree int) (c *Calibration, err error) {
+	if degree < 0 {
+		err = fmt.Errorf("Invalid degree: %d", degree)
+		return
+	}
+	if len(points) < degree+1 {
+		err = fmt.Errorf("%w: need %d, have %d", ErrPoints, degree+1, len(points))
+		return
+	}
+
+	// Fit in terms of t = (raw - mid) / scale, which lies within [-1, 1],
+	// to keep the normal equations well conditioned
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		lo, hi = math.Min(lo, p.Raw), math.Max(hi, p.Raw)
+	}
+	mid, scale := (lo+hi)/2, (hi-lo)/2
+	if scale == 0 {
+		scale = 1
+	}
+
+	n := degree + 1
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n+1)
+	}
+	for _, p := range points {
+		t := (p.Raw - mid) / scale
+		pow := make([]float64, 2*n)
+		pow[0] = 1
+		for k := 1; k < len(pow); k++ {
+			pow[k] = pow[k-1] * t
+		}
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				a[i][j] += pow[i+j]
+			}
+			a[i][n] += pow[i] * p.Value
+		}
+	}
+	coeffs, err := solve(a)
+	if err != nil {
+		return
+	}
+
+	// Expand the polynomial in t back into one in raw
+	c = &Calibration{Coefficients: make([]float64, n), Points: append([]Point(nil), points...)}
+	for k, ak := range coeffs {
+		for j := 0; j <= k; j++ {
+			c.Coefficients[j] += ak * binomial(k, j) * math.Pow(-mid, float64(k-j)) / math.Pow(scale, float64(k))
+		}
+	}
+	return
+}
+
+// solve solves the linear equations given by an augmented matrix, by
+// Gaussian elimination with partial pivoting.
+func solve(a [][]float64) (x []float64, err error) {
+	n := len(a)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			err = fmt.Errorf("%w: points don't determine a degree %d fit", ErrPoints, n-1)
+			return
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := col + 1; row < n; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k <= n; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+
+	x = make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := a[row][n]
+		for k := row + 1; k < n; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return
+}
+
+func binomial(n, k int) float64 {
+	b := 1.0
+	for i := 1; i <= k; i++ {
+		b = b * float64(n-k+i) / float64(i)
+	}
+	return b
+}
+
+// Func returns a reading function which calls read and applies the
+// calibration, for sensor drivers and packages such as datalog which take
+// one.
+func (c *Calibration) Func(read func() (float64, error)) func() (float64, error) {
+	return func() (value float64, err error) {
+		raw, err := read()
+		if err != nil {
+			return
+		}
+		return c.Apply(raw), nil
+	}
+}
+
+// calibratedPin is an AnalogPin whose Voltage is calibrated.
+type calibratedPin struct {
+	read func() (float64, error)
+}
+
+func (p calibratedPin) Voltage() (float64, error) {
+	return p.read()
+}
+
+// Pin returns an adc.AnalogPin whose Voltage method returns the calibration
+// applied to pin's voltage, in the calibration's units rather than volts, so
+// that it can be given to an adc.Watcher, the server or the MQTT bridge.
+func (c *Calibration) Pin(pin adc.AnalogPin) adc.AnalogPin {
+	return calibratedPin{c.Func(pin.Voltage)}
+}
+
+// Counts returns a reading function giving an AIN's raw conversions, from 0
+// to adc.MAX_RAW, for calibrations done in counts rather than volts.
+func Counts(ain *adc.AIN) func() (float64, error) {
+	return func() (float64, error) {
+		raw, err := ain.Raw()
+		return float64(raw), err
+	}
+}
+
+// CountsPin is like Pin, but calibrates an AIN's raw counts.
+func (c *Calibration) CountsPin(ain *adc.AIN) adc.AnalogPin {
+	return calibratedPin{c.Func(Counts(ain))}
+}
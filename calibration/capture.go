@@ -0,0 +1,65 @@
+package calibration
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Capture collects calibration points by reading a sensor while it
+// measures known references: for each, set up the reference, such as a
+// known weight or a reference thermometer's reading, and call Add with its
+// value.  Each point's raw reading is the mean of several, to average out
+// noise.
+type Capture struct {
+	Points []Point
+
+	read     func() (float64, error)
+	samples  int
+	interval time.Duration
+}
+
+// NewCapture returns a Capture reading raw values with read, averaging
+// samples readings taken interval apart for each point.
+func NewCapture(read func() (float64, error), samples int, interval time.Duration) *Capture {
+	if samples < 1 {
+		samples = 1
+	}
+	return &Capture{read: read, samples: samples, interval: interval}
+}
+
+// Add reads the sensor and records a point for the reference value.
+func (c *Capture) Add(value float64) (p Point, err error) {
+	var sum float64
+	for i := 0; i < c.samples; i++ {
+		if i > 0 {
+			time.Sleep(c.interval)
+		}
+		var raw float64
+		if raw, err = c.read(); err != nil {
+			err = fmt.Errorf("Reading point %d: %w", len(c.Points), err)
+			return
+		}
+		sum += raw
+	}
+	p = Point{Raw: sum / float64(c.samples), Value: value}
+	c.Points = append(c.Points, p)
+	return
+}
+
+// Undo removes the last point, if it was taken wrongly.
+func (c *Capture) Undo() {
+	if len(c.Points) > 0 {
+		c.Points = c.Points[:len(c.Points)-1]
+	}
+}
+
+// Fit fits a polynomial of the given degree to the points, as by Fit.
+func (c *Capture) Fit(degree int) (*Calibration, error) {
+	return Fit(c.Points, degree)
+}
+
+// Interpolate makes an interpolated calibration from the points, as by
+// Interpolate.
+func (c *Capture) Interpolate() (*Calibration, error) {
+	return Interpolate(c.Points)
+}
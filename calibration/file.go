@@ -0,0 +1,69 @@
+package calibration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// A Set is the calibrations for a board, keyed by the sensor's name.
+type Set map[string]*Calibration
+
+// Load reads a Set saved as JSON by Save.  A missing file is an error for
+// which errors.Is(err, fs.ErrNotExist) holds, so a program can start with an
+// empty Set on first run.
+func Load(path string) (s Set, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &s); err != nil {
+		err = fmt.Errorf("%s: %w", path, err)
+		s = nil
+	}
+	return
+}
+
+// Get returns the named calibration, or an identity calibration if there is
+// none, so that an uncalibrated sensor reads raw values.
+func (s Set) Get(name string) *Calibration {
+	if c := s[name]; c != nil {
+		return c
+	}
+	return &Calibration{}
+}
+
+// Save writes the Set to path as JSON.  The file is replaced atomically and
+// synced, so a power cut while saving leaves either the old calibrations or
+// the new ones.
+func (s Set) Save(path string) (err error) {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	_, err = f.Write(append(data, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return
+	}
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		return
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,263 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* This package loads and unloads device tree overlays, which many of the
+ * BeagleBone's peripherals need before they will show up in sysfs.  Older
+ * kernels do this at runtime through the cape manager's slots file; newer
+ * images instead list overlays in /boot/uEnv.txt for U-Boot to apply at the
+ * next boot.
+ */
+package capemgr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UEnvPath is the location of the U-Boot environment file used when the cape
+// manager's slots file is unavailable.
+var UEnvPath = "/boot/uEnv.txt"
+
+// ErrRebootRequired is returned when an overlay has been added to the U-Boot
+// environment, and so will not take effect until the board is rebooted.
+var ErrRebootRequired = errors.New("capemgr: overlay will be loaded on next boot")
+
+// A Slot is an entry in the cape manager's slots file.
+type Slot struct {
+	Number int
+	Name   string
+}
+
+// slotsFile returns the path of the cape manager's slots file, if any.
+func slotsFile() (path string, err error) {
+	for _, pattern := range []string{
+		"/sys/devices/platform/bone_capemgr/slots",
+		"/sys/devices/bone_capemgr.*/slots",
+	} {
+		var matches []string
+		matches, err = filepath.Glob(pattern)
+		if err != nil {
+			return
+		}
+		if len(matches) > 0 {
+			path = matches[0]
+			return
+		}
+	}
+
+	err = errors.New("capemgr: no cape manager slots file found")
+	return
+}
+
+// Slots returns the slots currently known to the cape manager.
+func Slots() (slots []Slot, err error) {
+	path, err := slotsFile()
+	if err != nil {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var slot Slot
+		line := scanner.Text()
+		if n, _ := fmt.Sscanf(line, "%d:", &slot.Number); n != 1 {
+			continue
+		}
+		if i := strings.LastIndex(line, ","); i >= 0 {
+			slot.Name = strings.TrimSpace(line[i+1:])
+		}
+		slots = append(slots, slot)
+	}
+	err = scanner.Err()
+
+	return
+}
+
+// Loaded reports whether the named overlay is loaded.  On systems using U-Boot
+// overlays, this reports whether the overlay is listed in the U-Boot
+// environment.
+func Loaded(name string) (loaded bool, err error) {
+	if _, err = slotsFile(); err != nil {
+		var overlays []string
+		overlays, err = ubootOverlays()
+		for _, overlay := range overlays {
+			if overlay == name {
+				loaded = true
+			}
+		}
+		return
+	}
+
+	slots, err := Slots()
+	for _, slot := range slots {
+		if slot.Name == name {
+			loaded = true
+		}
+	}
+
+	return
+}
+
+// Load loads the named overlay, such as "BB-I2C2".  Loading an overlay which
+// is already loaded does nothing.  If the cape manager is unavailable, the
+// overlay is added to the U-Boot environment instead and ErrRebootRequired is
+// returned.
+func Load(name string) (err error) {
+	loaded, err := Loaded(name)
+	if err != nil || loaded {
+		return
+	}
+
+	path, err := slotsFile()
+	if err != nil {
+		return loadUboot(name)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s", name)
+
+	return
+}
+
+// Unload unloads the named overlay.  Unloading overlays is unreliable on many
+// kernels, and is not supported at all for U-Boot overlays.
+func Unload(name string) (err error) {
+	path, err := slotsFile()
+	if err != nil {
+		return
+	}
+	slots, err := Slots()
+	if err != nil {
+		return
+	}
+
+	for _, slot := range slots {
+		if slot.Name != name {
+			continue
+		}
+		var f *os.File
+		f, err = os.OpenFile(path, os.O_WRONLY, 0666)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		_, err = fmt.Fprintf(f, "-%d", slot.Number)
+		return
+	}
+
+	return fmt.Errorf("capemgr: overlay not loaded: %s", name)
+}
+
+// ubootOverlays returns the overlays listed in the U-Boot environment.
+func ubootOverlays() (overlays []string, err error) {
+	f, err := os.Open(UEnvPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "uboot_overlay_addr") {
+			continue
+		}
+		if i := strings.Index(line, "="); i >= 0 {
+			overlays = append(overlays, strings.TrimSuffix(filepath.Base(line[i+1:]), ".dtbo"))
+		}
+	}
+	err = scanner.Err()
+
+	return
+}
+
+// loadUboot adds the named overlay to the first free uboot_overlay_addr slot
+// in the U-Boot environment.
+func loadUboot(name string) (err error) {
+	data, err := os.ReadFile(UEnvPath)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(string(data), "\n")
+
+	used := make(map[int]bool)
+	for _, line := range lines {
+		var n int
+		if c, _ := fmt.Sscanf(strings.TrimSpace(line), "uboot_overlay_addr%d=", &n); c == 1 {
+			used[n] = true
+		}
+	}
+
+	for n := 0; n < 8; n++ {
+		if used[n] {
+			continue
+		}
+		entry := fmt.Sprintf("uboot_overlay_addr%d=%s/%s.dtbo", n, FirmwarePath, name)
+		if len(lines) > 0 && lines[len(lines)-1] == "" {
+			lines[len(lines)-1] = entry
+			lines = append(lines, "")
+		} else {
+			lines = append(lines, entry)
+		}
+		err = os.WriteFile(UEnvPath, []byte(strings.Join(lines, "\n")), 0644)
+		if err == nil {
+			err = ErrRebootRequired
+		}
+		return
+	}
+
+	return fmt.Errorf("capemgr: no free overlay slots in %s", UEnvPath)
+}
+
+// EnableI2C2 loads the overlay for the I2C2 bus on P9_19 and P9_20.
+func EnableI2C2() error {
+	return Load("BB-I2C2")
+}
+
+// EnableI2C1 loads the overlay for the I2C1 bus on P9_17 and P9_18.
+func EnableI2C1() error {
+	return Load("BB-I2C1")
+}
+
+// EnablePWM loads the overlays needed to use the named header pin, such as
+// "P9_14", as a PWM output.
+func EnablePWM(pin string) (err error) {
+	err = Load("am33xx_pwm")
+	if err != nil {
+		return
+	}
+	return Load("bone_pwm_" + pin)
+}
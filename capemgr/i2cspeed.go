@@ -0,0 +1,83 @@
+package capemgr
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// FirmwarePath is where overlays are installed.
+var FirmwarePath = "/lib/firmware"
+
+// i2cSpeedOverlay is the source of an overlay which sets the clock frequency
+// of an I2C controller.  The part-number and version are needed by the cape
+// manager, and ignored by U-Boot.
+const i2cSpeedOverlay = `/dts-v1/;
+/plugin/;
+
+/ {
+	compatible = "ti,beaglebone", "ti,beaglebone-black", "ti,beaglebone-green";
+	part-number = "%s";
+	version = "00A0";
+
+	fragment@0 {
+		target = <&i2c%d>;
+		__overlay__ {
+			clock-frequency = <%d>;
+		};
+	};
+};
+`
+
+// SetI2CSpeed builds an overlay setting the clock frequency of the I2C
+// controller I2Cn to hz, installs it in FirmwarePath and loads it.  The
+// default speed is normally 100kHz; most devices also support 400kHz fast
+// mode.  The overlay is compiled with dtc, which must be installed.
+//
+// Note that n is the controller number, as in the names of the BB-I2C
+// overlays, which older kernels don't use to number /dev/i2c-N.  The
+// controller only reads its frequency when it is probed, so the new speed
+// takes effect when the bus is next enabled, or after a reboot.  As with Load,
+// ErrRebootRequired is returned if the overlay was added to the U-Boot
+// environment.
+func SetI2CSpeed(n int, hz uint32) (err error) {
+	if n < 0 || n > 2 {
+		err = fmt.Errorf("capemgr: invalid I2C controller: %d", n)
+		return
+	}
+	if hz == 0 || hz > 400000 {
+		err = fmt.Errorf("capemgr: invalid I2C speed: %d", hz)
+		return
+	}
+
+	name := fmt.Sprintf("GB-I2C%d-%dHZ", n, hz)
+	src, err := os.CreateTemp("", name+"-*.dts")
+	if err != nil {
+		return
+	}
+	defer os.Remove(src.Name())
+	_, err = fmt.Fprintf(src, i2cSpeedOverlay, name, n, hz)
+	if e := src.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return
+	}
+
+	// The cape manager looks for name-version.dtbo; U-Boot for name.dtbo
+	dtbo := fmt.Sprintf("%s/%s-00A0.dtbo", FirmwarePath, name)
+	out, err := exec.Command("dtc", "-@", "-I", "dts", "-O", "dtb", "-o", dtbo, src.Name()).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("capemgr: dtc: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	data, err := os.ReadFile(dtbo)
+	if err != nil {
+		return
+	}
+	if err = os.WriteFile(fmt.Sprintf("%s/%s.dtbo", FirmwarePath, name), data, 0644); err != nil {
+		return
+	}
+
+	return Load(name)
+}
@@ -0,0 +1,151 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package gopherbone keeps track of what the other GopherBone packages have
+// set up that should not outlive the program: exported GPIO pins, enabled PWM
+// channels, running motors, open buses and lit displays.  They register
+// themselves as they are created and unregister when closed, and Cleanup
+// releases whatever is left, newest first.  Call HandleSignals at the start
+// of main, and defer CleanupOnPanic, so that the program leaves the board
+// safe however it ends.
+package gopherbone
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// A Handle is a registered resource, returned by Register.
+type Handle struct {
+	name    string
+	release func() error
+}
+
+var (
+	registry     []*Handle
+	registryLock sync.Mutex
+)
+
+// Register adds a resource to be released by Cleanup, with a name for
+// Registered to report.  Its owner should call Unregister when it releases
+// the resource itself.
+func Register(name string, release func() error) *Handle {
+	h := &Handle{name: name, release: release}
+	registryLock.Lock()
+	registry = append(registry, h)
+	registryLock.Unlock()
+	return h
+}
+
+// Unregister removes the resource from the registry.  It may be called on a
+// nil Handle, or more than once, and does nothing then.
+func (h *Handle) Unregister() {
+	if h == nil {
+		return
+	}
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	for i, r := range registry {
+		if r == h {
+			registry = append(registry[:i], registry[i+1:]...)
+			return
+		}
+	}
+}
+
+// Registered returns the names of the resources not yet released, oldest
+// first.
+func Registered() (names []string) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	for _, h := range registry {
+		names = append(names, h.name)
+	}
+	return
+}
+
+// Cleanup releases every registered resource, newest first, so that for
+// example a motor is stopped before the pins driving it are unexported.
+// Everything is released even if something fails, and the first error is
+// returned.  Resources registered afterwards are left for the next Cleanup.
+func Cleanup() (err error) {
+	registryLock.Lock()
+	handles := registry
+	registry = nil
+	registryLock.Unlock()
+
+	for i := len(handles) - 1; i >= 0; i-- {
+		if e := handles[i].release(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+// HandleSignals makes the program run Cleanup and exit when it receives one
+// of sigs, or SIGINT or SIGTERM if none are given, rather than dying with
+// pins exported and motors running.  The exit status is 128 plus the signal
+// number, as a shell reports for a program killed by the signal.  The
+// returned function stops handling them.
+func HandleSignals(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sigs...)
+
+	go func() {
+		select {
+		case sig := <-c:
+			Cleanup()
+			status := 1
+			if s, ok := sig.(syscall.Signal); ok {
+				status = 128 + int(s)
+			}
+			os.Exit(status)
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+// CleanupOnPanic runs Cleanup if the program is panicking, then carries on
+// panicking.  Defer it at the start of main:
+//
+//	defer gopherbone.CleanupOnPanic()
+func CleanupOnPanic() {
+	if r := recover(); r != nil {
+		Cleanup()
+		panic(r)
+	}
+}
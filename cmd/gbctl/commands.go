@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/adc"
+	"github.com/Ratfink/gopherbone/board"
+	"github.com/Ratfink/gopherbone/display"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/i2c"
+	"github.com/Ratfink/gopherbone/pwm"
+	"github.com/Ratfink/gopherbone/ssd1306"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// lookupGPIO returns the GPIO number of a pin given by name or number.
+func lookupGPIO(name string) (n int, err error) {
+	if n, err = strconv.Atoi(name); err == nil {
+		return
+	}
+	pin, err := gpio.LookupPin(name)
+	if err != nil {
+		return
+	}
+	if pin.GPIO < 0 {
+		err = fmt.Errorf("Pin %s is not a GPIO", pin.Name)
+	}
+	n = pin.GPIO
+	return
+}
+
+// exported reports whether a GPIO is already exported.
+func exported(n int) bool {
+	_, err := os.Stat(fmt.Sprintf("%s/gpio%d", gpio.SysfsPath, n))
+	return err == nil
+}
+
+func gpioCmd(args []string) (err error) {
+	if len(args) < 2 {
+		return errUsage("gpio needs an action and a pin")
+	}
+	action, name := args[0], args[1]
+
+	if action == "mux" {
+		if len(args) != 3 {
+			return errUsage("gpio mux needs a pin and a mode")
+		}
+		pin, err := gpio.LookupPin(name)
+		if err != nil {
+			return err
+		}
+		return pin.Mux(args[2])
+	}
+
+	n, err := lookupGPIO(name)
+	if err != nil {
+		return
+	}
+	if action == "unexport" {
+		return (&gpio.GPIO{Pin: n}).Unexport()
+	}
+	was := exported(n)
+	g, err := gpio.Export(n)
+	if err != nil {
+		return
+	}
+
+	switch action {
+	case "export":
+	case "read":
+		var v int
+		if v, err = g.Value(); err == nil {
+			fmt.Println(v)
+		}
+		// Leave things as they were found
+		if !was {
+			g.Unexport()
+		}
+	case "write":
+		if len(args) != 3 || args[2] != "0" && args[2] != "1" {
+			return errUsage("gpio write needs a pin and 0 or 1")
+		}
+		// The pin stays exported, or it would stop driving the value
+		if err = g.SetDirection(gpio.Out); err == nil {
+			err = g.SetValue(int(args[2][0] - '0'))
+		}
+	default:
+		if !was {
+			g.Unexport()
+		}
+		err = errUsage("unknown gpio action " + action)
+	}
+
+	return
+}
+
+func i2cCmd(args []string) (err error) {
+	bus := 2
+	if len(args) > 1 {
+		return errUsage("i2c takes at most a bus number")
+	} else if len(args) == 1 {
+		if bus, err = strconv.Atoi(args[0]); err != nil || bus < 0 || bus > 255 {
+			return errUsage("invalid bus " + args[0])
+		}
+	}
+
+	addrs, err := i2c.Scan(byte(bus))
+	if err != nil {
+		return
+	}
+	found := make(map[int]bool)
+	for _, a := range addrs {
+		found[int(a)] = true
+	}
+
+	// Laid out like i2cdetect's output
+	fmt.Println("     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f")
+	for row := 0; row < 0x80; row += 0x10 {
+		fmt.Printf("%02x:", row)
+		for a := row; a < row+0x10; a++ {
+			switch {
+			case a < 0x03 || a > 0x77:
+				fmt.Print("   ")
+			case found[a]:
+				fmt.Printf(" %02x", a)
+			default:
+				fmt.Print(" --")
+			}
+		}
+		fmt.Println()
+	}
+
+	return
+}
+
+// parseDuty parses a fraction from 0 to 1, or a percentage.
+func parseDuty(s string) (duty float64, err error) {
+	if strings.HasSuffix(s, "%") {
+		duty, err = strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		duty /= 100
+	} else {
+		duty, err = strconv.ParseFloat(s, 64)
+	}
+	if err == nil && (duty < 0 || duty > 1) {
+		err = fmt.Errorf("Duty out of range: %s", s)
+	}
+	return
+}
+
+func pwmCmd(args []string) (err error) {
+	fs := flag.NewFlagSet("pwm", flag.ContinueOnError)
+	pinName := fs.String("pin", "", "pin to mux for PWM")
+	freq := fs.Float64("freq", 0, "frequency in hertz")
+	off := fs.Bool("off", false, "disable the channel")
+	if err = fs.Parse(args); err != nil {
+		return errUsage(err.Error())
+	}
+	args = fs.Args()
+	if *off && len(args) != 2 || !*off && len(args) != 3 {
+		return errUsage("pwm needs a chip, a channel and a duty")
+	}
+
+	chip, err1 := strconv.Atoi(args[0])
+	channel, err2 := strconv.Atoi(args[1])
+	if err1 != nil || err2 != nil {
+		return errUsage("invalid chip or channel")
+	}
+	if *pinName != "" {
+		pin, err := gpio.LookupPin(*pinName)
+		if err != nil {
+			return err
+		}
+		if err = pin.Mux("pwm"); err != nil {
+			return err
+		}
+	}
+
+	p, err := pwm.Export(chip, channel)
+	if err != nil {
+		return
+	}
+	if *off {
+		return p.Disable()
+	}
+
+	duty, err := parseDuty(args[2])
+	if err != nil {
+		return
+	}
+	if *freq > 0 {
+		if err = p.SetFrequency(*freq); err != nil {
+			return
+		}
+	}
+	if err = p.SetDuty(duty); err != nil {
+		return
+	}
+	return p.Enable()
+}
+
+func adcCmd(args []string) (err error) {
+	channels := []int{0, 1, 2, 3, 4, 5, 6}
+	if len(args) > 0 {
+		channels = channels[:0]
+		for _, a := range args {
+			n, e := strconv.Atoi(a)
+			if e != nil {
+				return errUsage("invalid channel " + a)
+			}
+			channels = append(channels, n)
+		}
+	}
+
+	for _, n := range channels {
+		ain, err := adc.Open(n)
+		if err != nil {
+			return err
+		}
+		raw, err := ain.Raw()
+		ain.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("AIN%d %.3f V (%d)\n", n, float64(raw)*adc.VREF/adc.MAX_RAW, raw)
+	}
+
+	return
+}
+
+func oledCmd(args []string) (err error) {
+	fs := flag.NewFlagSet("oled", flag.ContinueOnError)
+	bus := fs.Uint("bus", 2, "I2C bus")
+	addr := fs.Uint("addr", 0x3c, "I2C address")
+	reset := fs.String("reset", "", "reset pin")
+	width := fs.Int("width", 128, "width in pixels")
+	height := fs.Int("height", 64, "height in pixels")
+	extVCC := fs.Bool("ext-vcc", false, "panel has an external VCC supply")
+	if err = fs.Parse(args); err != nil {
+		return errUsage(err.Error())
+	}
+	args = fs.Args()
+	if len(args) < 2 || args[0] != "text" && args[0] != "image" {
+		return errUsage("oled needs text or an image")
+	}
+
+	rst := -1
+	if *reset != "" {
+		if rst, err = lookupGPIO(*reset); err != nil {
+			return
+		}
+	}
+	d, err := ssd1306.New(rst, ssd1306.IFACE_I2C, byte(*addr), byte(*bus), *width, *height)
+	if err != nil {
+		return
+	}
+	d.SetOptions(ssd1306.Options{ExternalVCC: *extVCC})
+	// The display is left on, showing what was sent, when gbctl exits
+	if err = d.Setup(); err != nil {
+		return
+	}
+
+	if args[0] == "text" {
+		var text string
+		if len(args) == 2 && args[1] == "-" {
+			var b []byte
+			if b, err = io.ReadAll(bufio.NewReader(os.Stdin)); err != nil {
+				return
+			}
+			text = string(b)
+		} else {
+			text = strings.Join(args[1:], " ")
+		}
+		return display.NewTerminal(d).Print(text)
+	}
+
+	f, err := os.Open(args[1])
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return
+	}
+	bm := display.Dither(img)
+	d.Clear(color.Black)
+	bm.Draw(d, (*width-bm.Width)/2, (*height-bm.Height)/2)
+	return d.Draw()
+}
+
+func boardCmd(args []string) (err error) {
+	info, err := board.Detect()
+	if err != nil {
+		return
+	}
+	fmt.Printf("Model:    %v\n", info.Model)
+	if info.DTModel != "" {
+		fmt.Printf("DT model: %s\n", info.DTModel)
+	}
+	if info.Revision != "" {
+		fmt.Printf("Revision: %s\n", info.Revision)
+	}
+	if info.Serial != "" {
+		fmt.Printf("Serial:   %s\n", info.Serial)
+	}
+	fmt.Printf("Headers:  %s\n", strings.Join(info.Headers(), " "))
+
+	return
+}
+
+func udevCmd(args []string) (err error) {
+	group := gopherbone.Group
+	if len(args) > 1 {
+		return errUsage("udev takes at most a group name")
+	} else if len(args) == 1 {
+		group = args[0]
+	}
+	fmt.Print(gopherbone.UdevRules(group))
+
+	return
+}
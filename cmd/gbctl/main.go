@@ -0,0 +1,102 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Gbctl works the BeagleBone's hardware from the shell, for bringing up
+// wiring before writing any Go.
+//
+// Usage:
+//
+//	gbctl gpio read PIN
+//	gbctl gpio write PIN 0|1
+//	gbctl gpio export|unexport PIN
+//	gbctl gpio mux PIN MODE
+//	gbctl i2c [BUS]
+//	gbctl pwm [-pin NAME] [-freq HZ] CHIP CHANNEL DUTY
+//	gbctl pwm -off CHIP CHANNEL
+//	gbctl adc [CHANNEL...]
+//	gbctl oled [flags] text TEXT...
+//	gbctl oled [flags] image FILE
+//	gbctl board
+//	gbctl udev [GROUP]
+//
+// Pins are given by header name, such as P8_10, by signal name, or by GPIO
+// number.  PWM duty is a fraction from 0 to 1, or a percentage such as 50%.
+// An oled text of "-" is read from standard input.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage:
+	gbctl gpio read PIN
+	gbctl gpio write PIN 0|1
+	gbctl gpio export|unexport PIN
+	gbctl gpio mux PIN MODE
+	gbctl i2c [BUS]
+	gbctl pwm [-pin NAME] [-freq HZ] CHIP CHANNEL DUTY
+	gbctl pwm -off CHIP CHANNEL
+	gbctl adc [CHANNEL...]
+	gbctl oled [-bus N] [-addr A] [-reset PIN] [-width W] [-height H] [-ext-vcc] text TEXT...
+	gbctl oled [-bus N] [-addr A] [-reset PIN] [-width W] [-height H] [-ext-vcc] image FILE
+	gbctl board
+	gbctl udev [GROUP]
+`
+
+var commands = map[string]func(args []string) error{
+	"gpio":  gpioCmd,
+	"i2c":   i2cCmd,
+	"pwm":   pwmCmd,
+	"adc":   adcCmd,
+	"oled":  oledCmd,
+	"board": boardCmd,
+	"udev":  udevCmd,
+}
+
+// errUsage makes main print the usage message.
+type errUsage string
+
+func (e errUsage) Error() string {
+	return string(e)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gbctl: unknown command %q\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "gbctl: %v\n", err)
+		if _, ok := err.(errUsage); ok {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
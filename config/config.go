@@ -0,0 +1,261 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package config sets up hardware from a description in a file, so that one
+// program can run on boards wired differently without being rebuilt.  The
+// description, in JSON or TOML, names GPIO pins, PWM channels, I2C devices
+// and displays; Open sets them all up and returns them by name.  In TOML:
+//
+//	[pins.led]
+//	pin = "P8_10"
+//	direction = "out"
+//	value = 0
+//
+//	[pins.button]
+//	pin = "P8_12"
+//	direction = "in"
+//	edge = "falling"
+//	debounce = "20ms"
+//
+//	[pwm.fan]
+//	pin = "P9_14"
+//	chip = 4
+//	channel = 0
+//	frequency = 25000
+//	duty = 0.5
+//	enable = true
+//
+//	[i2c.rtc]
+//	bus = 2
+//	addr = 0x68
+//
+//	[displays.oled]
+//	type = "ssd1306"
+//	bus = 2
+//	addr = 0x3c
+//	reset = "P9_23"
+//	width = 128
+//	height = 64
+//
+//	[displays.left]
+//	bus = 2
+//	addr = 0x3c
+//
+//	[displays.right]
+//	bus = 2
+//	addr = 0x3d
+//	x = 132
+//
+//	[groups.wide]
+//	mode = "tiled"
+//	displays = ["left", "right"]
+//
+//	[adc.light]
+//	channel = 1
+//
+//	[adc.temperature]
+//	channel = 2
+//	calibration = [-50, 100]
+//	unit = "°C"
+//
+//	[mqtt]
+//	broker = "localhost:1883"
+//	prefix = "home/shed"
+//	interval = "10s"
+//
+// The same in JSON has an object for each of "pins", "pwm", "i2c",
+// "displays", "groups", "adc" and "mqtt".  The mqtt table is used by the bridge
+// package rather than by Open.  Only the subset of TOML needed for this is understood:
+// tables, and keys, dotted or not, with string, number, boolean and array
+// values.  As in TOML, decimal numbers may not have leading zeros; use 0x,
+// 0o or 0b for other bases.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A Config describes a board's hardware.  Each map is keyed by the name
+// the program uses for the thing.
+type Config struct {
+	Pins     map[string]PinConfig     `json:"pins"`
+	PWM      map[string]PWMConfig     `json:"pwm"`
+	I2C      map[string]I2CConfig     `json:"i2c"`
+	Displays map[string]DisplayConfig `json:"displays"`
+	Groups   map[string]GroupConfig   `json:"groups"`
+	ADC      map[string]ADCConfig     `json:"adc"`
+	MQTT     MQTTConfig               `json:"mqtt"`
+}
+
+// A PinConfig describes a GPIO pin.  Pin is a header name such as "P8_10",
+// a signal name as understood by gpio.LookupPin, or a GPIO number.
+type PinConfig struct {
+	Pin string `json:"pin"`
+	// Mux, if set, is the pinmux mode to set first, such as "gpio_pu".
+	Mux string `json:"mux"`
+	// Direction is "in" or "out".  Outputs are set to Value.
+	Direction string `json:"direction"`
+	Value     int    `json:"value"`
+	ActiveLow bool   `json:"active_low"`
+	// Edge, if set, is the edge inputs interrupt on.
+	Edge     string   `json:"edge"`
+	Debounce Duration `json:"debounce"`
+}
+
+// A PWMConfig describes a PWM channel.  If Pin is set, that pin is muxed
+// for PWM.
+type PWMConfig struct {
+	Pin       string  `json:"pin"`
+	Chip      int     `json:"chip"`
+	Channel   int     `json:"channel"`
+	Frequency float64 `json:"frequency"`
+	Duty      float64 `json:"duty"`
+	Enable    bool    `json:"enable"`
+}
+
+// An I2CConfig describes a device on an I2C bus.
+type I2CConfig struct {
+	Bus  byte `json:"bus"`
+	Addr byte `json:"addr"`
+}
+
+// A DisplayConfig describes a display.  Type must be "ssd1306", which is
+// the default.  Reset names the reset pin as for PinConfig, and may be left
+// out if there is none.  X and Y place the display's top left corner when
+// it is part of a tiled group.  ExternalVCC and the settings after it are
+// as for ssd1306.Options, with zero meaning the default.
+type DisplayConfig struct {
+	Type   string `json:"type"`
+	Bus    byte   `json:"bus"`
+	Addr   byte   `json:"addr"`
+	Reset  string `json:"reset"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+
+	ExternalVCC bool `json:"external_vcc"`
+	Contrast    byte `json:"contrast"`
+	Precharge   byte `json:"precharge"`
+	Clock       byte `json:"clock"`
+	VCOMH       byte `json:"vcomh"`
+}
+
+// A GroupConfig combines displays named in Displays into one.  Mode is
+// "mirror", the default, to show the same picture on all of them, or
+// "tiled" to split one large canvas across them at their X and Y offsets.
+type GroupConfig struct {
+	Mode     string   `json:"mode"`
+	Displays []string `json:"displays"`
+}
+
+// An ADCConfig describes an analog input, AIN0 to AIN6.  If Calibration is
+// set, it is the coefficients of a polynomial converting volts to Unit,
+// lowest order first, as for a calibration.Calibration.
+type ADCConfig struct {
+	Channel     int       `json:"channel"`
+	Calibration []float64 `json:"calibration"`
+	Unit        string    `json:"unit"`
+}
+
+// An MQTTConfig describes the MQTT broker the bridge package connects to.
+// Broker is a host and port; if it is empty there is no bridge.  Topics are
+// under Prefix, and analog inputs are published every Interval.
+type MQTTConfig struct {
+	Broker    string   `json:"broker"`
+	ClientID  string   `json:"client_id"`
+	Username  string   `json:"username"`
+	Password  string   `json:"password"`
+	Prefix    string   `json:"prefix"`
+	Interval  Duration `json:"interval"`
+	KeepAlive Duration `json:"keep_alive"`
+}
+
+// A Duration is a time.Duration written as a string such as "20ms".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string as time.ParseDuration does.
+func (d *Duration) UnmarshalJSON(b []byte) (err error) {
+	var s string
+	if err = json.Unmarshal(b, &s); err != nil {
+		return
+	}
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return
+}
+
+// MarshalJSON writes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load reads a Config from a file, which is taken to be TOML if its name
+// ends in ".toml" and JSON otherwise.
+func Load(path string) (c *Config, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		c, err = ParseTOML(data)
+	} else {
+		c, err = ParseJSON(data)
+	}
+	if err != nil {
+		err = fmt.Errorf("%s: %w", path, err)
+	}
+	return
+}
+
+// ParseJSON parses a Config from JSON.  Unknown keys are an error, to catch
+// misspellings.
+func ParseJSON(data []byte) (c *Config, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	c = new(Config)
+	if err = dec.Decode(c); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// ParseTOML parses a Config from TOML.  Unknown keys are an error, as for
+// ParseJSON.
+func ParseTOML(data []byte) (c *Config, err error) {
+	doc, err := parseTOML(data)
+	if err != nil {
+		return
+	}
+	// The TOML is turned into JSON, so both are decoded the same way
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+	return ParseJSON(j)
+}
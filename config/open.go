@@ -0,0 +1,358 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/adc"
+	"github.com/Ratfink/gopherbone/calibration"
+	"github.com/Ratfink/gopherbone/display"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/i2c"
+	"github.com/Ratfink/gopherbone/pwm"
+	"github.com/Ratfink/gopherbone/ssd1306"
+	"image"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a Hardware has nothing by the name asked for.
+var ErrNotFound = errors.New("Not in configuration")
+
+// Hardware holds everything set up by Open, by the names in the Config.
+type Hardware struct {
+	pins     map[string]*gpio.GPIO
+	pwms     map[string]*pwm.PWM
+	devices  map[string]*i2c.Device
+	displays map[string]*ssd1306.SSD1306
+	groups   map[string]display.Display
+	adcs     map[string]*adc.AIN
+	cals     map[string]*calibration.Calibration
+}
+
+// resolvePin returns the GPIO number of a pin given by header name, signal
+// name or number, muxing it first if mode is set.
+func resolvePin(name, mode string) (n int, err error) {
+	if n, err = strconv.Atoi(name); err == nil {
+		if mode != "" {
+			err = fmt.Errorf("Pin %d must be given by name to be muxed", n)
+		}
+		return
+	}
+	pin, err := gpio.LookupPin(name)
+	if err != nil {
+		return
+	}
+	if mode != "" {
+		if err = pin.Mux(mode); err != nil {
+			return
+		}
+	}
+	n = pin.GPIO
+	return
+}
+
+// Open sets up everything the Config describes.  If anything fails, what has
+// been set up already is closed again, and the error says which name failed.
+func (c *Config) Open() (hw *Hardware, err error) {
+	hw = &Hardware{
+		pins:     make(map[string]*gpio.GPIO),
+		pwms:     make(map[string]*pwm.PWM),
+		devices:  make(map[string]*i2c.Device),
+		displays: make(map[string]*ssd1306.SSD1306),
+		groups:   make(map[string]display.Display),
+		adcs:     make(map[string]*adc.AIN),
+		cals:     make(map[string]*calibration.Calibration),
+	}
+	defer func() {
+		if err != nil {
+			hw.Close()
+			hw = nil
+		}
+	}()
+
+	for name, pc := range c.Pins {
+		if hw.pins[name], err = openPin(pc); err != nil {
+			delete(hw.pins, name)
+			err = fmt.Errorf("Pin %s: %w", name, err)
+			return
+		}
+	}
+	for name, pc := range c.PWM {
+		if hw.pwms[name], err = openPWM(pc); err != nil {
+			delete(hw.pwms, name)
+			err = fmt.Errorf("PWM %s: %w", name, err)
+			return
+		}
+	}
+	for name, ic := range c.I2C {
+		if hw.devices[name], err = i2c.NewDevice(ic.Addr, ic.Bus); err != nil {
+			delete(hw.devices, name)
+			err = fmt.Errorf("I2C device %s: %w", name, err)
+			return
+		}
+	}
+	for name, dc := range c.Displays {
+		if hw.displays[name], err = openDisplay(dc); err != nil {
+			delete(hw.displays, name)
+			err = fmt.Errorf("Display %s: %w", name, err)
+			return
+		}
+	}
+	for name, gc := range c.Groups {
+		if hw.groups[name], err = hw.openGroup(c, gc); err != nil {
+			delete(hw.groups, name)
+			err = fmt.Errorf("Display group %s: %w", name, err)
+			return
+		}
+	}
+	for name, ac := range c.ADC {
+		if hw.adcs[name], err = adc.Open(ac.Channel); err != nil {
+			delete(hw.adcs, name)
+			err = fmt.Errorf("Analog input %s: %w", name, err)
+			return
+		}
+		if len(ac.Calibration) > 0 {
+			hw.cals[name] = &calibration.Calibration{Unit: ac.Unit, Coefficients: ac.Calibration}
+		}
+	}
+
+	return
+}
+
+// Setup loads a Config from a file and opens it.
+func Setup(path string) (hw *Hardware, err error) {
+	c, err := Load(path)
+	if err != nil {
+		return
+	}
+	return c.Open()
+}
+
+func openPin(pc PinConfig) (g *gpio.GPIO, err error) {
+	n, err := resolvePin(pc.Pin, pc.Mux)
+	if err != nil {
+		return
+	}
+	if g, err = gpio.Export(n); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			g.Unexport()
+			g = nil
+		}
+	}()
+
+	if err = g.SetActiveLow(pc.ActiveLow); err != nil {
+		return
+	}
+	switch strings.ToLower(pc.Direction) {
+	case "out":
+		if err = g.SetDirection(gpio.Out); err == nil {
+			err = g.SetValue(pc.Value)
+		}
+	case "in", "":
+		if err = g.SetDirection(gpio.In); err == nil && pc.Edge != "" {
+			err = g.SetEdge(gpio.Edge(strings.ToLower(pc.Edge)))
+		}
+	default:
+		err = fmt.Errorf("%w: %s", gpio.ErrInvalidDirection, pc.Direction)
+	}
+	g.Debounce(time.Duration(pc.Debounce))
+
+	return
+}
+
+func openPWM(pc PWMConfig) (p *pwm.PWM, err error) {
+	if pc.Pin != "" {
+		if _, err = resolvePin(pc.Pin, "pwm"); err != nil {
+			return
+		}
+	}
+	if p, err = pwm.Export(pc.Chip, pc.Channel); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			p.Unexport()
+			p = nil
+		}
+	}()
+
+	if pc.Frequency > 0 {
+		if err = p.SetFrequency(pc.Frequency); err != nil {
+			return
+		}
+	}
+	if err = p.SetDuty(pc.Duty); err != nil {
+		return
+	}
+	if pc.Enable {
+		err = p.Enable()
+	}
+
+	return
+}
+
+func openDisplay(dc DisplayConfig) (d *ssd1306.SSD1306, err error) {
+	if t := strings.ToLower(dc.Type); t != "" && t != "ssd1306" {
+		err = fmt.Errorf("Unsupported display type: %s", dc.Type)
+		return
+	}
+	rst := -1
+	if dc.Reset != "" {
+		if rst, err = resolvePin(dc.Reset, ""); err != nil {
+			return
+		}
+	}
+	if dc.Width == 0 {
+		dc.Width = 128
+	}
+	if dc.Height == 0 {
+		dc.Height = 64
+	}
+	if d, err = ssd1306.New(rst, ssd1306.IFACE_I2C, dc.Addr, dc.Bus, dc.Width, dc.Height); err != nil {
+		return
+	}
+	d.SetOptions(ssd1306.Options{
+		ExternalVCC: dc.ExternalVCC,
+		Contrast:    dc.Contrast,
+		Precharge:   dc.Precharge,
+		Clock:       dc.Clock,
+		VCOMH:       dc.VCOMH,
+	})
+	if err = d.Setup(); err != nil {
+		d.Close()
+		d = nil
+	}
+
+	return
+}
+
+// openGroup combines already opened displays as a GroupConfig describes.
+func (hw *Hardware) openGroup(c *Config, gc GroupConfig) (g display.Display, err error) {
+	var tiles []display.Tile
+	for _, name := range gc.Displays {
+		d, err := hw.Display(name)
+		if err != nil {
+			return nil, err
+		}
+		dc := c.Displays[name]
+		tiles = append(tiles, display.Tile{Display: d, Offset: image.Pt(dc.X, dc.Y)})
+	}
+	switch strings.ToLower(gc.Mode) {
+	case "", "mirror":
+		ds := make([]display.Display, len(tiles))
+		for i, t := range tiles {
+			ds[i] = t.Display
+		}
+		return display.NewMirror(ds...)
+	case "tiled":
+		return display.NewTiled(tiles...)
+	}
+	err = fmt.Errorf("Unknown group mode: %s", gc.Mode)
+	return
+}
+
+// Pin returns the named GPIO pin.
+func (hw *Hardware) Pin(name string) (*gpio.GPIO, error) {
+	if g := hw.pins[name]; g != nil {
+		return g, nil
+	}
+	return nil, fmt.Errorf("%w: pin %s", ErrNotFound, name)
+}
+
+// PWM returns the named PWM channel.
+func (hw *Hardware) PWM(name string) (*pwm.PWM, error) {
+	if p := hw.pwms[name]; p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("%w: PWM %s", ErrNotFound, name)
+}
+
+// Device returns the named I2C device.
+func (hw *Hardware) Device(name string) (*i2c.Device, error) {
+	if d := hw.devices[name]; d != nil {
+		return d, nil
+	}
+	return nil, fmt.Errorf("%w: I2C device %s", ErrNotFound, name)
+}
+
+// Display returns the named display.
+func (hw *Hardware) Display(name string) (*ssd1306.SSD1306, error) {
+	if d := hw.displays[name]; d != nil {
+		return d, nil
+	}
+	return nil, fmt.Errorf("%w: display %s", ErrNotFound, name)
+}
+
+// Group returns the named display group.
+func (hw *Hardware) Group(name string) (display.Display, error) {
+	if g := hw.groups[name]; g != nil {
+		return g, nil
+	}
+	return nil, fmt.Errorf("%w: display group %s", ErrNotFound, name)
+}
+
+// ADC returns the named analog input.
+func (hw *Hardware) ADC(name string) (*adc.AIN, error) {
+	if a := hw.adcs[name]; a != nil {
+		return a, nil
+	}
+	return nil, fmt.Errorf("%w: analog input %s", ErrNotFound, name)
+}
+
+// Analog returns the named analog input with its calibration applied, so
+// that Voltage returns a value in the configured unit.  Without a
+// calibration it reads volts, as the input returned by ADC does.
+func (hw *Hardware) Analog(name string) (pin adc.AnalogPin, err error) {
+	a, err := hw.ADC(name)
+	if err != nil {
+		return
+	}
+	if c := hw.cals[name]; c != nil {
+		return c.Pin(a), nil
+	}
+	return a, nil
+}
+
+// Calibration returns the calibration of the named analog input, which is
+// an identity calibration if none is configured.
+func (hw *Hardware) Calibration(name string) (*calibration.Calibration, error) {
+	if _, err := hw.ADC(name); err != nil {
+		return nil, err
+	}
+	if c := hw.cals[name]; c != nil {
+		return c, nil
+	}
+	return &calibration.Calibration{}, nil
+}
+
+// Close switches off the displays, closes the I2C devices and analog inputs,
+// and unexports the PWM channels and pins.  Everything is released even if something
+// fails, and the first error is returned.
+func (hw *Hardware) Close() (err error) {
+	keep := func(e error) {
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	for _, d := range hw.displays {
+		keep(d.Close())
+	}
+	for _, d := range hw.devices {
+		keep(d.Close())
+	}
+	for _, a := range hw.adcs {
+		keep(a.Close())
+	}
+	for _, p := range hw.pwms {
+		keep(p.Unexport())
+	}
+	for _, g := range hw.pins {
+		keep(g.Unexport())
+	}
+
+	return
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOpenErrors(t *testing.T) {
+	// Each fails before touching any hardware
+	tests := []struct {
+		name string
+		c    Config
+		err  string
+	}{
+		{"pin", Config{Pins: map[string]PinConfig{"led": {Pin: "P99_99"}}}, "Pin led"},
+		{"muxed by number", Config{Pins: map[string]PinConfig{"led": {Pin: "60", Mux: "gpio"}}}, "Pin led"},
+		{"PWM", Config{PWM: map[string]PWMConfig{"fan": {Pin: "P99_99"}}}, "PWM fan"},
+		{"display", Config{Displays: map[string]DisplayConfig{"oled": {Type: "sh1106"}}}, "Display oled"},
+		{"group", Config{Groups: map[string]GroupConfig{"wall": {Displays: []string{"left"}}}}, "Display group wall"},
+		{"analog input", Config{ADC: map[string]ADCConfig{"temp": {Channel: 7}}}, "Analog input temp"},
+	}
+	for _, test := range tests {
+		hw, err := test.c.Open()
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%s: Open = %v, want error starting %q", test.name, err, test.err)
+		}
+		if hw != nil {
+			t.Errorf("%s: Open returned Hardware as well as an error", test.name)
+		}
+	}
+
+	_, err := (&Config{Groups: map[string]GroupConfig{"wall": {Displays: []string{"left"}}}}).Open()
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Group of a missing display: %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML described in the package comment
+// into nested maps.
+func parseTOML(data []byte) (doc map[string]interface{}, err error) {
+	doc = make(map[string]interface{})
+	table := doc
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(sc.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("Line %d: Unsupported table header: %s", n, line)
+			}
+			if table, err = subTable(doc, strings.TrimSpace(line[1:len(line)-1])); err != nil {
+				return nil, fmt.Errorf("Line %d: %w", n, err)
+			}
+			continue
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("Line %d: Expected key = value: %s", n, line)
+		}
+		// A dotted key sets a key in a table below the current one
+		var parts []string
+		if parts, err = splitKey(strings.TrimSpace(line[:eq])); err != nil {
+			return nil, fmt.Errorf("Line %d: %w", n, err)
+		}
+		t := table
+		for _, part := range parts[:len(parts)-1] {
+			if t, err = child(t, part); err != nil {
+				return nil, fmt.Errorf("Line %d: %w", n, err)
+			}
+		}
+		key := parts[len(parts)-1]
+		if _, ok := t[key]; ok {
+			return nil, fmt.Errorf("Line %d: Duplicate key: %s", n, key)
+		}
+		if t[key], err = parseValue(strings.TrimSpace(line[eq+1:])); err != nil {
+			return nil, fmt.Errorf("Line %d: %w", n, err)
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// stripComment removes a comment from a line, leaving any # in strings.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquoteKey returns a key without any quotes round it.
+func unquoteKey(key string) string {
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+// splitKey splits a dotted key or table name into its parts, unquoting
+// each.  Dots in quoted parts are part of the key.
+func splitKey(name string) (parts []string, err error) {
+	var quote byte
+	start := 0
+	for i := 0; i <= len(name); i++ {
+		if i < len(name) {
+			switch c := name[i]; {
+			case quote != 0 && c == '\\' && quote == '"':
+				i++
+				continue
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+				continue
+			case c == '"' || c == '\'':
+				quote = c
+				continue
+			case c != '.':
+				continue
+			}
+		}
+		part := unquoteKey(strings.TrimSpace(name[start:i]))
+		if part == "" {
+			return nil, fmt.Errorf("Invalid key: %s", name)
+		}
+		parts = append(parts, part)
+		start = i + 1
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("Invalid key: %s", name)
+	}
+	return
+}
+
+// child returns the table called name in table, creating it if need be.
+func child(table map[string]interface{}, name string) (map[string]interface{}, error) {
+	next, ok := table[name]
+	if !ok {
+		next = make(map[string]interface{})
+		table[name] = next
+	}
+	if t, ok := next.(map[string]interface{}); ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("%s is not a table", name)
+}
+
+// subTable returns the table named by a dotted table header, creating it
+// and its parents if need be.
+func subTable(doc map[string]interface{}, name string) (table map[string]interface{}, err error) {
+	parts, err := splitKey(name)
+	if err != nil {
+		return
+	}
+	table = doc
+	for _, part := range parts {
+		if table, err = child(table, part); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// parseValue parses a string, integer, float, boolean or array value.
+func parseValue(s string) (v interface{}, err error) {
+	switch {
+	case s == "":
+		err = fmt.Errorf("Missing value")
+	case s == "true", s == "false":
+		v = s == "true"
+	case s[0] == '"':
+		v, err = strconv.Unquote(s)
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			err = fmt.Errorf("Unterminated string: %s", s)
+		} else {
+			v = s[1 : len(s)-1]
+		}
+	case s[0] == '[':
+		v, err = parseArray(s)
+	default:
+		v, err = parseNumber(s)
+	}
+	return
+}
+
+// numberBases are the prefixes TOML allows on integers, which may not be
+// signed.
+var numberBases = []struct {
+	prefix string
+	base   int
+}{{"0x", 16}, {"0o", 8}, {"0b", 2}}
+
+// parseNumber parses an integer or float.  Decimal numbers may not have
+// leading zeros, as TOML forbids them; strconv would take them as octal,
+// which in a file of pin numbers would quietly pick the wrong pin.
+func parseNumber(s string) (v interface{}, err error) {
+	num := strings.Replace(s, "_", "", -1)
+	for _, b := range numberBases {
+		if !strings.HasPrefix(num, b.prefix) {
+			continue
+		}
+		digits := num[len(b.prefix):]
+		if digits == "" || digits[0] == '+' || digits[0] == '-' {
+			return nil, fmt.Errorf("Invalid value: %s", s)
+		}
+		if v, err = strconv.ParseInt(digits, b.base, 64); err != nil {
+			return nil, fmt.Errorf("Invalid value: %s", s)
+		}
+		return
+	}
+
+	digits := strings.TrimLeft(num, "+-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9' {
+		return nil, fmt.Errorf("Leading zero in number: %s", s)
+	}
+	if i, e := strconv.ParseInt(num, 10, 64); e == nil {
+		v = i
+	} else if f, e := strconv.ParseFloat(num, 64); e == nil {
+		v = f
+	} else {
+		err = fmt.Errorf("Invalid value: %s", s)
+	}
+	return
+}
+
+// parseArray parses a single line array of values.
+func parseArray(s string) (a []interface{}, err error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("Unterminated array: %s", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	a = []interface{}{}
+	for s != "" {
+		// Find the end of this element, skipping commas in strings and
+		// nested arrays
+		end, depth := len(s), 0
+		var quote byte
+	scan:
+		for i := 0; i < len(s); i++ {
+			switch c := s[i]; {
+			case quote != 0 && c == '\\' && quote == '"':
+				i++
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '"' || c == '\'':
+				quote = c
+			case c == '[':
+				depth++
+			case c == ']':
+				depth--
+			case c == ',' && depth == 0:
+				end = i
+				break scan
+			}
+		}
+
+		var v interface{}
+		if v, err = parseValue(strings.TrimSpace(s[:end])); err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+		if end == len(s) {
+			break
+		}
+		s = strings.TrimSpace(s[end+1:])
+	}
+	return
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type doc = map[string]interface{}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want interface{}
+	}{
+		{"true", true},
+		{"false", false},
+		{`"a # b"`, "a # b"},
+		{`"tab\tquote\""`, "tab\tquote\""},
+		{`'C:\raw'`, `C:\raw`},
+		{"0", int64(0)},
+		{"42", int64(42)},
+		{"+42", int64(42)},
+		{"-17", int64(-17)},
+		{"1_000", int64(1000)},
+		{"0x3c", int64(0x3c)},
+		{"0xFF", int64(0xff)},
+		{"0o755", int64(0755)},
+		{"0b1010", int64(10)},
+		{"0.5", 0.5},
+		{"-0.25", -0.25},
+		{"1e3", 1000.0},
+		{"[]", []interface{}{}},
+		{"[1, 2.5, \"x, y\"]", []interface{}{int64(1), 2.5, "x, y"}},
+		{"[[1, 2], ['a']]", []interface{}{[]interface{}{int64(1), int64(2)}, []interface{}{"a"}}},
+	}
+	for _, test := range tests {
+		got, err := parseValue(test.in)
+		if err != nil {
+			t.Errorf("parseValue(%s): %v", test.in, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseValue(%s) = %#v, want %#v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseValueErrors(t *testing.T) {
+	tests := []struct {
+		in, err string
+	}{
+		{"", "Missing value"},
+		{"060", "Leading zero"},
+		{"-012.5", "Leading zero"},
+		{"00", "Leading zero"},
+		{"0x", "Invalid value"},
+		{"0x-1", "Invalid value"},
+		{"0b102", "Invalid value"},
+		{"0o8", "Invalid value"},
+		{"yes", "Invalid value"},
+		{"'open", "Unterminated string"},
+		{`"open`, "invalid syntax"},
+		{"[1, 2", "Unterminated array"},
+		{"[1, nope]", "Invalid value"},
+	}
+	for _, test := range tests {
+		v, err := parseValue(test.in)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("parseValue(%s) = %v, %v, want error containing %q", test.in, v, err, test.err)
+		}
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want doc
+	}{
+		{"empty", "", doc{}},
+		{"comments", "# only a comment\n\na = 1 # and another\nb = \"# not one\"\n",
+			doc{"a": int64(1), "b": "# not one"}},
+		{"tables", "top = true\n[one]\nx = 1\n[two]\nx = 2\n",
+			doc{"top": true, "one": doc{"x": int64(1)}, "two": doc{"x": int64(2)}}},
+		{"dotted table", "[pins.led]\npin = \"P8_10\"\n[pins.button]\npin = \"P8_12\"\n",
+			doc{"pins": doc{"led": doc{"pin": "P8_10"}, "button": doc{"pin": "P8_12"}}}},
+		{"dotted keys", "a.b = 1\na.c.d = 2\n[t]\nu.v = 3\n",
+			doc{"a": doc{"b": int64(1), "c": doc{"d": int64(2)}}, "t": doc{"u": doc{"v": int64(3)}}}},
+		{"quoted keys", "\"x.y\" = 1\n'z' = 2\n[\"a.b\".c]\nd = 3\n",
+			doc{"x.y": int64(1), "z": int64(2), "a.b": doc{"c": doc{"d": int64(3)}}}},
+		{"spaces", "  [ sp . ace ]  \n  k  =  'v'  \n",
+			doc{"sp": doc{"ace": doc{"k": "v"}}}},
+	}
+	for _, test := range tests {
+		got, err := parseTOML([]byte(test.in))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: parseTOML = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		in, err string
+	}{
+		{"[[array]]", "Line 1: Unsupported table header"},
+		{"[open", "Line 1: Unsupported table header"},
+		{"a = 1\njust words", "Line 2: Expected key = value"},
+		{"a = 1\na = 2", "Line 2: Duplicate key: a"},
+		{"a.b = 1\na.b = 2", "Line 2: Duplicate key: b"},
+		{"a = 1\na.b = 2", "Line 2: a is not a table"},
+		{"a = 1\n[a]", "Line 2: a is not a table"},
+		{"a..b = 1", "Line 1: Invalid key"},
+		{"[a.]", "Line 1: Invalid key"},
+		{"[]", "Line 1: Invalid key"},
+		{"= 1", "Line 1: Invalid key"},
+		{"'a.b = 1", "Line 1: Invalid key"},
+		{"addr = 060", "Line 1: Leading zero in number: 060"},
+	}
+	for _, test := range tests {
+		_, err := parseTOML([]byte(test.in))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("parseTOML(%q) = %v, want error containing %q", test.in, err, test.err)
+		}
+	}
+}
+
+func TestParseTOMLConfig(t *testing.T) {
+	const toml = `
+[pins.button]
+pin = "P8_12"
+direction = "in"
+edge = "falling"
+debounce = "20ms"
+
+[i2c.rtc]
+bus = 2
+addr = 0x68
+
+[displays.oled]
+bus = 2
+addr = 0x3c
+width = 128
+height = 64
+
+[groups]
+wide.mode = "tiled"
+wide.displays = ["left", "right"]
+
+[adc.temperature]
+channel = 2
+calibration = [-50, 100]
+unit = "°C"
+`
+	const json = `{
+		"pins": {"button": {"pin": "P8_12", "direction": "in", "edge": "falling", "debounce": "20ms"}},
+		"i2c": {"rtc": {"bus": 2, "addr": 104}},
+		"displays": {"oled": {"bus": 2, "addr": 60, "width": 128, "height": 64}},
+		"groups": {"wide": {"mode": "tiled", "displays": ["left", "right"]}},
+		"adc": {"temperature": {"channel": 2, "calibration": [-50, 100], "unit": "°C"}}
+	}`
+
+	got, err := ParseTOML([]byte(toml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ParseJSON([]byte(json))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTOML = %+v, want %+v", got, want)
+	}
+	if d := time.Duration(got.Pins["button"].Debounce); d != 20*time.Millisecond {
+		t.Errorf("Debounce = %v, want 20ms", d)
+	}
+
+	if _, err = ParseTOML([]byte("[pins.led]\npn = \"P8_10\"\n")); err == nil {
+		t.Errorf("ParseTOML accepted an unknown key")
+	}
+}
@@ -0,0 +1,117 @@
+package control
+
+import (
+	"errors"
+	"github.com/Ratfink/gopherbone/adc"
+	"github.com/Ratfink/gopherbone/encoder"
+	"github.com/Ratfink/gopherbone/motor"
+	"github.com/Ratfink/gopherbone/pwm"
+	"github.com/Ratfink/gopherbone/sched"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by an Encoder input once the encoder is closed.
+var ErrClosed = errors.New("Input closed")
+
+// An Input reads the measured value.
+type Input func() (float64, error)
+
+// An Output applies the controller's output.
+type Output func(float64) error
+
+// ADC returns an Input reading the voltage of an analog input.  A
+// calibration.Calibration's Pin gives one in other units.
+func ADC(pin adc.AnalogPin) Input {
+	return pin.Voltage
+}
+
+// Encoder returns an Input reading the position of a rotary encoder, in
+// detents from where it was when Encoder was called.  It must be the only
+// reader of the encoder's channel.
+func Encoder(enc *encoder.Encoder) Input {
+	var lock sync.Mutex
+	var pos int
+	return func() (float64, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		for {
+			select {
+			case n, ok := <-enc.C:
+				if !ok {
+					return float64(pos), ErrClosed
+				}
+				pos += n
+			default:
+				return float64(pos), nil
+			}
+		}
+	}
+}
+
+// PWM returns an Output setting the duty of a PWM channel, for a controller
+// whose limits are 0 and 1.
+func PWM(ch pwm.Channel) Output {
+	return ch.SetDuty
+}
+
+// Motor returns an Output setting the speed of a motor, for a controller
+// whose limits are -1 and 1.
+func Motor(m *motor.Motor) Output {
+	return m.SetSpeed
+}
+
+// A Loop runs a PID controller at a fixed rate, reading the input and
+// writing the output each period.  If either fails, the loop stops; Done is
+// closed and Err holds the error.
+type Loop struct {
+	PID *PID
+
+	loop *sched.Loop
+}
+
+// Run starts a Loop calling pid.Update every period.  The controller
+// continues from its current state, so call its Track method first to take
+// over from manual control without a bump.
+func Run(pid *PID, period time.Duration, in Input, out Output) (l *Loop, err error) {
+	l = &Loop{PID: pid}
+	var last time.Time
+	l.loop, err = sched.New(period, sched.Options{}, func(t sched.Tick) (err error) {
+		value, err := in()
+		if err != nil {
+			return
+		}
+		now := t.Time.Add(t.Late)
+		var dt time.Duration
+		if !last.IsZero() {
+			dt = now.Sub(last)
+		}
+		last = now
+		return out(pid.Update(value, dt))
+	})
+	if err != nil {
+		l = nil
+	}
+	return
+}
+
+// Done returns a channel which is closed when the loop stops.
+func (l *Loop) Done() <-chan struct{} {
+	return l.loop.Done()
+}
+
+// Err returns the error which stopped the loop, once Done is closed.
+func (l *Loop) Err() error {
+	return l.loop.Err
+}
+
+// Stats returns the loop's timing statistics.
+func (l *Loop) Stats() sched.Stats {
+	return l.loop.Stats()
+}
+
+// Stop stops the loop, leaving the output as it was last set, and returns
+// the error which stopped it already, if any.
+func (l *Loop) Stop() error {
+	return l.loop.Stop()
+}
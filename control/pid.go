@@ -0,0 +1,204 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package control provides a PID controller, and a Loop which runs one at a
+// fixed rate between an input, such as an ADC or encoder, and an output,
+// such as a PWM channel or motor.  It is meant for the thermal and motor
+// projects which nearly all need one: the integral is kept from winding up
+// while the output is saturated, the derivative is taken from the
+// measurement and filtered so that setpoint changes and noise don't kick the
+// output, and gains can be changed while running without a bump.
+package control
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Gains are the tuning of a PID controller, in output units per unit of
+// error (Kp), per unit of error times seconds (Ki), and per unit of error
+// per second (Kd).
+type Gains struct {
+	Kp, Ki, Kd float64
+}
+
+// A PID is a proportional-integral-derivative controller.  It is safe for
+// concurrent use, so the setpoint and tuning can be changed while a Loop
+// runs it.
+type PID struct {
+	lock     sync.Mutex
+	gains    Gains
+	min, max float64
+	tau      float64
+	setpoint float64
+
+	// integral is the integral term itself, in output units, so that
+	// changing Ki doesn't change the output
+	integral   float64
+	derivative float64
+	last       float64
+	lastErr    float64
+	output     float64
+	started    bool
+}
+
+// NewPID returns a controller with the given gains, whose output is clamped
+// to [min, max].
+func NewPID(gains Gains, min, max float64) (pid *PID, err error) {
+	if min >= max {
+		err = fmt.Errorf("Invalid output limits: %g to %g", min, max)
+		return
+	}
+	pid = &PID{gains: gains, min: min, max: max}
+	return
+}
+
+// Gains returns the controller's gains.
+func (pid *PID) Gains() Gains {
+	pid.lock.Lock()
+	defer pid.lock.Unlock()
+	return pid.gains
+}
+
+// SetGains retunes the controller.  The integral is adjusted so that the
+// output doesn't jump when the proportional gain changes.
+func (pid *PID) SetGains(gains Gains) {
+	pid.lock.Lock()
+	defer pid.lock.Unlock()
+	if pid.started {
+		pid.integral += (pid.gains.Kp - gains.Kp) * pid.lastErr
+		pid.integral += (pid.gains.Kd - gains.Kd) * pid.derivative
+		pid.integral = clamp(pid.integral, pid.min, pid.max)
+	}
+	pid.gains = gains
+}
+
+// SetLimits changes the range the output is clamped to.
+func (pid *PID) SetLimits(min, max float64) (err error) {
+	if min >= max {
+		return fmt.Errorf("Invalid output limits: %g to %g", min, max)
+	}
+	pid.lock.Lock()
+	defer pid.lock.Unlock()
+	pid.min, pid.max = min, max
+	pid.integral = clamp(pid.integral, min, max)
+	return
+}
+
+// SetDerivativeFilter sets the time constant of the low-pass filter on the
+// derivative term.  A tenth or so of Kd/Kp is usual; zero, the default,
+// disables filtering.
+func (pid *PID) SetDerivativeFilter(tau time.Duration) {
+	pid.lock.Lock()
+	defer pid.lock.Unlock()
+	pid.tau = tau.Seconds()
+}
+
+// Setpoint returns the value the controller is aiming for.
+func (pid *PID) Setpoint() float64 {
+	pid.lock.Lock()
+	defer pid.lock.Unlock()
+	return pid.setpoint
+}
+
+// SetSetpoint sets the value the controller aims for.
+func (pid *PID) SetSetpoint(setpoint float64) {
+	pid.lock.Lock()
+	defer pid.lock.Unlock()
+	pid.setpoint = setpoint
+}
+
+// Output returns the output last returned by Update.
+func (pid *PID) Output() float64 {
+	pid.lock.Lock()
+	defer pid.lock.Unlock()
+	return pid.output
+}
+
+// Reset clears the controller's state, so that the next Update starts
+// afresh.
+func (pid *PID) Reset() {
+	pid.lock.Lock()
+	defer pid.lock.Unlock()
+	pid.integral, pid.derivative, pid.output = 0, 0, 0
+	pid.started = false
+}
+
+// Track prepares the controller to take over from manual control of the
+// output without a bump: given the current measurement and output, the next
+// Update continues from that output.
+func (pid *PID) Track(measurement, output float64) {
+	pid.lock.Lock()
+	defer pid.lock.Unlock()
+	pid.lastErr = pid.setpoint - measurement
+	pid.last = measurement
+	pid.derivative = 0
+	pid.integral = clamp(output-pid.gains.Kp*pid.lastErr, pid.min, pid.max)
+	pid.output = clamp(output, pid.min, pid.max)
+	pid.started = true
+}
+
+// Update takes a new measurement, dt after the previous one, and returns the
+// output.
+func (pid *PID) Update(measurement float64, dt time.Duration) float64 {
+	pid.lock.Lock()
+	defer pid.lock.Unlock()
+
+	e := pid.setpoint - measurement
+	secs := dt.Seconds()
+	if !pid.started || secs <= 0 {
+		// No derivative or integral without a previous measurement
+		pid.last, pid.lastErr = measurement, e
+		pid.started = true
+		pid.output = clamp(pid.gains.Kp*e+pid.integral, pid.min, pid.max)
+		return pid.output
+	}
+
+	// Derivative of the measurement, not the error, so a setpoint change
+	// doesn't kick the output
+	d := -(measurement - pid.last) / secs
+	if pid.tau > 0 {
+		alpha := secs / (pid.tau + secs)
+		d = pid.derivative + alpha*(d-pid.derivative)
+	}
+	pid.derivative = d
+	pid.last, pid.lastErr = measurement, e
+
+	p := pid.gains.Kp * e
+	integral := pid.integral + pid.gains.Ki*e*secs
+	out := p + integral + pid.gains.Kd*d
+
+	// Anti-windup: don't integrate further into saturation
+	if (out > pid.max && e > 0) || (out < pid.min && e < 0) {
+		integral = pid.integral
+		out = p + integral + pid.gains.Kd*d
+	}
+	pid.integral = clamp(integral, pid.min, pid.max)
+	pid.output = clamp(out, pid.min, pid.max)
+	return pid.output
+}
+
+func clamp(x, min, max float64) float64 {
+	return math.Max(min, math.Min(max, x))
+}
@@ -0,0 +1,209 @@
+package control
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// A step is one call to Update, after setting the setpoint.
+type step struct {
+	setpoint, measurement float64
+	dt                    time.Duration
+	want                  float64
+}
+
+func TestPIDUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		gains    Gains
+		min, max float64
+		tau      time.Duration
+		steps    []step
+	}{
+		{"proportional", Gains{Kp: 2}, -10, 10, 0, []step{
+			{1, 0, 0, 2},
+			{1, 0.5, time.Second, 1},
+			{1, 2, time.Second, -2},
+			{0, 20, time.Second, -10},
+		}},
+		{"integral", Gains{Ki: 1}, -3, 3, 0, []step{
+			{1, 0, 0, 0},
+			{1, 0, time.Second, 1},
+			{1, 0, 500 * time.Millisecond, 1.5},
+			{1, 0, time.Second, 2.5},
+			{1, 0, 0, 2.5},
+		}},
+		{"anti-windup", Gains{Kp: 1, Ki: 1}, 0, 2, 0, []step{
+			{10, 0, 0, 2},
+			{10, 0, time.Second, 2},
+			{10, 0, time.Second, 2},
+			{10, 0, time.Second, 2},
+			// The integral didn't wind up while saturated, so
+			// the output drops as soon as the error turns
+			{10, 11, time.Second, 0},
+		}},
+		{"derivative of measurement", Gains{Kd: 1}, -10, 10, 0, []step{
+			{0, 0, 0, 0},
+			{5, 0, time.Second, 0},
+			{5, 1, time.Second, -1},
+			{5, 1, 500 * time.Millisecond, 0},
+			{5, 3, 500 * time.Millisecond, -4},
+		}},
+		{"filtered derivative", Gains{Kd: 1}, -10, 10, time.Second, []step{
+			{0, 0, 0, 0},
+			{0, 2, time.Second, -1},
+			{0, 2, time.Second, -0.5},
+			{0, 2, time.Second, -0.25},
+		}},
+	}
+	for _, test := range tests {
+		pid, err := NewPID(test.gains, test.min, test.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pid.SetDerivativeFilter(test.tau)
+		for i, s := range test.steps {
+			pid.SetSetpoint(s.setpoint)
+			if got := pid.Update(s.measurement, s.dt); math.Abs(got-s.want) > 1e-9 {
+				t.Errorf("%s: Step %d: Update = %g, want %g", test.name, i, got, s.want)
+			}
+			if got := pid.Output(); math.Abs(got-s.want) > 1e-9 {
+				t.Errorf("%s: Step %d: Output = %g, want %g", test.name, i, got, s.want)
+			}
+		}
+	}
+}
+
+func TestPIDBumpless(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(pid *PID)
+	}{
+		{"SetGains", func(pid *PID) { pid.SetGains(Gains{Kp: 4, Ki: 1, Kd: 0.5}) }},
+		{"SetGains to zero", func(pid *PID) { pid.SetGains(Gains{Ki: 1}) }},
+		{"Track", func(pid *PID) { pid.Track(3, pid.Output()) }},
+		{"SetLimits", func(pid *PID) { pid.SetLimits(-50, 50) }},
+	}
+	for _, test := range tests {
+		pid, err := NewPID(Gains{Kp: 1, Ki: 1, Kd: 0.1}, -100, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pid.SetSetpoint(10)
+		for _, m := range []float64{0, 2, 3, 3} {
+			pid.Update(m, time.Second)
+		}
+		before := pid.Output()
+
+		// With the measurement holding steady, the output only moves
+		// by the next step of the integral
+		test.change(pid)
+		got := pid.Update(3, time.Millisecond)
+		want := before + pid.Gains().Ki*7*0.001
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: Output went from %g to %g, want %g", test.name, before, got, want)
+		}
+	}
+}
+
+func TestPIDTrack(t *testing.T) {
+	tests := []struct {
+		measurement, output, want float64
+	}{
+		{20, 0.3, 0.3},
+		{25, 0.7, 0.7},
+		{30, 0.5, 0.5},
+		{25, 1.5, 1},
+		{25, -1, 0},
+	}
+	for _, test := range tests {
+		pid, err := NewPID(Gains{Kp: 0.02, Ki: 0.01}, 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pid.SetSetpoint(25)
+		pid.Track(test.measurement, test.output)
+		if got := pid.Output(); got != test.want {
+			t.Errorf("Track(%g, %g): Output = %g, want %g", test.measurement, test.output, got, test.want)
+		}
+		if got := pid.Update(test.measurement, 0); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Track(%g, %g): Update = %g, want %g", test.measurement, test.output, got, test.want)
+		}
+	}
+}
+
+func TestPIDReset(t *testing.T) {
+	pid, err := NewPID(Gains{Ki: 1}, -10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid.SetSetpoint(1)
+	pid.Update(0, 0)
+	pid.Update(0, time.Second)
+	pid.Reset()
+	if got := pid.Output(); got != 0 {
+		t.Errorf("Output after Reset = %g", got)
+	}
+	if got := pid.Update(0, time.Second); got != 0 {
+		t.Errorf("First Update after Reset = %g, want 0", got)
+	}
+}
+
+func TestPIDLimits(t *testing.T) {
+	tests := []struct {
+		min, max float64
+		ok       bool
+	}{
+		{0, 1, true},
+		{-1, 1, true},
+		{1, 1, false},
+		{2, 1, false},
+	}
+	for _, test := range tests {
+		pid, err := NewPID(Gains{}, test.min, test.max)
+		if (err == nil) != test.ok {
+			t.Errorf("NewPID(%g, %g): %v", test.min, test.max, err)
+		}
+		pid, _ = NewPID(Gains{}, -1, 1)
+		if err = pid.SetLimits(test.min, test.max); (err == nil) != test.ok {
+			t.Errorf("SetLimits(%g, %g): %v", test.min, test.max, err)
+		}
+	}
+}
+
+func TestPIDPlant(t *testing.T) {
+	// A first order plant, such as a heater, whose output approaches the
+	// input with time constant tau
+	tests := []struct {
+		name  string
+		gains Gains
+		tau   float64
+	}{
+		{"PI", Gains{Kp: 2, Ki: 1}, 1},
+		{"PID", Gains{Kp: 4, Ki: 2, Kd: 0.2}, 2},
+		{"slow", Gains{Kp: 1, Ki: 0.2}, 5},
+	}
+	for _, test := range tests {
+		pid, err := NewPID(test.gains, 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pid.SetDerivativeFilter(100 * time.Millisecond)
+		pid.SetSetpoint(50)
+
+		dt := 10 * time.Millisecond
+		x, peak := 0.0, 0.0
+		for i := 0; i < 6000; i++ {
+			u := pid.Update(x, dt)
+			x += (u - x) / test.tau * dt.Seconds()
+			peak = math.Max(peak, x)
+		}
+		if math.Abs(x-50) > 0.5 {
+			t.Errorf("%s: Settled at %g, want 50", test.name, x)
+		}
+		if peak > 60 {
+			t.Errorf("%s: Overshot to %g", test.name, peak)
+		}
+	}
+}
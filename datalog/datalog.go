@@ -0,0 +1,146 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package datalog records samples from ADC inputs and other sensors for
+// long-running data collection in the field.  Samples are taken at a fixed
+// rate into a bounded ring buffer, and periodically appended to CSV or JSON
+// lines files, which are rotated by size with the oldest deleted.  Writing
+// in batches, and syncing each batch, spares the SD card without losing more
+// than a flush interval's data to a power cut, and if the card can't be
+// written the newest samples are kept in memory until it can.
+package datalog
+
+import (
+	"github.com/Ratfink/gopherbone/adc"
+	"math"
+	"sync"
+	"time"
+)
+
+// A Source reads one value of a sample.
+type Source func() (float64, error)
+
+// ADC returns a Source reading the voltage of an analog input.
+func ADC(pin adc.AnalogPin) Source {
+	return pin.Voltage
+}
+
+// A Column is a named Source, one of the values in each sample.
+type Column struct {
+	Name   string
+	Source Source
+}
+
+// A Sample is the value of each column at a time.  A value which couldn't
+// be read is NaN, and is written as an empty CSV field or a JSON null.
+type Sample struct {
+	Time   time.Time
+	Values []float64
+}
+
+// A Ring is a bounded buffer of samples.  When it is full, adding a sample
+// drops the oldest.
+type Ring struct {
+	lock    sync.Mutex
+	samples []Sample
+	start   int
+	n       int
+	// first counts the samples ever removed, so marks survive drops
+	first   int64
+	dropped int64
+}
+
+// NewRing returns a Ring holding up to size samples.
+func NewRing(size int) *Ring {
+	if size < 1 {
+		size = 1
+	}
+	return &Ring{samples: make([]Sample, size)}
+}
+
+// Push adds a sample, dropping the oldest if the ring is full.
+func (r *Ring) Push(s Sample) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.n == len(r.samples) {
+		r.remove(1)
+		r.dropped++
+	}
+	r.samples[(r.start+r.n)%len(r.samples)] = s
+	r.n++
+}
+
+func (r *Ring) remove(n int) {
+	r.start = (r.start + n) % len(r.samples)
+	r.n -= n
+	r.first += int64(n)
+}
+
+// Len returns the number of samples held.
+func (r *Ring) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.n
+}
+
+// Dropped returns the number of samples dropped because the ring was full.
+func (r *Ring) Dropped() int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.dropped
+}
+
+// Peek returns the samples held, oldest first, without removing them, and a
+// mark to pass to Release once they have been dealt with.
+func (r *Ring) Peek() (samples []Sample, mark int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	samples = make([]Sample, r.n)
+	for i := range samples {
+		samples[i] = r.samples[(r.start+i)%len(r.samples)]
+	}
+	return samples, r.first + int64(r.n)
+}
+
+// Release removes the samples returned by the Peek which returned mark,
+// apart from any already dropped, leaving those pushed since.
+func (r *Ring) Release(mark int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if n := mark - r.first; n > 0 {
+		r.remove(int(n))
+	}
+}
+
+// read takes a sample of each column.
+func read(columns []Column) (s Sample) {
+	s.Time = time.Now()
+	s.Values = make([]float64, len(columns))
+	for i, c := range columns {
+		v, err := c.Source()
+		if err != nil {
+			v = math.NaN()
+		}
+		s.Values[i] = v
+	}
+	return
+}
@@ -0,0 +1,319 @@
+package datalog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/Ratfink/gopherbone/sched"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A Format is a file format for samples.
+type Format int
+
+// Formats.  CSV files have a header row naming the columns, then a row per
+// sample with the time first.  JSON lines files have an object per line,
+// with the time under "time" and each value under its column's name.  Times
+// are RFC 3339, with nanoseconds.
+const (
+	CSV Format = iota
+	JSON
+)
+
+// Ext returns the file name extension for the format.
+func (f Format) Ext() string {
+	if f == JSON {
+		return ".jsonl"
+	}
+	return ".csv"
+}
+
+// Defaults for Options left zero.
+const (
+	DefaultPrefix        = "data"
+	DefaultFlushInterval = time.Minute
+	DefaultBufferSize    = 4096
+)
+
+// Options configures a Logger.
+type Options struct {
+	// Dir is the directory to write files to, which must exist.
+	Dir string
+	// Prefix starts each file's name, which continues with the time it
+	// was created, as in data-20130401-120000.csv.
+	Prefix string
+	Format Format
+	// Interval is the time between samples.  If it is zero, samples are
+	// only taken by calling Sample.
+	Interval time.Duration
+	// FlushInterval is the time between writes to the file.
+	FlushInterval time.Duration
+	// BufferSize is the number of samples held in memory between writes,
+	// or while writing fails.
+	BufferSize int
+	// MaxFileSize starts a new file once the current one has grown to
+	// this many bytes.  If it is zero, one file is used.
+	MaxFileSize int64
+	// MaxFiles is the number of files to keep, deleting the oldest.  If it
+	// is zero, all are kept.
+	MaxFiles int
+}
+
+// Stats counts what a Logger has done.
+type Stats struct {
+	// Samples is the number taken, Written the number written out, and
+	// Dropped the number lost because the buffer filled.
+	Samples, Written, Dropped int64
+	// Buffered is the number waiting to be written.
+	Buffered int
+	// File is the file being written, if any.
+	File string
+}
+
+// A Logger samples a set of columns into a Ring and flushes them to files.
+type Logger struct {
+	opts    Options
+	columns []Column
+	ring    *Ring
+
+	sampler *sched.Loop
+	flusher *sched.Loop
+
+	lock    sync.Mutex
+	file    *os.File
+	size    int64
+	samples int64
+	written int64
+	err     error
+}
+
+// New starts logging the columns with the given options.
+func New(opts Options, columns ...Column) (l *Logger, err error) {
+	if len(columns) == 0 {
+		err = fmt.Errorf("No columns to log")
+		return
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if info, e := os.Stat(opts.Dir); e != nil {
+		err = e
+		return
+	} else if !info.IsDir() {
+		err = fmt.Errorf("Not a directory: %s", opts.Dir)
+		return
+	}
+
+	l = &Logger{opts: opts, columns: columns, ring: NewRing(opts.BufferSize)}
+	if l.flusher, err = sched.New(opts.FlushInterval, sched.Options{Policy: sched.Reset}, l.flush); err != nil {
+		return nil, err
+	}
+	if opts.Interval > 0 {
+		l.sampler, err = sched.New(opts.Interval, sched.Options{}, func(sched.Tick) error {
+			l.Sample()
+			return nil
+		})
+		if err != nil {
+			l.flusher.Stop()
+			return nil, err
+		}
+	}
+	return
+}
+
+// Sample takes a sample now, adding it to the buffer.
+func (l *Logger) Sample() {
+	l.ring.Push(read(l.columns))
+	l.lock.Lock()
+	l.samples++
+	l.lock.Unlock()
+}
+
+// Recent returns the samples not yet written, oldest first.
+func (l *Logger) Recent() (samples []Sample) {
+	samples, _ = l.ring.Peek()
+	return
+}
+
+// Stats returns counts of what the logger has done.
+func (l *Logger) Stats() (stats Stats) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	stats = Stats{
+		Samples:  l.samples,
+		Written:  l.written,
+		Dropped:  l.ring.Dropped(),
+		Buffered: l.ring.Len(),
+	}
+	if l.file != nil {
+		stats.File = l.file.Name()
+	}
+	return
+}
+
+// Err returns the error from the last flush, or nil if it succeeded.
+func (l *Logger) Err() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.err
+}
+
+func (l *Logger) flush(sched.Tick) error {
+	l.Flush()
+	return nil
+}
+
+// Flush writes the buffered samples out now, and syncs the file.  If it
+// fails, the samples stay buffered for the next flush, which starts a new
+// file.
+func (l *Logger) Flush() (err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	defer func() { l.err = err }()
+
+	samples, mark := l.ring.Peek()
+	if len(samples) == 0 {
+		return
+	}
+
+	if l.file == nil {
+		if err = l.open(samples[0].Time); err != nil {
+			return
+		}
+	}
+	var buf bytes.Buffer
+	if l.size == 0 {
+		l.header(&buf)
+	}
+	l.encode(&buf, samples)
+
+	n, err := l.file.Write(buf.Bytes())
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		l.file.Close()
+		l.file = nil
+		return
+	}
+	l.ring.Release(mark)
+	l.written += int64(len(samples))
+	l.size += int64(n)
+
+	if l.opts.MaxFileSize > 0 && l.size >= l.opts.MaxFileSize {
+		err = l.file.Close()
+		l.file = nil
+	}
+	return
+}
+
+// open opens a new file, named for the time t, deleting the oldest if there
+// are too many.
+func (l *Logger) open(t time.Time) (err error) {
+	name := filepath.Join(l.opts.Dir, l.opts.Prefix+"-"+t.Format("20060102-150405")+l.opts.Format.Ext())
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return
+	}
+	l.file, l.size = f, info.Size()
+
+	if l.opts.MaxFiles > 0 {
+		l.prune()
+	}
+	return
+}
+
+// prune deletes the oldest files beyond MaxFiles.  Their names sort by the
+// time they were created.
+func (l *Logger) prune() {
+	files, _ := filepath.Glob(filepath.Join(l.opts.Dir, l.opts.Prefix+"-*"+l.opts.Format.Ext()))
+	sort.Strings(files)
+	for len(files) > l.opts.MaxFiles {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+}
+
+func (l *Logger) header(buf *bytes.Buffer) {
+	if l.opts.Format != CSV {
+		return
+	}
+	record := []string{"time"}
+	for _, c := range l.columns {
+		record = append(record, c.Name)
+	}
+	w := csv.NewWriter(buf)
+	w.Write(record)
+	w.Flush()
+}
+
+func (l *Logger) encode(buf *bytes.Buffer, samples []Sample) {
+	if l.opts.Format == JSON {
+		for _, s := range samples {
+			fmt.Fprintf(buf, `{"time":"%s"`, s.Time.Format(time.RFC3339Nano))
+			for i, c := range l.columns {
+				name, _ := json.Marshal(c.Name)
+				fmt.Fprintf(buf, ",%s:%s", name, formatValue(s.Values[i], "null"))
+			}
+			buf.WriteString("}\n")
+		}
+		return
+	}
+
+	w := csv.NewWriter(buf)
+	record := make([]string, len(l.columns)+1)
+	for _, s := range samples {
+		record[0] = s.Time.Format(time.RFC3339Nano)
+		for i, v := range s.Values {
+			record[i+1] = formatValue(v, "")
+		}
+		w.Write(record)
+	}
+	w.Flush()
+}
+
+// formatValue formats a value as briefly as possible, or as missing if it
+// isn't a finite number.
+func formatValue(v float64, missing string) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return missing
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Close stops sampling, writes out the buffered samples and closes the file.
+func (l *Logger) Close() (err error) {
+	if l.sampler != nil {
+		l.sampler.Stop()
+	}
+	l.flusher.Stop()
+	err = l.Flush()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file != nil {
+		if e := l.file.Close(); err == nil {
+			err = e
+		}
+		l.file = nil
+	}
+	return
+}
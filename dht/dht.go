@@ -0,0 +1,255 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* The DHT sensors use a single-wire protocol in which bits are distinguished
+ * by the length of pulses only tens of microseconds long.  Sampling a sysfs
+ * GPIO is barely fast enough for this, so reads fail fairly often and are
+ * retried.  Where the kernel's dht11 IIO driver is available (it handles
+ * DHT22s too), NewIIO is far more reliable.
+ */
+package dht
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/gpio"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A Model is a type of DHT sensor.
+type Model int
+
+// Supported sensor models.
+const (
+	DHT11 Model = iota
+	DHT22
+)
+
+// Retries is the number of times Read tries to get a good reading from a
+// bit-banged sensor before giving up.
+var Retries = 5
+
+// A Reading is a measurement from a DHT sensor.
+type Reading struct {
+	// Temperature is in degrees Celsius.
+	Temperature float64
+	// Humidity is relative humidity, in percent.
+	Humidity float64
+}
+
+// A DHT is a DHT11 or DHT22 temperature and humidity sensor.
+type DHT struct {
+	model    Model
+	gpio     *gpio.GPIO
+	iio      string
+	lastRead time.Time
+}
+
+// New creates a DHT of the given model on a GPIO pin, which should have a
+// pull-up resistor.
+func New(pin int, model Model) (dht *DHT, err error) {
+	if model != DHT11 && model != DHT22 {
+		err = fmt.Errorf("Invalid model: %d", model)
+		return
+	}
+	dht = &DHT{model: model}
+	dht.gpio, err = gpio.Export(pin)
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// NewIIO creates a DHT which reads from the kernel's dht11 driver, exposed as
+// the given IIO device number.
+func NewIIO(device int) (dht *DHT, err error) {
+	path := fmt.Sprintf("/sys/bus/iio/devices/iio:device%d", device)
+	if _, err = os.Stat(path); err != nil {
+		return
+	}
+	dht = &DHT{iio: path}
+
+	return
+}
+
+// interval returns the minimum time between reads for the sensor.
+func (dht *DHT) interval() time.Duration {
+	if dht.model == DHT22 {
+		return 2 * time.Second
+	}
+	return time.Second
+}
+
+// Read returns a reading from the sensor.  Bit-banged sensors are read up to
+// Retries times, waiting the minimum interval between reads the sensor
+// allows, so a failing Read can take several seconds.
+func (dht *DHT) Read() (reading Reading, err error) {
+	if dht.iio != "" {
+		return dht.readIIO()
+	}
+
+	for i := 0; i < Retries; i++ {
+		time.Sleep(time.Until(dht.lastRead.Add(dht.interval())))
+		reading, err = dht.readOnce()
+		dht.lastRead = time.Now()
+		if err == nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (dht *DHT) readIIO() (reading Reading, err error) {
+	var temp, humidity int
+	if temp, err = readInt(filepath.Join(dht.iio, "in_temp_input")); err != nil {
+		return
+	}
+	if humidity, err = readInt(filepath.Join(dht.iio, "in_humidityrelative_input")); err != nil {
+		return
+	}
+	// The driver reports both in thousandths
+	reading.Temperature = float64(temp) / 1000
+	reading.Humidity = float64(humidity) / 1000
+
+	return
+}
+
+func readInt(path string) (value int, err error) {
+	f, err := gopherbone.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	n, err := fmt.Fscanf(f, "%d", &value)
+	if n != 1 {
+		err = fmt.Errorf("Bad number of values read from %s: %d", path, n)
+	}
+
+	return
+}
+
+// readOnce performs a single bit-banged read.
+func (dht *DHT) readOnce() (reading Reading, err error) {
+	// Hold the line low to ask the sensor for a reading
+	if err = dht.gpio.SetDirection(gpio.Out); err != nil {
+		return
+	}
+	if err = dht.gpio.SetValue(0); err != nil {
+		return
+	}
+	if dht.model == DHT11 {
+		time.Sleep(18 * time.Millisecond)
+	} else {
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err = dht.gpio.SetDirection(gpio.In); err != nil {
+		return
+	}
+
+	highs, err := dht.sample(10 * time.Millisecond)
+	if err != nil {
+		return
+	}
+	// The last 40 high pulses are the data bits, after the sensor's 80us
+	// response pulse
+	if len(highs) < 40 {
+		err = fmt.Errorf("Too few bits received: %d", len(highs))
+		return
+	}
+	highs = highs[len(highs)-40:]
+
+	var data [5]byte
+	for i, d := range highs {
+		data[i/8] <<= 1
+		if d > 50*time.Microsecond {
+			data[i/8] |= 1
+		}
+	}
+
+	if data[0]+data[1]+data[2]+data[3] != data[4] {
+		err = fmt.Errorf("Checksum mismatch: %02x %02x %02x %02x %02x", data[0], data[1], data[2], data[3], data[4])
+		return
+	}
+
+	if dht.model == DHT11 {
+		reading.Humidity = float64(data[0]) + float64(data[1])/10
+		reading.Temperature = float64(data[2]) + float64(data[3]&0x7f)/10
+		if data[3]&0x80 != 0 {
+			reading.Temperature = -reading.Temperature
+		}
+	} else {
+		reading.Humidity = float64(int(data[0])<<8|int(data[1])) / 10
+		reading.Temperature = float64(int(data[2]&0x7f)<<8|int(data[3])) / 10
+		if data[2]&0x80 != 0 {
+			reading.Temperature = -reading.Temperature
+		}
+	}
+
+	return
+}
+
+// sample polls the pin's value as fast as possible for the given time, and
+// returns the lengths of the high pulses seen.
+func (dht *DHT) sample(d time.Duration) (highs []time.Duration, err error) {
+	f, err := gopherbone.OpenFile(fmt.Sprintf("%s/gpio%d/value", gpio.SysfsPath, dht.gpio.Pin), os.O_RDONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	buf := make([]byte, 1)
+	last := byte('1')
+	var rose time.Time
+	start := time.Now()
+
+	for {
+		if _, err = f.ReadAt(buf, 0); err != nil {
+			return
+		}
+		now := time.Now()
+		if buf[0] != last {
+			if buf[0] == '1' {
+				rose = now
+			} else if !rose.IsZero() {
+				highs = append(highs, now.Sub(rose))
+			}
+			last = buf[0]
+		}
+		if now.Sub(start) > d {
+			return
+		}
+	}
+}
+
+// Close unexports a bit-banged sensor's pin.
+func (dht *DHT) Close() (err error) {
+	if dht.gpio != nil {
+		err = dht.gpio.Unexport()
+	}
+
+	return
+}
@@ -0,0 +1,192 @@
+package diag
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/capemgr"
+	"github.com/Ratfink/gopherbone/config"
+	"github.com/Ratfink/gopherbone/display"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/i2c"
+	"github.com/Ratfink/gopherbone/pwm"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GPIO checks that the sysfs GPIO interface is there and that this program
+// may export pins.
+func GPIO() Check {
+	return Check{Name: "GPIO sysfs", Run: func() (detail string, err error) {
+		f, err := gopherbone.OpenFile(gpio.SysfsPath+"/export", os.O_WRONLY, 0)
+		if err != nil {
+			return
+		}
+		f.Close()
+		chips, _ := filepath.Glob(gpio.SysfsPath + "/gpiochip*")
+		detail = fmt.Sprintf("%d GPIO chips", len(chips))
+		return
+	}}
+}
+
+// pinNumber returns the GPIO number of a pin given by header name, signal
+// name or number.
+func pinNumber(name string) (n int, err error) {
+	if n, err = strconv.Atoi(name); err == nil {
+		return
+	}
+	pin, err := gpio.LookupPin(name)
+	if err != nil {
+		return
+	}
+	n = pin.GPIO
+	return
+}
+
+// Pin checks that a pin, given by header name, signal name or number, can
+// be exported and read.  A pin which wasn't exported is unexported again,
+// and one which was is left as it is.
+func Pin(name string) Check {
+	return Check{Name: "pin " + name, Run: func() (detail string, err error) {
+		n, err := pinNumber(name)
+		if err != nil {
+			return
+		}
+		s, err := gpio.Capture(n)
+		if err != nil {
+			return
+		}
+		if st := s.Pins[0]; st.Exported {
+			detail = fmt.Sprintf("GPIO %d %s, reads %d", n, st.Direction, st.Value)
+			return
+		}
+		g, err := gpio.Export(n)
+		if err != nil {
+			return
+		}
+		defer func() {
+			if e := g.Unexport(); e != nil && err == nil {
+				err = e
+			}
+		}()
+		v, err := g.Value()
+		detail = fmt.Sprintf("GPIO %d reads %d", n, v)
+		return
+	}}
+}
+
+// Overlay checks that a device tree overlay, such as "BB-I2C2", is loaded.
+func Overlay(name string) Check {
+	return Check{Name: "overlay " + name, Run: func() (detail string, err error) {
+		loaded, err := capemgr.Loaded(name)
+		if err == nil && !loaded {
+			err = fmt.Errorf("Not loaded")
+		}
+		return
+	}}
+}
+
+// I2C checks that a device acknowledges its address on a bus.
+func I2C(bus, addr byte) Check {
+	return Check{Name: fmt.Sprintf("I2C %d:%#02x", bus, addr), Run: func() (detail string, err error) {
+		found, err := i2c.Probe(bus, addr)
+		if err == nil && !found {
+			err = i2c.ErrNAK
+		}
+		return
+	}}
+}
+
+// PWM checks that a PWM chip is there and has the channel.  The channel is
+// not exported, so this may be run while something else is using it.
+func PWM(chip, channel int) Check {
+	return Check{Name: fmt.Sprintf("PWM %d:%d", chip, channel), Run: func() (detail string, err error) {
+		data, err := os.ReadFile(fmt.Sprintf("%s/pwmchip%d/npwm", pwm.SysfsPath, chip))
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return
+		}
+		if channel < 0 || channel >= n {
+			err = fmt.Errorf("pwmchip%d has only %d channels", chip, n)
+			return
+		}
+		detail = fmt.Sprintf("pwmchip%d has %d channels", chip, n)
+		return
+	}}
+}
+
+// Display checks a display by setting it up again if it can be, as an
+// SSD1306 can, then clearing and drawing it, so that every command is sent
+// and acknowledged.
+func Display(name string, d display.Display) Check {
+	return Check{Name: "display " + name, Run: func() (detail string, err error) {
+		if s, ok := d.(interface{ Setup() error }); ok {
+			if err = s.Setup(); err != nil {
+				return
+			}
+		}
+		d.Clear(color.Black)
+		if err = d.Draw(); err != nil {
+			return
+		}
+		w, h := d.Size()
+		detail = fmt.Sprintf("%dx%d", w, h)
+		return
+	}}
+}
+
+// FromConfig returns checks for everything a Config describes, which may be
+// run before opening it to say what is missing: the GPIO interface and
+// each pin, each PWM channel, and each I2C device and display's address.
+// Checks of the displays themselves need them open; see Display.  Things
+// are checked in order of name, so reports list them the same way each time.
+func FromConfig(c *config.Config) (checks []Check) {
+	var pins, pwms, devices, displays []string
+	for name := range c.Pins {
+		pins = append(pins, name)
+	}
+	for name := range c.PWM {
+		pwms = append(pwms, name)
+	}
+	for name := range c.I2C {
+		devices = append(devices, name)
+	}
+	for name := range c.Displays {
+		displays = append(displays, name)
+	}
+	sort.Strings(pins)
+	sort.Strings(pwms)
+	sort.Strings(devices)
+	sort.Strings(displays)
+
+	if len(pins) > 0 {
+		checks = append(checks, GPIO())
+	}
+	for _, name := range pins {
+		ch := Pin(c.Pins[name].Pin)
+		ch.Name = "pin " + name
+		checks = append(checks, ch)
+	}
+	for _, name := range pwms {
+		ch := PWM(c.PWM[name].Chip, c.PWM[name].Channel)
+		ch.Name = "PWM " + name
+		checks = append(checks, ch)
+	}
+	for _, name := range devices {
+		ch := I2C(c.I2C[name].Bus, c.I2C[name].Addr)
+		ch.Name = "I2C " + name
+		checks = append(checks, ch)
+	}
+	for _, name := range displays {
+		ch := I2C(c.Displays[name].Bus, c.Displays[name].Addr)
+		ch.Name = "display " + name
+		checks = append(checks, ch)
+	}
+	return
+}
@@ -0,0 +1,211 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package diag checks a board's wiring and setup, so that a unit in the
+// field can test itself at boot and say what is wrong.  Each Check tests
+// one thing, such as a device acknowledging its I2C address or an overlay
+// being loaded, and Run gathers their results into a Report which can be
+// logged or shown on a display:
+//
+//	c, err := config.Load("/etc/node.toml")
+//	...
+//	report := diag.Run(diag.FromConfig(c)...)
+//	if !report.OK() {
+//		log.Print(report)
+//	}
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/display"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrSkip may be returned by a check which doesn't apply, such as one for
+// hardware this board lacks.
+var ErrSkip = errors.New("Skipped")
+
+// A Status is the outcome of a check.
+type Status int
+
+const (
+	Pass Status = iota
+	Fail
+	Skip
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "ok"
+	case Fail:
+		return "FAIL"
+	case Skip:
+		return "skip"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// A Check tests one thing.  Run returns a short description of what it
+// found, such as the number of channels on a PWM chip, or an error saying
+// what is wrong.
+type Check struct {
+	Name string
+	Run  func() (detail string, err error)
+}
+
+// A Result is the outcome of one check.
+type Result struct {
+	Name     string
+	Status   Status
+	Detail   string
+	Err      error
+	Duration time.Duration
+}
+
+func (r Result) String() string {
+	s := fmt.Sprintf("%-4s %s", r.Status, r.Name)
+	if r.Err != nil && r.Status == Fail {
+		s += ": " + r.Err.Error()
+	} else if r.Detail != "" {
+		s += ": " + r.Detail
+	}
+	return s
+}
+
+// A Report holds the results of a run of checks, in the order they ran.
+type Report struct {
+	Time     time.Time
+	Duration time.Duration
+	Results  []Result
+}
+
+// Run runs the checks one at a time, since several may use the same bus,
+// and returns their results.  A check which panics fails rather than taking
+// the program with it.
+func Run(checks ...Check) (r *Report) {
+	r = &Report{Time: time.Now()}
+	for _, c := range checks {
+		r.Results = append(r.Results, run(c))
+	}
+	r.Duration = time.Since(r.Time)
+	return
+}
+
+func run(c Check) (res Result) {
+	res.Name = c.Name
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			res.Err = fmt.Errorf("Panic: %v", p)
+		}
+		res.Duration = time.Since(start)
+		switch {
+		case res.Err == nil:
+			res.Status = Pass
+		case errors.Is(res.Err, ErrSkip):
+			res.Status = Skip
+		default:
+			res.Status = Fail
+		}
+	}()
+	res.Detail, res.Err = c.Run()
+	return
+}
+
+// OK reports whether no check failed.
+func (r *Report) OK() bool {
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the results of the checks which failed.
+func (r *Report) Failures() (failed []Result) {
+	for _, res := range r.Results {
+		if res.Status == Fail {
+			failed = append(failed, res)
+		}
+	}
+	return
+}
+
+// Summary returns a one line summary, such as "7 ok, 1 failed, 2 skipped".
+func (r *Report) Summary() string {
+	var n [3]int
+	for _, res := range r.Results {
+		if res.Status >= Pass && res.Status <= Skip {
+			n[res.Status]++
+		}
+	}
+	return fmt.Sprintf("%d ok, %d failed, %d skipped", n[Pass], n[Fail], n[Skip])
+}
+
+// String returns the summary followed by each result on its own line.
+func (r *Report) String() string {
+	var b strings.Builder
+	r.WriteTo(&b)
+	return b.String()
+}
+
+// WriteTo writes the report as String formats it.
+func (r *Report) WriteTo(w io.Writer) (n int64, err error) {
+	m, err := fmt.Fprintln(w, r.Summary())
+	n += int64(m)
+	for _, res := range r.Results {
+		if err != nil {
+			return
+		}
+		m, err = fmt.Fprintln(w, res)
+		n += int64(m)
+	}
+	return
+}
+
+// Show writes the report on a display: whether it passed, then the
+// failures if there are any, one to a line and as many as fit.
+func (r *Report) Show(d display.Display) (err error) {
+	t := display.NewTerminal(d)
+	cols, rows := t.Size()
+	failed := r.Failures()
+	lines := []string{"Self-test: ok"}
+	if len(failed) > 0 {
+		lines[0] = fmt.Sprintf("Self-test: %d failed", len(failed))
+	}
+	for _, res := range failed {
+		if len(lines) >= rows {
+			break
+		}
+		line := res.Name
+		if res.Err != nil {
+			line += ": " + res.Err.Error()
+		}
+		lines = append(lines, line)
+	}
+	for i, line := range lines {
+		if r := []rune(line); len(r) > cols {
+			lines[i] = string(r[:cols])
+		}
+	}
+	return t.Print(strings.Join(lines, "\n"))
+}
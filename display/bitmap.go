@@ -0,0 +1,171 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// A Bitmap is a monochrome image, such as a sprite to be drawn with Blit.
+type Bitmap struct {
+	Width, Height int
+	// Pix holds the pixels row by row from the top left; true pixels are
+	// set, and are drawn lit.
+	Pix []bool
+	// Mask, if not nil, is a Bitmap of the same size whose clear pixels
+	// mark this one's transparent pixels, which Blit leaves alone.
+	Mask *Bitmap
+}
+
+// NewBitmap returns a clear Bitmap of the given size.
+func NewBitmap(width, height int) *Bitmap {
+	return &Bitmap{Width: width, Height: height, Pix: make([]bool, width*height)}
+}
+
+// At reports whether a pixel is set.  Pixels outside the bitmap are clear.
+func (b *Bitmap) At(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return false
+	}
+	return b.Pix[y*b.Width+x]
+}
+
+// Set sets or clears a pixel.  Pixels outside the bitmap are ignored.
+func (b *Bitmap) Set(x, y int, set bool) {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return
+	}
+	b.Pix[y*b.Width+x] = set
+}
+
+// opaque reports whether a pixel is drawn, according to the mask.
+func (b *Bitmap) opaque(x, y int) bool {
+	return b.Mask == nil || b.Mask.At(x, y)
+}
+
+// FromImage converts an image to a Bitmap, setting the pixels whose
+// brightness is at least half.  If the image has an alpha channel, pixels
+// less than half opaque are made transparent with a Mask.
+func FromImage(img image.Image) *Bitmap {
+	r := img.Bounds()
+	b := NewBitmap(r.Dx(), r.Dy())
+	var mask *Bitmap
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			c := img.At(r.Min.X+x, r.Min.Y+y)
+			b.Pix[y*b.Width+x] = color.GrayModel.Convert(c).(color.Gray).Y >= 0x80
+			if _, _, _, a := c.RGBA(); a < 0x8000 {
+				if mask == nil {
+					mask = NewBitmap(b.Width, b.Height)
+					for i := range mask.Pix {
+						mask.Pix[i] = true
+					}
+				}
+				mask.Pix[y*b.Width+x] = false
+			}
+		}
+	}
+	b.Mask = mask
+
+	return b
+}
+
+// An Op is a raster operation, saying how Blit combines a sprite's pixels
+// with those already on the display.
+type Op int
+
+// Raster operations.  Copy replaces the display's pixels with the sprite's;
+// Or lights the sprite's set pixels, And clears the display where the
+// sprite is clear, and Xor inverts the display where the sprite is set.
+const (
+	Copy Op = iota
+	Or
+	And
+	Xor
+)
+
+func (op Op) String() string {
+	switch op {
+	case Copy:
+		return "Copy"
+	case Or:
+		return "Or"
+	case And:
+		return "And"
+	case Xor:
+		return "Xor"
+	}
+	return "Unknown"
+}
+
+// A Canvas is a Display whose framebuffer can be read back, which Blit needs
+// for every Op but Copy.
+type Canvas interface {
+	Display
+	// Pixel reports whether a pixel is lit.  Pixels off the display are
+	// not.
+	Pixel(x, y int) bool
+}
+
+// Blit draws sprite with its top left corner at (x, y), combining it with
+// what is already there according to op.  Transparent pixels of the sprite,
+// as given by its Mask, are skipped.
+func Blit(d Canvas, x, y int, sprite *Bitmap, op Op) (err error) {
+	if op < Copy || op > Xor {
+		err = fmt.Errorf("Invalid raster operation: %d", op)
+		return
+	}
+
+	for sy := 0; sy < sprite.Height; sy++ {
+		for sx := 0; sx < sprite.Width; sx++ {
+			if !sprite.opaque(sx, sy) {
+				continue
+			}
+			src := sprite.Pix[sy*sprite.Width+sx]
+			var lit bool
+			switch op {
+			case Copy:
+				lit = src
+			case Or:
+				lit = d.Pixel(x+sx, y+sy) || src
+			case And:
+				lit = d.Pixel(x+sx, y+sy) && src
+			case Xor:
+				lit = d.Pixel(x+sx, y+sy) != src
+			}
+			if lit {
+				d.Point(x+sx, y+sy, color.White)
+			} else {
+				d.Point(x+sx, y+sy, color.Black)
+			}
+		}
+	}
+
+	return
+}
+
+// Dither converts an image to a Bitmap with Floyd-Steinberg error
+// diffusion, which keeps the look of greys and gradients that FromImage's
+// plain threshold would flatten.  It is a Converter using Diffusion with
+// the default settings.
+func Dither(img image.Image) *Bitmap {
+	return (&Converter{Method: Diffusion, Threshold: 0.5}).Convert(img)
+}
+
+// Draw draws the bitmap with its top left corner at (x, y), lighting its set
+// pixels and darkening its clear ones.  Transparent pixels are skipped.
+// Unlike Blit, this works on any Display.
+func (b *Bitmap) Draw(d Display, x, y int) {
+	for by := 0; by < b.Height; by++ {
+		for bx := 0; bx < b.Width; bx++ {
+			if !b.opaque(bx, by) {
+				continue
+			}
+			if b.Pix[by*b.Width+bx] {
+				d.Point(x+bx, y+by, color.White)
+			} else {
+				d.Point(x+bx, y+by, color.Black)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package display
+
+import (
+	"image"
+	"image/color"
+)
+
+// A SubCanvas is a rectangle of another Display, drawn on with coordinates
+// relative to its own top left corner.  Points outside the rectangle, or
+// outside its clip, are ignored, so a widget given a SubCanvas cannot draw
+// over its neighbours.
+type SubCanvas struct {
+	parent Display
+	bounds image.Rectangle
+	clip   image.Rectangle
+}
+
+var _ Canvas = (*SubCanvas)(nil)
+
+// NewSubCanvas returns a SubCanvas covering r of d, in d's coordinates.
+// SubCanvases may be nested.
+func NewSubCanvas(d Display, r image.Rectangle) *SubCanvas {
+	r = r.Canon()
+	return &SubCanvas{parent: d, bounds: r, clip: image.Rect(0, 0, r.Dx(), r.Dy())}
+}
+
+// Bounds returns the rectangle of the parent Display the SubCanvas covers.
+func (s *SubCanvas) Bounds() image.Rectangle {
+	return s.bounds
+}
+
+// Size returns the width and height of the SubCanvas.
+func (s *SubCanvas) Size() (width, height int) {
+	return s.bounds.Dx(), s.bounds.Dy()
+}
+
+// SetClip further restricts drawing to r, in the SubCanvas's coordinates.
+// The zero Rectangle removes the clip.
+func (s *SubCanvas) SetClip(r image.Rectangle) {
+	full := image.Rect(0, 0, s.bounds.Dx(), s.bounds.Dy())
+	if r == (image.Rectangle{}) {
+		s.clip = full
+		return
+	}
+	s.clip = r.Canon().Intersect(full)
+}
+
+// Clip returns the rectangle drawing is restricted to, in the SubCanvas's
+// coordinates.
+func (s *SubCanvas) Clip() image.Rectangle {
+	return s.clip
+}
+
+// Clear fills the clip rectangle with a colour.
+func (s *SubCanvas) Clear(c color.Gray16) {
+	Fill(s, s.clip, c)
+}
+
+// Point sets a pixel, if it is within the clip.
+func (s *SubCanvas) Point(x, y int, c color.Gray16) {
+	if !image.Pt(x, y).In(s.clip) {
+		return
+	}
+	s.parent.Point(s.bounds.Min.X+x, s.bounds.Min.Y+y, c)
+}
+
+// Pixel reports whether a pixel is lit.  It is always false if the parent
+// is not a Canvas.
+func (s *SubCanvas) Pixel(x, y int) bool {
+	p, ok := s.parent.(Canvas)
+	if !ok || !image.Pt(x, y).In(image.Rect(0, 0, s.bounds.Dx(), s.bounds.Dy())) {
+		return false
+	}
+	return p.Pixel(s.bounds.Min.X+x, s.bounds.Min.Y+y)
+}
+
+// Draw sends the whole of the parent Display's framebuffer to the display.
+func (s *SubCanvas) Draw() error {
+	return s.parent.Draw()
+}
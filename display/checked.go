@@ -0,0 +1,168 @@
+package display
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Errors returned by Checked.
+var (
+	ErrOutOfBounds     = errors.New("Coordinates out of bounds")
+	ErrNegativeRadius  = errors.New("Negative radius")
+	ErrReversedRect    = errors.New("Reversed rectangle")
+	ErrUnsupportedRune = errors.New("Character not in font")
+)
+
+// A BoundsError is returned when Checked is asked to draw off the display.
+// It wraps ErrOutOfBounds.
+type BoundsError struct {
+	// Op is the drawing operation, such as "Line".
+	Op string
+	// Rect is what was to be drawn, and Bounds the display's bounds.
+	Rect, Bounds image.Rectangle
+}
+
+func (e *BoundsError) Error() string {
+	return fmt.Sprintf("%s: %v outside %v", e.Op, e.Rect, e.Bounds)
+}
+
+func (e *BoundsError) Unwrap() error {
+	return ErrOutOfBounds
+}
+
+// Checked draws on a Display like the package's functions do, but returns
+// an error for invalid input instead of drawing nothing or something odd.
+// Drawing that runs off the display is an error too, unless Clip is set.
+// Nothing is drawn if an error is returned.
+type Checked struct {
+	d Display
+	// Clip, if set, makes drawing off the display silently clipped, as the
+	// package's functions do, rather than a *BoundsError.  Other invalid
+	// input is still an error.
+	Clip bool
+}
+
+// NewChecked returns a Checked drawing on d, with Clip unset.
+func NewChecked(d Display) *Checked {
+	return &Checked{d: d}
+}
+
+// Display returns the Display drawn on.
+func (c *Checked) Display() Display {
+	return c.d
+}
+
+// check returns a *BoundsError if r, a rectangle with exclusive Max, is not
+// within the display and Clip is unset.
+func (c *Checked) check(op string, r image.Rectangle) (err error) {
+	if c.Clip {
+		return
+	}
+	w, h := c.d.Size()
+	bounds := image.Rect(0, 0, w, h)
+	if !r.In(bounds) {
+		err = &BoundsError{Op: op, Rect: r, Bounds: bounds}
+	}
+	return
+}
+
+// Point sets a single pixel.
+func (c *Checked) Point(x, y int, col color.Gray16) (err error) {
+	if err = c.check("Point", image.Rect(x, y, x+1, y+1)); err != nil {
+		return
+	}
+	c.d.Point(x, y, col)
+	return
+}
+
+// Line draws a line from (x0, y0) to (x1, y1) inclusive.
+func (c *Checked) Line(x0, y0, x1, y1 int, col color.Gray16) (err error) {
+	r := image.Rect(x0, y0, x1, y1)
+	r.Max = r.Max.Add(image.Pt(1, 1))
+	if err = c.check("Line", r); err != nil {
+		return
+	}
+	Line(c.d, x0, y0, x1, y1, col)
+	return
+}
+
+// rect checks r for Rect and Fill.
+func (c *Checked) rect(op string, r image.Rectangle) (err error) {
+	if r.Min.X > r.Max.X || r.Min.Y > r.Max.Y {
+		err = fmt.Errorf("%s: %w: %v", op, ErrReversedRect, r)
+		return
+	}
+	return c.check(op, r)
+}
+
+// Rect draws the outline of r.  Unlike the package's Rect, a rectangle
+// whose Min is beyond its Max is an error rather than being swapped round.
+func (c *Checked) Rect(r image.Rectangle, col color.Gray16) (err error) {
+	if err = c.rect("Rect", r); err != nil {
+		return
+	}
+	Rect(c.d, r, col)
+	return
+}
+
+// Fill fills r, which must not be reversed, as for Rect.
+func (c *Checked) Fill(r image.Rectangle, col color.Gray16) (err error) {
+	if err = c.rect("Fill", r); err != nil {
+		return
+	}
+	Fill(c.d, r, col)
+	return
+}
+
+// Circle draws the outline of a circle centred on (x, y).
+func (c *Checked) Circle(x, y, radius int, col color.Gray16) (err error) {
+	if radius < 0 {
+		err = fmt.Errorf("Circle: %w: %d", ErrNegativeRadius, radius)
+		return
+	}
+	r := image.Rect(x-radius, y-radius, x+radius+1, y+radius+1)
+	if err = c.check("Circle", r); err != nil {
+		return
+	}
+	Circle(c.d, x, y, radius, col)
+	return
+}
+
+// Char draws a character as the package's Char does.  Characters outside
+// ASCII are an error rather than being drawn as '?', and the whole
+// character cell must be on the display unless Clip is set.
+func (c *Checked) Char(x, y int, col color.Gray16, bg *color.Gray16, r rune) (err error) {
+	if r < 0 || r > 127 {
+		err = fmt.Errorf("Char: %w: %q", ErrUnsupportedRune, r)
+		return
+	}
+	if err = c.check("Char", image.Rect(x, y, x+CharWidth, y+CharHeight)); err != nil {
+		return
+	}
+	Char(c.d, x, y, col, bg, r)
+	return
+}
+
+// String draws s as the package's String does, returning the x coordinate
+// just past its end.  Every character is checked before any is drawn.
+func (c *Checked) String(x, y int, col color.Gray16, bg *color.Gray16, s string) (end int, err error) {
+	cx, cy := x, y
+	for _, r := range s {
+		if r == '\n' {
+			cx, cy = x, cy+CharHeight
+			continue
+		}
+		if r < 0 || r > 127 {
+			err = fmt.Errorf("String: %w: %q", ErrUnsupportedRune, r)
+			return
+		}
+		if err = c.check("String", image.Rect(cx, cy, cx+CharWidth, cy+CharHeight)); err != nil {
+			return
+		}
+		cx += CharWidth
+	}
+	end = String(c.d, x, y, col, bg, s)
+	return
+}
@@ -0,0 +1,185 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// A Method is a way of turning grey levels into lit and dark pixels.
+type Method int
+
+const (
+	// Threshold lights the pixels brighter than the threshold.  It suits
+	// text and line art, including anti-aliased fonts.
+	Threshold Method = iota
+	// Ordered dithers with an 8x8 Bayer matrix, giving a regular pattern
+	// which is stable from frame to frame, so suits animation.
+	Ordered
+	// Diffusion dithers with Floyd-Steinberg error diffusion, as Dither
+	// does, which keeps the most detail in photos.
+	Diffusion
+)
+
+func (m Method) String() string {
+	switch m {
+	case Threshold:
+		return "threshold"
+	case Ordered:
+		return "ordered"
+	case Diffusion:
+		return "diffusion"
+	}
+	return fmt.Sprintf("Method(%d)", int(m))
+}
+
+// ParseMethod returns the Method with the given name, as returned by
+// String.
+func ParseMethod(name string) (m Method, err error) {
+	for m = Threshold; m <= Diffusion; m++ {
+		if m.String() == name {
+			return
+		}
+	}
+	err = fmt.Errorf("Unknown conversion method: %s", name)
+	return
+}
+
+// bayer is the 8x8 Bayer matrix, whose entries are spread evenly over
+// 0 to 63 so that neighbouring thresholds differ as much as possible.
+var bayer = [8][8]uint8{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// A Region converts part of an image differently from the rest, such as
+// thresholding a caption over a dithered photo.  Its Threshold is as for
+// a Converter.
+type Region struct {
+	// Rect is the part of the image, relative to its top left corner.
+	Rect      image.Rectangle
+	Method    Method
+	Threshold float64
+}
+
+// A Converter converts greyscale images to monochrome Bitmaps.  Each
+// pixel's brightness, from 0 to 1, is adjusted by Gamma, Contrast and
+// Brightness in that order before Method decides whether to light it.  The
+// zero Converter thresholds at half brightness, as FromImage does.
+type Converter struct {
+	Method Method
+	// Threshold is the brightness at which Threshold lights a pixel,
+	// and around which the dithering methods centre their patterns, 0.5
+	// if zero.
+	Threshold float64
+	// Gamma raises each brightness to its power: above 1 darkens the
+	// mid-tones, and below 1 lightens them, which OLEDs often need since
+	// they show dark greys brighter than they should.  Zero means 1.
+	Gamma float64
+	// Contrast scales each brightness about 0.5; zero means 1.
+	Contrast float64
+	// Brightness is added to each brightness.
+	Brightness float64
+	// Invert swaps lit and dark pixels.
+	Invert bool
+	// Regions override Method and Threshold for parts of the image.  Where
+	// they overlap, the last wins.
+	Regions []Region
+}
+
+// levels returns the adjusted brightness of each grey level, from 0 to 255.
+func (c *Converter) levels() (lut [256]int) {
+	gamma, contrast := c.Gamma, c.Contrast
+	if gamma <= 0 {
+		gamma = 1
+	}
+	if contrast == 0 {
+		contrast = 1
+	}
+	for i := range lut {
+		v := math.Pow(float64(i)/255, gamma)
+		v = (v-0.5)*contrast + 0.5 + c.Brightness
+		v = math.Max(0, math.Min(1, v))
+		if c.Invert {
+			v = 1 - v
+		}
+		lut[i] = int(math.Round(v * 255))
+	}
+	return
+}
+
+// level returns a threshold from 0 to 1 as a grey level.
+func level(threshold float64) int {
+	if threshold <= 0 {
+		threshold = 0.5
+	}
+	return int(math.Round(math.Min(threshold, 1) * 255))
+}
+
+// Convert converts an image to a Bitmap.  Transparency is ignored; use
+// FromImage for sprites with transparent parts.
+func (c *Converter) Convert(img image.Image) *Bitmap {
+	r := img.Bounds()
+	b := NewBitmap(r.Dx(), r.Dy())
+	lut := c.levels()
+	thresholds := make([]int, len(c.Regions))
+	for i, reg := range c.Regions {
+		thresholds[i] = level(reg.Threshold)
+	}
+	method, threshold := c.Method, level(c.Threshold)
+
+	// Brightness plus carried error for this row and the next
+	cur := make([]int, b.Width+2)
+	next := make([]int, b.Width+2)
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			m, t := method, threshold
+			for i := len(c.Regions) - 1; i >= 0; i-- {
+				if image.Pt(x, y).In(c.Regions[i].Rect) {
+					m, t = c.Regions[i].Method, thresholds[i]
+					break
+				}
+			}
+
+			v := lut[color.GrayModel.Convert(img.At(r.Min.X+x, r.Min.Y+y)).(color.Gray).Y]
+			switch m {
+			case Ordered:
+				// Spread the thresholds over t-128 to t+127
+				t += (int(bayer[y%8][x%8])*4 + 2) - 128
+				b.Pix[y*b.Width+x] = v >= t
+			case Diffusion:
+				v += cur[x+1]
+				e := v
+				if v >= t {
+					b.Pix[y*b.Width+x] = true
+					e = v - 0xff
+				}
+				cur[x+2] += e * 7 / 16
+				next[x] += e * 3 / 16
+				next[x+1] += e * 5 / 16
+				next[x+2] += e / 16
+			default:
+				b.Pix[y*b.Width+x] = v >= t
+			}
+		}
+		cur, next = next, cur
+		for i := range next {
+			next[i] = 0
+		}
+	}
+
+	return b
+}
+
+// ConvertTo converts an image as Convert does and draws it on d with its
+// top left corner at (x, y).
+func (c *Converter) ConvertTo(d Display, x, y int, img image.Image) {
+	c.Convert(img).Draw(d, x, y)
+}
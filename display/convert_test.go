@@ -0,0 +1,159 @@
+package display
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// grey returns a w by h image filled with one grey level.
+func grey(w, h int, y uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = y
+	}
+	return img
+}
+
+// lit returns the fraction of a Bitmap's pixels which are set.
+func lit(b *Bitmap) float64 {
+	n := 0
+	for _, p := range b.Pix {
+		if p {
+			n++
+		}
+	}
+	return float64(n) / float64(len(b.Pix))
+}
+
+func TestParseMethod(t *testing.T) {
+	tests := []struct {
+		name string
+		want Method
+		ok   bool
+	}{
+		{"threshold", Threshold, true},
+		{"ordered", Ordered, true},
+		{"diffusion", Diffusion, true},
+		{"Diffusion", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		m, err := ParseMethod(test.name)
+		if (err == nil) != test.ok || (test.ok && m != test.want) {
+			t.Errorf("ParseMethod(%q) = %v, %v", test.name, m, err)
+		}
+	}
+}
+
+func TestConvertUniform(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Converter
+		grey uint8
+		want float64
+	}{
+		{"threshold below", Converter{}, 127, 0},
+		{"threshold at", Converter{}, 128, 1},
+		{"threshold raised", Converter{Threshold: 0.75}, 128, 0},
+		{"gamma darkens", Converter{Gamma: 2}, 180, 0},
+		{"gamma lightens", Converter{Gamma: 0.5}, 64, 1},
+		{"contrast", Converter{Contrast: 3}, 100, 0},
+		{"brightness", Converter{Brightness: 0.3}, 100, 1},
+		{"invert", Converter{Invert: true}, 255, 0},
+		{"ordered black", Converter{Method: Ordered}, 0, 0},
+		{"ordered white", Converter{Method: Ordered}, 255, 1},
+		{"ordered half", Converter{Method: Ordered}, 128, 0.5},
+		{"ordered quarter", Converter{Method: Ordered}, 64, 0.25},
+		{"diffusion black", Converter{Method: Diffusion}, 0, 0},
+		{"diffusion white", Converter{Method: Diffusion}, 255, 1},
+		{"diffusion half", Converter{Method: Diffusion}, 128, 0.5},
+		{"diffusion quarter", Converter{Method: Diffusion}, 64, 0.25},
+		{"diffusion inverted", Converter{Method: Diffusion, Invert: true}, 64, 0.75},
+	}
+	for _, test := range tests {
+		got := lit(test.c.Convert(grey(64, 64, test.grey)))
+		if math.Abs(got-test.want) > 0.02 {
+			t.Errorf("%s: %.3f of pixels lit, want %.3f", test.name, got, test.want)
+		}
+	}
+}
+
+func TestConvertRegions(t *testing.T) {
+	c := Converter{
+		Method: Diffusion,
+		Regions: []Region{
+			{Rect: image.Rect(0, 0, 16, 16), Method: Threshold},
+			{Rect: image.Rect(8, 8, 24, 24), Method: Threshold, Threshold: 0.9},
+		},
+	}
+	b := c.Convert(grey(32, 32, 200))
+
+	tests := []struct {
+		x, y int
+		want bool
+	}{
+		{0, 0, true},
+		{7, 15, true},
+		{15, 7, true},
+		{8, 8, false},
+		{23, 23, false},
+	}
+	for _, test := range tests {
+		if got := b.At(test.x, test.y); got != test.want {
+			t.Errorf("Pixel (%d, %d) = %v, want %v", test.x, test.y, got, test.want)
+		}
+	}
+	// Below the regions, 200 is dithered rather than all lit
+	below := &Bitmap{Width: 32, Height: 8, Pix: b.Pix[24*32:]}
+	if got := lit(below); got == 1 || math.Abs(got-200.0/255) > 0.05 {
+		t.Errorf("Outside the regions: %.3f of pixels lit, want %.3f", got, 200.0/255)
+	}
+}
+
+func TestConvertBounds(t *testing.T) {
+	// Only the image's bounds are converted, whatever their origin
+	img := image.NewGray(image.Rect(10, 20, 14, 22))
+	img.SetGray(10, 20, color.Gray{0xff})
+	img.SetGray(13, 21, color.Gray{0xff})
+
+	b := (&Converter{}).Convert(img)
+	want := &Bitmap{Width: 4, Height: 2, Pix: []bool{
+		true, false, false, false,
+		false, false, false, true,
+	}}
+	if !reflect.DeepEqual(b, want) {
+		t.Errorf("Convert = %v, want %v", b, want)
+	}
+}
+
+func TestDither(t *testing.T) {
+	// A horizontal gradient keeps its brightness from left to right
+	img := image.NewGray(image.Rect(0, 0, 256, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 256; x++ {
+			img.SetGray(x, y, color.Gray{uint8(x)})
+		}
+	}
+	b := Dither(img)
+
+	if want := (&Converter{Method: Diffusion}).Convert(img); !reflect.DeepEqual(b, want) {
+		t.Errorf("Dither differs from a Diffusion Converter")
+	}
+	for x0 := 0; x0 < 256; x0 += 32 {
+		n := 0
+		for y := 0; y < 32; y++ {
+			for x := x0; x < x0+32; x++ {
+				if b.At(x, y) {
+					n++
+				}
+			}
+		}
+		got, want := float64(n)/(32*32), (float64(x0)+15.5)/255
+		if math.Abs(got-want) > 0.03 {
+			t.Errorf("Columns %d to %d: %.3f of pixels lit, want %.3f", x0, x0+31, got, want)
+		}
+	}
+}
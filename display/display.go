@@ -0,0 +1,92 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package display holds what is common to GopherBone's monochrome displays,
+// such as the SSD1306: the Display interface, text drawing with the built in
+// font, and things built on them, such as Terminal.
+package display
+
+import (
+	"image/color"
+)
+
+// A Display is a monochrome display with a framebuffer, which is drawn into
+// and then sent to the display with Draw.  Coordinates are in pixels from the
+// top left corner.
+type Display interface {
+	// Size returns the width and height of the display in pixels.
+	Size() (width, height int)
+	// Clear fills the framebuffer with a colour.
+	Clear(c color.Gray16)
+	// Point sets a single pixel.  Points off the display are ignored.
+	Point(x, y int, c color.Gray16)
+	// Draw sends the framebuffer to the display.
+	Draw() error
+}
+
+// The size of a character cell of Font, including a column of space to the
+// right of each glyph.
+const (
+	CharWidth  = 6
+	CharHeight = 8
+)
+
+// Char draws a character of Font with its top left corner at (x, y).  Only
+// the glyph's set pixels are drawn, in colour c; if bg is not nil, the rest
+// of the character cell is filled with it.  Characters outside ASCII are
+// drawn as '?'.
+func Char(d Display, x, y int, c color.Gray16, bg *color.Gray16, r rune) {
+	if r < 0 || r > 127 {
+		r = '?'
+	}
+	for i := 0; i < CharWidth; i++ {
+		var col byte
+		if i < 5 {
+			col = Font[5*int(r)+i]
+		}
+		for j := 0; j < CharHeight; j++ {
+			if col&(1<<uint(j)) != 0 {
+				d.Point(x+i, y+j, c)
+			} else if bg != nil {
+				d.Point(x+i, y+j, *bg)
+			}
+		}
+	}
+}
+
+// String draws s from left to right starting with its top left corner at
+// (x, y), as Char does, and returns the x coordinate just past its end.
+// Newlines start a new line below, back at x.
+func String(d Display, x, y int, c color.Gray16, bg *color.Gray16, s string) (end int) {
+	end = x
+	for _, r := range s {
+		if r == '\n' {
+			end = x
+			y += CharHeight
+			continue
+		}
+		Char(d, end, y, c, bg, r)
+		end += CharWidth
+	}
+
+	return
+}
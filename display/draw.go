@@ -0,0 +1,90 @@
+package display
+
+import (
+	"image"
+	"image/color"
+)
+
+// Line draws a line from (x0, y0) to (x1, y1) inclusive, using Bresenham's
+// algorithm.
+func Line(d Display, x0, y0, x1, y1 int, c color.Gray16) {
+	dx, sx := x1-x0, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	dy, sy := y1-y0, 1
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+
+	err := dx - dy
+	for {
+		d.Point(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 > -dy {
+			err -= dy
+			x0 += sx
+		}
+		if e2 < dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// Rect draws the outline of r, whose Max is exclusive as with all
+// image.Rectangles.
+func Rect(d Display, r image.Rectangle, c color.Gray16) {
+	r = r.Canon()
+	if r.Empty() {
+		return
+	}
+	Line(d, r.Min.X, r.Min.Y, r.Max.X-1, r.Min.Y, c)
+	Line(d, r.Min.X, r.Max.Y-1, r.Max.X-1, r.Max.Y-1, c)
+	Line(d, r.Min.X, r.Min.Y, r.Min.X, r.Max.Y-1, c)
+	Line(d, r.Max.X-1, r.Min.Y, r.Max.X-1, r.Max.Y-1, c)
+}
+
+// Fill fills r.
+func Fill(d Display, r image.Rectangle, c color.Gray16) {
+	r = r.Canon()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			d.Point(x, y, c)
+		}
+	}
+}
+
+// Circle draws the outline of a circle centred on (x0, y0), using the
+// midpoint algorithm.
+func Circle(d Display, x0, y0, radius int, c color.Gray16) {
+	f := 1 - radius
+	ddx, ddy := 1, -2*radius
+	x, y := 0, radius
+
+	d.Point(x0, y0+radius, c)
+	d.Point(x0, y0-radius, c)
+	d.Point(x0+radius, y0, c)
+	d.Point(x0-radius, y0, c)
+	for x < y {
+		if f >= 0 {
+			y--
+			ddy += 2
+			f += ddy
+		}
+		x++
+		ddx += 2
+		f += ddx
+		d.Point(x0+x, y0+y, c)
+		d.Point(x0-x, y0+y, c)
+		d.Point(x0+x, y0-y, c)
+		d.Point(x0-x, y0-y, c)
+		d.Point(x0+y, y0+x, c)
+		d.Point(x0-y, y0+x, c)
+		d.Point(x0+y, y0-x, c)
+		d.Point(x0-y, y0-x, c)
+	}
+}
@@ -1,6 +1,9 @@
-package ssd1306
+package display
 
-var font = [640]byte{
+// Font is a 5x8 pixel font covering the 128 ASCII characters.  Each glyph is
+// five bytes, one per column from left to right, with bit 0 at the top.  The
+// bottom row is left blank, so that lines of text don't touch.
+var Font = [640]byte{
 	0x00, 0x00, 0x00, 0x00, 0x00,
 	0x00, 0x00, 0x00, 0x00, 0x00,
 	0x00, 0x00, 0x00, 0x00, 0x00,
@@ -0,0 +1,120 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+)
+
+// DefaultGIFDelay is used for frames whose delay is zero, which web
+// browsers also treat as a tenth of a second rather than as fast as
+// possible.
+var DefaultGIFDelay = 100 * time.Millisecond
+
+// An Animation is a decoded animated GIF, converted to dithered monochrome
+// frames ready to be played on a Display.
+type Animation struct {
+	Frames []*Bitmap
+	Delays []time.Duration
+	// LoopCount is as in image/gif: 0 loops forever, -1 plays once, and n
+	// plays n+1 times.
+	LoopCount int
+}
+
+// DecodeGIF reads an animated GIF and converts its frames to monochrome,
+// compositing each onto those before it as the GIF's disposal methods say.
+func DecodeGIF(r io.Reader) (a *Animation, err error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return
+	}
+	if len(g.Image) == 0 {
+		err = fmt.Errorf("GIF has no frames")
+		return
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, bounds, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	prev := image.NewRGBA(bounds)
+
+	a = &Animation{LoopCount: g.LoopCount}
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			copy(prev.Pix, canvas.Pix)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		a.Frames = append(a.Frames, Dither(canvas))
+		delay := time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		if delay == 0 {
+			delay = DefaultGIFDelay
+		}
+		a.Delays = append(a.Delays, delay)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			copy(canvas.Pix, prev.Pix)
+		}
+	}
+
+	return
+}
+
+// Play shows the animation centred on d, waiting each frame's delay before
+// the next, until it has looped LoopCount times or stop is closed.  Time
+// spent drawing counts toward the delay, so the animation keeps its speed on
+// slow displays.
+func (a *Animation) Play(d Display, stop <-chan struct{}) (err error) {
+	if len(a.Frames) == 0 {
+		return
+	}
+	w, h := d.Size()
+	x := (w - a.Frames[0].Width) / 2
+	y := (h - a.Frames[0].Height) / 2
+
+	plays := a.LoopCount + 1
+	if a.LoopCount < 0 {
+		plays = 1
+	}
+	for n := 0; a.LoopCount == 0 || n < plays; n++ {
+		for i, frame := range a.Frames {
+			timer := time.NewTimer(a.Delays[i])
+			frame.Draw(d, x, y)
+			if err = d.Draw(); err != nil {
+				timer.Stop()
+				return
+			}
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// PlayGIF decodes an animated GIF and plays it on d, as Animation.Play does.
+func PlayGIF(d Display, r io.Reader, stop <-chan struct{}) (err error) {
+	a, err := DecodeGIF(r)
+	if err != nil {
+		return
+	}
+	return a.Play(d, stop)
+}
@@ -0,0 +1,210 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+)
+
+// drawAll draws each display at once, as they may be on different buses,
+// returning the first error.
+func drawAll(displays []Display) (err error) {
+	errs := make([]error, len(displays))
+	var wg sync.WaitGroup
+	for i, d := range displays {
+		wg.Add(1)
+		go func(i int, d Display) {
+			defer wg.Done()
+			errs[i] = d.Draw()
+		}(i, d)
+	}
+	wg.Wait()
+	for _, e := range errs {
+		if e != nil {
+			return e
+		}
+	}
+	return
+}
+
+// A Mirror shows the same picture on several displays, such as identical
+// SSD1306s on different addresses or buses.  Its size is the first
+// display's; smaller displays show the top left of the picture.
+type Mirror struct {
+	displays []Display
+}
+
+var _ Canvas = (*Mirror)(nil)
+
+// NewMirror returns a Mirror drawing on the displays.
+func NewMirror(displays ...Display) (m *Mirror, err error) {
+	if len(displays) == 0 {
+		err = fmt.Errorf("No displays to mirror")
+		return
+	}
+	m = &Mirror{displays: displays}
+	return
+}
+
+// Displays returns the mirrored displays.
+func (m *Mirror) Displays() []Display {
+	return m.displays
+}
+
+// Size returns the size of the first display.
+func (m *Mirror) Size() (width, height int) {
+	return m.displays[0].Size()
+}
+
+// Clear fills every display with a colour.
+func (m *Mirror) Clear(c color.Gray16) {
+	for _, d := range m.displays {
+		d.Clear(c)
+	}
+}
+
+// Point sets a pixel on every display.
+func (m *Mirror) Point(x, y int, c color.Gray16) {
+	for _, d := range m.displays {
+		d.Point(x, y, c)
+	}
+}
+
+// Pixel reports whether a pixel of the first display is lit.  It is always
+// false if the first display is not a Canvas.
+func (m *Mirror) Pixel(x, y int) bool {
+	if c, ok := m.displays[0].(Canvas); ok {
+		return c.Pixel(x, y)
+	}
+	return false
+}
+
+// Draw draws every display, returning the first error.
+func (m *Mirror) Draw() error {
+	return drawAll(m.displays)
+}
+
+// A Tile places a display on a Tiled canvas, with its top left corner at
+// Offset.
+type Tile struct {
+	Display Display
+	Offset  image.Point
+}
+
+// bounds returns the rectangle of the canvas the tile covers.
+func (t Tile) bounds() image.Rectangle {
+	w, h := t.Display.Size()
+	return image.Rect(0, 0, w, h).Add(t.Offset)
+}
+
+// A Tiled is one large canvas split across several displays, each showing
+// the part of it given by its tile's offset and its own size.  Tiles may
+// overlap, in which case each shows the overlapping part, and gaps between
+// them are simply not shown, which suits displays mounted with a bezel
+// between them.
+type Tiled struct {
+	tiles  []Tile
+	bounds image.Rectangle
+}
+
+var _ Canvas = (*Tiled)(nil)
+
+// NewTiled returns a Tiled canvas made of the tiles.  The canvas's origin
+// is the top left of the tiles' bounding box, so offsets may be negative.
+func NewTiled(tiles ...Tile) (t *Tiled, err error) {
+	if len(tiles) == 0 {
+		err = fmt.Errorf("No displays to tile")
+		return
+	}
+	t = &Tiled{tiles: append([]Tile(nil), tiles...)}
+	for i, tile := range t.tiles {
+		if i == 0 {
+			t.bounds = tile.bounds()
+		} else {
+			t.bounds = t.bounds.Union(tile.bounds())
+		}
+	}
+	for i := range t.tiles {
+		t.tiles[i].Offset = t.tiles[i].Offset.Sub(t.bounds.Min)
+	}
+	t.bounds = t.bounds.Sub(t.bounds.Min)
+	return
+}
+
+// Row returns a Tiled canvas of the displays side by side, left to right,
+// with gap pixels hidden between each pair.
+func Row(gap int, displays ...Display) (*Tiled, error) {
+	var tiles []Tile
+	x := 0
+	for _, d := range displays {
+		tiles = append(tiles, Tile{Display: d, Offset: image.Pt(x, 0)})
+		w, _ := d.Size()
+		x += w + gap
+	}
+	return NewTiled(tiles...)
+}
+
+// Column returns a Tiled canvas of the displays one above another, top to
+// bottom, with gap pixels hidden between each pair.
+func Column(gap int, displays ...Display) (*Tiled, error) {
+	var tiles []Tile
+	y := 0
+	for _, d := range displays {
+		tiles = append(tiles, Tile{Display: d, Offset: image.Pt(0, y)})
+		_, h := d.Size()
+		y += h + gap
+	}
+	return NewTiled(tiles...)
+}
+
+// Tiles returns the tiles, with offsets relative to the canvas's origin.
+func (t *Tiled) Tiles() []Tile {
+	return t.tiles
+}
+
+// Size returns the size of the whole canvas.
+func (t *Tiled) Size() (width, height int) {
+	return t.bounds.Dx(), t.bounds.Dy()
+}
+
+// Clear fills every display with a colour.
+func (t *Tiled) Clear(c color.Gray16) {
+	for _, tile := range t.tiles {
+		tile.Display.Clear(c)
+	}
+}
+
+// Point sets a pixel on whichever displays show it.
+func (t *Tiled) Point(x, y int, c color.Gray16) {
+	p := image.Pt(x, y)
+	for _, tile := range t.tiles {
+		if p.In(tile.bounds()) {
+			tile.Display.Point(x-tile.Offset.X, y-tile.Offset.Y, c)
+		}
+	}
+}
+
+// Pixel reports whether a pixel is lit on the first display showing it.
+// Pixels in gaps, or on displays which aren't Canvases, are not.
+func (t *Tiled) Pixel(x, y int) bool {
+	p := image.Pt(x, y)
+	for _, tile := range t.tiles {
+		if p.In(tile.bounds()) {
+			if c, ok := tile.Display.(Canvas); ok {
+				return c.Pixel(x-tile.Offset.X, y-tile.Offset.Y)
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// Draw draws every display, returning the first error.
+func (t *Tiled) Draw() error {
+	displays := make([]Display, len(t.tiles))
+	for i, tile := range t.tiles {
+		displays[i] = tile.Display
+	}
+	return drawAll(displays)
+}
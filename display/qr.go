@@ -0,0 +1,498 @@
+package display
+
+import (
+	"errors"
+	"image/color"
+)
+
+// ErrQRTooLong is returned when data will not fit in the largest QR code
+// DrawQR makes.
+var ErrQRTooLong = errors.New("Data too long for a QR code")
+
+// QRBorder is the width of the light quiet zone DrawQR draws around the
+// code, in modules.  The standard asks for 4, but 2 is enough for most
+// phones and saves space on a small display.
+var QRBorder = 2
+
+// The QR codes made here are versions 1 to 10 at error correction level M,
+// holding up to 213 bytes, which is as large as will fit on a 64 pixel tall
+// display.
+const qrMaxVersion = 10
+
+// Error correction codewords per block and number of blocks at level M,
+// indexed by version.
+var qrECCLen = [qrMaxVersion + 1]int{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26}
+var qrBlocks = [qrMaxVersion + 1]int{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5}
+
+// QRSize returns the width and height in pixels of the QR code DrawQR would
+// draw for data at the given scale, including its quiet zone.
+func QRSize(data string, scale int) (size int, err error) {
+	v, err := qrVersion(len(data))
+	if err != nil {
+		return
+	}
+	size = (qrModules(v) + 2*QRBorder) * scale
+	return
+}
+
+// DrawQR draws data as a QR code with its top left corner, including the
+// quiet zone, at (x, y).  Each module is scale pixels square.  Dark modules
+// are drawn black and light ones white, as scanners expect.
+func DrawQR(d Display, data string, x, y, scale int) (err error) {
+	q, err := encodeQR([]byte(data))
+	if err != nil {
+		return
+	}
+
+	n := len(q) + 2*QRBorder
+	for my := 0; my < n; my++ {
+		for mx := 0; mx < n; mx++ {
+			c := color.White
+			qx, qy := mx-QRBorder, my-QRBorder
+			if qx >= 0 && qy >= 0 && qx < len(q) && qy < len(q) && q[qy][qx] {
+				c = color.Black
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					d.Point(x+mx*scale+px, y+my*scale+py, c)
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// qrModules returns the width of a QR code of version v in modules.
+func qrModules(v int) int {
+	return 4*v + 17
+}
+
+// qrCodewords returns the number of codewords, data and error correction,
+// that a QR code of version v holds.
+func qrCodewords(v int) int {
+	bits := (16*v+128)*v + 64
+	if v >= 2 {
+		align := v/7 + 2
+		bits -= (25*align-10)*align - 55
+		if v >= 7 {
+			bits -= 36
+		}
+	}
+	return bits / 8
+}
+
+// qrDataCodewords returns the number of data codewords in version v.
+func qrDataCodewords(v int) int {
+	return qrCodewords(v) - qrECCLen[v]*qrBlocks[v]
+}
+
+// qrCountBits returns the length of the byte mode character count.
+func qrCountBits(v int) int {
+	if v < 10 {
+		return 8
+	}
+	return 16
+}
+
+// qrVersion returns the smallest version that holds n bytes.
+func qrVersion(n int) (v int, err error) {
+	for v = 1; v <= qrMaxVersion; v++ {
+		if 4+qrCountBits(v)+8*n <= 8*qrDataCodewords(v) {
+			return
+		}
+	}
+	err = ErrQRTooLong
+	return
+}
+
+// qrAlignment returns the row and column coordinates of the alignment
+// patterns of version v.
+func qrAlignment(v int) []int {
+	if v == 1 {
+		return nil
+	}
+	n := v/7 + 2
+	step := (v*4 + n*2 + 1) / (n*2 - 2) * 2
+	pos := make([]int, n)
+	pos[0] = 6
+	for i, p := n-1, qrModules(v)-7; i >= 1; i, p = i-1, p-step {
+		pos[i] = p
+	}
+	return pos
+}
+
+// encodeQR returns the modules of a QR code holding data in byte mode, true
+// for dark.
+func encodeQR(data []byte) (modules [][]bool, err error) {
+	v, err := qrVersion(len(data))
+	if err != nil {
+		return
+	}
+
+	// Bit stream: mode, count, data, terminator, then padding
+	var bits []bool
+	put := func(val, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, val>>uint(i)&1 != 0)
+		}
+	}
+	put(0x4, 4)
+	put(len(data), qrCountBits(v))
+	for _, b := range data {
+		put(int(b), 8)
+	}
+	capacity := 8 * qrDataCodewords(v)
+	for i := 0; i < 4 && len(bits) < capacity; i++ {
+		put(0, 1)
+	}
+	for len(bits)%8 != 0 {
+		put(0, 1)
+	}
+	for pad := 0xec; len(bits) < capacity; pad ^= 0xec ^ 0x11 {
+		put(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, b := range bits {
+		if b {
+			codewords[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+
+	q := newQR(v)
+	q.drawCodewords(qrInterleave(v, codewords))
+
+	// Use the mask that makes the code easiest to scan
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+
+	modules = q.modules
+	return
+}
+
+// qrInterleave splits data into blocks, adds each block's error correction,
+// and interleaves the lot as it is placed in the code.
+func qrInterleave(v int, data []byte) (out []byte) {
+	blocks, eccLen := qrBlocks[v], qrECCLen[v]
+	total := qrCodewords(v)
+	short := blocks - total%blocks
+	shortLen := total/blocks - eccLen
+
+	divisor := rsDivisor(eccLen)
+	dat := make([][]byte, blocks)
+	ecc := make([][]byte, blocks)
+	for i, k := 0, 0; i < blocks; i++ {
+		n := shortLen
+		if i >= short {
+			n++
+		}
+		dat[i] = data[k : k+n]
+		ecc[i] = rsRemainder(dat[i], divisor)
+		k += n
+	}
+
+	for i := 0; i <= shortLen; i++ {
+		for j := range dat {
+			if i < len(dat[j]) {
+				out = append(out, dat[j][i])
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for j := range ecc {
+			out = append(out, ecc[j][i])
+		}
+	}
+	return
+}
+
+// gfMul multiplies in GF(2^8) modulo the QR polynomial x^8+x^4+x^3+x^2+1.
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11d
+		z ^= int(y>>uint(i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the given
+// degree, highest coefficient first and the leading 1 omitted.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the Reed-Solomon error correction codewords for data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// qr is a QR code under construction.
+type qr struct {
+	version  int
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// newQR returns a QR code of version v with its function patterns drawn.
+func newQR(v int) *qr {
+	q := &qr{version: v, size: qrModules(v)}
+	q.modules = make([][]bool, q.size)
+	q.function = make([][]bool, q.size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, q.size)
+		q.function[i] = make([]bool, q.size)
+	}
+
+	for i := 0; i < q.size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	for _, c := range [][2]int{{3, 3}, {q.size - 4, 3}, {3, q.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x >= 0 && y >= 0 && x < q.size && y < q.size {
+					dist := max(abs(dx), abs(dy))
+					q.set(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+	pos := qrAlignment(v)
+	last := len(pos) - 1
+	for i := range pos {
+		for j := range pos {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(pos[i]+dx, pos[j]+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	// Reserve the format areas until the mask is chosen
+	q.drawFormat(0)
+
+	if v >= 7 {
+		rem := v
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1f25
+		}
+		bits := v<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>uint(i)&1 != 0
+			a, b := q.size-11+i%3, i/3
+			q.set(a, b, dark)
+			q.set(b, a, dark)
+		}
+	}
+
+	return q
+}
+
+// set sets a function module.
+func (q *qr) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+// drawFormat draws both copies of the format information for level M and
+// the given mask.
+func (q *qr) drawFormat(mask int) {
+	// Level M is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+// drawCodewords places data in the zigzag of two module wide columns from
+// the bottom right, skipping function modules.
+func (q *qr) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.function[y][x] && i < len(data)*8 {
+					q.modules[y][x] = data[i/8]&(0x80>>uint(i%8)) != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by a mask pattern.  Applying
+// it twice undoes it.
+func (q *qr) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.function[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code would be to scan, by the rules of the
+// QR standard: long runs, 2x2 blocks, finder-like patterns and imbalance
+// between dark and light.
+func (q *qr) penalty() (p int) {
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return q.modules[x][y]
+		}
+		return q.modules[y][x]
+	}
+	finder := []bool{true, false, true, true, true, false, true}
+
+	for _, t := range []bool{false, true} {
+		for y := 0; y < q.size; y++ {
+			run := 1
+			for x := 1; x <= q.size; x++ {
+				if x < q.size && at(x, y, t) == at(x-1, y, t) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			for x := 0; x+7 <= q.size; x++ {
+				match := true
+				for i, dark := range finder {
+					if at(x+i, y, t) != dark {
+						match = false
+						break
+					}
+				}
+				if match && (q.light(x-4, x, y, t) || q.light(x+7, x+11, y, t)) {
+					p += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size {
+				c := q.modules[y][x]
+				if q.modules[y][x+1] == c && q.modules[y+1][x] == c && q.modules[y+1][x+1] == c {
+					p += 3
+				}
+			}
+		}
+	}
+	total := q.size * q.size
+	p += (abs(dark*20-total*10)+total-1)/total*10 - 10
+
+	return
+}
+
+// light reports whether modules from and up to but not including to along
+// row y, or column y if transpose, are all light.  Modules outside the code
+// count as light.
+func (q *qr) light(from, to, y int, transpose bool) bool {
+	for x := from; x < to; x++ {
+		if x < 0 || x >= q.size {
+			continue
+		}
+		if transpose && q.modules[x][y] || !transpose && q.modules[y][x] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,272 @@
+package display
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGFMul(t *testing.T) {
+	tests := []struct {
+		x, y, want byte
+	}{
+		{0, 0x53, 0},
+		{1, 0x53, 0x53},
+		{3, 7, 9},
+		{0x80, 2, 0x1d},
+		{2, 0x8e, 1},
+	}
+	for _, test := range tests {
+		if got := gfMul(test.x, test.y); got != test.want {
+			t.Errorf("gfMul(%#02x, %#02x) = %#02x, want %#02x", test.x, test.y, got, test.want)
+		}
+	}
+}
+
+func TestRSDivisor(t *testing.T) {
+	tests := []struct {
+		degree int
+		want   []byte
+	}{
+		{2, []byte{3, 2}},
+		{7, []byte{127, 122, 154, 164, 11, 68, 117}},
+	}
+	for _, test := range tests {
+		if got := rsDivisor(test.degree); !bytes.Equal(got, test.want) {
+			t.Errorf("rsDivisor(%d) = %v, want %v", test.degree, got, test.want)
+		}
+	}
+}
+
+func TestRSRemainder(t *testing.T) {
+	tests := []struct {
+		name      string
+		data, ecc []byte
+	}{
+		{
+			"HELLO WORLD 1-M",
+			[]byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17},
+			[]byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23},
+		},
+		{
+			"zeros",
+			make([]byte, 16),
+			make([]byte, 10),
+		},
+	}
+	for _, test := range tests {
+		got := rsRemainder(test.data, rsDivisor(len(test.ecc)))
+		if !bytes.Equal(got, test.ecc) {
+			t.Errorf("%s: rsRemainder = %v, want %v", test.name, got, test.ecc)
+		}
+	}
+}
+
+func TestQRVersion(t *testing.T) {
+	tests := []struct {
+		n, want int
+		err     error
+	}{
+		{0, 1, nil},
+		{14, 1, nil},
+		{15, 2, nil},
+		{26, 2, nil},
+		{27, 3, nil},
+		{62, 4, nil},
+		{63, 5, nil},
+		{152, 8, nil},
+		{153, 9, nil},
+		{213, 10, nil},
+		{214, 0, ErrQRTooLong},
+	}
+	for _, test := range tests {
+		v, err := qrVersion(test.n)
+		if !errors.Is(err, test.err) || (err == nil && v != test.want) {
+			t.Errorf("qrVersion(%d) = %d, %v, want %d, %v", test.n, v, err, test.want, test.err)
+		}
+	}
+}
+
+func TestQRLayout(t *testing.T) {
+	tests := []struct {
+		v, modules, codewords, data int
+		alignment                   []int
+	}{
+		{1, 21, 26, 16, nil},
+		{2, 25, 44, 28, []int{6, 18}},
+		{6, 41, 172, 108, []int{6, 34}},
+		{7, 45, 196, 124, []int{6, 22, 38}},
+		{10, 57, 346, 216, []int{6, 28, 50}},
+	}
+	for _, test := range tests {
+		if got := qrModules(test.v); got != test.modules {
+			t.Errorf("qrModules(%d) = %d, want %d", test.v, got, test.modules)
+		}
+		if got := qrCodewords(test.v); got != test.codewords {
+			t.Errorf("qrCodewords(%d) = %d, want %d", test.v, got, test.codewords)
+		}
+		if got := qrDataCodewords(test.v); got != test.data {
+			t.Errorf("qrDataCodewords(%d) = %d, want %d", test.v, got, test.data)
+		}
+		if got := qrAlignment(test.v); !reflect.DeepEqual(got, test.alignment) {
+			t.Errorf("qrAlignment(%d) = %v, want %v", test.v, got, test.alignment)
+		}
+	}
+}
+
+// readFormat reads the format information next to the top left finder
+// pattern, returning the error correction level and mask.
+func readFormat(t *testing.T, modules [][]bool) (level, mask int) {
+	var bits int
+	at := func(i, x, y int) {
+		if modules[y][x] {
+			bits |= 1 << uint(i)
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		at(i, 8, i)
+	}
+	at(6, 8, 7)
+	at(7, 8, 8)
+	at(8, 7, 8)
+	for i := 9; i < 15; i++ {
+		at(i, 14-i, 8)
+	}
+	bits ^= 0x5412
+
+	data := bits >> 10
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	if bits != data<<10|rem&0x3ff {
+		t.Errorf("Format information %#04x fails its BCH check", bits)
+	}
+	return data >> 3, data & 7
+}
+
+// readCodewords unmasks a code and reads its codewords back in the order
+// they are placed.
+func readCodewords(v int, modules [][]bool, mask int) (out []byte) {
+	q := newQR(v)
+	for y := range q.modules {
+		copy(q.modules[y], modules[y])
+	}
+	q.applyMask(mask)
+
+	out = make([]byte, qrCodewords(v))
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.function[y][x] && i < len(out)*8 {
+					if q.modules[y][x] {
+						out[i/8] |= 0x80 >> uint(i%8)
+					}
+					i++
+				}
+			}
+		}
+	}
+	return
+}
+
+// deinterleave undoes the interleaving of the data codewords.
+func deinterleave(v int, codewords []byte) (data []byte) {
+	blocks, eccLen := qrBlocks[v], qrECCLen[v]
+	total := qrCodewords(v)
+	short := blocks - total%blocks
+	shortLen := total/blocks - eccLen
+
+	dat := make([][]byte, blocks)
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j := range dat {
+			if i < shortLen || j >= short {
+				dat[j] = append(dat[j], codewords[k])
+				k++
+			}
+		}
+	}
+	for _, d := range dat {
+		data = append(data, d...)
+	}
+	return
+}
+
+func TestEncodeQR(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		version int
+	}{
+		{"empty", "", 1},
+		{"short", "HELLO WORLD", 1},
+		{"url", "https://github.com/Ratfink/gopherbone", 3},
+		{"two blocks", strings.Repeat("2", 63), 5},
+		{"uneven blocks", strings.Repeat("9", 170), 9},
+		{"largest", strings.Repeat("\xff", 213), 10},
+	}
+	for _, test := range tests {
+		modules, err := encodeQR([]byte(test.data))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(modules) != qrModules(test.version) {
+			t.Errorf("%s: %d modules across, want version %d", test.name, len(modules), test.version)
+			continue
+		}
+
+		level, mask := readFormat(t, modules)
+		if level != 0 {
+			t.Errorf("%s: Error correction level %d, want M (0)", test.name, level)
+		}
+		codewords := readCodewords(test.version, modules, mask)
+		data := deinterleave(test.version, codewords)
+		if got := qrInterleave(test.version, data); !bytes.Equal(got, codewords) {
+			t.Errorf("%s: Error correction codewords don't match the data", test.name)
+		}
+
+		// Byte mode, then the count, then the bytes
+		var bits []int
+		for _, b := range data {
+			for i := 7; i >= 0; i-- {
+				bits = append(bits, int(b>>uint(i)&1))
+			}
+		}
+		read := func(n int) (v int) {
+			for ; n > 0; n-- {
+				v = v<<1 | bits[0]
+				bits = bits[1:]
+			}
+			return
+		}
+		if mode := read(4); mode != 0x4 {
+			t.Errorf("%s: Mode %#x, want byte mode", test.name, mode)
+			continue
+		}
+		got := make([]byte, read(qrCountBits(test.version)))
+		for i := range got {
+			got[i] = byte(read(8))
+		}
+		if string(got) != test.data {
+			t.Errorf("%s: Decoded %q", test.name, got)
+		}
+	}
+}
+
+func TestEncodeQRTooLong(t *testing.T) {
+	if _, err := encodeQR(make([]byte, 214)); !errors.Is(err, ErrQRTooLong) {
+		t.Errorf("encodeQR of 214 bytes: %v, want %v", err, ErrQRTooLong)
+	}
+}
@@ -0,0 +1,172 @@
+package display_test
+
+import (
+	"errors"
+	"github.com/Ratfink/gopherbone/display"
+	"github.com/Ratfink/gopherbone/mock"
+	"github.com/Ratfink/gopherbone/ssd1306"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// newDisplay returns an SSD1306 driver talking to a simulated display.
+func newDisplay(t *testing.T) (d *ssd1306.SSD1306, sim *mock.SSD1306) {
+	sim = mock.NewSSD1306(128, 64)
+	d, err := ssd1306.NewConn(sim, nil, 128, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err = d.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestDrawQR(t *testing.T) {
+	tests := []struct {
+		data        string
+		x, y, scale int
+		modules     int
+	}{
+		{"HELLO WORLD", 0, 0, 2, 21},
+		{"https://github.com/Ratfink/gopherbone", 40, 3, 1, 29},
+		{strings.Repeat("x", 60), 90, 27, 1, 33},
+	}
+	for _, test := range tests {
+		d, sim := newDisplay(t)
+		size, err := display.QRSize(test.data, test.scale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (test.modules + 2*display.QRBorder) * test.scale; size != want {
+			t.Errorf("%q: QRSize = %d, want %d", test.data, size, want)
+		}
+		if err = display.DrawQR(d, test.data, test.x, test.y, test.scale); err != nil {
+			t.Fatal(err)
+		}
+		if err = d.Draw(); err != nil {
+			t.Fatal(err)
+		}
+		img := sim.Image()
+		code := image.Rect(test.x, test.y, test.x+size, test.y+size)
+
+		// Each module is a lit (light) or dark square of pixels
+		dark := func(mx, my int) bool {
+			x := test.x + (display.QRBorder+mx)*test.scale
+			y := test.y + (display.QRBorder+my)*test.scale
+			v := img.GrayAt(x, y).Y
+			for py := 0; py < test.scale; py++ {
+				for px := 0; px < test.scale; px++ {
+					if img.GrayAt(x+px, y+py).Y != v {
+						t.Errorf("%q: Module (%d, %d) isn't uniform", test.data, mx, my)
+					}
+				}
+			}
+			return v == 0
+		}
+		for my := -display.QRBorder; my < test.modules+display.QRBorder; my++ {
+			for mx := -display.QRBorder; mx < test.modules+display.QRBorder; mx++ {
+				quiet := mx < 0 || my < 0 || mx >= test.modules || my >= test.modules
+				if quiet && dark(mx, my) {
+					t.Errorf("%q: Quiet zone module (%d, %d) is dark", test.data, mx, my)
+				}
+			}
+		}
+
+		// Finder patterns in three corners, with light separators
+		n := test.modules
+		for _, c := range []image.Point{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+			for dy := -1; dy <= 7; dy++ {
+				for dx := -1; dx <= 7; dx++ {
+					mx, my := c.X+dx, c.Y+dy
+					if mx < 0 || my < 0 || mx >= n || my >= n {
+						continue
+					}
+					ring := max(abs(dx-3), abs(dy-3))
+					if want := ring != 2 && ring != 4; dark(mx, my) != want {
+						t.Errorf("%q: Finder module (%d, %d) dark = %v", test.data, mx, my, !want)
+					}
+				}
+			}
+		}
+		// Timing patterns between them, and the dark module
+		for i := 8; i < n-8; i++ {
+			if dark(i, 6) != (i%2 == 0) || dark(6, i) != (i%2 == 0) {
+				t.Errorf("%q: Timing pattern wrong at %d", test.data, i)
+			}
+		}
+		if !dark(8, n-8) {
+			t.Errorf("%q: Dark module is light", test.data)
+		}
+
+		// Nothing is drawn outside the code
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 128; x++ {
+				if !image.Pt(x, y).In(code) && img.GrayAt(x, y).Y != 0 {
+					t.Fatalf("%q: Pixel (%d, %d) lit outside the code", test.data, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestDrawQRTooLong(t *testing.T) {
+	d, sim := newDisplay(t)
+	err := display.DrawQR(d, strings.Repeat("x", 214), 0, 0, 1)
+	if !errors.Is(err, display.ErrQRTooLong) {
+		t.Errorf("DrawQR of 214 bytes: %v, want %v", err, display.ErrQRTooLong)
+	}
+	if err = d.Draw(); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range sim.Image().Pix {
+		if v != 0 {
+			t.Fatal("DrawQR drew a code which was too long")
+		}
+	}
+}
+
+func TestConvertTo(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetGray(x, y, color.Gray{uint8(x * 4)})
+		}
+	}
+
+	tests := []struct {
+		c    display.Converter
+		x, y int
+	}{
+		{display.Converter{}, 0, 0},
+		{display.Converter{Method: display.Ordered}, 64, 0},
+		{display.Converter{Method: display.Diffusion, Gamma: 2.2}, 10, 32},
+	}
+	for _, test := range tests {
+		d, sim := newDisplay(t)
+		test.c.ConvertTo(d, test.x, test.y, img)
+		if err := d.Draw(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := test.c.Convert(img)
+		got := sim.Image()
+		for y := 0; y < want.Height; y++ {
+			for x := 0; x < want.Width; x++ {
+				if lit := got.GrayAt(test.x+x, test.y+y).Y != 0; lit != want.At(x, y) {
+					t.Fatalf("%v: Pixel (%d, %d) lit = %v, want %v", test.c.Method, x, y, lit, want.At(x, y))
+				}
+			}
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,175 @@
+package display
+
+import (
+	"sync"
+	"time"
+)
+
+// statsWeight is how much each frame moves the averages in FrameStats, so
+// that they follow the last few dozen frames.
+const statsWeight = 0.1
+
+// FrameStats describe how a display has been drawn, to show where the time
+// goes in a user interface on a slow bus.  Times and the frame rate are
+// averages weighted towards recent frames.
+type FrameStats struct {
+	// Frames is how many frames Draw has been called for, and Sent how
+	// many were transferred.  They differ when drawing in the background
+	// skips frames to keep up, or when transfers fail.
+	Frames, Sent uint64
+	// Errors is how many transfers failed.
+	Errors uint64
+	// FPS is the rate at which frames are being transferred.
+	FPS float64
+	// Render is the time from one Draw returning to the next being called:
+	// the time spent rendering, if frames are drawn back to back.
+	Render time.Duration
+	// Transfer is the time taken to send a frame, and MaxTransfer the
+	// longest so far.
+	Transfer, MaxTransfer time.Duration
+	// Bytes is how many bytes were written for the last frame, including
+	// commands and control bytes, and TotalBytes for all of them.  They are
+	// zero for displays which can't tell.
+	Bytes      int
+	TotalBytes uint64
+}
+
+// An Instrumented display reports FrameStats.  The SSD1306 is one; Instrument
+// makes any Display into one.
+type Instrumented interface {
+	Display
+	// Stats returns the display's statistics so far.
+	Stats() FrameStats
+	// ResetStats starts the statistics again.
+	ResetStats()
+}
+
+// A FrameMeter gathers FrameStats for a display driver.  The driver calls
+// StartFrame and EndFrame around Draw, and Sent after each transfer, which
+// may be on another goroutine.  Its methods may be called on a nil
+// *FrameMeter, which does nothing.
+type FrameMeter struct {
+	lock     sync.Mutex
+	stats    FrameStats
+	drawn    time.Time
+	lastSent time.Time
+}
+
+// average moves an average towards a new value.
+func average(avg, v float64, first bool) float64 {
+	if first {
+		return v
+	}
+	return avg + statsWeight*(v-avg)
+}
+
+// StartFrame records that Draw has been called.
+func (m *FrameMeter) StartFrame() {
+	if m == nil {
+		return
+	}
+	now := time.Now()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.drawn.IsZero() {
+		m.stats.Render = time.Duration(average(float64(m.stats.Render), float64(now.Sub(m.drawn)), m.stats.Render == 0))
+	}
+	m.stats.Frames++
+}
+
+// EndFrame records that Draw has returned.
+func (m *FrameMeter) EndFrame() {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	m.drawn = time.Now()
+	m.lock.Unlock()
+}
+
+// Sent records a transfer which started at start and wrote n bytes, or
+// failed with err.
+func (m *FrameMeter) Sent(start time.Time, n int, err error) {
+	if m == nil {
+		return
+	}
+	now := time.Now()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s := &m.stats
+	s.Bytes = n
+	s.TotalBytes += uint64(n)
+	if err != nil {
+		s.Errors++
+		return
+	}
+	d := now.Sub(start)
+	s.Transfer = time.Duration(average(float64(s.Transfer), float64(d), s.Sent == 0))
+	if d > s.MaxTransfer {
+		s.MaxTransfer = d
+	}
+	if !m.lastSent.IsZero() {
+		if gap := now.Sub(m.lastSent); gap > 0 {
+			s.FPS = average(s.FPS, float64(time.Second)/float64(gap), s.FPS == 0)
+		}
+	}
+	m.lastSent = now
+	s.Sent++
+}
+
+// Stats returns the statistics so far.
+func (m *FrameMeter) Stats() (stats FrameStats) {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.stats
+}
+
+// Reset starts the statistics again.
+func (m *FrameMeter) Reset() {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.stats = FrameStats{}
+	m.drawn = time.Time{}
+	m.lastSent = time.Time{}
+}
+
+// An instrumented wraps a Display to time its Draw.
+type instrumented struct {
+	Display
+	meter FrameMeter
+}
+
+// Instrument returns d if it is already Instrumented, or else a Display
+// drawing on d which times each Draw as a transfer.  Bytes are not counted.
+func Instrument(d Display) Instrumented {
+	if i, ok := d.(Instrumented); ok {
+		return i
+	}
+	return &instrumented{Display: d}
+}
+
+// Draw draws the display, timing it.
+func (i *instrumented) Draw() (err error) {
+	i.meter.StartFrame()
+	defer i.meter.EndFrame()
+	start := time.Now()
+	err = i.Display.Draw()
+	i.meter.Sent(start, 0, err)
+	return
+}
+
+// Stats returns the statistics so far.
+func (i *instrumented) Stats() FrameStats {
+	return i.meter.Stats()
+}
+
+// ResetStats starts the statistics again.
+func (i *instrumented) ResetStats() {
+	i.meter.Reset()
+}
@@ -0,0 +1,222 @@
+package display
+
+import (
+	"fmt"
+	"image/color"
+	"sync"
+	"unicode/utf8"
+)
+
+// A Terminal is a small text console on a Display, showing as many lines of
+// Font text as fit.  Text is written at the cursor, wrapping at the right
+// edge, and the lines scroll up when the bottom is reached.  It implements
+// io.Writer, so a log.Logger or the output of a command can be sent straight
+// to the display.  Each write draws the display.
+//
+// The control characters '\n', '\r', '\b' and '\t' behave as on a terminal;
+// other control characters are ignored.
+type Terminal struct {
+	disp       Display
+	cols, rows int
+
+	lock sync.Mutex
+	// lines holds the text on the screen, padded with spaces
+	lines [][]rune
+	// the cursor's position; col may equal cols, after the last column has
+	// been written and before the line wraps
+	col, row   int
+	showCursor bool
+	inverse    bool
+	// partial holds the start of a UTF-8 sequence split between writes
+	partial []byte
+}
+
+// NewTerminal returns a Terminal covering the whole of d, which it clears.
+func NewTerminal(d Display) (t *Terminal) {
+	w, h := d.Size()
+	t = &Terminal{disp: d, cols: w / CharWidth, rows: h / CharHeight}
+	t.lines = make([][]rune, t.rows)
+	for i := range t.lines {
+		t.lines[i] = t.blank()
+	}
+
+	return
+}
+
+func (t *Terminal) blank() []rune {
+	line := make([]rune, t.cols)
+	for i := range line {
+		line[i] = ' '
+	}
+	return line
+}
+
+// Size returns the number of columns and rows of text the Terminal holds.
+func (t *Terminal) Size() (cols, rows int) {
+	return t.cols, t.rows
+}
+
+// Write writes p at the cursor and draws the display.  It always consumes all
+// of p, so n is len(p) unless drawing fails.
+func (t *Terminal) Write(p []byte) (n int, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	buf := append(t.partial, p...)
+	t.partial = nil
+	for len(buf) > 0 {
+		if !utf8.FullRune(buf) {
+			t.partial = append([]byte(nil), buf...)
+			break
+		}
+		r, size := utf8.DecodeRune(buf)
+		buf = buf[size:]
+		t.put(r)
+	}
+
+	if err = t.draw(); err == nil {
+		n = len(p)
+	}
+
+	return
+}
+
+// Print formats its arguments as fmt.Print does and writes them.
+func (t *Terminal) Print(a ...interface{}) (err error) {
+	_, err = fmt.Fprint(t, a...)
+	return
+}
+
+// Println formats its arguments as fmt.Println does and writes them.
+func (t *Terminal) Println(a ...interface{}) (err error) {
+	_, err = fmt.Fprintln(t, a...)
+	return
+}
+
+// Printf formats its arguments as fmt.Printf does and writes them.
+func (t *Terminal) Printf(format string, a ...interface{}) (err error) {
+	_, err = fmt.Fprintf(t, format, a...)
+	return
+}
+
+// put writes a rune at the cursor, with the lock held.
+func (t *Terminal) put(r rune) {
+	switch r {
+	case '\n':
+		t.newline()
+	case '\r':
+		t.col = 0
+	case '\b':
+		if t.col > 0 {
+			t.col--
+		}
+	case '\t':
+		for t.put(' '); t.col%8 != 0 && t.col < t.cols; {
+			t.put(' ')
+		}
+	default:
+		if r < ' ' || r == 0x7f {
+			return
+		}
+		if t.col >= t.cols {
+			t.newline()
+		}
+		t.lines[t.row][t.col] = r
+		t.col++
+	}
+}
+
+// newline moves the cursor to the start of the next line, scrolling if it is
+// on the last.
+func (t *Terminal) newline() {
+	t.col = 0
+	if t.row < t.rows-1 {
+		t.row++
+		return
+	}
+	copy(t.lines, t.lines[1:])
+	t.lines[t.rows-1] = t.blank()
+}
+
+// Clear clears the text and moves the cursor to the top left.
+func (t *Terminal) Clear() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i := range t.lines {
+		t.lines[i] = t.blank()
+	}
+	t.col, t.row = 0, 0
+
+	return t.draw()
+}
+
+// Cursor returns the cursor's column and row.
+func (t *Terminal) Cursor() (col, row int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.col, t.row
+}
+
+// SetCursor moves the cursor, so that the next write starts there.
+func (t *Terminal) SetCursor(col, row int) (err error) {
+	if col < 0 || col >= t.cols || row < 0 || row >= t.rows {
+		err = fmt.Errorf("Cursor position out of range: (%d, %d)", col, row)
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.col, t.row = col, row
+
+	return t.draw()
+}
+
+// ShowCursor sets whether the cursor is shown, as an underline.  It is hidden
+// by default.
+func (t *Terminal) ShowCursor(show bool) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.showCursor = show
+	return t.draw()
+}
+
+// SetInverse sets whether text is drawn black on white rather than white on
+// black.
+func (t *Terminal) SetInverse(inverse bool) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.inverse = inverse
+	return t.draw()
+}
+
+// draw renders the text and draws the display, with the lock held.
+func (t *Terminal) draw() error {
+	fg, bg := color.White, color.Black
+	if t.inverse {
+		fg, bg = bg, fg
+	}
+	t.disp.Clear(bg)
+	for row, line := range t.lines {
+		for col, r := range line {
+			if r != ' ' {
+				Char(t.disp, col*CharWidth, row*CharHeight, fg, nil, r)
+			}
+		}
+	}
+	if t.showCursor {
+		col := t.col
+		if col >= t.cols {
+			col = t.cols - 1
+		}
+		y := t.row*CharHeight + CharHeight - 1
+		for i := 0; i < CharWidth-1; i++ {
+			t.disp.Point(col*CharWidth+i, y, fg)
+		}
+	}
+
+	return t.disp.Draw()
+}
@@ -0,0 +1,151 @@
+package display
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var xbmDefine = regexp.MustCompile(`#define\s+\S*_(width|height)\s+(\d+)`)
+var xbmByte = regexp.MustCompile(`0[xX][0-9a-fA-F]{1,2}\b`)
+
+// ParseXBM reads an X BitMap, the C source format written by GIMP and
+// ImageMagick among others.  Its set bits become set pixels.
+func ParseXBM(r io.Reader) (b *Bitmap, err error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return
+	}
+	text := string(src)
+
+	var width, height int
+	for _, m := range xbmDefine.FindAllStringSubmatch(text, -1) {
+		n, _ := strconv.Atoi(m[2])
+		if m[1] == "width" {
+			width = n
+		} else {
+			height = n
+		}
+	}
+	if width <= 0 || height <= 0 {
+		err = fmt.Errorf("XBM width or height missing")
+		return
+	}
+
+	brace := strings.IndexByte(text, '{')
+	if brace < 0 {
+		err = fmt.Errorf("XBM data missing")
+		return
+	}
+	hex := xbmByte.FindAllString(text[brace:], -1)
+	stride := (width + 7) / 8
+	if len(hex) < stride*height {
+		err = fmt.Errorf("XBM data too short: %d bytes for %dx%d", len(hex), width, height)
+		return
+	}
+
+	b = NewBitmap(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v, _ := strconv.ParseUint(hex[y*stride+x/8][2:], 16, 8)
+			// XBM puts the leftmost pixel in the least significant bit
+			b.Pix[y*width+x] = v&(1<<uint(x%8)) != 0
+		}
+	}
+
+	return
+}
+
+// pbmToken returns the next whitespace separated token of a PBM header,
+// skipping comments.
+func pbmToken(br *bufio.Reader) (tok string, err error) {
+	var sb strings.Builder
+	for {
+		var c byte
+		if c, err = br.ReadByte(); err != nil {
+			if err == io.EOF && sb.Len() > 0 {
+				err = nil
+			}
+			return sb.String(), err
+		}
+		switch {
+		case c == '#' && sb.Len() == 0:
+			if _, err = br.ReadString('\n'); err != nil {
+				return
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if sb.Len() > 0 {
+				return sb.String(), nil
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// ParsePBM reads a Portable BitMap, in either its plain (P1) or raw (P4)
+// form.  PBM's black pixels are 1s, and become set pixels.
+func ParsePBM(r io.Reader) (b *Bitmap, err error) {
+	br := bufio.NewReader(r)
+
+	magic, err := pbmToken(br)
+	if err != nil {
+		return
+	}
+	if magic != "P1" && magic != "P4" {
+		err = fmt.Errorf("Not a PBM file: magic %q", magic)
+		return
+	}
+	var dims [2]int
+	for i := range dims {
+		var tok string
+		if tok, err = pbmToken(br); err != nil {
+			return
+		}
+		if dims[i], err = strconv.Atoi(tok); err != nil || dims[i] <= 0 {
+			err = fmt.Errorf("Invalid PBM dimension: %q", tok)
+			return
+		}
+	}
+	width, height := dims[0], dims[1]
+	b = NewBitmap(width, height)
+
+	if magic == "P4" {
+		// A single whitespace character ended the header, so the raster
+		// starts here
+		row := make([]byte, (width+7)/8)
+		for y := 0; y < height; y++ {
+			if _, err = io.ReadFull(br, row); err != nil {
+				return nil, err
+			}
+			for x := 0; x < width; x++ {
+				b.Pix[y*width+x] = row[x/8]&(0x80>>uint(x%8)) != 0
+			}
+		}
+		return
+	}
+
+	for i := range b.Pix {
+		var c byte
+		for {
+			if c, err = br.ReadByte(); err != nil {
+				return nil, err
+			}
+			if c == '#' {
+				if _, err = br.ReadString('\n'); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if c == '0' || c == '1' {
+				break
+			}
+		}
+		b.Pix[i] = c == '1'
+	}
+
+	return
+}
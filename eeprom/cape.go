@@ -0,0 +1,176 @@
+package eeprom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Cape EEPROMs are AT24C256s or similar on I2C bus 2, at CAPE_ADDR to
+// CAPE_ADDR+3 as set by the cape's address jumpers.
+const CAPE_ADDR = 0x54
+
+// CAPE_MAGIC starts every valid BeagleBone EEPROM.
+const CAPE_MAGIC = 0xaa5533ee
+
+// CAPE_HEADER_LEN is the length of the cape EEPROM header, after which the
+// rest of the EEPROM is free for the cape's own use.
+const CAPE_HEADER_LEN = 244
+
+// CAPE_PINS is the number of expansion header pins described by a cape
+// EEPROM.
+const CAPE_PINS = 74
+
+// Pin usage bits, from the BeagleBone System Reference Manual.
+const (
+	PIN_USED      = 0x8000
+	PIN_OUTPUT    = 0x4000
+	PIN_INPUT     = 0x2000 // both set means bidirectional
+	PIN_SLOW      = 0x0040 // slow slew rate
+	PIN_RX_ENABLE = 0x0020
+	PIN_PULLUP    = 0x0010 // pullup rather than pulldown
+	PIN_PULL_OFF  = 0x0008 // pull resistor disabled
+	PIN_MODE_MASK = 0x0007
+)
+
+// ErrBadMagic is returned when parsing data which doesn't start with
+// CAPE_MAGIC, as from a blank EEPROM.
+var ErrBadMagic = errors.New("Bad EEPROM magic number")
+
+// A Cape is the header of a cape EEPROM, which identifies the cape and says
+// how it uses the expansion headers and power rails.
+type Cape struct {
+	// Revision is the format revision; "A1" is the only one defined.
+	Revision string
+	// BoardName, Version and PartNumber are used by the cape manager to
+	// find the cape's device tree overlay, which is named
+	// PartNumber-Version.dtbo.
+	BoardName    string
+	Version      string
+	Manufacturer string
+	PartNumber   string
+	SerialNumber string
+	// Pins holds the usage of each header pin, made of the PIN_ bits, in
+	// the order of the System Reference Manual's table.  NumPins is the
+	// number of pins in use, including pins not listed in the table.
+	Pins    [CAPE_PINS]uint16
+	NumPins int
+	// Currents drawn by the cape from each rail, and supplied by it on
+	// the DC rail, in milliamps.
+	VDD3V3B  int
+	VDD5V    int
+	SYS5V    int
+	DCSupply int
+}
+
+// field returns a NUL or space padded ASCII field as a string.
+func field(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimRight(string(b), " \xff")
+}
+
+// ParseCape parses a cape EEPROM header from the first CAPE_HEADER_LEN bytes
+// of data.
+func ParseCape(data []byte) (cape *Cape, err error) {
+	if len(data) < CAPE_HEADER_LEN {
+		err = fmt.Errorf("Cape EEPROM header too short: %d", len(data))
+		return
+	}
+	if binary.BigEndian.Uint32(data) != CAPE_MAGIC {
+		err = ErrBadMagic
+		return
+	}
+
+	cape = &Cape{
+		Revision:     field(data[4:6]),
+		BoardName:    field(data[6:38]),
+		Version:      field(data[38:42]),
+		Manufacturer: field(data[42:58]),
+		PartNumber:   field(data[58:74]),
+		NumPins:      int(binary.BigEndian.Uint16(data[74:])),
+		SerialNumber: field(data[76:88]),
+		VDD3V3B:      int(binary.BigEndian.Uint16(data[236:])),
+		VDD5V:        int(binary.BigEndian.Uint16(data[238:])),
+		SYS5V:        int(binary.BigEndian.Uint16(data[240:])),
+		DCSupply:     int(binary.BigEndian.Uint16(data[242:])),
+	}
+	for i := range cape.Pins {
+		cape.Pins[i] = binary.BigEndian.Uint16(data[88+2*i:])
+	}
+
+	return
+}
+
+// putField copies s into a field, padding it with NULs.  It fails if s is
+// too long.
+func putField(b []byte, name, s string) error {
+	if len(s) > len(b) {
+		return fmt.Errorf("%s too long: %q", name, s)
+	}
+	copy(b, s)
+	return nil
+}
+
+// Marshal returns the cape's header as CAPE_HEADER_LEN bytes, ready to be
+// written to the start of the EEPROM.  A missing Revision is written as "A1".
+func (cape *Cape) Marshal() (data []byte, err error) {
+	data = make([]byte, CAPE_HEADER_LEN)
+	binary.BigEndian.PutUint32(data, CAPE_MAGIC)
+
+	rev := cape.Revision
+	if rev == "" {
+		rev = "A1"
+	}
+	for _, f := range []struct {
+		b       []byte
+		name, s string
+	}{
+		{data[4:6], "Revision", rev},
+		{data[6:38], "Board name", cape.BoardName},
+		{data[38:42], "Version", cape.Version},
+		{data[42:58], "Manufacturer", cape.Manufacturer},
+		{data[58:74], "Part number", cape.PartNumber},
+		{data[76:88], "Serial number", cape.SerialNumber},
+	} {
+		if err = putField(f.b, f.name, f.s); err != nil {
+			return nil, err
+		}
+	}
+
+	binary.BigEndian.PutUint16(data[74:], uint16(cape.NumPins))
+	for i, p := range cape.Pins {
+		binary.BigEndian.PutUint16(data[88+2*i:], p)
+	}
+	binary.BigEndian.PutUint16(data[236:], uint16(cape.VDD3V3B))
+	binary.BigEndian.PutUint16(data[238:], uint16(cape.VDD5V))
+	binary.BigEndian.PutUint16(data[240:], uint16(cape.SYS5V))
+	binary.BigEndian.PutUint16(data[242:], uint16(cape.DCSupply))
+
+	return
+}
+
+// ReadCape reads and parses the cape header at the start of an EEPROM.
+func ReadCape(e *EEPROM) (cape *Cape, err error) {
+	data := make([]byte, CAPE_HEADER_LEN)
+	if _, err = e.ReadAt(data, 0); err != nil {
+		return
+	}
+	return ParseCape(data)
+}
+
+// WriteCape writes the cape's header to the start of an EEPROM.  Most capes
+// have a jumper or test point which must be shorted to disable the EEPROM's
+// write protection first.
+func WriteCape(e *EEPROM, cape *Cape) (err error) {
+	data, err := cape.Marshal()
+	if err != nil {
+		return
+	}
+	_, err = e.WriteAt(data, 0)
+
+	return
+}
@@ -0,0 +1,261 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package eeprom drives 24Cxx serial EEPROMs, such as Atmel's AT24C series,
+// over I2C, and reads and writes the BeagleBone cape EEPROM format.
+package eeprom
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/i2c"
+	"io"
+	"sync"
+	"time"
+)
+
+// ADDR is the I2C address with the A0-A2 pins tied to ground.
+const ADDR = 0x50
+
+// WriteTimeout is how long to wait for a page write to finish before giving
+// up.  Datasheets give at most 5 or 10ms.
+var WriteTimeout = 20 * time.Millisecond
+
+// A Model describes the organisation of an EEPROM.
+type Model struct {
+	// Size is the capacity in bytes.
+	Size int
+	// PageSize is the largest write which can be made in one go.  Writes
+	// must not cross a page boundary.
+	PageSize int
+	// AddrBytes is the length of the address sent before the data, 1 or 2.
+	// Models with 1 byte addresses and more than 256 bytes take the high
+	// bits of the address in the I2C address, so they appear as several
+	// devices.
+	AddrBytes int
+}
+
+// Common models.
+var (
+	AT24C01  = Model{Size: 128, PageSize: 8, AddrBytes: 1}
+	AT24C02  = Model{Size: 256, PageSize: 8, AddrBytes: 1}
+	AT24C04  = Model{Size: 512, PageSize: 16, AddrBytes: 1}
+	AT24C08  = Model{Size: 1024, PageSize: 16, AddrBytes: 1}
+	AT24C16  = Model{Size: 2048, PageSize: 16, AddrBytes: 1}
+	AT24C32  = Model{Size: 4096, PageSize: 32, AddrBytes: 2}
+	AT24C64  = Model{Size: 8192, PageSize: 32, AddrBytes: 2}
+	AT24C128 = Model{Size: 16384, PageSize: 64, AddrBytes: 2}
+	AT24C256 = Model{Size: 32768, PageSize: 64, AddrBytes: 2}
+	AT24C512 = Model{Size: 65536, PageSize: 128, AddrBytes: 2}
+)
+
+// conn is what an EEPROM needs of a connection with 2 byte addresses.
+type conn interface {
+	i2c.Conn
+	i2c.RawWriter
+	i2c.Transactor
+}
+
+// An EEPROM is a 24Cxx EEPROM on an I2C bus.  It implements io.ReaderAt and
+// io.WriterAt.
+type EEPROM struct {
+	model Model
+	// one connection per 256 byte block for models with 1 byte addresses,
+	// or just one otherwise
+	conns []i2c.Conn
+	lock  sync.Mutex
+	// set if the connections were opened by New
+	owned bool
+}
+
+var _ io.ReaderAt = (*EEPROM)(nil)
+var _ io.WriterAt = (*EEPROM)(nil)
+
+// New returns the EEPROM of the given model at addr on the given bus.
+func New(addr, bus byte, model Model) (e *EEPROM, err error) {
+	dev, err := i2c.NewDevice(addr, bus)
+	if err != nil {
+		return
+	}
+
+	conns := []i2c.Conn{dev}
+	if model.AddrBytes == 1 {
+		for block := 1; block*256 < model.Size; block++ {
+			conns = append(conns, dev.Bus().Device(addr+byte(block)))
+		}
+	}
+	if e, err = NewConn(model, conns...); err != nil {
+		dev.Close()
+		return
+	}
+	e.owned = true
+
+	return
+}
+
+// NewConn is like New, but talks to the chip through conns, which may be
+// fakes for testing.  Models with 1 byte addresses need one connection for
+// each 256 byte block, at consecutive addresses.  Models with 2 byte
+// addresses need a single connection, which must also implement
+// i2c.RawWriter and i2c.Transactor, as i2c.Device does.
+func NewConn(model Model, conns ...i2c.Conn) (e *EEPROM, err error) {
+	switch {
+	case model.Size <= 0 || model.PageSize <= 0 || model.Size%model.PageSize != 0:
+		err = fmt.Errorf("Invalid model: %+v", model)
+	case model.AddrBytes == 1 && model.PageSize > i2c.I2C_SMBUS_BLOCK_MAX:
+		err = fmt.Errorf("Invalid model: %+v", model)
+	case model.AddrBytes == 1 && len(conns) != (model.Size+255)/256:
+		err = fmt.Errorf("Need %d connections for %d bytes, got %d", (model.Size+255)/256, model.Size, len(conns))
+	case model.AddrBytes == 2 && len(conns) != 1:
+		err = fmt.Errorf("Need 1 connection, got %d", len(conns))
+	case model.AddrBytes != 1 && model.AddrBytes != 2:
+		err = fmt.Errorf("Invalid model: %+v", model)
+	}
+	if err != nil {
+		return
+	}
+	if model.AddrBytes == 2 {
+		if _, ok := conns[0].(conn); !ok {
+			err = fmt.Errorf("Connection can't send 2 byte addresses")
+			return
+		}
+	}
+	e = &EEPROM{model: model, conns: conns}
+
+	return
+}
+
+// Size returns the capacity in bytes.
+func (e *EEPROM) Size() int {
+	return e.model.Size
+}
+
+// ReadAt reads len(p) bytes starting at off.  Reading past the end returns
+// io.EOF with as many bytes as there were.
+func (e *EEPROM) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		err = fmt.Errorf("Invalid offset: %d", off)
+		return
+	}
+	if off >= int64(e.model.Size) {
+		err = io.EOF
+		return
+	}
+	want := len(p)
+	if rest := int64(e.model.Size) - off; int64(want) > rest {
+		want = int(rest)
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for n < want {
+		addr := int(off) + n
+		var chunk int
+		if e.model.AddrBytes == 1 {
+			// A block read can't cross into the next device
+			chunk = min(want-n, i2c.I2C_SMBUS_BLOCK_MAX, 256-addr%256)
+			var list []byte
+			if list, err = e.conns[addr/256].Read(byte(addr), byte(chunk)); err != nil {
+				return
+			}
+			copy(p[n:], list)
+		} else {
+			chunk = min(want-n, i2c.I2C_RDWR_MAX)
+			if err = e.conns[0].(conn).Tx([]byte{byte(addr >> 8), byte(addr)}, p[n:n+chunk]); err != nil {
+				return
+			}
+		}
+		n += chunk
+	}
+	if n < len(p) {
+		err = io.EOF
+	}
+
+	return
+}
+
+// WriteAt writes p starting at off, a page at a time, waiting for each page
+// to be written before sending the next.  Writing past the end writes nothing
+// and fails.
+func (e *EEPROM) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 || off+int64(len(p)) > int64(e.model.Size) {
+		err = fmt.Errorf("Write of %d bytes at %d past end of %d byte EEPROM", len(p), off, e.model.Size)
+		return
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for n < len(p) {
+		addr := int(off) + n
+		chunk := min(len(p)-n, e.model.PageSize-addr%e.model.PageSize)
+		c := e.conns[0]
+		if e.model.AddrBytes == 1 {
+			c = e.conns[addr/256]
+			err = c.Write(byte(addr), p[n:n+chunk])
+		} else {
+			msg := append([]byte{byte(addr >> 8), byte(addr)}, p[n:n+chunk]...)
+			err = c.(conn).WriteRaw(msg)
+		}
+		if err != nil {
+			return
+		}
+		if err = e.wait(c); err != nil {
+			return
+		}
+		n += chunk
+	}
+
+	return
+}
+
+// wait polls the chip until it acknowledges again, which it doesn't while
+// writing a page.  The poll is a read from the current address, which
+// changes nothing.
+func (e *EEPROM) wait(c i2c.Conn) (err error) {
+	deadline := time.Now().Add(WriteTimeout)
+	for {
+		if _, err = c.ReadByte(); !errors.Is(err, i2c.ErrNAK) {
+			return
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Write not finished after %s: %w", WriteTimeout, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Close closes the connection if it was opened by New.
+func (e *EEPROM) Close() (err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.owned {
+		e.owned = false
+		if c, ok := e.conns[0].(io.Closer); ok {
+			err = c.Close()
+		}
+	}
+
+	return
+}
@@ -0,0 +1,305 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package encoder reads rotary encoders, either by decoding the quadrature
+// signals on two GPIO inputs or by using one of the AM335x's eQEP modules.
+package encoder
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/button"
+	"github.com/Ratfink/gopherbone/gpio"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// transitions maps a change in the two-bit quadrature state, indexed by
+// previous<<2 | current, to a step of -1, 0 or +1.  Invalid transitions,
+// where both inputs changed at once, count as no movement.
+var transitions = [16]int{0, -1, 1, 0, 1, 0, 0, -1, -1, 0, 0, 1, 0, 1, -1, 0}
+
+// An Encoder sends position changes on its channel C.  Positive values are
+// clockwise rotation if channel A leads channel B.  If the consumer falls
+// behind, changes are summed rather than dropped, so no movement is lost.
+type Encoder struct {
+	C <-chan int
+
+	// Button is the encoder's push button, or nil if it has none.
+	Button *button.Button
+
+	c    chan int
+	a, b *gpio.GPIO
+	wa   *gpio.Watcher
+	wb   *gpio.Watcher
+	stop chan struct{}
+	done chan struct{}
+
+	stepsPerDetent int
+	count          int
+	pending        int
+}
+
+// New starts decoding an encoder on the GPIO pins pinA and pinB.  If the
+// encoder has a push button, buttonPin is its GPIO pin (an active low button
+// is assumed); otherwise it should be -1.  Most encoders produce four
+// quadrature steps per detent, so stepsPerDetent is usually 4.
+func New(pinA, pinB, buttonPin, stepsPerDetent int) (enc *Encoder, err error) {
+	if stepsPerDetent < 1 {
+		err = fmt.Errorf("Invalid steps per detent: %d", stepsPerDetent)
+		return
+	}
+	enc = &Encoder{
+		c:              make(chan int),
+		done:           make(chan struct{}),
+		stepsPerDetent: stepsPerDetent,
+	}
+	enc.C = enc.c
+
+	if enc.a, err = input(pinA); err != nil {
+		return nil, err
+	}
+	if enc.b, err = input(pinB); err != nil {
+		return nil, err
+	}
+	if enc.wa, err = enc.a.Watch(gpio.Both); err != nil {
+		return nil, err
+	}
+	if enc.wb, err = enc.b.Watch(gpio.Both); err != nil {
+		enc.wa.Close()
+		return nil, err
+	}
+	if buttonPin >= 0 {
+		if enc.Button, err = button.New(buttonPin, true); err != nil {
+			enc.wa.Close()
+			enc.wb.Close()
+			return nil, err
+		}
+	}
+
+	go enc.runGPIO()
+
+	return
+}
+
+func input(pin int) (g *gpio.GPIO, err error) {
+	g, err = gpio.Export(pin)
+	if err != nil {
+		return
+	}
+	err = g.SetDirection(gpio.In)
+
+	return
+}
+
+// step adds a number of quadrature steps to the encoder's position, moving
+// whole detents into the pending change.
+func (enc *Encoder) step(n int) {
+	enc.count += n
+	for enc.count >= enc.stepsPerDetent {
+		enc.count -= enc.stepsPerDetent
+		enc.pending++
+	}
+	for enc.count <= -enc.stepsPerDetent {
+		enc.count += enc.stepsPerDetent
+		enc.pending--
+	}
+}
+
+// out returns the channel to send pending changes on, or nil if there are
+// none, so that it may be used in a select.
+func (enc *Encoder) out() chan int {
+	if enc.pending == 0 {
+		return nil
+	}
+	return enc.c
+}
+
+func (enc *Encoder) runGPIO() {
+	defer close(enc.done)
+	defer close(enc.c)
+
+	a, err := enc.a.Value()
+	if err != nil {
+		return
+	}
+	b, err := enc.b.Value()
+	if err != nil {
+		return
+	}
+	state := a<<1 | b
+
+	for {
+		select {
+		case v, ok := <-enc.wa.C:
+			if !ok {
+				return
+			}
+			a = v
+		case v, ok := <-enc.wb.C:
+			if !ok {
+				return
+			}
+			b = v
+		case enc.out() <- enc.pending:
+			enc.pending = 0
+			continue
+		}
+
+		next := a<<1 | b
+		enc.step(transitions[state<<2|next])
+		state = next
+	}
+}
+
+// eqepPath finds the sysfs directory of the numbered eQEP module.
+func eqepPath(n int) (path string, err error) {
+	if n < 0 || n > 2 {
+		err = fmt.Errorf("Invalid eQEP module: %d", n)
+		return
+	}
+	addr := 0x48300180 + 0x2000*n
+	for _, pattern := range []string{
+		"/sys/devices/platform/ocp/*.epwmss/%08x.eqep",
+		"/sys/devices/ocp.*/*.epwmss/%08x.eqep",
+	} {
+		var matches []string
+		matches, err = filepath.Glob(fmt.Sprintf(pattern, addr))
+		if err != nil {
+			return
+		}
+		if len(matches) > 0 {
+			path = matches[0]
+			return
+		}
+	}
+
+	err = fmt.Errorf("eQEP%d not found; is its overlay loaded?", n)
+	return
+}
+
+func writeAttr(path, attr string, value int) (err error) {
+	f, err := os.OpenFile(filepath.Join(path, attr), os.O_WRONLY, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%d", value)
+
+	return
+}
+
+func readAttr(path, attr string) (value int, err error) {
+	f, err := os.Open(filepath.Join(path, attr))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	n, err := fmt.Fscanf(f, "%d", &value)
+	if n != 1 {
+		err = fmt.Errorf("Bad number of values read from %s: %d", filepath.Join(path, attr), n)
+	}
+
+	return
+}
+
+// NewEQEP starts reading an encoder connected to the numbered eQEP module
+// (0, 1 or 2), whose pins must already be muxed for eQEP.  The hardware counts
+// every quadrature step, so none are lost however fast the encoder turns; its
+// position is sampled every interval.
+func NewEQEP(n int, interval time.Duration, stepsPerDetent int) (enc *Encoder, err error) {
+	if stepsPerDetent < 1 {
+		err = fmt.Errorf("Invalid steps per detent: %d", stepsPerDetent)
+		return
+	}
+	path, err := eqepPath(n)
+	if err != nil {
+		return
+	}
+	// Absolute mode, so that position keeps counting between samples
+	if err = writeAttr(path, "mode", 0); err != nil {
+		return
+	}
+	if err = writeAttr(path, "enabled", 1); err != nil {
+		return
+	}
+	position, err := readAttr(path, "position")
+	if err != nil {
+		return
+	}
+
+	enc = &Encoder{
+		c:              make(chan int),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+		stepsPerDetent: stepsPerDetent,
+	}
+	enc.C = enc.c
+
+	go enc.runEQEP(path, position, interval)
+
+	return
+}
+
+func (enc *Encoder) runEQEP(path string, last int, interval time.Duration) {
+	defer close(enc.done)
+	defer close(enc.c)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			position, err := readAttr(path, "position")
+			if err != nil {
+				return
+			}
+			enc.step(position - last)
+			last = position
+		case enc.out() <- enc.pending:
+			enc.pending = 0
+		case <-enc.stop:
+			return
+		}
+	}
+}
+
+// Close stops reading the encoder and closes its channel.  The encoder's
+// button, if any, is closed too.
+func (enc *Encoder) Close() (err error) {
+	if enc.stop != nil {
+		close(enc.stop)
+	} else {
+		enc.wa.Close()
+		enc.wb.Close()
+	}
+	<-enc.done
+
+	if enc.Button != nil {
+		err = enc.Button.Close()
+	}
+
+	return
+}
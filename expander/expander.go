@@ -0,0 +1,368 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package expander drives I2C GPIO expanders, presenting each of their pins as
+// a gpio.DigitalPin so that they can be used anywhere a GPIO can.  Edge
+// watching uses the expander's interrupt output, wired to a GPIO, to find out
+// when to read the expander; without one, the expander is polled.
+package expander
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"sync"
+	"time"
+)
+
+// PollInterval is how often an expander without an interrupt pin is read
+// while any of its pins are being watched.
+var PollInterval = 10 * time.Millisecond
+
+// A chip is the hardware-specific part of an expander.
+type chip interface {
+	// read returns the levels of all the pins.
+	read() (uint16, error)
+	direction(n int) (gpio.Direction, error)
+	setDirection(n int, dir gpio.Direction) error
+	setValue(n, value int) error
+	// watch enables change interrupts for a pin, for chips which need it.
+	watch(n int) error
+}
+
+// An expander holds the state shared by all types of expander: its pins and
+// the goroutine which delivers changes to them.
+type expander struct {
+	chip   chip
+	width  int
+	intPin int
+	pins   []*Pin
+
+	lock    sync.Mutex
+	watches map[*pinWatch]bool
+	last    uint16
+	running bool
+	intr    *gpio.GPIO
+	intrW   *gpio.Watcher
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type pinWatch struct {
+	n   int
+	raw chan int
+}
+
+func newExpander(c chip, width, intPin int) (e *expander) {
+	e = &expander{
+		chip:    c,
+		width:   width,
+		intPin:  intPin,
+		watches: make(map[*pinWatch]bool),
+	}
+	for n := 0; n < width; n++ {
+		e.pins = append(e.pins, &Pin{e: e, n: n})
+	}
+
+	return
+}
+
+// Pin returns the numbered pin of the expander.
+func (e *expander) Pin(n int) (pin *Pin, err error) {
+	if n < 0 || n >= e.width {
+		err = fmt.Errorf("Invalid pin: %d", n)
+		return
+	}
+	pin = e.pins[n]
+
+	return
+}
+
+// start starts delivering changes to watchers, if it isn't already.  It must
+// be called with the lock held.
+func (e *expander) start() (err error) {
+	if e.running {
+		return
+	}
+
+	if e.intPin >= 0 {
+		if e.intr, err = gpio.Export(e.intPin); err != nil {
+			return
+		}
+		if err = e.intr.SetDirection(gpio.In); err != nil {
+			return
+		}
+		if e.intrW, err = e.intr.Watch(gpio.Falling); err != nil {
+			return
+		}
+	}
+	if e.last, err = e.chip.read(); err != nil {
+		return
+	}
+
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	e.running = true
+	go e.run()
+
+	return
+}
+
+func (e *expander) run() {
+	defer close(e.done)
+
+	var tick <-chan time.Time
+	var intr <-chan int
+	if e.intrW != nil {
+		intr = e.intrW.C
+	} else {
+		ticker := time.NewTicker(PollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-intr:
+		case <-e.stop:
+			return
+		}
+
+		levels, err := e.chip.read()
+		if err != nil {
+			continue
+		}
+
+		e.lock.Lock()
+		changed := levels ^ e.last
+		e.last = levels
+		for w := range e.watches {
+			if changed&(1<<uint(w.n)) == 0 {
+				continue
+			}
+			// Watchers only use the level as a hint, so if one has
+			// fallen behind it can safely miss this one
+			select {
+			case w.raw <- int(levels>>uint(w.n)) & 1:
+			default:
+			}
+		}
+		e.lock.Unlock()
+	}
+}
+
+func (e *expander) addWatch(w *pinWatch) (err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if err = e.chip.watch(w.n); err != nil {
+		return
+	}
+	if err = e.start(); err != nil {
+		return
+	}
+	e.watches[w] = true
+
+	return
+}
+
+func (e *expander) removeWatch(w *pinWatch) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.watches, w)
+}
+
+// close stops delivering changes and releases the interrupt pin.
+func (e *expander) close() (err error) {
+	e.lock.Lock()
+	running := e.running
+	e.running = false
+	e.lock.Unlock()
+	if !running {
+		return
+	}
+
+	close(e.stop)
+	<-e.done
+	if e.intrW != nil {
+		e.intrW.Close()
+		err = e.intr.Unexport()
+	}
+
+	return
+}
+
+// A Pin is a single pin of an expander.  It implements gpio.DigitalPin.
+type Pin struct {
+	e *expander
+	n int
+
+	lock      sync.Mutex
+	activeLow bool
+	debounce  time.Duration
+}
+
+var _ gpio.DigitalPin = (*Pin)(nil)
+
+func (pin *Pin) invert() int {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	if pin.activeLow {
+		return 1
+	}
+	return 0
+}
+
+// Value returns the pin's current value.
+func (pin *Pin) Value() (value int, err error) {
+	levels, err := pin.e.chip.read()
+	if err != nil {
+		return
+	}
+	value = int(levels>>uint(pin.n))&1 ^ pin.invert()
+
+	return
+}
+
+// SetValue sets the value of an output pin.
+func (pin *Pin) SetValue(value int) (err error) {
+	if value != 0 && value != 1 {
+		err = fmt.Errorf("Invalid value: %d", value)
+		return
+	}
+	return pin.e.chip.setValue(pin.n, value^pin.invert())
+}
+
+// Direction returns the pin's direction.
+func (pin *Pin) Direction() (dir gpio.Direction, err error) {
+	return pin.e.chip.direction(pin.n)
+}
+
+// SetDirection sets the pin's direction.
+func (pin *Pin) SetDirection(dir gpio.Direction) (err error) {
+	if dir != gpio.In && dir != gpio.Out {
+		err = fmt.Errorf("Invalid direction: %s", dir)
+		return
+	}
+	return pin.e.chip.setDirection(pin.n, dir)
+}
+
+// SetActiveLow sets whether the pin's value is inverted.  Expanders can't
+// invert in hardware, so this is done in software.
+func (pin *Pin) SetActiveLow(activeLow bool) (err error) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	pin.activeLow = activeLow
+
+	return
+}
+
+// Debounce sets the period for which the pin's value must be stable before a
+// Watcher reports it.
+func (pin *Pin) Debounce(d time.Duration) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	pin.debounce = d
+}
+
+// Watch starts a Watcher which sends the pin's value each time the given edge
+// occurs.
+func (pin *Pin) Watch(edge gpio.Edge) (w *gpio.Watcher, err error) {
+	if edge != gpio.Rising && edge != gpio.Falling && edge != gpio.Both {
+		err = fmt.Errorf("Invalid edge: %s", edge)
+		return
+	}
+	last, err := pin.Value()
+	if err != nil {
+		return
+	}
+
+	pw := &pinWatch{n: pin.n, raw: make(chan int, 16)}
+	if err = pin.e.addWatch(pw); err != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	w, values := gpio.NewWatcher(func() { close(stop) })
+	go pin.watch(pw, values, stop, edge, last)
+
+	return
+}
+
+func (pin *Pin) watch(pw *pinWatch, values chan<- int, stop chan struct{}, edge gpio.Edge, last int) {
+	defer close(values)
+	defer pin.e.removeWatch(pw)
+
+	pin.lock.Lock()
+	debounce := pin.debounce
+	pin.lock.Unlock()
+
+	for {
+		var value int
+		select {
+		case value = <-pw.raw:
+			value ^= pin.invert()
+		case <-stop:
+			return
+		}
+
+		if debounce > 0 {
+			timer := time.NewTimer(debounce)
+		settle:
+			for {
+				select {
+				case <-pw.raw:
+					timer.Reset(debounce)
+				case <-timer.C:
+					break settle
+				case <-stop:
+					timer.Stop()
+					return
+				}
+			}
+			var err error
+			if value, err = pin.Value(); err != nil {
+				continue
+			}
+		}
+
+		if value == last {
+			continue
+		}
+		last = value
+		if (edge == gpio.Rising && value != 1) || (edge == gpio.Falling && value != 0) {
+			continue
+		}
+
+		select {
+		case values <- value:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Unexport returns the pin to being an input.  Expander pins don't need
+// exporting, so this is all there is to do.
+func (pin *Pin) Unexport() (err error) {
+	return pin.e.chip.setDirection(pin.n, gpio.In)
+}
@@ -0,0 +1,140 @@
+package expander
+
+import (
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/i2c"
+	"sync"
+)
+
+// MCP23017 registers, with IOCON.BANK = 0 so that each A register is followed
+// by its B register.  Pins 0-7 are port A and pins 8-15 are port B.
+const (
+	MCP23017_IODIR   = 0x00
+	MCP23017_GPINTEN = 0x04
+	MCP23017_INTCON  = 0x08
+	MCP23017_IOCON   = 0x0a
+	MCP23017_GPPU    = 0x0c
+	MCP23017_GPIO    = 0x12
+	MCP23017_OLAT    = 0x14
+)
+
+// MCP23017_IOCON_MIRROR makes both interrupt outputs respond to either port,
+// so only one needs to be wired up.
+const MCP23017_IOCON_MIRROR = 0x40
+
+// An MCP23017 is a 16-bit I/O expander.
+type MCP23017 struct {
+	*expander
+	dev i2c.Conn
+
+	lock    sync.Mutex
+	iodir   uint16
+	olat    uint16
+	gpinten uint16
+	gppu    uint16
+}
+
+// NewMCP23017 returns the MCP23017 at addr on the given bus, with all pins set
+// as inputs.  intPin is the GPIO connected to either of the expander's INT
+// outputs, or -1 if neither is connected.
+func NewMCP23017(addr, bus byte, intPin int) (mcp *MCP23017, err error) {
+	dev, err := i2c.NewDevice(addr, bus)
+	if err != nil {
+		return
+	}
+
+	return NewMCP23017Conn(dev, intPin)
+}
+
+// NewMCP23017Conn is like NewMCP23017, but talks to the chip through conn, which may be a fake
+// for testing.
+func NewMCP23017Conn(conn i2c.Conn, intPin int) (mcp *MCP23017, err error) {
+	mcp = &MCP23017{iodir: 0xffff, dev: conn}
+	if err = mcp.dev.WriteReg(MCP23017_IOCON, MCP23017_IOCON_MIRROR); err != nil {
+		return nil, err
+	}
+	// Interrupt on any change, rather than on differing from DEFVAL
+	for _, reg := range []byte{MCP23017_INTCON, MCP23017_GPINTEN} {
+		if err = mcp.write16(reg, 0); err != nil {
+			return nil, err
+		}
+	}
+	if err = mcp.write16(MCP23017_IODIR, mcp.iodir); err != nil {
+		return nil, err
+	}
+	mcp.expander = newExpander(mcp, 16, intPin)
+
+	return
+}
+
+// Close stops watching the expander's pins.
+func (mcp *MCP23017) Close() error {
+	return mcp.expander.close()
+}
+
+// SetPullUp enables or disables the internal 100k pull-up resistor on a pin.
+func (mcp *MCP23017) SetPullUp(n int, enabled bool) (err error) {
+	if _, err = mcp.Pin(n); err != nil {
+		return
+	}
+	mcp.lock.Lock()
+	defer mcp.lock.Unlock()
+
+	mcp.gppu = setBit(mcp.gppu, n, enabled)
+	return mcp.write16(MCP23017_GPPU, mcp.gppu)
+}
+
+func setBit(v uint16, n int, set bool) uint16 {
+	if set {
+		return v | 1<<uint(n)
+	}
+	return v &^ (1 << uint(n))
+}
+
+func (mcp *MCP23017) write16(reg byte, v uint16) error {
+	return mcp.dev.Write(reg, []byte{byte(v), byte(v >> 8)})
+}
+
+func (mcp *MCP23017) read() (levels uint16, err error) {
+	data, err := mcp.dev.Read(MCP23017_GPIO, 2)
+	if err != nil {
+		return
+	}
+	levels = uint16(data[0]) | uint16(data[1])<<8
+
+	return
+}
+
+func (mcp *MCP23017) direction(n int) (dir gpio.Direction, err error) {
+	mcp.lock.Lock()
+	defer mcp.lock.Unlock()
+
+	if mcp.iodir&(1<<uint(n)) != 0 {
+		return gpio.In, nil
+	}
+	return gpio.Out, nil
+}
+
+func (mcp *MCP23017) setDirection(n int, dir gpio.Direction) error {
+	mcp.lock.Lock()
+	defer mcp.lock.Unlock()
+
+	mcp.iodir = setBit(mcp.iodir, n, dir == gpio.In)
+	return mcp.write16(MCP23017_IODIR, mcp.iodir)
+}
+
+func (mcp *MCP23017) setValue(n, value int) error {
+	mcp.lock.Lock()
+	defer mcp.lock.Unlock()
+
+	mcp.olat = setBit(mcp.olat, n, value != 0)
+	return mcp.write16(MCP23017_OLAT, mcp.olat)
+}
+
+func (mcp *MCP23017) watch(n int) error {
+	mcp.lock.Lock()
+	defer mcp.lock.Unlock()
+
+	mcp.gpinten = setBit(mcp.gpinten, n, true)
+	return mcp.write16(MCP23017_GPINTEN, mcp.gpinten)
+}
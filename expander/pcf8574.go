@@ -0,0 +1,101 @@
+package expander
+
+import (
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/i2c"
+	"sync"
+)
+
+// A PCF8574 is an 8-bit quasi-bidirectional I/O expander.  Its pins have no
+// real direction: an output driven high is a weak pull-up which can also be
+// read as an input.  Inputs are therefore implemented by driving the pin
+// high.
+type PCF8574 struct {
+	*expander
+	dev i2c.Conn
+
+	lock    sync.Mutex
+	outputs byte
+	values  byte
+}
+
+// NewPCF8574 returns the PCF8574 at addr on the given bus, with all pins set
+// as inputs.  intPin is the GPIO connected to the expander's INT output, or
+// -1 if it isn't connected.
+func NewPCF8574(addr, bus byte, intPin int) (pcf *PCF8574, err error) {
+	dev, err := i2c.NewDevice(addr, bus)
+	if err != nil {
+		return
+	}
+
+	return NewPCF8574Conn(dev, intPin)
+}
+
+// NewPCF8574Conn is like NewPCF8574, but talks to the chip through conn, which may be a fake
+// for testing.
+func NewPCF8574Conn(conn i2c.Conn, intPin int) (pcf *PCF8574, err error) {
+	pcf = &PCF8574{dev: conn}
+	if err = pcf.dev.WriteByte(0xff); err != nil {
+		return nil, err
+	}
+	pcf.expander = newExpander(pcf, 8, intPin)
+
+	return
+}
+
+// Close stops watching the expander's pins.
+func (pcf *PCF8574) Close() error {
+	return pcf.expander.close()
+}
+
+func (pcf *PCF8574) read() (levels uint16, err error) {
+	b, err := pcf.dev.ReadByte()
+	levels = uint16(b)
+
+	return
+}
+
+// latch writes the port, with inputs and high outputs set to 1.  It must be
+// called with the lock held.
+func (pcf *PCF8574) latch() error {
+	return pcf.dev.WriteByte(^pcf.outputs | pcf.values)
+}
+
+func (pcf *PCF8574) direction(n int) (dir gpio.Direction, err error) {
+	pcf.lock.Lock()
+	defer pcf.lock.Unlock()
+
+	if pcf.outputs&(1<<uint(n)) != 0 {
+		return gpio.Out, nil
+	}
+	return gpio.In, nil
+}
+
+func (pcf *PCF8574) setDirection(n int, dir gpio.Direction) error {
+	pcf.lock.Lock()
+	defer pcf.lock.Unlock()
+
+	if dir == gpio.Out {
+		pcf.outputs |= 1 << uint(n)
+	} else {
+		pcf.outputs &^= 1 << uint(n)
+	}
+	return pcf.latch()
+}
+
+func (pcf *PCF8574) setValue(n, value int) error {
+	pcf.lock.Lock()
+	defer pcf.lock.Unlock()
+
+	if value != 0 {
+		pcf.values |= 1 << uint(n)
+	} else {
+		pcf.values &^= 1 << uint(n)
+	}
+	return pcf.latch()
+}
+
+// The PCF8574 interrupts on any input change, so there's nothing to enable.
+func (pcf *PCF8574) watch(n int) error {
+	return nil
+}
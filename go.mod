@@ -0,0 +1,3 @@
+module github.com/Ratfink/gopherbone
+
+go 1.21
@@ -0,0 +1,152 @@
+package gpio
+
+import (
+	"fmt"
+)
+
+// A BitOrder says which pin of a Bus carries which bit of a value.
+type BitOrder int
+
+// Bit orders.  With LSBFirst, the first pin of a Bus carries bit 0; with
+// MSBFirst, it carries the most significant bit of the value being written or
+// read.
+const (
+	LSBFirst BitOrder = iota
+	MSBFirst
+)
+
+// A Bus groups several pins so that values can be written to and read from
+// them in parallel, as for the data lines of an HD44780 display or a bank of
+// DIP switches.  Pins are set one after another, so a Bus is not suitable for
+// signals which must all change at exactly the same time.
+type Bus struct {
+	pins  []DigitalPin
+	order BitOrder
+	// last holds the value last written to each pin, or -1 if unknown, so
+	// that pins which aren't changing needn't be written
+	last []int
+}
+
+// NewBus groups the given pins into a Bus with the given bit order.
+func NewBus(order BitOrder, pins ...DigitalPin) *Bus {
+	bus := &Bus{pins: pins, order: order, last: make([]int, len(pins))}
+	for i := range bus.last {
+		bus.last[i] = -1
+	}
+
+	return bus
+}
+
+// ExportBus exports each of the given GPIO pins and groups them into a Bus.
+func ExportBus(order BitOrder, pins ...int) (bus *Bus, err error) {
+	var gpios []DigitalPin
+	for _, pin := range pins {
+		var g *GPIO
+		if g, err = Export(pin); err != nil {
+			return
+		}
+		gpios = append(gpios, g)
+	}
+	bus = NewBus(order, gpios...)
+
+	return
+}
+
+// Width returns the number of pins in the bus.
+func (bus *Bus) Width() int {
+	return len(bus.pins)
+}
+
+// SetDirection sets the direction of every pin.
+func (bus *Bus) SetDirection(dir Direction) (err error) {
+	for i, pin := range bus.pins {
+		if err = pin.SetDirection(dir); err != nil {
+			return
+		}
+		bus.last[i] = -1
+	}
+
+	return
+}
+
+// bit returns which bit of an n-bit value pin i carries.
+func (bus *Bus) bit(i, n int) uint {
+	if bus.order == MSBFirst {
+		return uint(n - 1 - i)
+	}
+	return uint(i)
+}
+
+// WriteN writes the low n bits of value to the first n pins.
+func (bus *Bus) WriteN(value uint64, n int) (err error) {
+	if n < 0 || n > len(bus.pins) {
+		err = fmt.Errorf("Invalid width: %d", n)
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		v := int(value>>bus.bit(i, n)) & 1
+		if v == bus.last[i] {
+			continue
+		}
+		if err = bus.pins[i].SetValue(v); err != nil {
+			bus.last[i] = -1
+			return
+		}
+		bus.last[i] = v
+	}
+
+	return
+}
+
+// Write writes value to all of the pins.
+func (bus *Bus) Write(value uint64) error {
+	return bus.WriteN(value, len(bus.pins))
+}
+
+// WriteByte writes a byte to the first 8 pins.
+func (bus *Bus) WriteByte(b byte) error {
+	return bus.WriteN(uint64(b), 8)
+}
+
+// ReadN reads a value from the first n pins.
+func (bus *Bus) ReadN(n int) (value uint64, err error) {
+	if n < 0 || n > len(bus.pins) {
+		err = fmt.Errorf("Invalid width: %d", n)
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		var v int
+		if v, err = bus.pins[i].Value(); err != nil {
+			return
+		}
+		value |= uint64(v) << bus.bit(i, n)
+	}
+
+	return
+}
+
+// Read reads a value from all of the pins.
+func (bus *Bus) Read() (uint64, error) {
+	return bus.ReadN(len(bus.pins))
+}
+
+// ReadByte reads a byte from the first 8 pins.
+func (bus *Bus) ReadByte() (b byte, err error) {
+	value, err := bus.ReadN(8)
+	b = byte(value)
+
+	return
+}
+
+// Unexport unexports every pin.
+func (bus *Bus) Unexport() (err error) {
+	for _, pin := range bus.pins {
+		if e := pin.Unexport(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
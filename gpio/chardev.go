@@ -0,0 +1,157 @@
+package gpio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/metrics"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// as defined in /usr/include/linux/gpio.h
+const (
+	GPIOHANDLE_REQUEST_INPUT       = 1 << 0
+	GPIOEVENT_REQUEST_RISING_EDGE  = 1 << 0
+	GPIOEVENT_REQUEST_FALLING_EDGE = 1 << 1
+	GPIOEVENT_EVENT_RISING_EDGE    = 0x01
+	GPIOEVENT_EVENT_FALLING_EDGE   = 0x02
+	GPIO_GET_LINEEVENT_IOCTL       = 0xc030b404
+)
+
+// as defined in /usr/include/linux/gpio.h
+type gpioevent_request struct {
+	lineoffset    uint32
+	handleflags   uint32
+	eventflags    uint32
+	consumerLabel [32]byte
+	fd            int32
+}
+
+// gpioeventDataSize is the size of struct gpioevent_data: a 64-bit timestamp
+// and a 32-bit event ID, padded to 64 bits.
+const gpioeventDataSize = 16
+
+// findChip finds the GPIO character device and line offset of a pin, from the
+// base and size of each chip in the legacy sysfs numbering.
+func findChip(pin int) (dev string, offset int, err error) {
+	chips, err := filepath.Glob(SysfsPath + "/gpiochip*")
+	if err != nil {
+		return
+	}
+	for _, chip := range chips {
+		var base, ngpio int
+		if base, err = readInt(chip + "/base"); err != nil {
+			return
+		}
+		if ngpio, err = readInt(chip + "/ngpio"); err != nil {
+			return
+		}
+		if pin < base || pin >= base+ngpio {
+			continue
+		}
+
+		devs, _ := filepath.Glob(chip + "/device/gpiochip*")
+		if len(devs) == 0 {
+			break
+		}
+		return "/dev/" + filepath.Base(devs[0]), pin - base, nil
+	}
+
+	err = fmt.Errorf("No GPIO chip found for pin %d", pin)
+	return
+}
+
+func readInt(path string) (value int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	n, err := fmt.Fscanf(f, "%d", &value)
+	if n != 1 {
+		err = fmt.Errorf("Bad number of values read from %s: %d", path, n)
+	}
+
+	return
+}
+
+// OpenLineEvents requests edge events for a pin from the kernel's GPIO
+// character device and returns an EdgeWatcher delivering them.  The kernel
+// timestamps each edge in its interrupt handler, so the times are accurate
+// even when this process is slow to read them, and events are queued rather
+// than lost.  Kernels before 4.19 or so timestamp with CLOCK_REALTIME rather
+// than CLOCK_MONOTONIC, which only matters when comparing with other clocks.
+//
+// The pin must not be exported through sysfs at the same time; unexport it
+// first, or the request fails with ErrBusy.
+func OpenLineEvents(pin int, edge Edge) (w *EdgeWatcher, err error) {
+	req := gpioevent_request{handleflags: GPIOHANDLE_REQUEST_INPUT}
+	switch edge {
+	case Rising:
+		req.eventflags = GPIOEVENT_REQUEST_RISING_EDGE
+	case Falling:
+		req.eventflags = GPIOEVENT_REQUEST_FALLING_EDGE
+	case Both:
+		req.eventflags = GPIOEVENT_REQUEST_RISING_EDGE | GPIOEVENT_REQUEST_FALLING_EDGE
+	default:
+		err = fmt.Errorf("%w: %s", ErrInvalidEdge, edge)
+		return
+	}
+
+	dev, offset, err := findChip(pin)
+	if err != nil {
+		return
+	}
+	req.lineoffset = uint32(offset)
+	copy(req.consumerLabel[:], "gopherbone")
+
+	chip, err := gopherbone.OpenFile(dev, os.O_RDONLY, 0)
+	if err != nil {
+		err = pinError("open "+dev, pin, err)
+		return
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, chip.Fd(), GPIO_GET_LINEEVENT_IOCTL, uintptr(unsafe.Pointer(&req)))
+	chip.Close()
+	if errno != 0 {
+		err = pinError("request line events", pin, errno)
+		return
+	}
+
+	// A non-blocking file is read through the runtime's poller, so closing
+	// it wakes a blocked Read
+	if err = syscall.SetNonblock(int(req.fd), true); err != nil {
+		syscall.Close(int(req.fd))
+		return
+	}
+	f := os.NewFile(uintptr(req.fd), dev)
+
+	w = &EdgeWatcher{c: make(chan EdgeEvent, 16), stop: func() { f.Close() }}
+	w.C = w.c
+
+	go func() {
+		defer close(w.c)
+		var buf [gpioeventDataSize]byte
+		for {
+			if _, err := f.Read(buf[:]); err != nil {
+				if !errors.Is(err, os.ErrClosed) {
+					w.Err = pinError("read line event", pin, err)
+				}
+				return
+			}
+			ev := EdgeEvent{Time: time.Duration(binary.LittleEndian.Uint64(buf[0:]))}
+			if binary.LittleEndian.Uint32(buf[8:]) == GPIOEVENT_EVENT_RISING_EDGE {
+				ev.Value = 1
+			}
+			metrics.GPIOEdges.Inc()
+			w.c <- ev
+		}
+	}()
+
+	return
+}
@@ -0,0 +1,346 @@
+package gpio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/metrics"
+	"os"
+	"syscall"
+	"time"
+)
+
+var errInterrupted = errors.New("gpio: edge wait interrupted")
+
+// An edgePoller waits for the kernel to signal an edge on a pin's value file.
+// A pipe is registered alongside the value file so that a blocked wait can be
+// interrupted from another goroutine.
+type edgePoller struct {
+	pin  int
+	f    *os.File
+	epfd int
+	pipe [2]int
+}
+
+func newEdgePoller(pin int) (p *edgePoller, err error) {
+	p = &edgePoller{pin: pin, epfd: -1, pipe: [2]int{-1, -1}}
+
+	p.f, err = openAttr(pin, "value", os.O_RDONLY)
+	if err != nil {
+		return
+	}
+	if err = syscall.Pipe2(p.pipe[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		p.close()
+		return
+	}
+	if p.epfd, err = syscall.EpollCreate1(syscall.EPOLL_CLOEXEC); err != nil {
+		p.close()
+		return
+	}
+
+	err = syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, int(p.f.Fd()), &syscall.EpollEvent{
+		Events: syscall.EPOLLPRI | syscall.EPOLLERR,
+		Fd:     int32(p.f.Fd())})
+	if err == nil {
+		err = syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, p.pipe[0], &syscall.EpollEvent{
+			Events: syscall.EPOLLIN,
+			Fd:     int32(p.pipe[0])})
+	}
+	if err != nil {
+		p.close()
+		return
+	}
+
+	// The value file always polls as ready until it has been read once
+	_, err = p.read()
+	if err != nil {
+		p.close()
+	}
+
+	return
+}
+
+// read returns the pin's current value.
+func (p *edgePoller) read() (value int, err error) {
+	p.f.Seek(0, 0)
+	n, err := fmt.Fscanf(p.f, "%d", &value)
+	if n != 1 {
+		err = fmt.Errorf("Bad number of values read from %s/gpio%d/value: %d", SysfsPath, p.pin, n)
+	}
+
+	return
+}
+
+// wait blocks until an edge occurs or the timeout expires, returning whether
+// an edge occurred.  A negative timeout waits forever.  The value file is read
+// after an edge to rearm it for the next one.
+func (p *edgePoller) wait(timeout time.Duration) (edge bool, err error) {
+	msec := -1
+	if timeout >= 0 {
+		msec = int(timeout / time.Millisecond)
+	}
+	events := make([]syscall.EpollEvent, 2)
+
+	for {
+		var n int
+		n, err = syscall.EpollWait(p.epfd, events, msec)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		for _, ev := range events[:n] {
+			if int(ev.Fd) == p.pipe[0] {
+				err = errInterrupted
+				return
+			}
+			edge = true
+		}
+		if edge {
+			_, err = p.read()
+		}
+		return
+	}
+}
+
+// interrupt causes any current or future wait to return errInterrupted.
+func (p *edgePoller) interrupt() {
+	syscall.Write(p.pipe[1], []byte{0})
+}
+
+func (p *edgePoller) close() {
+	if p.epfd >= 0 {
+		syscall.Close(p.epfd)
+	}
+	for _, fd := range p.pipe {
+		if fd >= 0 {
+			syscall.Close(fd)
+		}
+	}
+	if p.f != nil {
+		p.f.Close()
+	}
+}
+
+// settle waits until no edges have occurred for the debounce period and then
+// returns the pin's value.
+func (p *edgePoller) settle(debounce time.Duration) (value int, err error) {
+	for {
+		var edge bool
+		edge, err = p.wait(debounce)
+		if err != nil {
+			return
+		}
+		if !edge {
+			return p.read()
+		}
+	}
+}
+
+// Debounce sets the period for which an input pin's value must be stable
+// before WaitForEdge or a Watcher reports an edge.  Mechanical switches bounce
+// for a few milliseconds when pressed or released, so something in the range
+// of 5 to 50 milliseconds is typical.  A duration of zero disables
+// debouncing.
+func (gpio *GPIO) Debounce(d time.Duration) {
+	gpio.debounce = d
+}
+
+// WaitForEdge blocks until an edge selected by SetEdge occurs on the pin, and
+// returns the pin's new value.  A negative timeout waits forever; if the
+// timeout expires, timedOut is true.  If the pin is being debounced, the value
+// returned is the one the pin settles to.
+func (gpio *GPIO) WaitForEdge(timeout time.Duration) (value int, timedOut bool, err error) {
+	ctx := context.Background()
+	if timeout >= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	value, err = gpio.WaitForEdgeContext(ctx)
+	if err == context.DeadlineExceeded {
+		timedOut, err = true, nil
+	}
+
+	return
+}
+
+// WaitForEdgeContext is like WaitForEdge, but waits until ctx is done rather
+// than for a timeout, in which case it returns ctx.Err().
+func (gpio *GPIO) WaitForEdgeContext(ctx context.Context) (value int, err error) {
+	p, err := newEdgePoller(gpio.Pin)
+	if err != nil {
+		return
+	}
+	defer p.close()
+
+	// Interrupt the poller if ctx is done before it returns
+	waited := make(chan struct{})
+	interrupter := make(chan struct{})
+	defer func() {
+		close(waited)
+		<-interrupter
+	}()
+	go func() {
+		defer close(interrupter)
+		select {
+		case <-ctx.Done():
+			p.interrupt()
+		case <-waited:
+		}
+	}()
+
+	_, err = p.wait(-1)
+	if err == nil && gpio.debounce > 0 {
+		value, err = p.settle(gpio.debounce)
+	} else if err == nil {
+		value, err = p.read()
+	}
+	if err == errInterrupted {
+		err = ctx.Err()
+	}
+
+	return
+}
+
+// A Watcher delivers the values of a pin following each edge on the channel
+// C.  Create one with the Watch method.
+type Watcher struct {
+	C <-chan int
+
+	// Err holds the error which stopped the Watcher, if any.  It is valid
+	// once C has been closed.
+	Err error
+
+	c       chan int
+	stop    func()
+	cleanup func()
+
+	// unwatch and unwatched stop the goroutine watching a context, if any
+	unwatch   chan struct{}
+	unwatched chan struct{}
+}
+
+// NewWatcher creates a Watcher for pins which are not sysfs GPIOs, such as
+// those on I/O expanders, returning the channel on which the pin's values
+// should be sent.  Close calls stop, which must make the sender close the
+// channel.
+func NewWatcher(stop func()) (w *Watcher, values chan<- int) {
+	w = &Watcher{c: make(chan int, 1), stop: stop}
+	w.C = w.c
+
+	return w, w.c
+}
+
+// Watch sets the pin's edge and starts a Watcher which sends the pin's value
+// on its channel each time the edge occurs.  If the pin is being debounced,
+// the Watcher watches both edges internally and only reports changes which
+// have been stable for the debounce period and match the requested edge.
+func (gpio *GPIO) Watch(edge Edge) (w *Watcher, err error) {
+	if edge == None {
+		err = fmt.Errorf("%w: %s", ErrInvalidEdge, edge)
+		return
+	}
+
+	debounce := gpio.debounce
+	if debounce > 0 {
+		err = gpio.SetEdge(Both)
+	} else {
+		err = gpio.SetEdge(edge)
+	}
+	if err != nil {
+		return
+	}
+
+	p, err := newEdgePoller(gpio.Pin)
+	if err != nil {
+		return
+	}
+	w, _ = NewWatcher(p.interrupt)
+	w.cleanup = p.close
+
+	go w.run(p, edge, debounce)
+
+	return
+}
+
+// WatchContext is like Watch, but the Watcher also stops, closing C, when ctx
+// is done.  Close must still be called to release the pin.
+func (gpio *GPIO) WatchContext(ctx context.Context, edge Edge) (w *Watcher, err error) {
+	if w, err = gpio.Watch(edge); err != nil {
+		return
+	}
+	w.StopWith(ctx)
+
+	return
+}
+
+// StopWith stops the Watcher, closing C, when ctx is done.  It may be called
+// at most once, and Close must still be called afterwards.
+func (w *Watcher) StopWith(ctx context.Context) {
+	w.unwatch = make(chan struct{})
+	w.unwatched = make(chan struct{})
+	go func() {
+		defer close(w.unwatched)
+		select {
+		case <-ctx.Done():
+			w.stop()
+		case <-w.unwatch:
+		}
+	}()
+}
+
+func (w *Watcher) run(p *edgePoller, edge Edge, debounce time.Duration) {
+	defer close(w.c)
+
+	last, err := p.read()
+	for err == nil {
+		var value int
+		_, err = p.wait(-1)
+		if err != nil {
+			break
+		}
+
+		if debounce > 0 {
+			value, err = p.settle(debounce)
+			if err != nil || value == last {
+				continue
+			}
+			last = value
+			if (edge == Rising && value != 1) || (edge == Falling && value != 0) {
+				continue
+			}
+		} else {
+			value, err = p.read()
+			if err != nil {
+				break
+			}
+		}
+
+		metrics.GPIOEdges.Inc()
+		w.c <- value
+	}
+
+	if err != errInterrupted {
+		w.Err = err
+	}
+}
+
+// Close stops the Watcher and closes its channel.
+func (w *Watcher) Close() (err error) {
+	w.stop()
+	// Drain the channel so that the sender can't block sending to it
+	for range w.c {
+	}
+	if w.unwatch != nil {
+		close(w.unwatch)
+		<-w.unwatched
+	}
+	if w.cleanup != nil {
+		w.cleanup()
+	}
+
+	return
+}
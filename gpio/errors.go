@@ -0,0 +1,76 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Errors which may be matched with errors.Is.  Errors from the kernel are
+// kept as well, so os.ErrPermission and os.ErrNotExist can also be matched.
+var (
+	// ErrNotExported is returned when a pin's sysfs files don't exist.
+	ErrNotExported = errors.New("GPIO not exported")
+	// ErrBusy is returned by Export when the pin is claimed by a kernel
+	// driver or another program.
+	ErrBusy error = syscall.EBUSY
+
+	ErrInvalidDirection = errors.New("Invalid direction")
+	ErrInvalidEdge      = errors.New("Invalid edge")
+	ErrInvalidValue     = errors.New("Invalid value")
+)
+
+// A PinError records a failed operation on a pin and the error which caused
+// it.
+type PinError struct {
+	Op  string
+	Pin int
+	Err error
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("GPIO %d: %s: %v", e.Pin, e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PinError) Unwrap() error {
+	return e.Err
+}
+
+// pinError wraps a non-nil error in a PinError, dropping any *os.PathError
+// since the pin and operation say where it came from.
+func pinError(op string, pin int, err error) error {
+	if err == nil {
+		return nil
+	}
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	return &PinError{Op: op, Pin: pin, Err: err}
+}
+
+// traceAttr traces a write of data to one of a pin's sysfs attribute files
+// which started at start.
+func traceAttr(start time.Time, pin int, attr, data string, err error) {
+	if gopherbone.Tracing() {
+		gopherbone.TraceSysfsWrite(start, fmt.Sprintf("%s/gpio%d/%s", SysfsPath, pin, attr), data, err)
+	}
+}
+
+// openAttr opens one of a pin's sysfs attribute files.
+func openAttr(pin int, attr string, flag int) (f *os.File, err error) {
+	f, err = gopherbone.OpenFile(fmt.Sprintf("%s/gpio%d/%s", SysfsPath, pin, attr), flag, 0666)
+	if os.IsNotExist(err) {
+		// Keep the kernel's error too, so os.ErrNotExist still matches
+		if pe, ok := err.(*os.PathError); ok {
+			err = pe.Err
+		}
+		err = fmt.Errorf("%w: %w", ErrNotExported, err)
+	}
+	err = pinError("open "+attr, pin, err)
+
+	return
+}
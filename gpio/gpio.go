@@ -27,10 +27,19 @@
 package gpio
 
 import (
+	"errors"
 	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"io"
 	"os"
+	"sync"
+	"time"
 )
 
+// SysfsPath is where the kernel's sysfs GPIO interface is, which may be
+// changed for testing or for unusual systems.
+var SysfsPath = "/sys/class/gpio"
+
 // P8 is an array of pin values made for conveniently referring to pins on the
 // BeagleBone's P8 header.
 var P8 = [47]int{
@@ -135,12 +144,62 @@ var P9 = [47]int{
     -1,  // GND
 }
 
+// Direction is the direction of a GPIO pin, as written to its sysfs direction
+// file.
+type Direction string
+
+// Possible pin directions.
+const (
+	In  Direction = "in"
+	Out Direction = "out"
+)
+
+func (dir Direction) valid() bool {
+	return dir == In || dir == Out
+}
+
+// Edge selects which transitions of an input pin's value cause polling its
+// value file to return.
+type Edge string
+
+// Possible edge settings.
+const (
+	None    Edge = "none"
+	Rising  Edge = "rising"
+	Falling Edge = "falling"
+	Both    Edge = "both"
+)
+
+func (edge Edge) valid() bool {
+	return edge == None || edge == Rising || edge == Falling || edge == Both
+}
+
 // A GPIO structure represents a GPIO pin on the BeagleBone, or for that matter
 // any Linux system.  To use a GPIO, create a pointer to a GPIO struct using
 // the Create function, passing the number of the pin requested.
 type GPIO struct {
 	Pin int
 	ValueFile *os.File
+
+	debounce time.Duration
+	// cleanup unexports the pin if the program exits without doing so; it
+	// is nil if the pin was already exported when this GPIO was made
+	cleanup *gopherbone.Handle
+	// lock protects ValueFile
+	lock sync.Mutex
+}
+
+// A DigitalPin is anything which can be used like a GPIO, such as a pin on an
+// I/O expander.  Drivers which take a DigitalPin work with any of them.
+type DigitalPin interface {
+	Value() (int, error)
+	SetValue(value int) error
+	Direction() (Direction, error)
+	SetDirection(dir Direction) error
+	SetActiveLow(activeLow bool) error
+	Debounce(d time.Duration)
+	Watch(edge Edge) (*Watcher, error)
+	Unexport() error
 }
 
 // Export creates a GPIO structure from the specified pin, exports the pin to
@@ -149,40 +208,58 @@ func Export(pin int) (gpio *GPIO, err error) {
 	gpio = new(GPIO)
 	var f *os.File
 
-	_, err = os.Stat(fmt.Sprintf("/sys/class/gpio/gpio%d", pin))
-	if err != nil && os.IsNotExist(err) {
-		f, err = os.OpenFile("/sys/class/gpio/export", os.O_WRONLY, 0666)
+	gpio.Pin = pin
+	_, err = os.Stat(fmt.Sprintf("%s/gpio%d", SysfsPath, pin))
+	if err != nil && !os.IsNotExist(err) {
+		err = pinError("export", pin, err)
+		return
+	}
+	// Only a pin this call exports is unexported by Cleanup; one exported
+	// already belongs to someone else
+	if err != nil {
+		f, err = gopherbone.OpenFile(SysfsPath+"/export", os.O_WRONLY, 0666)
 		if err != nil {
+			err = pinError("export", pin, err)
 			return
 		}
 		defer f.Close()
 
+		start := time.Now()
 		_, err = fmt.Fprintf(f, "%d", pin)
+		gopherbone.TraceSysfsWrite(start, SysfsPath+"/export", fmt.Sprint(pin), err)
+		err = pinError("export", pin, err)
 		if err != nil {
 			return
 		}
+		// Give udev time to let a non-root user at the new files
+		err = pinError("export", pin, gopherbone.WaitWritable(fmt.Sprintf("%s/gpio%d/direction", SysfsPath, pin)))
+		if err != nil {
+			return
+		}
+		gpio.cleanup = gopherbone.Register(fmt.Sprintf("GPIO %d", pin), gpio.Unexport)
 	}
-	gpio.Pin = pin
-	gpio.ValueFile = nil
 
 	return
 }
 
 // Unexport removes the sysfs entry of a GPIO.
 func (gpio *GPIO) Unexport() (err error) {
-	if gpio.ValueFile != nil {
-		err = gpio.CloseValue()
-		if err != nil {
-			return
-		}
+	gpio.cleanup.Unregister()
+	err = gpio.CloseValue()
+	if err != nil {
+		return
 	}
-	f, err := os.OpenFile("/sys/class/gpio/unexport", os.O_WRONLY, 0666)
+	f, err := gopherbone.OpenFile(SysfsPath+"/unexport", os.O_WRONLY, 0666)
 	if err != nil {
+		err = pinError("unexport", gpio.Pin, err)
 		return
 	}
 	defer f.Close()
 
+	start := time.Now()
 	_, err = fmt.Fprintf(f, "%d", gpio.Pin)
+	gopherbone.TraceSysfsWrite(start, SysfsPath+"/unexport", fmt.Sprint(gpio.Pin), err)
+	err = pinError("unexport", gpio.Pin, err)
 	// Don't bother checking for errors here because we're returning anyway
 
 	return
@@ -192,21 +269,16 @@ func (gpio *GPIO) Unexport() (err error) {
 // value is the one set by SetValue; if the pin is an input, the value comes
 // from the outside world.
 func (gpio *GPIO) Value() (value int, err error) {
-	var f *os.File
-	if gpio.ValueFile == nil {
-		f, err = os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/value", gpio.Pin), os.O_RDONLY, 0666)
-		if err != nil {
-			return
-		}
-		defer f.Close()
-	} else {
-		f = gpio.ValueFile
-		f.Seek(0, 0)
+	f, err := gpio.valueFile()
+	if err != nil {
+		return
 	}
 
-	n, err := fmt.Fscanf(f, "%d", &value)
+	// Read at offset 0 rather than seeking, so that goroutines sharing the
+	// file don't disturb each other
+	n, err := fmt.Fscanf(io.NewSectionReader(f, 0, 8), "%d", &value)
 	if n != 1 {
-		err = fmt.Errorf("Bad number of values read from /sys/class/gpio/gpio%d/value: %d", gpio.Pin, n)
+		err = fmt.Errorf("Bad number of values read from %s/gpio%d/value: %d", SysfsPath, gpio.Pin, n)
 	}
 
 	return
@@ -214,50 +286,72 @@ func (gpio *GPIO) Value() (value int, err error) {
 
 // SetValue sets the value of an output pin.
 func (gpio *GPIO) SetValue(value int) (err error) {
-	var f *os.File
 	if value != 0 && value != 1 {
-		err = fmt.Errorf("Invalid value: %d", value)
+		err = fmt.Errorf("%w: %d", ErrInvalidValue, value)
 		return
 	}
+	f, err := gpio.valueFile()
+	if err != nil {
+		return
+	}
+
+	start := time.Now()
+	_, err = f.WriteAt([]byte{'0' + byte(value)}, 0)
+	traceAttr(start, gpio.Pin, "value", string('0'+rune(value)), err)
+	err = pinError("write value", gpio.Pin, err)
+
+	return
+}
+
+// valueFile returns the GPIO's value file, opening it the first time it is
+// needed and keeping it open until CloseValue or Unexport, since opening it
+// costs far more than reading or writing it.  If the file can't be opened for
+// writing, it is opened read only so that inputs can still be read.
+func (gpio *GPIO) valueFile() (f *os.File, err error) {
+	gpio.lock.Lock()
+	defer gpio.lock.Unlock()
+
 	if gpio.ValueFile == nil {
-		f, err = os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/value", gpio.Pin), os.O_WRONLY, 0666)
+		gpio.ValueFile, err = openAttr(gpio.Pin, "value", os.O_RDWR)
+		if errors.Is(err, os.ErrPermission) {
+			gpio.ValueFile, err = openAttr(gpio.Pin, "value", os.O_RDONLY)
+		}
 		if err != nil {
+			gpio.ValueFile = nil
 			return
 		}
-		defer f.Close()
-	} else {
-		f = gpio.ValueFile
-		f.Seek(0, 0)
 	}
 
-	_, err = fmt.Fprintf(f, "%d", value)
-
-	return
+	return gpio.ValueFile, nil
 }
 
 // OpenValue opens the GPIO's value file for reading and writing.  The open
-// file is kept in the GPIO struct's ValueFile member.
+// file is kept in the GPIO struct's ValueFile member.  Value and SetValue
+// open the file themselves if need be, so calling this is only useful to
+// check for errors early.
 func (gpio *GPIO) OpenValue() (err error) {
-	gpio.ValueFile, err = os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/value", gpio.Pin), os.O_RDWR, 0666)
+	_, err = gpio.valueFile()
 
 	return
 }
 
+// CloseValue closes the GPIO's value file, if it is open.
 func (gpio *GPIO) CloseValue() (err error) {
-	gpio.ValueFile.Close()
-	if err != nil {
-		return
-	}
+	gpio.lock.Lock()
+	defer gpio.lock.Unlock()
 
-	gpio.ValueFile = nil
+	if gpio.ValueFile != nil {
+		err = gpio.ValueFile.Close()
+		gpio.ValueFile = nil
+	}
 
 	return
 }
 
 // Direction sets returns the current direction of a pin.  This may be either
-// "in" or "out".
-func (gpio *GPIO) Direction() (dir string, err error) {
-	f, err := os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", gpio.Pin), os.O_RDONLY, 0666)
+// In or Out.
+func (gpio *GPIO) Direction() (dir Direction, err error) {
+	f, err := openAttr(gpio.Pin, "direction", os.O_RDONLY)
 	if err != nil {
 		return
 	}
@@ -265,34 +359,44 @@ func (gpio *GPIO) Direction() (dir string, err error) {
 
 	n, err := fmt.Fscanf(f, "%s", &dir)
 	if n != 1 {
-		err = fmt.Errorf("Bad number of values read from /sys/class/gpio/gpio%d/direction: %d", gpio.Pin, n)
+		err = fmt.Errorf("Bad number of values read from %s/gpio%d/direction: %d", SysfsPath, gpio.Pin, n)
 	}
 
 	return
 }
 
 // SetDirection sets a pin's direction, input or output.  The argument must be
-// either "in" or "out".
-func (gpio *GPIO) SetDirection(dir string) (err error) {
-	if dir != "in" && dir != "out" {
-		err = fmt.Errorf("Invalid direction: %s", dir)
+// either In or Out.
+func (gpio *GPIO) SetDirection(dir Direction) (err error) {
+	if !dir.valid() {
+		err = fmt.Errorf("%w: %s", ErrInvalidDirection, dir)
 		return
 	}
-	f, err := os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/direction", gpio.Pin), os.O_WRONLY, 0666)
+	f, err := openAttr(gpio.Pin, "direction", os.O_WRONLY)
 	if err != nil {
 		return
 	}
 	defer f.Close()
 
+	start := time.Now()
 	_, err = fmt.Fprintf(f, "%s", dir)
+	traceAttr(start, gpio.Pin, "direction", string(dir), err)
+	err = pinError("write direction", gpio.Pin, err)
 
 	return
 }
 
+// SetDirectionString is like SetDirection, but takes the direction as a
+// plain string ("in" or "out").  It exists for code written before the
+// Direction type was introduced.
+func (gpio *GPIO) SetDirectionString(dir string) (err error) {
+	return gpio.SetDirection(Direction(dir))
+}
+
 // Edge returns the current edge(s) for which polling this pin's value file
 // will return.
-func (gpio *GPIO) Edge() (edge string, err error) {
-	f, err := os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/edge", gpio.Pin), os.O_RDONLY, 0666)
+func (gpio *GPIO) Edge() (edge Edge, err error) {
+	f, err := openAttr(gpio.Pin, "edge", os.O_RDONLY)
 	if err != nil {
 		return
 	}
@@ -300,7 +404,7 @@ func (gpio *GPIO) Edge() (edge string, err error) {
 
 	n, err := fmt.Fscanf(f, "%s", &edge)
 	if n != 1 {
-		err = fmt.Errorf("Bad number of values read from /sys/class/gpio/gpio%d/edge: %d", gpio.Pin, n)
+		err = fmt.Errorf("Bad number of values read from %s/gpio%d/edge: %d", SysfsPath, gpio.Pin, n)
 	}
 
 	return
@@ -308,18 +412,91 @@ func (gpio *GPIO) Edge() (edge string, err error) {
 
 // SetEdge sets the edge(s) for which polling this pin's value file will
 // return.
-func (gpio *GPIO) SetEdge(edge string) (err error) {
-	if edge != "none" && edge != "rising" && edge != "falling" && edge != "both" {
-		err = fmt.Errorf("Invalid edge: %s", edge)
+func (gpio *GPIO) SetEdge(edge Edge) (err error) {
+	if !edge.valid() {
+		err = fmt.Errorf("%w: %s", ErrInvalidEdge, edge)
 		return
 	}
-	f, err := os.OpenFile(fmt.Sprintf("/sys/class/gpio/gpio%d/edge", gpio.Pin), os.O_WRONLY, 0666)
+	f, err := openAttr(gpio.Pin, "edge", os.O_WRONLY)
 	if err != nil {
 		return
 	}
 	defer f.Close()
 
+	start := time.Now()
 	_, err = fmt.Fprintf(f, "%s", edge)
+	traceAttr(start, gpio.Pin, "edge", string(edge), err)
+	err = pinError("write edge", gpio.Pin, err)
+
+	return
+}
+
+// SetEdgeString is like SetEdge, but takes the edge as a plain string
+// ("none", "rising", "falling" or "both").  It exists for code written before
+// the Edge type was introduced.
+func (gpio *GPIO) SetEdgeString(edge string) (err error) {
+	return gpio.SetEdge(Edge(edge))
+}
+
+// ActiveLow returns whether the pin's value is inverted by the kernel.  When
+// a pin is active low, a value of 1 means the pin is being driven (or read)
+// low.
+func (gpio *GPIO) ActiveLow() (activeLow bool, err error) {
+	f, err := openAttr(gpio.Pin, "active_low", os.O_RDONLY)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var value int
+	n, err := fmt.Fscanf(f, "%d", &value)
+	if n != 1 {
+		err = fmt.Errorf("Bad number of values read from %s/gpio%d/active_low: %d", SysfsPath, gpio.Pin, n)
+	}
+	activeLow = value != 0
+
+	return
+}
+
+// SetActiveLow sets whether the pin's value should be inverted.  This is
+// handy for hardware such as relays and LEDs which are switched on by pulling
+// the pin low, since 1 can then always mean "on".
+func (gpio *GPIO) SetActiveLow(activeLow bool) (err error) {
+	f, err := openAttr(gpio.Pin, "active_low", os.O_WRONLY)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	value := "0"
+	if activeLow {
+		value = "1"
+	}
+	start := time.Now()
+	_, err = fmt.Fprint(f, value)
+	traceAttr(start, gpio.Pin, "active_low", value, err)
+	err = pinError("write active_low", gpio.Pin, err)
+
+	return
+}
+
+// SetHigh sets an output pin to its logical high (active) state.  If the pin
+// is active low, this drives the pin low.
+func (gpio *GPIO) SetHigh() (err error) {
+	return gpio.SetValue(1)
+}
+
+// SetLow sets an output pin to its logical low (inactive) state.  If the pin
+// is active low, this drives the pin high.
+func (gpio *GPIO) SetLow() (err error) {
+	return gpio.SetValue(0)
+}
+
+// IsHigh returns whether the pin is in its logical high (active) state,
+// taking the pin's active low setting into account.
+func (gpio *GPIO) IsHigh() (high bool, err error) {
+	value, err := gpio.Value()
+	high = value == 1
 
 	return
 }
@@ -0,0 +1,214 @@
+package gpio
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/board"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// p8Signals and p9Signals name the signal found on each pin of the P8 and P9
+// headers, in the same order as P8 and P9.
+var p8Signals = [47]string{
+	"", "GND", "GND", "GPIO1_6", "GPIO1_7", "GPIO1_2", "GPIO1_3", "TIMER4",
+	"TIMER7", "TIMER5", "TIMER6", "GPIO1_13", "GPIO1_12", "EHRPWM2B",
+	"GPIO0_26", "GPIO1_15", "GPIO1_14", "GPIO0_27", "GPIO2_1", "EHRPWM2A",
+	"GPIO1_31", "GPIO1_30", "GPIO1_5", "GPIO1_4", "GPIO1_1", "GPIO1_0",
+	"GPIO1_29", "GPIO1_22", "GPIO1_24", "GPIO1_23", "GPIO1_25", "UART5_CTSN",
+	"UART5_RTSN", "UART4_RTSN", "UART3_RTSN", "UART4_CTSN", "UART3_CTSN",
+	"UART5_TXD", "UART5_RXD", "GPIO2_12", "GPIO2_13", "GPIO2_10", "GPIO2_11",
+	"GPIO2_8", "GPIO2_9", "GPIO2_6", "GPIO2_7",
+}
+
+var p9Signals = [47]string{
+	"", "GND", "GND", "DC_3.3V", "DC_3.3V", "VDD_5V", "VDD_5V", "SYS_5V",
+	"SYS_5V", "PWR_BUT", "SYS_RESETn", "UART4_RXD", "GPIO1_28", "UART4_TXD",
+	"EHRPWM1A", "GPIO1_16", "EHRPWM1B", "I2C1_SCL", "I2C1_SDA", "I2C2_SCL",
+	"I2C2_SDA", "UART2_TXD", "UART2_RXD", "GPIO1_17", "UART1_TXD", "GPIO3_21",
+	"UART1_RXD", "GPIO3_19", "SPI1_CS0", "SPI1_D0", "SPI1_D1", "SPI1_SCLK",
+	"VDD_ADC", "AIN4", "GNDA_ADC", "AIN6", "AIN5", "AIN2", "AIN3", "AIN0",
+	"AIN1", "CLKOUT2", "GPIO0_7", "GND", "GND", "GND", "GND",
+}
+
+// A Pin describes a single pin on one of the BeagleBone's expansion headers.
+type Pin struct {
+	// Name is the header name of the pin, such as "P8_13".
+	Name string
+	// Header is the header or connector the pin is on, such as "P8".
+	Header string
+	// Signal is the name of the pin's primary signal, such as "EHRPWM2B".
+	Signal string
+	// GPIO is the number of the GPIO on this pin, or -1 if the pin cannot be
+	// used as a GPIO.
+	GPIO int
+	// Modes lists the modes which may be passed to Mux.
+	Modes []string
+}
+
+var pinsByName map[string]*Pin
+var pinsBySignal map[string][]*Pin
+
+func init() {
+	pinsByName = make(map[string]*Pin)
+	pinsBySignal = make(map[string][]*Pin)
+	addHeader("P8", P8[:], p8Signals[:])
+	addHeader("P9", P9[:], p9Signals[:])
+	addHeader("P1", P1[:], p1Signals[:])
+	addHeader("P2", P2[:], p2Signals[:])
+	addConnector("GP0", GP0[:], gp0Signals[:])
+	addConnector("GP1", GP1[:], gp1Signals[:])
+	addConnector("S1.1", S1_1[:], s1_1Signals[:])
+	addConnector("S1.2", S1_2[:], s1_2Signals[:])
+}
+
+func addHeader(header string, gpios []int, signals []string) {
+	for i := 1; i < len(gpios); i++ {
+		addPin(header, fmt.Sprintf("%s_%02d", header, i), gpios[i], signals[i])
+	}
+}
+
+// addConnector is like addHeader, for the BeagleBone Blue's connectors,
+// whose pin numbers aren't zero padded.
+func addConnector(conn string, gpios []int, signals []string) {
+	for i := 1; i < len(gpios); i++ {
+		addPin(conn, fmt.Sprintf("%s_%d", conn, i), gpios[i], signals[i])
+	}
+}
+
+func addPin(header, name string, gpio int, signal string) {
+	pin := &Pin{Name: name, Header: header, Signal: signal, GPIO: gpio}
+	pin.Modes = pinModes(pin.GPIO, pin.Signal)
+	pinsByName[pin.Name] = pin
+	pinsBySignal[pin.Signal] = append(pinsBySignal[pin.Signal], pin)
+}
+
+// onBoard reports whether a header is present on the board the program is
+// running on.  If the board can't be identified, the BeagleBone's P8 and P9
+// are assumed.
+func onBoard(header string) bool {
+	headers := []string{"P8", "P9"}
+	if info, err := board.Current(); err == nil {
+		headers = info.Headers()
+	}
+	for _, h := range headers {
+		if h == header {
+			return true
+		}
+	}
+	return false
+}
+
+// pinModes works out the config-pin modes available on a pin from its GPIO
+// number and signal name.
+func pinModes(gpio int, signal string) (modes []string) {
+	if gpio < 0 {
+		return
+	}
+	modes = []string{"default", "gpio", "gpio_pu", "gpio_pd"}
+
+	switch {
+	case strings.HasPrefix(signal, "EHRPWM"):
+		modes = append(modes, "pwm")
+	case strings.HasPrefix(signal, "TIMER"):
+		modes = append(modes, "timer")
+	case strings.HasPrefix(signal, "UART"):
+		modes = append(modes, "uart")
+	case strings.HasPrefix(signal, "I2C"):
+		modes = append(modes, "i2c")
+	case strings.HasPrefix(signal, "SPI") && strings.HasSuffix(signal, "_SCLK"):
+		modes = append(modes, "spi_sclk")
+	case strings.HasPrefix(signal, "SPI") && strings.Contains(signal, "_CS"):
+		modes = append(modes, "spi_cs")
+	case strings.HasPrefix(signal, "SPI"):
+		modes = append(modes, "spi")
+	}
+
+	return
+}
+
+// LookupPin finds a pin by its header name ("P8_13", "P9_3", "P1_36", "GP0_3")
+// or by its signal name ("EHRPWM2B", "UART4_TXD").  Names are not case
+// sensitive.  Header names may be of any board in the family, but signal
+// names are only looked up on the headers of the board the program is
+// running on, as found by the board package.  Signal names shared by several
+// of those pins, such as "GND", are rejected as ambiguous.
+func LookupPin(name string) (pin *Pin, err error) {
+	name = strings.ToUpper(name)
+
+	var header string
+	var num int
+	if n, _ := fmt.Sscanf(name, "P%1s_%d", &header, &num); n == 2 {
+		name = fmt.Sprintf("P%s_%02d", header, num)
+	}
+	if pin = pinsByName[name]; pin != nil {
+		return
+	}
+
+	for signal, pins := range pinsBySignal {
+		if strings.ToUpper(signal) != name {
+			continue
+		}
+		for _, p := range pins {
+			if !onBoard(p.Header) {
+				continue
+			}
+			if pin != nil {
+				pin = nil
+				err = fmt.Errorf("Ambiguous signal name: %s", name)
+				return
+			}
+			pin = p
+		}
+		if pin != nil {
+			return
+		}
+	}
+
+	err = fmt.Errorf("No such pin: %s", name)
+	return
+}
+
+// HasMode reports whether mode is one of the pin's available modes.
+func (pin *Pin) HasMode(mode string) bool {
+	for _, m := range pin.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Mux sets the pin's pinmux to the given mode.  If the kernel exposes the
+// cape-universal pinmux helper for the pin, its state file is written
+// directly; otherwise the config-pin utility is run.
+func (pin *Pin) Mux(mode string) (err error) {
+	if !pin.HasMode(mode) {
+		err = fmt.Errorf("Invalid mode for %s: %s", pin.Name, mode)
+		return
+	}
+
+	f, err := gopherbone.OpenFile(fmt.Sprintf("/sys/devices/platform/ocp/ocp:%s_pinmux/state", pin.Name), os.O_WRONLY, 0666)
+	if err == nil {
+		defer f.Close()
+		_, err = fmt.Fprintf(f, "%s", mode)
+		return
+	}
+
+	out, err := exec.Command("config-pin", pin.Name, mode).CombinedOutput()
+	if err != nil {
+		err = fmt.Errorf("config-pin %s %s: %v: %s", pin.Name, mode, err, strings.TrimSpace(string(out)))
+	}
+
+	return
+}
+
+// Export exports the pin's GPIO, as with the Export function.
+func (pin *Pin) Export() (gpio *GPIO, err error) {
+	if pin.GPIO < 0 {
+		err = fmt.Errorf("Pin %s is not a GPIO", pin.Name)
+		return
+	}
+	return Export(pin.GPIO)
+}
@@ -0,0 +1,50 @@
+package gpio
+
+// P1 and P2 give the GPIO number of each pin on the PocketBeagle's P1 and P2
+// headers, or -1 for pins which cannot be used as GPIOs, as P8 and P9 do for
+// the BeagleBone.
+var P1 = [37]int{
+	-1, -1, 87, 109, 89, -1, 5, -1, 2, -1,
+	3, -1, 4, -1, -1, -1, -1, -1, -1, -1,
+	20, -1, -1, -1, -1, -1, 12, -1, 13, 117,
+	43, 114, 42, 111, 26, 88, 110,
+}
+
+var P2 = [37]int{
+	-1, 50, 59, 23, 58, 30, 57, 31, 60, 15,
+	52, 14, -1, -1, -1, -1, -1, 65, 47, 27,
+	64, -1, 46, -1, 44, 41, -1, 40, 116, 7,
+	113, 19, 112, 45, 115, 86, -1,
+}
+
+var p1Signals = [37]string{
+	"", "VIN", "GPIO2_23", "USB1_DRVVBUS", "GPIO2_25", "USB1_VBUS",
+	"SPI0_CS0", "VIN_USB", "SPI0_SCLK", "USB1_DN", "SPI0_D0", "USB1_DP",
+	"SPI0_D1", "USB1_ID", "VOUT_3.3V", "GND", "GND", "VREFN", "VREFP",
+	"AIN0", "GPIO0_20", "AIN1", "GND", "AIN2", "VOUT_5V", "AIN3",
+	"I2C2_SDA", "AIN4", "I2C2_SCL", "GPIO3_21", "UART0_TXD", "GPIO3_18",
+	"UART0_RXD", "EHRPWM0B", "GPIO0_26", "GPIO2_24", "EHRPWM0A",
+}
+
+var p2Signals = [37]string{
+	"", "EHRPWM1A", "GPIO1_27", "GPIO0_23", "GPIO1_26", "UART4_RXD",
+	"GPIO1_25", "UART4_TXD", "GPIO1_28", "I2C1_SCL", "GPIO1_20",
+	"I2C1_SDA", "PWR_BUT", "VOUT_5V", "BAT_VIN", "GND", "BAT_TEMP",
+	"GPIO2_1", "GPIO1_15", "GPIO0_27", "GPIO2_0", "GND", "GPIO1_14",
+	"VOUT_3.3V", "GPIO1_12", "SPI1_D1", "SYS_RESETn", "SPI1_D0",
+	"GPIO3_20", "SPI1_SCLK", "GPIO3_17", "SPI1_CS1", "GPIO3_16",
+	"GPIO1_13", "GPIO3_19", "GPIO2_22", "AIN7",
+}
+
+// The BeagleBone Blue has no headers, but brings GPIOs out on some of its
+// JST connectors: GP0 and GP1, and the slave select pins of the two SPI
+// connectors, S1.1 and S1.2.  Their pins are named like "GP0_3".
+var GP0 = [7]int{-1, -1, -1, 57, 49, 116, 113}
+var GP1 = [5]int{-1, -1, -1, 97, 98}
+var S1_1 = [7]int{-1, -1, -1, -1, -1, -1, 29}
+var S1_2 = [7]int{-1, -1, -1, -1, -1, -1, 7}
+
+var gp0Signals = [7]string{"", "GND", "3.3V", "GPIO1_25", "GPIO1_17", "GPIO3_20", "GPIO3_17"}
+var gp1Signals = [5]string{"", "GND", "3.3V", "GPIO3_1", "GPIO3_2"}
+var s1_1Signals = [7]string{"", "GND", "3.3V", "SPI1_D1", "SPI1_D0", "SPI1_SCLK", "GPIO0_29"}
+var s1_2Signals = [7]string{"", "GND", "3.3V", "SPI1_D1", "SPI1_D0", "SPI1_SCLK", "GPIO0_7"}
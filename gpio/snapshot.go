@@ -0,0 +1,189 @@
+package gpio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A PinState is the saved state of one pin.  Value is the logical value,
+// taking ActiveLow into account.  Edge is empty for pins which can't
+// interrupt.
+type PinState struct {
+	Pin       int       `json:"pin"`
+	Exported  bool      `json:"exported"`
+	Direction Direction `json:"direction,omitempty"`
+	Value     int       `json:"value"`
+	Edge      Edge      `json:"edge,omitempty"`
+	ActiveLow bool      `json:"active_low"`
+}
+
+// A Snapshot is the saved state of a set of pins, which can be written out
+// as JSON and restored later, for instance to put the hardware back into a
+// known good state after an experiment, or when a program restarts.
+type Snapshot struct {
+	Time time.Time  `json:"time"`
+	Pins []PinState `json:"pins"`
+}
+
+// exported returns the pins exported through sysfs, in order.
+func exported() (pins []int, err error) {
+	dirs, err := filepath.Glob(SysfsPath + "/gpio[0-9]*")
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		if n, e := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "gpio")); e == nil {
+			pins = append(pins, n)
+		}
+	}
+	sort.Ints(pins)
+	return
+}
+
+// Capture saves the state of the given pins, or of every exported pin if
+// none are given.  Pins which aren't exported are recorded as such, and
+// left alone.
+func Capture(pins ...int) (s *Snapshot, err error) {
+	if len(pins) == 0 {
+		if pins, err = exported(); err != nil {
+			return
+		}
+	}
+
+	s = &Snapshot{Time: time.Now()}
+	for _, pin := range pins {
+		var state PinState
+		if state, err = capturePin(pin); err != nil {
+			return nil, err
+		}
+		s.Pins = append(s.Pins, state)
+	}
+	return
+}
+
+func capturePin(pin int) (state PinState, err error) {
+	state.Pin = pin
+	if _, err = os.Stat(fmt.Sprintf("%s/gpio%d", SysfsPath, pin)); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	state.Exported = true
+
+	g := &GPIO{Pin: pin}
+	if state.Direction, err = g.Direction(); err != nil {
+		return
+	}
+	if state.ActiveLow, err = g.ActiveLow(); err != nil {
+		return
+	}
+	if state.Value, err = g.Value(); err != nil {
+		return
+	}
+	if state.Edge, err = g.Edge(); errors.Is(err, ErrNotExported) {
+		// Pins which can't interrupt have no edge file
+		state.Edge, err = "", nil
+	}
+	return
+}
+
+// Restore puts each pin back as it was: exporting it if it was exported,
+// restoring its active low setting, direction, edge and, for an output, its
+// value, and unexporting it if it wasn't exported.  An output's direction
+// and level are set in one write, so it doesn't glitch.  Pins exported by
+// Restore are not unexported by gopherbone.Cleanup.  Every pin is restored
+// even if some fail, and the first error is returned.
+func (s *Snapshot) Restore() (err error) {
+	for _, state := range s.Pins {
+		if e := state.Restore(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Restore puts the pin back into the saved state.
+func (state PinState) Restore() (err error) {
+	_, statErr := os.Stat(fmt.Sprintf("%s/gpio%d", SysfsPath, state.Pin))
+	isExported := statErr == nil
+
+	if !state.Exported {
+		if isExported {
+			g := &GPIO{Pin: state.Pin}
+			err = g.Unexport()
+		}
+		return
+	}
+
+	g, err := Export(state.Pin)
+	if err != nil {
+		return
+	}
+	// The restored state should outlive the program
+	g.cleanup.Unregister()
+
+	if err = g.SetActiveLow(state.ActiveLow); err != nil {
+		return
+	}
+	switch state.Direction {
+	case Out:
+		// "high" and "low" set the direction and physical level together
+		level := "low"
+		if (state.Value != 0) != state.ActiveLow {
+			level = "high"
+		}
+		err = g.writeAttr("direction", level)
+	case In:
+		err = g.SetDirection(In)
+	default:
+		err = pinError("restore", state.Pin, fmt.Errorf("%w: %s", ErrInvalidDirection, state.Direction))
+	}
+	if err != nil || state.Edge == "" {
+		return
+	}
+	return g.SetEdge(state.Edge)
+}
+
+// writeAttr writes a string to one of the pin's sysfs attribute files.
+func (gpio *GPIO) writeAttr(attr, data string) (err error) {
+	f, err := openAttr(gpio.Pin, attr, os.O_WRONLY)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	start := time.Now()
+	_, err = f.WriteString(data)
+	traceAttr(start, gpio.Pin, attr, data, err)
+	return pinError("write "+attr, gpio.Pin, err)
+}
+
+// Save writes the snapshot to a file as JSON.
+func (s *Snapshot) Save(path string) (err error) {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// LoadSnapshot reads a snapshot written by Save.
+func LoadSnapshot(path string) (s *Snapshot, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	s = new(Snapshot)
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return
+}
@@ -0,0 +1,196 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/metrics"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ErrTimeout is returned when a measurement doesn't finish in time.
+var ErrTimeout = errors.New("Timed out")
+
+// An EdgeEvent is an edge on a pin and when it happened.
+type EdgeEvent struct {
+	// Value is the pin's value after the edge.
+	Value int
+	// Time is the time of the edge on the CLOCK_MONOTONIC clock, which
+	// counts from boot.  Only differences between times are meaningful.
+	Time time.Duration
+}
+
+// monotonic returns the current time on the CLOCK_MONOTONIC clock.
+func monotonic() time.Duration {
+	var ts syscall.Timespec
+	syscall.Syscall(syscall.SYS_CLOCK_GETTIME, 1, uintptr(unsafe.Pointer(&ts)), 0)
+	return time.Duration(ts.Nano())
+}
+
+// An EdgeWatcher delivers timestamped edges on its channel C.  Create one
+// with WatchEdges or OpenLineEvents.
+type EdgeWatcher struct {
+	C <-chan EdgeEvent
+
+	// Err holds the error which stopped the EdgeWatcher, if any.  It is
+	// valid once C has been closed.
+	Err error
+
+	c       chan EdgeEvent
+	stop    func()
+	cleanup func()
+}
+
+// WatchEdges sets the pin's edge and starts an EdgeWatcher which sends an
+// event each time the edge occurs.  The timestamps are taken as soon as the
+// kernel wakes the watching goroutine, so they are typically accurate to some
+// tens of microseconds, but can be late when the system is busy; for
+// timestamps taken by the kernel's interrupt handler, use OpenLineEvents.
+// Debouncing is not applied.
+func (gpio *GPIO) WatchEdges(edge Edge) (w *EdgeWatcher, err error) {
+	if edge == None {
+		err = fmt.Errorf("%w: %s", ErrInvalidEdge, edge)
+		return
+	}
+	if err = gpio.SetEdge(edge); err != nil {
+		return
+	}
+
+	p, err := newEdgePoller(gpio.Pin)
+	if err != nil {
+		return
+	}
+	w = &EdgeWatcher{c: make(chan EdgeEvent, 16), stop: p.interrupt, cleanup: p.close}
+	w.C = w.c
+
+	go func() {
+		defer close(w.c)
+		for {
+			_, err := p.wait(-1)
+			t := monotonic()
+			var value int
+			if err == nil {
+				// wait has already read the value file to rearm it
+				value, err = p.read()
+			}
+			if err != nil {
+				if err != errInterrupted {
+					w.Err = err
+				}
+				return
+			}
+			metrics.GPIOEdges.Inc()
+			w.c <- EdgeEvent{Value: value, Time: t}
+		}
+	}()
+
+	return
+}
+
+// Close stops the EdgeWatcher and closes its channel.
+func (w *EdgeWatcher) Close() (err error) {
+	w.stop()
+	// Drain the channel so that the sender can't block sending to it
+	for range w.C {
+	}
+	if w.cleanup != nil {
+		w.cleanup()
+	}
+
+	return
+}
+
+// next returns the next event, or ErrTimeout if none arrives before the
+// deadline.
+func (w *EdgeWatcher) next(deadline *time.Timer) (ev EdgeEvent, err error) {
+	select {
+	case e, ok := <-w.C:
+		if !ok {
+			err = w.Err
+			if err == nil {
+				err = errors.New("Edge watcher closed")
+			}
+			return
+		}
+		ev = e
+	case <-deadline.C:
+		err = ErrTimeout
+	}
+
+	return
+}
+
+// MeasurePulse waits for the next pulse at the given level on the watched pin
+// and returns its width, the time between the edge to that level and the
+// edge away from it.  The watcher must be watching Both edges.
+func (w *EdgeWatcher) MeasurePulse(level int, timeout time.Duration) (width time.Duration, err error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	var start EdgeEvent
+	for {
+		if start, err = w.next(deadline); err != nil {
+			return
+		}
+		if start.Value == level {
+			break
+		}
+	}
+	for {
+		var end EdgeEvent
+		if end, err = w.next(deadline); err != nil {
+			return
+		}
+		if end.Value != level {
+			return end.Time - start.Time, nil
+		}
+	}
+}
+
+// Frequency counts the edges arriving within the window and returns their
+// rate in hertz, timed from the first edge to the last.  At least two edges
+// are needed.  To measure a signal's frequency, watch just its Rising or
+// Falling edges.
+func (w *EdgeWatcher) Frequency(window time.Duration) (hz float64, err error) {
+	deadline := time.NewTimer(window)
+	defer deadline.Stop()
+
+	var first, last EdgeEvent
+	n := 0
+	for {
+		ev, e := w.next(deadline)
+		if e == ErrTimeout {
+			break
+		}
+		if e != nil {
+			err = e
+			return
+		}
+		if n == 0 {
+			first = ev
+		}
+		last = ev
+		n++
+	}
+
+	if n < 2 || last.Time == first.Time {
+		err = fmt.Errorf("Too few edges to measure frequency: %d", n)
+		return
+	}
+	hz = float64(n-1) / (last.Time - first.Time).Seconds()
+
+	return
+}
+
+// MeasurePulse watches both edges of the pin and returns the width of the
+// next pulse at the given level, as with EdgeWatcher.MeasurePulse.
+func (gpio *GPIO) MeasurePulse(level int, timeout time.Duration) (width time.Duration, err error) {
+	w, err := gpio.WatchEdges(Both)
+	if err != nil {
+		return
+	}
+	defer w.Close()
+
+	return w.MeasurePulse(level, timeout)
+}
@@ -0,0 +1,230 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package gps reads GPS modules which send NMEA sentences over a serial
+// port, as almost all do.  The GGA and RMC sentences are combined into a
+// Fix, and the latest is sent on a channel as it changes.  The update rate
+// of u-blox and MediaTek modules can also be set.
+package gps
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/Ratfink/gopherbone/uart"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultBaud is the baud rate most modules start at.
+const DefaultBaud = 9600
+
+// A Fix is the receiver's idea of where and when it is.
+type Fix struct {
+	Time time.Time
+	// Valid is false until the receiver has a fix.
+	Valid bool
+	// Latitude and Longitude are in degrees, negative to the south and
+	// west.
+	Latitude  float64
+	Longitude float64
+	// Altitude is above mean sea level, in metres.
+	Altitude float64
+	// Speed is over the ground, in metres per second.
+	Speed float64
+	// Course is over the ground, in degrees clockwise from true north.
+	Course float64
+	// Quality is as for GGA.
+	Quality    int
+	Satellites int
+	HDOP       float64
+}
+
+func (f Fix) String() string {
+	if !f.Valid {
+		return "no fix"
+	}
+	return fmt.Sprintf("%.6f,%.6f %.1fm %.1fm/s %.0f° (%d satellites)",
+		f.Latitude, f.Longitude, f.Altitude, f.Speed, f.Course, f.Satellites)
+}
+
+// A GPS reads fixes from a module, sending each updated fix on C.  A fix
+// is sent after each GGA or RMC sentence; if C isn't read in time the fix
+// waiting in it is replaced, so it is always the latest.
+type GPS struct {
+	C <-chan Fix
+
+	// Err holds the error which stopped the GPS, if any.  It is valid once
+	// C has been closed.
+	Err error
+
+	rw   io.ReadWriter
+	c    chan Fix
+	lock sync.Mutex
+	fix  Fix
+	done chan struct{}
+}
+
+// Open reads a module on UART n at the given baud rate, such as
+// DefaultBaud.
+func Open(n, baud int) (g *GPS, err error) {
+	u, err := uart.Open(n, baud)
+	if err != nil {
+		return
+	}
+	return New(u), nil
+}
+
+// New reads a module on rw, which is closed by Close if it is an
+// io.Closer.
+func New(rw io.ReadWriter) *GPS {
+	g := &GPS{rw: rw, c: make(chan Fix, 1), done: make(chan struct{})}
+	g.C = g.c
+	go g.run()
+	return g
+}
+
+func (g *GPS) run() {
+	defer close(g.done)
+	defer close(g.c)
+
+	scanner := bufio.NewScanner(g.rw)
+	for scanner.Scan() {
+		s, err := ParseSentence(scanner.Text())
+		if err != nil {
+			// Noise, binary replies and partial lines are skipped
+			continue
+		}
+		g.lock.Lock()
+		fix := g.fix
+		switch s.Type {
+		case "GGA":
+			gga, err := ParseGGA(s)
+			if err != nil {
+				g.lock.Unlock()
+				continue
+			}
+			fix.Latitude, fix.Longitude = gga.Latitude, gga.Longitude
+			fix.Altitude = gga.Altitude
+			fix.Quality, fix.Satellites, fix.HDOP = gga.Quality, gga.Satellites, gga.HDOP
+			if gga.Quality == 0 {
+				fix.Valid = false
+			}
+		case "RMC":
+			rmc, err := ParseRMC(s)
+			if err != nil {
+				g.lock.Unlock()
+				continue
+			}
+			fix.Time, fix.Valid = rmc.Time, rmc.Valid
+			fix.Latitude, fix.Longitude = rmc.Latitude, rmc.Longitude
+			fix.Speed, fix.Course = rmc.Speed, rmc.Course
+		default:
+			g.lock.Unlock()
+			continue
+		}
+		g.fix = fix
+		g.lock.Unlock()
+
+		select {
+		case <-g.c:
+		default:
+		}
+		g.c <- fix
+	}
+	g.Err = scanner.Err()
+}
+
+// Fix returns the latest fix.
+func (g *GPS) Fix() Fix {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.fix
+}
+
+// SendNMEA sends a sentence, adding the $, checksum and line ending to
+// body, such as "PMTK220,200".
+func (g *GPS) SendNMEA(body string) (err error) {
+	_, err = fmt.Fprintf(g.rw, "$%s*%02X\r\n", body, Checksum(body))
+	return
+}
+
+// SendUBX sends a u-blox UBX binary message.
+func (g *GPS) SendUBX(class, id byte, payload []byte) (err error) {
+	msg := []byte{0xb5, 0x62, class, id, byte(len(payload)), byte(len(payload) >> 8)}
+	msg = append(msg, payload...)
+	var a, b byte
+	for _, c := range msg[2:] {
+		a += c
+		b += a
+	}
+	_, err = g.rw.Write(append(msg, a, b))
+	return
+}
+
+// UBX message class and ID for setting the navigation rate.
+const (
+	UBX_CFG      = 0x06
+	UBX_CFG_RATE = 0x08
+)
+
+// rateMillis converts an update rate in hertz to a period in milliseconds.
+func rateMillis(hz float64) (ms int, err error) {
+	if hz <= 0 || hz > 40 {
+		err = fmt.Errorf("Invalid update rate: %gHz", hz)
+		return
+	}
+	ms = int(math.Round(1000 / hz))
+	return
+}
+
+// SetUBXRate sets the update rate of a u-blox module in hertz.  Rates above
+// 1Hz may need a higher baud rate to fit all the sentences in.
+func (g *GPS) SetUBXRate(hz float64) (err error) {
+	ms, err := rateMillis(hz)
+	if err != nil {
+		return
+	}
+	// Measurement period, navigation cycles per measurement, and time
+	// reference (1 = GPS time)
+	return g.SendUBX(UBX_CFG, UBX_CFG_RATE, []byte{byte(ms), byte(ms >> 8), 1, 0, 1, 0})
+}
+
+// SetMTKRate sets the update rate of a MediaTek module, such as those on
+// many breakout boards, in hertz.
+func (g *GPS) SetMTKRate(hz float64) (err error) {
+	ms, err := rateMillis(hz)
+	if err != nil {
+		return
+	}
+	return g.SendNMEA(fmt.Sprintf("PMTK220,%d", ms))
+}
+
+// Close stops reading the module and closes C.
+func (g *GPS) Close() (err error) {
+	if c, ok := g.rw.(io.Closer); ok {
+		err = c.Close()
+	}
+	<-g.done
+	return
+}
@@ -0,0 +1,228 @@
+package gps
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrChecksum is returned for a sentence whose checksum is wrong.
+	ErrChecksum = errors.New("NMEA checksum mismatch")
+	// ErrNotNMEA is returned for a line which isn't an NMEA sentence.
+	ErrNotNMEA = errors.New("Not an NMEA sentence")
+)
+
+// A Sentence is an NMEA sentence, such as
+// "$GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47".
+type Sentence struct {
+	// Talker identifies the system, such as "GP" for GPS or "GN" for
+	// several combined.
+	Talker string
+	// Type is the kind of sentence, such as "GGA".
+	Type   string
+	Fields []string
+}
+
+// Checksum returns the checksum of a sentence's body, the part between the
+// $ and the *.
+func Checksum(body string) (sum byte) {
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return
+}
+
+// ParseSentence parses a line as an NMEA sentence.  The checksum is
+// checked if there is one.
+func ParseSentence(line string) (s Sentence, err error) {
+	line = strings.TrimSpace(line)
+	if len(line) < 6 || line[0] != '$' {
+		return s, ErrNotNMEA
+	}
+	body := line[1:]
+	if i := strings.LastIndexByte(body, '*'); i >= 0 {
+		sum, e := strconv.ParseUint(body[i+1:], 16, 8)
+		if e != nil {
+			return s, fmt.Errorf("%w: %q", ErrNotNMEA, line)
+		}
+		body = body[:i]
+		if byte(sum) != Checksum(body) {
+			return s, ErrChecksum
+		}
+	}
+	fields := strings.Split(body, ",")
+	if len(fields[0]) < 3 {
+		return s, fmt.Errorf("%w: %q", ErrNotNMEA, line)
+	}
+	addr := fields[0]
+	if addr[0] == 'P' {
+		// Proprietary sentences have no talker
+		s.Type = addr
+	} else {
+		s.Talker, s.Type = addr[:2], addr[2:]
+	}
+	s.Fields = fields[1:]
+	return
+}
+
+// field returns field i, or "" if there aren't that many.
+func (s Sentence) field(i int) string {
+	if i < len(s.Fields) {
+		return s.Fields[i]
+	}
+	return ""
+}
+
+// parseFloat parses a number, taking an empty field to be zero.
+func parseFloat(f string) (v float64, err error) {
+	if f == "" {
+		return
+	}
+	return strconv.ParseFloat(f, 64)
+}
+
+// parseCoord parses a latitude or longitude in degrees and minutes, such as
+// "4807.038" and "N", into degrees, negative to the south and west.
+func parseCoord(f, hemisphere string) (deg float64, err error) {
+	if f == "" {
+		return
+	}
+	v, err := strconv.ParseFloat(f, 64)
+	if err != nil {
+		return
+	}
+	d := math.Floor(v / 100)
+	deg = d + (v-100*d)/60
+	switch hemisphere {
+	case "S", "W":
+		deg = -deg
+	case "N", "E":
+	default:
+		err = fmt.Errorf("Invalid hemisphere: %q", hemisphere)
+	}
+	return
+}
+
+// parseTime parses a time of day such as "123519.00" into the time since
+// midnight.
+func parseTime(f string) (t time.Duration, err error) {
+	if len(f) < 6 {
+		err = fmt.Errorf("Invalid time: %q", f)
+		return
+	}
+	h, e1 := strconv.Atoi(f[0:2])
+	m, e2 := strconv.Atoi(f[2:4])
+	s, e3 := strconv.ParseFloat(f[4:], 64)
+	if e1 != nil || e2 != nil || e3 != nil {
+		err = fmt.Errorf("Invalid time: %q", f)
+		return
+	}
+	t = time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s*float64(time.Second))
+	return
+}
+
+// A GGA is a fix's position and quality.
+type GGA struct {
+	// Time is the time of the fix since midnight UTC.
+	Time      time.Duration
+	Latitude  float64
+	Longitude float64
+	// Quality is 0 for no fix, 1 for GPS, 2 for differential GPS, and
+	// higher for other kinds of fix.
+	Quality    int
+	Satellites int
+	HDOP       float64
+	// Altitude is above mean sea level, in metres.
+	Altitude float64
+	// Geoid is the height of mean sea level above the WGS84 ellipsoid.
+	Geoid float64
+}
+
+// ParseGGA parses a GGA sentence.
+func ParseGGA(s Sentence) (g GGA, err error) {
+	if s.Type != "GGA" {
+		return g, fmt.Errorf("Not a GGA sentence: %s", s.Type)
+	}
+	if g.Time, err = parseTime(s.field(0)); err != nil {
+		return
+	}
+	if g.Latitude, err = parseCoord(s.field(1), s.field(2)); err != nil {
+		return
+	}
+	if g.Longitude, err = parseCoord(s.field(3), s.field(4)); err != nil {
+		return
+	}
+	if f := s.field(5); f != "" {
+		if g.Quality, err = strconv.Atoi(f); err != nil {
+			return
+		}
+	}
+	if f := s.field(6); f != "" {
+		if g.Satellites, err = strconv.Atoi(f); err != nil {
+			return
+		}
+	}
+	if g.HDOP, err = parseFloat(s.field(7)); err != nil {
+		return
+	}
+	if g.Altitude, err = parseFloat(s.field(8)); err != nil {
+		return
+	}
+	g.Geoid, err = parseFloat(s.field(10))
+	return
+}
+
+// Knot is a speed of one nautical mile per hour, in metres per second.
+const Knot = 1852.0 / 3600
+
+// An RMC is the recommended minimum fix: time, position and motion.
+type RMC struct {
+	Time time.Time
+	// Valid is false when the receiver has no fix, in which case the
+	// position and motion are meaningless.
+	Valid     bool
+	Latitude  float64
+	Longitude float64
+	// Speed is over the ground, in metres per second.
+	Speed float64
+	// Course is over the ground, in degrees clockwise from true north.
+	Course float64
+}
+
+// ParseRMC parses an RMC sentence.
+func ParseRMC(s Sentence) (r RMC, err error) {
+	if s.Type != "RMC" {
+		return r, fmt.Errorf("Not an RMC sentence: %s", s.Type)
+	}
+	tod, err := parseTime(s.field(0))
+	if err != nil {
+		return
+	}
+	r.Valid = s.field(1) == "A"
+	if r.Latitude, err = parseCoord(s.field(2), s.field(3)); err != nil {
+		return
+	}
+	if r.Longitude, err = parseCoord(s.field(4), s.field(5)); err != nil {
+		return
+	}
+	if r.Speed, err = parseFloat(s.field(6)); err != nil {
+		return
+	}
+	r.Speed *= Knot
+	if r.Course, err = parseFloat(s.field(7)); err != nil {
+		return
+	}
+	if date := s.field(8); date != "" {
+		d, e := time.Parse("020106", date)
+		if e != nil {
+			err = fmt.Errorf("Invalid date: %q", date)
+			return
+		}
+		r.Time = d.Add(tod)
+	}
+	return
+}
@@ -0,0 +1,58 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* The hal package names the small interfaces which GopherBone's drivers are
+ * written against, gathered in one place for anyone porting them to other
+ * hardware.  The sysfs and spidev implementations in the gpio, i2c, spi and
+ * pwm packages satisfy them, as do the fakes in the mock package; so could
+ * adapters for a Raspberry Pi or periph.io.
+ *
+ * The interfaces are aliases for those defined alongside their
+ * implementations, so a value of either type may be used as the other.
+ */
+package hal
+
+import (
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/i2c"
+	"github.com/Ratfink/gopherbone/pwm"
+	"github.com/Ratfink/gopherbone/spi"
+)
+
+// A DigitalPin is a GPIO which can be read, written and watched for edges.
+type DigitalPin = gpio.DigitalPin
+
+// An I2CBus is a connection to a single device on an I2C bus.
+type I2CBus = i2c.Conn
+
+// An SPIBus is a connection to a single device on an SPI bus.
+type SPIBus = spi.Conn
+
+// A PWMPin is a PWM output.
+type PWMPin = pwm.Channel
+
+var (
+	_ DigitalPin = (*gpio.GPIO)(nil)
+	_ I2CBus     = (*i2c.Device)(nil)
+	_ SPIBus     = (*spi.SPI)(nil)
+	_ PWMPin     = (*pwm.PWM)(nil)
+)
@@ -2,22 +2,32 @@ package i2c
 
 import (
 	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/metrics"
 	"os"
+	"runtime"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
 // as defined in /usr/include/linux/i2c-dev.h
 const (
 	I2C_SLAVE = 0x0703
+	I2C_RDWR  = 0x0707
 	I2C_SMBUS = 0x0720
 )
 
+// I2C_RDWR_MAX is the longest message i2c-dev accepts with I2C_RDWR.
+const I2C_RDWR_MAX = 8192
+
 // as defined in /usr/include/linux/i2c.h
 const (
 	I2C_SMBUS_WRITE            = 0
 	I2C_SMBUS_READ             = 1
+	I2C_SMBUS_QUICK            = 0
+	I2C_SMBUS_BYTE             = 1
 	I2C_SMBUS_I2C_BLOCK_BROKEN = 6
 	I2C_SMBUS_I2C_BLOCK_DATA   = 8
 	I2C_SMBUS_BLOCK_MAX        = 32
@@ -38,6 +48,22 @@ type i2c_smbus_ioctl_data struct {
 	data      uintptr
 }
 
+// as defined in /usr/include/linux/i2c.h
+const I2C_M_RD = 0x0001
+
+// as defined in /usr/include/linux/i2c.h and i2c-dev.h
+type i2c_msg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   uintptr
+}
+
+type i2c_rdwr_ioctl_data struct {
+	msgs  uintptr
+	nmsgs uint32
+}
+
 type Bus struct {
 	// i2c-dev file pointer
 	file *os.File
@@ -49,6 +75,18 @@ type Bus struct {
 	// simple bus access lock to ensure address
 	// set and data writes occur atomically
 	lock sync.Mutex
+	// bus number, and the number of NewBus calls
+	// not yet matched by Close, protected by
+	// busMapLock
+	num  byte
+	refs int
+	// retry policy for transfers which fail
+	// with EAGAIN
+	retries int
+	backoff time.Duration
+	// cleanup closes the file if the program exits without closing the
+	// bus
+	cleanup *gopherbone.Handle
 }
 
 // Returns an instance to an I2CBus.  If we already have an I2CBus
@@ -59,17 +97,66 @@ func NewBus(addr, bus byte) (i2cbus *Bus, err error) {
 	defer busMapLock.Unlock()
 
 	if i2cbus = busMap[bus]; i2cbus == nil {
-		i2cbus = new(Bus)
-		if i2cbus.file, err = os.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus), os.O_RDWR, os.ModeExclusive); err == nil {
+		i2cbus = &Bus{num: bus}
+		if i2cbus.file, err = gopherbone.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus), os.O_RDWR, os.ModeExclusive); err == nil {
 			busMap[bus] = i2cbus
+			i2cbus.cleanup = gopherbone.Register(fmt.Sprintf("I2C bus %d", bus), i2cbus.release)
 			err = i2cbus.SetAddress(addr)
 		}
 	}
+	if err == nil {
+		i2cbus.refs++
+	}
+
+	return
+}
+
+// Close releases a reference to the bus taken by NewBus.  The bus's file is
+// closed once every reference has been released, after which NewBus opens it
+// afresh.  Calling Close more times than NewBus has no effect.
+func (i2cbus *Bus) Close() (err error) {
+	busMapLock.Lock()
+	defer busMapLock.Unlock()
+
+	if i2cbus.refs == 0 {
+		return
+	}
+	if i2cbus.refs--; i2cbus.refs == 0 {
+		err = i2cbus.closeFile()
+	}
 
 	return
 }
 
+// release closes the bus whatever references remain, for gopherbone.Cleanup.
+func (i2cbus *Bus) release() (err error) {
+	busMapLock.Lock()
+	defer busMapLock.Unlock()
+
+	i2cbus.refs = 0
+	return i2cbus.closeFile()
+}
+
+// closeFile closes the bus's file and forgets it.  busMapLock must be held.
+func (i2cbus *Bus) closeFile() (err error) {
+	i2cbus.cleanup.Unregister()
+	delete(busMap, i2cbus.num)
+	i2cbus.lock.Lock()
+	err = i2cbus.file.Close()
+	i2cbus.lock.Unlock()
+
+	return
+}
+
+// SetAddress selects the slave address used by subsequent reads and writes.
 func (i2cbus *Bus) SetAddress(addr byte) (err error) {
+	i2cbus.lock.Lock()
+	defer i2cbus.lock.Unlock()
+
+	return i2cbus.setAddress(addr)
+}
+
+func (i2cbus *Bus) setAddress(addr byte) (err error) {
 	if addr != i2cbus.addr {
 		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, i2cbus.file.Fd(), I2C_SLAVE, uintptr(addr)); errno != 0 {
 			err = syscall.Errno(errno)
@@ -86,60 +173,215 @@ func (i2cbus *Bus) Read(reg byte, readLength byte) (list []byte, err error) {
 	i2cbus.lock.Lock()
 	defer i2cbus.lock.Unlock()
 
-	blockData := make([]byte, readLength+1)
-	blockData[0] = readLength
+	return i2cbus.read(reg, readLength)
+}
+
+func (i2cbus *Bus) Write(reg byte, list []byte) (err error) {
+	i2cbus.lock.Lock()
+	defer i2cbus.lock.Unlock()
+
+	return i2cbus.write(reg, list, I2C_SMBUS_I2C_BLOCK_DATA)
+}
+
+func (i2cbus *Bus) WriteI2C(reg byte, list []byte) (err error) {
+	i2cbus.lock.Lock()
+	defer i2cbus.lock.Unlock()
+
+	return i2cbus.write(reg, list, I2C_SMBUS_I2C_BLOCK_BROKEN)
+}
+
+// SetRetries makes transfers which fail with EAGAIN, as they do when
+// arbitration is lost to another master, be retried up to retries times.  The
+// first retry waits for backoff, and each one after that waits twice as long
+// as the last.  By default, transfers are not retried.  The policy applies to
+// every Device on the bus.
+func (i2cbus *Bus) SetRetries(retries int, backoff time.Duration) (err error) {
+	if retries < 0 {
+		err = fmt.Errorf("Invalid number of retries: %d", retries)
+		return
+	}
+
+	i2cbus.lock.Lock()
+	defer i2cbus.lock.Unlock()
+	i2cbus.retries, i2cbus.backoff = retries, backoff
+
+	return
+}
+
+// transfer performs an I2C_SMBUS or I2C_RDWR ioctl with the bus already
+// locked, retrying according to the bus's retry policy.
+func (i2cbus *Bus) transfer(req uintptr, arg unsafe.Pointer) (err error) {
+	backoff := i2cbus.backoff
+	for try := 0; ; try++ {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, i2cbus.file.Fd(), req, uintptr(arg))
+		if errno == 0 {
+			return nil
+		}
+		if errno != syscall.EAGAIN || try >= i2cbus.retries {
+			return transferError(errno)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
 
-	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
-		i2cbus.file.Fd(), I2C_SMBUS, uintptr(unsafe.Pointer(&i2c_smbus_ioctl_data{
-			readWrite: I2C_SMBUS_READ,
-			command:   reg,
-			size:      I2C_SMBUS_I2C_BLOCK_DATA,
-			data:      uintptr(unsafe.Pointer(&blockData[0]))}))); errno != 0 {
-		err = syscall.Errno(errno)
+// trace counts a transfer to addr which started at start, and traces it if a
+// tracer is set.  reg is the SMBus register or command byte, or -1.
+func (i2cbus *Bus) trace(start time.Time, op string, addr byte, reg int, w, r []byte, err error) {
+	metrics.ObserveI2C(start, err)
+	if !gopherbone.Tracing() {
+		return
 	}
+	gopherbone.Trace(gopherbone.TraceEvent{
+		Time:     start,
+		Kind:     gopherbone.TraceI2C,
+		Target:   fmt.Sprintf("i2c-%d 0x%02x", i2cbus.num, addr),
+		Op:       op,
+		Reg:      reg,
+		Write:    w,
+		Read:     r,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
+// read performs a block read with the bus already locked.  The kernel always
+// copies a whole i2c_smbus_data union, so the buffer must be that big however
+// few bytes are wanted.
+func (i2cbus *Bus) read(reg byte, readLength byte) (list []byte, err error) {
+	if readLength > I2C_SMBUS_BLOCK_MAX {
+		err = fmt.Errorf("Read too long: %d", readLength)
+		return
+	}
+	blockData := make([]byte, I2C_SMBUS_BLOCK_MAX+2)
+	blockData[0] = readLength
+
+	start := time.Now()
+	err = i2cbus.transfer(I2C_SMBUS, unsafe.Pointer(&i2c_smbus_ioctl_data{
+		readWrite: I2C_SMBUS_READ,
+		command:   reg,
+		size:      I2C_SMBUS_I2C_BLOCK_DATA,
+		data:      uintptr(unsafe.Pointer(&blockData[0]))}))
 
 	list = make([]byte, readLength)
 	copy(list, blockData[1:])
+	i2cbus.trace(start, "smbus read", i2cbus.addr, int(reg), nil, list, err)
 
 	return
 }
 
-func (i2cbus *Bus) Write(reg byte, list []byte) (err error) {
-	i2cbus.lock.Lock()
-	defer i2cbus.lock.Unlock()
-
-	blockData := make([]byte, len(list)+1)
+// write performs a block write of the given size with the bus already locked.
+func (i2cbus *Bus) write(reg byte, list []byte, size uint32) (err error) {
+	if len(list) > I2C_SMBUS_BLOCK_MAX {
+		err = fmt.Errorf("Write too long: %d", len(list))
+		return
+	}
+	blockData := make([]byte, I2C_SMBUS_BLOCK_MAX+2)
 	blockData[0] = byte(len(list))
 	copy(blockData[1:], list)
 
-	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
-		i2cbus.file.Fd(), I2C_SMBUS, uintptr(unsafe.Pointer(&i2c_smbus_ioctl_data{
-			readWrite: I2C_SMBUS_WRITE,
-			command:   reg,
-			size:      I2C_SMBUS_I2C_BLOCK_DATA,
-			data:      uintptr(unsafe.Pointer(&blockData[0]))}))); errno != 0 {
-		err = syscall.Errno(errno)
-	}
+	start := time.Now()
+	err = i2cbus.transfer(I2C_SMBUS, unsafe.Pointer(&i2c_smbus_ioctl_data{
+		readWrite: I2C_SMBUS_WRITE,
+		command:   reg,
+		size:      size,
+		data:      uintptr(unsafe.Pointer(&blockData[0]))}))
+	runtime.KeepAlive(blockData)
+	i2cbus.trace(start, "smbus write", i2cbus.addr, int(reg), list, nil, err)
 
 	return
 }
 
-func (i2cbus *Bus) WriteI2C(reg byte, list []byte) (err error) {
-	i2cbus.lock.Lock()
-	defer i2cbus.lock.Unlock()
+// readByte reads a single byte without sending a register address first,
+// with the bus already locked.
+func (i2cbus *Bus) readByte() (value byte, err error) {
+	blockData := make([]byte, I2C_SMBUS_BLOCK_MAX+2)
 
-	blockData := make([]byte, len(list)+1)
-	blockData[0] = byte(len(list))
-	copy(blockData[1:], list)
+	start := time.Now()
+	err = i2cbus.transfer(I2C_SMBUS, unsafe.Pointer(&i2c_smbus_ioctl_data{
+		readWrite: I2C_SMBUS_READ,
+		size:      I2C_SMBUS_BYTE,
+		data:      uintptr(unsafe.Pointer(&blockData[0]))}))
+	value = blockData[0]
+	i2cbus.trace(start, "smbus read byte", i2cbus.addr, -1, nil, blockData[:1], err)
+
+	return
+}
+
+// writeByte writes a single byte without a register address, with the bus
+// already locked.
+func (i2cbus *Bus) writeByte(value byte) (err error) {
+	start := time.Now()
+	err = i2cbus.transfer(I2C_SMBUS, unsafe.Pointer(&i2c_smbus_ioctl_data{
+		readWrite: I2C_SMBUS_WRITE,
+		command:   value,
+		size:      I2C_SMBUS_BYTE}))
+	i2cbus.trace(start, "smbus write byte", i2cbus.addr, -1, []byte{value}, nil, err)
+
+	return
+}
 
-	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
-		i2cbus.file.Fd(), I2C_SMBUS, uintptr(unsafe.Pointer(&i2c_smbus_ioctl_data{
-			readWrite: I2C_SMBUS_WRITE,
-			command:   reg,
-			size:      I2C_SMBUS_I2C_BLOCK_BROKEN,
-			data:      uintptr(unsafe.Pointer(&blockData[0]))}))); errno != 0 {
-		err = syscall.Errno(errno)
+// writeRaw writes data to addr as a single plain I2C message, with no
+// register address or length prefix added, with the bus already locked.
+// Unlike the SMBus transfers, a message may be up to I2C_RDWR_MAX bytes.
+func (i2cbus *Bus) writeRaw(addr byte, data []byte) (err error) {
+	if len(data) > I2C_RDWR_MAX {
+		err = fmt.Errorf("Write too long: %d", len(data))
+		return
+	}
+	if len(data) == 0 {
+		return
 	}
 
+	msg := i2c_msg{
+		addr: uint16(addr),
+		len:  uint16(len(data)),
+		buf:  uintptr(unsafe.Pointer(&data[0]))}
+	start := time.Now()
+	err = i2cbus.transfer(I2C_RDWR, unsafe.Pointer(&i2c_rdwr_ioctl_data{
+		msgs:  uintptr(unsafe.Pointer(&msg)),
+		nmsgs: 1}))
+	runtime.KeepAlive(&msg)
+	runtime.KeepAlive(data)
+	i2cbus.trace(start, "write", addr, -1, data, nil, err)
+
+	return
+}
+
+// tx writes w to addr and then reads len(r) bytes into r, with a repeated
+// start between them, with the bus already locked.  Either may be empty.
+func (i2cbus *Bus) tx(addr byte, w, r []byte) (err error) {
+	if len(w) > I2C_RDWR_MAX || len(r) > I2C_RDWR_MAX {
+		err = fmt.Errorf("Transfer too long: %d, %d", len(w), len(r))
+		return
+	}
+
+	var msgs []i2c_msg
+	if len(w) > 0 {
+		msgs = append(msgs, i2c_msg{
+			addr: uint16(addr),
+			len:  uint16(len(w)),
+			buf:  uintptr(unsafe.Pointer(&w[0]))})
+	}
+	if len(r) > 0 {
+		msgs = append(msgs, i2c_msg{
+			addr:  uint16(addr),
+			flags: I2C_M_RD,
+			len:   uint16(len(r)),
+			buf:   uintptr(unsafe.Pointer(&r[0]))})
+	}
+	if len(msgs) == 0 {
+		return
+	}
+
+	start := time.Now()
+	err = i2cbus.transfer(I2C_RDWR, unsafe.Pointer(&i2c_rdwr_ioctl_data{
+		msgs:  uintptr(unsafe.Pointer(&msgs[0])),
+		nmsgs: uint32(len(msgs))}))
+	runtime.KeepAlive(msgs)
+	runtime.KeepAlive(w)
+	runtime.KeepAlive(r)
+	i2cbus.trace(start, "tx", addr, -1, w, r, err)
+
 	return
 }
@@ -0,0 +1,175 @@
+package i2c
+
+// A Conn is a connection to a single slave, such as a Device.  Drivers which
+// use a Conn rather than a *Device can be given a fake one for testing.
+type Conn interface {
+	Read(reg byte, readLength byte) ([]byte, error)
+	Write(reg byte, list []byte) error
+	WriteI2C(reg byte, list []byte) error
+	ReadReg(reg byte) (byte, error)
+	WriteReg(reg byte, value byte) error
+	ReadByte() (byte, error)
+	WriteByte(value byte) error
+}
+
+// A RawWriter can write plain I2C messages of any length, as Device.WriteRaw
+// does.  Drivers which stream a lot of data may use it when their Conn
+// implements it.
+type RawWriter interface {
+	WriteRaw(data []byte) error
+}
+
+// A Transactor can write and then read plain I2C messages in one transaction,
+// as Device.Tx does.  Drivers for devices with 16-bit register addresses,
+// such as large EEPROMs, need one.
+type Transactor interface {
+	Tx(w, r []byte) error
+}
+
+var _ Conn = (*Device)(nil)
+var _ RawWriter = (*Device)(nil)
+var _ Transactor = (*Device)(nil)
+
+// A Device is a single slave on an I2C bus.  Several Devices may share a Bus;
+// each of their transactions selects the device's address and transfers its
+// data while holding the bus lock, so they can't be interleaved.
+type Device struct {
+	bus  *Bus
+	addr byte
+	// owned is set if the bus was opened for the Device and must be
+	// closed with it
+	owned bool
+}
+
+// NewDevice returns a Device for the slave at addr on the given bus number,
+// opening the bus if it isn't already open.
+func NewDevice(addr, bus byte) (dev *Device, err error) {
+	i2cbus, err := NewBus(addr, bus)
+	if err != nil {
+		return
+	}
+	dev = &Device{bus: i2cbus, addr: addr, owned: true}
+
+	return
+}
+
+// Device returns a Device for the slave at addr on an already open bus, so
+// that drivers for several devices can share one file descriptor and lock.
+func (i2cbus *Bus) Device(addr byte) *Device {
+	return &Device{bus: i2cbus, addr: addr}
+}
+
+// Close closes the bus if it was opened by NewDevice, as with Bus.Close.
+// Devices returned by Bus.Device leave the bus open.  Calling Close more than
+// once has no effect.
+func (dev *Device) Close() (err error) {
+	if dev.owned {
+		dev.owned = false
+		err = dev.bus.Close()
+	}
+
+	return
+}
+
+// Addr returns the device's slave address.
+func (dev *Device) Addr() byte {
+	return dev.addr
+}
+
+// Bus returns the bus the device is on.
+func (dev *Device) Bus() *Bus {
+	return dev.bus
+}
+
+// Read reads readLength consecutive registers, starting at reg.
+func (dev *Device) Read(reg byte, readLength byte) (list []byte, err error) {
+	dev.bus.lock.Lock()
+	defer dev.bus.lock.Unlock()
+
+	if err = dev.bus.setAddress(dev.addr); err != nil {
+		return
+	}
+	return dev.bus.read(reg, readLength)
+}
+
+// Write writes list to consecutive registers, starting at reg.
+func (dev *Device) Write(reg byte, list []byte) (err error) {
+	dev.bus.lock.Lock()
+	defer dev.bus.lock.Unlock()
+
+	if err = dev.bus.setAddress(dev.addr); err != nil {
+		return
+	}
+	return dev.bus.write(reg, list, I2C_SMBUS_I2C_BLOCK_DATA)
+}
+
+// WriteI2C is like Write, but uses the I2C_SMBUS_I2C_BLOCK_BROKEN transfer
+// size, as Bus.WriteI2C does.
+func (dev *Device) WriteI2C(reg byte, list []byte) (err error) {
+	dev.bus.lock.Lock()
+	defer dev.bus.lock.Unlock()
+
+	if err = dev.bus.setAddress(dev.addr); err != nil {
+		return
+	}
+	return dev.bus.write(reg, list, I2C_SMBUS_I2C_BLOCK_BROKEN)
+}
+
+// ReadReg reads a single register.
+func (dev *Device) ReadReg(reg byte) (value byte, err error) {
+	list, err := dev.Read(reg, 1)
+	if err != nil {
+		return
+	}
+	value = list[0]
+
+	return
+}
+
+// WriteReg writes a single register.
+func (dev *Device) WriteReg(reg byte, value byte) (err error) {
+	return dev.Write(reg, []byte{value})
+}
+
+// WriteRaw writes data as a single plain I2C message, without the register
+// address and 32 byte limit of Write.  It suits devices such as displays
+// which take long streams of data.
+func (dev *Device) WriteRaw(data []byte) (err error) {
+	dev.bus.lock.Lock()
+	defer dev.bus.lock.Unlock()
+
+	return dev.bus.writeRaw(dev.addr, data)
+}
+
+// Tx writes w and then reads len(r) bytes into r, with a repeated start
+// between them rather than a stop, so that nothing else on the bus can get
+// in between.  Either may be empty.
+func (dev *Device) Tx(w, r []byte) (err error) {
+	dev.bus.lock.Lock()
+	defer dev.bus.lock.Unlock()
+
+	return dev.bus.tx(dev.addr, w, r)
+}
+
+// ReadByte reads a single byte from a device which has no registers, such as
+// a PCF8574.
+func (dev *Device) ReadByte() (value byte, err error) {
+	dev.bus.lock.Lock()
+	defer dev.bus.lock.Unlock()
+
+	if err = dev.bus.setAddress(dev.addr); err != nil {
+		return
+	}
+	return dev.bus.readByte()
+}
+
+// WriteByte writes a single byte to a device which has no registers.
+func (dev *Device) WriteByte(value byte) (err error) {
+	dev.bus.lock.Lock()
+	defer dev.bus.lock.Unlock()
+
+	if err = dev.bus.setAddress(dev.addr); err != nil {
+		return
+	}
+	return dev.bus.writeByte(value)
+}
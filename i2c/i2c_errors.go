@@ -0,0 +1,21 @@
+package i2c
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// ErrNAK is returned when a device doesn't acknowledge a transfer, usually
+// because nothing is present at the address.  It may be matched with
+// errors.Is; the errno from the kernel is kept as well.
+var ErrNAK = errors.New("I2C device did not acknowledge")
+
+// transferError converts the errno from an I2C_SMBUS ioctl into an error.
+// Adapter drivers report a missing acknowledge as either ENXIO or EREMOTEIO.
+func transferError(errno syscall.Errno) error {
+	if errno == syscall.ENXIO || errno == syscall.EREMOTEIO {
+		return fmt.Errorf("%w: %w", ErrNAK, errno)
+	}
+	return errno
+}
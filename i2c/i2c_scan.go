@@ -0,0 +1,84 @@
+package i2c
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Scan probes every address from 0x03 to 0x77 on the given bus, as i2cdetect
+// does, and returns the addresses which responded.  Addresses claimed by a
+// kernel driver cannot be probed, but are included since a device is known to
+// be there.
+//
+// Like i2cdetect, most addresses are probed with an SMBus quick write, but
+// those commonly used by EEPROMs (0x30-0x37 and 0x50-0x5f) are probed with a
+// byte read instead, since a quick write can corrupt some of them.
+func Scan(bus byte) (addrs []byte, err error) {
+	f, err := gopherbone.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus), os.O_RDWR, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	for addr := byte(0x03); addr <= 0x77; addr++ {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), I2C_SLAVE, uintptr(addr)); errno != 0 {
+			if errno == syscall.EBUSY {
+				addrs = append(addrs, addr)
+				continue
+			}
+			err = syscall.Errno(errno)
+			return
+		}
+
+		if probe(f, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return
+}
+
+// Probe reports whether a device acknowledges addr on the given bus, probing
+// it as Scan does.  An address claimed by a kernel driver is taken to have a
+// device.
+func Probe(bus, addr byte) (found bool, err error) {
+	f, err := gopherbone.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus), os.O_RDWR, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), I2C_SLAVE, uintptr(addr)); errno != 0 {
+		if errno == syscall.EBUSY {
+			found = true
+			return
+		}
+		err = syscall.Errno(errno)
+		return
+	}
+	found = probe(f, addr)
+
+	return
+}
+
+// probe reports whether a device acknowledges the given address, which must
+// already have been selected with I2C_SLAVE.
+func probe(f *os.File, addr byte) bool {
+	var data [I2C_SMBUS_BLOCK_MAX + 2]byte
+	args := i2c_smbus_ioctl_data{
+		readWrite: I2C_SMBUS_WRITE,
+		size:      I2C_SMBUS_QUICK,
+	}
+	if (addr >= 0x30 && addr <= 0x37) || (addr >= 0x50 && addr <= 0x5f) {
+		args.readWrite = I2C_SMBUS_READ
+		args.size = I2C_SMBUS_BYTE
+		args.data = uintptr(unsafe.Pointer(&data[0]))
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), I2C_SMBUS, uintptr(unsafe.Pointer(&args)))
+
+	return errno == 0
+}
@@ -0,0 +1,439 @@
+package i2c
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/metrics"
+	"sync"
+	"time"
+)
+
+// ErrStretchTimeout is returned when a slave holds SCL low for longer than a
+// SoftBus's stretch timeout.
+var ErrStretchTimeout = errors.New("I2C clock stretched too long")
+
+var _ Conn = (*SoftDevice)(nil)
+var _ RawWriter = (*SoftDevice)(nil)
+var _ Transactor = (*SoftDevice)(nil)
+
+// A SoftBus is an I2C master bit-banged on two GPIO pins, for when the
+// hardware buses' pins are taken or a device needs a bus of its own.  Both
+// lines need pull-up resistors.  They are driven open drain: a line is pulled
+// low by making it an output (which sysfs initialises low) and released by
+// making it an input.  Slaves which stretch the clock are waited for.
+//
+// The clock rate is a maximum.  Through sysfs each line change takes some
+// tens of microseconds, so the bus runs at a few kHz whatever rate is asked
+// for.  Only a single master is supported.
+type SoftBus struct {
+	scl, sda gpio.DigitalPin
+	lock     sync.Mutex
+	// half of the clock period
+	half time.Duration
+	// longest time to wait for a slave to release SCL
+	stretch time.Duration
+	// set if the pins were exported by NewSoftBus and must be unexported
+	// by Close
+	owned bool
+}
+
+// NewSoftBus exports the given SCL and SDA pins and returns a SoftBus using
+// them, with a clock rate of at most hz.
+func NewSoftBus(sclPin, sdaPin int, hz int) (bus *SoftBus, err error) {
+	scl, err := gpio.Export(sclPin)
+	if err != nil {
+		return
+	}
+	sda, err := gpio.Export(sdaPin)
+	if err != nil {
+		scl.Unexport()
+		return
+	}
+
+	if bus, err = NewSoftBusPins(scl, sda, hz); err != nil {
+		scl.Unexport()
+		sda.Unexport()
+		return
+	}
+	bus.owned = true
+
+	return
+}
+
+// NewSoftBusPins returns a SoftBus using pins which are already exported.
+// If a slave was left holding SDA low, for instance by a transfer which was
+// interrupted, the bus is clocked until it lets go.
+func NewSoftBusPins(scl, sda gpio.DigitalPin, hz int) (bus *SoftBus, err error) {
+	if hz <= 0 {
+		err = fmt.Errorf("Invalid clock rate: %d", hz)
+		return
+	}
+	bus = &SoftBus{
+		scl:     scl,
+		sda:     sda,
+		half:    time.Second / time.Duration(2*hz),
+		stretch: 10 * time.Millisecond,
+	}
+
+	if err = bus.recover(); err != nil {
+		bus = nil
+	}
+
+	return
+}
+
+// SetStretchTimeout sets how long to wait for a slave which is stretching
+// the clock before giving up with ErrStretchTimeout.  The default is 10ms.
+func (bus *SoftBus) SetStretchTimeout(d time.Duration) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	bus.stretch = d
+}
+
+// Close unexports the pins if they were exported by NewSoftBus.
+func (bus *SoftBus) Close() (err error) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	if !bus.owned {
+		return
+	}
+	bus.owned = false
+	err = bus.scl.Unexport()
+	if e := bus.sda.Unexport(); err == nil {
+		err = e
+	}
+
+	return
+}
+
+// Device returns a SoftDevice for the slave at addr on the bus.
+func (bus *SoftBus) Device(addr byte) *SoftDevice {
+	return &SoftDevice{bus: bus, addr: addr}
+}
+
+// delay waits for half a clock period.  The periods are far too short for
+// time.Sleep, so it spins.
+func (bus *SoftBus) delay() {
+	for start := time.Now(); time.Since(start) < bus.half; {
+	}
+}
+
+func (bus *SoftBus) low(pin gpio.DigitalPin) error {
+	return pin.SetDirection(gpio.Out)
+}
+
+func (bus *SoftBus) release(pin gpio.DigitalPin) error {
+	return pin.SetDirection(gpio.In)
+}
+
+// sclHigh releases SCL and waits for it to go high, in case a slave is
+// stretching the clock.
+func (bus *SoftBus) sclHigh() (err error) {
+	if err = bus.release(bus.scl); err != nil {
+		return
+	}
+	deadline := time.Now().Add(bus.stretch)
+	for {
+		var v int
+		if v, err = bus.scl.Value(); err != nil || v != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			err = ErrStretchTimeout
+			break
+		}
+	}
+	bus.delay()
+
+	return
+}
+
+// recover clocks the bus until SDA is released, then sends a stop.
+func (bus *SoftBus) recover() (err error) {
+	if err = bus.release(bus.sda); err != nil {
+		return
+	}
+	if err = bus.sclHigh(); err != nil {
+		return
+	}
+	for i := 0; i < 9; i++ {
+		var v int
+		if v, err = bus.sda.Value(); err != nil || v != 0 {
+			break
+		}
+		if err = bus.low(bus.scl); err != nil {
+			return
+		}
+		bus.delay()
+		if err = bus.sclHigh(); err != nil {
+			return
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	return bus.stop()
+}
+
+// start sends a start condition, or a repeated start if the bus is already
+// held.  SCL is left low.
+func (bus *SoftBus) start() (err error) {
+	if err = bus.release(bus.sda); err != nil {
+		return
+	}
+	if err = bus.sclHigh(); err != nil {
+		return
+	}
+	if err = bus.low(bus.sda); err != nil {
+		return
+	}
+	bus.delay()
+	return bus.low(bus.scl)
+}
+
+// stop sends a stop condition, leaving both lines released.
+func (bus *SoftBus) stop() (err error) {
+	if err = bus.low(bus.scl); err != nil {
+		return
+	}
+	if err = bus.low(bus.sda); err != nil {
+		return
+	}
+	bus.delay()
+	if err = bus.sclHigh(); err != nil {
+		return
+	}
+	if err = bus.release(bus.sda); err != nil {
+		return
+	}
+	bus.delay()
+
+	return
+}
+
+// writeBit clocks out one bit; SCL starts and ends low.
+func (bus *SoftBus) writeBit(bit bool) (err error) {
+	if bit {
+		err = bus.release(bus.sda)
+	} else {
+		err = bus.low(bus.sda)
+	}
+	if err != nil {
+		return
+	}
+	bus.delay()
+	if err = bus.sclHigh(); err != nil {
+		return
+	}
+	return bus.low(bus.scl)
+}
+
+// readBit releases SDA and clocks in one bit; SCL starts and ends low.
+func (bus *SoftBus) readBit() (bit bool, err error) {
+	if err = bus.release(bus.sda); err != nil {
+		return
+	}
+	bus.delay()
+	if err = bus.sclHigh(); err != nil {
+		return
+	}
+	v, err := bus.sda.Value()
+	if err != nil {
+		return
+	}
+	bit = v != 0
+
+	return bit, bus.low(bus.scl)
+}
+
+// writeByte clocks out a byte, most significant bit first, and returns
+// ErrNAK if the slave doesn't acknowledge it.
+func (bus *SoftBus) writeByte(b byte) (err error) {
+	for i := 7; i >= 0; i-- {
+		if err = bus.writeBit(b&(1<<uint(i)) != 0); err != nil {
+			return
+		}
+	}
+	nak, err := bus.readBit()
+	if err == nil && nak {
+		err = ErrNAK
+	}
+
+	return
+}
+
+// readByte clocks in a byte, then acknowledges it unless it is the last one
+// wanted.
+func (bus *SoftBus) readByte(last bool) (b byte, err error) {
+	for i := 0; i < 8; i++ {
+		var bit bool
+		if bit, err = bus.readBit(); err != nil {
+			return
+		}
+		b <<= 1
+		if bit {
+			b |= 1
+		}
+	}
+	err = bus.writeBit(last)
+
+	return
+}
+
+// transfer sends a start, the address and data to write, then if n is
+// non-zero a repeated start and n bytes read back, and finally a stop.
+// Either part may be empty.  The bus must be locked.
+func (bus *SoftBus) transfer(addr byte, w []byte, n int) (r []byte, err error) {
+	start := time.Now()
+	defer func() {
+		if e := bus.stop(); err == nil {
+			err = e
+		}
+		metrics.ObserveI2C(start, err)
+		if gopherbone.Tracing() {
+			gopherbone.Trace(gopherbone.TraceEvent{
+				Time:     start,
+				Kind:     gopherbone.TraceI2C,
+				Target:   fmt.Sprintf("soft i2c 0x%02x", addr),
+				Op:       "tx",
+				Reg:      -1,
+				Write:    w,
+				Read:     r,
+				Duration: time.Since(start),
+				Err:      err,
+			})
+		}
+	}()
+
+	if len(w) > 0 || n == 0 {
+		if err = bus.start(); err != nil {
+			return
+		}
+		if err = bus.writeByte(addr << 1); err != nil {
+			err = fmt.Errorf("%w: address %#02x", err, addr)
+			return
+		}
+		for _, b := range w {
+			if err = bus.writeByte(b); err != nil {
+				return
+			}
+		}
+	}
+
+	if n > 0 {
+		if err = bus.start(); err != nil {
+			return
+		}
+		if err = bus.writeByte(addr<<1 | 1); err != nil {
+			err = fmt.Errorf("%w: address %#02x", err, addr)
+			return
+		}
+		r = make([]byte, n)
+		for i := range r {
+			if r[i], err = bus.readByte(i == n-1); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// A SoftDevice is a single slave on a SoftBus.  It implements Conn, so
+// drivers may be given one in place of a Device.
+type SoftDevice struct {
+	bus  *SoftBus
+	addr byte
+}
+
+func (dev *SoftDevice) transfer(w []byte, n int) (r []byte, err error) {
+	dev.bus.lock.Lock()
+	defer dev.bus.lock.Unlock()
+
+	return dev.bus.transfer(dev.addr, w, n)
+}
+
+// Addr returns the device's slave address.
+func (dev *SoftDevice) Addr() byte {
+	return dev.addr
+}
+
+// Read reads readLength consecutive registers, starting at reg.
+func (dev *SoftDevice) Read(reg byte, readLength byte) (list []byte, err error) {
+	if readLength == 0 {
+		return []byte{}, nil
+	}
+	return dev.transfer([]byte{reg}, int(readLength))
+}
+
+// Write writes list to consecutive registers, starting at reg.  Unlike
+// Device.Write, there is no limit on its length.
+func (dev *SoftDevice) Write(reg byte, list []byte) (err error) {
+	_, err = dev.transfer(append([]byte{reg}, list...), 0)
+	return
+}
+
+// WriteI2C is the same as Write; the distinction only matters to the
+// kernel's SMBus emulation.
+func (dev *SoftDevice) WriteI2C(reg byte, list []byte) (err error) {
+	return dev.Write(reg, list)
+}
+
+// ReadReg reads a single register.
+func (dev *SoftDevice) ReadReg(reg byte) (value byte, err error) {
+	list, err := dev.Read(reg, 1)
+	if err != nil {
+		return
+	}
+	value = list[0]
+
+	return
+}
+
+// WriteReg writes a single register.
+func (dev *SoftDevice) WriteReg(reg byte, value byte) (err error) {
+	return dev.Write(reg, []byte{value})
+}
+
+// WriteRaw writes data as a single plain I2C message.
+func (dev *SoftDevice) WriteRaw(data []byte) (err error) {
+	if len(data) == 0 {
+		return
+	}
+	_, err = dev.transfer(data, 0)
+	return
+}
+
+// Tx writes w and then reads len(r) bytes into r, with a repeated start
+// between them.  Either may be empty.
+func (dev *SoftDevice) Tx(w, r []byte) (err error) {
+	if len(w) == 0 && len(r) == 0 {
+		return
+	}
+	list, err := dev.transfer(w, len(r))
+	copy(r, list)
+
+	return
+}
+
+// ReadByte reads a single byte from a device which has no registers.
+func (dev *SoftDevice) ReadByte() (value byte, err error) {
+	list, err := dev.transfer(nil, 1)
+	if err != nil {
+		return
+	}
+	value = list[0]
+
+	return
+}
+
+// WriteByte writes a single byte to a device which has no registers.
+func (dev *SoftDevice) WriteByte(value byte) (err error) {
+	_, err = dev.transfer([]byte{value}, 0)
+	return
+}
@@ -0,0 +1,26 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// Speed returns the clock frequency of the given bus in hertz, as set by the
+// device tree.  The speed can't be changed through i2c-dev; to use 400kHz fast
+// mode, set the controller's clock-frequency property with an overlay, such
+// as the one capemgr.SetI2CSpeed builds.
+func Speed(bus byte) (hz uint32, err error) {
+	path := fmt.Sprintf("/sys/bus/i2c/devices/i2c-%d/of_node/clock-frequency", bus)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if len(data) != 4 {
+		err = fmt.Errorf("Bad length of %s: %d", path, len(data))
+		return
+	}
+	hz = binary.BigEndian.Uint32(data)
+
+	return
+}
@@ -0,0 +1,249 @@
+package input
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// A Type is the kind of an Event.
+type Type int
+
+// Event types.  Press, Release and Repeat are sent for keys and buttons,
+// with the key code in Code; Repeat is sent while a key is held down, by
+// devices with autorepeat enabled, and for a long press of a GPIO button.
+// Move is sent for relative motion, such as turning a rotary encoder, with
+// the axis in Code and the distance in Value.  Absolute is sent when an
+// absolute axis other than a touchscreen's position changes, with its new
+// value.  Touch is sent when a touchscreen is touched and as the touch moves,
+// and Lift when it ends, both with the position in X and Y and BTN_TOUCH in
+// Code.
+const (
+	Press Type = iota
+	Release
+	Repeat
+	Move
+	Absolute
+	Touch
+	Lift
+)
+
+func (t Type) String() string {
+	switch t {
+	case Press:
+		return "Press"
+	case Release:
+		return "Release"
+	case Repeat:
+		return "Repeat"
+	case Move:
+		return "Move"
+	case Absolute:
+		return "Absolute"
+	case Touch:
+		return "Touch"
+	case Lift:
+		return "Lift"
+	}
+	return "Unknown"
+}
+
+// An Event is a normalized input event.
+type Event struct {
+	// Source is the name of the device which sent the event.
+	Source string
+	Type   Type
+	// Code is the key or axis, one of the KEY_, BTN_, REL_ or ABS_
+	// constants.
+	Code  uint16
+	Value int
+	// X and Y are the position of a Touch or Lift.
+	X, Y int
+	Time time.Time
+}
+
+// scale maps the range of an absolute axis onto size pixels, reversed if
+// size is negative.
+type scale struct {
+	min, max int
+	size     int
+}
+
+func newScale(info AbsInfo, size int) *scale {
+	return &scale{min: int(info.Minimum), max: int(info.Maximum), size: size}
+}
+
+func (s *scale) apply(v int) int {
+	if s == nil || s.max <= s.min {
+		return v
+	}
+	if v < s.min {
+		v = s.min
+	} else if v > s.max {
+		v = s.max
+	}
+	if s.size < 0 {
+		return (s.max - v) * (-s.size - 1) / (s.max - s.min)
+	}
+	return (v - s.min) * (s.size - 1) / (s.max - s.min)
+}
+
+// A reader decodes the events read from a device.  Touchscreen events are
+// collected until the end of each report, so that a touch is sent once with
+// both coordinates.
+type reader struct {
+	source string
+	touch  bool
+	c      chan<- Event
+	stop   <-chan struct{}
+
+	lock   sync.Mutex
+	sx, sy *scale
+
+	x, y     int
+	slot     int
+	touching bool
+	touched  bool
+	moved    bool
+	dropping bool
+}
+
+func (rd *reader) setScale(sx, sy *scale) {
+	rd.lock.Lock()
+	rd.sx, rd.sy = sx, sy
+	rd.lock.Unlock()
+}
+
+func (rd *reader) run(r io.Reader) (err error) {
+	buf := make([]byte, 64*eventSize)
+	for {
+		var n int
+		if n, err = r.Read(buf); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return
+		}
+		for i := 0; i+int(eventSize) <= n; i += int(eventSize) {
+			if !rd.handle(decode(buf[i : i+int(eventSize)])) {
+				return
+			}
+		}
+	}
+}
+
+// handle handles one event, returning false if the device is being closed.
+func (rd *reader) handle(t time.Time, typ, code uint16, value int32) bool {
+	ev := Event{Source: rd.source, Code: code, Value: int(value), Time: t}
+
+	if typ == EV_SYN {
+		switch code {
+		case SYN_DROPPED:
+			// Events were lost; ignore the rest of the report
+			rd.dropping = true
+		case SYN_REPORT:
+			if rd.dropping {
+				rd.dropping = false
+				rd.touched, rd.moved = false, false
+				return true
+			}
+			return rd.report(ev)
+		}
+		return true
+	}
+	if rd.dropping {
+		return true
+	}
+
+	switch typ {
+	case EV_KEY:
+		if rd.touch && code == BTN_TOUCH {
+			rd.touching = value != 0
+			rd.touched = true
+			return true
+		}
+		switch value {
+		case 0:
+			ev.Type = Release
+		case 1:
+			ev.Type = Press
+		default:
+			ev.Type = Repeat
+		}
+	case EV_REL:
+		ev.Type = Move
+	case EV_ABS:
+		if rd.touch && rd.position(code, int(value)) {
+			return true
+		}
+		ev.Type = Absolute
+	default:
+		return true
+	}
+
+	return rd.send(ev)
+}
+
+// position records a change to a touchscreen's position axes, returning
+// false if code is some other axis.  Only the first touch of a multi-touch
+// screen is followed.
+func (rd *reader) position(code uint16, value int) bool {
+	switch code {
+	case ABS_MT_SLOT:
+		rd.slot = value
+	case ABS_X:
+		rd.x, rd.moved = value, true
+	case ABS_Y:
+		rd.y, rd.moved = value, true
+	case ABS_MT_POSITION_X:
+		if rd.slot == 0 {
+			rd.x, rd.moved = value, true
+		}
+	case ABS_MT_POSITION_Y:
+		if rd.slot == 0 {
+			rd.y, rd.moved = value, true
+		}
+	case ABS_MT_TRACKING_ID:
+		if rd.slot == 0 {
+			rd.touching = value >= 0
+			rd.touched = true
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// report sends the touch, if any, completed by the end of a report.
+func (rd *reader) report(ev Event) bool {
+	if !rd.touch {
+		return true
+	}
+	touched, moved := rd.touched, rd.moved
+	rd.touched, rd.moved = false, false
+
+	switch {
+	case touched && !rd.touching:
+		ev.Type = Lift
+	case rd.touching && (touched || moved):
+		ev.Type = Touch
+	default:
+		return true
+	}
+	rd.lock.Lock()
+	ev.X, ev.Y = rd.sx.apply(rd.x), rd.sy.apply(rd.y)
+	rd.lock.Unlock()
+	ev.Code, ev.Value = BTN_TOUCH, 0
+
+	return rd.send(ev)
+}
+
+func (rd *reader) send(ev Event) bool {
+	select {
+	case rd.c <- ev:
+		return true
+	case <-rd.stop:
+		return false
+	}
+}
@@ -0,0 +1,384 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package input reads Linux input devices, /dev/input/event*, such as USB
+// keypads, buttons and rotary encoders registered with the gpio-keys and
+// rotary-encoder device tree overlays, and touchscreens.  Their events are
+// normalized into key presses, relative motion and touches, and GPIO buttons
+// and encoders read with the button and encoder packages can be adapted to
+// send the same events, so that user interface code needn't care which kind
+// of input it is given.
+package input
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// DevPath is the directory holding the event devices.
+var DevPath = "/dev/input"
+
+// Event types, as defined in /usr/include/linux/input-event-codes.h
+const (
+	EV_SYN = 0x00
+	EV_KEY = 0x01
+	EV_REL = 0x02
+	EV_ABS = 0x03
+)
+
+// Synchronization codes
+const (
+	SYN_REPORT  = 0
+	SYN_DROPPED = 3
+)
+
+// Some key and button codes.  Keypads and gpio-keys overlays may send any
+// other code from input-event-codes.h.
+const (
+	KEY_ESC       = 1
+	KEY_1         = 2
+	KEY_2         = 3
+	KEY_3         = 4
+	KEY_4         = 5
+	KEY_5         = 6
+	KEY_6         = 7
+	KEY_7         = 8
+	KEY_8         = 9
+	KEY_9         = 10
+	KEY_0         = 11
+	KEY_BACKSPACE = 14
+	KEY_ENTER     = 28
+	KEY_SPACE     = 57
+	KEY_KPENTER   = 96
+	KEY_HOME      = 102
+	KEY_UP        = 103
+	KEY_LEFT      = 105
+	KEY_RIGHT     = 106
+	KEY_DOWN      = 108
+	KEY_POWER     = 116
+	KEY_MENU      = 139
+	KEY_BACK      = 158
+	KEY_OK        = 0x160
+	KEY_SELECT    = 0x161
+	BTN_0         = 0x100
+	BTN_LEFT      = 0x110
+	BTN_RIGHT     = 0x111
+	BTN_MIDDLE    = 0x112
+	BTN_TOUCH     = 0x14a
+	KEY_MAX       = 0x2ff
+)
+
+// Relative axes
+const (
+	REL_X      = 0x00
+	REL_Y      = 0x01
+	REL_HWHEEL = 0x06
+	REL_DIAL   = 0x07
+	REL_WHEEL  = 0x08
+)
+
+// Absolute axes
+const (
+	ABS_X              = 0x00
+	ABS_Y              = 0x01
+	ABS_PRESSURE       = 0x18
+	ABS_MT_SLOT        = 0x2f
+	ABS_MT_POSITION_X  = 0x35
+	ABS_MT_POSITION_Y  = 0x36
+	ABS_MT_TRACKING_ID = 0x39
+	ABS_MAX            = 0x3f
+)
+
+// ioctl requests, as defined in /usr/include/linux/input.h
+const (
+	EVIOCGRAB = 0x40044590
+)
+
+// eviocg returns the request for one of the variable length EVIOCG ioctls,
+// which read size bytes.
+func eviocg(nr, size uintptr) uintptr {
+	return 2<<30 | size<<16 | 'E'<<8 | nr
+}
+
+func eviocgname(size uintptr) uintptr    { return eviocg(0x06, size) }
+func eviocgbit(ev, size uintptr) uintptr { return eviocg(0x20+ev, size) }
+func eviocgabs(abs uintptr) uintptr      { return eviocg(0x40+abs, unsafe.Sizeof(AbsInfo{})) }
+
+// eventSize is the size of struct input_event: a struct timeval of two
+// longs, then a 16-bit type and code and a 32-bit value.
+const eventSize = 2*unsafe.Sizeof(uintptr(0)) + 8
+
+// ErrNotInput is returned by Open for a file which isn't an input device.
+var ErrNotInput = errors.New("Not an input device")
+
+// ErrNotFound is returned by Find when no device has the given name.
+var ErrNotFound = errors.New("Input device not found")
+
+// AbsInfo describes an absolute axis, as struct input_absinfo.
+type AbsInfo struct {
+	Value      int32
+	Minimum    int32
+	Maximum    int32
+	Fuzz       int32
+	Flat       int32
+	Resolution int32
+}
+
+// A Device is an open input device, sending its events on C.  C is closed
+// when the device is closed or unplugged, after which Err holds the error
+// that stopped it, if any.
+type Device struct {
+	C   <-chan Event
+	Err error
+
+	// Name is the name the driver gives the device, such as "gpio-keys".
+	Name string
+	// Path is the device's event file.
+	Path string
+
+	file   *os.File
+	reader *reader
+	done   chan struct{}
+	stop   chan struct{}
+}
+
+var _ Source = (*Device)(nil)
+
+// Info names an input device, as returned by List.
+type Info struct {
+	Path string
+	Name string
+}
+
+// List returns the input devices the program may open, in order of their
+// event numbers.
+func List() (devices []Info, err error) {
+	paths, err := filepath.Glob(DevPath + "/event*")
+	if err != nil {
+		return
+	}
+	for _, path := range sortEvents(paths) {
+		f, e := os.Open(path)
+		if e != nil {
+			continue
+		}
+		name, e := ioctlString(f, eviocgname)
+		f.Close()
+		if e != nil {
+			continue
+		}
+		devices = append(devices, Info{Path: path, Name: name})
+	}
+	return
+}
+
+// sortEvents sorts event device paths by number, so that event10 follows
+// event9.
+func sortEvents(paths []string) []string {
+	num := func(path string) (n int) {
+		fmt.Sscanf(strings.TrimPrefix(filepath.Base(path), "event"), "%d", &n)
+		return
+	}
+	for i := 1; i < len(paths); i++ {
+		for j := i; j > 0 && num(paths[j]) < num(paths[j-1]); j-- {
+			paths[j], paths[j-1] = paths[j-1], paths[j]
+		}
+	}
+	return paths
+}
+
+// Find opens the first input device whose name is name, as reported by
+// List.  Event numbers depend on the order devices were probed in, so this
+// is more dependable than opening a path.
+func Find(name string) (dev *Device, err error) {
+	devices, err := List()
+	if err != nil {
+		return
+	}
+	for _, info := range devices {
+		if info.Name == name {
+			return Open(info.Path)
+		}
+	}
+	err = fmt.Errorf("%w: %q", ErrNotFound, name)
+	return
+}
+
+// Open opens the input device at path, such as /dev/input/event0, and
+// starts reading its events.
+func Open(path string) (dev *Device, err error) {
+	// A non-blocking file is read through the runtime's poller, so closing
+	// it wakes a blocked Read
+	f, err := gopherbone.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return
+	}
+
+	name, err := ioctlString(f, eviocgname)
+	if err != nil {
+		f.Close()
+		err = fmt.Errorf("%w: %s: %v", ErrNotInput, path, err)
+		return
+	}
+
+	dev = &Device{Name: name, Path: path, file: f}
+	touch := dev.HasKey(BTN_TOUCH) || dev.HasAxis(ABS_MT_POSITION_X)
+	dev.start(f, touch)
+	return
+}
+
+func (dev *Device) start(r io.Reader, touch bool) {
+	c := make(chan Event, 16)
+	dev.C = c
+	dev.done = make(chan struct{})
+	dev.stop = make(chan struct{})
+	dev.reader = &reader{source: dev.Name, touch: touch, c: c, stop: dev.stop}
+
+	go func() {
+		defer close(dev.done)
+		defer close(c)
+		if err := dev.reader.run(r); err != nil && !errors.Is(err, os.ErrClosed) {
+			dev.Err = fmt.Errorf("Reading %s: %w", dev.Path, err)
+		}
+	}()
+}
+
+// Events returns C.
+func (dev *Device) Events() <-chan Event {
+	return dev.C
+}
+
+// Close stops reading the device and closes C.
+func (dev *Device) Close() (err error) {
+	err = dev.file.Close()
+	close(dev.stop)
+	<-dev.done
+	return
+}
+
+// Grab gives the program exclusive use of the device, so that for example
+// keypresses on a USB keypad don't also reach the console, or releases it if
+// grab is false.
+func (dev *Device) Grab(grab bool) (err error) {
+	var arg uintptr
+	if grab {
+		arg = 1
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dev.file.Fd(), EVIOCGRAB, arg); errno != 0 {
+		err = errno
+	}
+	return
+}
+
+// HasKey returns whether the device can send the key or button code.
+func (dev *Device) HasKey(code uint16) bool {
+	return dev.hasBit(EV_KEY, KEY_MAX, uint(code))
+}
+
+// HasAxis returns whether the device has the absolute axis.
+func (dev *Device) HasAxis(axis uint16) bool {
+	return dev.hasBit(EV_ABS, ABS_MAX, uint(axis))
+}
+
+func (dev *Device) hasBit(ev, max, bit uint) bool {
+	bits := make([]byte, max/8+1)
+	if dev.ioctl(eviocgbit(uintptr(ev), uintptr(len(bits))), unsafe.Pointer(&bits[0])) != nil {
+		return false
+	}
+	return bit <= max && bits[bit/8]&(1<<(bit%8)) != 0
+}
+
+// AbsInfo returns the current value and range of an absolute axis.
+func (dev *Device) AbsInfo(axis uint16) (info AbsInfo, err error) {
+	err = dev.ioctl(eviocgabs(uintptr(axis)), unsafe.Pointer(&info))
+	return
+}
+
+// SetScreen scales touches to a screen of w by h pixels, using the range of
+// the device's X and Y axes, rather than reporting the axes' raw values.  A
+// touchscreen mounted rotated or mirrored can be corrected by swapping the
+// axes or negating w or h, giving the scale from the axis' minimum to its
+// maximum.
+func (dev *Device) SetScreen(w, h int) (err error) {
+	xAxis, yAxis := uint16(ABS_X), uint16(ABS_Y)
+	if dev.HasAxis(ABS_MT_POSITION_X) {
+		xAxis, yAxis = ABS_MT_POSITION_X, ABS_MT_POSITION_Y
+	}
+	x, err := dev.AbsInfo(xAxis)
+	if err != nil {
+		return
+	}
+	y, err := dev.AbsInfo(yAxis)
+	if err != nil {
+		return
+	}
+	dev.reader.setScale(newScale(x, w), newScale(y, h))
+	return
+}
+
+func (dev *Device) ioctl(req uintptr, arg unsafe.Pointer) (err error) {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dev.file.Fd(), req, uintptr(arg)); errno != 0 {
+		err = errno
+	}
+	return
+}
+
+// ioctlString reads a NUL-terminated string with a variable length ioctl.
+func ioctlString(f *os.File, req func(size uintptr) uintptr) (s string, err error) {
+	var buf [256]byte
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req(uintptr(len(buf))), uintptr(unsafe.Pointer(&buf[0]))); errno != 0 {
+		err = errno
+		return
+	}
+	if i := bytes.IndexByte(buf[:], 0); i >= 0 {
+		s = string(buf[:i])
+	}
+	return
+}
+
+// decode decodes a struct input_event.
+func decode(buf []byte) (t time.Time, typ, code uint16, value int32) {
+	n := len(buf) - 8
+	var sec, usec int64
+	if n == 16 {
+		sec = int64(binary.LittleEndian.Uint64(buf[0:]))
+		usec = int64(binary.LittleEndian.Uint64(buf[8:]))
+	} else {
+		sec = int64(int32(binary.LittleEndian.Uint32(buf[0:])))
+		usec = int64(binary.LittleEndian.Uint32(buf[4:]))
+	}
+	t = time.Unix(sec, usec*1000)
+	typ = binary.LittleEndian.Uint16(buf[n:])
+	code = binary.LittleEndian.Uint16(buf[n+2:])
+	value = int32(binary.LittleEndian.Uint32(buf[n+4:]))
+	return
+}
@@ -0,0 +1,182 @@
+package input
+
+import (
+	"github.com/Ratfink/gopherbone/button"
+	"github.com/Ratfink/gopherbone/encoder"
+	"sync"
+	"time"
+)
+
+// A Source is anything which sends Events: a Device, a GPIO button or
+// encoder adapted by FromButton or FromEncoder, or several Sources merged
+// together.
+type Source interface {
+	// Events returns the channel events are sent on, which is closed when
+	// the Source is closed or fails.
+	Events() <-chan Event
+	// Close stops the Source and closes what it reads from.
+	Close() error
+}
+
+// A Stream sends events on C from some other source of input.
+type Stream struct {
+	C <-chan Event
+
+	c       chan Event
+	stop    chan struct{}
+	done    chan struct{}
+	release func() error
+	once    sync.Once
+}
+
+var _ Source = (*Stream)(nil)
+
+func newStream(release func() error) *Stream {
+	s := &Stream{
+		c:       make(chan Event, 16),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		release: release,
+	}
+	s.C = s.c
+	return s
+}
+
+// send sends ev, returning false if the stream is being closed.
+func (s *Stream) send(ev Event) bool {
+	select {
+	case s.c <- ev:
+		return true
+	case <-s.stop:
+		return false
+	}
+}
+
+// Events returns C.
+func (s *Stream) Events() <-chan Event {
+	return s.C
+}
+
+// Close stops the stream, closes its source and closes C.
+func (s *Stream) Close() (err error) {
+	s.once.Do(func() {
+		close(s.stop)
+		err = s.release()
+		<-s.done
+	})
+	return
+}
+
+// buttonEvent converts a button's event to the key events a keyboard would
+// send, returning false for those which have no equivalent.
+func buttonEvent(name string, code uint16, bev button.Event) (ev Event, ok bool) {
+	ev = Event{Source: name, Code: code, Time: time.Now()}
+	switch bev {
+	case button.Press:
+		ev.Type, ev.Value = Press, 1
+	case button.Release:
+		ev.Type = Release
+	case button.LongPress:
+		ev.Type, ev.Value = Repeat, 2
+	default:
+		return ev, false
+	}
+	return ev, true
+}
+
+// FromButton sends the events of a GPIO button as those of a key with the
+// given code and source name: Press and Release as it is pressed and
+// released, and Repeat when it has been held for its long press time.
+// Closing the stream closes the button.
+func FromButton(b *button.Button, name string, code uint16) (s *Stream) {
+	s = newStream(b.Close)
+
+	go func() {
+		defer close(s.done)
+		defer close(s.c)
+		for bev := range b.C {
+			if ev, ok := buttonEvent(name, code, bev); ok && !s.send(ev) {
+				return
+			}
+		}
+	}()
+
+	return
+}
+
+// FromEncoder sends the events of a rotary encoder as those of the kernel's
+// rotary-encoder driver: Move on the REL_DIAL axis as it turns, and its
+// button, if it has one, as the KEY_ENTER key, as by FromButton.  Closing
+// the stream closes the encoder.
+func FromEncoder(enc *encoder.Encoder, name string) (s *Stream) {
+	s = newStream(enc.Close)
+
+	var buttonC <-chan button.Event
+	if enc.Button != nil {
+		buttonC = enc.Button.C
+	}
+
+	go func() {
+		defer close(s.done)
+		defer close(s.c)
+		encC := enc.C
+		for encC != nil || buttonC != nil {
+			var ev Event
+			select {
+			case n, ok := <-encC:
+				if !ok {
+					encC = nil
+					continue
+				}
+				ev = Event{Source: name, Type: Move, Code: REL_DIAL, Value: n, Time: time.Now()}
+			case bev, ok := <-buttonC:
+				if !ok {
+					buttonC = nil
+					continue
+				}
+				if ev, ok = buttonEvent(name, KEY_ENTER, bev); !ok {
+					continue
+				}
+			}
+			if !s.send(ev) {
+				return
+			}
+		}
+	}()
+
+	return
+}
+
+// Merge sends the events of all of the sources on one stream, which is
+// closed once all of them are.  Closing the stream closes every source,
+// returning the first error.
+func Merge(sources ...Source) (s *Stream) {
+	s = newStream(func() (err error) {
+		for _, src := range sources {
+			if e := src.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+		return
+	})
+
+	var wg sync.WaitGroup
+	for _, src := range sources {
+		wg.Add(1)
+		go func(c <-chan Event) {
+			defer wg.Done()
+			for ev := range c {
+				if !s.send(ev) {
+					return
+				}
+			}
+		}(src.Events())
+	}
+	go func() {
+		wg.Wait()
+		close(s.c)
+		close(s.done)
+	}()
+
+	return
+}
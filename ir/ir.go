@@ -0,0 +1,318 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package ir receives and sends infrared remote control codes in the NEC and
+// RC5 protocols.  Codes are received from a TSOP-style demodulating receiver
+// on a GPIO pin, or from firmware on a PRU, and sent by switching a 38kHz
+// PWM carrier driving an IR LED.  Signals in other protocols can still be
+// learnt and replayed as Raw timings.
+package ir
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Protocol is an IR remote control protocol.
+type Protocol int
+
+// Protocols
+const (
+	NEC Protocol = iota
+	RC5
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case NEC:
+		return "NEC"
+	case RC5:
+		return "RC5"
+	}
+	return fmt.Sprintf("Protocol(%d)", int(p))
+}
+
+// NEC timings
+const (
+	NEC_LEADER_MARK  = 9000 * time.Microsecond
+	NEC_LEADER_SPACE = 4500 * time.Microsecond
+	NEC_REPEAT_SPACE = 2250 * time.Microsecond
+	NEC_BIT_MARK     = 562500 * time.Nanosecond
+	NEC_ZERO_SPACE   = 562500 * time.Nanosecond
+	NEC_ONE_SPACE    = 1687500 * time.Nanosecond
+	// NEC_PERIOD is the time from the start of one frame to the next when
+	// a button is held.
+	NEC_PERIOD = 108 * time.Millisecond
+)
+
+// RC5 timings
+const (
+	RC5_HALF_BIT = 889 * time.Microsecond
+	RC5_PERIOD   = 114 * time.Millisecond
+)
+
+// Tolerance is the fraction by which a received pulse may differ from its
+// nominal length.
+var Tolerance = 0.3
+
+var (
+	// ErrUnknown is returned when a signal isn't in any supported
+	// protocol.
+	ErrUnknown = errors.New("Unknown IR protocol")
+	// ErrChecksum is returned when an NEC code's inverted command byte
+	// doesn't match.
+	ErrChecksum = errors.New("IR code checksum mismatch")
+)
+
+// A Code is a decoded button press.  NEC addresses are 8 bits, or 16 bits
+// for extended NEC; RC5 addresses are 5 bits and commands 7 bits.
+type Code struct {
+	Protocol Protocol
+	Address  uint16
+	Command  uint8
+	// Repeat is set for NEC's repeat frames, sent while a button is held,
+	// which carry no address or command of their own.  A Receiver fills
+	// them in from the frame before.
+	Repeat bool
+	// Toggle is RC5's toggle bit, which flips on each new button press.
+	Toggle bool
+}
+
+func (c Code) String() string {
+	s := fmt.Sprintf("%s 0x%02x 0x%02x", c.Protocol, c.Address, c.Command)
+	if c.Repeat {
+		s += " repeat"
+	}
+	if c.Toggle {
+		s += " toggle"
+	}
+	return s
+}
+
+// Raw is a signal as the lengths of its alternating marks (carrier on) and
+// spaces, starting and ending with a mark.
+type Raw []time.Duration
+
+// String formats the signal as lengths in microseconds separated by spaces,
+// as LIRC does for raw codes.
+func (r Raw) String() string {
+	var b strings.Builder
+	for i, d := range r {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strconv.FormatInt(d.Microseconds(), 10))
+	}
+	return b.String()
+}
+
+// ParseRaw parses a signal in the form produced by Raw's String method.
+func ParseRaw(s string) (r Raw, err error) {
+	for _, f := range strings.Fields(s) {
+		us, err := strconv.ParseUint(f, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pulse length: %s", f)
+		}
+		r = append(r, time.Duration(us)*time.Microsecond)
+	}
+	if len(r)%2 == 0 {
+		err = fmt.Errorf("Signal must end with a mark: %d pulses", len(r))
+	}
+	return
+}
+
+// near reports whether d is within Tolerance of nominal.
+func near(d, nominal time.Duration) bool {
+	diff := float64(d - nominal)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= Tolerance*float64(nominal)
+}
+
+// Decode decodes a signal in any supported protocol.
+func Decode(r Raw) (c Code, err error) {
+	if c, err = DecodeNEC(r); err != ErrUnknown {
+		return
+	}
+	return DecodeRC5(r)
+}
+
+// DecodeNEC decodes an NEC frame or repeat frame.  Codes whose address
+// bytes aren't inverses of each other are taken as extended NEC, with a 16
+// bit address.
+func DecodeNEC(r Raw) (c Code, err error) {
+	c.Protocol = NEC
+	if len(r) < 3 || !near(r[0], NEC_LEADER_MARK) {
+		return c, ErrUnknown
+	}
+	if len(r) == 3 && near(r[1], NEC_REPEAT_SPACE) && near(r[2], NEC_BIT_MARK) {
+		c.Repeat = true
+		return
+	}
+	if len(r) != 67 || !near(r[1], NEC_LEADER_SPACE) {
+		return c, ErrUnknown
+	}
+
+	var bits uint32
+	for i := 0; i < 32; i++ {
+		mark, space := r[2+2*i], r[3+2*i]
+		if !near(mark, NEC_BIT_MARK) {
+			return c, ErrUnknown
+		}
+		switch {
+		case near(space, NEC_ONE_SPACE):
+			bits |= 1 << uint(i)
+		case near(space, NEC_ZERO_SPACE):
+		default:
+			return c, ErrUnknown
+		}
+	}
+	addr, naddr := uint8(bits), uint8(bits>>8)
+	cmd, ncmd := uint8(bits>>16), uint8(bits>>24)
+	if cmd != ^ncmd {
+		return c, ErrChecksum
+	}
+	if addr == ^naddr {
+		c.Address = uint16(addr)
+	} else {
+		c.Address = uint16(bits)
+	}
+	c.Command = cmd
+	return
+}
+
+// EncodeNEC encodes an NEC frame, or a repeat frame if c.Repeat is set.
+// Addresses above 0xff are sent as extended NEC.
+func EncodeNEC(c Code) (r Raw) {
+	if c.Repeat {
+		return Raw{NEC_LEADER_MARK, NEC_REPEAT_SPACE, NEC_BIT_MARK}
+	}
+	bits := uint32(c.Address)
+	if c.Address <= 0xff {
+		bits |= uint32(^uint8(c.Address)) << 8
+	}
+	bits |= uint32(c.Command)<<16 | uint32(^c.Command)<<24
+
+	r = append(make(Raw, 0, 67), NEC_LEADER_MARK, NEC_LEADER_SPACE)
+	for i := 0; i < 32; i++ {
+		space := NEC_ZERO_SPACE
+		if bits&(1<<uint(i)) != 0 {
+			space = NEC_ONE_SPACE
+		}
+		r = append(r, NEC_BIT_MARK, space)
+	}
+	return append(r, NEC_BIT_MARK)
+}
+
+// DecodeRC5 decodes an RC5 frame, including the extended RC5 commands 64 to
+// 127 signalled by the second start bit.
+func DecodeRC5(r Raw) (c Code, err error) {
+	c.Protocol = RC5
+	// Turn the pulses into half bits, with the first start bit's first
+	// half, which is a space and so can't be seen, put back
+	halves := []bool{false}
+	for i, d := range r {
+		mark := i%2 == 0
+		switch {
+		case near(d, RC5_HALF_BIT):
+			halves = append(halves, mark)
+		case near(d, 2*RC5_HALF_BIT):
+			halves = append(halves, mark, mark)
+		default:
+			return c, ErrUnknown
+		}
+	}
+	// A final 0 bit ends with a space, which is lost in the gap
+	if len(halves) == 27 {
+		halves = append(halves, false)
+	}
+	if len(halves) != 28 {
+		return c, ErrUnknown
+	}
+
+	var bits uint16
+	for i := 0; i < 28; i += 2 {
+		bits <<= 1
+		switch {
+		case !halves[i] && halves[i+1]:
+			bits |= 1
+		case halves[i] && !halves[i+1]:
+		default:
+			return c, ErrUnknown
+		}
+	}
+	if bits&(1<<13) == 0 {
+		return c, ErrUnknown
+	}
+	c.Toggle = bits&(1<<11) != 0
+	c.Address = (bits >> 6) & 0x1f
+	c.Command = uint8(bits & 0x3f)
+	if bits&(1<<12) == 0 {
+		c.Command |= 0x40
+	}
+	return
+}
+
+// EncodeRC5 encodes an RC5 frame.
+func EncodeRC5(c Code) (r Raw) {
+	bits := uint16(1)<<13 | (c.Address&0x1f)<<6 | uint16(c.Command&0x3f)
+	if c.Command&0x40 == 0 {
+		bits |= 1 << 12
+	}
+	if c.Toggle {
+		bits |= 1 << 11
+	}
+
+	var halves []bool
+	for i := 13; i >= 0; i-- {
+		one := bits&(1<<uint(i)) != 0
+		halves = append(halves, !one, one)
+	}
+	// Merge the half bits into pulses.  The first start bit's first half
+	// is a space, which is left off, so the signal starts with a mark.
+	mark := true
+	r = Raw{RC5_HALF_BIT}
+	for _, h := range halves[2:] {
+		if h == mark {
+			r[len(r)-1] += RC5_HALF_BIT
+		} else {
+			r = append(r, RC5_HALF_BIT)
+			mark = h
+		}
+	}
+	if !mark {
+		r = r[:len(r)-1]
+	}
+	return
+}
+
+// Raw encodes the code in its protocol.
+func (c Code) Raw() Raw {
+	if c.Protocol == RC5 {
+		return EncodeRC5(c)
+	}
+	return EncodeNEC(c)
+}
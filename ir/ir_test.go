@@ -0,0 +1,184 @@
+package ir
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// scale returns r with every pulse multiplied by f.
+func scale(r Raw, f float64) (out Raw) {
+	for _, d := range r {
+		out = append(out, time.Duration(float64(d)*f))
+	}
+	return
+}
+
+func TestNEC(t *testing.T) {
+	tests := []struct {
+		name string
+		code Code
+	}{
+		{"zero", Code{Protocol: NEC}},
+		{"typical", Code{Protocol: NEC, Address: 0x04, Command: 0x08}},
+		{"all ones", Code{Protocol: NEC, Address: 0xff, Command: 0xff}},
+		{"extended", Code{Protocol: NEC, Address: 0x1234, Command: 0x56}},
+		{"repeat", Code{Protocol: NEC, Repeat: true}},
+	}
+	for _, test := range tests {
+		r := EncodeNEC(test.code)
+		if test.code.Repeat && len(r) != 3 || !test.code.Repeat && len(r) != 67 {
+			t.Errorf("%s: %d pulses", test.name, len(r))
+		}
+		for _, f := range []float64{1, 0.8, 1.25} {
+			c, err := DecodeNEC(scale(r, f))
+			if err != nil || c != test.code {
+				t.Errorf("%s at %gx: DecodeNEC = %v, %v", test.name, f, c, err)
+			}
+		}
+		if c, err := DecodeNEC(scale(r, 1.5)); err != ErrUnknown {
+			t.Errorf("%s at 1.5x: DecodeNEC = %v, %v, want %v", test.name, c, err, ErrUnknown)
+		}
+	}
+}
+
+func TestNECBits(t *testing.T) {
+	// Address, inverted address, command and inverted command, each
+	// least significant bit first
+	r := EncodeNEC(Code{Address: 0x04, Command: 0x08})
+	want := "00100000" + "11011111" + "00010000" + "11101111"
+	for i, b := range want {
+		one := r[3+2*i] == NEC_ONE_SPACE
+		if one != (b == '1') {
+			t.Fatalf("Bit %d is %v, want %c", i, one, b)
+		}
+	}
+}
+
+func TestNECErrors(t *testing.T) {
+	good := EncodeNEC(Code{Address: 0x04, Command: 0x08})
+	// Flip the last bit of the inverted command
+	bad := append(Raw(nil), good...)
+	bad[65] = NEC_ZERO_SPACE
+	// A bit with a space of neither length
+	odd := append(Raw(nil), good...)
+	odd[11] = 1100 * time.Microsecond
+
+	tests := []struct {
+		name string
+		r    Raw
+		err  error
+	}{
+		{"checksum", bad, ErrChecksum},
+		{"bad space", odd, ErrUnknown},
+		{"truncated", good[:65], ErrUnknown},
+		{"no leader", good[2:], ErrUnknown},
+		{"empty", nil, ErrUnknown},
+	}
+	for _, test := range tests {
+		if c, err := DecodeNEC(test.r); err != test.err {
+			t.Errorf("%s: DecodeNEC = %v, %v, want %v", test.name, c, err, test.err)
+		}
+	}
+}
+
+func TestRC5(t *testing.T) {
+	tests := []struct {
+		name string
+		code Code
+	}{
+		{"zero", Code{Protocol: RC5}},
+		{"typical", Code{Protocol: RC5, Address: 5, Command: 53}},
+		{"toggle", Code{Protocol: RC5, Address: 5, Command: 53, Toggle: true}},
+		{"largest", Code{Protocol: RC5, Address: 31, Command: 63, Toggle: true}},
+		{"extended", Code{Protocol: RC5, Address: 0, Command: 64}},
+		{"extended largest", Code{Protocol: RC5, Address: 31, Command: 127}},
+	}
+	for _, test := range tests {
+		r := EncodeRC5(test.code)
+		if len(r)%2 == 0 {
+			t.Errorf("%s: Signal ends with a space", test.name)
+		}
+		for _, f := range []float64{1, 0.8, 1.25} {
+			c, err := DecodeRC5(scale(r, f))
+			if err != nil || c != test.code {
+				t.Errorf("%s at %gx: DecodeRC5 = %v, %v", test.name, f, c, err)
+			}
+		}
+	}
+
+	// Every address and command survives the trip
+	for addr := uint16(0); addr < 32; addr++ {
+		for cmd := uint8(0); cmd < 128; cmd++ {
+			code := Code{Protocol: RC5, Address: addr, Command: cmd, Toggle: cmd%2 == 0}
+			if c, err := DecodeRC5(EncodeRC5(code)); err != nil || c != code {
+				t.Fatalf("%v: DecodeRC5 = %v, %v", code, c, err)
+			}
+		}
+	}
+}
+
+func TestRC5Pulses(t *testing.T) {
+	// Start bits 1 and 1, toggle 0, then zeros: the first start bit's
+	// space is lost, and so is the last bit's
+	h := RC5_HALF_BIT
+	want := Raw{h, h, 2 * h}
+	for i := 0; i < 22; i++ {
+		want = append(want, h)
+	}
+	if r := EncodeRC5(Code{Protocol: RC5}); !reflect.DeepEqual(r, want) {
+		t.Errorf("EncodeRC5 = %v, want %v", r, want)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	h := RC5_HALF_BIT
+	tests := []struct {
+		name string
+		r    Raw
+		want Code
+		err  error
+	}{
+		{"NEC", EncodeNEC(Code{Address: 1, Command: 2}), Code{Protocol: NEC, Address: 1, Command: 2}, nil},
+		{"NEC repeat", EncodeNEC(Code{Repeat: true}), Code{Protocol: NEC, Repeat: true}, nil},
+		{"RC5", EncodeRC5(Code{Address: 3, Command: 4}), Code{Protocol: RC5, Address: 3, Command: 4}, nil},
+		{"too short for RC5", Raw{h, h, h}, Code{Protocol: RC5}, ErrUnknown},
+		{"noise", Raw{300 * time.Microsecond}, Code{Protocol: RC5}, ErrUnknown},
+	}
+	for _, test := range tests {
+		c, err := Decode(test.r)
+		if err != test.err || c != test.want {
+			t.Errorf("%s: Decode = %v, %v, want %v, %v", test.name, c, err, test.want, test.err)
+		}
+	}
+}
+
+func TestRaw(t *testing.T) {
+	tests := []struct {
+		s    string
+		r    Raw
+		fail bool
+	}{
+		{"9000 4500 562", Raw{9000 * time.Microsecond, 4500 * time.Microsecond, 562 * time.Microsecond}, false},
+		{"889", Raw{889 * time.Microsecond}, false},
+		{"9000 4500", nil, true},
+		{"9000 -4500 562", nil, true},
+		{"9000 abc 562", nil, true},
+	}
+	for _, test := range tests {
+		r, err := ParseRaw(test.s)
+		if (err != nil) != test.fail {
+			t.Errorf("ParseRaw(%q): %v", test.s, err)
+			continue
+		}
+		if test.fail {
+			continue
+		}
+		if !reflect.DeepEqual(r, test.r) {
+			t.Errorf("ParseRaw(%q) = %v, want %v", test.s, r, test.r)
+		}
+		if s := r.String(); s != test.s {
+			t.Errorf("String() = %q, want %q", s, test.s)
+		}
+	}
+}
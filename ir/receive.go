@@ -0,0 +1,274 @@
+package ir
+
+import (
+	"context"
+	"errors"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/pru"
+	"sync"
+	"time"
+)
+
+// FrameGap is the length of space which ends a frame.  It must be longer
+// than any space within a frame, and shorter than the gap between frames.
+var FrameGap = 8 * time.Millisecond
+
+// ErrClosed is returned by Learn when the Receiver is closed.
+var ErrClosed = errors.New("IR receiver closed")
+
+// An edge is the start of a mark or space at time t.
+type edge struct {
+	mark bool
+	t    time.Duration
+}
+
+// A Receiver decodes signals from a demodulating IR receiver, delivering
+// them on its channel C.  Signals which can't be decoded are dropped, unless
+// being learnt.
+type Receiver struct {
+	C <-chan Code
+
+	// Err holds the error which stopped the Receiver, if any.  It is valid
+	// once C has been closed.
+	Err error
+
+	c     chan Code
+	lock  sync.Mutex
+	learn chan Raw
+	last  *Code
+	stop  chan struct{}
+	done  chan struct{}
+	close func() error
+}
+
+func newReceiver(edges <-chan edge, close func() error) *Receiver {
+	r := &Receiver{
+		c:     make(chan Code, 8),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+		close: close,
+	}
+	r.C = r.c
+	go r.run(edges)
+	return r
+}
+
+// NewReceiver returns a Receiver for a TSOP-style receiver on a GPIO pin.
+// Its output is low while a carrier is received; if yours is the other way
+// round, set the pin active low.  The edges are timestamped as they are
+// seen, which is accurate enough for NEC and RC5 unless the system is busy.
+func NewReceiver(pin *gpio.GPIO) (r *Receiver, err error) {
+	w, err := pin.WatchEdges(gpio.Both)
+	if err != nil {
+		return
+	}
+	edges := make(chan edge, 64)
+	r = newReceiver(edges, w.Close)
+	go func() {
+		defer close(edges)
+		for ev := range w.C {
+			edges <- edge{mark: ev.Value == 0, t: ev.Time}
+		}
+		r.Err = w.Err
+	}()
+	return
+}
+
+// Offsets into PRU shared RAM of the interface to PRU receiver firmware.
+// The firmware watches its input pin, and at each edge writes a word to the
+// ring of PRU_RING_SIZE words at PRU_RING, then advances the index at
+// PRU_HEAD to the next word.  Bit 31 of each word is set for the start of a
+// mark, and the rest is the time of the edge in 5ns cycles, which wraps.
+const (
+	PRU_HEAD      = 0x0
+	PRU_RING      = 0x4
+	PRU_RING_SIZE = 1024
+)
+
+// PollInterval is how often the ring written by PRU firmware is read.
+var PollInterval = 2 * time.Millisecond
+
+// NewPRUReceiver returns a Receiver for a TSOP-style receiver on a PRU
+// input pin, timestamped by firmware running on PRU core (0 or 1), which is
+// loaded from the named file in /lib/firmware and started.  The firmware
+// must implement the interface described at PRU_HEAD.  Its timestamps are
+// exact whatever the load on the system.
+func NewPRUReceiver(core int, firmware string) (r *Receiver, err error) {
+	p, err := pru.Open(core)
+	if err != nil {
+		return
+	}
+	mem, err := pru.SharedRAM()
+	if err != nil {
+		return
+	}
+	mem.SetUint32(PRU_HEAD, 0)
+	if err = p.Load(firmware); err == nil {
+		err = p.Start()
+	}
+	if err != nil {
+		mem.Close()
+		return
+	}
+
+	edges := make(chan edge, 64)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	r = newReceiver(edges, func() (err error) {
+		close(stop)
+		<-stopped
+		err = p.Stop()
+		mem.Close()
+		return
+	})
+	go func() {
+		defer close(stopped)
+		defer close(edges)
+		tick := time.NewTicker(PollInterval)
+		defer tick.Stop()
+
+		var tail uint32
+		var t time.Duration
+		var prev uint32
+		first := true
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+			}
+			head := mem.Uint32(PRU_HEAD) % PRU_RING_SIZE
+			for ; tail != head; tail = (tail + 1) % PRU_RING_SIZE {
+				word := mem.Uint32(PRU_RING + 4*int(tail))
+				cycles := word & 0x7fffffff
+				if !first {
+					t += time.Duration((cycles-prev)&0x7fffffff) * 5 * time.Nanosecond
+				}
+				prev, first = cycles, false
+				select {
+				case edges <- edge{mark: word&(1<<31) != 0, t: t}:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
+	return
+}
+
+// run gathers edges into frames, which end at a space of at least FrameGap,
+// and decodes them.
+func (r *Receiver) run(edges <-chan edge) {
+	defer close(r.done)
+	defer close(r.c)
+
+	var frame Raw
+	var last time.Duration
+	var mark, inFrame bool
+	finish := func() {
+		if inFrame {
+			if len(frame)%2 == 0 && len(frame) > 0 {
+				// Ended mid-mark, so the mark is incomplete
+				frame = frame[:len(frame)-1]
+			}
+			if len(frame) > 0 {
+				r.frame(frame)
+			}
+		}
+		frame, inFrame = nil, false
+	}
+	// The gap is also timed here, since no edge comes to end the last
+	// space
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case e, ok := <-edges:
+			if !ok {
+				finish()
+				return
+			}
+			if !inFrame || (!mark && e.t-last >= FrameGap) {
+				finish()
+				if !e.mark {
+					continue
+				}
+				inFrame, mark, last = true, true, e.t
+			} else if e.mark != mark {
+				frame = append(frame, e.t-last)
+				mark, last = e.mark, e.t
+			}
+			timer.Reset(2 * FrameGap)
+		case <-timer.C:
+			finish()
+		}
+	}
+}
+
+// frame handles a complete frame.
+func (r *Receiver) frame(raw Raw) {
+	r.lock.Lock()
+	learn := r.learn
+	r.learn = nil
+	r.lock.Unlock()
+	if learn != nil {
+		learn <- raw
+		return
+	}
+
+	c, err := Decode(raw)
+	if err != nil {
+		return
+	}
+	if c.Repeat {
+		if r.last == nil {
+			return
+		}
+		c.Address, c.Command = r.last.Address, r.last.Command
+	} else {
+		r.last = &c
+	}
+	select {
+	case r.c <- c:
+	case <-r.stop:
+	}
+}
+
+// Learn returns the next signal received, undecoded, instead of delivering
+// it on C.  The signal can be saved with its String method and sent again
+// with a Transmitter, which lets buttons in any protocol be copied.
+func (r *Receiver) Learn(ctx context.Context) (raw Raw, err error) {
+	learn := make(chan Raw, 1)
+	r.lock.Lock()
+	r.learn = learn
+	r.lock.Unlock()
+	defer func() {
+		r.lock.Lock()
+		if r.learn == learn {
+			r.learn = nil
+		}
+		r.lock.Unlock()
+	}()
+
+	select {
+	case raw = <-learn:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-r.done:
+		err = ErrClosed
+	}
+	return
+}
+
+// Close stops the Receiver and closes C.
+func (r *Receiver) Close() (err error) {
+	close(r.stop)
+	err = r.close()
+	// Drain the channel so that run can finish
+	for range r.c {
+	}
+	<-r.done
+	return
+}
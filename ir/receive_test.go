@@ -0,0 +1,134 @@
+package ir
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sendEdges sends the edges of a signal starting at t, and returns the time
+// it ends.
+func sendEdges(edges chan<- edge, t time.Duration, r Raw) time.Duration {
+	for i, d := range r {
+		edges <- edge{mark: i%2 == 0, t: t}
+		t += d
+	}
+	edges <- edge{mark: false, t: t}
+	return t
+}
+
+func TestReceiver(t *testing.T) {
+	nec := Code{Protocol: NEC, Address: 0x04, Command: 0x08}
+	rc5 := Code{Protocol: RC5, Address: 5, Command: 53, Toggle: true}
+	repeat := Code{Protocol: NEC, Repeat: true}
+
+	tests := []struct {
+		name   string
+		frames []Raw
+		want   []Code
+	}{
+		{"NEC", []Raw{nec.Raw()}, []Code{nec}},
+		{"held", []Raw{nec.Raw(), repeat.Raw(), repeat.Raw()},
+			[]Code{nec, {Protocol: NEC, Address: 0x04, Command: 0x08, Repeat: true}, {Protocol: NEC, Address: 0x04, Command: 0x08, Repeat: true}}},
+		{"repeat first", []Raw{repeat.Raw(), nec.Raw()}, []Code{nec}},
+		{"RC5", []Raw{rc5.Raw()}, []Code{rc5}},
+		{"noise dropped", []Raw{{300 * time.Microsecond}, rc5.Raw(), Raw{100 * time.Microsecond, 200 * time.Microsecond, 100 * time.Microsecond}, nec.Raw()},
+			[]Code{rc5, nec}},
+	}
+	for _, test := range tests {
+		edges := make(chan edge)
+		r := newReceiver(edges, func() error { return nil })
+
+		go func() {
+			// A space first, as a receiver idles
+			edges <- edge{mark: false, t: 0}
+			t := time.Second
+			for _, f := range test.frames {
+				sendEdges(edges, t, f)
+				t += NEC_PERIOD + RC5_PERIOD
+			}
+			close(edges)
+		}()
+
+		var got []Code
+		for c := range r.C {
+			got = append(got, c)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Received %v, want %v", test.name, got, test.want)
+		}
+		if err := r.Close(); err != nil {
+			t.Errorf("%s: Close: %v", test.name, err)
+		}
+	}
+}
+
+func TestReceiverGap(t *testing.T) {
+	// Without another edge, a frame ends once FrameGap has passed
+	edges := make(chan edge)
+	r := newReceiver(edges, func() error { return nil })
+	defer r.Close()
+	code := Code{Protocol: RC5, Address: 1, Command: 2}
+	sendEdges(edges, 0, code.Raw())
+
+	select {
+	case c := <-r.C:
+		if c != code {
+			t.Errorf("Received %v, want %v", c, code)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("No code received")
+	}
+	close(edges)
+}
+
+func TestLearn(t *testing.T) {
+	edges := make(chan edge)
+	closed := false
+	r := newReceiver(edges, func() error {
+		closed = true
+		return nil
+	})
+
+	// A signal in no known protocol
+	want := Raw{3 * time.Millisecond, time.Millisecond, 500 * time.Microsecond, 2 * time.Millisecond, time.Millisecond}
+	learnt := make(chan Raw, 1)
+	go func() {
+		raw, err := r.Learn(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		learnt <- raw
+	}()
+	for {
+		r.lock.Lock()
+		learning := r.learn != nil
+		r.lock.Unlock()
+		if learning {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	end := sendEdges(edges, 0, want)
+	// Then a code, which is delivered as usual
+	code := Code{Protocol: NEC, Address: 1, Command: 2}
+	sendEdges(edges, end+time.Second, code.Raw())
+	close(edges)
+
+	if raw := <-learnt; !reflect.DeepEqual(raw, want) {
+		t.Errorf("Learnt %v, want %v", raw, want)
+	}
+	if c := <-r.C; c != code {
+		t.Errorf("Received %v, want %v", c, code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.Learn(ctx); err != ErrClosed {
+		t.Errorf("Learn after the edges stopped: %v, want %v", err, ErrClosed)
+	}
+	if err := r.Close(); err != nil || !closed {
+		t.Errorf("Close: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package ir
+
+import (
+	"github.com/Ratfink/gopherbone/pwm"
+	"runtime"
+	"time"
+)
+
+// CARRIER is the usual carrier frequency in hertz, for NEC and, near
+// enough, for RC5's 36kHz.
+const CARRIER = 38000
+
+// A Transmitter sends signals on an IR LED driven by a PWM channel, which
+// makes the carrier.  Marks are sent by enabling the channel and spaces by
+// disabling it, timed by spinning on the clock, since sleeping is too
+// coarse for pulses of half a millisecond.
+type Transmitter struct {
+	ch pwm.Channel
+}
+
+// NewTransmitter returns a Transmitter on ch with a carrier of the given
+// frequency in hertz, such as CARRIER.  A duty cycle of a third lets the LED
+// be driven harder than it could be continuously.
+func NewTransmitter(ch pwm.Channel, carrier float64) (tx *Transmitter, err error) {
+	if err = ch.Disable(); err != nil {
+		return
+	}
+	if err = ch.SetFrequency(carrier); err != nil {
+		return
+	}
+	if err = ch.SetDuty(1.0 / 3); err != nil {
+		return
+	}
+	tx = &Transmitter{ch: ch}
+
+	return
+}
+
+// Send sends a signal.  Each pulse is timed from the start of the signal, so
+// the time taken to switch the carrier doesn't accumulate.
+func (tx *Transmitter) Send(raw Raw) (err error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer tx.ch.Disable()
+
+	start := time.Now()
+	var end time.Duration
+	for i, d := range raw {
+		if i%2 == 0 {
+			err = tx.ch.Enable()
+		} else {
+			err = tx.ch.Disable()
+		}
+		if err != nil {
+			return
+		}
+		end += d
+		for time.Since(start) < end {
+		}
+	}
+	return
+}
+
+// SendCode sends a code, then repeats it as a held button would: NEC sends
+// repeat frames, and RC5 the same frame again.  Frames are sent one period
+// apart.
+func (tx *Transmitter) SendCode(c Code, repeats int) (err error) {
+	period := NEC_PERIOD
+	repeat := c
+	if c.Protocol == RC5 {
+		period = RC5_PERIOD
+	} else {
+		repeat.Repeat = true
+	}
+
+	next := time.Now()
+	for i := 0; i <= repeats; i++ {
+		time.Sleep(time.Until(next))
+		next = next.Add(period)
+		frame := c
+		if i > 0 {
+			frame = repeat
+		}
+		if err = tx.Send(frame.Raw()); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,191 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* This package controls LEDs through the kernel's sysfs LED interface, most
+ * usefully the BeagleBone's four user LEDs, USR0 to USR3.
+ */
+package leds
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A Trigger is a kernel event source which can control an LED.
+type Trigger string
+
+// Commonly available triggers.  The BeagleBone's default triggers are
+// heartbeat for USR0, mmc0 for USR1, cpu0 for USR2 and mmc1 for USR3.
+const (
+	None      Trigger = "none"
+	Heartbeat Trigger = "heartbeat"
+	Timer     Trigger = "timer"
+	CPU0      Trigger = "cpu0"
+	MMC0      Trigger = "mmc0"
+	MMC1      Trigger = "mmc1"
+)
+
+// SysfsPath is where the kernel's LEDs are, which may be changed for testing
+// or for unusual systems.
+var SysfsPath = "/sys/class/leds"
+
+// An LED is an LED under SysfsPath.
+type LED struct {
+	Name string
+}
+
+// The BeagleBone's user LEDs.
+var (
+	USR0 = &LED{Name: "beaglebone:green:usr0"}
+	USR1 = &LED{Name: "beaglebone:green:usr1"}
+	USR2 = &LED{Name: "beaglebone:green:usr2"}
+	USR3 = &LED{Name: "beaglebone:green:usr3"}
+)
+
+// Open returns the named LED, checking that it exists.
+func Open(name string) (led *LED, err error) {
+	led = &LED{Name: name}
+	if _, err = os.Stat(led.path("")); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func (led *LED) path(attr string) string {
+	return filepath.Join(SysfsPath, led.Name, attr)
+}
+
+func (led *LED) readInt(attr string) (value int, err error) {
+	f, err := gopherbone.OpenFile(led.path(attr), os.O_RDONLY, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	n, err := fmt.Fscanf(f, "%d", &value)
+	if n != 1 {
+		err = fmt.Errorf("Bad number of values read from %s: %d", led.path(attr), n)
+	}
+
+	return
+}
+
+func (led *LED) write(attr string, value interface{}) (err error) {
+	f, err := gopherbone.OpenFile(led.path(attr), os.O_WRONLY, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprint(f, value)
+
+	return
+}
+
+// Brightness returns the LED's current brightness.
+func (led *LED) Brightness() (int, error) {
+	return led.readInt("brightness")
+}
+
+// MaxBrightness returns the brightness at which the LED is fully on.  For
+// the user LEDs, which can only be on or off, this is 255.
+func (led *LED) MaxBrightness() (int, error) {
+	return led.readInt("max_brightness")
+}
+
+// SetBrightness sets the LED's brightness.  Setting it to zero also removes
+// any trigger.
+func (led *LED) SetBrightness(brightness int) (err error) {
+	if brightness < 0 {
+		err = fmt.Errorf("Invalid brightness: %d", brightness)
+		return
+	}
+	return led.write("brightness", brightness)
+}
+
+// On turns the LED fully on, removing any trigger.
+func (led *LED) On() (err error) {
+	if err = led.SetTrigger(None); err != nil {
+		return
+	}
+	max, err := led.MaxBrightness()
+	if err != nil {
+		return
+	}
+	return led.SetBrightness(max)
+}
+
+// Off turns the LED off, removing any trigger.
+func (led *LED) Off() (err error) {
+	if err = led.SetTrigger(None); err != nil {
+		return
+	}
+	return led.SetBrightness(0)
+}
+
+// Triggers returns the triggers available for the LED, and which one is
+// currently selected.
+func (led *LED) Triggers() (triggers []Trigger, current Trigger, err error) {
+	data, err := os.ReadFile(led.path("trigger"))
+	if err != nil {
+		return
+	}
+
+	// The current trigger is shown in brackets
+	for _, field := range strings.Fields(string(data)) {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			field = strings.Trim(field, "[]")
+			current = Trigger(field)
+		}
+		triggers = append(triggers, Trigger(field))
+	}
+
+	return
+}
+
+// Trigger returns the currently selected trigger.
+func (led *LED) Trigger() (current Trigger, err error) {
+	_, current, err = led.Triggers()
+	return
+}
+
+// SetTrigger selects the trigger controlling the LED.
+func (led *LED) SetTrigger(trigger Trigger) error {
+	return led.write("trigger", string(trigger))
+}
+
+// Blink makes the kernel blink the LED, on for one duration and off for the
+// other, using the timer trigger.  Durations are rounded to milliseconds.
+func (led *LED) Blink(on, off time.Duration) (err error) {
+	if err = led.SetTrigger(Timer); err != nil {
+		return
+	}
+	if err = led.write("delay_on", int64(on/time.Millisecond)); err != nil {
+		return
+	}
+	return led.write("delay_off", int64(off/time.Millisecond))
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+var publishOnce sync.Once
+
+// Publish exposes the metrics through expvar, as a map named "gopherbone"
+// which the expvar handler serves at /debug/vars.  Histograms appear as
+// their count, sum and cumulative buckets.  Calling it more than once has
+// no further effect.
+func Publish() {
+	publishOnce.Do(func() {
+		expvar.Publish("gopherbone", expvar.Func(func() interface{} {
+			vars := make(map[string]interface{})
+			for _, m := range All() {
+				switch m := m.(type) {
+				case *Counter:
+					vars[m.Name()] = m.Value()
+				case *Gauge:
+					vars[m.Name()] = m.Value()
+				case *GaugeVec:
+					vars[m.Name()] = m.Values()
+				case *Histogram:
+					bounds, cumulative, count, sum := m.Snapshot()
+					buckets := make(map[string]uint64, len(bounds))
+					for i, b := range bounds {
+						buckets[formatFloat(b)] = cumulative[i]
+					}
+					vars[m.Name()] = map[string]interface{}{"count": count, "sum": sum, "buckets": buckets}
+				}
+			}
+			return vars
+		}))
+	})
+}
+
+// Handler returns an http.Handler serving the metrics in Prometheus's text
+// exposition format, for a Prometheus server to scrape.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes the metrics in Prometheus's text exposition
+// format.
+func WritePrometheus(w io.Writer) (err error) {
+	bw := bufio.NewWriter(w)
+	for _, m := range All() {
+		switch m := m.(type) {
+		case *Counter:
+			header(bw, m, "counter")
+			fmt.Fprintf(bw, "%s %d\n", m.Name(), m.Value())
+		case *Gauge:
+			header(bw, m, "gauge")
+			fmt.Fprintf(bw, "%s %s\n", m.Name(), formatFloat(m.Value()))
+		case *GaugeVec:
+			header(bw, m, "gauge")
+			values := m.Values()
+			keys := make([]string, 0, len(values))
+			for k := range values {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(bw, "%s{%s=%q} %s\n", m.Name(), m.Label(), k, formatFloat(values[k]))
+			}
+		case *Histogram:
+			header(bw, m, "histogram")
+			bounds, cumulative, count, sum := m.Snapshot()
+			for i, b := range bounds {
+				fmt.Fprintf(bw, "%s_bucket{le=\"%s\"} %d\n", m.Name(), formatFloat(b), cumulative[i])
+			}
+			fmt.Fprintf(bw, "%s_bucket{le=\"+Inf\"} %d\n", m.Name(), count)
+			fmt.Fprintf(bw, "%s_sum %s\n", m.Name(), formatFloat(sum))
+			fmt.Fprintf(bw, "%s_count %d\n", m.Name(), count)
+		}
+	}
+	return bw.Flush()
+}
+
+func header(w io.Writer, m Metric, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.Name(), m.Help(), m.Name(), kind)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
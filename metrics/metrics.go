@@ -0,0 +1,259 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package metrics counts what the GopherBone packages do, such as I2C
+// transactions and frames drawn, so that long running programs can be
+// monitored.  The counting is always on and costs an atomic add or two; the
+// results are exposed only if asked for, through expvar with Publish or in
+// Prometheus's text format with Handler.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Metric is a Counter, Gauge, Histogram or GaugeVec.
+type Metric interface {
+	// Name returns the metric's name, in Prometheus style.
+	Name() string
+	// Help returns a description of the metric.
+	Help() string
+}
+
+var (
+	registry     []Metric
+	registryLock sync.Mutex
+)
+
+// register adds a metric to those exposed.
+func register(m Metric) {
+	registryLock.Lock()
+	registry = append(registry, m)
+	registryLock.Unlock()
+}
+
+// All returns every metric, sorted by name.
+func All() (ms []Metric) {
+	registryLock.Lock()
+	ms = append(ms, registry...)
+	registryLock.Unlock()
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Name() < ms[j].Name() })
+	return
+}
+
+type desc struct {
+	name, help string
+}
+
+func (d *desc) Name() string { return d.name }
+func (d *desc) Help() string { return d.help }
+
+// A Counter is a count which only goes up.
+type Counter struct {
+	desc
+	v uint64
+}
+
+// NewCounter returns a new Counter, exposed with the other metrics.
+func NewCounter(name, help string) *Counter {
+	c := &Counter{desc: desc{name, help}}
+	register(c)
+	return c
+}
+
+// Inc adds one to the count.
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.v, 1)
+}
+
+// Add adds n to the count.
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.v, n)
+}
+
+// Value returns the count.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.v)
+}
+
+// A Gauge is a value which may go up and down, such as a voltage.
+type Gauge struct {
+	desc
+	bits uint64
+}
+
+// NewGauge returns a new Gauge, exposed with the other metrics.
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{desc: desc{name, help}}
+	register(g)
+	return g
+}
+
+// Set sets the value.
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Value returns the value.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// A GaugeVec is a set of Gauges told apart by the value of one label, such
+// as an ADC channel number.
+type GaugeVec struct {
+	desc
+	label  string
+	lock   sync.Mutex
+	gauges map[string]*Gauge
+}
+
+// NewGaugeVec returns a new GaugeVec, exposed with the other metrics.
+func NewGaugeVec(name, help, label string) *GaugeVec {
+	v := &GaugeVec{desc: desc{name, help}, label: label, gauges: make(map[string]*Gauge)}
+	register(v)
+	return v
+}
+
+// With returns the Gauge for a value of the label, creating it if need be.
+func (v *GaugeVec) With(value string) *Gauge {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	g := v.gauges[value]
+	if g == nil {
+		g = &Gauge{desc: v.desc}
+		v.gauges[value] = g
+	}
+	return g
+}
+
+// Label returns the name of the label.
+func (v *GaugeVec) Label() string {
+	return v.label
+}
+
+// Values returns the value of each Gauge by its label value.
+func (v *GaugeVec) Values() map[string]float64 {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	values := make(map[string]float64, len(v.gauges))
+	for k, g := range v.gauges {
+		values[k] = g.Value()
+	}
+	return values
+}
+
+// LatencyBuckets are histogram bucket bounds in seconds suited to bus
+// transfers and frame draws, from 100µs to 1s.
+var LatencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// A Histogram counts observations, such as latencies, in buckets.
+type Histogram struct {
+	desc
+	bounds []float64
+	// counts[i] counts observations no more than bounds[i]; the last
+	// counts those above every bound
+	counts  []uint64
+	count   uint64
+	sumBits uint64
+}
+
+// NewHistogram returns a new Histogram with the given bucket upper bounds,
+// which must be sorted, exposed with the other metrics.
+func NewHistogram(name, help string, bounds []float64) *Histogram {
+	h := &Histogram{
+		desc:   desc{name, help},
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+	register(h)
+	return h
+}
+
+// Observe records a value.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	for {
+		old := atomic.LoadUint64(&h.sumBits)
+		sum := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&h.sumBits, old, sum) {
+			return
+		}
+	}
+}
+
+// ObserveSince records the time since start, in seconds.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// Snapshot returns the bucket bounds, the cumulative count of observations
+// no more than each, the total count and the sum of the observations.
+func (h *Histogram) Snapshot() (bounds []float64, cumulative []uint64, count uint64, sum float64) {
+	bounds = h.bounds
+	cumulative = make([]uint64, len(h.bounds))
+	var total uint64
+	for i := range h.bounds {
+		total += atomic.LoadUint64(&h.counts[i])
+		cumulative[i] = total
+	}
+	count = atomic.LoadUint64(&h.count)
+	sum = math.Float64frombits(atomic.LoadUint64(&h.sumBits))
+	return
+}
+
+// The GopherBone packages' metrics.
+var (
+	I2CTransactions = NewCounter("gopherbone_i2c_transactions_total", "I2C transfers attempted.")
+	I2CErrors       = NewCounter("gopherbone_i2c_errors_total", "I2C transfers which failed.")
+	I2CLatency      = NewHistogram("gopherbone_i2c_latency_seconds", "Time taken by I2C transfers.", LatencyBuckets)
+	SPITransfers    = NewCounter("gopherbone_spi_transfers_total", "SPI transfers attempted.")
+	SPIErrors       = NewCounter("gopherbone_spi_errors_total", "SPI transfers which failed.")
+	FramesDrawn     = NewCounter("gopherbone_display_frames_total", "Frames sent to displays.")
+	DrawErrors      = NewCounter("gopherbone_display_errors_total", "Frames which failed to send.")
+	DrawLatency     = NewHistogram("gopherbone_display_draw_seconds", "Time taken to send a frame to a display.", LatencyBuckets)
+	GPIOEdges       = NewCounter("gopherbone_gpio_edges_total", "GPIO edge events delivered.")
+	ADCReadings     = NewCounter("gopherbone_adc_readings_total", "ADC readings taken.")
+	ADCVolts        = NewGaugeVec("gopherbone_adc_volts", "Last voltage read from each ADC channel.", "channel")
+)
+
+// ObserveI2C records an I2C transfer which started at start.
+func ObserveI2C(start time.Time, err error) {
+	I2CTransactions.Inc()
+	if err != nil {
+		I2CErrors.Inc()
+	}
+	I2CLatency.ObserveSince(start)
+}
+
+// ObserveADC records an ADC reading from channel n.
+func ObserveADC(n int, volts float64) {
+	ADCReadings.Inc()
+	ADCVolts.With(strconv.Itoa(n)).Set(volts)
+}
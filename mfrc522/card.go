@@ -0,0 +1,292 @@
+package mfrc522
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Card commands
+const (
+	PICC_REQA          = 0x26
+	PICC_WUPA          = 0x52
+	PICC_CT            = 0x88 // cascade tag
+	PICC_SEL_CL1       = 0x93
+	PICC_SEL_CL2       = 0x95
+	PICC_SEL_CL3       = 0x97
+	PICC_HLTA          = 0x50
+	PICC_MF_AUTH_KEY_A = 0x60
+	PICC_MF_AUTH_KEY_B = 0x61
+	PICC_MF_READ       = 0x30
+	PICC_MF_WRITE      = 0xa0
+	PICC_ACK           = 0x0a
+)
+
+// BlockSize is the size of a MIFARE block.
+const BlockSize = 16
+
+// A Key is a MIFARE Classic sector key.
+type Key [6]byte
+
+// DefaultKey is the key new cards come with.
+var DefaultKey = Key{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// A KeyType says whether a key is a sector's key A or key B.
+type KeyType byte
+
+// Key types
+const (
+	KeyA KeyType = PICC_MF_AUTH_KEY_A
+	KeyB KeyType = PICC_MF_AUTH_KEY_B
+)
+
+// A Card is a card which has been selected.
+type Card struct {
+	// UID is 4, 7 or 10 bytes long.
+	UID  []byte
+	ATQA uint16
+	SAK  byte
+}
+
+// String returns the UID in hex, with colons between bytes.
+func (c *Card) String() string {
+	h := hex.EncodeToString(c.UID)
+	var parts []string
+	for i := 0; i < len(h); i += 2 {
+		parts = append(parts, h[i:i+2])
+	}
+	return strings.ToUpper(strings.Join(parts, ":"))
+}
+
+// Type names the kind of card from its SAK.
+func (c *Card) Type() string {
+	switch c.SAK & 0x7f {
+	case 0x00:
+		return "MIFARE Ultralight"
+	case 0x08:
+		return "MIFARE Classic 1K"
+	case 0x09:
+		return "MIFARE Mini"
+	case 0x18:
+		return "MIFARE Classic 4K"
+	case 0x10, 0x11:
+		return "MIFARE Plus"
+	case 0x20:
+		return "ISO 14443-4"
+	}
+	return fmt.Sprintf("Unknown (SAK %#02x)", c.SAK)
+}
+
+// transceive sends data to the card and returns its answer.
+func (rd *Reader) transceive(data []byte, txLastBits byte) (back []byte, rxLastBits int, err error) {
+	return rd.command(PCD_TRANSCEIVE, data, txLastBits, IRQ_RX|IRQ_IDLE)
+}
+
+// transceiveCRC appends a CRC to data and sends it, and checks the CRC of
+// the answer, unless the answer is a 4 bit ACK or NAK.
+func (rd *Reader) transceiveCRC(data []byte) (back []byte, err error) {
+	crc, err := rd.crc(data)
+	if err != nil {
+		return
+	}
+	back, lastBits, err := rd.transceive(append(data, crc[:]...), 0)
+	if err != nil {
+		return
+	}
+	if len(back) == 1 && lastBits == 4 {
+		if back[0]&0x0f != PICC_ACK {
+			return nil, ErrNAK
+		}
+		return
+	}
+	if len(back) < 3 {
+		return nil, ErrCommunication
+	}
+	want, err := rd.crc(back[:len(back)-2])
+	if err != nil {
+		return
+	}
+	if back[len(back)-2] != want[0] || back[len(back)-1] != want[1] {
+		return nil, ErrCommunication
+	}
+	return back[:len(back)-2], nil
+}
+
+// request sends REQA or WUPA, a short frame of 7 bits, and returns the ATQA.
+func (rd *Reader) request(cmd byte) (atqa uint16, err error) {
+	// Clear the collision bit's ValuesAfterColl so collisions are seen
+	if err = rd.updateReg(CollReg, 0x80, 0); err != nil {
+		return
+	}
+	back, lastBits, err := rd.transceive([]byte{cmd}, 7)
+	if err != nil {
+		return
+	}
+	if len(back) != 2 || lastBits != 0 {
+		return 0, ErrCommunication
+	}
+	return uint16(back[1])<<8 | uint16(back[0]), nil
+}
+
+// selectCard runs the anticollision loop and selects the card, cascade
+// level by level.  Only one card may be in the field.
+func (rd *Reader) selectCard() (card *Card, err error) {
+	card = new(Card)
+	for _, sel := range []byte{PICC_SEL_CL1, PICC_SEL_CL2, PICC_SEL_CL3} {
+		// Anticollision: ask for the whole UID part at this level
+		back, _, err := rd.transceive([]byte{sel, 0x20}, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(back) != 5 || back[0]^back[1]^back[2]^back[3] != back[4] {
+			return nil, ErrCommunication
+		}
+
+		sak, err := rd.transceiveCRC(append([]byte{sel, 0x70}, back...))
+		if err != nil {
+			return nil, err
+		}
+		if len(sak) != 1 {
+			return nil, ErrCommunication
+		}
+		card.SAK = sak[0]
+
+		if back[0] == PICC_CT {
+			card.UID = append(card.UID, back[1:4]...)
+		} else {
+			card.UID = append(card.UID, back[0:4]...)
+		}
+		// The cascade bit says the UID isn't complete
+		if card.SAK&0x04 == 0 {
+			return card, nil
+		}
+	}
+	return nil, ErrCommunication
+}
+
+// Detect wakes and selects a card in the field, returning ErrNoCard if there
+// is none.  Halted cards are woken too, so a card left on the reader is
+// found each time.
+func (rd *Reader) Detect() (card *Card, err error) {
+	rd.lock.Lock()
+	defer rd.lock.Unlock()
+	return rd.detect(PICC_WUPA)
+}
+
+// DetectNew is like Detect, but halted cards are left alone, so a card is
+// only found again once it has left the field and returned.
+func (rd *Reader) DetectNew() (card *Card, err error) {
+	rd.lock.Lock()
+	defer rd.lock.Unlock()
+	return rd.detect(PICC_REQA)
+}
+
+func (rd *Reader) detect(cmd byte) (card *Card, err error) {
+	rd.stopCrypto()
+	atqa, err := rd.request(cmd)
+	if err != nil {
+		return
+	}
+	if card, err = rd.selectCard(); err != nil {
+		return
+	}
+	card.ATQA = atqa
+	return
+}
+
+// Halt puts the selected card to sleep, ending any authentication.
+func (rd *Reader) Halt() (err error) {
+	rd.lock.Lock()
+	defer rd.lock.Unlock()
+
+	crc, err := rd.crc([]byte{PICC_HLTA, 0})
+	if err != nil {
+		return
+	}
+	// The card doesn't answer a successful HLTA, so timing out is success
+	_, _, err = rd.transceive([]byte{PICC_HLTA, 0, crc[0], crc[1]}, 0)
+	if err == ErrNoCard {
+		err = nil
+	} else if err == nil {
+		err = ErrNAK
+	}
+	rd.stopCrypto()
+	return
+}
+
+// Auth authenticates with the sector holding block, using a key of the
+// card's, which allows that sector's blocks to be read and written until
+// another sector is authenticated or the card is halted.
+func (rd *Reader) Auth(card *Card, block int, kt KeyType, key Key) (err error) {
+	if len(card.UID) < 4 {
+		return fmt.Errorf("Invalid UID length: %d", len(card.UID))
+	}
+	rd.lock.Lock()
+	defer rd.lock.Unlock()
+
+	// The last 4 bytes of the UID are used, as the card uses them
+	uid := card.UID[len(card.UID)-4:]
+	data := append([]byte{byte(kt), byte(block)}, key[:]...)
+	data = append(data, uid...)
+	if _, _, err = rd.command(PCD_MF_AUTHENT, data, 0, IRQ_IDLE); err != nil {
+		if err == ErrNoCard {
+			err = ErrAuth
+		}
+		return
+	}
+	status, err := rd.readReg(Status2Reg)
+	if err != nil {
+		return
+	}
+	if status&STATUS2_CRYPTO1_ON == 0 {
+		return ErrAuth
+	}
+	return
+}
+
+func (rd *Reader) stopCrypto() error {
+	return rd.updateReg(Status2Reg, STATUS2_CRYPTO1_ON, 0)
+}
+
+// ReadBlock reads a block from a MIFARE card.  For MIFARE Classic, the
+// block's sector must have been authenticated.
+func (rd *Reader) ReadBlock(block int) (data [BlockSize]byte, err error) {
+	rd.lock.Lock()
+	defer rd.lock.Unlock()
+
+	back, err := rd.transceiveCRC([]byte{PICC_MF_READ, byte(block)})
+	if err != nil {
+		return
+	}
+	if len(back) != BlockSize {
+		return data, ErrCommunication
+	}
+	copy(data[:], back)
+	return
+}
+
+// WriteBlock writes a block of a MIFARE card.  For MIFARE Classic, the
+// block's sector must have been authenticated.  Block 0 holds the UID and
+// each sector's last block its keys and access bits; writing them wrongly
+// can make a sector unusable, so check the block number carefully.
+func (rd *Reader) WriteBlock(block int, data [BlockSize]byte) (err error) {
+	rd.lock.Lock()
+	defer rd.lock.Unlock()
+
+	if _, err = rd.transceiveCRC([]byte{PICC_MF_WRITE, byte(block)}); err != nil {
+		return
+	}
+	_, err = rd.transceiveCRC(data[:])
+	return
+}
+
+// SectorTrailer returns the block number of the trailer, holding the keys
+// and access bits, of the sector holding block on a MIFARE Classic card.
+// Sectors are 4 blocks, except on 4K cards from block 128 on, where they
+// are 16.
+func SectorTrailer(block int) int {
+	if block < 128 {
+		return block | 3
+	}
+	return block | 15
+}
@@ -0,0 +1,507 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package mfrc522 drives the MFRC522 13.56MHz RFID reader, as on the cheap
+// RC522 modules, over SPI or I2C.  It finds cards and reads their UIDs,
+// which is all many access control projects need, and reads and writes the
+// blocks of MIFARE Classic cards after authenticating with a sector key.
+package mfrc522
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/i2c"
+	"github.com/Ratfink/gopherbone/spi"
+	"sync"
+	"time"
+)
+
+// Registers
+const (
+	CommandReg    = 0x01
+	ComIEnReg     = 0x02
+	DivIEnReg     = 0x03
+	ComIrqReg     = 0x04
+	DivIrqReg     = 0x05
+	ErrorReg      = 0x06
+	Status1Reg    = 0x07
+	Status2Reg    = 0x08
+	FIFODataReg   = 0x09
+	FIFOLevelReg  = 0x0a
+	ControlReg    = 0x0c
+	BitFramingReg = 0x0d
+	CollReg       = 0x0e
+	ModeReg       = 0x11
+	TxModeReg     = 0x12
+	RxModeReg     = 0x13
+	TxControlReg  = 0x14
+	TxASKReg      = 0x15
+	CRCResultRegH = 0x21
+	CRCResultRegL = 0x22
+	ModWidthReg   = 0x24
+	RFCfgReg      = 0x26
+	TModeReg      = 0x2a
+	TPrescalerReg = 0x2b
+	TReloadRegH   = 0x2c
+	TReloadRegL   = 0x2d
+	VersionReg    = 0x37
+)
+
+// Commands
+const (
+	PCD_IDLE       = 0x00
+	PCD_CALC_CRC   = 0x03
+	PCD_TRANSCEIVE = 0x0c
+	PCD_MF_AUTHENT = 0x0e
+	PCD_SOFT_RESET = 0x0f
+)
+
+// Interrupt and status bits
+const (
+	IRQ_TIMER = 1 << 0
+	IRQ_ERR   = 1 << 1
+	IRQ_IDLE  = 1 << 4
+	IRQ_RX    = 1 << 5
+	IRQ_CRC   = 1 << 2 // in DivIrqReg
+
+	ERR_PROTOCOL = 1 << 0
+	ERR_PARITY   = 1 << 1
+	ERR_COLL     = 1 << 3
+	ERR_OVERFLOW = 1 << 4
+
+	STATUS2_CRYPTO1_ON = 1 << 3
+)
+
+var (
+	// ErrNotFound is returned when no MFRC522 answers.
+	ErrNotFound = errors.New("No MFRC522 found")
+	// ErrNoCard is returned when no card answers.
+	ErrNoCard = errors.New("No card present")
+	// ErrCollision is returned when more than one card answers at once.
+	ErrCollision = errors.New("Card collision")
+	// ErrCommunication is returned when a card's answer is garbled.
+	ErrCommunication = errors.New("Card communication error")
+	// ErrAuth is returned when a card refuses a key.
+	ErrAuth = errors.New("Card authentication failed")
+	// ErrNAK is returned when a card refuses a command.
+	ErrNAK = errors.New("Card refused command")
+)
+
+// regs reads and writes the chip's registers over SPI or I2C.
+type regs interface {
+	read(reg byte, n int) ([]byte, error)
+	write(reg byte, data []byte) error
+	close() error
+}
+
+type spiRegs struct {
+	spi spi.Conn
+}
+
+// The SPI address byte has the register in bits 1 to 6, and bit 7 set to
+// read.  Reading n bytes sends the address n times, then a zero.
+func (r spiRegs) read(reg byte, n int) (data []byte, err error) {
+	tx := make([]byte, n+1)
+	for i := 0; i < n; i++ {
+		tx[i] = 0x80 | reg<<1
+	}
+	rx, err := r.spi.Transfer(tx)
+	if err != nil {
+		return
+	}
+	return rx[1:], nil
+}
+
+func (r spiRegs) write(reg byte, data []byte) error {
+	return r.spi.Write(append([]byte{reg << 1}, data...))
+}
+
+func (r spiRegs) close() error {
+	return r.spi.Close()
+}
+
+// Over I2C the register address isn't incremented as bytes are read or
+// written, which suits the FIFO.  SMBus block transfers are limited to 32
+// bytes, so the 64 byte FIFO takes two.
+type i2cRegs struct {
+	conn i2c.Conn
+}
+
+func (r i2cRegs) read(reg byte, n int) (data []byte, err error) {
+	for len(data) < n {
+		chunk := n - len(data)
+		if chunk > 32 {
+			chunk = 32
+		}
+		b, err := r.conn.Read(reg, byte(chunk))
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (r i2cRegs) write(reg byte, data []byte) (err error) {
+	for len(data) > 0 {
+		chunk := len(data)
+		if chunk > 32 {
+			chunk = 32
+		}
+		if err = r.conn.Write(reg, data[:chunk]); err != nil {
+			return
+		}
+		data = data[chunk:]
+	}
+	return
+}
+
+func (r i2cRegs) close() error {
+	if c, ok := r.conn.(interface{ Close() error }); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// A Reader is an MFRC522.
+type Reader struct {
+	lock    sync.Mutex
+	regs    regs
+	rst     gpio.DigitalPin
+	ownRst  bool
+	version byte
+}
+
+// resetPin exports the reset pin, if there is one.
+func resetPin(pin int) (rst *gpio.GPIO, err error) {
+	if pin < 0 {
+		return
+	}
+	return gpio.Export(pin)
+}
+
+// New returns the reader on the given spidev bus and chip select, with its
+// reset pin on the given GPIO, or -1 if it isn't connected.
+func New(bus, cs, rstPin int) (rd *Reader, err error) {
+	s, err := spi.Open(bus, cs)
+	if err != nil {
+		return
+	}
+	rst, err := resetPin(rstPin)
+	if err != nil {
+		s.Close()
+		return
+	}
+	if rst != nil {
+		rd, err = NewConn(s, rst)
+	} else {
+		rd, err = NewConn(s, nil)
+	}
+	if err != nil {
+		s.Close()
+		if rst != nil {
+			rst.Unexport()
+		}
+		return nil, err
+	}
+	rd.ownRst = rst != nil
+
+	return
+}
+
+// NewConn is like New, but uses an already open SPI connection and reset
+// pin, which may be nil.  The connection is closed along with the reader.
+func NewConn(s spi.Conn, rst gpio.DigitalPin) (rd *Reader, err error) {
+	if err = s.SetSpeed(4000000); err != nil {
+		return
+	}
+	return newReader(spiRegs{s}, rst)
+}
+
+// NewI2C returns the reader at the given address on an I2C bus, with its
+// reset pin on the given GPIO, or -1.  The module must be wired for I2C,
+// which most RC522 boards aren't without modification.
+func NewI2C(addr, bus byte, rstPin int) (rd *Reader, err error) {
+	dev, err := i2c.NewDevice(addr, bus)
+	if err != nil {
+		return
+	}
+	rst, err := resetPin(rstPin)
+	if err != nil {
+		dev.Close()
+		return
+	}
+	if rst != nil {
+		rd, err = NewI2CConn(dev, rst)
+	} else {
+		rd, err = NewI2CConn(dev, nil)
+	}
+	if err != nil {
+		dev.Close()
+		if rst != nil {
+			rst.Unexport()
+		}
+		return nil, err
+	}
+	rd.ownRst = rst != nil
+
+	return
+}
+
+// NewI2CConn is like NewI2C, but talks to the chip through conn, which may be
+// a fake for testing.
+func NewI2CConn(conn i2c.Conn, rst gpio.DigitalPin) (*Reader, error) {
+	return newReader(i2cRegs{conn}, rst)
+}
+
+func newReader(r regs, rst gpio.DigitalPin) (rd *Reader, err error) {
+	rd = &Reader{regs: r, rst: rst}
+	if err = rd.Reset(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (rd *Reader) readReg(reg byte) (value byte, err error) {
+	data, err := rd.regs.read(reg, 1)
+	if err != nil {
+		return
+	}
+	return data[0], nil
+}
+
+func (rd *Reader) writeReg(reg, value byte) error {
+	return rd.regs.write(reg, []byte{value})
+}
+
+// updateReg sets the bits of a register selected by mask to value.
+func (rd *Reader) updateReg(reg, mask, value byte) (err error) {
+	v, err := rd.readReg(reg)
+	if err != nil {
+		return
+	}
+	return rd.writeReg(reg, v&^mask|value&mask)
+}
+
+// Reset resets the chip, by its reset pin if it has one, and sets it up:
+// a 25ms timeout for card responses, 100% ASK modulation, and the antenna
+// on.
+func (rd *Reader) Reset() (err error) {
+	rd.lock.Lock()
+	defer rd.lock.Unlock()
+
+	if rd.rst != nil {
+		if err = rd.rst.SetDirection(gpio.Out); err != nil {
+			return
+		}
+		if err = rd.rst.SetValue(0); err != nil {
+			return
+		}
+		time.Sleep(time.Millisecond)
+		if err = rd.rst.SetValue(1); err != nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	} else {
+		if err = rd.writeReg(CommandReg, PCD_SOFT_RESET); err != nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if rd.version, err = rd.readReg(VersionReg); err != nil {
+		return
+	}
+	if rd.version == 0x00 || rd.version == 0xff {
+		return ErrNotFound
+	}
+
+	for _, rv := range [][2]byte{
+		{TxModeReg, 0x00},
+		{RxModeReg, 0x00},
+		{ModWidthReg, 0x26},
+		// The timer starts when sending ends, and counts 1000 ticks of
+		// 13.56MHz/(2*0xa9+1), about 25ms
+		{TModeReg, 0x80},
+		{TPrescalerReg, 0xa9},
+		{TReloadRegH, 0x03},
+		{TReloadRegL, 0xe8},
+		{TxASKReg, 0x40},
+		// CRC preset 0x6363, as ISO 14443-3 says
+		{ModeReg, 0x3d},
+	} {
+		if err = rd.writeReg(rv[0], rv[1]); err != nil {
+			return
+		}
+	}
+	return rd.updateReg(TxControlReg, 0x03, 0x03)
+}
+
+// Version returns the chip's version register: 0x91 or 0x92 for versions 1.0
+// and 2.0, and other values for clones.
+func (rd *Reader) Version() byte {
+	return rd.version
+}
+
+// SetGain sets the receiver gain, from 0 (18dB) to 7 (48dB).  Higher gains
+// read cards from further away.
+func (rd *Reader) SetGain(gain int) (err error) {
+	if gain < 0 || gain > 7 {
+		return fmt.Errorf("Invalid gain: %d", gain)
+	}
+	rd.lock.Lock()
+	defer rd.lock.Unlock()
+	return rd.updateReg(RFCfgReg, 0x70, byte(gain)<<4)
+}
+
+// SetAntenna switches the antenna on or off.  Off saves power between reads.
+func (rd *Reader) SetAntenna(on bool) (err error) {
+	var v byte
+	if on {
+		v = 0x03
+	}
+	rd.lock.Lock()
+	defer rd.lock.Unlock()
+	return rd.updateReg(TxControlReg, 0x03, v)
+}
+
+// command runs a command with data in the FIFO, waiting for any of the
+// interrupts in wait, and returns what is left in the FIFO afterwards along
+// with the number of valid bits in its last byte, or 0 if all are.
+func (rd *Reader) command(cmd byte, data []byte, txLastBits byte, wait byte) (back []byte, rxLastBits int, err error) {
+	for _, rv := range [][2]byte{
+		{CommandReg, PCD_IDLE},
+		{ComIrqReg, 0x7f},
+		{FIFOLevelReg, 0x80},
+	} {
+		if err = rd.writeReg(rv[0], rv[1]); err != nil {
+			return
+		}
+	}
+	if err = rd.regs.write(FIFODataReg, data); err != nil {
+		return
+	}
+	if err = rd.writeReg(BitFramingReg, txLastBits); err != nil {
+		return
+	}
+	if err = rd.writeReg(CommandReg, cmd); err != nil {
+		return
+	}
+	if cmd == PCD_TRANSCEIVE {
+		// StartSend
+		if err = rd.updateReg(BitFramingReg, 0x80, 0x80); err != nil {
+			return
+		}
+	}
+
+	deadline := time.Now().Add(40 * time.Millisecond)
+	for {
+		irq, e := rd.readReg(ComIrqReg)
+		if e != nil {
+			return nil, 0, e
+		}
+		if irq&wait != 0 {
+			break
+		}
+		if irq&IRQ_TIMER != 0 || time.Now().After(deadline) {
+			return nil, 0, ErrNoCard
+		}
+	}
+	rd.updateReg(BitFramingReg, 0x80, 0)
+
+	errs, err := rd.readReg(ErrorReg)
+	if err != nil {
+		return
+	}
+	if errs&(ERR_OVERFLOW|ERR_PARITY|ERR_PROTOCOL) != 0 {
+		return nil, 0, ErrCommunication
+	}
+	if errs&ERR_COLL != 0 {
+		return nil, 0, ErrCollision
+	}
+	if cmd != PCD_TRANSCEIVE {
+		return
+	}
+
+	n, err := rd.readReg(FIFOLevelReg)
+	if err != nil || n == 0 {
+		return
+	}
+	if back, err = rd.regs.read(FIFODataReg, int(n&0x7f)); err != nil {
+		return
+	}
+	control, err := rd.readReg(ControlReg)
+	rxLastBits = int(control & 0x07)
+	return
+}
+
+// crc calculates the ISO 14443 CRC of data with the chip's coprocessor.
+func (rd *Reader) crc(data []byte) (crc [2]byte, err error) {
+	for _, rv := range [][2]byte{
+		{CommandReg, PCD_IDLE},
+		{DivIrqReg, IRQ_CRC},
+		{FIFOLevelReg, 0x80},
+	} {
+		if err = rd.writeReg(rv[0], rv[1]); err != nil {
+			return
+		}
+	}
+	if err = rd.regs.write(FIFODataReg, data); err != nil {
+		return
+	}
+	if err = rd.writeReg(CommandReg, PCD_CALC_CRC); err != nil {
+		return
+	}
+	deadline := time.Now().Add(90 * time.Millisecond)
+	for {
+		irq, e := rd.readReg(DivIrqReg)
+		if e != nil {
+			return crc, e
+		}
+		if irq&IRQ_CRC != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return crc, errors.New("CRC calculation timed out")
+		}
+	}
+	rd.writeReg(CommandReg, PCD_IDLE)
+	if crc[0], err = rd.readReg(CRCResultRegL); err != nil {
+		return
+	}
+	crc[1], err = rd.readReg(CRCResultRegH)
+	return
+}
+
+// Close switches the antenna off and closes the connection, and if the
+// reader came from New or NewI2C, unexports its reset pin.
+func (rd *Reader) Close() (err error) {
+	rd.SetAntenna(false)
+	rd.lock.Lock()
+	defer rd.lock.Unlock()
+	err = rd.regs.close()
+	if rd.ownRst {
+		rd.rst.Unexport()
+	}
+	return
+}
@@ -0,0 +1,93 @@
+package mfrc522
+
+import (
+	"bytes"
+	"time"
+)
+
+// An Event is a card arriving at or leaving the reader.
+type Event struct {
+	Card    *Card
+	Present bool
+}
+
+// A Watcher polls a reader for cards, sending an Event on C when one
+// arrives and when it leaves.
+type Watcher struct {
+	C <-chan Event
+
+	c    chan Event
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Watch starts polling for cards every interval; 100ms feels immediate.  A
+// card is taken to have left after it has been missed twice, since reads
+// at the edge of the field fail now and then.
+func (rd *Reader) Watch(interval time.Duration) *Watcher {
+	w := &Watcher{
+		c:    make(chan Event, 4),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	w.C = w.c
+	go w.run(rd, interval)
+	return w
+}
+
+func (w *Watcher) run(rd *Reader, interval time.Duration) {
+	defer close(w.done)
+	defer close(w.c)
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	var current *Card
+	missed := 0
+	for {
+		card, err := rd.Detect()
+		switch {
+		case err == nil && (current == nil || !bytes.Equal(card.UID, current.UID)):
+			if current != nil && !w.send(Event{Card: current}) {
+				return
+			}
+			current, missed = card, 0
+			if !w.send(Event{Card: card, Present: true}) {
+				return
+			}
+		case err == nil:
+			missed = 0
+		case current != nil:
+			if missed++; missed >= 2 {
+				if !w.send(Event{Card: current}) {
+					return
+				}
+				current = nil
+			}
+		}
+		if err == nil {
+			rd.Halt()
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (w *Watcher) send(ev Event) bool {
+	select {
+	case w.c <- ev:
+		return true
+	case <-w.stop:
+		return false
+	}
+}
+
+// Close stops the Watcher and closes C.
+func (w *Watcher) Close() {
+	close(w.stop)
+	<-w.done
+}
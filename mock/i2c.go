@@ -0,0 +1,134 @@
+package mock
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/i2c"
+	"sync"
+)
+
+// A Device is a simulated I2C device with 256 byte-wide registers, which
+// auto-increment on block transfers as most devices' do.  Devices without
+// registers, such as the PCF8574, are simulated with Byte instead.
+//
+// Tests may set up Regs and Byte before handing the Device to a driver, and
+// use OnRead and OnWrite to simulate a device's behaviour, for example
+// clearing a status bit once it has been read.  Both hooks are called with
+// the Device's lock held, so they may modify Regs and Byte but must not call
+// the Device's methods.
+type Device struct {
+	lock sync.Mutex
+
+	Regs [256]byte
+	Byte byte
+
+	// NAK makes every transfer fail with i2c.ErrNAK, as if the device was
+	// missing.
+	NAK bool
+
+	// OnRead is called before n registers are read, starting at reg.
+	OnRead func(reg byte, n int)
+	// OnWrite is called after data has been written to the registers
+	// starting at reg.
+	OnWrite func(reg byte, data []byte)
+}
+
+var _ i2c.Conn = (*Device)(nil)
+
+// NewDevice returns a Device whose registers are all zero.
+func NewDevice() *Device {
+	return new(Device)
+}
+
+func (dev *Device) nak() (err error) {
+	if dev.NAK {
+		err = fmt.Errorf("%w: simulated", i2c.ErrNAK)
+	}
+	return
+}
+
+// Read reads readLength consecutive registers, starting at reg.
+func (dev *Device) Read(reg byte, readLength byte) (list []byte, err error) {
+	dev.lock.Lock()
+	defer dev.lock.Unlock()
+	if err = dev.nak(); err != nil {
+		return
+	}
+	if readLength > i2c.I2C_SMBUS_BLOCK_MAX {
+		err = fmt.Errorf("Read too long: %d", readLength)
+		return
+	}
+
+	if dev.OnRead != nil {
+		dev.OnRead(reg, int(readLength))
+	}
+	list = make([]byte, readLength)
+	for i := range list {
+		list[i] = dev.Regs[reg+byte(i)]
+	}
+
+	return
+}
+
+// Write writes list to consecutive registers, starting at reg.
+func (dev *Device) Write(reg byte, list []byte) (err error) {
+	dev.lock.Lock()
+	defer dev.lock.Unlock()
+	if err = dev.nak(); err != nil {
+		return
+	}
+	if len(list) > i2c.I2C_SMBUS_BLOCK_MAX {
+		err = fmt.Errorf("Write too long: %d", len(list))
+		return
+	}
+
+	for i, v := range list {
+		dev.Regs[reg+byte(i)] = v
+	}
+	if dev.OnWrite != nil {
+		dev.OnWrite(reg, append([]byte(nil), list...))
+	}
+
+	return
+}
+
+// WriteI2C is the same as Write.
+func (dev *Device) WriteI2C(reg byte, list []byte) error {
+	return dev.Write(reg, list)
+}
+
+// ReadReg reads a single register.
+func (dev *Device) ReadReg(reg byte) (value byte, err error) {
+	list, err := dev.Read(reg, 1)
+	if err == nil {
+		value = list[0]
+	}
+
+	return
+}
+
+// WriteReg writes a single register.
+func (dev *Device) WriteReg(reg byte, value byte) error {
+	return dev.Write(reg, []byte{value})
+}
+
+// ReadByte returns Byte.
+func (dev *Device) ReadByte() (value byte, err error) {
+	dev.lock.Lock()
+	defer dev.lock.Unlock()
+	if err = dev.nak(); err != nil {
+		return
+	}
+
+	return dev.Byte, nil
+}
+
+// WriteByte sets Byte.
+func (dev *Device) WriteByte(value byte) (err error) {
+	dev.lock.Lock()
+	defer dev.lock.Unlock()
+	if err = dev.nak(); err == nil {
+		dev.Byte = value
+	}
+
+	return
+}
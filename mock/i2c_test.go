@@ -0,0 +1,102 @@
+package mock
+
+import (
+	"bytes"
+	"errors"
+	"github.com/Ratfink/gopherbone/i2c"
+	"testing"
+)
+
+func TestDevice(t *testing.T) {
+	dev := NewDevice()
+	dev.Regs[0x10] = 0xaa
+
+	// Block transfers auto-increment, wrapping at the last register
+	if err := dev.Write(0xfe, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if dev.Regs[0xfe] != 1 || dev.Regs[0xff] != 2 || dev.Regs[0x00] != 3 {
+		t.Errorf("Write wrote % x ... % x", dev.Regs[0:1], dev.Regs[0xfe:])
+	}
+	if list, err := dev.Read(0xff, 2); err != nil || !bytes.Equal(list, []byte{2, 3}) {
+		t.Errorf("Read = % x, %v", list, err)
+	}
+	if v, err := dev.ReadReg(0x10); err != nil || v != 0xaa {
+		t.Errorf("ReadReg = %#x, %v", v, err)
+	}
+	if err := dev.WriteReg(0x10, 0x55); err != nil || dev.Regs[0x10] != 0x55 {
+		t.Errorf("WriteReg: %v", err)
+	}
+
+	// Byte is separate from the registers
+	if err := dev.WriteByte(0x42); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := dev.ReadByte(); err != nil || v != 0x42 {
+		t.Errorf("ReadByte = %#x, %v", v, err)
+	}
+
+	if _, err := dev.Read(0, i2c.I2C_SMBUS_BLOCK_MAX+1); err == nil {
+		t.Errorf("Read longer than a block succeeded")
+	}
+	if err := dev.Write(0, make([]byte, i2c.I2C_SMBUS_BLOCK_MAX+1)); err == nil {
+		t.Errorf("Write longer than a block succeeded")
+	}
+}
+
+func TestDeviceHooks(t *testing.T) {
+	dev := NewDevice()
+	// A FIFO register, which holds a new value for each read, and a
+	// command register which resets it
+	dev.OnRead = func(reg byte, n int) {
+		if reg == 0 {
+			dev.Regs[0]++
+		}
+	}
+	var writes [][]byte
+	dev.OnWrite = func(reg byte, data []byte) {
+		writes = append(writes, data)
+		if reg == 1 {
+			dev.Regs[0] = 0
+		}
+	}
+
+	for i := 1; i <= 3; i++ {
+		if v, _ := dev.ReadReg(0); int(v) != i {
+			t.Errorf("Read %d of the FIFO = %d", i, v)
+		}
+	}
+	if err := dev.Write(1, []byte{0x01, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	if len(writes) != 1 || !bytes.Equal(writes[0], []byte{0x01, 0x02}) {
+		t.Errorf("OnWrite saw %v", writes)
+	}
+	if v, _ := dev.ReadReg(0); v != 1 {
+		t.Errorf("Read after reset = %d, want 1", v)
+	}
+}
+
+func TestDeviceNAK(t *testing.T) {
+	dev := NewDevice()
+	dev.NAK = true
+	tests := []struct {
+		name string
+		f    func() error
+	}{
+		{"Read", func() error { _, err := dev.Read(0, 1); return err }},
+		{"Write", func() error { return dev.Write(0, []byte{1}) }},
+		{"ReadReg", func() error { _, err := dev.ReadReg(0); return err }},
+		{"WriteReg", func() error { return dev.WriteReg(0, 1) }},
+		{"ReadByte", func() error { _, err := dev.ReadByte(); return err }},
+		{"WriteByte", func() error { return dev.WriteByte(1) }},
+	}
+	for _, test := range tests {
+		if err := test.f(); !errors.Is(err, i2c.ErrNAK) {
+			t.Errorf("%s: %v, want %v", test.name, err, i2c.ErrNAK)
+		}
+	}
+	if dev.Regs[0] != 0 || dev.Byte != 0 {
+		t.Errorf("Writes took effect despite the NAK")
+	}
+}
@@ -0,0 +1,277 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* The mock package provides in-memory stand-ins for the hardware used by the
+ * rest of GopherBone, so that code using it can be tested off the board.  A
+ * Pin is a gpio.DigitalPin, a Device is an i2c.Conn backed by a register
+ * array, an SPI is an spi.Conn which records what is sent, and an SSD1306 is
+ * an i2c.Conn which interprets the display's commands and renders its memory
+ * to an image.
+ */
+package mock
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"os"
+	"sync"
+	"time"
+)
+
+// A Pin is a simulated GPIO.  Like a sysfs GPIO, it starts as an input; its
+// level as an input is set with Drive, as if by the outside world.
+type Pin struct {
+	lock       sync.Mutex
+	level      int
+	dir        gpio.Direction
+	activeLow  bool
+	debounce   time.Duration
+	unexported bool
+	watches    []*pinWatch
+}
+
+// A pinWatch feeds a Watcher from its own goroutine, so that Drive never
+// blocks on a consumer for longer than it takes to queue a value.
+type pinWatch struct {
+	edge   gpio.Edge
+	events chan int
+	stop   chan struct{}
+	once   sync.Once
+}
+
+var _ gpio.DigitalPin = (*Pin)(nil)
+
+// NewPin returns a Pin configured as an input at level 0.
+func NewPin() *Pin {
+	return &Pin{dir: gpio.In}
+}
+
+func (pin *Pin) check() (err error) {
+	if pin.unexported {
+		err = gpio.ErrNotExported
+	}
+	return
+}
+
+// logical converts between the pin's level and its value.
+func (pin *Pin) logical(v int) int {
+	if pin.activeLow {
+		return 1 - v
+	}
+	return v
+}
+
+// Value returns the pin's value, taking active low into account.
+func (pin *Pin) Value() (value int, err error) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	if err = pin.check(); err != nil {
+		return
+	}
+
+	return pin.logical(pin.level), nil
+}
+
+// SetValue sets the value of an output pin.  As with sysfs, setting the value
+// of an input is not permitted.
+func (pin *Pin) SetValue(value int) (err error) {
+	if value != 0 && value != 1 {
+		err = fmt.Errorf("%w: %d", gpio.ErrInvalidValue, value)
+		return
+	}
+
+	pin.lock.Lock()
+	if err = pin.check(); err == nil && pin.dir != gpio.Out {
+		err = fmt.Errorf("Cannot set value of an input: %w", os.ErrPermission)
+	}
+	pin.lock.Unlock()
+	if err != nil {
+		return
+	}
+
+	pin.set(pin.logical(value))
+
+	return
+}
+
+// Level returns the pin's physical level, ignoring active low.
+func (pin *Pin) Level() int {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	return pin.level
+}
+
+// Drive sets the level of an input pin, as if driven by an external signal,
+// and notifies any Watchers.  It has no effect on an output.  Debouncing is
+// not simulated; each change is reported at once.
+func (pin *Pin) Drive(level int) {
+	pin.lock.Lock()
+	out := pin.dir == gpio.Out
+	pin.lock.Unlock()
+	if !out {
+		pin.set(level)
+	}
+}
+
+// set changes the pin's level and notifies Watchers of the new value.
+func (pin *Pin) set(level int) {
+	pin.lock.Lock()
+	changed := level != pin.level
+	pin.level = level
+	value := pin.logical(level)
+	watches := append([]*pinWatch(nil), pin.watches...)
+	pin.lock.Unlock()
+
+	if !changed {
+		return
+	}
+	for _, w := range watches {
+		if (w.edge == gpio.Rising && value != 1) || (w.edge == gpio.Falling && value != 0) {
+			continue
+		}
+		select {
+		case w.events <- value:
+		case <-w.stop:
+		}
+	}
+}
+
+// Direction returns the pin's direction.
+func (pin *Pin) Direction() (dir gpio.Direction, err error) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	if err = pin.check(); err != nil {
+		return
+	}
+
+	return pin.dir, nil
+}
+
+// SetDirection sets the pin's direction.  As with sysfs, an output starts
+// low.
+func (pin *Pin) SetDirection(dir gpio.Direction) (err error) {
+	if dir != gpio.In && dir != gpio.Out {
+		err = fmt.Errorf("%w: %s", gpio.ErrInvalidDirection, dir)
+		return
+	}
+
+	pin.lock.Lock()
+	if err = pin.check(); err == nil {
+		pin.dir = dir
+	}
+	pin.lock.Unlock()
+	if err == nil && dir == gpio.Out {
+		pin.set(0)
+	}
+
+	return
+}
+
+// SetActiveLow sets whether the pin's value is inverted.
+func (pin *Pin) SetActiveLow(activeLow bool) (err error) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	if err = pin.check(); err == nil {
+		pin.activeLow = activeLow
+	}
+
+	return
+}
+
+// Debounce records the debounce period, which DebouncePeriod returns.
+func (pin *Pin) Debounce(d time.Duration) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	pin.debounce = d
+}
+
+// DebouncePeriod returns the period last passed to Debounce.
+func (pin *Pin) DebouncePeriod() time.Duration {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	return pin.debounce
+}
+
+// Watch starts a Watcher which receives the pin's value each time it changes
+// on the given edge.
+func (pin *Pin) Watch(edge gpio.Edge) (w *gpio.Watcher, err error) {
+	if edge != gpio.Rising && edge != gpio.Falling && edge != gpio.Both {
+		err = fmt.Errorf("%w: %s", gpio.ErrInvalidEdge, edge)
+		return
+	}
+
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	if err = pin.check(); err != nil {
+		return
+	}
+
+	pw := &pinWatch{edge: edge, events: make(chan int, 16), stop: make(chan struct{})}
+	w, values := gpio.NewWatcher(func() {
+		pw.once.Do(func() {
+			pin.unwatch(pw)
+			close(pw.stop)
+		})
+	})
+	pin.watches = append(pin.watches, pw)
+
+	go func() {
+		defer close(values)
+		for {
+			select {
+			case v := <-pw.events:
+				select {
+				case values <- v:
+				case <-pw.stop:
+					return
+				}
+			case <-pw.stop:
+				return
+			}
+		}
+	}()
+
+	return
+}
+
+func (pin *Pin) unwatch(pw *pinWatch) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	for i, w := range pin.watches {
+		if w == pw {
+			pin.watches = append(pin.watches[:i], pin.watches[i+1:]...)
+			break
+		}
+	}
+}
+
+// Unexport marks the pin as unexported, after which its methods return
+// gpio.ErrNotExported.
+func (pin *Pin) Unexport() (err error) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	if err = pin.check(); err == nil {
+		pin.unexported = true
+	}
+
+	return
+}
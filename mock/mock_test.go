@@ -0,0 +1,131 @@
+package mock
+
+import (
+	"errors"
+	"github.com/Ratfink/gopherbone/gpio"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPin(t *testing.T) {
+	pin := NewPin()
+	if dir, err := pin.Direction(); err != nil || dir != gpio.In {
+		t.Errorf("New pin is %s, %v, want %s", dir, err, gpio.In)
+	}
+
+	// Inputs follow Drive and can't be set
+	pin.Drive(1)
+	if v, err := pin.Value(); err != nil || v != 1 {
+		t.Errorf("Value after Drive(1) = %d, %v", v, err)
+	}
+	if err := pin.SetValue(0); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("SetValue on an input: %v", err)
+	}
+
+	// Outputs start low, and ignore Drive
+	if err := pin.SetDirection(gpio.Out); err != nil {
+		t.Fatal(err)
+	}
+	if level := pin.Level(); level != 0 {
+		t.Errorf("New output at level %d", level)
+	}
+	if err := pin.SetValue(1); err != nil {
+		t.Fatal(err)
+	}
+	pin.Drive(0)
+	if level := pin.Level(); level != 1 {
+		t.Errorf("Drive changed an output to %d", level)
+	}
+
+	// Active low inverts the value, but not the level
+	if err := pin.SetActiveLow(true); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := pin.Value(); v != 0 {
+		t.Errorf("Active low value %d at level 1", v)
+	}
+	if err := pin.SetValue(1); err != nil {
+		t.Fatal(err)
+	}
+	if level := pin.Level(); level != 0 {
+		t.Errorf("Active low value 1 at level %d", level)
+	}
+
+	pin.Debounce(20 * time.Millisecond)
+	if d := pin.DebouncePeriod(); d != 20*time.Millisecond {
+		t.Errorf("DebouncePeriod = %v", d)
+	}
+}
+
+func TestPinErrors(t *testing.T) {
+	pin := NewPin()
+	if err := pin.SetValue(2); !errors.Is(err, gpio.ErrInvalidValue) {
+		t.Errorf("SetValue(2): %v", err)
+	}
+	if err := pin.SetDirection(gpio.Direction("sideways")); !errors.Is(err, gpio.ErrInvalidDirection) {
+		t.Errorf("SetDirection(sideways): %v", err)
+	}
+	if _, err := pin.Watch(gpio.Edge("none")); !errors.Is(err, gpio.ErrInvalidEdge) {
+		t.Errorf("Watch(none): %v", err)
+	}
+
+	if err := pin.Unexport(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pin.Value(); !errors.Is(err, gpio.ErrNotExported) {
+		t.Errorf("Value after Unexport: %v", err)
+	}
+	if err := pin.SetDirection(gpio.Out); !errors.Is(err, gpio.ErrNotExported) {
+		t.Errorf("SetDirection after Unexport: %v", err)
+	}
+	if _, err := pin.Watch(gpio.Both); !errors.Is(err, gpio.ErrNotExported) {
+		t.Errorf("Watch after Unexport: %v", err)
+	}
+	if err := pin.Unexport(); !errors.Is(err, gpio.ErrNotExported) {
+		t.Errorf("Second Unexport: %v", err)
+	}
+}
+
+func TestPinWatch(t *testing.T) {
+	tests := []struct {
+		edge      gpio.Edge
+		activeLow bool
+		want      []int
+	}{
+		{gpio.Both, false, []int{1, 0, 1, 0}},
+		{gpio.Rising, false, []int{1, 1}},
+		{gpio.Falling, false, []int{0, 0}},
+		{gpio.Rising, true, []int{1, 1}},
+	}
+	for _, test := range tests {
+		pin := NewPin()
+		pin.SetActiveLow(test.activeLow)
+		w, err := pin.Watch(test.edge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Repeated levels aren't edges
+		for _, level := range []int{1, 1, 0, 1, 0, 0} {
+			pin.Drive(level)
+		}
+		for i, want := range test.want {
+			select {
+			case v := <-w.C:
+				if v != want {
+					t.Errorf("%s, active low %v: Value %d is %d, want %d", test.edge, test.activeLow, i, v, want)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s, active low %v: Only %d values", test.edge, test.activeLow, i)
+			}
+		}
+		if err = w.Close(); err != nil {
+			t.Errorf("%s: Close: %v", test.edge, err)
+		}
+		if _, ok := <-w.C; ok {
+			t.Errorf("%s: Extra value, or C left open", test.edge)
+		}
+		// Closed Watchers are forgotten
+		pin.Drive(1 - pin.Level())
+	}
+}
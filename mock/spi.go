@@ -0,0 +1,99 @@
+package mock
+
+import (
+	"errors"
+	"github.com/Ratfink/gopherbone/spi"
+	"sync"
+)
+
+// An SPI is a simulated SPI device which records everything sent to it.
+type SPI struct {
+	lock sync.Mutex
+
+	Mode  uint8
+	Bits  uint8
+	Speed uint32
+
+	// Sent holds the data of each transfer, in order.
+	Sent [][]byte
+
+	// Respond, if set, returns the bytes received during a transfer of tx,
+	// which must be the same length.  Otherwise zeros are received.
+	Respond func(tx []byte) []byte
+
+	closed bool
+}
+
+var _ spi.Conn = (*SPI)(nil)
+
+// NewSPI returns an SPI in mode 0 at 1MHz with 8 bits per word, as spi.Open
+// does.
+func NewSPI() *SPI {
+	return &SPI{Bits: 8, Speed: 1000000}
+}
+
+var errClosed = errors.New("SPI device closed")
+
+// SetMode sets Mode.
+func (s *SPI) SetMode(mode uint8) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Mode = mode
+	return nil
+}
+
+// SetBitsPerWord sets Bits.
+func (s *SPI) SetBitsPerWord(bits uint8) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Bits = bits
+	return nil
+}
+
+// SetSpeed sets Speed.
+func (s *SPI) SetSpeed(hz uint32) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Speed = hz
+	return nil
+}
+
+// Transfer records tx and returns the response from Respond.
+func (s *SPI) Transfer(tx []byte) (rx []byte, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		err = errClosed
+		return
+	}
+
+	s.Sent = append(s.Sent, append([]byte(nil), tx...))
+	if s.Respond != nil {
+		rx = s.Respond(tx)
+	} else {
+		rx = make([]byte, len(tx))
+	}
+
+	return
+}
+
+// Write records data.
+func (s *SPI) Write(data []byte) (err error) {
+	_, err = s.Transfer(data)
+	return
+}
+
+// Close marks the device closed, after which transfers fail.
+func (s *SPI) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Closed reports whether Close has been called.
+func (s *SPI) Closed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closed
+}
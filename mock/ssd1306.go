@@ -0,0 +1,282 @@
+package mock
+
+import (
+	"errors"
+	"github.com/Ratfink/gopherbone/i2c"
+	"github.com/Ratfink/gopherbone/ssd1306"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"sync"
+)
+
+// ssd1306Args gives the number of argument bytes following each command
+// which takes any.
+var ssd1306Args = map[byte]int{
+	ssd1306.CONTRAST:             1,
+	ssd1306.ADDRESS_MODE:         1,
+	ssd1306.COLUMN_ADDRESS:       2,
+	ssd1306.PAGE_ADDRESS:         2,
+	ssd1306.MUX_RATIO:            1,
+	ssd1306.VERT_SHIFT:           1,
+	ssd1306.COM_CONFIG:           1,
+	ssd1306.CLOCK_FREQ:           1,
+	ssd1306.PRECHARGE:            1,
+	ssd1306.VCOMH_DESELECT_LEVEL: 1,
+	ssd1306.CHARGE_PUMP:          1,
+	ssd1306.VSCROLL:              2,
+	ssd1306.HSCROLL_RIGHT:        6,
+	ssd1306.HSCROLL_LEFT:         6,
+	ssd1306.VHSCROLL_RIGHT:       5,
+	ssd1306.VHSCROLL_LEFT:        5,
+}
+
+var errWriteOnly = errors.New("SSD1306 is write only over I2C")
+
+// An SSD1306 is a simulated SSD1306 OLED controller, connected by I2C.  It
+// interprets the commands and data written to it much as the real chip does,
+// and renders its display memory with Image.  Scrolling, multiplex ratio and
+// display offset are accepted but not simulated.
+type SSD1306 struct {
+	lock sync.Mutex
+
+	width, height int
+	ram           [][]byte
+
+	// Address pointer and addressing window
+	mode               byte
+	col, page          int
+	colStart, colEnd   int
+	pageStart, pageEnd int
+
+	on, inverse, allOn   bool
+	segRemap, comReverse bool
+	startLine            int
+	contrast             byte
+
+	// Partly received command
+	cmd []byte
+}
+
+var _ i2c.Conn = (*SSD1306)(nil)
+var _ i2c.RawWriter = (*SSD1306)(nil)
+
+// NewSSD1306 returns a simulated display of the given size, in the state the
+// real chip is in after a reset: off, in page addressing mode, with its
+// memory cleared.
+func NewSSD1306(width, height int) *SSD1306 {
+	disp := &SSD1306{
+		width:    width,
+		height:   height,
+		ram:      make([][]byte, height/8),
+		mode:     ssd1306.ADDRESS_MODE_PAGE,
+		colEnd:   width - 1,
+		pageEnd:  height/8 - 1,
+		contrast: 0x7f,
+	}
+	for i := range disp.ram {
+		disp.ram[i] = make([]byte, width)
+	}
+
+	return disp
+}
+
+// Write interprets list according to the control byte reg: commands if bit 6
+// is clear, display data if it is set.  If the continuation bit, bit 7, is
+// set, only one byte follows each control byte.
+func (disp *SSD1306) Write(reg byte, list []byte) error {
+	disp.lock.Lock()
+	defer disp.lock.Unlock()
+
+	for len(list) > 0 {
+		n := len(list)
+		if reg&0x80 != 0 {
+			n = 1
+		}
+		for _, b := range list[:n] {
+			if reg&0x40 != 0 {
+				disp.data(b)
+			} else {
+				disp.command(b)
+			}
+		}
+		list = list[n:]
+		if len(list) > 0 {
+			reg, list = list[0], list[1:]
+		}
+	}
+
+	return nil
+}
+
+// WriteI2C is the same as Write.
+func (disp *SSD1306) WriteI2C(reg byte, list []byte) error {
+	return disp.Write(reg, list)
+}
+
+// WriteRaw interprets a plain I2C message, whose first byte is the control
+// byte.
+func (disp *SSD1306) WriteRaw(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return disp.Write(data[0], data[1:])
+}
+
+// WriteReg writes a single byte following the control byte reg.
+func (disp *SSD1306) WriteReg(reg byte, value byte) error {
+	return disp.Write(reg, []byte{value})
+}
+
+// Read always fails, since the SSD1306 can't be read over I2C.
+func (disp *SSD1306) Read(reg byte, readLength byte) ([]byte, error) {
+	return nil, errWriteOnly
+}
+
+// ReadReg always fails.
+func (disp *SSD1306) ReadReg(reg byte) (byte, error) {
+	return 0, errWriteOnly
+}
+
+// ReadByte always fails.
+func (disp *SSD1306) ReadByte() (byte, error) {
+	return 0, errWriteOnly
+}
+
+// WriteByte always fails, since every write needs a control byte.
+func (disp *SSD1306) WriteByte(value byte) error {
+	return errors.New("SSD1306 write is missing a control byte")
+}
+
+// command accumulates a command byte, carrying out the command once all of
+// its arguments have arrived.
+func (disp *SSD1306) command(b byte) {
+	disp.cmd = append(disp.cmd, b)
+	if len(disp.cmd) <= ssd1306Args[disp.cmd[0]] {
+		return
+	}
+	cmd := disp.cmd
+	disp.cmd = nil
+
+	switch c := cmd[0]; {
+	case c == ssd1306.CONTRAST:
+		disp.contrast = cmd[1]
+	case c == ssd1306.DISP_RAM:
+		disp.allOn = false
+	case c == ssd1306.DISP_ALL:
+		disp.allOn = true
+	case c == ssd1306.INVERSE_OFF:
+		disp.inverse = false
+	case c == ssd1306.INVERSE_ON:
+		disp.inverse = true
+	case c == ssd1306.DISP_OFF:
+		disp.on = false
+	case c == ssd1306.DISP_ON:
+		disp.on = true
+	case c == ssd1306.ADDRESS_MODE:
+		disp.mode = cmd[1] & 0x03
+	case c == ssd1306.COLUMN_ADDRESS:
+		disp.colStart, disp.colEnd = int(cmd[1])%disp.width, int(cmd[2])%disp.width
+		disp.col = disp.colStart
+	case c == ssd1306.PAGE_ADDRESS:
+		pages := len(disp.ram)
+		disp.pageStart, disp.pageEnd = int(cmd[1])%pages, int(cmd[2])%pages
+		disp.page = disp.pageStart
+	case c < 0x10:
+		disp.col = (disp.col&0xf0 | int(c)) % disp.width
+	case c < 0x20:
+		disp.col = (disp.col&0x0f | int(c&0x0f)<<4) % disp.width
+	case c&0xc0 == ssd1306.START_LINE:
+		disp.startLine = int(c & 0x3f)
+	case c == ssd1306.HORI_NORMAL:
+		disp.segRemap = false
+	case c == ssd1306.HORI_MIRROR:
+		disp.segRemap = true
+	case c == ssd1306.VERT_NORMAL:
+		disp.comReverse = false
+	case c == ssd1306.VERT_MIRROR:
+		disp.comReverse = true
+	case c&0xf8 == ssd1306.PAGE_START:
+		disp.page = int(c&0x07) % len(disp.ram)
+	}
+}
+
+// data writes a byte of display memory and advances the address pointer
+// according to the addressing mode.
+func (disp *SSD1306) data(b byte) {
+	disp.ram[disp.page][disp.col] = b
+
+	switch disp.mode {
+	case ssd1306.ADDRESS_MODE_HORI:
+		if disp.col++; disp.col > disp.colEnd {
+			disp.col = disp.colStart
+			if disp.page++; disp.page > disp.pageEnd {
+				disp.page = disp.pageStart
+			}
+		}
+	case ssd1306.ADDRESS_MODE_VERT:
+		if disp.page++; disp.page > disp.pageEnd {
+			disp.page = disp.pageStart
+			if disp.col++; disp.col > disp.colEnd {
+				disp.col = disp.colStart
+			}
+		}
+	default:
+		if disp.col++; disp.col >= disp.width {
+			disp.col = 0
+		}
+	}
+}
+
+// On reports whether the display is switched on.
+func (disp *SSD1306) On() bool {
+	disp.lock.Lock()
+	defer disp.lock.Unlock()
+	return disp.on
+}
+
+// Contrast returns the contrast last set.
+func (disp *SSD1306) Contrast() byte {
+	disp.lock.Lock()
+	defer disp.lock.Unlock()
+	return disp.contrast
+}
+
+// Image renders what the display would show.  Most modules are wired so that
+// the image is the right way round with the segment remap and reversed COM
+// scan direction set, as the ssd1306 package sets them, so the image is
+// mirrored if either is not.  A display which is off is black.
+func (disp *SSD1306) Image() *image.Gray {
+	disp.lock.Lock()
+	defer disp.lock.Unlock()
+
+	img := image.NewGray(image.Rect(0, 0, disp.width, disp.height))
+	if !disp.on {
+		return img
+	}
+	for row := 0; row < disp.height; row++ {
+		line := (row + disp.startLine) % disp.height
+		y := row
+		if !disp.comReverse {
+			y = disp.height - 1 - row
+		}
+		for col := 0; col < disp.width; col++ {
+			x := col
+			if !disp.segRemap {
+				x = disp.width - 1 - col
+			}
+			lit := disp.ram[line/8][col]&(1<<uint(line%8)) != 0
+			if (lit != disp.inverse) || disp.allOn {
+				img.SetGray(x, y, color.Gray{0xff})
+			}
+		}
+	}
+
+	return img
+}
+
+// WritePNG writes the rendered display to w as a PNG.
+func (disp *SSD1306) WritePNG(w io.Writer) error {
+	return png.Encode(w, disp.Image())
+}
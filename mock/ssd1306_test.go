@@ -0,0 +1,152 @@
+package mock
+
+import (
+	"bytes"
+	"github.com/Ratfink/gopherbone/ssd1306"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// lit returns the coordinates of the lit pixels of disp.
+func lit(disp *SSD1306) (pixels [][2]int) {
+	img := disp.Image()
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.GrayAt(x, y).Y != 0 {
+				pixels = append(pixels, [2]int{x, y})
+			}
+		}
+	}
+	return
+}
+
+func TestSSD1306(t *testing.T) {
+	// The orientation the ssd1306 package sets up
+	upright := []byte{ssd1306.DISP_ON, ssd1306.HORI_MIRROR, ssd1306.VERT_MIRROR}
+
+	tests := []struct {
+		name string
+		cmds []byte
+		data []byte
+		want [][2]int
+	}{
+		{"off", []byte{ssd1306.HORI_MIRROR, ssd1306.VERT_MIRROR}, []byte{0x01}, nil},
+		{"page mode", upright, []byte{0x01, 0x80}, [][2]int{{0, 0}, {1, 7}}},
+		{"page and column", append(upright, ssd1306.PAGE_START|1, 0x03, 0x10|0x01), []byte{0x02},
+			[][2]int{{19, 9}}},
+		{"horizontal mode wraps", append(upright, ssd1306.ADDRESS_MODE, ssd1306.ADDRESS_MODE_HORI,
+			ssd1306.COLUMN_ADDRESS, 4, 5, ssd1306.PAGE_ADDRESS, 0, 1), []byte{0x01, 0x01, 0x01},
+			[][2]int{{4, 0}, {5, 0}, {4, 8}}},
+		{"vertical mode", append(upright, ssd1306.ADDRESS_MODE, ssd1306.ADDRESS_MODE_VERT), []byte{0x01, 0x01, 0x01},
+			[][2]int{{0, 0}, {0, 8}, {1, 0}}},
+		{"not remapped", []byte{ssd1306.DISP_ON}, []byte{0x01}, [][2]int{{31, 15}}},
+		{"start line", append(upright, ssd1306.START_LINE|1), []byte{0x03}, [][2]int{{0, 0}, {0, 15}}},
+		{"inverse", append(upright, ssd1306.INVERSE_ON), nil, nil},
+		{"all on", append(upright, ssd1306.DISP_ALL), nil, nil},
+	}
+	for _, test := range tests {
+		disp := NewSSD1306(32, 16)
+		if err := disp.Write(0x00, test.cmds); err != nil {
+			t.Fatal(err)
+		}
+		if err := disp.Write(0x40, test.data); err != nil {
+			t.Fatal(err)
+		}
+		got := lit(disp)
+		switch test.name {
+		case "inverse", "all on":
+			// Every pixel of a blank screen
+			if len(got) != 32*16 {
+				t.Errorf("%s: %d pixels lit", test.name, len(got))
+			}
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: Lit %v, want %v", test.name, got, test.want)
+			continue
+		}
+		want := make(map[[2]int]bool)
+		for _, p := range test.want {
+			want[p] = true
+		}
+		for _, p := range got {
+			if !want[p] {
+				t.Errorf("%s: Lit %v, want %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSSD1306Control(t *testing.T) {
+	disp := NewSSD1306(32, 16)
+	// With the continuation bit set, each byte has its own control byte,
+	// and arguments may be split across them
+	msg := []byte{0x80, ssd1306.DISP_ON, 0x80, ssd1306.CONTRAST, 0x80, 0x20, 0xc0, 0xff, 0x40, 0x0f, 0x0f}
+	if err := disp.WriteRaw(msg); err != nil {
+		t.Fatal(err)
+	}
+	if !disp.On() {
+		t.Errorf("Display off")
+	}
+	if c := disp.Contrast(); c != 0x20 {
+		t.Errorf("Contrast = %#x, want 0x20", c)
+	}
+	if n := len(lit(disp)); n != 8+4+4 {
+		t.Errorf("%d pixels lit, want 16", n)
+	}
+
+	if _, err := disp.Read(0x00, 1); err == nil {
+		t.Errorf("Read succeeded")
+	}
+	if err := disp.WriteByte(ssd1306.DISP_OFF); err == nil {
+		t.Errorf("WriteByte succeeded")
+	}
+}
+
+func TestSSD1306Driver(t *testing.T) {
+	disp := NewSSD1306(128, 64)
+	d, err := ssd1306.NewConn(disp, nil, 128, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err = d.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if !disp.On() {
+		t.Errorf("Display off after Setup")
+	}
+
+	white := color.Gray16{0xffff}
+	d.Point(0, 0, white)
+	d.Point(127, 63, white)
+	d.Rectangle(10, 20, 12, 21, white)
+	if err = d.Draw(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(lit(disp)); n != 2+3*2 {
+		t.Errorf("%d pixels lit, want 8", n)
+	}
+
+	// The PNG decodes to the same image
+	var buf bytes.Buffer
+	if err = disp.WritePNG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := disp.Image()
+	if img.Bounds() != want.Bounds() {
+		t.Fatalf("PNG is %v, want %v", img.Bounds(), want.Bounds())
+	}
+	for _, p := range [][2]int{{0, 0}, {127, 63}, {11, 20}, {1, 1}, {64, 32}} {
+		if got := color.GrayModel.Convert(img.At(p[0], p[1])); got != want.At(p[0], p[1]) {
+			t.Errorf("PNG pixel %v is %v, want %v", p, got, want.At(p[0], p[1]))
+		}
+	}
+}
@@ -0,0 +1,177 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package motor drives brushed DC motors through an H-bridge, such as the
+// L298N or TB6612, using two GPIOs to choose the direction and a PWM channel
+// to set the speed.
+package motor
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/pwm"
+)
+
+// Frequency is the PWM frequency in hertz set up by New and NewTB6612.  It is
+// above the range of hearing, so the motor doesn't whine.  Change it on the
+// PWM channel afterwards if the driver can't keep up.
+const Frequency = 20000
+
+// A Motor is a DC motor on one channel of an H-bridge.
+type Motor struct {
+	in1, in2 *gpio.GPIO
+	standby  *gpio.GPIO
+	pwm      pwm.Channel
+	duty     float64
+	// cleanup stops the motor if the program exits without closing it
+	cleanup *gopherbone.Handle
+}
+
+func output(pin int) (g *gpio.GPIO, err error) {
+	g, err = gpio.Export(pin)
+	if err != nil {
+		return
+	}
+	err = g.SetDirection(gpio.Out)
+
+	return
+}
+
+// New creates a Motor on an H-bridge such as the L298N, with direction inputs
+// on the GPIO pins in1 and in2 and the enable input driven by speed.  The
+// motor starts out coasting.
+func New(in1, in2 int, speed pwm.Channel) (motor *Motor, err error) {
+	motor = &Motor{pwm: speed}
+	if motor.in1, err = output(in1); err != nil {
+		return nil, err
+	}
+	if motor.in2, err = output(in2); err != nil {
+		return nil, err
+	}
+	if err = speed.SetFrequency(Frequency); err != nil {
+		return nil, err
+	}
+	if err = motor.Coast(); err != nil {
+		return nil, err
+	}
+	if err = speed.Enable(); err != nil {
+		return nil, err
+	}
+	motor.cleanup = gopherbone.Register(fmt.Sprintf("motor on GPIO %d and %d", in1, in2), motor.Close)
+
+	return
+}
+
+// NewTB6612 creates a Motor on one channel of a TB6612 driver.  This is like
+// New, but also takes the GPIO pin connected to the driver's STBY input,
+// which is pulled high to bring the driver out of standby.  If STBY is tied
+// high in hardware, pass -1.
+func NewTB6612(in1, in2 int, speed pwm.Channel, standby int) (motor *Motor, err error) {
+	motor, err = New(in1, in2, speed)
+	if err != nil || standby < 0 {
+		return
+	}
+	if motor.standby, err = output(standby); err != nil {
+		return nil, err
+	}
+	if err = motor.standby.SetValue(1); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func (motor *Motor) setInputs(in1, in2 int) (err error) {
+	if err = motor.in1.SetValue(in1); err != nil {
+		return
+	}
+	return motor.in2.SetValue(in2)
+}
+
+// Forward drives the motor forwards at the current speed.
+func (motor *Motor) Forward() (err error) {
+	if err = motor.setInputs(1, 0); err != nil {
+		return
+	}
+	return motor.pwm.SetDuty(motor.duty)
+}
+
+// Reverse drives the motor backwards at the current speed.
+func (motor *Motor) Reverse() (err error) {
+	if err = motor.setInputs(0, 1); err != nil {
+		return
+	}
+	return motor.pwm.SetDuty(motor.duty)
+}
+
+// Brake shorts the motor's terminals together, stopping it quickly.
+func (motor *Motor) Brake() (err error) {
+	if err = motor.setInputs(1, 1); err != nil {
+		return
+	}
+	return motor.pwm.SetDuty(1)
+}
+
+// Coast disconnects the motor, letting it spin down freely.
+func (motor *Motor) Coast() (err error) {
+	if err = motor.pwm.SetDuty(0); err != nil {
+		return
+	}
+	return motor.setInputs(0, 0)
+}
+
+// SetSpeed sets the motor's speed, from -1 (full speed in reverse) through 0
+// (coasting) to 1 (full speed forwards).
+func (motor *Motor) SetSpeed(speed float64) (err error) {
+	if speed < -1 || speed > 1 {
+		err = fmt.Errorf("Invalid speed: %g", speed)
+		return
+	}
+
+	switch {
+	case speed > 0:
+		motor.duty = speed
+		return motor.Forward()
+	case speed < 0:
+		motor.duty = -speed
+		return motor.Reverse()
+	}
+	motor.duty = 0
+	return motor.Coast()
+}
+
+// Close stops the motor, puts the driver in standby if possible and unexports
+// the pins.  The PWM channel is disabled but left exported.
+func (motor *Motor) Close() (err error) {
+	motor.cleanup.Unregister()
+	err = motor.Coast()
+	motor.pwm.Disable()
+	if motor.standby != nil {
+		motor.standby.SetValue(0)
+		motor.standby.Unexport()
+	}
+	motor.in1.Unexport()
+	motor.in2.Unexport()
+
+	return
+}
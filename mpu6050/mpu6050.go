@@ -0,0 +1,398 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package mpu6050 drives the InvenSense MPU-6050 accelerometer and gyroscope
+// over I2C.
+package mpu6050
+
+import (
+	"context"
+	"fmt"
+	"github.com/Ratfink/gopherbone/i2c"
+	"time"
+)
+
+// I2C addresses, selected by the AD0 pin.
+const (
+	ADDR_LOW  = 0x68
+	ADDR_HIGH = 0x69
+)
+
+// Registers
+const (
+	SMPLRT_DIV   = 0x19
+	CONFIG       = 0x1a
+	GYRO_CONFIG  = 0x1b
+	ACCEL_CONFIG = 0x1c
+	FIFO_EN      = 0x23
+	INT_STATUS   = 0x3a
+	ACCEL_XOUT_H = 0x3b
+	USER_CTRL    = 0x6a
+	PWR_MGMT_1   = 0x6b
+	FIFO_COUNTH  = 0x72
+	FIFO_R_W     = 0x74
+	WHO_AM_I     = 0x75
+)
+
+// Register bits
+const (
+	PWR_MGMT_1_RESET      = 0x80
+	PWR_MGMT_1_CLK_PLLX   = 0x01
+	USER_CTRL_FIFO_EN     = 0x40
+	USER_CTRL_FIFO_RST    = 0x04
+	FIFO_EN_ALL           = 0xf8 // Temperature, gyro X, Y, Z and accelerometer
+	INT_STATUS_FIFO_OFLOW = 0x10
+)
+
+// sampleSize is the number of bytes in one sample, both in the data registers
+// and in the FIFO: accelerometer, temperature and gyro, 16 bits each.
+const sampleSize = 14
+
+// fifoSize is the size of the MPU-6050's FIFO in bytes.
+const fifoSize = 1024
+
+// An AccelRange is the full-scale range of the accelerometer.
+type AccelRange byte
+
+// Accelerometer ranges, in multiples of g.
+const (
+	Accel2G AccelRange = iota
+	Accel4G
+	Accel8G
+	Accel16G
+)
+
+// A GyroRange is the full-scale range of the gyroscope.
+type GyroRange byte
+
+// Gyroscope ranges, in degrees per second.
+const (
+	Gyro250 GyroRange = iota
+	Gyro500
+	Gyro1000
+	Gyro2000
+)
+
+// A Sample is one set of readings from the MPU-6050.
+type Sample struct {
+	// Accel is the acceleration along the X, Y and Z axes, in g.
+	Accel [3]float64
+	// Gyro is the rate of rotation about the X, Y and Z axes, in degrees
+	// per second.
+	Gyro [3]float64
+	// Temperature is the die temperature in degrees Celsius.
+	Temperature float64
+	// Time is when the sample was taken.  Samples read from the FIFO are
+	// timestamped from the sample rate, so this is approximate.
+	Time time.Time
+}
+
+// An MPU6050 is an MPU-6050 on an I2C bus.
+type MPU6050 struct {
+	dev        i2c.Conn
+	accelScale float64
+	gyroScale  float64
+	rate       float64
+}
+
+// New wakes up the MPU-6050 at addr on the given bus and configures it for a
+// 44Hz bandwidth, a 100Hz sample rate and the most sensitive ranges, ±2g and
+// ±250°/s.
+func New(addr, bus byte) (mpu *MPU6050, err error) {
+	dev, err := i2c.NewDevice(addr, bus)
+	if err != nil {
+		return
+	}
+
+	return NewConn(dev)
+}
+
+// NewConn is like New, but talks to the chip through conn, which may be a fake
+// for testing.
+func NewConn(conn i2c.Conn) (mpu *MPU6050, err error) {
+	mpu = &MPU6050{dev: conn}
+
+	id, err := mpu.dev.ReadReg(WHO_AM_I)
+	if err != nil {
+		return nil, err
+	}
+	// WHO_AM_I holds the upper six bits of the address whatever AD0 is
+	if id != ADDR_LOW {
+		return nil, fmt.Errorf("Unexpected WHO_AM_I value: %#02x", id)
+	}
+
+	if err = mpu.dev.WriteReg(PWR_MGMT_1, PWR_MGMT_1_RESET); err != nil {
+		return nil, err
+	}
+	time.Sleep(100 * time.Millisecond)
+	// Wake up, clocked from the X gyro's PLL as the datasheet recommends
+	if err = mpu.dev.WriteReg(PWR_MGMT_1, PWR_MGMT_1_CLK_PLLX); err != nil {
+		return nil, err
+	}
+	if err = mpu.dev.WriteReg(CONFIG, 0x03); err != nil {
+		return nil, err
+	}
+	if err = mpu.SetSampleRate(100); err != nil {
+		return nil, err
+	}
+	if err = mpu.SetAccelRange(Accel2G); err != nil {
+		return nil, err
+	}
+	if err = mpu.SetGyroRange(Gyro250); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// SetAccelRange sets the accelerometer's full-scale range.
+func (mpu *MPU6050) SetAccelRange(r AccelRange) (err error) {
+	if r > Accel16G {
+		err = fmt.Errorf("Invalid accelerometer range: %d", r)
+		return
+	}
+	if err = mpu.dev.WriteReg(ACCEL_CONFIG, byte(r)<<3); err != nil {
+		return
+	}
+	mpu.accelScale = float64(int(2)<<r) / 32768
+
+	return
+}
+
+// SetGyroRange sets the gyroscope's full-scale range.
+func (mpu *MPU6050) SetGyroRange(r GyroRange) (err error) {
+	if r > Gyro2000 {
+		err = fmt.Errorf("Invalid gyroscope range: %d", r)
+		return
+	}
+	if err = mpu.dev.WriteReg(GYRO_CONFIG, byte(r)<<3); err != nil {
+		return
+	}
+	mpu.gyroScale = float64(int(250)<<r) / 32768
+
+	return
+}
+
+// SetSampleRate sets the rate, in hertz, at which samples are taken and
+// written to the FIFO.  With the low pass filter New sets up, the gyro is
+// sampled at 1kHz, so rates between about 4Hz and 1kHz are possible.
+func (mpu *MPU6050) SetSampleRate(hz float64) (err error) {
+	if hz < 1000.0/256 || hz > 1000 {
+		err = fmt.Errorf("Invalid sample rate: %g", hz)
+		return
+	}
+	div := int(1000/hz+0.5) - 1
+	if div > 255 {
+		div = 255
+	}
+	if err = mpu.dev.WriteReg(SMPLRT_DIV, byte(div)); err != nil {
+		return
+	}
+	mpu.rate = 1000 / float64(div+1)
+
+	return
+}
+
+// SampleRate returns the actual sample rate, which may differ slightly from
+// that requested with SetSampleRate.
+func (mpu *MPU6050) SampleRate() float64 {
+	return mpu.rate
+}
+
+func (mpu *MPU6050) parse(data []byte, t time.Time) (sample Sample) {
+	word := func(i int) float64 {
+		return float64(int16(uint16(data[2*i])<<8 | uint16(data[2*i+1])))
+	}
+	for i := 0; i < 3; i++ {
+		sample.Accel[i] = word(i) * mpu.accelScale
+		sample.Gyro[i] = word(i+4) * mpu.gyroScale
+	}
+	sample.Temperature = word(3)/340 + 36.53
+	sample.Time = t
+
+	return
+}
+
+// Read returns the current readings from the data registers.
+func (mpu *MPU6050) Read() (sample Sample, err error) {
+	data, err := mpu.dev.Read(ACCEL_XOUT_H, sampleSize)
+	if err != nil {
+		return
+	}
+	sample = mpu.parse(data, time.Now())
+
+	return
+}
+
+// EnableFIFO resets the FIFO and starts writing every sample to it.
+func (mpu *MPU6050) EnableFIFO() (err error) {
+	if err = mpu.dev.WriteReg(USER_CTRL, USER_CTRL_FIFO_RST); err != nil {
+		return
+	}
+	if err = mpu.dev.WriteReg(FIFO_EN, FIFO_EN_ALL); err != nil {
+		return
+	}
+	return mpu.dev.WriteReg(USER_CTRL, USER_CTRL_FIFO_EN)
+}
+
+// DisableFIFO stops writing samples to the FIFO.
+func (mpu *MPU6050) DisableFIFO() (err error) {
+	if err = mpu.dev.WriteReg(FIFO_EN, 0); err != nil {
+		return
+	}
+	return mpu.dev.WriteReg(USER_CTRL, 0)
+}
+
+// ReadFIFO returns all complete samples waiting in the FIFO, oldest first.
+// If the FIFO has overflowed, its contents are no longer aligned to samples,
+// so it is reset and an error is returned.
+func (mpu *MPU6050) ReadFIFO() (samples []Sample, err error) {
+	status, err := mpu.dev.ReadReg(INT_STATUS)
+	if err != nil {
+		return
+	}
+	if status&INT_STATUS_FIFO_OFLOW != 0 {
+		mpu.EnableFIFO()
+		err = fmt.Errorf("FIFO overflow")
+		return
+	}
+
+	count, err := mpu.dev.Read(FIFO_COUNTH, 2)
+	if err != nil {
+		return
+	}
+	n := (int(count[0])<<8 | int(count[1])) / sampleSize
+	now := time.Now()
+	period := time.Duration(float64(time.Second) / mpu.rate)
+
+	// Two samples fit in a single SMBus block read
+	for i := 0; i < n; i += 2 {
+		size := byte(2 * sampleSize)
+		if n-i == 1 {
+			size = sampleSize
+		}
+		var data []byte
+		if data, err = mpu.dev.Read(FIFO_R_W, size); err != nil {
+			return
+		}
+		for j := 0; j < len(data); j += sampleSize {
+			k := i + j/sampleSize
+			samples = append(samples, mpu.parse(data[j:j+sampleSize], now.Add(-time.Duration(n-1-k)*period)))
+		}
+	}
+
+	return
+}
+
+// A Stream reads samples from the FIFO in the background and sends them on
+// its channel C.
+type Stream struct {
+	C <-chan Sample
+
+	// Err holds the error which stopped the Stream, if any.  It is valid
+	// once C has been closed.
+	Err error
+
+	c    chan Sample
+	mpu  *MPU6050
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Stream sets the sample rate, enables the FIFO and starts streaming every
+// sample on a channel.  The FIFO is polled often enough that it can't
+// overflow, so no samples are lost as long as the channel is kept drained.
+func (mpu *MPU6050) Stream(hz float64) (s *Stream, err error) {
+	return mpu.StreamContext(context.Background(), hz)
+}
+
+// StreamContext is like Stream, but the Stream also stops, closing C, when ctx
+// is done, with Err set to ctx.Err().  Close must still be called to disable
+// the FIFO.
+func (mpu *MPU6050) StreamContext(ctx context.Context, hz float64) (s *Stream, err error) {
+	if err = mpu.SetSampleRate(hz); err != nil {
+		return
+	}
+	if err = mpu.EnableFIFO(); err != nil {
+		return
+	}
+
+	s = &Stream{
+		c:    make(chan Sample, fifoSize/sampleSize),
+		mpu:  mpu,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	s.C = s.c
+
+	go s.run(ctx)
+
+	return
+}
+
+func (s *Stream) run(ctx context.Context) {
+	defer close(s.done)
+	defer close(s.c)
+
+	// Poll when the FIFO is no more than half full
+	interval := time.Duration(float64(time.Second) * fifoSize / sampleSize / 2 / s.mpu.rate)
+	if interval > 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		case <-ctx.Done():
+			s.Err = ctx.Err()
+			return
+		}
+
+		samples, err := s.mpu.ReadFIFO()
+		if err != nil {
+			s.Err = err
+			return
+		}
+		for _, sample := range samples {
+			select {
+			case s.c <- sample:
+			case <-s.stop:
+				return
+			case <-ctx.Done():
+				s.Err = ctx.Err()
+				return
+			}
+		}
+	}
+}
+
+// Close stops the Stream, closes its channel and disables the FIFO.
+func (s *Stream) Close() (err error) {
+	close(s.stop)
+	<-s.done
+
+	return s.mpu.DisableFIFO()
+}
@@ -0,0 +1,452 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package mqtt is a small MQTT 3.1.1 client, enough to publish readings and
+// receive commands.  Only QoS 0 is supported: messages are sent once and
+// not acknowledged, which suits sensor readings which are soon superseded.
+package mqtt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Packet types
+const (
+	CONNECT     = 1
+	CONNACK     = 2
+	PUBLISH     = 3
+	SUBSCRIBE   = 8
+	SUBACK      = 9
+	UNSUBSCRIBE = 10
+	UNSUBACK    = 11
+	PINGREQ     = 12
+	PINGRESP    = 13
+	DISCONNECT  = 14
+)
+
+// DefaultKeepAlive is the keep alive interval used if Options leaves it zero.
+var DefaultKeepAlive = 30 * time.Second
+
+var (
+	// ErrClosed is returned by a Client which has been closed or has lost
+	// its connection.
+	ErrClosed = errors.New("MQTT connection closed")
+	// ErrRefused is wrapped by the error returned when the broker refuses a
+	// connection.
+	ErrRefused = errors.New("MQTT connection refused")
+)
+
+// Options configures a connection.
+type Options struct {
+	// ClientID identifies the client to the broker.  If empty, the broker
+	// assigns one.
+	ClientID string
+	Username string
+	Password string
+	// KeepAlive is how often the client pings the broker when idle.
+	KeepAlive time.Duration
+	// WillTopic, if set, is published to by the broker with WillMessage if
+	// the client disconnects without closing, such as on losing power.
+	WillTopic   string
+	WillMessage []byte
+	WillRetain  bool
+}
+
+// A Handler is called with each message received on a subscribed topic.  It
+// is called from the Client's receiving goroutine, so must not block for
+// long.
+type Handler func(topic string, payload []byte)
+
+type subscription struct {
+	filter  string
+	handler Handler
+}
+
+// A Client is a connection to an MQTT broker.
+type Client struct {
+	conn net.Conn
+
+	writeLock sync.Mutex
+	w         *bufio.Writer
+
+	lock    sync.Mutex
+	subs    []subscription
+	nextID  uint16
+	pending map[uint16]chan []byte
+	err     error
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Dial connects to the broker at addr, a host and port such as
+// "localhost:1883".
+func Dial(addr string, opts Options) (c *Client, err error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return
+	}
+	if c, err = NewClient(conn, opts); err != nil {
+		conn.Close()
+	}
+	return
+}
+
+// NewClient connects to a broker over conn, which the Client then owns.
+func NewClient(conn net.Conn, opts Options) (c *Client, err error) {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = DefaultKeepAlive
+	}
+	c = &Client{
+		conn:    conn,
+		w:       bufio.NewWriter(conn),
+		pending: make(map[uint16]chan []byte),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err = c.send(CONNECT<<4, connectBody(opts)); err != nil {
+		return nil, err
+	}
+	typ, body, err := readPacket(r)
+	if err != nil {
+		return nil, err
+	}
+	if typ>>4 != CONNACK || len(body) != 2 {
+		return nil, fmt.Errorf("Unexpected MQTT packet type %d", typ>>4)
+	}
+	if body[1] != 0 {
+		return nil, fmt.Errorf("%w: code %d", ErrRefused, body[1])
+	}
+	conn.SetDeadline(time.Time{})
+
+	go c.receive(r)
+	go c.ping(opts.KeepAlive)
+
+	return
+}
+
+func connectBody(opts Options) []byte {
+	var flags byte = 0x02 // clean session
+	body := appendString(nil, "MQTT")
+	payload := appendString(nil, opts.ClientID)
+	if opts.WillTopic != "" {
+		flags |= 0x04
+		if opts.WillRetain {
+			flags |= 0x20
+		}
+		payload = appendString(payload, opts.WillTopic)
+		payload = appendBytes(payload, opts.WillMessage)
+	}
+	if opts.Username != "" {
+		flags |= 0x80
+		payload = appendString(payload, opts.Username)
+		if opts.Password != "" {
+			flags |= 0x40
+			payload = appendString(payload, opts.Password)
+		}
+	}
+	keepAlive := int(opts.KeepAlive / time.Second)
+	if keepAlive > 0xffff {
+		keepAlive = 0xffff
+	}
+	body = append(body, 4, flags, byte(keepAlive>>8), byte(keepAlive))
+	return append(body, payload...)
+}
+
+func appendString(b []byte, s string) []byte {
+	return appendBytes(b, []byte(s))
+}
+
+func appendBytes(b, s []byte) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// send writes a packet with the given first byte and body.
+func (c *Client) send(header byte, body []byte) (err error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.w.WriteByte(header)
+	n := len(body)
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n > 0 {
+			b |= 0x80
+		}
+		c.w.WriteByte(b)
+		if n == 0 {
+			break
+		}
+	}
+	c.w.Write(body)
+	if err = c.w.Flush(); err != nil {
+		c.fail(err)
+		return ErrClosed
+	}
+	return
+}
+
+// readPacket reads a packet, returning its first byte and body.
+func readPacket(r *bufio.Reader) (header byte, body []byte, err error) {
+	if header, err = r.ReadByte(); err != nil {
+		return
+	}
+	n, shift := 0, 0
+	for {
+		var b byte
+		if b, err = r.ReadByte(); err != nil {
+			return
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			err = errors.New("Malformed MQTT packet length")
+			return
+		}
+	}
+	body = make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return
+}
+
+// receive reads packets until the connection closes.
+func (c *Client) receive(r *bufio.Reader) {
+	defer close(c.done)
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		switch header >> 4 {
+		case PUBLISH:
+			c.deliver(header, body)
+		case SUBACK, UNSUBACK:
+			if len(body) < 2 {
+				continue
+			}
+			id := uint16(body[0])<<8 | uint16(body[1])
+			c.lock.Lock()
+			ch := c.pending[id]
+			delete(c.pending, id)
+			c.lock.Unlock()
+			if ch != nil {
+				ch <- body[2:]
+			}
+		}
+	}
+}
+
+func (c *Client) deliver(header byte, body []byte) {
+	if len(body) < 2 {
+		return
+	}
+	n := int(body[0])<<8 | int(body[1])
+	if len(body) < 2+n {
+		return
+	}
+	topic := string(body[2 : 2+n])
+	payload := body[2+n:]
+	if header&0x06 != 0 {
+		// QoS 1 and 2 have a packet identifier, which isn't acknowledged
+		// since only QoS 0 is subscribed to
+		if len(payload) < 2 {
+			return
+		}
+		payload = payload[2:]
+	}
+
+	c.lock.Lock()
+	var handlers []Handler
+	for _, s := range c.subs {
+		if Match(s.filter, topic) {
+			handlers = append(handlers, s.handler)
+		}
+	}
+	c.lock.Unlock()
+	for _, h := range handlers {
+		h(topic, payload)
+	}
+}
+
+// ping keeps the connection alive.
+func (c *Client) ping(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-c.done:
+			return
+		case <-t.C:
+			c.send(PINGREQ<<4, nil)
+		}
+	}
+}
+
+// fail records the first error and closes the connection.
+func (c *Client) fail(err error) {
+	c.lock.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.lock.Unlock()
+	c.conn.Close()
+}
+
+// Publish sends a message to topic.  If retain is set, the broker keeps it
+// and sends it to clients which subscribe later, which suits values such as
+// the state of an output.
+func (c *Client) Publish(topic string, payload []byte, retain bool) (err error) {
+	var header byte = PUBLISH << 4
+	if retain {
+		header |= 0x01
+	}
+	return c.send(header, append(appendString(nil, topic), payload...))
+}
+
+// Subscribe asks the broker for messages on topics matching filter, which
+// may contain the wildcards + and #, and calls h with each.
+func (c *Client) Subscribe(filter string, h Handler) (err error) {
+	c.lock.Lock()
+	c.subs = append(c.subs, subscription{filter, h})
+	c.lock.Unlock()
+
+	body := append(appendString(nil, filter), 0)
+	reply, err := c.request(SUBSCRIBE<<4|0x02, body)
+	if err != nil {
+		return
+	}
+	if len(reply) != 1 || reply[0] == 0x80 {
+		err = fmt.Errorf("Subscription to %s refused", filter)
+	}
+	return
+}
+
+// Unsubscribe stops the messages on topics matching filter.
+func (c *Client) Unsubscribe(filter string) (err error) {
+	c.lock.Lock()
+	subs := c.subs[:0]
+	for _, s := range c.subs {
+		if s.filter != filter {
+			subs = append(subs, s)
+		}
+	}
+	c.subs = subs
+	c.lock.Unlock()
+
+	_, err = c.request(UNSUBSCRIBE<<4|0x02, appendString(nil, filter))
+	return
+}
+
+// request sends a packet with a packet identifier and waits for the reply.
+func (c *Client) request(header byte, body []byte) (reply []byte, err error) {
+	ch := make(chan []byte, 1)
+	c.lock.Lock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID++
+	}
+	id := c.nextID
+	c.pending[id] = ch
+	c.lock.Unlock()
+
+	if err = c.send(header, append([]byte{byte(id >> 8), byte(id)}, body...)); err != nil {
+		return
+	}
+	select {
+	case reply = <-ch:
+	case <-c.done:
+		err = ErrClosed
+	case <-time.After(10 * time.Second):
+		c.lock.Lock()
+		delete(c.pending, id)
+		c.lock.Unlock()
+		err = errors.New("Timed out waiting for MQTT broker")
+	}
+	return
+}
+
+// Done returns a channel which is closed when the connection is lost or the
+// Client closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error which ended the connection, if any.
+func (c *Client) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+// Close disconnects from the broker.  The will message, if any, is not
+// sent.
+func (c *Client) Close() (err error) {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		c.send(DISCONNECT<<4, nil)
+		c.lock.Lock()
+		if c.err == nil {
+			c.err = ErrClosed
+		}
+		c.lock.Unlock()
+		err = c.conn.Close()
+	})
+	<-c.done
+	return
+}
+
+// Match reports whether topic matches filter, in which + matches one level
+// and a final # matches any number.
+func Match(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return i == len(f)-1
+		}
+		if i >= len(t) {
+			return false
+		}
+		if level != "+" && level != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
@@ -0,0 +1,486 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package nrf24 drives nRF24L01+ 2.4GHz radio modules over SPI, for talking
+// to cheap wireless sensor nodes.  Packets of up to 32 bytes are sent to an
+// address, and received on up to six pipes, each listening on its own
+// address.  By default packets are acknowledged and retried automatically,
+// and have dynamic lengths, as with the popular RF24 Arduino library.
+//
+// Addresses are 5 bytes, least significant byte first, as the chip takes
+// them.  Pipes 2 to 5 share all but their first byte with pipe 1.
+package nrf24
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/spi"
+	"sync"
+	"time"
+)
+
+// Commands
+const (
+	R_REGISTER         = 0x00
+	W_REGISTER         = 0x20
+	R_RX_PL_WID        = 0x60
+	R_RX_PAYLOAD       = 0x61
+	W_TX_PAYLOAD       = 0xa0
+	W_ACK_PAYLOAD      = 0xa8
+	W_TX_PAYLOAD_NOACK = 0xb0
+	FLUSH_TX           = 0xe1
+	FLUSH_RX           = 0xe2
+	NOP                = 0xff
+)
+
+// Registers
+const (
+	CONFIG      = 0x00
+	EN_AA       = 0x01
+	EN_RXADDR   = 0x02
+	SETUP_AW    = 0x03
+	SETUP_RETR  = 0x04
+	RF_CH       = 0x05
+	RF_SETUP    = 0x06
+	STATUS      = 0x07
+	OBSERVE_TX  = 0x08
+	RPD         = 0x09
+	RX_ADDR_P0  = 0x0a // RX_ADDR_P1-5 follow
+	TX_ADDR     = 0x10
+	RX_PW_P0    = 0x11 // RX_PW_P1-5 follow
+	FIFO_STATUS = 0x17
+	DYNPD       = 0x1c
+	FEATURE     = 0x1d
+)
+
+// Register bits
+const (
+	CONFIG_MASK_RX_DR  = 1 << 6
+	CONFIG_MASK_TX_DS  = 1 << 5
+	CONFIG_MASK_MAX_RT = 1 << 4
+	CONFIG_EN_CRC      = 1 << 3
+	CONFIG_CRCO        = 1 << 2
+	CONFIG_PWR_UP      = 1 << 1
+	CONFIG_PRIM_RX     = 1 << 0
+
+	STATUS_RX_DR  = 1 << 6
+	STATUS_TX_DS  = 1 << 5
+	STATUS_MAX_RT = 1 << 4
+
+	FIFO_RX_EMPTY = 1 << 0
+
+	FEATURE_EN_DPL     = 1 << 2
+	FEATURE_EN_ACK_PAY = 1 << 1
+	FEATURE_EN_DYN_ACK = 1 << 0
+)
+
+// MaxPayload is the largest payload a packet can carry.
+const MaxPayload = 32
+
+// A DataRate is an air data rate.  Slower rates reach further.
+type DataRate int
+
+// Data rates
+const (
+	Rate1M DataRate = iota
+	Rate2M
+	Rate250K
+)
+
+// A Power is a transmit power level.
+type Power int
+
+// Power levels
+const (
+	PowerMin  Power = iota // -18dBm
+	PowerLow               // -12dBm
+	PowerHigh              // -6dBm
+	PowerMax               // 0dBm
+)
+
+var (
+	// ErrNotFound is returned when no radio answers on the SPI bus.
+	ErrNotFound = errors.New("No nRF24L01 found")
+	// ErrNoAck is returned by Send when the receiver didn't acknowledge
+	// the packet after every retry.
+	ErrNoAck = errors.New("Packet not acknowledged")
+)
+
+// PollInterval is how often the radio is checked for received packets when
+// it has no IRQ pin, and for the end of a transmission.
+var PollInterval = 5 * time.Millisecond
+
+// A Packet is a received payload, and the pipe it arrived on.
+type Packet struct {
+	Pipe int
+	Data []byte
+}
+
+// A Radio is an nRF24L01+ module.  While it is listening, packets received
+// are sent on C.
+type Radio struct {
+	C <-chan Packet
+
+	spi spi.Conn
+	ce  gpio.DigitalPin
+	irq gpio.DigitalPin
+
+	lock        sync.Mutex
+	config      byte
+	payloadSize int
+	rxAddr0     []byte
+	listening   bool
+	asleep      bool
+	ownPins     bool
+
+	c    chan Packet
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns the radio on the given spidev bus and chip select, with its CE
+// pin on the given GPIO and its IRQ pin on another, or -1 if the IRQ pin
+// isn't connected.
+func New(bus, cs, cePin, irqPin int) (radio *Radio, err error) {
+	s, err := spi.Open(bus, cs)
+	if err != nil {
+		return
+	}
+	ce, err := gpio.Export(cePin)
+	if err != nil {
+		s.Close()
+		return
+	}
+	var irq *gpio.GPIO
+	if irqPin >= 0 {
+		if irq, err = gpio.Export(irqPin); err == nil {
+			err = irq.SetDirection(gpio.In)
+		}
+	}
+	if err == nil {
+		if irq != nil {
+			radio, err = NewConn(s, ce, irq)
+		} else {
+			radio, err = NewConn(s, ce, nil)
+		}
+	}
+	if err != nil {
+		s.Close()
+		ce.Unexport()
+		if irq != nil {
+			irq.Unexport()
+		}
+		return nil, err
+	}
+	radio.ownPins = true
+
+	return
+}
+
+// NewConn is like New, but uses an already open SPI connection and pins.
+// irq may be nil.  The connection is closed along with the radio, but the
+// pins are left to the caller.
+func NewConn(s spi.Conn, ce, irq gpio.DigitalPin) (radio *Radio, err error) {
+	radio = &Radio{spi: s, ce: ce, irq: irq, c: make(chan Packet, 16)}
+	radio.C = radio.c
+
+	if err = s.SetSpeed(8000000); err != nil {
+		return nil, err
+	}
+	if err = ce.SetDirection(gpio.Out); err == nil {
+		err = ce.SetValue(0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The chip needs 100ms after power on before it can be set up
+	time.Sleep(5 * time.Millisecond)
+
+	// Check that something is there by writing a register and reading it
+	// back
+	if err = radio.writeReg(SETUP_AW, 0x03); err != nil {
+		return nil, err
+	}
+	if aw, err := radio.readReg(SETUP_AW); err != nil || aw != 0x03 {
+		if err == nil {
+			err = ErrNotFound
+		}
+		return nil, err
+	}
+
+	// 16 bit CRC, interrupts only for received packets, and auto-ack,
+	// dynamic payloads and ack payloads on every pipe
+	radio.config = CONFIG_EN_CRC | CONFIG_CRCO | CONFIG_MASK_TX_DS | CONFIG_MASK_MAX_RT
+	for _, rv := range [][2]byte{
+		{CONFIG, radio.config},
+		{EN_AA, 0x3f},
+		{EN_RXADDR, 0x03},
+		{SETUP_RETR, 5<<4 | 15},
+		{RF_CH, 76},
+		{RF_SETUP, rfSetup(Rate1M, PowerMax)},
+		{FEATURE, FEATURE_EN_DPL | FEATURE_EN_ACK_PAY | FEATURE_EN_DYN_ACK},
+		{DYNPD, 0x3f},
+		{STATUS, STATUS_RX_DR | STATUS_TX_DS | STATUS_MAX_RT},
+	} {
+		if err = radio.writeReg(rv[0], rv[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err = radio.command(FLUSH_RX); err == nil {
+		err = radio.command(FLUSH_TX)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Power up into standby, which takes 1.5ms
+	radio.config |= CONFIG_PWR_UP
+	if err = radio.writeReg(CONFIG, radio.config); err != nil {
+		return nil, err
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	return
+}
+
+func rfSetup(rate DataRate, power Power) (v byte) {
+	switch rate {
+	case Rate2M:
+		v = 1 << 3
+	case Rate250K:
+		v = 1 << 5
+	}
+	return v | byte(power)<<1
+}
+
+// command sends a command with no data.
+func (radio *Radio) command(cmd byte) error {
+	return radio.spi.Write([]byte{cmd})
+}
+
+func (radio *Radio) readRegs(reg byte, n int) (data []byte, err error) {
+	tx := make([]byte, n+1)
+	tx[0] = R_REGISTER | reg
+	rx, err := radio.spi.Transfer(tx)
+	if err != nil {
+		return
+	}
+	return rx[1:], nil
+}
+
+func (radio *Radio) readReg(reg byte) (value byte, err error) {
+	data, err := radio.readRegs(reg, 1)
+	if err != nil {
+		return
+	}
+	return data[0], nil
+}
+
+func (radio *Radio) writeRegs(reg byte, data []byte) error {
+	return radio.spi.Write(append([]byte{W_REGISTER | reg}, data...))
+}
+
+func (radio *Radio) writeReg(reg, value byte) error {
+	return radio.writeRegs(reg, []byte{value})
+}
+
+// updateReg sets the bits of a register selected by mask to value.
+func (radio *Radio) updateReg(reg, mask, value byte) (err error) {
+	v, err := radio.readReg(reg)
+	if err != nil {
+		return
+	}
+	return radio.writeReg(reg, v&^mask|value&mask)
+}
+
+func (radio *Radio) status() (status byte, err error) {
+	rx, err := radio.spi.Transfer([]byte{NOP})
+	if err != nil {
+		return
+	}
+	return rx[0], nil
+}
+
+// SetChannel sets the RF channel, from 0 to 125, which is 2400MHz plus that
+// many MHz.  Both ends must use the same channel.
+func (radio *Radio) SetChannel(ch int) (err error) {
+	if ch < 0 || ch > 125 {
+		return fmt.Errorf("Invalid channel: %d", ch)
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+	return radio.writeReg(RF_CH, byte(ch))
+}
+
+// SetDataRate sets the air data rate and transmit power.  Both ends must
+// use the same rate.
+func (radio *Radio) SetDataRate(rate DataRate, power Power) (err error) {
+	if rate < Rate1M || rate > Rate250K {
+		return fmt.Errorf("Invalid data rate: %d", rate)
+	}
+	if power < PowerMin || power > PowerMax {
+		return fmt.Errorf("Invalid power: %d", power)
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+	return radio.writeReg(RF_SETUP, rfSetup(rate, power))
+}
+
+// SetRetries sets the delay between automatic retries, from 250µs to 4ms in
+// steps of 250µs, and how many there are, up to 15.  At 250kbps, delays of
+// at least 500µs are needed, more with ack payloads.
+func (radio *Radio) SetRetries(delay time.Duration, count int) (err error) {
+	steps := int(delay/(250*time.Microsecond)) - 1
+	if steps < 0 || steps > 15 {
+		return fmt.Errorf("Invalid retry delay: %s", delay)
+	}
+	if count < 0 || count > 15 {
+		return fmt.Errorf("Invalid retry count: %d", count)
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+	return radio.writeReg(SETUP_RETR, byte(steps<<4|count))
+}
+
+// SetAutoAck turns automatic acknowledgement on or off for a pipe.  Both
+// ends must agree.
+func (radio *Radio) SetAutoAck(pipe int, on bool) (err error) {
+	if pipe < 0 || pipe > 5 {
+		return fmt.Errorf("Invalid pipe: %d", pipe)
+	}
+	var v byte
+	if on {
+		v = 1 << uint(pipe)
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+	return radio.updateReg(EN_AA, 1<<uint(pipe), v)
+}
+
+// SetPayloadSize sets a fixed payload size for every pipe, or with zero
+// returns to dynamic payloads, the default.  Fixed sizes are needed to talk
+// to the older nRF24L01, which lacks dynamic payloads.  Shorter payloads are
+// padded with zeros when sent.
+func (radio *Radio) SetPayloadSize(n int) (err error) {
+	if n < 0 || n > MaxPayload {
+		return fmt.Errorf("Invalid payload size: %d", n)
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+
+	var dynpd, feature byte = 0, FEATURE_EN_DYN_ACK
+	if n == 0 {
+		dynpd, feature = 0x3f, FEATURE_EN_DPL|FEATURE_EN_ACK_PAY|FEATURE_EN_DYN_ACK
+	}
+	if err = radio.writeReg(FEATURE, feature); err != nil {
+		return
+	}
+	if err = radio.writeReg(DYNPD, dynpd); err != nil {
+		return
+	}
+	for p := byte(0); p < 6; p++ {
+		if err = radio.writeReg(RX_PW_P0+p, byte(n)); err != nil {
+			return
+		}
+	}
+	radio.payloadSize = n
+	return
+}
+
+func checkAddr(addr []byte) error {
+	if len(addr) != 5 {
+		return fmt.Errorf("Invalid address length: %d", len(addr))
+	}
+	return nil
+}
+
+// OpenReadingPipe starts receiving on a pipe, from 0 to 5, at an address.
+// Pipes 2 to 5 take only the first byte of addr, the rest being pipe 1's.
+// Pipe 0 is also used to receive acknowledgements while sending, so is
+// best left for that; its address is restored when listening starts.
+func (radio *Radio) OpenReadingPipe(pipe int, addr []byte) (err error) {
+	if pipe < 0 || pipe > 5 {
+		return fmt.Errorf("Invalid pipe: %d", pipe)
+	}
+	if err = checkAddr(addr); err != nil {
+		return
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+
+	reg := byte(RX_ADDR_P0 + pipe)
+	if pipe >= 2 {
+		err = radio.writeReg(reg, addr[0])
+	} else {
+		err = radio.writeRegs(reg, addr)
+	}
+	if err != nil {
+		return
+	}
+	if pipe == 0 {
+		radio.rxAddr0 = append([]byte(nil), addr...)
+	}
+	return radio.updateReg(EN_RXADDR, 1<<uint(pipe), 1<<uint(pipe))
+}
+
+// ClosePipe stops receiving on a pipe.
+func (radio *Radio) ClosePipe(pipe int) (err error) {
+	if pipe < 0 || pipe > 5 {
+		return fmt.Errorf("Invalid pipe: %d", pipe)
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+	if pipe == 0 {
+		radio.rxAddr0 = nil
+	}
+	return radio.updateReg(EN_RXADDR, 1<<uint(pipe), 0)
+}
+
+// OpenWritingPipe sets the address packets are sent to.  Pipe 0 receives on
+// the same address, for the acknowledgements.
+func (radio *Radio) OpenWritingPipe(addr []byte) (err error) {
+	if err = checkAddr(addr); err != nil {
+		return
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+	if err = radio.writeRegs(TX_ADDR, addr); err != nil {
+		return
+	}
+	return radio.writeRegs(RX_ADDR_P0, addr)
+}
+
+// WriteAckPayload queues data to be sent back on a pipe with the
+// acknowledgement of the next packet received on it, so a hub can answer a
+// node without the node having to listen.  Dynamic payloads must be on.
+func (radio *Radio) WriteAckPayload(pipe int, data []byte) (err error) {
+	if pipe < 0 || pipe > 5 {
+		return fmt.Errorf("Invalid pipe: %d", pipe)
+	}
+	if len(data) == 0 || len(data) > MaxPayload {
+		return fmt.Errorf("Invalid payload length: %d", len(data))
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+	return radio.spi.Write(append([]byte{W_ACK_PAYLOAD | byte(pipe)}, data...))
+}
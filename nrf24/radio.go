@@ -0,0 +1,272 @@
+package nrf24
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"time"
+)
+
+// SendTimeout is how long Send waits for a packet to be sent and
+// acknowledged, which with the longest retry delay and most retries takes
+// about 70ms.
+var SendTimeout = 100 * time.Millisecond
+
+// pulseCE raises CE for long enough to start a transmission.
+func (radio *Radio) pulseCE() (err error) {
+	if err = radio.ce.SetValue(1); err != nil {
+		return
+	}
+	time.Sleep(15 * time.Microsecond)
+	return radio.ce.SetValue(0)
+}
+
+// setRX switches between receive and transmit modes.  The radio must be
+// locked.
+func (radio *Radio) setRX(rx bool) (err error) {
+	if err = radio.ce.SetValue(0); err != nil {
+		return
+	}
+	if rx {
+		radio.config |= CONFIG_PRIM_RX
+	} else {
+		radio.config &^= CONFIG_PRIM_RX
+	}
+	if err = radio.writeReg(CONFIG, radio.config); err != nil {
+		return
+	}
+	if radio.asleep {
+		// Powering up takes 1.5ms
+		time.Sleep(2 * time.Millisecond)
+		radio.asleep = false
+	}
+	if !rx {
+		return
+	}
+	if radio.rxAddr0 != nil {
+		if err = radio.writeRegs(RX_ADDR_P0, radio.rxAddr0); err != nil {
+			return
+		}
+	}
+	return radio.ce.SetValue(1)
+}
+
+// Send sends a packet to the address set by OpenWritingPipe, waiting until
+// it has been acknowledged, or ErrNoAck if it never is.  A listening radio
+// stops listening while it sends, and then carries on.
+func (radio *Radio) Send(data []byte) error {
+	return radio.send(W_TX_PAYLOAD, data)
+}
+
+// SendNoAck sends a packet without asking for it to be acknowledged, even if
+// auto-ack is on, so it may be lost.
+func (radio *Radio) SendNoAck(data []byte) error {
+	return radio.send(W_TX_PAYLOAD_NOACK, data)
+}
+
+func (radio *Radio) send(cmd byte, data []byte) (err error) {
+	if len(data) == 0 || len(data) > MaxPayload {
+		return fmt.Errorf("Invalid payload length: %d", len(data))
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+
+	if radio.payloadSize > 0 {
+		if len(data) > radio.payloadSize {
+			return fmt.Errorf("Payload longer than fixed size: %d", len(data))
+		}
+		data = append(data, make([]byte, radio.payloadSize-len(data))...)
+	}
+	if err = radio.setRX(false); err != nil {
+		return
+	}
+	defer func() {
+		if radio.listening {
+			if e := radio.setRX(true); err == nil {
+				err = e
+			}
+		}
+	}()
+
+	if err = radio.spi.Write(append([]byte{cmd}, data...)); err != nil {
+		return
+	}
+	if err = radio.pulseCE(); err != nil {
+		return
+	}
+
+	deadline := time.Now().Add(SendTimeout)
+	for {
+		status, e := radio.status()
+		if e != nil {
+			return e
+		}
+		if status&(STATUS_TX_DS|STATUS_MAX_RT) != 0 {
+			if err = radio.writeReg(STATUS, STATUS_TX_DS|STATUS_MAX_RT); err != nil {
+				return
+			}
+			if status&STATUS_MAX_RT != 0 {
+				radio.command(FLUSH_TX)
+				return ErrNoAck
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			radio.command(FLUSH_TX)
+			return fmt.Errorf("Radio not responding")
+		}
+		time.Sleep(250 * time.Microsecond)
+	}
+}
+
+// StartListening puts the radio in receive mode, sending packets received
+// on the open reading pipes on C.  With an IRQ pin, packets are read as
+// soon as they arrive; otherwise the radio is checked every PollInterval.
+func (radio *Radio) StartListening() (err error) {
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+	if radio.listening {
+		return
+	}
+	if err = radio.setRX(true); err != nil {
+		return
+	}
+	radio.listening = true
+	radio.stop = make(chan struct{})
+	radio.done = make(chan struct{})
+	go radio.receive(radio.stop, radio.done)
+	return
+}
+
+// StopListening takes the radio out of receive mode.
+func (radio *Radio) StopListening() (err error) {
+	radio.lock.Lock()
+	if !radio.listening {
+		radio.lock.Unlock()
+		return
+	}
+	radio.listening = false
+	close(radio.stop)
+	done := radio.done
+	radio.lock.Unlock()
+	<-done
+
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+	return radio.setRX(false)
+}
+
+// receive delivers packets until stop is closed.
+func (radio *Radio) receive(stop, done chan struct{}) {
+	defer close(done)
+
+	interval := PollInterval
+	var irq <-chan int
+	if radio.irq != nil {
+		if w, err := radio.irq.Watch(gpio.Falling); err == nil {
+			defer w.Close()
+			irq = w.C
+			// Still check now and then, in case an edge is missed
+			interval = 100 * time.Millisecond
+		}
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		packets, _ := radio.Receive()
+		for _, p := range packets {
+			select {
+			case radio.c <- p:
+			case <-stop:
+				return
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-irq:
+		case <-tick.C:
+		}
+	}
+}
+
+// Receive reads any packets waiting in the radio.  It is only needed if
+// packets are to be read without listening, such as after a period in
+// standby.
+func (radio *Radio) Receive() (packets []Packet, err error) {
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+
+	for {
+		fifo, e := radio.readReg(FIFO_STATUS)
+		if e != nil {
+			return packets, e
+		}
+		if fifo&FIFO_RX_EMPTY != 0 {
+			break
+		}
+		status, e := radio.status()
+		if e != nil {
+			return packets, e
+		}
+		pipe := int(status>>1) & 7
+
+		n := radio.payloadSize
+		if n == 0 {
+			rx, e := radio.spi.Transfer([]byte{R_RX_PL_WID, NOP})
+			if e != nil {
+				return packets, e
+			}
+			n = int(rx[1])
+		}
+		if n == 0 || n > MaxPayload {
+			// A corrupt length; the datasheet says to flush
+			if err = radio.command(FLUSH_RX); err != nil {
+				return
+			}
+			continue
+		}
+
+		tx := make([]byte, n+1)
+		tx[0] = R_RX_PAYLOAD
+		rx, e := radio.spi.Transfer(tx)
+		if e != nil {
+			return packets, e
+		}
+		packets = append(packets, Packet{Pipe: pipe, Data: rx[1:]})
+	}
+	err = radio.writeReg(STATUS, STATUS_RX_DR)
+	return
+}
+
+// PowerDown puts the radio into its power down mode, drawing under 1µA,
+// until StartListening or Send is called.
+func (radio *Radio) PowerDown() (err error) {
+	if err = radio.StopListening(); err != nil {
+		return
+	}
+	radio.lock.Lock()
+	defer radio.lock.Unlock()
+	if err = radio.writeReg(CONFIG, radio.config&^CONFIG_PWR_UP); err != nil {
+		return
+	}
+	radio.asleep = true
+	return
+}
+
+// Close powers the radio down, closes C and the SPI connection, and if the
+// radio came from New, unexports its pins.
+func (radio *Radio) Close() (err error) {
+	err = radio.PowerDown()
+	close(radio.c)
+	if e := radio.spi.Close(); err == nil {
+		err = e
+	}
+	if radio.ownPins {
+		radio.ce.Unexport()
+		if radio.irq != nil {
+			radio.irq.Unexport()
+		}
+	}
+	return
+}
@@ -0,0 +1,216 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* This package reads 1-Wire devices through the kernel's w1 subsystem, which
+ * must be enabled with an overlay such as BB-W1-P9.12.
+ */
+package onewire
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DevicesPath is where the kernel lists 1-Wire devices.
+var DevicesPath = "/sys/bus/w1/devices"
+
+// Family codes of the temperature sensors understood by Sensor.
+const (
+	FamilyDS18S20  = 0x10
+	FamilyDS1822   = 0x22
+	FamilyDS18B20  = 0x28
+	FamilyMAX31826 = 0x3b
+)
+
+// A Device is a 1-Wire device found on the bus.
+type Device struct {
+	// ID is the device's kernel name, such as "28-0000055d6c1f".
+	ID string
+	// Family is the family code, the first byte of the device's ROM.
+	Family byte
+}
+
+// Devices lists the 1-Wire devices on all buses.  Bus masters are not
+// included.
+func Devices() (devices []Device, err error) {
+	matches, err := filepath.Glob(filepath.Join(DevicesPath, "[0-9a-f][0-9a-f]-*"))
+	if err != nil {
+		return
+	}
+
+	for _, match := range matches {
+		var family byte
+		id := filepath.Base(match)
+		if n, _ := fmt.Sscanf(id, "%02x-", &family); n != 1 {
+			continue
+		}
+		devices = append(devices, Device{ID: id, Family: family})
+	}
+
+	return
+}
+
+// A Sensor is a DS18B20 or compatible temperature sensor.
+type Sensor struct {
+	ID string
+}
+
+// Sensors lists the temperature sensors on all buses.
+func Sensors() (sensors []*Sensor, err error) {
+	devices, err := Devices()
+	if err != nil {
+		return
+	}
+
+	for _, dev := range devices {
+		switch dev.Family {
+		case FamilyDS18S20, FamilyDS1822, FamilyDS18B20, FamilyMAX31826:
+			sensors = append(sensors, &Sensor{ID: dev.ID})
+		}
+	}
+
+	return
+}
+
+// Temperature reads the sensor's temperature in degrees Celsius.  A
+// conversion takes up to 750ms at the sensor's default 12 bit resolution.
+func (sensor *Sensor) Temperature() (temp float64, err error) {
+	path := filepath.Join(DevicesPath, sensor.ID, "w1_slave")
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	// The first line ends in YES if the CRC was good, and the second line
+	// ends in t= followed by the temperature in thousandths of a degree
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		err = fmt.Errorf("Short read from %s", path)
+		return
+	}
+	if !strings.HasSuffix(strings.TrimSpace(scanner.Text()), "YES") {
+		err = fmt.Errorf("CRC error reading %s", sensor.ID)
+		return
+	}
+	if !scanner.Scan() {
+		err = fmt.Errorf("Short read from %s", path)
+		return
+	}
+	line := scanner.Text()
+	i := strings.LastIndex(line, "t=")
+	if i < 0 {
+		err = fmt.Errorf("No temperature read from %s", path)
+		return
+	}
+
+	var millis int
+	if n, _ := fmt.Sscanf(line[i+2:], "%d", &millis); n != 1 {
+		err = fmt.Errorf("Bad temperature read from %s: %s", path, line[i+2:])
+		return
+	}
+	temp = float64(millis) / 1000
+
+	return
+}
+
+// A Reading is a temperature read by a Watcher.
+type Reading struct {
+	ID          string
+	Temperature float64
+	Time        time.Time
+	// Err is set if reading the sensor failed, in which case Temperature
+	// is meaningless.
+	Err error
+}
+
+// A Watcher reads a set of sensors at a regular interval and sends the
+// readings on its channel C.
+type Watcher struct {
+	C <-chan Reading
+
+	c    chan Reading
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Watch starts reading the given sensors every interval.  The sensors are
+// read one after another, so the interval should allow for a second or so per
+// sensor.
+func Watch(sensors []*Sensor, interval time.Duration) (w *Watcher) {
+	return WatchContext(context.Background(), sensors, interval)
+}
+
+// WatchContext is like Watch, but the Watcher also stops, closing C, when ctx
+// is done.
+func WatchContext(ctx context.Context, sensors []*Sensor, interval time.Duration) (w *Watcher) {
+	w = &Watcher{
+		c:    make(chan Reading, len(sensors)),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	w.C = w.c
+
+	go w.run(ctx, sensors, interval)
+
+	return
+}
+
+func (w *Watcher) run(ctx context.Context, sensors []*Sensor, interval time.Duration) {
+	defer close(w.done)
+	defer close(w.c)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, sensor := range sensors {
+			temp, err := sensor.Temperature()
+			select {
+			case w.c <- Reading{ID: sensor.ID, Temperature: temp, Time: time.Now(), Err: err}:
+			case <-w.stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Close stops the Watcher and closes its channel.
+func (w *Watcher) Close() {
+	close(w.stop)
+	<-w.done
+}
@@ -0,0 +1,204 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package pattern plays brightness patterns, such as breathing, blinking, a
+// heartbeat or Morse code, on an LED driven by PWM, the kernel's LED class or
+// a plain GPIO, for status indication.  A pattern is a list of keyframes,
+// played in the background by a Player, which can switch to a new pattern at
+// once or queue it to follow the current one.
+package pattern
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/leds"
+	"github.com/Ratfink/gopherbone/pwm"
+	"math"
+	"strings"
+	"time"
+)
+
+// Interval is how often the level is updated during a fade.
+var Interval = 20 * time.Millisecond
+
+// Gamma corrects levels for the eye's response to brightness, so that a
+// linear fade looks linear and a level of 0.5 looks half as bright as 1.  The
+// output is set to level^Gamma.  Set it to 1 to drive outputs linearly.
+var Gamma = 2.2
+
+// An Output is something whose level can be set, from 0 (off) to 1 (fully
+// on).
+type Output interface {
+	SetLevel(level float64) error
+}
+
+// OutputFunc adapts a function to an Output.
+type OutputFunc func(level float64) error
+
+// SetLevel calls f(level).
+func (f OutputFunc) SetLevel(level float64) error {
+	return f(level)
+}
+
+// PWM returns an Output setting the duty cycle of a PWM channel, whose
+// period should already be set; 1kHz or so avoids visible flicker.  The
+// channel is enabled by the first level set.
+func PWM(ch pwm.Channel) Output {
+	enabled := false
+	return OutputFunc(func(level float64) (err error) {
+		if err = ch.SetDuty(level); err != nil || enabled {
+			return
+		}
+		if err = ch.Enable(); err == nil {
+			enabled = true
+		}
+		return
+	})
+}
+
+// LED returns an Output setting the brightness of an LED class LED, scaled
+// to its maximum brightness.  The LED's trigger is removed.  LEDs on plain
+// GPIOs, such as the BeagleBone's user LEDs, are either off or fully on.
+func LED(led *leds.LED) (out Output, err error) {
+	if err = led.SetTrigger(leds.None); err != nil {
+		return
+	}
+	max, err := led.MaxBrightness()
+	if err != nil {
+		return
+	}
+	out = OutputFunc(func(level float64) error {
+		return led.SetBrightness(int(math.Round(level * float64(max))))
+	})
+	return
+}
+
+// Pin returns an Output driving an output pin, which is on for levels of
+// one half or more.
+func Pin(pin gpio.DigitalPin) Output {
+	return OutputFunc(func(level float64) error {
+		if level >= 0.5 {
+			return pin.SetValue(1)
+		}
+		return pin.SetValue(0)
+	})
+}
+
+// A Keyframe is a step of a pattern: the level fades linearly to Level over
+// Fade, then stays there for Hold.
+type Keyframe struct {
+	Level float64
+	Fade  time.Duration
+	Hold  time.Duration
+}
+
+// A Pattern is a sequence of keyframes, played Repeat times, or forever if
+// Repeat is zero.  The level is left where the last keyframe puts it.
+type Pattern struct {
+	Frames []Keyframe
+	Repeat int
+}
+
+// Duration returns how long one repetition of the pattern takes.
+func (pat Pattern) Duration() (d time.Duration) {
+	for _, f := range pat.Frames {
+		d += f.Fade + f.Hold
+	}
+	return
+}
+
+// Solid returns a pattern setting a steady level.
+func Solid(level float64) Pattern {
+	return Pattern{Frames: []Keyframe{{Level: level}}, Repeat: 1}
+}
+
+// Off is a pattern turning the output off.
+var Off = Solid(0)
+
+// Blink returns a pattern blinking forever, on for one duration and off for
+// the other.
+func Blink(on, off time.Duration) Pattern {
+	return Pattern{Frames: []Keyframe{{Level: 1, Hold: on}, {Level: 0, Hold: off}}}
+}
+
+// Breathe returns a pattern fading smoothly up and down forever, once per
+// period.
+func Breathe(period time.Duration) Pattern {
+	return Pattern{Frames: []Keyframe{{Level: 1, Fade: period / 2}, {Level: 0, Fade: period / 2}}}
+}
+
+// Heartbeat returns a pattern blinking twice per beat, like the kernel's
+// heartbeat trigger, at the given rate in beats per minute.
+func Heartbeat(bpm int) Pattern {
+	beat := time.Minute / time.Duration(bpm)
+	on := beat / 14
+	return Pattern{Frames: []Keyframe{
+		{Level: 1, Hold: on},
+		{Level: 0, Hold: 2 * on},
+		{Level: 1, Hold: on},
+		{Level: 0, Hold: beat - 4*on},
+	}}
+}
+
+// morse gives the dots and dashes of each character Morse accepts.
+var morse = map[rune]string{
+	'A': ".-", 'B': "-...", 'C': "-.-.", 'D': "-..", 'E': ".", 'F': "..-.",
+	'G': "--.", 'H': "....", 'I': "..", 'J': ".---", 'K': "-.-", 'L': ".-..",
+	'M': "--", 'N': "-.", 'O': "---", 'P': ".--.", 'Q': "--.-", 'R': ".-.",
+	'S': "...", 'T': "-", 'U': "..-", 'V': "...-", 'W': ".--", 'X': "-..-",
+	'Y': "-.--", 'Z': "--..",
+	'0': "-----", '1': ".----", '2': "..---", '3': "...--", '4': "....-",
+	'5': ".....", '6': "-....", '7': "--...", '8': "---..", '9': "----.",
+}
+
+// Morse returns a pattern signalling text in Morse code forever, with a dot
+// lasting unit.  A dash is three units, the gap within a letter one, between
+// letters three and between words, and after the message, seven.  Text may
+// contain letters, digits and spaces.
+func Morse(text string, unit time.Duration) (pat Pattern, err error) {
+	for _, word := range strings.Fields(strings.ToUpper(text)) {
+		for _, c := range word {
+			code, ok := morse[c]
+			if !ok {
+				err = fmt.Errorf("No Morse code for %q", c)
+				return
+			}
+			for _, sym := range code {
+				on := unit
+				if sym == '-' {
+					on = 3 * unit
+				}
+				pat.Frames = append(pat.Frames, Keyframe{Level: 1, Hold: on}, Keyframe{Level: 0, Hold: unit})
+			}
+			// Lengthen the last gap to the gap between letters
+			pat.Frames[len(pat.Frames)-1].Hold = 3 * unit
+		}
+		pat.Frames[len(pat.Frames)-1].Hold = 7 * unit
+	}
+	return
+}
+
+// SOS returns a pattern signalling SOS in Morse code forever.
+func SOS(unit time.Duration) Pattern {
+	pat, _ := Morse("SOS", unit)
+	return pat
+}
@@ -0,0 +1,200 @@
+package pattern
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// A Player plays patterns on an Output in the background.  If setting the
+// output fails, the Player stops; Done is closed and Err holds the error.
+type Player struct {
+	Err error
+
+	out   Output
+	level float64
+	set   bool
+
+	lock  sync.Mutex
+	queue []Pattern
+	cut   chan struct{}
+	wake  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewPlayer returns a Player for out, which is left alone until a pattern
+// is played.
+func NewPlayer(out Output) (p *Player) {
+	p = &Player{
+		out:  out,
+		cut:  make(chan struct{}, 1),
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go p.run()
+	return
+}
+
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// Play starts playing pat at once, abandoning the current pattern and any
+// queued ones.
+func (p *Player) Play(pat Pattern) {
+	p.lock.Lock()
+	p.queue = []Pattern{pat}
+	signal(p.cut)
+	signal(p.wake)
+	p.lock.Unlock()
+}
+
+// Queue plays pat after the patterns already playing or queued.  A pattern
+// repeating forever gives way to a queued pattern at the end of its current
+// repetition.
+func (p *Player) Queue(pat Pattern) {
+	p.lock.Lock()
+	p.queue = append(p.queue, pat)
+	signal(p.wake)
+	p.lock.Unlock()
+}
+
+// Stop stops the current pattern, discards any queued ones and turns the
+// output off.
+func (p *Player) Stop() {
+	p.Play(Off)
+}
+
+// Done returns a channel which is closed when the Player stops, either
+// because it was closed or because setting the output failed.
+func (p *Player) Done() <-chan struct{} {
+	return p.done
+}
+
+// Close stops the Player and turns the output off.
+func (p *Player) Close() (err error) {
+	p.once.Do(func() {
+		close(p.stop)
+		<-p.done
+		if p.Err == nil {
+			p.Err = p.out.SetLevel(0)
+		}
+	})
+	return p.Err
+}
+
+// next removes the next pattern from the queue.  Any cut signalled before
+// now was for a pattern which has finished.
+func (p *Player) next() (pat Pattern, ok bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	select {
+	case <-p.cut:
+	default:
+	}
+	if len(p.queue) == 0 {
+		return
+	}
+	pat, p.queue = p.queue[0], p.queue[1:]
+	return pat, true
+}
+
+func (p *Player) queued() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.queue) > 0
+}
+
+func (p *Player) run() {
+	defer close(p.done)
+
+	for {
+		pat, ok := p.next()
+		if !ok {
+			select {
+			case <-p.wake:
+				continue
+			case <-p.stop:
+				return
+			}
+		}
+		if !p.play(pat) {
+			select {
+			case <-p.stop:
+				return
+			default:
+			}
+			if p.Err != nil {
+				return
+			}
+		}
+	}
+}
+
+// play plays a pattern, returning false if it was interrupted or failed.
+func (p *Player) play(pat Pattern) bool {
+	repeat := pat.Repeat
+	if repeat == 0 && pat.Duration() == 0 {
+		// Playing it forever would never give way
+		repeat = 1
+	}
+
+	for n := 0; repeat == 0 || n < repeat; n++ {
+		for _, f := range pat.Frames {
+			if !p.fade(f.Level, f.Fade) || !p.wait(f.Hold) {
+				return false
+			}
+		}
+		if repeat == 0 && p.queued() {
+			break
+		}
+	}
+	return true
+}
+
+// fade changes the level linearly to level over d.
+func (p *Player) fade(level float64, d time.Duration) bool {
+	from := p.level
+	start := time.Now()
+	for elapsed := time.Duration(0); elapsed < d; elapsed = time.Since(start) {
+		if !p.setLevel(from+(level-from)*float64(elapsed)/float64(d)) || !p.wait(Interval) {
+			return false
+		}
+	}
+	return p.setLevel(level)
+}
+
+func (p *Player) setLevel(level float64) bool {
+	level = math.Max(0, math.Min(1, level))
+	if p.set && level == p.level {
+		return true
+	}
+	if p.Err = p.out.SetLevel(math.Pow(level, Gamma)); p.Err != nil {
+		return false
+	}
+	p.level, p.set = level, true
+	return true
+}
+
+// wait waits for d, returning false if the pattern is interrupted first.
+func (p *Player) wait(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-p.cut:
+		return false
+	case <-p.stop:
+		return false
+	}
+}
@@ -0,0 +1,250 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package pca9685 drives the NXP PCA9685 16-channel, 12-bit PWM controller
+// over I2C.  Each output is a pwm.Channel, so it can drive servos, motors and
+// LEDs just like the BeagleBone's own PWM outputs.
+package pca9685
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/i2c"
+	"github.com/Ratfink/gopherbone/pwm"
+	"math"
+	"sync"
+	"time"
+)
+
+// ADDR is the I2C address with all address pins low.
+const ADDR = 0x40
+
+// Registers
+const (
+	MODE1        = 0x00
+	MODE2        = 0x01
+	LED0_ON_L    = 0x06 // Each channel has 4 registers: ON_L, ON_H, OFF_L, OFF_H
+	ALL_LED_ON_L = 0xfa
+	PRE_SCALE    = 0xfe
+)
+
+// Register bits
+const (
+	MODE1_RESTART = 0x80
+	MODE1_AI      = 0x20 // Register auto-increment
+	MODE1_SLEEP   = 0x10
+	MODE2_OUTDRV  = 0x04 // Totem pole rather than open drain outputs
+	LED_FULL      = 0x10 // In ON_H or OFF_H; output fully on or off
+)
+
+// Oscillator is the frequency of the PCA9685's internal oscillator in hertz.
+// Individual chips vary by a few percent, so for servos it may be worth
+// measuring and adjusting this.
+var Oscillator = 25000000.0
+
+// resolution is the number of steps in each PWM period.
+const resolution = 4096
+
+// A PCA9685 is a PCA9685 on an I2C bus.
+type PCA9685 struct {
+	dev      i2c.Conn
+	lock     sync.Mutex
+	period   time.Duration
+	channels [16]*Channel
+}
+
+// New resets the PCA9685 at addr on the given bus, with all outputs off and
+// a frequency of 50Hz, suitable for servos.
+func New(addr, bus byte) (pca *PCA9685, err error) {
+	dev, err := i2c.NewDevice(addr, bus)
+	if err != nil {
+		return
+	}
+
+	return NewConn(dev)
+}
+
+// NewConn is like New, but talks to the chip through conn, which may be a fake
+// for testing.
+func NewConn(conn i2c.Conn) (pca *PCA9685, err error) {
+	pca = &PCA9685{dev: conn}
+	for n := range pca.channels {
+		pca.channels[n] = &Channel{pca: pca, n: n}
+	}
+
+	if err = pca.dev.Write(ALL_LED_ON_L, []byte{0, 0, 0, LED_FULL}); err != nil {
+		return nil, err
+	}
+	if err = pca.dev.WriteReg(MODE2, MODE2_OUTDRV); err != nil {
+		return nil, err
+	}
+	if err = pca.dev.WriteReg(MODE1, MODE1_AI); err != nil {
+		return nil, err
+	}
+	time.Sleep(500 * time.Microsecond)
+	if err = pca.SetFrequency(50); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Channel returns one of the 16 outputs.
+func (pca *PCA9685) Channel(n int) (ch *Channel, err error) {
+	if n < 0 || n >= len(pca.channels) {
+		err = fmt.Errorf("Invalid channel: %d", n)
+		return
+	}
+	ch = pca.channels[n]
+
+	return
+}
+
+// SetFrequency sets the PWM frequency of all channels, between about 24Hz and
+// 1526Hz.  Duty cycles are kept as the same fraction of the period.
+func (pca *PCA9685) SetFrequency(hz float64) (err error) {
+	prescale := math.Floor(Oscillator/(resolution*hz)+0.5) - 1
+	if prescale < 3 || prescale > 255 {
+		err = fmt.Errorf("Invalid frequency: %g", hz)
+		return
+	}
+
+	pca.lock.Lock()
+	defer pca.lock.Unlock()
+
+	// The prescaler can only be changed while the oscillator is off
+	if err = pca.dev.WriteReg(MODE1, MODE1_AI|MODE1_SLEEP); err != nil {
+		return
+	}
+	if err = pca.dev.WriteReg(PRE_SCALE, byte(prescale)); err != nil {
+		return
+	}
+	if err = pca.dev.WriteReg(MODE1, MODE1_AI); err != nil {
+		return
+	}
+	time.Sleep(500 * time.Microsecond)
+	if err = pca.dev.WriteReg(MODE1, MODE1_AI|MODE1_RESTART); err != nil {
+		return
+	}
+	pca.period = time.Duration(float64(time.Second) * (prescale + 1) * resolution / Oscillator)
+
+	return
+}
+
+// Period returns the PWM period shared by all channels.
+func (pca *PCA9685) Period() time.Duration {
+	pca.lock.Lock()
+	defer pca.lock.Unlock()
+	return pca.period
+}
+
+// A Channel is one output of a PCA9685.  It implements pwm.Channel; since
+// the period is shared by all channels, setting it on one sets it for all.
+type Channel struct {
+	pca     *PCA9685
+	n       int
+	lock    sync.Mutex
+	duty    float64
+	enabled bool
+}
+
+var _ pwm.Channel = (*Channel)(nil)
+
+// update writes the channel's on and off times.  Duty cycles which round to
+// zero or a whole period use the full off and full on bits, since an off
+// time of 4096 would set the full off bit instead.
+func (ch *Channel) update() error {
+	reg := byte(LED0_ON_L + 4*ch.n)
+	off := 0
+	if ch.enabled {
+		off = int(ch.duty*resolution + 0.5)
+	}
+	switch {
+	case off <= 0:
+		return ch.pca.dev.Write(reg, []byte{0, 0, 0, LED_FULL})
+	case off >= resolution:
+		return ch.pca.dev.Write(reg, []byte{0, LED_FULL, 0, 0})
+	}
+	return ch.pca.dev.Write(reg, []byte{0, 0, byte(off), byte(off >> 8)})
+}
+
+// Period returns the PWM period shared by all channels.
+func (ch *Channel) Period() (time.Duration, error) {
+	return ch.pca.Period(), nil
+}
+
+// SetPeriod sets the PWM period of all channels.
+func (ch *Channel) SetPeriod(period time.Duration) error {
+	return ch.pca.SetFrequency(float64(time.Second) / float64(period))
+}
+
+// SetFrequency sets the PWM frequency of all channels.
+func (ch *Channel) SetFrequency(hz float64) error {
+	return ch.pca.SetFrequency(hz)
+}
+
+// DutyCycle returns the time for which each period is active.
+func (ch *Channel) DutyCycle() (time.Duration, error) {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	return time.Duration(ch.duty * float64(ch.pca.Period())), nil
+}
+
+// SetDutyCycle sets the time for which each period is active.
+func (ch *Channel) SetDutyCycle(duty time.Duration) (err error) {
+	period := ch.pca.Period()
+	if duty < 0 || duty > period {
+		err = fmt.Errorf("Invalid duty cycle: %v", duty)
+		return
+	}
+	return ch.SetDuty(float64(duty) / float64(period))
+}
+
+// SetDuty sets the fraction of each period which is active, from 0 to 1.
+func (ch *Channel) SetDuty(fraction float64) (err error) {
+	if fraction < 0 || fraction > 1 {
+		err = fmt.Errorf("Invalid duty: %g", fraction)
+		return
+	}
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	ch.duty = fraction
+
+	return ch.update()
+}
+
+// Enable starts the output.
+func (ch *Channel) Enable() error {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	ch.enabled = true
+
+	return ch.update()
+}
+
+// Disable turns the output fully off.
+func (ch *Channel) Disable() error {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	ch.enabled = false
+
+	return ch.update()
+}
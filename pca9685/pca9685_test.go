@@ -0,0 +1,46 @@
+package pca9685
+
+import (
+	"bytes"
+	"github.com/Ratfink/gopherbone/mock"
+	"testing"
+)
+
+func TestChannelDuty(t *testing.T) {
+	tests := []struct {
+		duty    float64
+		enabled bool
+		want    []byte
+	}{
+		{0.5, true, []byte{0, 0, 0x00, 0x08}},
+		{0.25, true, []byte{0, 0, 0x00, 0x04}},
+		{1.0 / resolution, true, []byte{0, 0, 0x01, 0x00}},
+		{4095.0 / resolution, true, []byte{0, 0, 0xff, 0x0f}},
+		// Rounding to a whole period or nothing needs the full bits
+		{4095.6 / resolution, true, []byte{0, LED_FULL, 0, 0}},
+		{1, true, []byte{0, LED_FULL, 0, 0}},
+		{0.4 / resolution, true, []byte{0, 0, 0, LED_FULL}},
+		{0, true, []byte{0, 0, 0, LED_FULL}},
+		{0.5, false, []byte{0, 0, 0, LED_FULL}},
+	}
+	for _, test := range tests {
+		dev := mock.NewDevice()
+		pca, err := NewConn(dev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ch, _ := pca.Channel(3)
+		if err = ch.SetDuty(test.duty); err != nil {
+			t.Fatal(err)
+		}
+		if test.enabled {
+			if err = ch.Enable(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		reg := LED0_ON_L + 4*3
+		if got := dev.Regs[reg : reg+4]; !bytes.Equal(got, test.want) {
+			t.Errorf("Duty %g, enabled %v: Wrote % x, want % x", test.duty, test.enabled, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package gopherbone
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Group is the group UdevRules gives access to the hardware, and which
+// permission errors suggest joining.
+var Group = "gpio"
+
+// AccessTimeout is how long WaitWritable waits for udev to give a newly
+// exported file to Group.
+var AccessTimeout = time.Second
+
+// A PermissionError is returned in place of a bare "permission denied" when
+// a device or sysfs file can't be opened, saying what to do about it.  It
+// wraps the original error, so errors.Is(err, os.ErrPermission) still works.
+type PermissionError struct {
+	Path string
+	Err  error
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("Permission denied on %s: run as root, or install the rules from gopherbone.UdevRules and add the user to the %s group", e.Path, Group)
+}
+
+// Unwrap returns the underlying error.
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+// CheckPermission returns err wrapped in a *PermissionError if it is a
+// permission error, and err unchanged otherwise.
+func CheckPermission(path string, err error) error {
+	var pe *PermissionError
+	if err == nil || !errors.Is(err, os.ErrPermission) || errors.As(err, &pe) {
+		return err
+	}
+	return &PermissionError{Path: path, Err: err}
+}
+
+// OpenFile is os.OpenFile, with permission errors explained by
+// CheckPermission.  The GopherBone packages open device and sysfs files with
+// it.
+func OpenFile(name string, flag int, perm os.FileMode) (f *os.File, err error) {
+	f, err = os.OpenFile(name, flag, perm)
+	err = CheckPermission(name, err)
+	return
+}
+
+// WaitWritable waits up to AccessTimeout for path to become writable.  When
+// a GPIO or PWM channel is exported, its files belong to root until udev
+// gets round to applying its rules, so a program that isn't root must wait
+// before using them.  It returns at once if the program is root.
+func WaitWritable(path string) (err error) {
+	deadline := time.Now().Add(AccessTimeout)
+	for {
+		err = syscall.Access(path, 2) // W_OK
+		if err == nil || err != syscall.EACCES && err != syscall.EPERM || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		err = CheckPermission(path, &os.PathError{Op: "access", Path: path, Err: err})
+	}
+	return
+}
+
+// UdevRules returns udev rules giving group access to GPIO, pin muxing,
+// PWM, LEDs, the ADC, I2C and SPI, so that GopherBone programs needn't run
+// as root.  Device nodes are made mode 0660, and sysfs attributes group
+// writable.  Save them as, for example, /etc/udev/rules.d/80-gopherbone.rules,
+// then run "udevadm control --reload" and "udevadm trigger", or reboot.
+func UdevRules(group string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by gopherbone.UdevRules for group %s\n", group)
+
+	// Device nodes
+	for _, subsystem := range []string{"i2c-dev", "spidev", "gpio", "input", "rtc"} {
+		fmt.Fprintf(&b, "SUBSYSTEM==\"%s\", GROUP=\"%s\", MODE=\"0660\"\n", subsystem, group)
+	}
+
+	// sysfs attributes, whose permissions udev can't set itself
+	chown := func(match, paths string) {
+		fmt.Fprintf(&b, "%s, RUN+=\"/bin/sh -c 'chgrp -R %s %s && chmod -R g+w %s'\"\n", match, group, paths, paths)
+	}
+	chown(`SUBSYSTEM=="gpio", KERNEL=="gpiochip*", ACTION=="add"`,
+		"/sys/class/gpio/export /sys/class/gpio/unexport")
+	chown(`SUBSYSTEM=="gpio", KERNEL=="gpio*", ACTION=="add"`,
+		"/sys%p/active_low /sys%p/direction /sys%p/edge /sys%p/value")
+	chown(`SUBSYSTEM=="pwm", KERNEL=="pwmchip*", ACTION=="add"`,
+		"/sys%p/export /sys%p/unexport")
+	// Exporting a channel is a change to its chip
+	chown(`SUBSYSTEM=="pwm", KERNEL=="pwmchip*", ACTION=="change"`,
+		"/sys%p/pwm*/")
+	chown(`SUBSYSTEM=="leds", ACTION=="add"`,
+		"/sys%p/brightness /sys%p/trigger")
+	chown(`SUBSYSTEM=="iio", ACTION=="add"`,
+		"/sys%p/")
+	chown(`SUBSYSTEM=="platform", KERNEL=="ocp:*_pinmux", ACTION=="add"`,
+		"/sys%p/state")
+
+	return b.String()
+}
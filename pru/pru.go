@@ -0,0 +1,200 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* This package controls the AM335x's two Programmable Real-time Units
+ * through the kernel's remoteproc framework, and talks to them through rpmsg
+ * character devices or their memories mapped via /dev/mem.  Firmware files
+ * must be installed in /lib/firmware.
+ */
+package pru
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// remoteprocNames are the device names remoteproc gives PRU0 and PRU1.
+var remoteprocNames = [2]string{"4a334000.pru", "4a338000.pru"}
+
+// Physical addresses and sizes of the PRU memories.
+const (
+	DATA_RAM0_ADDR  = 0x4a300000
+	DATA_RAM1_ADDR  = 0x4a302000
+	DATA_RAM_SIZE   = 0x2000
+	SHARED_RAM_ADDR = 0x4a310000
+	SHARED_RAM_SIZE = 0x3000
+)
+
+// A PRU is one of the two PRU cores.
+type PRU struct {
+	n    int
+	path string
+}
+
+// Open finds the remoteproc device for PRU n (0 or 1).
+func Open(n int) (pru *PRU, err error) {
+	if n < 0 || n > 1 {
+		err = fmt.Errorf("Invalid PRU: %d", n)
+		return
+	}
+
+	procs, err := filepath.Glob("/sys/class/remoteproc/remoteproc*")
+	if err != nil {
+		return
+	}
+	for _, proc := range procs {
+		name, e := readAttr(filepath.Join(proc, "name"))
+		if e == nil && name == remoteprocNames[n] {
+			pru = &PRU{n: n, path: proc}
+			return
+		}
+	}
+
+	err = fmt.Errorf("PRU%d not found; is the PRU remoteproc driver loaded?", n)
+	return
+}
+
+func readAttr(path string) (value string, err error) {
+	data, err := os.ReadFile(path)
+	value = strings.TrimSpace(string(data))
+
+	return
+}
+
+func (pru *PRU) writeAttr(attr, value string) (err error) {
+	f, err := gopherbone.OpenFile(filepath.Join(pru.path, attr), os.O_WRONLY, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s", value)
+
+	return
+}
+
+// State returns the core's remoteproc state, such as "offline" or "running".
+func (pru *PRU) State() (string, error) {
+	return readAttr(filepath.Join(pru.path, "state"))
+}
+
+// Running reports whether the core is running.
+func (pru *PRU) Running() (running bool, err error) {
+	state, err := pru.State()
+	running = state == "running"
+
+	return
+}
+
+// Load stops the core if it is running, and selects the named firmware file
+// from /lib/firmware to run next time it is started.
+func (pru *PRU) Load(firmware string) (err error) {
+	if err = pru.Stop(); err != nil {
+		return
+	}
+	return pru.writeAttr("firmware", firmware)
+}
+
+// Start starts the core running its firmware.
+func (pru *PRU) Start() (err error) {
+	running, err := pru.Running()
+	if err != nil || running {
+		return
+	}
+	return pru.writeAttr("state", "start")
+}
+
+// Stop stops the core.  Stopping a core which isn't running does nothing.
+func (pru *PRU) Stop() (err error) {
+	running, err := pru.Running()
+	if err != nil || !running {
+		return
+	}
+	return pru.writeAttr("state", "stop")
+}
+
+// RPMsg opens the rpmsg character device created by the firmware running on
+// the core, for exchanging messages with it.  Messages are limited to 496
+// bytes each.
+func (pru *PRU) RPMsg() (*os.File, error) {
+	return gopherbone.OpenFile(fmt.Sprintf("/dev/rpmsg_pru%d", 30+pru.n), os.O_RDWR, 0)
+}
+
+// A Memory is a region of PRU memory mapped into this process.
+type Memory struct {
+	data []byte
+}
+
+func mapMemory(addr, size int64) (mem *Memory, err error) {
+	f, err := gopherbone.OpenFile("/dev/mem", os.O_RDWR|os.O_SYNC, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	data, err := syscall.Mmap(int(f.Fd()), addr, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return
+	}
+	mem = &Memory{data: data}
+
+	return
+}
+
+// DataRAM maps the core's own 8KB data RAM.
+func (pru *PRU) DataRAM() (*Memory, error) {
+	if pru.n == 0 {
+		return mapMemory(DATA_RAM0_ADDR, DATA_RAM_SIZE)
+	}
+	return mapMemory(DATA_RAM1_ADDR, DATA_RAM_SIZE)
+}
+
+// SharedRAM maps the 12KB RAM shared by both cores.
+func SharedRAM() (*Memory, error) {
+	return mapMemory(SHARED_RAM_ADDR, SHARED_RAM_SIZE)
+}
+
+// Bytes returns the mapped memory.  Writes to it go straight to the PRU's
+// memory.
+func (mem *Memory) Bytes() []byte {
+	return mem.data
+}
+
+// Uint32 reads the little-endian 32-bit word at offset.
+func (mem *Memory) Uint32(offset int) uint32 {
+	return binary.LittleEndian.Uint32(mem.data[offset:])
+}
+
+// SetUint32 writes a little-endian 32-bit word at offset.
+func (mem *Memory) SetUint32(offset int, v uint32) {
+	binary.LittleEndian.PutUint32(mem.data[offset:], v)
+}
+
+// Close unmaps the memory.
+func (mem *Memory) Close() error {
+	return syscall.Munmap(mem.data)
+}
@@ -0,0 +1,254 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* This PWM system uses the kernel's sysfs PWM interface.  The pins must be
+ * muxed for PWM first, for example with gpio.Pin.Mux or by loading an overlay
+ * with the capemgr package.
+ */
+package pwm
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"os"
+	"time"
+)
+
+// SysfsPath is where the kernel's sysfs PWM interface is, which may be
+// changed for testing or for unusual systems.
+var SysfsPath = "/sys/class/pwm"
+
+// A Channel is anything which can be used like a PWM channel, such as an
+// output of a PCA9685.  Drivers which take a Channel work with any of them.
+type Channel interface {
+	Period() (time.Duration, error)
+	SetPeriod(period time.Duration) error
+	DutyCycle() (time.Duration, error)
+	SetDutyCycle(duty time.Duration) error
+	SetFrequency(hz float64) error
+	SetDuty(fraction float64) error
+	Enable() error
+	Disable() error
+}
+
+// A PWM structure represents one channel of a PWM chip.  To use a PWM, create
+// a pointer to a PWM struct using the Export function.
+type PWM struct {
+	Chip    int
+	Channel int
+
+	// cleanup disables and unexports the channel if the program exits
+	// without doing so; it is nil if the channel was already exported
+	cleanup *gopherbone.Handle
+}
+
+func (pwm *PWM) path(attr string) string {
+	return fmt.Sprintf("%s/pwmchip%d/pwm%d/%s", SysfsPath, pwm.Chip, pwm.Channel, attr)
+}
+
+// Export creates a PWM structure for the given chip and channel, exports the
+// channel to sysfs, and returns the PWM structure.
+func Export(chip, channel int) (pwm *PWM, err error) {
+	pwm = &PWM{Chip: chip, Channel: channel}
+	var f *os.File
+
+	_, err = os.Stat(fmt.Sprintf("%s/pwmchip%d/pwm%d", SysfsPath, chip, channel))
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	// Only a channel this call exports is released by Cleanup; one
+	// exported already belongs to someone else
+	if err != nil {
+		f, err = gopherbone.OpenFile(fmt.Sprintf("%s/pwmchip%d/export", SysfsPath, chip), os.O_WRONLY, 0666)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		start := time.Now()
+		_, err = fmt.Fprintf(f, "%d", channel)
+		gopherbone.TraceSysfsWrite(start, fmt.Sprintf("%s/pwmchip%d/export", SysfsPath, chip), fmt.Sprint(channel), err)
+		if err != nil {
+			return
+		}
+		// Give udev time to let a non-root user at the new files
+		if err = gopherbone.WaitWritable(pwm.path("enable")); err != nil {
+			return
+		}
+		pwm.cleanup = gopherbone.Register(fmt.Sprintf("PWM %d:%d", chip, channel), pwm.Unexport)
+	}
+
+	return
+}
+
+// Unexport disables the channel and removes its sysfs entry.
+func (pwm *PWM) Unexport() (err error) {
+	pwm.cleanup.Unregister()
+	pwm.Disable()
+
+	f, err := gopherbone.OpenFile(fmt.Sprintf("%s/pwmchip%d/unexport", SysfsPath, pwm.Chip), os.O_WRONLY, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	start := time.Now()
+	_, err = fmt.Fprintf(f, "%d", pwm.Channel)
+	gopherbone.TraceSysfsWrite(start, fmt.Sprintf("%s/pwmchip%d/unexport", SysfsPath, pwm.Chip), fmt.Sprint(pwm.Channel), err)
+
+	return
+}
+
+func (pwm *PWM) readInt(attr string) (value int64, err error) {
+	f, err := gopherbone.OpenFile(pwm.path(attr), os.O_RDONLY, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	n, err := fmt.Fscanf(f, "%d", &value)
+	if n != 1 {
+		err = fmt.Errorf("Bad number of values read from %s: %d", pwm.path(attr), n)
+	}
+
+	return
+}
+
+func (pwm *PWM) write(attr string, value interface{}) (err error) {
+	f, err := gopherbone.OpenFile(pwm.path(attr), os.O_WRONLY, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	start := time.Now()
+	_, err = fmt.Fprint(f, value)
+	if gopherbone.Tracing() {
+		gopherbone.TraceSysfsWrite(start, pwm.path(attr), fmt.Sprint(value), err)
+	}
+
+	return
+}
+
+// Period returns the PWM period.
+func (pwm *PWM) Period() (period time.Duration, err error) {
+	ns, err := pwm.readInt("period")
+	period = time.Duration(ns)
+
+	return
+}
+
+// SetPeriod sets the PWM period.  The kernel refuses periods shorter than the
+// current duty cycle, so shorten the duty cycle first if necessary.
+func (pwm *PWM) SetPeriod(period time.Duration) (err error) {
+	return pwm.write("period", int64(period))
+}
+
+// DutyCycle returns the time for which each period is active.
+func (pwm *PWM) DutyCycle() (duty time.Duration, err error) {
+	ns, err := pwm.readInt("duty_cycle")
+	duty = time.Duration(ns)
+
+	return
+}
+
+// SetDutyCycle sets the time for which each period is active.  It may not be
+// longer than the period.
+func (pwm *PWM) SetDutyCycle(duty time.Duration) (err error) {
+	return pwm.write("duty_cycle", int64(duty))
+}
+
+// SetFrequency sets the PWM frequency in hertz, keeping the same fraction of
+// each period active.
+func (pwm *PWM) SetFrequency(hz float64) (err error) {
+	if hz <= 0 {
+		err = fmt.Errorf("Invalid frequency: %g", hz)
+		return
+	}
+	oldPeriod, err := pwm.Period()
+	if err != nil {
+		return
+	}
+	oldDuty, err := pwm.DutyCycle()
+	if err != nil {
+		return
+	}
+
+	period := time.Duration(float64(time.Second) / hz)
+	var duty time.Duration
+	if oldPeriod > 0 {
+		duty = time.Duration(float64(period) * float64(oldDuty) / float64(oldPeriod))
+	}
+
+	// Keep the duty cycle no longer than the period at every step
+	if period < oldPeriod {
+		if err = pwm.SetDutyCycle(duty); err != nil {
+			return
+		}
+		return pwm.SetPeriod(period)
+	}
+	if err = pwm.SetPeriod(period); err != nil {
+		return
+	}
+	return pwm.SetDutyCycle(duty)
+}
+
+// SetDuty sets the fraction of each period which is active, from 0 to 1.
+func (pwm *PWM) SetDuty(fraction float64) (err error) {
+	if fraction < 0 || fraction > 1 {
+		err = fmt.Errorf("Invalid duty: %g", fraction)
+		return
+	}
+	period, err := pwm.Period()
+	if err != nil {
+		return
+	}
+
+	return pwm.SetDutyCycle(time.Duration(float64(period) * fraction))
+}
+
+// Enabled returns whether the PWM output is running.
+func (pwm *PWM) Enabled() (enabled bool, err error) {
+	value, err := pwm.readInt("enable")
+	enabled = value != 0
+
+	return
+}
+
+// Enable starts the PWM output.
+func (pwm *PWM) Enable() (err error) {
+	return pwm.write("enable", 1)
+}
+
+// Disable stops the PWM output.
+func (pwm *PWM) Disable() (err error) {
+	return pwm.write("enable", 0)
+}
+
+// SetInverted sets whether the output is low, rather than high, for the
+// active part of each period.  The channel must be disabled to change this.
+func (pwm *PWM) SetInverted(inverted bool) (err error) {
+	if inverted {
+		return pwm.write("polarity", "inversed")
+	}
+	return pwm.write("polarity", "normal")
+}
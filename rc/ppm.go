@@ -0,0 +1,136 @@
+package rc
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"sync"
+	"time"
+)
+
+// SyncGap is the shortest gap taken as the sync gap between PPM frames.  It
+// must be longer than any channel's pulse.
+var SyncGap = 3 * time.Millisecond
+
+// A Frame holds every channel's pulse width in microseconds.
+type Frame []int
+
+// A PPM reads every channel of a receiver's PPM sum output, in which each
+// channel's width is the time between one edge and the next, and frames are
+// separated by a longer sync gap.  Each complete frame is sent on C;
+// frames are dropped rather than queued if they aren't received in time.
+type PPM struct {
+	C <-chan Frame
+
+	c    chan Frame
+	w    *gpio.EdgeWatcher
+	n    int
+	lock sync.Mutex
+	last Frame
+	at   time.Time
+	done chan struct{}
+}
+
+// OpenPPM reads a PPM signal with n channels on the given GPIO, which must
+// not be exported through sysfs.  If n is zero, frames of any length are
+// accepted.
+func OpenPPM(pin, n int) (p *PPM, err error) {
+	// Only one edge is watched, so the signal's polarity doesn't matter
+	w, err := gpio.OpenLineEvents(pin, gpio.Rising)
+	if err != nil {
+		return
+	}
+	return NewPPM(w, n), nil
+}
+
+// NewPPM reads a PPM signal with n channels from a watcher watching either
+// Rising or Falling edges of its pin.  The PPM closes the watcher when
+// closed.
+func NewPPM(w *gpio.EdgeWatcher, n int) *PPM {
+	p := &PPM{
+		c:    make(chan Frame, 1),
+		w:    w,
+		n:    n,
+		done: make(chan struct{}),
+	}
+	p.C = p.c
+	go p.run()
+	return p
+}
+
+func (p *PPM) run() {
+	defer close(p.done)
+	defer close(p.c)
+
+	var frame Frame
+	var last time.Duration
+	synced, first := false, true
+	for ev := range p.w.C {
+		gap := ev.Time - last
+		last = ev.Time
+		if first {
+			first = false
+			continue
+		}
+		if gap >= SyncGap {
+			if synced && len(frame) > 0 && (p.n == 0 || len(frame) == p.n) {
+				p.deliver(frame)
+			}
+			frame, synced = nil, true
+			continue
+		}
+		if !synced {
+			continue
+		}
+		if !valid(gap) {
+			// A glitch spoils the frame; wait for the next sync
+			frame, synced = nil, false
+			continue
+		}
+		frame = append(frame, int(gap/time.Microsecond))
+	}
+}
+
+func (p *PPM) deliver(frame Frame) {
+	p.lock.Lock()
+	p.last, p.at = frame, time.Now()
+	p.lock.Unlock()
+
+	// Replace an unreceived frame with the newer one
+	select {
+	case <-p.c:
+	default:
+	}
+	select {
+	case p.c <- frame:
+	default:
+	}
+}
+
+// Frame returns the latest complete frame.
+func (p *PPM) Frame() (frame Frame, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.at.IsZero() || time.Since(p.at) > Timeout {
+		return nil, ErrNoSignal
+	}
+	return append(Frame(nil), p.last...), nil
+}
+
+// Channel returns channel i's latest pulse width, counting from 0.
+func (p *PPM) Channel(i int) (width time.Duration, err error) {
+	frame, err := p.Frame()
+	if err != nil {
+		return
+	}
+	if i < 0 || i >= len(frame) {
+		return 0, fmt.Errorf("Invalid channel: %d of %d", i, len(frame))
+	}
+	return time.Duration(frame[i]) * time.Microsecond, nil
+}
+
+// Close stops reading the signal and closes C.
+func (p *PPM) Close() (err error) {
+	err = p.w.Close()
+	<-p.done
+	return
+}
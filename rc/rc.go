@@ -0,0 +1,155 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package rc reads hobby RC receivers, for driving a robot from an ordinary
+// transmitter.  Each channel of a receiver is a pulse repeated about every
+// 20ms, 1000µs long at one end of the stick's travel and 2000µs at the
+// other.  Receivers either have a PWM output for each channel, read with a
+// Channel, or put them all on one PPM output, read with a PPM.  Both are
+// timed from edges timestamped by the kernel's interrupt handler, so the
+// readings are good to a few microseconds.
+package rc
+
+import (
+	"errors"
+	"github.com/Ratfink/gopherbone/gpio"
+	"sync"
+	"time"
+)
+
+// Nominal pulse widths.
+const (
+	MinPulse = 1000 * time.Microsecond
+	MidPulse = 1500 * time.Microsecond
+	MaxPulse = 2000 * time.Microsecond
+)
+
+var (
+	// ValidMin and ValidMax bound the pulse widths accepted; anything
+	// else is taken to be noise.
+	ValidMin = 700 * time.Microsecond
+	ValidMax = 2300 * time.Microsecond
+	// Timeout is how long since the last good pulse before a channel is
+	// taken to have lost the signal, so that a robot can stop when the
+	// transmitter goes out of range.
+	Timeout = 100 * time.Millisecond
+)
+
+// ErrNoSignal is returned when there has been no good pulse within Timeout.
+var ErrNoSignal = errors.New("No RC signal")
+
+func valid(width time.Duration) bool {
+	return width >= ValidMin && width <= ValidMax
+}
+
+// Normalize maps a pulse width to -1 at MinPulse, 0 at MidPulse and 1 at
+// MaxPulse, clamped to that range.
+func Normalize(width time.Duration) float64 {
+	v := float64(width-MidPulse) / float64(MaxPulse-MidPulse)
+	if v < -1 {
+		v = -1
+	} else if v > 1 {
+		v = 1
+	}
+	return v
+}
+
+// A Channel reads one PWM channel of a receiver.
+type Channel struct {
+	w *gpio.EdgeWatcher
+
+	lock  sync.Mutex
+	width time.Duration
+	at    time.Time
+	done  chan struct{}
+}
+
+// OpenChannel reads a receiver channel on the given GPIO, which must not be
+// exported through sysfs.
+func OpenChannel(pin int) (c *Channel, err error) {
+	w, err := gpio.OpenLineEvents(pin, gpio.Both)
+	if err != nil {
+		return
+	}
+	return NewChannel(w), nil
+}
+
+// NewChannel reads a receiver channel from a watcher watching Both edges
+// of its pin.  The Channel closes the watcher when closed.
+func NewChannel(w *gpio.EdgeWatcher) *Channel {
+	c := &Channel{w: w, done: make(chan struct{})}
+	go c.run()
+	return c
+}
+
+func (c *Channel) run() {
+	defer close(c.done)
+	var rise time.Duration
+	high := false
+	for ev := range c.w.C {
+		if ev.Value == 1 {
+			rise, high = ev.Time, true
+			continue
+		}
+		if !high {
+			continue
+		}
+		high = false
+		if width := ev.Time - rise; valid(width) {
+			c.lock.Lock()
+			c.width, c.at = width, time.Now()
+			c.lock.Unlock()
+		}
+	}
+}
+
+// Pulse returns the latest pulse width.
+func (c *Channel) Pulse() (width time.Duration, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.at.IsZero() || time.Since(c.at) > Timeout {
+		return 0, ErrNoSignal
+	}
+	return c.width, nil
+}
+
+// Microseconds returns the latest pulse width in microseconds.
+func (c *Channel) Microseconds() (us int, err error) {
+	width, err := c.Pulse()
+	return int(width / time.Microsecond), err
+}
+
+// Value returns the latest pulse width normalized, as by Normalize.
+func (c *Channel) Value() (v float64, err error) {
+	width, err := c.Pulse()
+	if err != nil {
+		return
+	}
+	return Normalize(width), nil
+}
+
+// Close stops reading the channel.
+func (c *Channel) Close() (err error) {
+	err = c.w.Close()
+	<-c.done
+	return
+}
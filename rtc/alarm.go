@@ -0,0 +1,136 @@
+package rtc
+
+import (
+	"fmt"
+	"time"
+)
+
+// An AlarmMatch says which parts of the time an alarm compares, and so how
+// often it fires.
+type AlarmMatch int
+
+// Alarm matches.  Each compares the fields of the ones before it as well:
+// MatchHours fires when the hours, minutes and (for alarm 1) seconds match,
+// once a day.  EverySecond and MatchSeconds are only available on alarm 1,
+// and EveryMinute only on alarm 2, which has no seconds.
+const (
+	EverySecond AlarmMatch = iota
+	EveryMinute
+	MatchSeconds
+	MatchMinutes
+	MatchHours
+	// MatchDate also compares the day of the month; MatchWeekday compares
+	// the day of the week instead.
+	MatchDate
+	MatchWeekday
+)
+
+// alarmMasks are the values of the AxMy mask bits for each match, bit 0
+// for seconds through bit 3 for the date; 1 means don't care.
+var alarmMasks = map[AlarmMatch]uint{
+	EverySecond:  0xf,
+	EveryMinute:  0xf,
+	MatchSeconds: 0xe,
+	MatchMinutes: 0xc,
+	MatchHours:   0x8,
+	MatchDate:    0x0,
+	MatchWeekday: 0x0,
+}
+
+// SetAlarm sets alarm 1 or 2 of a DS3231 to fire when the clock matches t as
+// given by match, and enables it.  While the flag of a fired alarm is set,
+// the chip's INT/SQW pin is held low, so it can be watched with a GPIO.
+func (rtc *RTC) SetAlarm(n int, t time.Time, match AlarmMatch) (err error) {
+	if rtc.chip != DS3231 {
+		err = ErrUnsupported
+		return
+	}
+	if n != 1 && n != 2 {
+		err = fmt.Errorf("Invalid alarm: %d", n)
+		return
+	}
+	mask, ok := alarmMasks[match]
+	if !ok || (n == 1 && match == EveryMinute) ||
+		(n == 2 && (match == EverySecond || match == MatchSeconds)) {
+		err = fmt.Errorf("Invalid match for alarm %d: %d", n, match)
+		return
+	}
+
+	t = t.UTC()
+	day := toBCD(t.Day())
+	if match == MatchWeekday {
+		day = byte(t.Weekday()) + 1 | 0x40
+	}
+	regs := []byte{toBCD(t.Second()), toBCD(t.Minute()), toBCD(t.Hour()), day}
+	for i := range regs {
+		if mask&(1<<uint(i)) != 0 {
+			regs[i] |= 0x80
+		}
+	}
+
+	reg := byte(DS3231_ALARM1)
+	if n == 2 {
+		reg = DS3231_ALARM2
+		regs = regs[1:]
+	}
+	if err = rtc.dev.Write(reg, regs); err != nil {
+		return
+	}
+	if err = rtc.ClearAlarm(n); err != nil {
+		return
+	}
+	return rtc.updateReg(DS3231_CONTROL, DS3231_INTCN|alarmBit(n), DS3231_INTCN|alarmBit(n))
+}
+
+// alarmBit returns the bit for an alarm in the control and status
+// registers, which happen to be the same.
+func alarmBit(n int) byte {
+	if n == 1 {
+		return DS3231_A1IE
+	}
+	return DS3231_A2IE
+}
+
+// DisableAlarm disables alarm 1 or 2 of a DS3231.
+func (rtc *RTC) DisableAlarm(n int) (err error) {
+	if rtc.chip != DS3231 {
+		err = ErrUnsupported
+		return
+	}
+	if n != 1 && n != 2 {
+		err = fmt.Errorf("Invalid alarm: %d", n)
+		return
+	}
+	return rtc.updateReg(DS3231_CONTROL, alarmBit(n), 0)
+}
+
+// AlarmFired reports whether alarm 1 or 2 of a DS3231 has fired since its
+// flag was last cleared.
+func (rtc *RTC) AlarmFired(n int) (fired bool, err error) {
+	if rtc.chip != DS3231 {
+		err = ErrUnsupported
+		return
+	}
+	if n != 1 && n != 2 {
+		err = fmt.Errorf("Invalid alarm: %d", n)
+		return
+	}
+	status, err := rtc.dev.ReadReg(DS3231_STATUS)
+	fired = status&alarmBit(n) != 0
+
+	return
+}
+
+// ClearAlarm clears the flag of alarm 1 or 2 of a DS3231, releasing the
+// INT/SQW pin.
+func (rtc *RTC) ClearAlarm(n int) (err error) {
+	if rtc.chip != DS3231 {
+		err = ErrUnsupported
+		return
+	}
+	if n != 1 && n != 2 {
+		err = fmt.Errorf("Invalid alarm: %d", n)
+		return
+	}
+	return rtc.updateReg(DS3231_STATUS, alarmBit(n), 0)
+}
@@ -0,0 +1,252 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package rtc drives the Maxim DS1307 and DS3231 real-time clocks over I2C,
+// so that a BeagleBone with no network connection can keep time across power
+// cycles.  The DS3231 also has a temperature sensor and two alarms.
+//
+// The clock is kept in UTC.  Both chips store a two digit year, taken to be
+// in the 2000s; the DS3231's century bit extends this to 2199.
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/i2c"
+	"time"
+)
+
+// ADDR is the I2C address of both chips.
+const ADDR = 0x68
+
+// Timekeeping registers, common to both chips
+const (
+	SECONDS = 0x00 // bit 7 is CH on the DS1307
+	MINUTES = 0x01
+	HOURS   = 0x02 // bit 6 selects 12 hour mode, bit 5 is then PM
+	DAY     = 0x03
+	DATE    = 0x04
+	MONTH   = 0x05 // bit 7 is the century on the DS3231
+	YEAR    = 0x06
+)
+
+// DS1307 registers and bits
+const (
+	DS1307_CONTROL = 0x07
+	DS1307_CH      = 0x80 // clock halt
+)
+
+// DS3231 registers
+const (
+	DS3231_ALARM1  = 0x07
+	DS3231_ALARM2  = 0x0b
+	DS3231_CONTROL = 0x0e
+	DS3231_STATUS  = 0x0f
+	DS3231_AGING   = 0x10
+	DS3231_TEMP    = 0x11
+)
+
+// DS3231 control register bits
+const (
+	DS3231_EOSC  = 0x80 // oscillator disabled on battery
+	DS3231_BBSQW = 0x40
+	DS3231_CONV  = 0x20
+	DS3231_INTCN = 0x04 // INT/SQW pin signals alarms
+	DS3231_A2IE  = 0x02
+	DS3231_A1IE  = 0x01
+)
+
+// DS3231 status register bits
+const (
+	DS3231_OSF     = 0x80 // oscillator stopped; time is invalid
+	DS3231_EN32KHZ = 0x08
+	DS3231_BSY     = 0x04
+	DS3231_A2F     = 0x02
+	DS3231_A1F     = 0x01
+)
+
+// ErrUnsupported is returned for features the chip doesn't have, such as the
+// temperature sensor on a DS1307.
+var ErrUnsupported = errors.New("Not supported by this chip")
+
+// ErrNotSet is returned by SyncSystemClock when the clock has stopped since
+// it was last set, so its time can't be trusted.
+var ErrNotSet = errors.New("RTC time is not valid")
+
+// A Chip is a model of clock.
+type Chip int
+
+// Supported chips.  The DS3231 also covers the DS3232 and DS3231M.
+const (
+	DS1307 Chip = iota
+	DS3231
+)
+
+// An RTC is a DS1307 or DS3231 on an I2C bus.
+type RTC struct {
+	dev  i2c.Conn
+	chip Chip
+}
+
+// New returns the clock of the given model at addr on the given bus.
+func New(addr, bus byte, chip Chip) (rtc *RTC, err error) {
+	if chip != DS1307 && chip != DS3231 {
+		err = fmt.Errorf("Invalid chip: %d", chip)
+		return
+	}
+	dev, err := i2c.NewDevice(addr, bus)
+	if err != nil {
+		return
+	}
+
+	return NewConn(dev, chip)
+}
+
+// NewConn is like New, but talks to the chip through conn, which may be a fake
+// for testing.
+func NewConn(conn i2c.Conn, chip Chip) (rtc *RTC, err error) {
+	if chip != DS1307 && chip != DS3231 {
+		err = fmt.Errorf("Invalid chip: %d", chip)
+		return
+	}
+	rtc = &RTC{dev: conn, chip: chip}
+
+	return
+}
+
+func fromBCD(b byte) int {
+	return int(b>>4)*10 + int(b&0x0f)
+}
+
+func toBCD(n int) byte {
+	return byte(n/10)<<4 | byte(n%10)
+}
+
+// fromHours decodes an hours register in either 12 or 24 hour mode.
+func fromHours(b byte) int {
+	if b&0x40 == 0 {
+		return fromBCD(b & 0x3f)
+	}
+	h := fromBCD(b&0x1f) % 12
+	if b&0x20 != 0 {
+		h += 12
+	}
+	return h
+}
+
+// Time returns the time kept by the clock.  Check Valid first if the clock
+// may never have been set.
+func (rtc *RTC) Time() (t time.Time, err error) {
+	regs, err := rtc.dev.Read(SECONDS, 7)
+	if err != nil {
+		return
+	}
+
+	year := 2000 + fromBCD(regs[YEAR])
+	if rtc.chip == DS3231 && regs[MONTH]&0x80 != 0 {
+		year += 100
+	}
+	t = time.Date(year, time.Month(fromBCD(regs[MONTH]&0x1f)), fromBCD(regs[DATE]&0x3f),
+		fromHours(regs[HOURS]), fromBCD(regs[MINUTES]&0x7f), fromBCD(regs[SECONDS]&0x7f), 0, time.UTC)
+
+	return
+}
+
+// SetTime sets the clock, in 24 hour mode, and starts it if it was stopped.
+// Fractions of a second are discarded.
+func (rtc *RTC) SetTime(t time.Time) (err error) {
+	t = t.UTC()
+	max := 2099
+	if rtc.chip == DS3231 {
+		max = 2199
+	}
+	if t.Year() < 2000 || t.Year() > max {
+		err = fmt.Errorf("Year out of range: %d", t.Year())
+		return
+	}
+
+	month := toBCD(int(t.Month()))
+	if t.Year() >= 2100 {
+		month |= 0x80
+	}
+	regs := []byte{
+		toBCD(t.Second()), // clears CH on the DS1307
+		toBCD(t.Minute()),
+		toBCD(t.Hour()),
+		byte(t.Weekday()) + 1,
+		toBCD(t.Day()),
+		month,
+		toBCD(t.Year() % 100),
+	}
+	if err = rtc.dev.Write(SECONDS, regs); err != nil {
+		return
+	}
+
+	if rtc.chip == DS3231 {
+		// The time is good now, so clear the oscillator stop flag
+		err = rtc.updateReg(DS3231_STATUS, DS3231_OSF, 0)
+	}
+
+	return
+}
+
+// Valid reports whether the clock has kept running since it was last set.
+// It is false for a new chip, or one whose battery has run out.
+func (rtc *RTC) Valid() (valid bool, err error) {
+	var b byte
+	switch rtc.chip {
+	case DS1307:
+		b, err = rtc.dev.ReadReg(SECONDS)
+		valid = b&DS1307_CH == 0
+	case DS3231:
+		b, err = rtc.dev.ReadReg(DS3231_STATUS)
+		valid = b&DS3231_OSF == 0
+	}
+
+	return
+}
+
+// Temperature returns the DS3231's die temperature in degrees Celsius, to a
+// quarter of a degree.  The chip measures it every 64 seconds to compensate
+// its oscillator.
+func (rtc *RTC) Temperature() (temp float64, err error) {
+	if rtc.chip != DS3231 {
+		err = ErrUnsupported
+		return
+	}
+	regs, err := rtc.dev.Read(DS3231_TEMP, 2)
+	if err != nil {
+		return
+	}
+	temp = float64(int8(regs[0])) + float64(regs[1]>>6)*0.25
+
+	return
+}
+
+// updateReg sets the bits of a register selected by mask to value.
+func (rtc *RTC) updateReg(reg, mask, value byte) (err error) {
+	b, err := rtc.dev.ReadReg(reg)
+	if err != nil {
+		return
+	}
+	return rtc.dev.WriteReg(reg, b&^mask|value&mask)
+}
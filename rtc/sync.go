@@ -0,0 +1,39 @@
+package rtc
+
+import (
+	"syscall"
+	"time"
+)
+
+// SyncSystemClock sets the system clock from the RTC, as hwclock --hctosys
+// does, and returns the time set.  Call it early at boot on a BeagleBone
+// which has no network to get the time from.  It needs CAP_SYS_TIME, and
+// fails with ErrNotSet if the RTC's time isn't valid.
+//
+// Alternatively, the kernel's rtc-ds1307 driver handles both chips; with it
+// bound, the kernel sets the clock itself at boot.
+func SyncSystemClock(rtc *RTC) (t time.Time, err error) {
+	valid, err := rtc.Valid()
+	if err != nil {
+		return
+	}
+	if !valid {
+		err = ErrNotSet
+		return
+	}
+	if t, err = rtc.Time(); err != nil {
+		return
+	}
+
+	tv := syscall.NsecToTimeval(t.UnixNano())
+	err = syscall.Settimeofday(&tv)
+
+	return
+}
+
+// SyncFromSystem sets the RTC from the system clock, as hwclock --systohc
+// does.  Call it once the system clock is known to be right, for instance
+// after NTP has synchronised it.
+func SyncFromSystem(rtc *RTC) error {
+	return rtc.SetTime(time.Now())
+}
@@ -0,0 +1,319 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package sched runs callbacks at a fixed rate for hardware loops, such as
+// polling sensors, refreshing displays and generating software PWM.  Ticks
+// are scheduled from the time the loop started rather than from the end of
+// the previous one, so timing doesn't drift, and a Policy decides what
+// happens when the callback overruns.  For tighter timing a loop can spin for
+// the last part of each wait, and run on its own thread with a real-time
+// SCHED_FIFO priority.
+package sched
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// A Policy decides what a Loop does about ticks missed because the callback
+// overran or the program wasn't scheduled in time.
+type Policy int
+
+// Policies for missed ticks.  Skip drops ticks which are wholly missed, so
+// the next tick is the latest one due, and later ticks stay on the original
+// schedule.  CatchUp runs every missed tick, one after another, until the
+// loop is back on schedule; it suits loops which count ticks, such as
+// integrators.  Reset starts a new schedule from the late tick, so ticks are
+// never missed but the loop runs slow after an overrun.
+const (
+	Skip Policy = iota
+	CatchUp
+	Reset
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Skip:
+		return "Skip"
+	case CatchUp:
+		return "CatchUp"
+	case Reset:
+		return "Reset"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// SCHED_FIFO is the real-time first in, first out scheduling policy.
+const SCHED_FIFO = 1
+
+// ErrStop may be returned by a Loop's callback to stop the loop without
+// reporting an error.
+var ErrStop = errors.New("Stop loop")
+
+// A Tick is passed to a Loop's callback.
+type Tick struct {
+	// N counts ticks on the schedule from 0, including missed ones.
+	N int64
+	// Time is when the tick was due.
+	Time time.Time
+	// Late is how long after Time the callback was called.
+	Late time.Duration
+	// Missed is the number of ticks skipped just before this one.
+	Missed int
+}
+
+// Options configures a Loop.  The zero value skips missed ticks, sleeps
+// until each tick and runs at normal priority.
+type Options struct {
+	Policy Policy
+	// Spin is how long before each tick the loop stops sleeping and spins,
+	// as the kernel may wake a sleeping thread some time late.  Spinning
+	// uses a whole CPU, so keep it short.
+	Spin time.Duration
+	// Priority, if not zero, runs the loop on its own thread at this
+	// SCHED_FIFO priority, from 1 to 99.  This needs root, or the
+	// CAP_SYS_NICE capability.  A real-time thread which never sleeps
+	// can starve the rest of the system, so use Spin sparingly with it.
+	Priority int
+}
+
+// Stats describes how well a Loop has kept to its schedule.
+type Stats struct {
+	// Ticks is the number of times the callback has been called.
+	Ticks int64
+	// Missed is the number of ticks skipped under the Skip policy.
+	Missed int64
+	// Overruns is the number of times the callback took longer than the
+	// period.
+	Overruns int64
+	// MaxLate and MeanLate are the greatest and mean lateness of calls.
+	MaxLate  time.Duration
+	MeanLate time.Duration
+}
+
+// A Loop calls a function at a fixed rate in the background.  If the
+// function returns an error, the loop stops; Done is closed and Err holds
+// the error.
+type Loop struct {
+	Err error
+
+	fn   func(Tick) error
+	opts Options
+
+	lock      sync.Mutex
+	period    time.Duration
+	stats     Stats
+	totalLate time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// New starts calling fn every period, the first time one period from now.
+func New(period time.Duration, opts Options, fn func(Tick) error) (l *Loop, err error) {
+	if period <= 0 {
+		err = fmt.Errorf("Invalid period: %s", period)
+		return
+	}
+	if opts.Priority < 0 || opts.Priority > 99 {
+		err = fmt.Errorf("Invalid priority: %d", opts.Priority)
+		return
+	}
+	l = &Loop{
+		fn:     fn,
+		opts:   opts,
+		period: period,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	started := make(chan error)
+	go l.run(started)
+	if err = <-started; err != nil {
+		l = nil
+	}
+	return
+}
+
+// Period returns the loop's period.
+func (l *Loop) Period() time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.period
+}
+
+// SetPeriod changes the loop's period, from the next tick on.
+func (l *Loop) SetPeriod(period time.Duration) (err error) {
+	if period <= 0 {
+		return fmt.Errorf("Invalid period: %s", period)
+	}
+	l.lock.Lock()
+	l.period = period
+	l.lock.Unlock()
+	return
+}
+
+// Stats returns the loop's timing statistics so far.
+func (l *Loop) Stats() (stats Stats) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	stats = l.stats
+	if stats.Ticks > 0 {
+		stats.MeanLate = l.totalLate / time.Duration(stats.Ticks)
+	}
+	return
+}
+
+// Done returns a channel which is closed when the loop stops.
+func (l *Loop) Done() <-chan struct{} {
+	return l.done
+}
+
+// Stop stops the loop, waiting for a call in progress to return, and
+// returns the error which stopped it already, if any.
+func (l *Loop) Stop() error {
+	l.once.Do(func() { close(l.stop) })
+	<-l.done
+	return l.Err
+}
+
+func (l *Loop) run(started chan<- error) {
+	defer close(l.done)
+
+	if l.opts.Priority > 0 {
+		// The thread is left locked, so that it exits with the goroutine
+		// rather than returning to the runtime with a raised priority
+		runtime.LockOSThread()
+		if err := SetPriority(l.opts.Priority); err != nil {
+			started <- err
+			return
+		}
+	}
+	close(started)
+
+	var n int64
+	next := time.Now().Add(l.Period())
+	for {
+		if !l.wait(next) {
+			return
+		}
+
+		now := time.Now()
+		late := now.Sub(next)
+		period := l.Period()
+		missed := 0
+		if late >= period {
+			switch l.opts.Policy {
+			case Skip:
+				missed = int(late / period)
+				next = next.Add(time.Duration(missed) * period)
+				n += int64(missed)
+				late = now.Sub(next)
+			case Reset:
+				next, late = now, 0
+			}
+		}
+
+		l.lock.Lock()
+		l.stats.Ticks++
+		l.stats.Missed += int64(missed)
+		l.totalLate += late
+		if late > l.stats.MaxLate {
+			l.stats.MaxLate = late
+		}
+		l.lock.Unlock()
+
+		err := l.fn(Tick{N: n, Time: next, Late: late, Missed: missed})
+		if err != nil {
+			if err != ErrStop {
+				l.Err = err
+			}
+			return
+		}
+
+		if time.Since(now) > period {
+			l.lock.Lock()
+			l.stats.Overruns++
+			l.lock.Unlock()
+		}
+		n++
+		next = next.Add(l.Period())
+	}
+}
+
+// wait waits until t, returning false if the loop is stopped first.
+func (l *Loop) wait(t time.Time) bool {
+	if d := time.Until(t) - l.opts.Spin; d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-l.stop:
+			timer.Stop()
+			return false
+		}
+	}
+	select {
+	case <-l.stop:
+		return false
+	default:
+	}
+	SleepUntil(t, l.opts.Spin)
+	return true
+}
+
+// SleepUntil sleeps until t, spinning for the last spin of the wait rather
+// than sleeping, for precise timing of short delays.
+func SleepUntil(t time.Time, spin time.Duration) {
+	if d := time.Until(t) - spin; d > 0 {
+		time.Sleep(d)
+	}
+	for time.Now().Before(t) {
+	}
+}
+
+// as defined in /usr/include/linux/sched/types.h
+type sched_param struct {
+	priority int32
+}
+
+// SetPriority gives the calling thread the SCHED_FIFO policy at the given
+// priority, from 1 to 99, or returns it to normal scheduling if priority is
+// zero.  The caller should call runtime.LockOSThread first, or the priority
+// may apply to whichever goroutines the runtime later runs on the thread.
+func SetPriority(priority int) (err error) {
+	policy := SCHED_FIFO
+	if priority == 0 {
+		policy = 0
+	}
+	param := sched_param{priority: int32(priority)}
+	_, _, errno := syscall.Syscall(syscall.SYS_SCHED_SETSCHEDULER, 0, uintptr(policy), uintptr(unsafe.Pointer(&param)))
+	if errno != 0 {
+		err = fmt.Errorf("Setting SCHED_FIFO priority %d: %w", priority, errno)
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"github.com/Ratfink/gopherbone/config"
+	"github.com/Ratfink/gopherbone/gpio"
+	"strings"
+)
+
+// Setup returns a Server serving everything in hw.  Input pins with an edge
+// configured are watched, so their changes are sent as events.
+func Setup(c *config.Config, hw *config.Hardware) (s *Server, err error) {
+	srv := New()
+	defer func() {
+		if err != nil {
+			srv.Close()
+		}
+	}()
+
+	for name, pc := range c.Pins {
+		pin, err := hw.Pin(name)
+		if err != nil {
+			return nil, err
+		}
+		if pc.Edge != "" && !strings.EqualFold(pc.Direction, "out") {
+			if err = srv.WatchPin(name, pin, gpio.Edge(strings.ToLower(pc.Edge))); err != nil {
+				return nil, err
+			}
+		} else {
+			srv.AddPin(name, pin)
+		}
+	}
+	for name := range c.PWM {
+		p, err := hw.PWM(name)
+		if err != nil {
+			return nil, err
+		}
+		srv.AddPWM(name, p)
+	}
+	for name := range c.ADC {
+		a, err := hw.Analog(name)
+		if err != nil {
+			return nil, err
+		}
+		srv.AddADC(name, a)
+	}
+	for name := range c.Displays {
+		d, err := hw.Display(name)
+		if err != nil {
+			return nil, err
+		}
+		srv.AddScreen(name, d)
+	}
+
+	return srv, nil
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"errors"
+	"github.com/Ratfink/gopherbone/config"
+	"testing"
+)
+
+func TestSetupErrors(t *testing.T) {
+	// Nothing is opened, so every name is missing from the Hardware
+	hw, err := (&config.Config{}).Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		c    config.Config
+	}{
+		{"pin", config.Config{Pins: map[string]config.PinConfig{"led": {Pin: "P8_10"}}}},
+		{"PWM", config.Config{PWM: map[string]config.PWMConfig{"fan": {}}}},
+		{"analog input", config.Config{ADC: map[string]config.ADCConfig{"temp": {}}}},
+		{"display", config.Config{Displays: map[string]config.DisplayConfig{"oled": {}}}},
+	}
+	for _, test := range tests {
+		s, err := Setup(&test.c, hw)
+		if !errors.Is(err, config.ErrNotFound) {
+			t.Errorf("%s: Setup = %v, want %v", test.name, err, config.ErrNotFound)
+		}
+		if s != nil {
+			t.Errorf("%s: Setup returned a Server as well as an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package server exposes pins, PWM channels, analog inputs and displays over
+// HTTP, for remote control and debugging.  A Server is an http.Handler with
+// these routes:
+//
+//	GET  /api/pins             every pin's value, as a JSON object
+//	GET  /api/pins/NAME        {"value": 1}
+//	PUT  /api/pins/NAME        set an output from {"value": 1}, or just 1
+//	GET  /api/pwm/NAME         period, duty cycle and whether enabled
+//	PUT  /api/pwm/NAME         set any of {"frequency", "duty", "enabled"}
+//	GET  /api/adc              every analog input's voltage
+//	GET  /api/adc/NAME         {"volts": 1.2}
+//	GET  /api/displays/NAME    the display's framebuffer, as a PNG
+//	GET  /api/events           a WebSocket stream of events, as JSON
+//	GET  /view/NAME            a page showing the display live
+//
+// Events are sent when a watched pin changes and when a pin or PWM channel
+// is set through the API.
+//
+// Requests from web pages on other origins are refused, so that a page
+// visited on the same network can't set pins through the visitor's browser;
+// AllowedOrigins lists any other sites which may.  There is no
+// authentication unless Authorize is set, for example to BasicAuth, so
+// without it only serve on a trusted network.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone/adc"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/pwm"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Screen is a display whose framebuffer can be viewed, such as an
+// *ssd1306.SSD1306.
+type Screen interface {
+	Image() *image.Gray
+}
+
+// An Event is sent to WebSocket clients.  Kind is "edge" for a watched pin
+// changing, or "pin" or "pwm" for something set through the API.
+type Event struct {
+	Kind  string      `json:"kind"`
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+	Time  time.Time   `json:"time"`
+}
+
+// A Server serves the things added to it.  Its exported fields must be set
+// before it starts serving.
+type Server struct {
+	// Authorize, if set, is called before every request is served.  If it
+	// returns false, the request is refused; Authorize must have written
+	// the response.
+	Authorize func(w http.ResponseWriter, r *http.Request) bool
+	// AllowedOrigins lists the origins, such as "https://dash.example",
+	// whose pages may use the API besides the server's own.
+	AllowedOrigins []string
+
+	lock     sync.Mutex
+	pins     map[string]gpio.DigitalPin
+	pwms     map[string]*pwm.PWM
+	adcs     map[string]adc.AnalogPin
+	screens  map[string]Screen
+	watchers []*gpio.Watcher
+	clients  map[chan []byte]struct{}
+}
+
+// New returns a Server with nothing added.
+func New() *Server {
+	return &Server{
+		pins:    make(map[string]gpio.DigitalPin),
+		pwms:    make(map[string]*pwm.PWM),
+		adcs:    make(map[string]adc.AnalogPin),
+		screens: make(map[string]Screen),
+		clients: make(map[chan []byte]struct{}),
+	}
+}
+
+// AddPin serves a pin.
+func (s *Server) AddPin(name string, pin gpio.DigitalPin) {
+	s.lock.Lock()
+	s.pins[name] = pin
+	s.lock.Unlock()
+}
+
+// WatchPin serves an input pin, and sends an event each time it changes on
+// edge.
+func (s *Server) WatchPin(name string, pin gpio.DigitalPin, edge gpio.Edge) (err error) {
+	w, err := pin.Watch(edge)
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	s.pins[name] = pin
+	s.watchers = append(s.watchers, w)
+	s.lock.Unlock()
+
+	go func() {
+		for v := range w.C {
+			s.Broadcast(Event{Kind: "edge", Name: name, Value: v})
+		}
+	}()
+	return
+}
+
+// AddPWM serves a PWM channel.
+func (s *Server) AddPWM(name string, p *pwm.PWM) {
+	s.lock.Lock()
+	s.pwms[name] = p
+	s.lock.Unlock()
+}
+
+// AddADC serves an analog input.
+func (s *Server) AddADC(name string, a adc.AnalogPin) {
+	s.lock.Lock()
+	s.adcs[name] = a
+	s.lock.Unlock()
+}
+
+// AddScreen serves a display's framebuffer.
+func (s *Server) AddScreen(name string, d Screen) {
+	s.lock.Lock()
+	s.screens[name] = d
+	s.lock.Unlock()
+}
+
+// Broadcast sends an event to every WebSocket client.  If Time is zero, it
+// is set to now.  Clients too slow to keep up miss events rather than
+// holding up the others.
+func (s *Server) Broadcast(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	msg, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for c := range s.clients {
+		select {
+		case c <- msg:
+		default:
+		}
+	}
+}
+
+// Close stops watching pins and disconnects WebSocket clients.  What was
+// added is left open.
+func (s *Server) Close() (err error) {
+	s.lock.Lock()
+	watchers := s.watchers
+	s.watchers = nil
+	for c := range s.clients {
+		close(c)
+		delete(s.clients, c)
+	}
+	s.lock.Unlock()
+
+	for _, w := range watchers {
+		w.Close()
+	}
+	return
+}
+
+// BasicAuth returns an Authorize function which requires HTTP basic
+// authentication with the given user name and password.
+func BasicAuth(user, password string) func(http.ResponseWriter, *http.Request) bool {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		u, p, ok := r.BasicAuth()
+		if ok && subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1 {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="gopherbone"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+}
+
+// allowOrigin reports whether a request may be served: those without an
+// Origin header come from programs or same-origin page loads, and the rest
+// must come from the server's own pages or an allowed origin.
+func (s *Server) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range s.AllowedOrigins {
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP serves the API and the display view.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.allowOrigin(r) {
+		http.Error(w, "Cross-origin request refused", http.StatusForbidden)
+		return
+	}
+	if s.Authorize != nil && !s.Authorize(w, r) {
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.SplitN(path, "/", 3)
+	switch {
+	case path == "api/events":
+		s.serveEvents(w, r)
+	case len(parts) == 2 && parts[0] == "view":
+		s.serveView(w, r, parts[1])
+	case len(parts) >= 2 && parts[0] == "api":
+		name := ""
+		if len(parts) == 3 {
+			name = parts[2]
+		}
+		switch parts[1] {
+		case "pins":
+			s.servePin(w, r, name)
+		case "pwm":
+			s.servePWM(w, r, name)
+		case "adc":
+			s.serveADC(w, r, name)
+		case "displays":
+			s.serveScreen(w, r, strings.TrimSuffix(name, ".png"))
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func httpError(w http.ResponseWriter, code int, err error) {
+	http.Error(w, err.Error(), code)
+}
+
+func notFound(w http.ResponseWriter, kind, name string) {
+	http.Error(w, fmt.Sprintf("No %s named %q", kind, name), http.StatusNotFound)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func (s *Server) servePin(w http.ResponseWriter, r *http.Request, name string) {
+	s.lock.Lock()
+	pin := s.pins[name]
+	pins := make(map[string]gpio.DigitalPin)
+	if name == "" {
+		for n, p := range s.pins {
+			pins[n] = p
+		}
+	}
+	s.lock.Unlock()
+
+	if name == "" {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, "GET")
+			return
+		}
+		values := make(map[string]int, len(pins))
+		for n, p := range pins {
+			v, err := p.Value()
+			if err != nil {
+				httpError(w, http.StatusInternalServerError, fmt.Errorf("%s: %w", n, err))
+				return
+			}
+			values[n] = v
+		}
+		writeJSON(w, values)
+		return
+	}
+	if pin == nil {
+		notFound(w, "pin", name)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			Value *int `json:"value"`
+		}
+		if err := decode(r.Body, &req); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.Value == nil {
+			httpError(w, http.StatusBadRequest, errors.New("No value given"))
+			return
+		}
+		if err := pin.SetValue(*req.Value); err != nil {
+			httpError(w, http.StatusConflict, err)
+			return
+		}
+		s.Broadcast(Event{Kind: "pin", Name: name, Value: *req.Value})
+	default:
+		methodNotAllowed(w, "GET, PUT")
+		return
+	}
+
+	v, err := pin.Value()
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, map[string]int{"value": v})
+}
+
+// decode reads a JSON object into v, or a bare number into its only field,
+// so that "curl -d 1" works as well as "curl -d '{"value": 1}'".
+func decode(r io.Reader, v interface{}) (err error) {
+	body, err := io.ReadAll(io.LimitReader(r, 4096))
+	if err != nil {
+		return
+	}
+	body = []byte(strings.TrimSpace(string(body)))
+	if len(body) > 0 && body[0] != '{' {
+		if _, err = strconv.ParseFloat(string(body), 64); err != nil {
+			return fmt.Errorf("Invalid value: %q", body)
+		}
+		body = []byte(`{"value":` + string(body) + `}`)
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (s *Server) servePWM(w http.ResponseWriter, r *http.Request, name string) {
+	s.lock.Lock()
+	p := s.pwms[name]
+	s.lock.Unlock()
+	if p == nil {
+		notFound(w, "PWM channel", name)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			Frequency *float64 `json:"frequency"`
+			Duty      *float64 `json:"duty"`
+			Value     *float64 `json:"value"`
+			Enabled   *bool    `json:"enabled"`
+		}
+		if err := decode(r.Body, &req); err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.Duty == nil {
+			req.Duty = req.Value
+		}
+		var err error
+		if req.Frequency != nil {
+			err = p.SetFrequency(*req.Frequency)
+		}
+		if err == nil && req.Duty != nil {
+			err = p.SetDuty(*req.Duty)
+		}
+		if err == nil && req.Enabled != nil {
+			if *req.Enabled {
+				err = p.Enable()
+			} else {
+				err = p.Disable()
+			}
+		}
+		if err != nil {
+			httpError(w, http.StatusConflict, err)
+			return
+		}
+	default:
+		methodNotAllowed(w, "GET, PUT")
+		return
+	}
+
+	state, err := pwmState(p)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.Broadcast(Event{Kind: "pwm", Name: name, Value: state})
+	}
+	writeJSON(w, state)
+}
+
+type pwmStatus struct {
+	Period    float64 `json:"period"`
+	Frequency float64 `json:"frequency"`
+	Duty      float64 `json:"duty"`
+	Enabled   bool    `json:"enabled"`
+}
+
+func pwmState(p *pwm.PWM) (st pwmStatus, err error) {
+	period, err := p.Period()
+	if err != nil {
+		return
+	}
+	duty, err := p.DutyCycle()
+	if err != nil {
+		return
+	}
+	if st.Enabled, err = p.Enabled(); err != nil {
+		return
+	}
+	st.Period = period.Seconds()
+	if period > 0 {
+		st.Frequency = 1 / period.Seconds()
+		st.Duty = float64(duty) / float64(period)
+	}
+	return
+}
+
+func (s *Server) serveADC(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	s.lock.Lock()
+	adcs := make(map[string]adc.AnalogPin)
+	for n, a := range s.adcs {
+		if name == "" || n == name {
+			adcs[n] = a
+		}
+	}
+	s.lock.Unlock()
+	if name != "" && len(adcs) == 0 {
+		notFound(w, "analog input", name)
+		return
+	}
+
+	volts := make(map[string]float64, len(adcs))
+	for n, a := range adcs {
+		v, err := a.Voltage()
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, fmt.Errorf("%s: %w", n, err))
+			return
+		}
+		volts[n] = v
+	}
+	if name != "" {
+		writeJSON(w, map[string]float64{"volts": volts[name]})
+		return
+	}
+	writeJSON(w, volts)
+}
+
+func (s *Server) serveScreen(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+	s.lock.Lock()
+	d := s.screens[name]
+	s.lock.Unlock()
+	if d == nil {
+		notFound(w, "display", name)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	png.Encode(w, d.Image())
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"github.com/Ratfink/gopherbone/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrigin(t *testing.T) {
+	s := New()
+	s.AddPin("led", mock.NewPin())
+	s.AllowedOrigins = []string{"https://dash.example"}
+
+	tests := []struct {
+		origin string
+		code   int
+	}{
+		{"", http.StatusOK},
+		{"http://bone.local:8080", http.StatusOK},
+		{"https://dash.example", http.StatusOK},
+		{"https://evil.example", http.StatusForbidden},
+		{"http://bone.local", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		for _, path := range []string{"/api/pins/led", "/api/events"} {
+			r := httptest.NewRequest(http.MethodGet, "http://bone.local:8080"+path, nil)
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			// A plain GET of the events gets as far as asking for an
+			// upgrade
+			want := test.code
+			if path == "/api/events" && want == http.StatusOK {
+				want = http.StatusUpgradeRequired
+			}
+			if w.Code != want {
+				t.Errorf("%s from %q: Status %d, want %d", path, test.origin, w.Code, want)
+			}
+		}
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	s := New()
+	s.AddPin("led", mock.NewPin())
+	s.Authorize = BasicAuth("admin", "hunter2")
+
+	tests := []struct {
+		user, password string
+		code           int
+	}{
+		{"admin", "hunter2", http.StatusOK},
+		{"admin", "hunter3", http.StatusUnauthorized},
+		{"root", "hunter2", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/pins/led", nil)
+		if test.user != "" {
+			r.SetBasicAuth(test.user, test.password)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%q, %q: Status %d, want %d", test.user, test.password, w.Code, test.code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%q, %q: No WWW-Authenticate header", test.user, test.password)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// ViewInterval is how often, in milliseconds, the display view reloads the
+// framebuffer.
+var ViewInterval = 200
+
+var viewTemplate = template.Must(template.New("view").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>{{.Name}}</title>
+<style>
+body { background: #222; color: #ccc; font-family: monospace; }
+img { image-rendering: pixelated; width: {{.Width}}px; border: 4px solid #000; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<img id="fb" src="/api/displays/{{.Name}}.png">
+<pre id="events"></pre>
+<script>
+const fb = document.getElementById("fb");
+const src = fb.src;
+let loading = false;
+fb.onload = fb.onerror = () => { loading = false; };
+setInterval(() => {
+	if (!loading) {
+		loading = true;
+		fb.src = src + "?t=" + Date.now();
+	}
+}, {{.Interval}});
+
+const events = document.getElementById("events");
+const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/api/events");
+ws.onmessage = (m) => {
+	const lines = (m.data + "\n" + events.textContent).split("\n");
+	events.textContent = lines.slice(0, 20).join("\n");
+};
+</script>
+</body>
+</html>
+`))
+
+// serveView serves a page showing a display's framebuffer, scaled up, and
+// the event stream beneath it.
+func (s *Server) serveView(w http.ResponseWriter, r *http.Request, name string) {
+	s.lock.Lock()
+	d := s.screens[name]
+	s.lock.Unlock()
+	if d == nil {
+		notFound(w, "display", name)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	viewTemplate.Execute(w, struct {
+		Name     string
+		Width    int
+		Interval int
+	}{name, d.Image().Bounds().Dx() * 4, ViewInterval})
+}
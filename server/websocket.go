@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+// wsGUID is appended to the client's key to make the accept header, as
+// RFC 6455 says.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// PingInterval is how often WebSocket clients are pinged, so that dead
+// connections are noticed.
+var PingInterval = 30 * time.Second
+
+// A wsConn is the server end of a WebSocket.  Only sending is supported;
+// messages from the client are read and discarded.
+type wsConn struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	writeLock sync.Mutex
+}
+
+func headerHas(h http.Header, key, token string) bool {
+	for _, v := range h.Values(key) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgrade performs the WebSocket handshake.
+func upgrade(w http.ResponseWriter, r *http.Request) (ws *wsConn, err error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" ||
+		!headerHas(r.Header, "Connection", "upgrade") ||
+		!headerHas(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("Not a WebSocket request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusBadRequest)
+		return nil, errors.New("Unsupported WebSocket version")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("Connection cannot be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	_, err = io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+accept+"\r\n\r\n")
+	if err != nil {
+		conn.Close()
+		return
+	}
+	ws = &wsConn{conn: conn, r: rw.Reader}
+	return
+}
+
+// write sends one unfragmented frame.
+func (ws *wsConn) write(opcode byte, payload []byte) (err error) {
+	ws.writeLock.Lock()
+	defer ws.writeLock.Unlock()
+
+	hdr := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, byte(n))
+	case n <= 0xffff:
+		hdr = append(hdr, 126, byte(n>>8), byte(n))
+	default:
+		hdr = append(hdr, 127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	ws.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err = ws.conn.Write(append(hdr, payload...))
+	return
+}
+
+// readLoop reads frames from the client, answering pings, until the client
+// closes the connection or it fails.
+func (ws *wsConn) readLoop() {
+	var hdr [2]byte
+	for {
+		if _, err := io.ReadFull(ws.r, hdr[:]); err != nil {
+			return
+		}
+		opcode := hdr[0] & 0x0f
+		n := uint64(hdr[1] & 0x7f)
+		switch n {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+				return
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+				return
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+		var mask [4]byte
+		if hdr[1]&0x80 != 0 {
+			if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+				return
+			}
+		}
+		if n > 1<<16 {
+			return
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(ws.r, payload); err != nil {
+			return
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch opcode {
+		case wsClose:
+			ws.write(wsClose, payload)
+			return
+		case wsPing:
+			ws.write(wsPong, payload)
+		}
+	}
+}
+
+// serveEvents streams events to a WebSocket client until it goes away or
+// the Server closes.
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.conn.Close()
+
+	c := make(chan []byte, 64)
+	s.lock.Lock()
+	s.clients[c] = struct{}{}
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.clients, c)
+		s.lock.Unlock()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		ws.readLoop()
+		close(done)
+	}()
+	ping := time.NewTicker(PingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case msg, ok := <-c:
+			if !ok {
+				ws.write(wsClose, []byte{0x03, 0xe9}) // going away
+				return
+			}
+			if ws.write(wsText, msg) != nil {
+				return
+			}
+		case <-ping.C:
+			if ws.write(wsPing, nil) != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
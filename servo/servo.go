@@ -0,0 +1,119 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package servo positions hobby servos driven by any pwm.Channel, whether one
+// of the BeagleBone's own PWM outputs or a channel of a PCA9685.
+package servo
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/pwm"
+	"time"
+)
+
+// Frequency is the PWM frequency servos expect, in hertz.
+const Frequency = 50
+
+// Default pulse widths for the ends of a servo's travel.  Many servos go
+// further, with pulses from 500us to 2500us; use SetRange to allow that.
+const (
+	DefaultMinPulse = 1000 * time.Microsecond
+	DefaultMaxPulse = 2000 * time.Microsecond
+)
+
+// A Servo is a hobby servo on a PWM channel.
+type Servo struct {
+	ch       pwm.Channel
+	minPulse time.Duration
+	maxPulse time.Duration
+	maxAngle float64
+}
+
+// New sets the channel to the servo frequency and returns a Servo on it,
+// with a range of 0 to 180 degrees.  The servo isn't driven until its
+// position is first set.
+func New(ch pwm.Channel) (servo *Servo, err error) {
+	servo = &Servo{
+		ch:       ch,
+		minPulse: DefaultMinPulse,
+		maxPulse: DefaultMaxPulse,
+		maxAngle: 180,
+	}
+	if err = ch.Disable(); err != nil {
+		return nil, err
+	}
+	if err = ch.SetDutyCycle(0); err != nil {
+		return nil, err
+	}
+	if err = ch.SetFrequency(Frequency); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// SetRange sets the pulse widths at either end of the servo's travel, and the
+// angle the travel covers.
+func (servo *Servo) SetRange(minPulse, maxPulse time.Duration, maxAngle float64) (err error) {
+	if minPulse <= 0 || maxPulse <= minPulse || maxAngle <= 0 {
+		err = fmt.Errorf("Invalid range: %v-%v, %g degrees", minPulse, maxPulse, maxAngle)
+		return
+	}
+	servo.minPulse, servo.maxPulse, servo.maxAngle = minPulse, maxPulse, maxAngle
+
+	return
+}
+
+// SetPulse drives the servo with the given pulse width.
+func (servo *Servo) SetPulse(pulse time.Duration) (err error) {
+	if err = servo.ch.SetDutyCycle(pulse); err != nil {
+		return
+	}
+	return servo.ch.Enable()
+}
+
+// SetAngle moves the servo to an angle between 0 and its maximum angle.
+func (servo *Servo) SetAngle(degrees float64) (err error) {
+	if degrees < 0 || degrees > servo.maxAngle {
+		err = fmt.Errorf("Invalid angle: %g", degrees)
+		return
+	}
+	span := float64(servo.maxPulse - servo.minPulse)
+
+	return servo.SetPulse(servo.minPulse + time.Duration(span*degrees/servo.maxAngle))
+}
+
+// SetPosition moves the servo to a position between -1 and 1, with 0 being
+// the centre of its travel.  This is convenient for continuous rotation
+// servos, where it sets the speed instead.
+func (servo *Servo) SetPosition(position float64) (err error) {
+	if position < -1 || position > 1 {
+		err = fmt.Errorf("Invalid position: %g", position)
+		return
+	}
+	return servo.SetAngle((position + 1) / 2 * servo.maxAngle)
+}
+
+// Release stops driving the servo, letting it be moved by hand.
+func (servo *Servo) Release() error {
+	return servo.ch.Disable()
+}
@@ -0,0 +1,156 @@
+package sevenseg
+
+import (
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/sched"
+	"sync"
+	"time"
+)
+
+// DigitPeriod is how long each digit of a directly driven display is lit in
+// turn.  With four digits, the default refreshes the display at 125Hz.
+var DigitPeriod = 2 * time.Millisecond
+
+type directDriver struct {
+	lock        sync.Mutex
+	segments    *gpio.Bus
+	width       int
+	digits      []*gpio.GPIO
+	commonAnode bool
+	segs        []byte
+	brightness  float64
+
+	loop  *sched.Loop
+	digit int
+}
+
+// NewGPIO returns a display multiplexed directly from GPIOs.  segments gives
+// the pins driving segments A to G and the decimal point, in that order; the
+// decimal point pin may be -1 if it isn't connected.  digits gives the pins
+// driving each digit's common pin, from the left.  For common cathode
+// displays, a segment is lit by driving it high and its digit low; for common
+// anode displays, the other way around.  If the digits are switched by
+// transistors which invert the signal, choose the opposite of what the
+// display itself is.
+//
+// Digits are lit one at a time by a background goroutine, so the display
+// flickers if the system is heavily loaded.
+func NewGPIO(segments [8]int, digits []int, commonAnode bool) (disp *Display, err error) {
+	d := &directDriver{
+		width:       8,
+		commonAnode: commonAnode,
+		segs:        make([]byte, len(digits)),
+		brightness:  1,
+	}
+	pins := segments[:]
+	if segments[7] < 0 {
+		pins = segments[:7]
+		d.width = 7
+	}
+
+	defer func() {
+		if err != nil {
+			d.unexport()
+		}
+	}()
+	if d.segments, err = gpio.ExportBus(gpio.LSBFirst, pins...); err != nil {
+		return
+	}
+	if err = d.segments.SetDirection(gpio.Out); err != nil {
+		return
+	}
+	for _, pin := range digits {
+		var g *gpio.GPIO
+		if g, err = gpio.Export(pin); err != nil {
+			return
+		}
+		d.digits = append(d.digits, g)
+		if err = g.SetDirection(gpio.Out); err != nil {
+			return
+		}
+		if err = d.enable(g, false); err != nil {
+			return
+		}
+	}
+
+	if d.loop, err = sched.New(DigitPeriod, sched.Options{}, d.tick); err != nil {
+		return
+	}
+	disp = newDisplay(len(digits), d)
+
+	return
+}
+
+// enable turns a digit's common pin on or off.
+func (d *directDriver) enable(g *gpio.GPIO, on bool) error {
+	if on != d.commonAnode {
+		return g.SetLow()
+	}
+	return g.SetHigh()
+}
+
+// tick lights the next digit for its share of the period.
+func (d *directDriver) tick(t sched.Tick) error {
+	if len(d.digits) == 0 {
+		return nil
+	}
+	i, g := d.digit, d.digits[d.digit]
+	d.digit = (d.digit + 1) % len(d.digits)
+
+	d.lock.Lock()
+	segs, brightness := d.segs[i], d.brightness
+	d.lock.Unlock()
+
+	on := time.Duration(brightness * float64(DigitPeriod))
+	if on <= 0 || segs == 0 {
+		return nil
+	}
+	if d.commonAnode {
+		segs = ^segs
+	}
+	d.segments.WriteN(uint64(segs), d.width)
+	d.enable(g, true)
+	sched.SleepUntil(t.Time.Add(t.Late+on), 0)
+	d.enable(g, false)
+
+	return nil
+}
+
+func (d *directDriver) show(segs []byte) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	copy(d.segs, segs)
+
+	return nil
+}
+
+func (d *directDriver) setBrightness(brightness float64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.brightness = brightness
+
+	return nil
+}
+
+func (d *directDriver) unexport() (err error) {
+	for _, g := range d.digits {
+		d.enable(g, false)
+		if e := g.Unexport(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if d.segments != nil {
+		if e := d.segments.Unexport(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+// close stops multiplexing, blanks the display and unexports its pins.
+func (d *directDriver) close() error {
+	d.loop.Stop()
+
+	return d.unexport()
+}
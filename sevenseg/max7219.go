@@ -0,0 +1,111 @@
+package sevenseg
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/spi"
+)
+
+// MAX7219 registers
+const (
+	MAX7219_DIGIT0       = 0x01 // DIGIT1-7 follow
+	MAX7219_DECODE_MODE  = 0x09
+	MAX7219_INTENSITY    = 0x0a
+	MAX7219_SCAN_LIMIT   = 0x0b
+	MAX7219_SHUTDOWN     = 0x0c
+	MAX7219_DISPLAY_TEST = 0x0f
+)
+
+type max7219Driver struct {
+	spi    spi.Conn
+	digits int
+}
+
+// NewMAX7219 returns a display of up to 8 digits driven by a MAX7219 on the
+// given spidev bus and chip select.  The MAX7219 numbers its digits from the
+// right, as most modules are wired, so DIG0 is the rightmost digit.
+func NewMAX7219(bus, cs, digits int) (disp *Display, err error) {
+	if digits < 1 || digits > 8 {
+		err = fmt.Errorf("Invalid number of digits: %d", digits)
+		return
+	}
+
+	s, err := spi.Open(bus, cs)
+	if err != nil {
+		return
+	}
+	if disp, err = NewMAX7219Conn(s, digits); err != nil {
+		s.Close()
+	}
+
+	return
+}
+
+// NewMAX7219Conn is like NewMAX7219, but uses an already open SPI connection,
+// such as a spi.Soft when the hardware SPI pins are taken.  The connection is
+// closed along with the display.
+func NewMAX7219Conn(s spi.Conn, digits int) (disp *Display, err error) {
+	if digits < 1 || digits > 8 {
+		err = fmt.Errorf("Invalid number of digits: %d", digits)
+		return
+	}
+	d := &max7219Driver{spi: s, digits: digits}
+
+	for _, rv := range [][2]byte{
+		{MAX7219_DISPLAY_TEST, 0},
+		{MAX7219_DECODE_MODE, 0},
+		{MAX7219_SCAN_LIMIT, byte(digits - 1)},
+		{MAX7219_INTENSITY, 0x0f},
+	} {
+		if err = d.write(rv[0], rv[1]); err != nil {
+			return
+		}
+	}
+	if err = d.show(make([]byte, digits)); err == nil {
+		err = d.write(MAX7219_SHUTDOWN, 1)
+	}
+	if err != nil {
+		return
+	}
+	disp = newDisplay(digits, d)
+
+	return
+}
+
+func (d *max7219Driver) write(reg, value byte) error {
+	return d.spi.Write([]byte{reg, value})
+}
+
+// show writes each digit, converting from this package's segment order to the
+// MAX7219's, which has the decimal point in bit 7 and then A to G from bit 6
+// down to bit 0.
+func (d *max7219Driver) show(segs []byte) (err error) {
+	for i, s := range segs {
+		var v byte = s & SEG_DP
+		for b := uint(0); b < 7; b++ {
+			if s&(1<<b) != 0 {
+				v |= 0x40 >> b
+			}
+		}
+		if err = d.write(byte(MAX7219_DIGIT0+d.digits-1-i), v); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// setBrightness sets the intensity, shutting the chip down for zero since its
+// lowest intensity is not off.
+func (d *max7219Driver) setBrightness(brightness float64) (err error) {
+	if brightness == 0 {
+		return d.write(MAX7219_SHUTDOWN, 0)
+	}
+	if err = d.write(MAX7219_INTENSITY, byte(brightness*15+0.5)); err != nil {
+		return
+	}
+	return d.write(MAX7219_SHUTDOWN, 1)
+}
+
+func (d *max7219Driver) close() error {
+	return d.spi.Close()
+}
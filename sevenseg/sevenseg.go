@@ -0,0 +1,175 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* Seven-segment displays can be driven three ways by this package: directly
+ * from GPIOs, multiplexing the digits in a background goroutine (NewGPIO); by
+ * a TM1637 over its two-wire interface (NewTM1637); or by a MAX7219 over SPI
+ * (NewMAX7219).  All three present the same Display, with digits numbered
+ * from the left.
+ *
+ * Segments are encoded one per bit, A in bit 0 through G in bit 6, with the
+ * decimal point in bit 7:
+ *
+ *	 -A-
+ *	F   B
+ *	 -G-
+ *	E   C
+ *	 -D-  .DP
+ */
+package sevenseg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Segment bits
+const (
+	SEG_A  = 0x01
+	SEG_B  = 0x02
+	SEG_C  = 0x04
+	SEG_D  = 0x08
+	SEG_E  = 0x10
+	SEG_F  = 0x20
+	SEG_G  = 0x40
+	SEG_DP = 0x80
+)
+
+// font gives the segments for each character SetText can show.  Letters are
+// shown in whichever case is more legible.
+var font = map[rune]byte{
+	' ': 0x00, '-': 0x40, '_': 0x08, '=': 0x48, '"': 0x22, '\'': 0x02,
+	'0': 0x3f, '1': 0x06, '2': 0x5b, '3': 0x4f, '4': 0x66,
+	'5': 0x6d, '6': 0x7d, '7': 0x07, '8': 0x7f, '9': 0x6f,
+	'A': 0x77, 'B': 0x7c, 'C': 0x39, 'D': 0x5e, 'E': 0x79, 'F': 0x71,
+	'G': 0x3d, 'H': 0x76, 'I': 0x30, 'J': 0x1e, 'L': 0x38, 'N': 0x54,
+	'O': 0x5c, 'P': 0x73, 'Q': 0x67, 'R': 0x50, 'S': 0x6d, 'T': 0x78,
+	'U': 0x3e, 'Y': 0x6e,
+}
+
+// A driver sends segment data to the hardware.
+type driver interface {
+	show(segs []byte) error
+	setBrightness(brightness float64) error
+	close() error
+}
+
+// A Display is a row of seven-segment digits.
+type Display struct {
+	lock   sync.Mutex
+	segs   []byte
+	driver driver
+}
+
+func newDisplay(digits int, d driver) *Display {
+	return &Display{segs: make([]byte, digits), driver: d}
+}
+
+// Digits returns the number of digits in the display.
+func (disp *Display) Digits() int {
+	return len(disp.segs)
+}
+
+// SetSegments shows raw segment patterns, one byte per digit from the left.
+// Digits beyond the end of segs are blanked.
+func (disp *Display) SetSegments(segs []byte) (err error) {
+	if len(segs) > len(disp.segs) {
+		err = fmt.Errorf("Too many digits: %d", len(segs))
+		return
+	}
+
+	disp.lock.Lock()
+	defer disp.lock.Unlock()
+	for i := range disp.segs {
+		disp.segs[i] = 0
+	}
+	copy(disp.segs, segs)
+
+	return disp.driver.show(disp.segs)
+}
+
+// SetText shows a string, left aligned.  A '.' lights the decimal point of
+// the digit before it rather than taking a digit of its own.  Letters are not
+// case sensitive, and those which can't be shown legibly on seven segments,
+// such as K, M, W and X, are rejected.
+func (disp *Display) SetText(text string) (err error) {
+	var segs []byte
+	for _, r := range strings.ToUpper(text) {
+		if r == '.' {
+			if len(segs) > 0 && segs[len(segs)-1]&SEG_DP == 0 {
+				segs[len(segs)-1] |= SEG_DP
+			} else {
+				segs = append(segs, SEG_DP)
+			}
+			continue
+		}
+		s, ok := font[r]
+		if !ok {
+			err = fmt.Errorf("Invalid character: %q", r)
+			return
+		}
+		segs = append(segs, s)
+	}
+
+	return disp.SetSegments(segs)
+}
+
+// SetNumber shows an integer, right aligned.
+func (disp *Display) SetNumber(n int) (err error) {
+	text := strconv.Itoa(n)
+	if len(text) > len(disp.segs) {
+		err = fmt.Errorf("Number does not fit in %d digits: %d", len(disp.segs), n)
+		return
+	}
+
+	return disp.SetText(strings.Repeat(" ", len(disp.segs)-len(text)) + text)
+}
+
+// Clear blanks every digit.
+func (disp *Display) Clear() error {
+	return disp.SetSegments(nil)
+}
+
+// SetBrightness sets the brightness of the display, from 0 (off) to 1.  The
+// TM1637 and MAX7219 have 8 and 16 brightness levels respectively, so the
+// brightness is rounded to the nearest one.
+func (disp *Display) SetBrightness(brightness float64) (err error) {
+	if brightness < 0 || brightness > 1 {
+		err = fmt.Errorf("Invalid brightness: %g", brightness)
+		return
+	}
+
+	disp.lock.Lock()
+	defer disp.lock.Unlock()
+
+	return disp.driver.setBrightness(brightness)
+}
+
+// Close releases the hardware driving the display.
+func (disp *Display) Close() error {
+	disp.lock.Lock()
+	defer disp.lock.Unlock()
+
+	return disp.driver.close()
+}
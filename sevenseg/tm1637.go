@@ -0,0 +1,179 @@
+package sevenseg
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+)
+
+// TM1637 commands
+const (
+	TM1637_DATA    = 0x40 // write display data, auto-incrementing the address
+	TM1637_ADDRESS = 0xc0 // set the address of the first digit
+	TM1637_DISPLAY = 0x80 // display control; bit 3 is on, bits 0-2 brightness
+	TM1637_ON      = 0x08
+)
+
+// The TM1637's two-wire interface looks like I2C but isn't: there is no
+// device address, and bytes are sent least significant bit first.  Both lines
+// are open drain with pull-ups on the module, so a line is driven low by
+// making it an output (which sysfs initialises low) and released high by
+// making it an input.  Sysfs is slow enough that the chip's timing
+// requirements are met without any extra delays.
+type tm1637Driver struct {
+	clk, dio   *gpio.GPIO
+	segs       []byte
+	brightness byte
+	on         bool
+}
+
+// NewTM1637 returns a display of the given number of digits driven by a
+// TM1637 whose CLK and DIO lines are connected to the given GPIO pins.
+func NewTM1637(clkPin, dioPin, digits int) (disp *Display, err error) {
+	if digits < 1 || digits > 6 {
+		err = fmt.Errorf("Invalid number of digits: %d", digits)
+		return
+	}
+
+	d := &tm1637Driver{brightness: 7, on: true, segs: make([]byte, digits)}
+	if d.clk, err = gpio.Export(clkPin); err != nil {
+		return
+	}
+	if d.dio, err = gpio.Export(dioPin); err == nil {
+		err = d.show(d.segs)
+	}
+	if err != nil {
+		d.close()
+		return
+	}
+	disp = newDisplay(digits, d)
+
+	return
+}
+
+func (d *tm1637Driver) high(pin *gpio.GPIO) error {
+	return pin.SetDirection(gpio.In)
+}
+
+func (d *tm1637Driver) low(pin *gpio.GPIO) error {
+	return pin.SetDirection(gpio.Out)
+}
+
+func (d *tm1637Driver) start() (err error) {
+	if err = d.high(d.clk); err != nil {
+		return
+	}
+	if err = d.high(d.dio); err != nil {
+		return
+	}
+	return d.low(d.dio)
+}
+
+func (d *tm1637Driver) stop() (err error) {
+	if err = d.low(d.clk); err != nil {
+		return
+	}
+	if err = d.low(d.dio); err != nil {
+		return
+	}
+	if err = d.high(d.clk); err != nil {
+		return
+	}
+	return d.high(d.dio)
+}
+
+// writeByte clocks out one byte and checks that the chip acknowledges it.
+func (d *tm1637Driver) writeByte(b byte) (err error) {
+	for i := uint(0); i < 8; i++ {
+		if err = d.low(d.clk); err != nil {
+			return
+		}
+		if b&(1<<i) != 0 {
+			err = d.high(d.dio)
+		} else {
+			err = d.low(d.dio)
+		}
+		if err != nil {
+			return
+		}
+		if err = d.high(d.clk); err != nil {
+			return
+		}
+	}
+
+	// The chip pulls DIO low during the ninth clock to acknowledge
+	if err = d.low(d.clk); err != nil {
+		return
+	}
+	if err = d.high(d.dio); err != nil {
+		return
+	}
+	if err = d.high(d.clk); err != nil {
+		return
+	}
+	ack, err := d.dio.Value()
+	if err != nil {
+		return
+	}
+	if err = d.low(d.clk); err != nil {
+		return
+	}
+	if ack != 0 {
+		err = fmt.Errorf("TM1637 did not acknowledge byte: %#02x", b)
+	}
+
+	return
+}
+
+// command sends a sequence of bytes between a start and stop condition.
+func (d *tm1637Driver) command(bytes ...byte) (err error) {
+	if err = d.start(); err != nil {
+		return
+	}
+	for _, b := range bytes {
+		if err = d.writeByte(b); err != nil {
+			d.stop()
+			return
+		}
+	}
+	return d.stop()
+}
+
+func (d *tm1637Driver) show(segs []byte) (err error) {
+	copy(d.segs, segs)
+	if err = d.command(TM1637_DATA); err != nil {
+		return
+	}
+	if err = d.command(append([]byte{TM1637_ADDRESS}, d.segs...)...); err != nil {
+		return
+	}
+	return d.control()
+}
+
+func (d *tm1637Driver) control() error {
+	cmd := byte(TM1637_DISPLAY) | d.brightness
+	if d.on {
+		cmd |= TM1637_ON
+	}
+	return d.command(cmd)
+}
+
+func (d *tm1637Driver) setBrightness(brightness float64) error {
+	d.on = brightness > 0
+	if d.on {
+		d.brightness = byte(brightness*7 + 0.5)
+	}
+	return d.control()
+}
+
+func (d *tm1637Driver) close() (err error) {
+	for _, pin := range []*gpio.GPIO{d.clk, d.dio} {
+		if pin == nil {
+			continue
+		}
+		if e := pin.Unexport(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
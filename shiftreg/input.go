@@ -0,0 +1,262 @@
+package shiftreg
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"sync"
+	"time"
+)
+
+// An Input is a chain of 74HC165 shift registers.  Reading any pin loads and
+// shifts in the whole chain, so to read many pins at once use Read.
+type Input struct {
+	load, clock, data, inhibit *gpio.GPIO
+	chips                      int
+
+	lock sync.Mutex
+}
+
+// NewInput returns an Input for a chain of the given number of 74HC165s, with
+// their SH/LD and CLK inputs and the last chip's QH output connected to the
+// given GPIO pins.  inhibit is the pin connected to their CLK INH input, or -1
+// if it is tied low.  Each chip's SER input should be connected to the next
+// chip's QH.
+func NewInput(loadPin, clockPin, dataPin, inhibitPin, chips int) (in *Input, err error) {
+	if chips < 1 {
+		err = fmt.Errorf("Invalid number of chips: %d", chips)
+		return
+	}
+	pins, err := export(loadPin, clockPin, dataPin, inhibitPin)
+	if err != nil {
+		return
+	}
+
+	in = &Input{
+		load:    pins[0],
+		clock:   pins[1],
+		data:    pins[2],
+		inhibit: pins[3],
+		chips:   chips,
+	}
+	if err = in.load.SetDirection(gpio.Out); err == nil {
+		err = in.load.SetValue(1)
+	}
+	if err == nil {
+		err = in.clock.SetDirection(gpio.Out)
+	}
+	if err == nil {
+		err = in.data.SetDirection(gpio.In)
+	}
+	if err == nil && in.inhibit != nil {
+		err = in.inhibit.SetDirection(gpio.Out)
+	}
+	if err != nil {
+		unexport(pins...)
+		in = nil
+	}
+
+	return
+}
+
+// Width returns the number of pins in the chain.
+func (in *Input) Width() int {
+	return in.chips * 8
+}
+
+// Pin returns the numbered pin of the chain.
+func (in *Input) Pin(n int) (pin *InputPin, err error) {
+	if n < 0 || n >= in.Width() {
+		err = fmt.Errorf("Invalid pin: %d", n)
+		return
+	}
+	pin = &InputPin{in: in, n: n}
+
+	return
+}
+
+// Read loads the inputs of every chip and shifts them in, returning one byte
+// per chip, the first byte from the first chip.  Input A is bit 0 of each.
+//
+// The chip nearest the BeagleBone is the one whose QH is read, so its inputs
+// come out first, H first.
+func (in *Input) Read() (state []byte, err error) {
+	in.lock.Lock()
+	defer in.lock.Unlock()
+
+	if err = in.load.SetValue(0); err != nil {
+		return
+	}
+	if err = in.load.SetValue(1); err != nil {
+		return
+	}
+
+	state = make([]byte, in.chips)
+	for i := range state {
+		for b := 7; b >= 0; b-- {
+			var v int
+			if v, err = in.data.Value(); err != nil {
+				return nil, err
+			}
+			state[i] |= byte(v&1) << uint(b)
+			if err = in.clock.SetValue(1); err != nil {
+				return nil, err
+			}
+			if err = in.clock.SetValue(0); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return
+}
+
+// Close unexports the GPIO pins.
+func (in *Input) Close() error {
+	return unexport(in.load, in.clock, in.data, in.inhibit)
+}
+
+// An InputPin is a single input of a 74HC165 chain.  It implements
+// gpio.DigitalPin, but is always an input.  Edges are found by polling the
+// chain every PollInterval.
+type InputPin struct {
+	in *Input
+	n  int
+
+	lock      sync.Mutex
+	activeLow bool
+	debounce  time.Duration
+}
+
+var _ gpio.DigitalPin = (*InputPin)(nil)
+
+func (pin *InputPin) invert() int {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	if pin.activeLow {
+		return 1
+	}
+	return 0
+}
+
+// Value reads the chain and returns the pin's value.
+func (pin *InputPin) Value() (value int, err error) {
+	state, err := pin.in.Read()
+	if err != nil {
+		return
+	}
+	value = int(state[pin.n/8]>>uint(pin.n%8))&1 ^ pin.invert()
+
+	return
+}
+
+// SetValue fails, since the pin is an input.
+func (pin *InputPin) SetValue(value int) (err error) {
+	err = fmt.Errorf("%w: setting an input", ErrUnsupported)
+	return
+}
+
+// Direction always returns gpio.In.
+func (pin *InputPin) Direction() (dir gpio.Direction, err error) {
+	return gpio.In, nil
+}
+
+// SetDirection does nothing for gpio.In, and fails for gpio.Out.
+func (pin *InputPin) SetDirection(dir gpio.Direction) (err error) {
+	switch dir {
+	case gpio.In:
+	case gpio.Out:
+		err = fmt.Errorf("%w: %s", ErrUnsupported, dir)
+	default:
+		err = fmt.Errorf("%w: %s", gpio.ErrInvalidDirection, dir)
+	}
+
+	return
+}
+
+// SetActiveLow sets whether the pin's value is inverted, which is done in
+// software.
+func (pin *InputPin) SetActiveLow(activeLow bool) (err error) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	pin.activeLow = activeLow
+
+	return
+}
+
+// Debounce sets the period for which the pin's value must be stable before a
+// Watcher reports it.
+func (pin *InputPin) Debounce(d time.Duration) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	pin.debounce = d
+}
+
+// Watch starts a Watcher which sends the pin's value each time the given edge
+// occurs.
+func (pin *InputPin) Watch(edge gpio.Edge) (w *gpio.Watcher, err error) {
+	if edge != gpio.Rising && edge != gpio.Falling && edge != gpio.Both {
+		err = fmt.Errorf("%w: %s", gpio.ErrInvalidEdge, edge)
+		return
+	}
+	last, err := pin.Value()
+	if err != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	w, values := gpio.NewWatcher(func() { close(stop) })
+	go pin.watch(values, stop, edge, last)
+
+	return
+}
+
+func (pin *InputPin) watch(values chan<- int, stop chan struct{}, edge gpio.Edge, last int) {
+	defer close(values)
+
+	pin.lock.Lock()
+	debounce := pin.debounce
+	pin.lock.Unlock()
+
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+
+	// changed is when the value was first seen to differ from last
+	var changed time.Time
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		value, err := pin.Value()
+		if err != nil || value == last {
+			changed = time.Time{}
+			continue
+		}
+		if debounce > 0 {
+			if changed.IsZero() {
+				changed = time.Now()
+			}
+			if time.Since(changed) < debounce {
+				continue
+			}
+		}
+		changed = time.Time{}
+		last = value
+		if (edge == gpio.Rising && value != 1) || (edge == gpio.Falling && value != 0) {
+			continue
+		}
+
+		select {
+		case values <- value:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Unexport does nothing; shift register pins don't need exporting.
+func (pin *InputPin) Unexport() (err error) {
+	return
+}
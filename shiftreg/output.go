@@ -0,0 +1,252 @@
+package shiftreg
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"sync"
+	"time"
+)
+
+// An Output is a chain of 74HC595 shift registers.  The state of every
+// output is kept in memory; setting a pin shifts the whole chain out and
+// latches it, so to change many pins at once use Set and Latch instead.
+type Output struct {
+	data, clock, latch, oe *gpio.GPIO
+
+	lock  sync.Mutex
+	state []byte
+}
+
+// NewOutput returns an Output for a chain of the given number of 74HC595s,
+// with their SER, SRCLK and RCLK inputs connected to the given GPIO pins.
+// oe is the pin connected to their OE input, or -1 if it is tied low.  All
+// outputs start low.
+func NewOutput(dataPin, clockPin, latchPin, oePin, chips int) (out *Output, err error) {
+	if chips < 1 {
+		err = fmt.Errorf("Invalid number of chips: %d", chips)
+		return
+	}
+	pins, err := export(dataPin, clockPin, latchPin, oePin)
+	if err != nil {
+		return
+	}
+
+	out = &Output{
+		data:  pins[0],
+		clock: pins[1],
+		latch: pins[2],
+		oe:    pins[3],
+		state: make([]byte, chips),
+	}
+	for _, p := range pins {
+		if p == nil {
+			continue
+		}
+		if err = p.SetDirection(gpio.Out); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = out.Latch()
+	}
+	if err != nil {
+		unexport(pins...)
+		out = nil
+	}
+
+	return
+}
+
+// Width returns the number of pins in the chain.
+func (out *Output) Width() int {
+	return len(out.state) * 8
+}
+
+// Pin returns the numbered pin of the chain.
+func (out *Output) Pin(n int) (pin *OutputPin, err error) {
+	if n < 0 || n >= out.Width() {
+		err = fmt.Errorf("Invalid pin: %d", n)
+		return
+	}
+	pin = &OutputPin{out: out, n: n}
+
+	return
+}
+
+// Set sets the value of a pin in memory, without shifting it out.
+func (out *Output) Set(n, value int) (err error) {
+	if n < 0 || n >= out.Width() {
+		err = fmt.Errorf("Invalid pin: %d", n)
+		return
+	}
+	if value != 0 && value != 1 {
+		err = fmt.Errorf("%w: %d", gpio.ErrInvalidValue, value)
+		return
+	}
+
+	out.lock.Lock()
+	defer out.lock.Unlock()
+	out.set(n, value)
+
+	return
+}
+
+func (out *Output) set(n, value int) {
+	if value != 0 {
+		out.state[n/8] |= 1 << uint(n%8)
+	} else {
+		out.state[n/8] &^= 1 << uint(n%8)
+	}
+}
+
+// Write sets the outputs of each chip in memory, the first byte going to the
+// first chip, without shifting them out.
+func (out *Output) Write(state []byte) (err error) {
+	if len(state) != len(out.state) {
+		err = fmt.Errorf("Wrong number of bytes for %d chips: %d", len(out.state), len(state))
+		return
+	}
+
+	out.lock.Lock()
+	defer out.lock.Unlock()
+	copy(out.state, state)
+
+	return
+}
+
+// Latch shifts the state of every pin out to the chain and latches it onto
+// the outputs.
+func (out *Output) Latch() (err error) {
+	out.lock.Lock()
+	defer out.lock.Unlock()
+
+	return out.shift()
+}
+
+// shift clocks the state out, last chip first and QH first within each chip,
+// then pulses RCLK.  The lock must be held.
+func (out *Output) shift() (err error) {
+	for i := len(out.state) - 1; i >= 0; i-- {
+		for b := 7; b >= 0; b-- {
+			if err = out.data.SetValue(int(out.state[i]>>uint(b)) & 1); err != nil {
+				return
+			}
+			if err = out.clock.SetValue(1); err != nil {
+				return
+			}
+			if err = out.clock.SetValue(0); err != nil {
+				return
+			}
+		}
+	}
+	if err = out.latch.SetValue(1); err != nil {
+		return
+	}
+	return out.latch.SetValue(0)
+}
+
+// Enable enables or disables the outputs using OE.  It fails if there is no
+// OE pin.
+func (out *Output) Enable(enable bool) (err error) {
+	if out.oe == nil {
+		err = fmt.Errorf("%w: no OE pin", ErrUnsupported)
+		return
+	}
+	if enable {
+		return out.oe.SetValue(0)
+	}
+	return out.oe.SetValue(1)
+}
+
+// Close unexports the GPIO pins.  The outputs are left as they are.
+func (out *Output) Close() error {
+	return unexport(out.data, out.clock, out.latch, out.oe)
+}
+
+// An OutputPin is a single output of a 74HC595 chain.  It implements
+// gpio.DigitalPin, but is always an output and can't be watched.
+type OutputPin struct {
+	out *Output
+	n   int
+
+	lock      sync.Mutex
+	activeLow bool
+}
+
+var _ gpio.DigitalPin = (*OutputPin)(nil)
+
+func (pin *OutputPin) invert() int {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	if pin.activeLow {
+		return 1
+	}
+	return 0
+}
+
+// Value returns the value the pin was last set to.
+func (pin *OutputPin) Value() (value int, err error) {
+	pin.out.lock.Lock()
+	value = int(pin.out.state[pin.n/8]>>uint(pin.n%8)) & 1
+	pin.out.lock.Unlock()
+
+	return value ^ pin.invert(), nil
+}
+
+// SetValue sets the pin's value, shifting out the whole chain.
+func (pin *OutputPin) SetValue(value int) (err error) {
+	if value != 0 && value != 1 {
+		err = fmt.Errorf("%w: %d", gpio.ErrInvalidValue, value)
+		return
+	}
+	value ^= pin.invert()
+
+	pin.out.lock.Lock()
+	defer pin.out.lock.Unlock()
+	pin.out.set(pin.n, value)
+
+	return pin.out.shift()
+}
+
+// Direction always returns gpio.Out.
+func (pin *OutputPin) Direction() (dir gpio.Direction, err error) {
+	return gpio.Out, nil
+}
+
+// SetDirection does nothing for gpio.Out, and fails for gpio.In.
+func (pin *OutputPin) SetDirection(dir gpio.Direction) (err error) {
+	switch dir {
+	case gpio.Out:
+	case gpio.In:
+		err = fmt.Errorf("%w: %s", ErrUnsupported, dir)
+	default:
+		err = fmt.Errorf("%w: %s", gpio.ErrInvalidDirection, dir)
+	}
+
+	return
+}
+
+// SetActiveLow sets whether the pin's value is inverted, which is done in
+// software.
+func (pin *OutputPin) SetActiveLow(activeLow bool) (err error) {
+	pin.lock.Lock()
+	defer pin.lock.Unlock()
+	pin.activeLow = activeLow
+
+	return
+}
+
+// Debounce does nothing, since output pins can't be watched.
+func (pin *OutputPin) Debounce(d time.Duration) {
+}
+
+// Watch fails, since an output pin only changes when it is set.
+func (pin *OutputPin) Watch(edge gpio.Edge) (w *gpio.Watcher, err error) {
+	err = fmt.Errorf("%w: watching an output", ErrUnsupported)
+	return
+}
+
+// Unexport does nothing; shift register pins don't need exporting.
+func (pin *OutputPin) Unexport() (err error) {
+	return
+}
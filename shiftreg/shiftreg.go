@@ -0,0 +1,75 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package shiftreg drives chains of shift registers to expand the
+// BeagleBone's GPIOs: 74HC595s for outputs and 74HC165s for inputs.  Each
+// expanded pin is presented as a gpio.DigitalPin, so that it can be used
+// anywhere a GPIO can.
+//
+// Pins are numbered from the chip nearest the BeagleBone: pin 0 is output QA
+// (or input A) of the first chip, pin 8 is QA of the second chip, and so on.
+package shiftreg
+
+import (
+	"errors"
+	"github.com/Ratfink/gopherbone/gpio"
+	"time"
+)
+
+// PollInterval is how often an input chain is read while any of its pins are
+// being watched, since the 74HC165 has no interrupt output.
+var PollInterval = 10 * time.Millisecond
+
+// ErrUnsupported is returned by operations which a shift register pin can't
+// perform, such as making an output pin an input.
+var ErrUnsupported = errors.New("Not supported by shift register pin")
+
+// export exports each of the given pins.  Pins given as -1 are left nil.  If
+// any export fails, those already exported are unexported again.
+func export(pins ...int) (gpios []*gpio.GPIO, err error) {
+	gpios = make([]*gpio.GPIO, len(pins))
+	for i, pin := range pins {
+		if pin < 0 {
+			continue
+		}
+		if gpios[i], err = gpio.Export(pin); err != nil {
+			unexport(gpios...)
+			return
+		}
+	}
+
+	return
+}
+
+// unexport unexports the non-nil pins given, returning the first error.
+func unexport(gpios ...*gpio.GPIO) (err error) {
+	for _, g := range gpios {
+		if g == nil {
+			continue
+		}
+		if e := g.Unexport(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
@@ -0,0 +1,208 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package softpwm generates PWM on any GPIO in software, for when the
+// hardware PWM outputs are used up or not on the pins needed.  Timing is
+// done by a sched.Loop, so it jitters with system load; it is good enough
+// for dimming LEDs and driving slow things such as heaters, but servos and
+// motors are better served by hardware PWM or a PCA9685.
+package softpwm
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/pwm"
+	"github.com/Ratfink/gopherbone/sched"
+	"sync"
+	"time"
+)
+
+// DefaultPeriod is the period of a new PWM, giving 100Hz.
+var DefaultPeriod = 10 * time.Millisecond
+
+// A PWM is a software PWM output on a GPIO.
+type PWM struct {
+	pin    gpio.DigitalPin
+	ownPin bool
+	opts   sched.Options
+
+	lock   sync.Mutex
+	period time.Duration
+	duty   time.Duration
+	loop   *sched.Loop
+}
+
+var _ pwm.Channel = (*PWM)(nil)
+
+// New exports a GPIO pin as an output and returns a disabled PWM on it.
+// opts sets the loop's spin time and priority; a spin of 100µs or so gives
+// much steadier timing at the cost of CPU time.
+func New(pin int, opts sched.Options) (p *PWM, err error) {
+	g, err := gpio.Export(pin)
+	if err != nil {
+		return
+	}
+	if err = g.SetDirection(gpio.Out); err != nil {
+		g.Unexport()
+		return
+	}
+	if p, err = NewPin(g, opts); err != nil {
+		g.Unexport()
+		return
+	}
+	p.ownPin = true
+	return
+}
+
+// NewPin returns a disabled PWM on an output pin, driving it low.
+func NewPin(pin gpio.DigitalPin, opts sched.Options) (p *PWM, err error) {
+	if err = pin.SetValue(0); err != nil {
+		return
+	}
+	p = &PWM{pin: pin, opts: opts, period: DefaultPeriod}
+	return
+}
+
+// Period returns the PWM period.
+func (p *PWM) Period() (time.Duration, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.period, nil
+}
+
+// SetPeriod sets the PWM period, which may not be shorter than the duty
+// cycle.
+func (p *PWM) SetPeriod(period time.Duration) (err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if period <= 0 || period < p.duty {
+		return fmt.Errorf("Invalid period: %s", period)
+	}
+	p.period = period
+	if p.loop != nil {
+		err = p.loop.SetPeriod(period)
+	}
+	return
+}
+
+// DutyCycle returns the time for which each period is high.
+func (p *PWM) DutyCycle() (time.Duration, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.duty, nil
+}
+
+// SetDutyCycle sets the time for which each period is high.  It may not be
+// longer than the period.
+func (p *PWM) SetDutyCycle(duty time.Duration) (err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if duty < 0 || duty > p.period {
+		return fmt.Errorf("Invalid duty cycle: %s", duty)
+	}
+	p.duty = duty
+	return
+}
+
+// SetFrequency sets the PWM frequency in hertz, keeping the same fraction of
+// each period high.
+func (p *PWM) SetFrequency(hz float64) (err error) {
+	if hz <= 0 {
+		return fmt.Errorf("Invalid frequency: %g", hz)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	period := time.Duration(float64(time.Second) / hz)
+	p.duty = time.Duration(float64(period) * float64(p.duty) / float64(p.period))
+	p.period = period
+	if p.loop != nil {
+		err = p.loop.SetPeriod(period)
+	}
+	return
+}
+
+// SetDuty sets the fraction of each period which is high, from 0 to 1.
+func (p *PWM) SetDuty(fraction float64) (err error) {
+	if fraction < 0 || fraction > 1 {
+		return fmt.Errorf("Invalid duty: %g", fraction)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.duty = time.Duration(float64(p.period) * fraction)
+	return
+}
+
+// Enable starts the PWM output.
+func (p *PWM) Enable() (err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.loop != nil {
+		return
+	}
+	p.loop, err = sched.New(p.period, p.opts, p.tick)
+	return
+}
+
+// Disable stops the PWM output, leaving the pin low.  It returns the error
+// which stopped the output, if writing the pin failed.
+func (p *PWM) Disable() (err error) {
+	p.lock.Lock()
+	loop := p.loop
+	p.loop = nil
+	p.lock.Unlock()
+	if loop == nil {
+		return
+	}
+
+	err = loop.Stop()
+	if e := p.pin.SetValue(0); err == nil {
+		err = e
+	}
+	return
+}
+
+// tick drives one period of the output.
+func (p *PWM) tick(t sched.Tick) (err error) {
+	p.lock.Lock()
+	period, duty := p.period, p.duty
+	p.lock.Unlock()
+
+	if duty <= 0 {
+		return p.pin.SetValue(0)
+	}
+	if err = p.pin.SetValue(1); err != nil || duty >= period {
+		return
+	}
+	sched.SleepUntil(t.Time.Add(t.Late+duty), p.opts.Spin)
+	return p.pin.SetValue(0)
+}
+
+// Close disables the output, and unexports the pin if New exported it.
+func (p *PWM) Close() (err error) {
+	err = p.Disable()
+	if p.ownPin {
+		if e := p.pin.Unexport(); err == nil {
+			err = e
+		}
+	}
+	return
+}
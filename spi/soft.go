@@ -0,0 +1,282 @@
+package spi
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"sync"
+	"time"
+)
+
+var _ Conn = (*Soft)(nil)
+
+// A Soft is an SPI master bit-banged on GPIO pins, for low speed devices such
+// as shift registers and LED drivers when the hardware SPI pins are taken.
+// Through sysfs each line change takes some tens of microseconds, so the
+// clock runs at a few kHz at best, whatever speed is set.
+//
+// Words of up to 8 bits take one byte each, as with spidev, and words of 9 to
+// 16 bits take two bytes, least significant first.  Words are sent most
+// significant bit first.
+type Soft struct {
+	sclk, mosi, miso, cs gpio.DigitalPin
+	lock                 sync.Mutex
+	mode                 uint8
+	bits                 uint8
+	// half of the clock period
+	half time.Duration
+	// set if the pins were exported by NewSoft and must be unexported by
+	// Close
+	owned bool
+}
+
+// NewSoft exports the given pins and returns a Soft using them.  miso may be
+// -1 for devices which only receive, in which case zeros are read, and cs may
+// be -1 for devices with their chip select tied low.  The device starts in
+// mode 0 at 1MHz with 8 bits per word, as with Open.
+func NewSoft(sclkPin, mosiPin, misoPin, csPin int) (spi *Soft, err error) {
+	var pins [4]gpio.DigitalPin
+	for i, pin := range []int{sclkPin, mosiPin, misoPin, csPin} {
+		if pin < 0 {
+			continue
+		}
+		var p *gpio.GPIO
+		if p, err = gpio.Export(pin); err != nil {
+			break
+		}
+		pins[i] = p
+	}
+	if err == nil {
+		spi, err = NewSoftPins(pins[0], pins[1], pins[2], pins[3])
+	}
+	if err != nil {
+		for _, p := range pins {
+			if p != nil {
+				p.Unexport()
+			}
+		}
+		return
+	}
+	spi.owned = true
+
+	return
+}
+
+// NewSoftPins returns a Soft using pins which are already exported.  miso and
+// cs may be nil, as with NewSoft.
+func NewSoftPins(sclk, mosi, miso, cs gpio.DigitalPin) (spi *Soft, err error) {
+	if sclk == nil || mosi == nil {
+		err = fmt.Errorf("SCLK and MOSI pins are required")
+		return
+	}
+	spi = &Soft{sclk: sclk, mosi: mosi, miso: miso, cs: cs, bits: 8}
+	spi.half = time.Second / (2 * 1000000)
+
+	if err = sclk.SetDirection(gpio.Out); err == nil {
+		err = mosi.SetDirection(gpio.Out)
+	}
+	if err == nil && miso != nil {
+		err = miso.SetDirection(gpio.In)
+	}
+	if err == nil && cs != nil {
+		if err = cs.SetDirection(gpio.Out); err == nil {
+			err = cs.SetValue(1)
+		}
+	}
+	if err != nil {
+		spi = nil
+	}
+
+	return
+}
+
+// SetMode sets the SPI mode, one of MODE_0 to MODE_3.  The clock is moved to
+// its new idle level straight away.
+func (spi *Soft) SetMode(mode uint8) (err error) {
+	if mode > MODE_3 {
+		err = fmt.Errorf("Invalid mode: %d", mode)
+		return
+	}
+
+	spi.lock.Lock()
+	defer spi.lock.Unlock()
+	spi.mode = mode
+
+	return spi.sclk.SetValue(spi.idle())
+}
+
+// SetBitsPerWord sets the word size, from 1 to 16 bits.
+func (spi *Soft) SetBitsPerWord(bits uint8) (err error) {
+	if bits < 1 || bits > 16 {
+		err = fmt.Errorf("Invalid bits per word: %d", bits)
+		return
+	}
+
+	spi.lock.Lock()
+	defer spi.lock.Unlock()
+	spi.bits = bits
+
+	return
+}
+
+// SetSpeed sets the maximum clock speed in hertz.
+func (spi *Soft) SetSpeed(hz uint32) (err error) {
+	if hz == 0 {
+		err = fmt.Errorf("Invalid speed: %d", hz)
+		return
+	}
+
+	spi.lock.Lock()
+	defer spi.lock.Unlock()
+	spi.half = time.Second / time.Duration(2*uint64(hz))
+
+	return
+}
+
+// Transfer sends tx while receiving the same number of bytes, which are
+// returned.  With more than 8 bits per word, tx must hold a whole number of
+// words.
+func (spi *Soft) Transfer(tx []byte) (rx []byte, err error) {
+	if len(tx) == 0 {
+		return
+	}
+	rx = make([]byte, len(tx))
+	if err = spi.transfer(tx, rx); err != nil {
+		rx = nil
+	}
+
+	return
+}
+
+// Write sends data, discarding whatever is received.
+func (spi *Soft) Write(data []byte) (err error) {
+	if len(data) == 0 {
+		return
+	}
+	return spi.transfer(data, nil)
+}
+
+// Close unexports the pins if they were exported by NewSoft.
+func (spi *Soft) Close() (err error) {
+	spi.lock.Lock()
+	defer spi.lock.Unlock()
+
+	if !spi.owned {
+		return
+	}
+	spi.owned = false
+	for _, p := range []gpio.DigitalPin{spi.sclk, spi.mosi, spi.miso, spi.cs} {
+		if p == nil {
+			continue
+		}
+		if e := p.Unexport(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+// idle returns the clock's level between transfers, given by CPOL.
+func (spi *Soft) idle() int {
+	return int(spi.mode>>1) & 1
+}
+
+// delay waits for half a clock period.  The periods are far too short for
+// time.Sleep, so it spins.
+func (spi *Soft) delay() {
+	for start := time.Now(); time.Since(start) < spi.half; {
+	}
+}
+
+func (spi *Soft) transfer(tx, rx []byte) (err error) {
+	spi.lock.Lock()
+	defer spi.lock.Unlock()
+	start := time.Now()
+	defer func() {
+		traceTransfer(start, "soft spi", tx, rx, err)
+	}()
+
+	size := 1
+	if spi.bits > 8 {
+		size = 2
+	}
+	if len(tx)%size != 0 {
+		err = fmt.Errorf("Transfer of %d bytes is not a whole number of %d bit words", len(tx), spi.bits)
+		return
+	}
+
+	if spi.cs != nil {
+		if err = spi.cs.SetValue(0); err != nil {
+			return
+		}
+		defer func() {
+			if e := spi.cs.SetValue(1); err == nil {
+				err = e
+			}
+		}()
+	}
+
+	for i := 0; i < len(tx); i += size {
+		out := uint16(tx[i])
+		if size == 2 {
+			out |= uint16(tx[i+1]) << 8
+		}
+		var in uint16
+		if in, err = spi.word(out); err != nil {
+			return
+		}
+		if rx != nil {
+			rx[i] = byte(in)
+			if size == 2 {
+				rx[i+1] = byte(in >> 8)
+			}
+		}
+	}
+
+	return
+}
+
+// word clocks one word out and in.  With CPHA clear, data is set up before
+// the clock's leading edge and sampled on it; with CPHA set, it is set up on
+// the leading edge and sampled on the trailing one.
+func (spi *Soft) word(out uint16) (in uint16, err error) {
+	idle := spi.idle()
+	cpha := spi.mode&1 != 0
+
+	for i := int(spi.bits) - 1; i >= 0; i-- {
+		if cpha {
+			if err = spi.sclk.SetValue(1 - idle); err != nil {
+				return
+			}
+		}
+		if err = spi.mosi.SetValue(int(out>>uint(i)) & 1); err != nil {
+			return
+		}
+		spi.delay()
+		if cpha {
+			err = spi.sclk.SetValue(idle)
+		} else {
+			err = spi.sclk.SetValue(1 - idle)
+		}
+		if err != nil {
+			return
+		}
+		if spi.miso != nil {
+			var v int
+			if v, err = spi.miso.Value(); err != nil {
+				return
+			}
+			in = in<<1 | uint16(v&1)
+		} else {
+			in <<= 1
+		}
+		spi.delay()
+		if !cpha {
+			if err = spi.sclk.SetValue(idle); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
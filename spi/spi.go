@@ -0,0 +1,222 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* This SPI system uses the kernel's spidev interface.  The bus must be
+ * enabled first, for example by loading the BB-SPIDEV0 overlay with the
+ * capemgr package.
+ */
+package spi
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/metrics"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// as defined in /usr/include/linux/spi/spidev.h
+const (
+	SPI_IOC_MESSAGE_1        = 0x40206b00
+	SPI_IOC_WR_MODE          = 0x40016b01
+	SPI_IOC_WR_BITS_PER_WORD = 0x40016b03
+	SPI_IOC_WR_MAX_SPEED_HZ  = 0x40046b04
+)
+
+// SPI modes, selecting the clock polarity and phase.
+const (
+	MODE_0 = 0x00
+	MODE_1 = 0x01
+	MODE_2 = 0x02
+	MODE_3 = 0x03
+)
+
+// as defined in /usr/include/linux/spi/spidev.h
+type spi_ioc_transfer struct {
+	txBuf       uint64
+	rxBuf       uint64
+	len         uint32
+	speedHz     uint32
+	delayUsecs  uint16
+	bitsPerWord uint8
+	csChange    uint8
+	txNbits     uint8
+	rxNbits     uint8
+	wordDelay   uint8
+	pad         uint8
+}
+
+// A Conn is a connection to a single device on an SPI bus, such as an SPI.
+// Drivers which use a Conn rather than an *SPI can be given a fake one for
+// testing.
+type Conn interface {
+	SetMode(mode uint8) error
+	SetBitsPerWord(bits uint8) error
+	SetSpeed(hz uint32) error
+	Transfer(tx []byte) ([]byte, error)
+	Write(data []byte) error
+	Close() error
+}
+
+var _ Conn = (*SPI)(nil)
+
+// An SPI is a device on an SPI bus, selected by one chip select line.
+type SPI struct {
+	file  *os.File
+	speed uint32
+	bits  uint8
+	// name is the device's name, for tracing
+	name string
+}
+
+// Open opens the spidev device for the given bus and chip select.  The
+// device starts in mode 0 at 1MHz with 8 bits per word.
+func Open(bus, cs int) (spi *SPI, err error) {
+	spi = &SPI{name: fmt.Sprintf("spidev%d.%d", bus, cs)}
+	spi.file, err = gopherbone.OpenFile(fmt.Sprintf("/dev/spidev%d.%d", bus, cs), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = spi.SetMode(MODE_0); err == nil {
+		err = spi.SetBitsPerWord(8)
+	}
+	if err == nil {
+		err = spi.SetSpeed(1000000)
+	}
+	if err != nil {
+		spi.file.Close()
+		return nil, err
+	}
+
+	return
+}
+
+func (spi *SPI) ioctl(req uintptr, arg unsafe.Pointer) (err error) {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, spi.file.Fd(), req, uintptr(arg)); errno != 0 {
+		err = syscall.Errno(errno)
+	}
+
+	return
+}
+
+// SetMode sets the SPI mode, one of MODE_0 to MODE_3.
+func (spi *SPI) SetMode(mode uint8) (err error) {
+	if mode > MODE_3 {
+		err = fmt.Errorf("Invalid mode: %d", mode)
+		return
+	}
+	return spi.ioctl(SPI_IOC_WR_MODE, unsafe.Pointer(&mode))
+}
+
+// SetBitsPerWord sets the word size.
+func (spi *SPI) SetBitsPerWord(bits uint8) (err error) {
+	if err = spi.ioctl(SPI_IOC_WR_BITS_PER_WORD, unsafe.Pointer(&bits)); err == nil {
+		spi.bits = bits
+	}
+
+	return
+}
+
+// SetSpeed sets the maximum clock speed in hertz.  The actual speed is the
+// fastest the controller can manage without exceeding it; the AM335x divides
+// a 48MHz clock.
+func (spi *SPI) SetSpeed(hz uint32) (err error) {
+	if err = spi.ioctl(SPI_IOC_WR_MAX_SPEED_HZ, unsafe.Pointer(&hz)); err == nil {
+		spi.speed = hz
+	}
+
+	return
+}
+
+// Transfer sends tx while receiving the same number of bytes, which are
+// returned.  The kernel limits a single transfer to the spidev module's
+// bufsiz parameter, 4096 bytes by default.
+func (spi *SPI) Transfer(tx []byte) (rx []byte, err error) {
+	if len(tx) == 0 {
+		return
+	}
+	rx = make([]byte, len(tx))
+	err = spi.transfer(tx, rx)
+
+	return
+}
+
+// Write sends data, discarding whatever is received.
+func (spi *SPI) Write(data []byte) (err error) {
+	if len(data) == 0 {
+		return
+	}
+	return spi.transfer(data, nil)
+}
+
+func (spi *SPI) transfer(tx, rx []byte) error {
+	xfer := spi_ioc_transfer{
+		txBuf:       uint64(uintptr(unsafe.Pointer(&tx[0]))),
+		len:         uint32(len(tx)),
+		speedHz:     spi.speed,
+		bitsPerWord: spi.bits,
+	}
+	if rx != nil {
+		xfer.rxBuf = uint64(uintptr(unsafe.Pointer(&rx[0])))
+	}
+
+	start := time.Now()
+	err := spi.ioctl(SPI_IOC_MESSAGE_1, unsafe.Pointer(&xfer))
+	// The buffers are only referenced through integers in xfer
+	runtime.KeepAlive(tx)
+	runtime.KeepAlive(rx)
+	traceTransfer(start, spi.name, tx, rx, err)
+
+	return err
+}
+
+// traceTransfer counts a transfer which started at start, and traces it if
+// a tracer is set.
+func traceTransfer(start time.Time, name string, tx, rx []byte, err error) {
+	metrics.SPITransfers.Inc()
+	if err != nil {
+		metrics.SPIErrors.Inc()
+	}
+	if !gopherbone.Tracing() {
+		return
+	}
+	gopherbone.Trace(gopherbone.TraceEvent{
+		Time:     start,
+		Kind:     gopherbone.TraceSPI,
+		Target:   name,
+		Op:       "transfer",
+		Reg:      -1,
+		Write:    tx,
+		Read:     rx,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
+
+// Close closes the device.
+func (spi *SPI) Close() error {
+	return spi.file.Close()
+}
@@ -0,0 +1,49 @@
+package ssd1306
+
+import (
+	"github.com/Ratfink/gopherbone/display"
+	"image"
+)
+
+// SetClip restricts drawing to r, so that nothing outside it is changed by
+// Point, Clear, Rectangle, Char, or anything drawn with them.  The zero
+// Rectangle removes the clip.
+func (ssd1306 *SSD1306) SetClip(r image.Rectangle) {
+	if r == (image.Rectangle{}) {
+		ssd1306.clip, ssd1306.clipped = image.Rectangle{}, false
+		return
+	}
+	ssd1306.clip = r.Canon().Intersect(image.Rect(0, 0, ssd1306.width, ssd1306.height))
+	ssd1306.clipped = true
+}
+
+// Clip returns the rectangle drawing is restricted to, which is the whole
+// display if no clip is set.
+func (ssd1306 *SSD1306) Clip() image.Rectangle {
+	if !ssd1306.clipped {
+		return image.Rect(0, 0, ssd1306.width, ssd1306.height)
+	}
+	return ssd1306.clip
+}
+
+// SubCanvas returns a drawing target covering r of the display, with its
+// own origin at r's top left corner.
+func (ssd1306 *SSD1306) SubCanvas(r image.Rectangle) *display.SubCanvas {
+	return display.NewSubCanvas(ssd1306, r)
+}
+
+// visible reports whether a point may be drawn.
+func (ssd1306 *SSD1306) visible(x, y int) bool {
+	return image.Pt(x, y).In(ssd1306.Clip())
+}
+
+// pageMask returns the bits of a page's bytes that are within the clip.
+func (ssd1306 *SSD1306) pageMask(page int) (mask byte) {
+	r := ssd1306.Clip()
+	for bit := 0; bit < 8; bit++ {
+		if y := page*8 + bit; y >= r.Min.Y && y < r.Max.Y {
+			mask |= 1 << uint(bit)
+		}
+	}
+	return
+}
@@ -0,0 +1,84 @@
+package ssd1306
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/display"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+var _ display.Canvas = (*SSD1306)(nil)
+
+// Pixel reports whether a pixel of the framebuffer is lit.  Pixels off the
+// display are not.
+func (ssd1306 *SSD1306) Pixel(x, y int) bool {
+	if ssd1306.checkBounds(x, y) != nil {
+		return false
+	}
+	return ssd1306.buf[ssd1306.width*(y/8)+x]&(1<<uint(y%8)) != 0
+}
+
+// Blit draws a sprite with its top left corner at (x, y), combining it with
+// the framebuffer as display.Blit does.
+func (ssd1306 *SSD1306) Blit(x, y int, sprite *display.Bitmap, op display.Op) error {
+	return display.Blit(ssd1306, x, y, sprite, op)
+}
+
+// Framebuffer returns a copy of the framebuffer in the display's own layout:
+// a byte per column of each 8 pixel high page, bit 0 at the top, pages from
+// top to bottom.
+func (ssd1306 *SSD1306) Framebuffer() []byte {
+	return append([]byte(nil), ssd1306.buf...)
+}
+
+// LoadFramebuffer replaces the framebuffer with raw, which must be in the
+// layout returned by Framebuffer and exactly width*height/8 bytes long.
+func (ssd1306 *SSD1306) LoadFramebuffer(raw []byte) (err error) {
+	if len(raw) != len(ssd1306.buf) {
+		err = fmt.Errorf("Framebuffer is %d bytes, not %d", len(raw), len(ssd1306.buf))
+		return
+	}
+	copy(ssd1306.buf, raw)
+
+	return
+}
+
+// Image returns a copy of the framebuffer as an image, with lit pixels white.
+// It shows what the next Draw will send, whatever the display's inversion,
+// mirroring or contrast.
+func (ssd1306 *SSD1306) Image() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, ssd1306.width, ssd1306.height))
+	for y := 0; y < ssd1306.height; y++ {
+		for x := 0; x < ssd1306.width; x++ {
+			if ssd1306.buf[ssd1306.width*(y/8)+x]&(1<<uint(y%8)) != 0 {
+				img.SetGray(x, y, color.Gray{Y: 0xff})
+			}
+		}
+	}
+
+	return img
+}
+
+// LoadImage replaces the framebuffer with img, lighting the pixels whose
+// brightness is at least half.  The image's top left corner goes at the top
+// left of the display; parts which don't fit are cut off, and parts of the
+// display it doesn't cover are cleared.
+func (ssd1306 *SSD1306) LoadImage(img image.Image) {
+	ssd1306.Clear(color.Black)
+	b := img.Bounds()
+	for y := 0; y < ssd1306.height && y < b.Dy(); y++ {
+		for x := 0; x < ssd1306.width && x < b.Dx(); x++ {
+			if color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y >= 0x80 {
+				ssd1306.buf[ssd1306.width*(y/8)+x] |= 1 << uint(y%8)
+			}
+		}
+	}
+}
+
+// WritePNG writes the framebuffer to w as a PNG image, as a screenshot for
+// debugging or documentation.
+func (ssd1306 *SSD1306) WritePNG(w io.Writer) error {
+	return png.Encode(w, ssd1306.Image())
+}
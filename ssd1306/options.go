@@ -0,0 +1,73 @@
+package ssd1306
+
+// Defaults for panels powered by the SSD1306's own charge pump, which most
+// modules are, and for panels with an external VCC supply, as recommended
+// by the datasheet.
+const (
+	DEFAULT_CONTRAST  = 0xcf
+	DEFAULT_PRECHARGE = 0xf1
+	EXT_VCC_CONTRAST  = 0x9f
+	EXT_VCC_PRECHARGE = 0x22
+	DEFAULT_CLOCK     = 0xf0
+	DEFAULT_VCOMH     = 0x40
+)
+
+// Options change how Setup configures the panel.  A zero field takes its
+// default, which for Contrast and Precharge depends on ExternalVCC.
+type Options struct {
+	// ExternalVCC is set for panels powered from an external supply rather
+	// than the charge pump, which is then left off.
+	ExternalVCC bool
+	// Contrast is the initial contrast.
+	Contrast byte
+	// Precharge is the second byte of the PRECHARGE command: phase 1 in
+	// the low nibble and phase 2 in the high, in display clocks.
+	Precharge byte
+	// Clock is the second byte of the CLOCK_FREQ command: the divide ratio
+	// less one in the low nibble and the oscillator frequency in the high.
+	Clock byte
+	// VCOMH is the second byte of the VCOMH_DESELECT_LEVEL command.
+	VCOMH byte
+}
+
+// withDefaults returns the options with zero fields filled in.
+func (opts Options) withDefaults() Options {
+	if opts.Contrast == 0 {
+		opts.Contrast = DEFAULT_CONTRAST
+		if opts.ExternalVCC {
+			opts.Contrast = EXT_VCC_CONTRAST
+		}
+	}
+	if opts.Precharge == 0 {
+		opts.Precharge = DEFAULT_PRECHARGE
+		if opts.ExternalVCC {
+			opts.Precharge = EXT_VCC_PRECHARGE
+		}
+	}
+	if opts.Clock == 0 {
+		opts.Clock = DEFAULT_CLOCK
+	}
+	if opts.VCOMH == 0 {
+		opts.VCOMH = DEFAULT_VCOMH
+	}
+	return opts
+}
+
+// SetOptions sets how the next Setup configures the panel, including its
+// contrast, which replaces any set with SetContrast.  It must be called
+// before Setup for an externally powered panel, as the charge pump would
+// otherwise be switched on.
+func (ssd1306 *SSD1306) SetOptions(opts Options) {
+	opts = opts.withDefaults()
+	ssd1306.drawLock.Lock()
+	defer ssd1306.drawLock.Unlock()
+	ssd1306.opts = opts
+	ssd1306.contrast = opts.Contrast
+}
+
+// Options returns the options Setup uses, with defaults filled in.
+func (ssd1306 *SSD1306) Options() Options {
+	ssd1306.drawLock.Lock()
+	defer ssd1306.drawLock.Unlock()
+	return ssd1306.opts
+}
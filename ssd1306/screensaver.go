@@ -0,0 +1,200 @@
+package ssd1306
+
+import (
+	"fmt"
+	"time"
+)
+
+// FadeStep is the interval between contrast changes while fading.
+var FadeStep = 20 * time.Millisecond
+
+// SetContrast sets the display's contrast, which is its brightness; 0 is
+// dimmest but not off.
+func (ssd1306 *SSD1306) SetContrast(contrast byte) (err error) {
+	ssd1306.drawLock.Lock()
+	defer ssd1306.drawLock.Unlock()
+
+	if err = ssd1306.WriteCmd([]byte{CONTRAST, contrast}); err != nil {
+		return
+	}
+	ssd1306.contrast = contrast
+
+	return
+}
+
+// Contrast returns the contrast last set.
+func (ssd1306 *SSD1306) Contrast() byte {
+	ssd1306.drawLock.Lock()
+	defer ssd1306.drawLock.Unlock()
+	return ssd1306.contrast
+}
+
+// FadeTo changes the contrast smoothly to the given value over d, returning
+// once it has got there.
+func (ssd1306 *SSD1306) FadeTo(contrast byte, d time.Duration) (err error) {
+	from := int(ssd1306.Contrast())
+	steps := int(d / FadeStep)
+	for i := 1; i < steps; i++ {
+		c := from + (int(contrast)-from)*i/steps
+		if err = ssd1306.SetContrast(byte(c)); err != nil {
+			return
+		}
+		time.Sleep(FadeStep)
+	}
+	return ssd1306.SetContrast(contrast)
+}
+
+// Sleep switches the display off, keeping what is in its memory.  Drawing
+// still updates the memory while it sleeps.
+func (ssd1306 *SSD1306) Sleep() error {
+	ssd1306.drawLock.Lock()
+	defer ssd1306.drawLock.Unlock()
+	return ssd1306.WriteCmd([]byte{DISP_OFF})
+}
+
+// Wake switches the display back on after Sleep.
+func (ssd1306 *SSD1306) Wake() error {
+	ssd1306.drawLock.Lock()
+	defer ssd1306.drawLock.Unlock()
+	return ssd1306.WriteCmd([]byte{DISP_ON})
+}
+
+// screensaver dims and then sleeps the display when it has been idle.
+type screensaver struct {
+	dim, sleep  time.Duration
+	dimContrast byte
+	activity    chan struct{}
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// StartScreensaver dims the display to dimContrast once it has been idle
+// for dim, and switches it off once it has been idle for sleep, to save it
+// from burn-in.  Either duration may be zero to skip that stage.  Draw, and
+// Activity, which input handlers should call, count as activity, and wake
+// the display at its former contrast.
+func (ssd1306 *SSD1306) StartScreensaver(dim, sleep time.Duration, dimContrast byte) (err error) {
+	if dim < 0 || sleep < 0 || dim > 0 && sleep > 0 && sleep < dim {
+		err = fmt.Errorf("Invalid screensaver times: dim %v, sleep %v", dim, sleep)
+		return
+	}
+	s := &screensaver{
+		dim:         dim,
+		sleep:       sleep,
+		dimContrast: dimContrast,
+		activity:    make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	ssd1306.saverLock.Lock()
+	old := ssd1306.saver
+	ssd1306.saver = s
+	ssd1306.saverLock.Unlock()
+	stopScreensaver(old)
+	go ssd1306.runScreensaver(s)
+
+	return
+}
+
+// StopScreensaver stops the screensaver, waking the display if it had
+// dimmed or slept.
+func (ssd1306 *SSD1306) StopScreensaver() {
+	ssd1306.saverLock.Lock()
+	s := ssd1306.saver
+	ssd1306.saver = nil
+	ssd1306.saverLock.Unlock()
+	stopScreensaver(s)
+}
+
+// stopScreensaver stops a screensaver which has been taken out of use, if
+// there is one, and waits for it to wake the display.
+func stopScreensaver(s *screensaver) {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+// Activity tells the screensaver that the display is in use, waking it if
+// it has dimmed or slept.  It does nothing if there is no screensaver.
+func (ssd1306 *SSD1306) Activity() {
+	ssd1306.saverLock.Lock()
+	defer ssd1306.saverLock.Unlock()
+	s := ssd1306.saver
+	if s == nil {
+		return
+	}
+	select {
+	case s.activity <- struct{}{}:
+	default:
+	}
+}
+
+func (ssd1306 *SSD1306) runScreensaver(s *screensaver) {
+	defer close(s.done)
+
+	const (
+		awake = iota
+		dimmed
+		asleep
+	)
+	state := awake
+	bright := ssd1306.Contrast()
+	idle := time.Now()
+
+	// Errors are ignored here; the next Draw will report a broken bus
+	wake := func() {
+		if state == asleep {
+			ssd1306.Wake()
+		}
+		if state != awake {
+			ssd1306.SetContrast(bright)
+		}
+		state = awake
+	}
+	defer wake()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		// Work out what is next and when
+		next, at := awake, time.Time{}
+		switch {
+		case state == awake && s.dim > 0:
+			next, at = dimmed, idle.Add(s.dim)
+		case state < asleep && s.sleep > 0:
+			next, at = asleep, idle.Add(s.sleep)
+		}
+		var fire <-chan time.Time
+		if next != awake {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(time.Until(at))
+			fire = timer.C
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-s.activity:
+			idle = time.Now()
+			wake()
+		case <-fire:
+			if state == awake {
+				bright = ssd1306.Contrast()
+			}
+			switch next {
+			case dimmed:
+				ssd1306.FadeTo(s.dimContrast, 500*time.Millisecond)
+			case asleep:
+				ssd1306.Sleep()
+			}
+			state = next
+		}
+	}
+}
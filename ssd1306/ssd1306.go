@@ -23,11 +23,21 @@
 package ssd1306
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/display"
 	"github.com/Ratfink/gopherbone/gpio"
 	"github.com/Ratfink/gopherbone/i2c"
+	"github.com/Ratfink/gopherbone/metrics"
+	"github.com/Ratfink/gopherbone/sched"
 	"time"
+	"image"
 	"image/color"
+	"io"
 	"math"
+	"sync"
 )
 
 // Constants to allow different serial interfaces to be used in communicating
@@ -37,6 +47,14 @@ const (
 	IFACE_I2C = 1
 )
 
+// Errors which may be matched with errors.Is
+var (
+	// ErrOutOfBounds is display.ErrOutOfBounds, so either matches errors from
+	// the display and from display.Checked drawing on it
+	ErrOutOfBounds = display.ErrOutOfBounds
+	ErrUnsupportedInterface = errors.New("Unsupported interface")
+)
+
 // Fundamental commands
 const (
 	CONTRAST = 0x81 // 2 bytes; follow with 8 contrast bits
@@ -104,92 +122,438 @@ const (
 	CHARGE_PUMP_ON = 0x14
 )
 
+var _ display.Display = (*SSD1306)(nil)
+var _ display.Instrumented = (*SSD1306)(nil)
+
 type SSD1306 struct {
-	rst *gpio.GPIO
+	rst gpio.DigitalPin
 	iface int
-	i2cbus *i2c.Bus
+	i2cbus i2c.Conn
 	width int
 	height int
 	buf []byte
+	// ownsConn is set if New opened the connection, so Close must close it
+	ownsConn bool
+	// chunk is the number of bytes of display data sent per transfer
+	chunk int
+	rawbuf []byte
+	// drawLock serialises transfers of display data
+	drawLock sync.Mutex
+	// async is the background drawer, if any, and closed is set by Close,
+	// both guarded by asyncLock
+	async *asyncDrawer
+	closed bool
+	asyncLock sync.Mutex
+	// clip restricts drawing if clipped is set
+	clip image.Rectangle
+	clipped bool
+	// opaque makes Char fill the whole character cell
+	opaque bool
+	// contrast is the last contrast sent, guarded by drawLock
+	contrast byte
+	// opts configure the panel in Setup, guarded by drawLock
+	opts Options
+	// saver is the running screensaver, if any, guarded by saverLock
+	saver *screensaver
+	saverLock sync.Mutex
+	// meter gathers frame statistics for Stats
+	meter display.FrameMeter
+	// cleanup switches the display off if the program exits without
+	// closing it
+	cleanup *gopherbone.Handle
 }
 
+// New returns the display at addr on the given bus, with its reset line on
+// rstpin, or -1 if it has none.  If the bus is already open, for example by
+// another driver, its descriptor is shared.
 func New(rstpin, iface int, addr, bus byte, width, height int) (ssd1306 *SSD1306, err error) {
-	ssd1306 = new(SSD1306)
-
-	ssd1306.rst, err = gpio.Export(rstpin)
-	if err != nil {
+	if iface != IFACE_I2C {
+		err = fmt.Errorf("%w: %d", ErrUnsupportedInterface, iface)
 		return
 	}
 
-	ssd1306.iface = iface
-	if iface == IFACE_I2C {
-		ssd1306.i2cbus, err = i2c.NewBus(addr, bus)
-		if err != nil {
+	var rst gpio.DigitalPin
+	if rstpin >= 0 {
+		if rst, err = gpio.Export(rstpin); err != nil {
 			return
 		}
 	}
 
-	ssd1306.width, ssd1306.height = width, height
-	ssd1306.buf = make([]byte, width*height/8)
+	dev, err := i2c.NewDevice(addr, bus)
+	if err != nil {
+		if rst != nil {
+			rst.Unexport()
+		}
+		return
+	}
+
+	ssd1306, err = NewConn(dev, rst, width, height)
+	ssd1306.ownsConn = true
 
 	return
 }
 
-func (ssd1306 *SSD1306) Close() {
-	ssd1306.WriteData([]byte{0xae})
-	ssd1306.rst.Unexport()
+// NewOnBus returns the display at addr on a bus which is already open,
+// sharing its descriptor and lock with any other devices on it.
+func NewOnBus(bus *i2c.Bus, addr byte, rst gpio.DigitalPin, width, height int) (*SSD1306, error) {
+	return NewConn(bus.Device(addr), rst, width, height)
 }
 
-func (ssd1306 *SSD1306) Setup() (err error) {
-	// Reset the display
-	err = ssd1306.rst.SetDirection("out")
-	if err != nil {
-		return
+// NewConn returns a display connected by I2C through conn, with its reset
+// line on rst, or nil if it has none.  Either may be a fake for testing, or
+// an implementation for other hardware.
+func NewConn(conn i2c.Conn, rst gpio.DigitalPin, width, height int) (ssd1306 *SSD1306, err error) {
+	ssd1306 = &SSD1306{
+		rst: rst,
+		iface: IFACE_I2C,
+		i2cbus: conn,
+		width: width,
+		height: height,
+		buf: make([]byte, width*height/8),
+		contrast: DEFAULT_CONTRAST,
+		opts: Options{}.withDefaults(),
 	}
-	err = ssd1306.rst.SetValue(0)
-	if err != nil {
-		return
+	ssd1306.chunk = ssd1306.maxChunk()
+	if ssd1306.chunk > len(ssd1306.buf) {
+		ssd1306.chunk = len(ssd1306.buf)
 	}
-	time.Sleep(3*time.Millisecond)
-	err = ssd1306.rst.SetValue(1)
-	if err != nil {
+	ssd1306.cleanup = gopherbone.Register("SSD1306 display", ssd1306.Close)
+
+	return
+}
+
+// Close switches the display off and unexports its reset pin.  If the
+// display was opened with New, its connection is closed too; otherwise the
+// connection is left for its owner to close.  Everything is released even if
+// something fails, and the first error is returned.  Calling Close more than
+// once has no effect.
+func (ssd1306 *SSD1306) Close() (err error) {
+	ssd1306.asyncLock.Lock()
+	closed := ssd1306.closed
+	ssd1306.closed = true
+	ssd1306.asyncLock.Unlock()
+	if closed {
 		return
 	}
+	ssd1306.cleanup.Unregister()
+
+	ssd1306.StopScreensaver()
+	ssd1306.StopAsync()
+	err = ssd1306.WriteCmd([]byte{DISP_OFF})
+	if ssd1306.rst != nil {
+		if e := ssd1306.rst.Unexport(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if c, ok := ssd1306.i2cbus.(io.Closer); ok && ssd1306.ownsConn {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+func (ssd1306 *SSD1306) Setup() (err error) {
+	// Reset the display
+	if ssd1306.rst != nil {
+		err = ssd1306.rst.SetDirection(gpio.Out)
+		if err != nil {
+			return
+		}
+		err = ssd1306.rst.SetValue(0)
+		if err != nil {
+			return
+		}
+		time.Sleep(3*time.Millisecond)
+		err = ssd1306.rst.SetValue(1)
+		if err != nil {
+			return
+		}
+	}
+
+	ssd1306.drawLock.Lock()
+	opts := ssd1306.opts
+	contrast := ssd1306.contrast
+	ssd1306.drawLock.Unlock()
+	pump := byte(CHARGE_PUMP_ON)
+	if opts.ExternalVCC {
+		pump = CHARGE_PUMP_OFF
+	}
 
 	// Configure the display.  The whole thing is 24 bytes, so send it in one big write.
-	ssd1306.WriteCmd([]byte{
+	err = ssd1306.WriteCmd([]byte{
 		DISP_OFF,
 		START_LINE | 0x00,
 		ADDRESS_MODE, ADDRESS_MODE_HORI,
-		CONTRAST, 0xcf,
+		CONTRAST, contrast,
 		HORI_MIRROR,
 		INVERSE_OFF,
 		MUX_RATIO, 0x3f,
 		VERT_SHIFT, 0x00,
 		VERT_MIRROR,
-		CLOCK_FREQ, 0xf0,
-		PRECHARGE, 0xf1,
+		CLOCK_FREQ, opts.Clock,
+		PRECHARGE, opts.Precharge,
 		COM_CONFIG, COM_CONFIG2 | COM_CONFIG2_ALT,
-		VCOMH_DESELECT_LEVEL, 0x40,
-		CHARGE_PUMP, CHARGE_PUMP_ON,
+		VCOMH_DESELECT_LEVEL, opts.VCOMH,
+		CHARGE_PUMP, pump,
 		DISP_ON})
 
 	return
 }
 
-// Draw the display as fast as I can
+// maxChunk returns the most display data the connection can take in one
+// transfer.  Connections which can write plain I2C messages can take a whole
+// frame at once; otherwise SMBus block writes limit it to 32 bytes.
+func (ssd1306 *SSD1306) maxChunk() int {
+	if _, ok := ssd1306.i2cbus.(i2c.RawWriter); ok {
+		return i2c.I2C_RDWR_MAX - 1
+	}
+	return i2c.I2C_SMBUS_BLOCK_MAX
+}
+
+// SetChunkSize sets how many bytes of display data Draw sends per transfer.
+// By default, the whole frame is sent in one transfer if the connection
+// supports it, and 32 bytes at a time otherwise.  Smaller chunks may help
+// with unreliable wiring, since a failed transfer then loses less.
+func (ssd1306 *SSD1306) SetChunkSize(n int) (err error) {
+	if n < 1 || n > ssd1306.maxChunk() {
+		err = fmt.Errorf("Invalid chunk size: %d", n)
+		return
+	}
+	ssd1306.chunk = n
+
+	return
+}
+
+// ChunkSize returns how many bytes of display data Draw sends per transfer.
+func (ssd1306 *SSD1306) ChunkSize() int {
+	return ssd1306.chunk
+}
+
+// Draw the display as fast as I can.  If asynchronous drawing has been
+// started with StartAsync, the frame is queued instead, and any error from
+// drawing an earlier frame is returned.
 func (ssd1306 *SSD1306) Draw() (err error) {
-	if ssd1306.iface == IFACE_I2C {
-		for i := 0; i < len(ssd1306.buf); i += 32 {
-			err = ssd1306.WriteData(ssd1306.buf[i:i+32])
-			if err != nil {
-				return
-			}
+	ssd1306.Activity()
+	ssd1306.meter.StartFrame()
+	defer ssd1306.meter.EndFrame()
+	ssd1306.asyncLock.Lock()
+	if a := ssd1306.async; a != nil {
+		defer ssd1306.asyncLock.Unlock()
+		return a.queue(ssd1306.buf)
+	}
+	ssd1306.asyncLock.Unlock()
+	return ssd1306.DrawContext(context.Background())
+}
+
+// Stats returns how long frames have taken to render and send, the frame
+// rate, and how many bytes each frame takes on the bus.
+func (ssd1306 *SSD1306) Stats() display.FrameStats {
+	return ssd1306.meter.Stats()
+}
+
+// ResetStats starts the frame statistics again.
+func (ssd1306 *SSD1306) ResetStats() {
+	ssd1306.meter.Reset()
+}
+
+// DrawContext draws the display, giving up between transfers if ctx is done.
+// The display is then left partly drawn, but never mid-transfer.  The address
+// window is reset first, so a draw which failed partway doesn't leave the
+// next one shifted.
+func (ssd1306 *SSD1306) DrawContext(ctx context.Context) (err error) {
+	return ssd1306.send(ctx, ssd1306.buf)
+}
+
+// send transfers a frame to the display.
+func (ssd1306 *SSD1306) send(ctx context.Context, frame []byte) (err error) {
+	if ssd1306.iface != IFACE_I2C {
+		return
+	}
+	ssd1306.drawLock.Lock()
+	defer ssd1306.drawLock.Unlock()
+
+	start := time.Now()
+	n := 0
+	defer func() {
+		ssd1306.meter.Sent(start, n, err)
+		metrics.DrawLatency.ObserveSince(start)
+		if err != nil {
+			metrics.DrawErrors.Inc()
+		} else {
+			metrics.FramesDrawn.Inc()
+		}
+	}()
+
+	err = ssd1306.WriteCmd([]byte{
+		COLUMN_ADDRESS, 0, byte(ssd1306.width - 1),
+		PAGE_ADDRESS, 0, byte(ssd1306.height/8 - 1)})
+	if err != nil {
+		return
+	}
+	n += 7
+
+	raw, _ := ssd1306.i2cbus.(i2c.RawWriter)
+	for i := 0; i < len(frame); i += ssd1306.chunk {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		end := i + ssd1306.chunk
+		if end > len(frame) {
+			end = len(frame)
+		}
+
+		if raw != nil {
+			// One message: the data control byte, then the data
+			ssd1306.rawbuf = append(append(ssd1306.rawbuf[:0], 0x40), frame[i:end]...)
+			err = raw.WriteRaw(ssd1306.rawbuf)
+		} else {
+			err = ssd1306.WriteData(frame[i:end])
+		}
+		if err != nil {
+			return
 		}
+		n += 1 + end - i
 	}
 	return
 }
 
+// An asyncDrawer sends frames to the display in the background.  Only the
+// latest frame queued is kept, so a render loop which outpaces the bus skips
+// frames rather than falling behind.
+type asyncDrawer struct {
+	lock    sync.Mutex
+	frame   []byte
+	pending bool
+	err     error
+	// out is the frame being sent, used only by the sender
+	out []byte
+	// loop sends frames at a fixed rate, if one was given; otherwise
+	// runAsync sends each frame as it is queued
+	loop *sched.Loop
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartAsync makes Draw queue frames to be sent in the background rather
+// than sending them itself, so that rendering isn't held up by the bus; a
+// full frame takes about 25ms at 400kHz.  If fps is set, the latest frame is
+// sent on each tick of a sched.Loop at that rate, skipping ticks if the bus
+// falls behind.  If fps is zero, each frame is sent as soon as the bus
+// allows.
+func (ssd1306 *SSD1306) StartAsync(fps float64) (err error) {
+	if fps < 0 {
+		err = fmt.Errorf("Invalid frame rate: %g", fps)
+		return
+	}
+	ssd1306.asyncLock.Lock()
+	defer ssd1306.asyncLock.Unlock()
+	ssd1306.stopAsync()
+
+	a := &asyncDrawer{
+		frame: make([]byte, len(ssd1306.buf)),
+		out:   make([]byte, len(ssd1306.buf)),
+		wake:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if fps > 0 {
+		period := time.Duration(float64(time.Second) / fps)
+		a.loop, err = sched.New(period, sched.Options{}, func(sched.Tick) error {
+			ssd1306.flush(a)
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	} else {
+		go ssd1306.runAsync(a)
+	}
+	ssd1306.async = a
+
+	return
+}
+
+// StopAsync sends any frame still queued, stops the background drawing and
+// makes Draw synchronous again.  It returns any error from the last frames
+// sent.
+func (ssd1306 *SSD1306) StopAsync() (err error) {
+	ssd1306.asyncLock.Lock()
+	defer ssd1306.asyncLock.Unlock()
+	return ssd1306.stopAsync()
+}
+
+// stopAsync is StopAsync, with asyncLock held.
+func (ssd1306 *SSD1306) stopAsync() (err error) {
+	a := ssd1306.async
+	if a == nil {
+		return
+	}
+	ssd1306.async = nil
+	if a.loop != nil {
+		a.loop.Stop()
+	} else {
+		close(a.stop)
+		<-a.done
+	}
+	ssd1306.flush(a)
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.err
+}
+
+// queue copies a frame to be sent, replacing any frame not yet sent, and
+// returns the error from the last frame sent, if any.
+func (a *asyncDrawer) queue(frame []byte) (err error) {
+	a.lock.Lock()
+	copy(a.frame, frame)
+	a.pending = true
+	err, a.err = a.err, nil
+	a.lock.Unlock()
+
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+
+	return
+}
+
+// flush sends the frame queued, if any, keeping any error for queue to
+// return.
+func (ssd1306 *SSD1306) flush(a *asyncDrawer) {
+	a.lock.Lock()
+	pending := a.pending
+	copy(a.out, a.frame)
+	a.pending = false
+	a.lock.Unlock()
+
+	if !pending {
+		return
+	}
+	if err := ssd1306.send(context.Background(), a.out); err != nil {
+		a.lock.Lock()
+		a.err = err
+		a.lock.Unlock()
+	}
+}
+
+// runAsync sends each frame as it is queued, until stopped.
+func (ssd1306 *SSD1306) runAsync(a *asyncDrawer) {
+	defer close(a.done)
+	for {
+		select {
+		case <-a.wake:
+			ssd1306.flush(a)
+		case <-a.stop:
+			return
+		}
+	}
+}
+
 func (ssd1306 *SSD1306) WriteCmd(cmd []byte) (err error) {
 	if ssd1306.iface == IFACE_I2C {
 		var dc byte
@@ -222,6 +586,13 @@ func (ssd1306 *SSD1306) WriteData(data []byte) (err error) {
 	return
 }
 
+// Size returns the width and height of the display in pixels.
+func (ssd1306 *SSD1306) Size() (width, height int) {
+	return ssd1306.width, ssd1306.height
+}
+
+// Clear fills the framebuffer, or only the clip rectangle if one is set,
+// with a colour.
 func (ssd1306 *SSD1306) Clear(c color.Gray16) {
 	var block byte
 	if c == color.White {
@@ -229,13 +600,33 @@ func (ssd1306 *SSD1306) Clear(c color.Gray16) {
 	} else {
 		block = 0x00
 	}
-	for i := 0; i < len(ssd1306.buf); i++ {
-		ssd1306.buf[i] = block
+	if !ssd1306.clipped {
+		for i := 0; i < len(ssd1306.buf); i++ {
+			ssd1306.buf[i] = block
+		}
+		return
+	}
+
+	r := ssd1306.clip
+	for page := 0; page < ssd1306.height/8; page++ {
+		mask := ssd1306.pageMask(page)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			element := ssd1306.width*page + x
+			ssd1306.buf[element] = ssd1306.buf[element]&^mask | block&mask
+		}
 	}
 }
 
-func (ssd1306 *SSD1306) Point(x, y int, c color.Gray16) {
+// checkBounds returns ErrOutOfBounds if a point is off the display.
+func (ssd1306 *SSD1306) checkBounds(x, y int) (err error) {
 	if x >= ssd1306.width || y >= ssd1306.height || x < 0 || y < 0 {
+		err = fmt.Errorf("%w: (%d, %d)", ErrOutOfBounds, x, y)
+	}
+	return
+}
+
+func (ssd1306 *SSD1306) Point(x, y int, c color.Gray16) {
+	if !ssd1306.visible(x, y) {
 		return
 	}
 
@@ -321,6 +712,26 @@ func (ssd1306 *SSD1306) Circle(x0, y0, radius int, c color.Gray16) {
 func (ssd1306 *SSD1306) Rectangle(x0, y0, x1, y1 int, c color.Gray16) {
 	switch {
 	// Ignore backwards rectangles
+	case x0 > x1 || y0 > y1:
+		return
+	}
+
+	// Keep within the clip rectangle, which is always on the display
+	r := ssd1306.Clip()
+	if x0 < r.Min.X {
+		x0 = r.Min.X
+	}
+	if x1 > r.Max.X - 1 {
+		x1 = r.Max.X - 1
+	}
+	if y0 < r.Min.Y {
+		y0 = r.Min.Y
+	}
+	if y1 > r.Max.Y - 1 {
+		y1 = r.Max.Y - 1
+	}
+
+	switch {
 	case x0 > x1 || y0 > y1:
 		return
 	// If the rectangle is a line, draw it as one
@@ -369,62 +780,102 @@ func (ssd1306 *SSD1306) Rectangle(x0, y0, x1, y1 int, c color.Gray16) {
 	}
 }
 
-// TODO: make this return an error instead of an int
-func (ssd1306 *SSD1306) Char(x, y int, c color.Gray16, r rune) int {
-	bufi := (ssd1306.width*(y/8)) + x
-	bufiup := bufi - ssd1306.width
+// SetOpaque sets whether Char and String fill each character cell with the
+// opposite colour before drawing the glyph, so that text drawn over other
+// text or graphics leaves nothing of them behind.  It is off by default.
+func (ssd1306 *SSD1306) SetOpaque(opaque bool) {
+	ssd1306.opaque = opaque
+}
 
-    if x >= ssd1306.width || y >= ssd1306.height {
-        return -1
+// Char draws a character of the built in font with the bottom left corner
+// of its 6x8 cell at (x, y), as it always has: bit b of each column of the
+// glyph is drawn on row y-7+b.  This differs from display.Char, which takes
+// the top left corner; to draw at a top left corner (x, top), pass
+// top+display.CharHeight-1.  A cell whose bottom row is at a y that is one
+// less than a multiple of 8 lies in a single page of the framebuffer;
+// others are split over two.  Cells partly off the display or outside the
+// clip are clipped.  An error wrapping display.ErrUnsupportedRune is
+// returned for characters outside ASCII, and one wrapping ErrOutOfBounds if
+// the cell is wholly off the display.
+func (ssd1306 *SSD1306) Char(x, y int, c color.Gray16, r rune) (err error) {
+	if r < 0 || r > 127 {
+		err = fmt.Errorf("%w: %q", display.ErrUnsupportedRune, r)
+		return
+	}
+	top := y - (display.CharHeight - 1)
+	cell := image.Rect(x, top, x + display.CharWidth, top + display.CharHeight)
+	if !cell.Overlaps(image.Rect(0, 0, ssd1306.width, ssd1306.height)) {
+		err = fmt.Errorf("%w: (%d, %d)", ErrOutOfBounds, x, y)
+		return
 	}
 
-    if r < 0 || r > 127 {
-        return -1
+	// The cell covers rows shift to shift+7 of page and the one below,
+	// rounding down so that negative rows work too
+	page := top / 8
+	if top < 0 && top % 8 != 0 {
+		page--
 	}
+	shift := uint(top - page * 8)
 
-	if bufi < ssd1306.width * ssd1306.height / 8 && bufi >= 0 {
-		for i := 0; i < 5 && x + i < ssd1306.width; i++ {
-			if c == color.White {
-				ssd1306.buf[bufi+i] |= font[uint((5*int(r))+i)] >> uint(7 - y % 8)
-			} else {
-				ssd1306.buf[bufi+i] &^= font[uint((5*int(r))+i)] >> uint(7 - y % 8)
+	clip := ssd1306.Clip()
+	for i := 0; i < display.CharWidth; i++ {
+		if x + i < clip.Min.X || x + i >= clip.Max.X {
+			continue
+		}
+		var glyph uint16
+		if i < 5 {
+			glyph = uint16(display.Font[5*int(r)+i]) << shift
+		}
+		// Only the glyph's own pixels are drawn, unless opaque
+		cellMask := glyph
+		if ssd1306.opaque {
+			cellMask = uint16(0xff) << shift
+		}
+		bits := glyph
+		if c != color.White {
+			bits = ^glyph
+		}
+
+		for p := 0; p < 2; p++ {
+			if page + p < 0 || page + p >= ssd1306.height / 8 {
+				continue
 			}
+			mask := byte(cellMask >> uint(8*p)) & ssd1306.pageMask(page + p)
+			element := ssd1306.width*(page + p) + x + i
+			ssd1306.buf[element] = ssd1306.buf[element]&^mask | byte(bits >> uint(8*p))&mask
 		}
 	}
-    if bufiup < ssd1306.width * ssd1306.height / 8 && bufiup >= 0 {
-		for i := 0; i < 5 && x + i < ssd1306.width; i++ {
-            if c == color.White {
-                ssd1306.buf[bufiup+i] |= font[uint((5*int(r))+i)] << uint(1 + y % 8)
-            } else {
-                ssd1306.buf[bufiup+i] &^= font[uint((5*int(r))+i)] << uint(1 + y % 8)
-			}
-        }
-    }
 
-    return 0
+	return
 }
 
-func (ssd1306 *SSD1306) String(x, y int, c color.Gray16, s string) (rv int) {
+// String draws s from left to right with the bottom left corner of its
+// first character cell at (x, y), as Char does.  Newlines and vertical tabs
+// move up a line, as they always have, carriage returns and newlines return
+// to x, and backspaces move back a character.  Every character is drawn even if some fail, and
+// the first error is returned.
+func (ssd1306 *SSD1306) String(x, y int, c color.Gray16, s string) (err error) {
 	xx := x
 	yy := y
-	rv = 0
 
 	for _, ch := range s {
 		switch ch {
 		case '\n':
-            xx = x
-            yy -= 8
+			xx = x
+			yy -= display.CharHeight
 		case '\r':
-            xx = x
+			xx = x
 		case '\v':
-            yy -= 8
+			yy -= display.CharHeight
 		case '\b':
-            xx -= 6
+			xx -= display.CharWidth
 		default:
-			rv = ssd1306.Char(xx, yy, c, ch)
-			xx += 6
-        }
-    }
+			if e := ssd1306.Char(xx, yy, c, ch); e != nil && err == nil {
+				err = e
+			}
+			xx += display.CharWidth
+		}
+	}
 
-    return rv;
+	return
 }
@@ -0,0 +1,105 @@
+package ssd1306_test
+
+import (
+	"github.com/Ratfink/gopherbone/mock"
+	"github.com/Ratfink/gopherbone/ssd1306"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newDisplay returns an SSD1306 driver talking to a simulated display.
+func newDisplay(t *testing.T) (d *ssd1306.SSD1306, sim *mock.SSD1306) {
+	sim = mock.NewSSD1306(128, 64)
+	d, err := ssd1306.NewConn(sim, nil, 128, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestAsyncConcurrent(t *testing.T) {
+	d, sim := newDisplay(t)
+	d.Point(5, 6, color.Gray16{0xffff})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if err := d.Draw(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			d.StartAsync(float64(1000 * (i % 2)))
+			d.StopAsync()
+		}
+	}()
+	wg.Wait()
+
+	// Whichever way the last frame went, it arrived
+	if err := d.StopAsync(); err != nil {
+		t.Fatal(err)
+	}
+	if sim.Image().GrayAt(5, 6).Y == 0 {
+		t.Errorf("Frame not drawn")
+	}
+
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer wg.Done()
+			d.Close()
+		}()
+	}
+	wg.Wait()
+	if sim.On() {
+		t.Errorf("Display on after Close")
+	}
+}
+
+func TestAsyncRate(t *testing.T) {
+	for _, fps := range []float64{50, 0} {
+		d, sim := newDisplay(t)
+		d.ResetStats()
+		if err := d.StartAsync(fps); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		for i := 0; i < 40; i++ {
+			d.Clear(color.Gray16{})
+			d.Point(i, 0, color.Gray16{0xffff})
+			if err := d.Draw(); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		elapsed := time.Since(start)
+		if err := d.StopAsync(); err != nil {
+			t.Fatal(err)
+		}
+
+		// A frame on each tick, and one more on stopping, or most of
+		// them if the rate isn't limited
+		max := uint64(40)
+		if fps > 0 {
+			max = uint64(elapsed.Seconds()*fps) + 1
+		}
+		if stats := d.Stats(); stats.Frames != 40 || stats.Sent < 2 || stats.Sent > max {
+			t.Errorf("%g fps: Sent %d of %d frames, want at most %d", fps, stats.Sent, stats.Frames, max)
+		}
+		// The last frame is always sent
+		if img := sim.Image(); img.GrayAt(39, 0).Y == 0 || img.GrayAt(38, 0).Y != 0 {
+			t.Errorf("%g fps: Last frame not drawn", fps)
+		}
+		d.Close()
+	}
+}
@@ -0,0 +1,576 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package stepper drives stepper motors, either directly through four GPIOs
+// and a driver such as the ULN2003 or L298, or through a step/dir driver board
+// such as the A4988 or DRV8825.  Moves are made in the background with
+// trapezoidal acceleration, so MoveTo returns immediately.
+package stepper
+
+import (
+	"context"
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/pwm"
+	"math"
+	"sync"
+	"time"
+)
+
+// A Mode selects the coil sequence used for four-wire steppers.
+type Mode int
+
+// Coil sequences.  WaveDrive energizes one coil at a time, using the least
+// power; FullStep energizes two at a time for more torque; HalfStep
+// alternates between the two, doubling the resolution.
+const (
+	WaveDrive Mode = iota
+	FullStep
+	HalfStep
+)
+
+var sequences = map[Mode][][4]int{
+	WaveDrive: {
+		{1, 0, 0, 0},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	},
+	FullStep: {
+		{1, 1, 0, 0},
+		{0, 1, 1, 0},
+		{0, 0, 1, 1},
+		{1, 0, 0, 1},
+	},
+	HalfStep: {
+		{1, 0, 0, 0},
+		{1, 1, 0, 0},
+		{0, 1, 0, 0},
+		{0, 1, 1, 0},
+		{0, 0, 1, 0},
+		{0, 0, 1, 1},
+		{0, 0, 0, 1},
+		{1, 0, 0, 1},
+	},
+}
+
+// pwmTick is how often the step frequency is updated when the step signal is
+// generated by a PWM channel.
+const pwmTick = 10 * time.Millisecond
+
+// A Stepper is a stepper motor.  Positions are counted in steps, which for
+// step/dir boards are whatever microsteps the board has been configured for.
+type Stepper struct {
+	coils    []gpio.DigitalPin
+	sequence [][4]int
+	index    int
+
+	stepPin *gpio.GPIO
+	stepPWM pwm.Channel
+	dirPin  *gpio.GPIO
+
+	lock     sync.Mutex
+	cond     *sync.Cond
+	position int
+	target   int
+	maxSpeed float64
+	accel    float64
+	speed    float64
+	dir      int
+	closed   bool
+	err      error
+	done     chan struct{}
+}
+
+func newStepper() *Stepper {
+	stepper := &Stepper{
+		maxSpeed: 200,
+		accel:    400,
+		done:     make(chan struct{}),
+	}
+	stepper.cond = sync.NewCond(&stepper.lock)
+	return stepper
+}
+
+// output exports a pin as an output, unexporting it again on failure.
+func output(pin int) (g *gpio.GPIO, err error) {
+	g, err = gpio.Export(pin)
+	if err != nil {
+		return
+	}
+	if err = g.SetDirection(gpio.Out); err != nil {
+		g.Unexport()
+		g = nil
+	}
+
+	return
+}
+
+// unexport unexports pins, ignoring errors, to release them on failure.
+func unexport(pins ...gpio.DigitalPin) {
+	for _, pin := range pins {
+		pin.Unexport()
+	}
+}
+
+// New creates a Stepper driven directly through four GPIO pins, one for each
+// coil input of the driver, using the given coil sequence.
+func New(pins [4]int, mode Mode) (stepper *Stepper, err error) {
+	if _, ok := sequences[mode]; !ok {
+		err = fmt.Errorf("Invalid mode: %d", mode)
+		return
+	}
+
+	var coils [4]gpio.DigitalPin
+	for i, pin := range pins {
+		if coils[i], err = gpio.Export(pin); err != nil {
+			unexport(coils[:i]...)
+			return nil, err
+		}
+	}
+
+	return NewPins(coils, mode)
+}
+
+// NewPins is like New, but drives coil pins which are already exported, such
+// as pins on an I/O expander or mocks for testing.  They are set as outputs,
+// and unexported by Close, or at once if any of them can't be set as an
+// output.
+func NewPins(pins [4]gpio.DigitalPin, mode Mode) (stepper *Stepper, err error) {
+	sequence, ok := sequences[mode]
+	if !ok {
+		err = fmt.Errorf("Invalid mode: %d", mode)
+		return
+	}
+
+	stepper = newStepper()
+	stepper.sequence = sequence
+	for _, pin := range pins {
+		if err = pin.SetDirection(gpio.Out); err != nil {
+			unexport(pins[:]...)
+			return nil, err
+		}
+		stepper.coils = append(stepper.coils, pin)
+	}
+
+	go stepper.runSteps()
+
+	return
+}
+
+// NewStepDir creates a Stepper driven by a step/dir driver board, with the
+// step signal generated by toggling a GPIO.  This gives exact positioning, but
+// sysfs GPIO limits the speed to a few thousand steps per second.
+func NewStepDir(stepPin, dirPin int) (stepper *Stepper, err error) {
+	stepper = newStepper()
+	if stepper.stepPin, err = output(stepPin); err != nil {
+		return nil, err
+	}
+	if stepper.dirPin, err = output(dirPin); err != nil {
+		stepper.stepPin.Unexport()
+		return nil, err
+	}
+
+	go stepper.runSteps()
+
+	return
+}
+
+// NewStepDirPWM creates a Stepper driven by a step/dir driver board, with the
+// step signal generated by a PWM channel.  This allows much higher speeds than
+// NewStepDir, but the position is estimated from the step frequency and time,
+// so it may drift by a few steps over each move.
+func NewStepDirPWM(step pwm.Channel, dirPin int) (stepper *Stepper, err error) {
+	stepper = newStepper()
+	stepper.stepPWM = step
+	if stepper.dirPin, err = output(dirPin); err != nil {
+		return nil, err
+	}
+	if err = step.Disable(); err != nil {
+		stepper.dirPin.Unexport()
+		return nil, err
+	}
+
+	go stepper.runPWM()
+
+	return
+}
+
+// SetSpeed sets the maximum speed in steps per second.
+func (stepper *Stepper) SetSpeed(stepsPerSecond float64) (err error) {
+	if stepsPerSecond <= 0 {
+		err = fmt.Errorf("Invalid speed: %g", stepsPerSecond)
+		return
+	}
+	stepper.lock.Lock()
+	defer stepper.lock.Unlock()
+	stepper.maxSpeed = stepsPerSecond
+
+	return
+}
+
+// SetAcceleration sets the acceleration and deceleration in steps per second
+// squared.  Zero disables ramping, so moves run at full speed throughout.
+func (stepper *Stepper) SetAcceleration(stepsPerSecond2 float64) (err error) {
+	if stepsPerSecond2 < 0 {
+		err = fmt.Errorf("Invalid acceleration: %g", stepsPerSecond2)
+		return
+	}
+	stepper.lock.Lock()
+	defer stepper.lock.Unlock()
+	stepper.accel = stepsPerSecond2
+
+	return
+}
+
+// MoveTo starts moving the motor to an absolute position and returns
+// immediately.  A new MoveTo may be issued while a move is in progress; the
+// motor decelerates first if it has to change direction.
+func (stepper *Stepper) MoveTo(position int) {
+	stepper.lock.Lock()
+	defer stepper.lock.Unlock()
+	stepper.target = position
+	stepper.cond.Broadcast()
+}
+
+// Move starts moving the motor by a number of steps relative to its current
+// target.
+func (stepper *Stepper) Move(steps int) {
+	stepper.lock.Lock()
+	defer stepper.lock.Unlock()
+	stepper.target += steps
+	stepper.cond.Broadcast()
+}
+
+// Stop stops the motor as quickly as the acceleration allows.
+func (stepper *Stepper) Stop() {
+	stepper.lock.Lock()
+	defer stepper.lock.Unlock()
+	stepper.target = stepper.position
+	if stepper.accel > 0 {
+		stopping := stepper.speed * stepper.speed / (2 * stepper.accel)
+		stepper.target += stepper.dir * int(math.Ceil(stopping))
+	}
+	stepper.cond.Broadcast()
+}
+
+// Position returns the motor's current position.
+func (stepper *Stepper) Position() int {
+	stepper.lock.Lock()
+	defer stepper.lock.Unlock()
+	return stepper.position
+}
+
+// SetPosition sets the current position without moving the motor, for
+// example after homing against a limit switch.
+func (stepper *Stepper) SetPosition(position int) {
+	stepper.lock.Lock()
+	defer stepper.lock.Unlock()
+	stepper.position = position
+	stepper.target = position
+	stepper.cond.Broadcast()
+}
+
+// Wait blocks until the motor reaches its target, and returns any error which
+// stopped it from getting there.
+func (stepper *Stepper) Wait() (err error) {
+	return stepper.WaitContext(context.Background())
+}
+
+// WaitContext is like Wait, but if ctx is done first it stops the motor and
+// returns ctx.Err().  The motor may still be decelerating when WaitContext
+// returns; call Wait to wait for it to come to rest.
+func (stepper *Stepper) WaitContext(ctx context.Context) (err error) {
+	// Wake the wait below if ctx is done
+	waited := make(chan struct{})
+	defer close(waited)
+	go func() {
+		select {
+		case <-ctx.Done():
+			stepper.lock.Lock()
+			stepper.cond.Broadcast()
+			stepper.lock.Unlock()
+		case <-waited:
+		}
+	}()
+
+	stepper.lock.Lock()
+	for stepper.position != stepper.target && !stepper.closed && stepper.err == nil && ctx.Err() == nil {
+		stepper.cond.Wait()
+	}
+	err = stepper.err
+	cancelled := err == nil && stepper.position != stepper.target && !stepper.closed
+	stepper.lock.Unlock()
+
+	if cancelled {
+		stepper.Stop()
+		err = ctx.Err()
+	}
+
+	return
+}
+
+// MoveToContext moves the motor to an absolute position and waits for it to
+// get there, as with MoveTo and WaitContext.
+func (stepper *Stepper) MoveToContext(ctx context.Context, position int) error {
+	stepper.MoveTo(position)
+	return stepper.WaitContext(ctx)
+}
+
+// Release de-energizes a four-wire stepper's coils so it stops drawing
+// current when idle.  It has no effect on step/dir boards, whose enable pin is
+// left to the user.
+func (stepper *Stepper) Release() (err error) {
+	stepper.lock.Lock()
+	defer stepper.lock.Unlock()
+	for _, coil := range stepper.coils {
+		if err = coil.SetValue(0); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Close stops the motor immediately and unexports its pins.
+func (stepper *Stepper) Close() (err error) {
+	stepper.lock.Lock()
+	stepper.closed = true
+	stepper.cond.Broadcast()
+	stepper.lock.Unlock()
+	<-stepper.done
+
+	if stepper.stepPWM != nil {
+		stepper.stepPWM.Disable()
+	}
+	pins := append([]gpio.DigitalPin(nil), stepper.coils...)
+	for _, g := range []*gpio.GPIO{stepper.stepPin, stepper.dirPin} {
+		if g != nil {
+			pins = append(pins, g)
+		}
+	}
+	for _, g := range pins {
+		if e := g.Unexport(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+// waitForMove blocks until there is somewhere to go, returning the signed
+// distance to the target and the motion limits, or false once closed.
+func (stepper *Stepper) waitForMove(moving bool) (dist int, maxSpeed, accel float64, ok bool) {
+	stepper.lock.Lock()
+	defer stepper.lock.Unlock()
+
+	for !moving && stepper.position == stepper.target && !stepper.closed {
+		stepper.cond.Wait()
+	}
+
+	return stepper.target - stepper.position, stepper.maxSpeed, stepper.accel, !stepper.closed
+}
+
+// ramp works out the speed for the next part of a move.  speed and dir are
+// the current speed and direction, dist the signed distance to the target and
+// dv the most the speed may change.  A motor moving away from the target
+// decelerates to a stop before it turns around.
+func ramp(speed float64, dir, dist int, maxSpeed, accel, dv float64) (float64, int) {
+	if dist == 0 {
+		return 0, 0
+	}
+	want := 1
+	if dist < 0 {
+		want = -1
+	}
+	if accel <= 0 {
+		return maxSpeed, want
+	}
+
+	stopping := speed * speed / (2 * accel)
+	if (dir != 0 && want != dir) || math.Abs(float64(dist)) <= stopping {
+		speed -= dv
+		if speed <= 0 {
+			return 0, 0
+		}
+		return speed, dir
+	}
+
+	if dir == 0 {
+		dir = want
+	}
+	return math.Min(speed+dv, maxSpeed), dir
+}
+
+func (stepper *Stepper) fail(err error) {
+	stepper.lock.Lock()
+	defer stepper.lock.Unlock()
+	stepper.err = err
+	stepper.target = stepper.position
+	stepper.speed, stepper.dir = 0, 0
+	stepper.cond.Broadcast()
+}
+
+// runSteps moves a stepper whose steps are made one at a time.
+func (stepper *Stepper) runSteps() {
+	defer close(stepper.done)
+
+	var speed float64
+	var dir int
+	var next time.Time
+
+	for {
+		dist, maxSpeed, accel, ok := stepper.waitForMove(speed > 0)
+		if !ok {
+			return
+		}
+		if speed == 0 {
+			next = time.Now()
+		}
+
+		// With v^2 = u^2 + 2as over one step, the speed change is at
+		// most sqrt(2a) when starting from rest
+		dv := math.Sqrt(speed*speed+2*accel) - speed
+		speed, dir = ramp(speed, dir, dist, maxSpeed, accel, dv)
+		if speed == 0 {
+			stepper.lock.Lock()
+			stepper.speed, stepper.dir = 0, 0
+			stepper.lock.Unlock()
+			continue
+		}
+
+		if err := stepper.step(dir); err != nil {
+			stepper.fail(err)
+			speed, dir = 0, 0
+			continue
+		}
+
+		stepper.lock.Lock()
+		stepper.position += dir
+		stepper.speed, stepper.dir = speed, dir
+		stepper.cond.Broadcast()
+		stepper.lock.Unlock()
+
+		next = next.Add(time.Duration(float64(time.Second) / speed))
+		time.Sleep(time.Until(next))
+	}
+}
+
+// step makes a single step in the given direction.
+func (stepper *Stepper) step(dir int) (err error) {
+	if stepper.coils != nil {
+		n := len(stepper.sequence)
+		stepper.index = (stepper.index + dir + n) % n
+		for i, coil := range stepper.coils {
+			if err = coil.SetValue(stepper.sequence[stepper.index][i]); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	if err = stepper.setDir(dir); err != nil {
+		return
+	}
+	if err = stepper.stepPin.SetValue(1); err != nil {
+		return
+	}
+	return stepper.stepPin.SetValue(0)
+}
+
+func (stepper *Stepper) setDir(dir int) error {
+	if dir > 0 {
+		return stepper.dirPin.SetValue(1)
+	}
+	return stepper.dirPin.SetValue(0)
+}
+
+// runPWM moves a stepper whose step signal comes from a PWM channel, updating
+// the frequency every pwmTick and estimating the distance travelled.
+func (stepper *Stepper) runPWM() {
+	defer close(stepper.done)
+
+	var speed, travelled float64
+	var dir int
+	ticker := time.NewTicker(pwmTick)
+	defer ticker.Stop()
+
+	for {
+		dist, maxSpeed, accel, ok := stepper.waitForMove(speed > 0)
+		if !ok {
+			return
+		}
+
+		lastDir := dir
+		speed, dir = ramp(speed, dir, dist, maxSpeed, accel, accel*pwmTick.Seconds())
+		err := stepper.setPWM(speed, dir, lastDir)
+		if err != nil {
+			stepper.fail(err)
+			speed, dir = 0, 0
+			continue
+		}
+		if speed == 0 {
+			travelled = 0
+			stepper.lock.Lock()
+			stepper.speed, stepper.dir = 0, 0
+			stepper.lock.Unlock()
+			continue
+		}
+
+		<-ticker.C
+
+		travelled += speed * pwmTick.Seconds()
+		stepper.lock.Lock()
+		steps := int(travelled)
+		travelled -= float64(steps)
+		// Never overshoot the target on estimate alone
+		if remaining := stepper.target - stepper.position; dir*remaining >= 0 && steps > dir*remaining {
+			steps = dir * remaining
+		}
+		stepper.position += dir * steps
+		stepper.speed, stepper.dir = speed, dir
+		stepper.cond.Broadcast()
+		stepper.lock.Unlock()
+	}
+}
+
+func (stepper *Stepper) setPWM(speed float64, dir, lastDir int) (err error) {
+	if speed == 0 {
+		return stepper.stepPWM.Disable()
+	}
+	if dir != lastDir {
+		if err = stepper.setDir(dir); err != nil {
+			return
+		}
+	}
+	if err = stepper.stepPWM.SetFrequency(speed); err != nil {
+		return
+	}
+	if lastDir == 0 {
+		if err = stepper.stepPWM.SetDuty(0.5); err != nil {
+			return
+		}
+		err = stepper.stepPWM.Enable()
+	}
+
+	return
+}
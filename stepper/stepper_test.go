@@ -0,0 +1,218 @@
+package stepper
+
+import (
+	"context"
+	"errors"
+	"github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/mock"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRamp(t *testing.T) {
+	tests := []struct {
+		name                string
+		speed               float64
+		dir, dist           int
+		maxSpeed, accel, dv float64
+		wantSpeed           float64
+		wantDir             int
+	}{
+		{"arrived", 50, 1, 0, 200, 400, 10, 0, 0},
+		{"no ramping", 0, 0, -5, 200, 0, 10, 200, -1},
+		{"start forward", 0, 0, 100, 200, 400, 10, 10, 1},
+		{"start backward", 0, 0, -100, 200, 400, 10, 10, -1},
+		{"accelerate", 100, 1, 100, 200, 400, 10, 110, 1},
+		{"cruise", 195, -1, -1000, 200, 400, 10, 200, -1},
+		{"slow down for target", 100, 1, 10, 200, 400, 10, 90, 1},
+		{"turn around", 50, 1, -100, 200, 400, 10, 40, 1},
+		{"stop to turn around", 5, -1, 100, 200, 400, 10, 0, 0},
+	}
+	for _, test := range tests {
+		speed, dir := ramp(test.speed, test.dir, test.dist, test.maxSpeed, test.accel, test.dv)
+		if speed != test.wantSpeed || dir != test.wantDir {
+			t.Errorf("%s: ramp = %g, %d, want %g, %d", test.name, speed, dir, test.wantSpeed, test.wantDir)
+		}
+	}
+}
+
+func TestRampProfile(t *testing.T) {
+	// Step through moves as runSteps does, without the waiting
+	tests := []struct {
+		name            string
+		speed           float64
+		dir, dist       int
+		maxSpeed, accel float64
+	}{
+		{"trapezoid", 0, 0, 1000, 200, 400},
+		{"triangle", 0, 0, 20, 200, 400},
+		{"backward", 0, 0, -300, 500, 1000},
+		{"one step", 0, 0, 1, 200, 400},
+		{"reverse while moving", 200, 1, -100, 200, 400},
+		{"overshoot", 200, 1, 10, 200, 400},
+	}
+	for _, test := range tests {
+		pos, speed, dir := 0, test.speed, test.dir
+		peak, n := 0.0, 0
+		for ; n < 10000; n++ {
+			dist := test.dist - pos
+			if dist == 0 && speed == 0 {
+				break
+			}
+			dv := math.Sqrt(speed*speed+2*test.accel) - speed
+			last := speed
+			speed, dir = ramp(speed, dir, dist, test.maxSpeed, test.accel, dv)
+			if speed > test.maxSpeed {
+				t.Errorf("%s: Speed %g over the maximum", test.name, speed)
+			}
+			// Stopping from a crawl at the target is allowed
+			if speed > 0 && math.Abs(speed*speed-last*last) > 2*test.accel+1e-6 {
+				t.Errorf("%s: Speed changed from %g to %g in one step", test.name, last, speed)
+			}
+			if speed > 0 {
+				pos += dir
+				peak = math.Max(peak, speed)
+			}
+		}
+		if pos != test.dist || speed != 0 {
+			t.Errorf("%s: Stopped at %d with speed %g after %d steps, want %d", test.name, pos, speed, n, test.dist)
+		}
+		// From rest, the peak is where accelerating and decelerating
+		// meet, if that's below the maximum
+		if test.speed == 0 && test.dist*test.dist >= 400 {
+			want := math.Min(test.maxSpeed, math.Sqrt(test.accel*math.Abs(float64(test.dist))))
+			if math.Abs(peak-want) > 0.05*want {
+				t.Errorf("%s: Peak speed %g, want %g", test.name, peak, want)
+			}
+		}
+	}
+}
+
+// newMock returns a four-wire Stepper whose coils are mock pins.
+func newMock(t *testing.T, mode Mode) (stepper *Stepper, pins [4]*mock.Pin) {
+	var coils [4]gpio.DigitalPin
+	for i := range pins {
+		pins[i] = mock.NewPin()
+		coils[i] = pins[i]
+	}
+	stepper, err := NewPins(coils, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestNewPins(t *testing.T) {
+	stepper, pins := newMock(t, HalfStep)
+	for i, pin := range pins {
+		if dir, _ := pin.Direction(); dir != gpio.Out {
+			t.Errorf("Coil %d is an %s", i, dir)
+		}
+	}
+	if err := stepper.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, pin := range pins {
+		if _, err := pin.Value(); !errors.Is(err, gpio.ErrNotExported) {
+			t.Errorf("Coil %d left exported: %v", i, err)
+		}
+	}
+
+	var coils [4]gpio.DigitalPin
+	for i := range coils {
+		coils[i] = mock.NewPin()
+	}
+	if _, err := NewPins(coils, Mode(3)); err == nil {
+		t.Errorf("NewPins accepted an invalid mode")
+	}
+	coils[2].Unexport()
+	if _, err := NewPins(coils, FullStep); !errors.Is(err, gpio.ErrNotExported) {
+		t.Errorf("NewPins with an unexported coil: %v", err)
+	}
+	// The coils it was given are released
+	for i, pin := range coils {
+		if _, err := pin.Value(); !errors.Is(err, gpio.ErrNotExported) {
+			t.Errorf("Coil %d left exported after NewPins failed: %v", i, err)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  Mode
+		moves []int
+		index int
+	}{
+		{"wave drive", WaveDrive, []int{5}, 1},
+		{"full step backward", FullStep, []int{-3}, 1},
+		{"half step", HalfStep, []int{13}, 5},
+		{"there and back", HalfStep, []int{20, -27}, 1},
+		{"full turn", FullStep, []int{8, -4, -4}, 0},
+	}
+	for _, test := range tests {
+		stepper, pins := newMock(t, test.mode)
+		stepper.SetSpeed(2000)
+		stepper.SetAcceleration(20000)
+
+		want := 0
+		for _, m := range test.moves {
+			want += m
+			stepper.Move(m)
+			if err := stepper.Wait(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if pos := stepper.Position(); pos != want {
+			t.Errorf("%s: Position %d, want %d", test.name, pos, want)
+		}
+		for i, pin := range pins {
+			if level := pin.Level(); level != sequences[test.mode][test.index][i] {
+				t.Errorf("%s: Coil %d is %d, want step %d of the sequence", test.name, i, level, test.index)
+			}
+		}
+
+		if err := stepper.Release(); err != nil {
+			t.Fatal(err)
+		}
+		for i, pin := range pins {
+			if pin.Level() != 0 {
+				t.Errorf("%s: Coil %d energized after Release", test.name, i)
+			}
+		}
+		stepper.Close()
+	}
+}
+
+func TestWaitContext(t *testing.T) {
+	stepper, _ := newMock(t, FullStep)
+	defer stepper.Close()
+	stepper.SetSpeed(200)
+	stepper.SetAcceleration(2000)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := stepper.MoveToContext(ctx, 1000); err != context.DeadlineExceeded {
+		t.Errorf("MoveToContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// It comes to rest, short of the target, within the stopping distance
+	if err := stepper.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if pos := stepper.Position(); pos <= 0 || pos >= 1000 {
+		t.Errorf("Stopped at %d", pos)
+	}
+}
+
+func TestFail(t *testing.T) {
+	stepper, pins := newMock(t, WaveDrive)
+	defer stepper.Close()
+	stepper.SetSpeed(2000)
+
+	pins[1].Unexport()
+	stepper.Move(10)
+	if err := stepper.Wait(); !errors.Is(err, gpio.ErrNotExported) {
+		t.Errorf("Wait with a failed coil: %v", err)
+	}
+}
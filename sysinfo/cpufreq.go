@@ -0,0 +1,105 @@
+package sysinfo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CPU frequency governors commonly available.  The BeagleBone's images
+// default to ondemand or performance.
+const (
+	Performance  = "performance"
+	Powersave    = "powersave"
+	Ondemand     = "ondemand"
+	Conservative = "conservative"
+	Userspace    = "userspace"
+)
+
+// Frequency returns the processor's current clock frequency in hertz.
+func Frequency() (hz int64, err error) {
+	khz, err := readInt(CPUFreqPath + "/scaling_cur_freq")
+	if err != nil {
+		return
+	}
+	hz = khz * 1000
+
+	return
+}
+
+// Frequencies returns the clock frequencies the processor can run at, in
+// hertz.  The AM335x's are 300MHz to 1GHz.
+func Frequencies() (hz []int64, err error) {
+	s, err := readString(CPUFreqPath + "/scaling_available_frequencies")
+	if err != nil {
+		return
+	}
+	for _, f := range strings.Fields(s) {
+		var khz int64
+		if khz, err = strconv.ParseInt(f, 10, 64); err != nil {
+			return nil, err
+		}
+		hz = append(hz, khz*1000)
+	}
+
+	return
+}
+
+// Governor returns the name of the cpufreq governor in use.
+func Governor() (string, error) {
+	return readString(CPUFreqPath + "/scaling_governor")
+}
+
+// Governors returns the names of the available cpufreq governors.
+func Governors() (governors []string, err error) {
+	s, err := readString(CPUFreqPath + "/scaling_available_governors")
+	if err != nil {
+		return
+	}
+	governors = strings.Fields(s)
+
+	return
+}
+
+// SetGovernor changes the cpufreq governor.
+func SetGovernor(governor string) error {
+	return writeString(CPUFreqPath+"/scaling_governor", governor)
+}
+
+// SetFrequency fixes the processor's clock at hz, which should be one of
+// Frequencies, by switching to the userspace governor.
+func SetFrequency(hz int64) (err error) {
+	if hz <= 0 {
+		err = fmt.Errorf("Invalid frequency: %d", hz)
+		return
+	}
+	if err = SetGovernor(Userspace); err != nil {
+		return
+	}
+	return writeString(CPUFreqPath+"/scaling_setspeed", strconv.FormatInt(hz/1000, 10))
+}
+
+// SetFrequencyLimits limits the frequencies the governor may choose.
+func SetFrequencyLimits(min, max int64) (err error) {
+	if min <= 0 || max < min {
+		err = fmt.Errorf("Invalid frequency limits: %d, %d", min, max)
+		return
+	}
+
+	// Raise the maximum first if need be, so that the minimum is never
+	// above it
+	cur, err := readInt(CPUFreqPath + "/scaling_max_freq")
+	if err != nil {
+		return
+	}
+	if max/1000 > cur {
+		if err = writeString(CPUFreqPath+"/scaling_max_freq", strconv.FormatInt(max/1000, 10)); err != nil {
+			return
+		}
+		return writeString(CPUFreqPath+"/scaling_min_freq", strconv.FormatInt(min/1000, 10))
+	}
+	if err = writeString(CPUFreqPath+"/scaling_min_freq", strconv.FormatInt(min/1000, 10)); err != nil {
+		return
+	}
+	return writeString(CPUFreqPath+"/scaling_max_freq", strconv.FormatInt(max/1000, 10))
+}
@@ -0,0 +1,120 @@
+package sysinfo
+
+import (
+	"github.com/Ratfink/gopherbone/adc"
+	"path/filepath"
+	"sort"
+)
+
+// A PowerSupply is a source of power known to the kernel, such as the
+// TPS65217 PMIC's AC and USB inputs on the BeagleBone Black.
+type PowerSupply struct {
+	Name string
+	// Type is the kind of supply, such as "Mains", "USB" or "Battery".
+	Type   string
+	Online bool
+	// Status is set for batteries and chargers, to "Charging",
+	// "Discharging", "Full" or "Not charging".
+	Status string
+	// Voltage is in volts, or zero if the supply doesn't report it.
+	Voltage float64
+}
+
+// PowerSupplies returns the power supplies registered with the kernel.
+func PowerSupplies() (supplies []PowerSupply, err error) {
+	dirs, err := filepath.Glob(PowerSupplyPath + "/*")
+	if err != nil {
+		return
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		ps := PowerSupply{Name: filepath.Base(dir)}
+		ps.Type, _ = readString(dir + "/type")
+		ps.Status, _ = readString(dir + "/status")
+		if online, err := readInt(dir + "/online"); err == nil {
+			ps.Online = online != 0
+		} else if present, err := readInt(dir + "/present"); err == nil {
+			ps.Online = present != 0
+		}
+		if uv, err := readInt(dir + "/voltage_now"); err == nil {
+			ps.Voltage = float64(uv) / 1e6
+		}
+		supplies = append(supplies, ps)
+	}
+
+	return
+}
+
+// The BeagleBone Blue measures its 2 cell LiPo battery on AIN6 and its DC
+// jack on AIN5, each through an 11:1 divider.
+const (
+	BLUE_BATTERY_AIN = 6
+	BLUE_JACK_AIN    = 5
+	BLUE_DIVIDER     = 11.0
+)
+
+// Voltages of a 2 cell LiPo at empty and fully charged, used to estimate
+// the charge.
+const (
+	LIPO2_EMPTY = 6.4
+	LIPO2_FULL  = 8.4
+)
+
+// A Battery is the state of the BeagleBone Blue's battery.
+type Battery struct {
+	Voltage float64
+	// Charge estimates the remaining charge from 0 to 1 by the voltage,
+	// which is only a rough guide under load.
+	Charge float64
+	// JackVoltage is the voltage at the DC jack; the battery charges when
+	// it is above about 9V.
+	JackVoltage float64
+	Charging    bool
+}
+
+// BlueBattery returns the state of the BeagleBone Blue's battery.  Charging
+// is taken from the kernel's power supplies if any battery reports its
+// status, and otherwise guessed from the jack voltage.
+func BlueBattery() (bat Battery, err error) {
+	if bat.Voltage, err = readAIN(BLUE_BATTERY_AIN); err != nil {
+		return
+	}
+	if bat.JackVoltage, err = readAIN(BLUE_JACK_AIN); err != nil {
+		return
+	}
+
+	bat.Charge = (bat.Voltage - LIPO2_EMPTY) / (LIPO2_FULL - LIPO2_EMPTY)
+	if bat.Charge < 0 {
+		bat.Charge = 0
+	} else if bat.Charge > 1 {
+		bat.Charge = 1
+	}
+
+	bat.Charging = bat.JackVoltage > 9 && bat.Charge < 1
+	if supplies, e := PowerSupplies(); e == nil {
+		for _, ps := range supplies {
+			if ps.Type == "Battery" && ps.Status != "" {
+				bat.Charging = ps.Status == "Charging"
+				break
+			}
+		}
+	}
+
+	return
+}
+
+func readAIN(n int) (volts float64, err error) {
+	ain, err := adc.Open(n)
+	if err != nil {
+		return
+	}
+	defer ain.Close()
+
+	if volts, err = ain.Voltage(); err != nil {
+		return
+	}
+	volts *= BLUE_DIVIDER
+
+	return
+}
@@ -0,0 +1,156 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package sysinfo reads the state of the system: the processor's temperature,
+// load and clock speed, memory use, and power supplies, including the
+// BeagleBone Blue's battery.  It is meant for status screens and dashboards,
+// such as one drawn on an SSD1306.
+package sysinfo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Paths to the kernel interfaces used, which may be changed for testing.
+var (
+	ProcPath        = "/proc"
+	ThermalPath     = "/sys/class/thermal"
+	CPUFreqPath     = "/sys/devices/system/cpu/cpu0/cpufreq"
+	PowerSupplyPath = "/sys/class/power_supply"
+)
+
+// ErrUnavailable is returned when the kernel doesn't provide a reading, such
+// as the temperature on kernels without a driver for the AM335x's sensor.
+var ErrUnavailable = errors.New("Not available on this system")
+
+// readString returns the contents of a sysfs or procfs file, without the
+// trailing newline.
+func readString(path string) (s string, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	s = strings.TrimSpace(string(b))
+
+	return
+}
+
+func readInt(path string) (value int64, err error) {
+	s, err := readString(path)
+	if err != nil {
+		return
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func writeString(path, s string) error {
+	return os.WriteFile(path, []byte(s), 0666)
+}
+
+// Temperature returns the processor's temperature in degrees Celsius, from
+// the first thermal zone.  The AM335x's bandgap sensor is rough, and many
+// kernels have no driver for it, in which case ErrUnavailable is returned.
+func Temperature() (temp float64, err error) {
+	zones, _ := filepath.Glob(ThermalPath + "/thermal_zone*/temp")
+	for _, zone := range zones {
+		var milli int64
+		if milli, err = readInt(zone); err == nil {
+			temp = float64(milli) / 1000
+			return
+		}
+	}
+	err = ErrUnavailable
+
+	return
+}
+
+// A Load is the system load average: the mean number of runnable processes
+// over the last one, five and fifteen minutes.
+type Load struct {
+	One, Five, Fifteen float64
+}
+
+// LoadAverage returns the system load average.
+func LoadAverage() (load Load, err error) {
+	s, err := readString(ProcPath + "/loadavg")
+	if err != nil {
+		return
+	}
+	n, err := fmt.Sscanf(s, "%g %g %g", &load.One, &load.Five, &load.Fifteen)
+	if n != 3 {
+		err = fmt.Errorf("Bad number of values read from %s/loadavg: %d", ProcPath, n)
+	}
+
+	return
+}
+
+// Uptime returns how long the system has been running.
+func Uptime() (uptime time.Duration, err error) {
+	s, err := readString(ProcPath + "/uptime")
+	if err != nil {
+		return
+	}
+	var secs float64
+	if _, err = fmt.Sscanf(s, "%g", &secs); err != nil {
+		return
+	}
+	uptime = time.Duration(secs * float64(time.Second))
+
+	return
+}
+
+// Memory returns the total memory and the memory available to new programs,
+// in bytes.
+func Memory() (total, available uint64, err error) {
+	s, err := readString(ProcPath + "/meminfo")
+	if err != nil {
+		return
+	}
+
+	var found int
+	for _, line := range strings.Split(s, "\n") {
+		var name string
+		var kb uint64
+		if n, _ := fmt.Sscanf(line, "%s %d kB", &name, &kb); n != 2 {
+			continue
+		}
+		switch name {
+		case "MemTotal:":
+			total = kb * 1024
+			found++
+		case "MemAvailable:":
+			available = kb * 1024
+			found++
+		}
+	}
+	if found != 2 {
+		err = fmt.Errorf("MemTotal or MemAvailable missing from %s/meminfo", ProcPath)
+	}
+
+	return
+}
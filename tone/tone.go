@@ -0,0 +1,211 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package tone plays tones and melodies on a passive piezo buzzer or speaker
+// driven by a PWM channel.  The pitch is set by the PWM period, with the duty
+// fixed at 50% for the loudest, cleanest square wave.
+package tone
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/pwm"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Gap is the silence left at the end of each note of a melody, so that
+// repeated notes can be told apart.
+var Gap = 10 * time.Millisecond
+
+// A Note is a pitch held for a duration.  A Frequency of zero is a rest.
+type Note struct {
+	Frequency float64
+	Duration  time.Duration
+}
+
+// A Tone plays on a PWM channel.
+type Tone struct {
+	ch pwm.Channel
+}
+
+// New returns a Tone playing on the given channel, which is disabled until
+// something is played.
+func New(ch pwm.Channel) (tone *Tone, err error) {
+	if err = ch.Disable(); err != nil {
+		return
+	}
+	tone = &Tone{ch: ch}
+
+	return
+}
+
+// Start starts playing a tone of the given frequency in hertz, until Stop is
+// called.
+func (tone *Tone) Start(hz float64) (err error) {
+	if err = tone.ch.SetFrequency(hz); err != nil {
+		return
+	}
+	if err = tone.ch.SetDuty(0.5); err != nil {
+		return
+	}
+	return tone.ch.Enable()
+}
+
+// Stop silences the tone.
+func (tone *Tone) Stop() error {
+	return tone.ch.Disable()
+}
+
+// Play plays a tone of the given frequency for a duration, blocking until it
+// has finished.
+func (tone *Tone) Play(hz float64, d time.Duration) (err error) {
+	if err = tone.Start(hz); err != nil {
+		return
+	}
+	time.Sleep(d)
+	return tone.Stop()
+}
+
+// PlayMelody plays a sequence of notes, blocking until it has finished or
+// stop is closed.  stop may be nil if the melody should always finish.
+func (tone *Tone) PlayMelody(notes []Note, stop <-chan struct{}) (err error) {
+	defer tone.Stop()
+
+	for _, note := range notes {
+		sound := note.Duration - Gap
+		if sound < 0 {
+			sound = 0
+		}
+		if note.Frequency > 0 {
+			if err = tone.Start(note.Frequency); err != nil {
+				return
+			}
+		}
+		if !wait(sound, stop) {
+			return
+		}
+		if err = tone.Stop(); err != nil {
+			return
+		}
+		if !wait(note.Duration-sound, stop) {
+			return
+		}
+	}
+
+	return
+}
+
+// wait sleeps for d, returning false if stop was closed first.
+func wait(d time.Duration, stop <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+var semitones = map[byte]int{'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11}
+
+// Frequency returns the frequency of a named note in equal temperament with
+// A4 at 440Hz.  Names are a letter, an optional # or b, and an octave number,
+// such as "C4" (middle C), "F#5" or "Bb3".
+func Frequency(name string) (hz float64, err error) {
+	if len(name) < 2 {
+		err = fmt.Errorf("Invalid note: %s", name)
+		return
+	}
+	semitone, ok := semitones[strings.ToUpper(name)[0]]
+	if !ok {
+		err = fmt.Errorf("Invalid note: %s", name)
+		return
+	}
+
+	rest := name[1:]
+	switch rest[0] {
+	case '#':
+		semitone++
+		rest = rest[1:]
+	case 'b':
+		semitone--
+		rest = rest[1:]
+	}
+	octave, err := strconv.Atoi(rest)
+	if err != nil {
+		err = fmt.Errorf("Invalid note: %s", name)
+		return
+	}
+
+	// MIDI note numbers put A4 at 69
+	midi := 12*(octave+1) + semitone
+	hz = 440 * math.Pow(2, float64(midi-69)/12)
+
+	return
+}
+
+// Parse parses a melody written as space-separated notes, each a note name
+// (or R for a rest) and a length as a fraction of a whole note, such as
+// "C4/4 E4/8 G4/8 R/4 C5/2".  A length followed by a dot is dotted, making it
+// half as long again.  tempo is in quarter notes per minute.
+func Parse(melody string, tempo int) (notes []Note, err error) {
+	if tempo <= 0 {
+		err = fmt.Errorf("Invalid tempo: %d", tempo)
+		return
+	}
+	whole := 4 * time.Minute / time.Duration(tempo)
+
+	for _, field := range strings.Fields(melody) {
+		parts := strings.SplitN(field, "/", 2)
+		if len(parts) != 2 {
+			err = fmt.Errorf("Invalid note: %s", field)
+			return
+		}
+
+		var note Note
+		if parts[0] != "R" && parts[0] != "r" {
+			if note.Frequency, err = Frequency(parts[0]); err != nil {
+				return
+			}
+		}
+
+		length := parts[1]
+		dotted := strings.HasSuffix(length, ".")
+		var div int
+		if div, err = strconv.Atoi(strings.TrimSuffix(length, ".")); err != nil || div <= 0 {
+			err = fmt.Errorf("Invalid length: %s", field)
+			return
+		}
+		note.Duration = whole / time.Duration(div)
+		if dotted {
+			note.Duration += note.Duration / 2
+		}
+
+		notes = append(notes, note)
+	}
+
+	return
+}
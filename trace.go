@@ -0,0 +1,144 @@
+package gopherbone
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A TraceKind says what sort of thing a TraceEvent records.
+type TraceKind int
+
+// Kinds of TraceEvent.
+const (
+	// TraceSysfs is a write to a sysfs file, such as a GPIO's value.
+	TraceSysfs TraceKind = iota
+	// TraceI2C is an I2C or SMBus transfer.
+	TraceI2C
+	// TraceSPI is an SPI transfer.
+	TraceSPI
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TraceSysfs:
+		return "sysfs"
+	case TraceI2C:
+		return "i2c"
+	case TraceSPI:
+		return "spi"
+	}
+	return "unknown"
+}
+
+// A TraceEvent records one operation on the hardware, for the function
+// given to SetTracer.
+type TraceEvent struct {
+	Time time.Time
+	Kind TraceKind
+	// Target is what was operated on: a sysfs path, or a bus and address
+	// such as "i2c-2 0x3c" or "spidev1.0".
+	Target string
+	// Op names the operation, such as "write" or "smbus read".
+	Op string
+	// Reg is the register or command byte of an SMBus transfer, or -1.
+	Reg int
+	// Write and Read are the bytes sent and received.
+	Write, Read []byte
+	Duration    time.Duration
+	Err         error
+}
+
+// String formats the event on one line, with the payloads in hex, except
+// sysfs writes, which are text.
+func (e TraceEvent) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s %s", e.Time.Format("15:04:05.000000"), e.Kind, e.Target, e.Op)
+	if e.Reg >= 0 {
+		fmt.Fprintf(&b, " reg 0x%02x", e.Reg)
+	}
+	if e.Write != nil && e.Kind == TraceSysfs {
+		fmt.Fprintf(&b, " %q", e.Write)
+	} else if e.Write != nil {
+		fmt.Fprintf(&b, " w[% x]", e.Write)
+	}
+	if e.Read != nil {
+		fmt.Fprintf(&b, " r[% x]", e.Read)
+	}
+	fmt.Fprintf(&b, " %v", e.Duration)
+	if e.Err != nil {
+		fmt.Fprintf(&b, " error: %v", e.Err)
+	}
+	return b.String()
+}
+
+// tracer holds a tracerFunc, since an atomic.Value can't hold nil.
+var tracer atomic.Value
+
+type tracerFunc struct {
+	f func(TraceEvent)
+}
+
+// SetTracer makes every sysfs write, I2C transfer and SPI transfer made by
+// the GopherBone packages be passed to f, which may be called from several
+// goroutines at once.  A nil f stops tracing.  Payloads are copies, which f
+// may keep.
+func SetTracer(f func(TraceEvent)) {
+	tracer.Store(tracerFunc{f})
+}
+
+// Tracing reports whether a tracer is set, so that callers can skip
+// building events no one will see.
+func Tracing() bool {
+	t, _ := tracer.Load().(tracerFunc)
+	return t.f != nil
+}
+
+// Trace passes an event to the tracer, if there is one, copying its
+// payloads.  The GopherBone packages call it; it is exported so that other
+// drivers can too.
+func Trace(e TraceEvent) {
+	t, _ := tracer.Load().(tracerFunc)
+	if t.f == nil {
+		return
+	}
+	if e.Write != nil {
+		e.Write = append([]byte{}, e.Write...)
+	}
+	if e.Read != nil {
+		e.Read = append([]byte{}, e.Read...)
+	}
+	t.f(e)
+}
+
+// LogTracer returns a tracer for SetTracer that writes each event to w on a
+// line of its own.
+func LogTracer(w io.Writer) func(TraceEvent) {
+	var lock sync.Mutex
+	return func(e TraceEvent) {
+		lock.Lock()
+		fmt.Fprintln(w, e)
+		lock.Unlock()
+	}
+}
+
+// TraceSysfsWrite traces a write of data to a sysfs file which started at
+// start, if a tracer is set.
+func TraceSysfsWrite(start time.Time, path, data string, err error) {
+	if !Tracing() {
+		return
+	}
+	Trace(TraceEvent{
+		Time:     start,
+		Kind:     TraceSysfs,
+		Target:   path,
+		Op:       "write",
+		Reg:      -1,
+		Write:    []byte(data),
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
@@ -0,0 +1,194 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package uart uses the BeagleBone's serial ports.  UART1, 2, 4 and 5 are on
+// the headers, as /dev/ttyS1 and so on (/dev/ttyO1 on older kernels), once
+// their pins are muxed; UART0 is the debug console.  A UART is set to raw
+// mode, 8 data bits, no parity and one stop bit, which is what most devices
+// speak.
+package uart
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"github.com/Ratfink/gopherbone/gpio"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ioctls and flags missing from package syscall
+const (
+	TCSBRK    = 0x5409
+	TCFLSH    = 0x540b
+	TCIOFLUSH = 2
+	CBAUD     = 0x100f
+)
+
+// rates maps baud rates to their termios speed codes.
+var rates = map[int]uint32{
+	1200:    syscall.B1200,
+	2400:    syscall.B2400,
+	4800:    syscall.B4800,
+	9600:    syscall.B9600,
+	19200:   syscall.B19200,
+	38400:   syscall.B38400,
+	57600:   syscall.B57600,
+	115200:  syscall.B115200,
+	230400:  syscall.B230400,
+	460800:  syscall.B460800,
+	921600:  syscall.B921600,
+	1000000: syscall.B1000000,
+	1500000: syscall.B1500000,
+	3000000: syscall.B3000000,
+}
+
+// A UART is an open serial port.  Reads and writes may have deadlines set,
+// and closing it wakes a blocked Read.
+type UART struct {
+	*os.File
+	baud int
+}
+
+// Mux sets the pinmux of UART n's TX and RX pins for the UART.
+func Mux(n int) (err error) {
+	for _, signal := range []string{"TXD", "RXD"} {
+		pin, err := gpio.LookupPin(fmt.Sprintf("UART%d_%s", n, signal))
+		if err != nil {
+			return err
+		}
+		if err = pin.Mux("uart"); err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// Open opens UART n at the given baud rate.
+func Open(n, baud int) (u *UART, err error) {
+	path := fmt.Sprintf("/dev/ttyS%d", n)
+	if _, e := os.Stat(path); e != nil {
+		if _, e := os.Stat(fmt.Sprintf("/dev/ttyO%d", n)); e == nil {
+			path = fmt.Sprintf("/dev/ttyO%d", n)
+		}
+	}
+	return OpenDevice(path, baud)
+}
+
+// OpenDevice opens the serial device at path, such as a USB serial adapter's
+// /dev/ttyUSB0, at the given baud rate.
+func OpenDevice(path string, baud int) (u *UART, err error) {
+	// A non-blocking file is read through the runtime's poller, so closing
+	// it wakes a blocked Read
+	f, err := gopherbone.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return
+	}
+	u = &UART{File: f}
+	if err = u.SetBaud(baud); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return
+}
+
+func (u *UART) control(f func(fd uintptr) syscall.Errno) (err error) {
+	rc, err := u.SyscallConn()
+	if err != nil {
+		return
+	}
+	rc.Control(func(fd uintptr) {
+		if errno := f(fd); errno != 0 {
+			err = errno
+		}
+	})
+	return
+}
+
+// termios gets or sets the port's termios settings.
+func (u *UART) termios(req uintptr, t *syscall.Termios) error {
+	return u.control(func(fd uintptr) syscall.Errno {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(t)))
+		return errno
+	})
+}
+
+// ioctl makes an ioctl with an integer argument.
+func (u *UART) ioctl(req, arg uintptr) error {
+	return u.control(func(fd uintptr) syscall.Errno {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+		return errno
+	})
+}
+
+// SetBaud sets the baud rate, and puts the port in raw 8N1 mode.
+func (u *UART) SetBaud(baud int) (err error) {
+	speed, ok := rates[baud]
+	if !ok {
+		return fmt.Errorf("Unsupported baud rate: %d", baud)
+	}
+	var t syscall.Termios
+	if err = u.termios(syscall.TCGETS, &t); err != nil {
+		return
+	}
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON | syscall.IXOFF
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB | syscall.CSTOPB | CBAUD
+	t.Cflag |= syscall.CS8 | syscall.CREAD | syscall.CLOCAL | speed
+	t.Ispeed, t.Ospeed = speed, speed
+	t.Cc[syscall.VMIN], t.Cc[syscall.VTIME] = 1, 0
+	if err = u.termios(syscall.TCSETS, &t); err != nil {
+		return
+	}
+	u.baud = baud
+	return
+}
+
+// Baud returns the baud rate.
+func (u *UART) Baud() int {
+	return u.baud
+}
+
+// Flush discards data received but not read, and data written but not yet
+// sent.
+func (u *UART) Flush() error {
+	return u.ioctl(TCFLSH, TCIOFLUSH)
+}
+
+// Drain waits until everything written has been sent.
+func (u *UART) Drain() error {
+	// TCSBRK with a non-zero argument is tcdrain
+	return u.ioctl(TCSBRK, 1)
+}
+
+// ReadTimeout is like Read, but gives up with os.ErrDeadlineExceeded if no
+// data arrives within timeout.
+func (u *UART) ReadTimeout(b []byte, timeout time.Duration) (n int, err error) {
+	if err = u.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return
+	}
+	defer u.SetReadDeadline(time.Time{})
+	return u.Read(b)
+}
@@ -0,0 +1,180 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/display"
+	"image"
+	"image/color"
+	"math"
+)
+
+// A Graph is a line plot of a rolling window of samples, the newest at the
+// right, for live sensor readings.  Unless Min and Max are set, it scales
+// itself to fit the samples shown.  NaN samples, such as failed readings,
+// leave gaps in the line.
+type Graph struct {
+	Bounds image.Rectangle
+	// Min and Max fix the scale; if they are equal, it is automatic.
+	Min, Max float64
+	// Window is the number of samples kept.  If it is zero, there is one
+	// per pixel of the plot's width; if there are more than that, the
+	// line is squeezed to fit.
+	Window int
+	// Axes draws a line along the left and bottom of the plot.
+	Axes bool
+	// Labels draws the top and bottom of the scale at the left, formatted
+	// with Format, or with %.3g if Format is empty.
+	Labels bool
+	Format string
+	// Label, if not empty, is drawn at the top right of the plot.
+	Label string
+
+	samples []float64
+}
+
+// Add appends a sample, discarding the oldest once the window is full.
+func (g *Graph) Add(v float64) {
+	g.samples = append(g.samples, v)
+	if n := g.window(); len(g.samples) > n {
+		g.samples = append(g.samples[:0], g.samples[len(g.samples)-n:]...)
+	}
+}
+
+// Values returns the samples in the window, oldest first.
+func (g *Graph) Values() []float64 {
+	return g.samples
+}
+
+// Clear discards all the samples.
+func (g *Graph) Clear() {
+	g.samples = g.samples[:0]
+}
+
+// window returns the number of samples to keep.
+func (g *Graph) window() int {
+	if g.Window > 0 {
+		return g.Window
+	}
+	if n := g.plot(g.Bounds.Canon(), "", "").Dx(); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// scale returns the range of the plot.
+func (g *Graph) scale() (min, max float64) {
+	min, max = g.Min, g.Max
+	if min != max {
+		return
+	}
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, v := range g.samples {
+		if !math.IsNaN(v) {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+	}
+	if math.IsInf(min, 0) {
+		return 0, 1
+	}
+	if min == max {
+		// Show a flat line in the middle
+		min, max = min-1, max+1
+	}
+	return
+}
+
+// plot returns the rectangle the line is drawn in, leaving room for labels
+// and axes.
+func (g *Graph) plot(r image.Rectangle, top, bottom string) image.Rectangle {
+	if g.Labels {
+		n := len(top)
+		if len(bottom) > n {
+			n = len(bottom)
+		}
+		r.Min.X += n*display.CharWidth + 1
+	}
+	if g.Axes {
+		r.Min.X += 2
+		r.Max.Y -= 2
+	}
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+	return r
+}
+
+// Render draws the graph.
+func (g *Graph) Render(d display.Display) {
+	r := g.Bounds.Canon()
+	display.Fill(d, r, color.Black)
+
+	min, max := g.scale()
+	var top, bottom string
+	if g.Labels {
+		format := g.Format
+		if format == "" {
+			format = "%.3g"
+		}
+		top, bottom = fmt.Sprintf(format, max), fmt.Sprintf(format, min)
+		if r.Dy() < display.CharHeight*2 {
+			top, bottom = "", ""
+		}
+	}
+	p := g.plot(r, top, bottom)
+	if p.Empty() {
+		return
+	}
+
+	if top != "" {
+		display.String(d, r.Min.X, r.Min.Y, color.White, nil, top)
+		display.String(d, r.Min.X, p.Max.Y-display.CharHeight, color.White, nil, bottom)
+	}
+	if g.Axes {
+		display.Line(d, p.Min.X-2, p.Min.Y, p.Min.X-2, p.Max.Y+1, color.White)
+		display.Line(d, p.Min.X-2, p.Max.Y+1, p.Max.X-1, p.Max.Y+1, color.White)
+	}
+	if g.Label != "" && p.Dy() >= display.CharHeight {
+		x := p.Max.X - len(g.Label)*display.CharWidth
+		if x >= p.Min.X {
+			display.String(d, x, p.Min.Y, color.White, nil, g.Label)
+		}
+	}
+
+	window := g.Window
+	if window <= 0 {
+		window = p.Dx()
+	}
+	samples := g.samples
+	if len(samples) > window {
+		samples = samples[len(samples)-window:]
+	}
+
+	// Sample i of the window is at x = i*(width-1)/(window-1), so the
+	// newest is at the right edge
+	xAt := func(i int) int {
+		if window < 2 {
+			return p.Max.X - 1
+		}
+		return p.Min.X + int(math.Round(float64(i)*float64(p.Dx()-1)/float64(window-1)))
+	}
+	yAt := func(v float64) int {
+		return p.Max.Y - 1 - int(math.Round(clamp(v, min, max)*float64(p.Dy()-1)))
+	}
+
+	start := window - len(samples)
+	drawn := false
+	var px, py int
+	for i, v := range samples {
+		if math.IsNaN(v) {
+			drawn = false
+			continue
+		}
+		x, y := xAt(start+i), yAt(v)
+		if drawn {
+			display.Line(d, px, py, x, y, color.White)
+		} else {
+			d.Point(x, y, color.White)
+		}
+		px, py, drawn = x, y, true
+	}
+}
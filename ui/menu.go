@@ -0,0 +1,229 @@
+package ui
+
+import (
+	"github.com/Ratfink/gopherbone/button"
+	"github.com/Ratfink/gopherbone/display"
+	"github.com/Ratfink/gopherbone/encoder"
+	"github.com/Ratfink/gopherbone/input"
+	"image"
+	"image/color"
+)
+
+// A Menu is a list of items, one of which is selected, drawn one per line of
+// text.  The selected item is highlighted, and the list scrolls to keep it in
+// view.  A Menu can be driven by hand with Move, or with an encoder or
+// buttons by Run and RunButtons.
+type Menu struct {
+	Bounds image.Rectangle
+	Items  []string
+	// Wrap makes moving past either end of the list go round to the other.
+	Wrap bool
+
+	selected int
+	top      int
+}
+
+// Selected returns the index of the selected item.
+func (m *Menu) Selected() int {
+	return m.selected
+}
+
+// Select selects an item by its index, clamped to the list.
+func (m *Menu) Select(i int) {
+	if i >= len(m.Items) {
+		i = len(m.Items) - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	m.selected = i
+}
+
+// Move moves the selection by n items, positive being down the list.
+func (m *Menu) Move(n int) {
+	if len(m.Items) == 0 {
+		return
+	}
+	i := m.selected + n
+	if m.Wrap {
+		i %= len(m.Items)
+		if i < 0 {
+			i += len(m.Items)
+		}
+	}
+	m.Select(i)
+}
+
+// Render draws the visible items.
+func (m *Menu) Render(d display.Display) {
+	r := m.Bounds.Canon()
+	display.Fill(d, r, color.Black)
+
+	lines := r.Dy() / display.CharHeight
+	if lines < 1 {
+		return
+	}
+	if m.selected < m.top {
+		m.top = m.selected
+	}
+	if m.selected >= m.top+lines {
+		m.top = m.selected - lines + 1
+	}
+
+	cols := (r.Dx() - 2) / display.CharWidth
+	for i := 0; i < lines && m.top+i < len(m.Items); i++ {
+		item := []rune(m.Items[m.top+i])
+		if len(item) > cols {
+			item = item[:cols]
+		}
+		y := r.Min.Y + i*display.CharHeight
+		fg := color.White
+		if m.top+i == m.selected {
+			display.Fill(d, image.Rect(r.Min.X, y, r.Max.X, y+display.CharHeight), color.White)
+			fg = color.Black
+		}
+		display.String(d, r.Min.X+1, y, fg, nil, string(item))
+	}
+}
+
+// Run lets the user choose an item with a rotary encoder, drawing the menu
+// on d after each change: turning the encoder moves the selection, clicking
+// its button chooses the selected item, and a long press backs out.  It
+// returns the index of the chosen item, or -1 if the user backed out or the
+// encoder was closed.  The encoder must have a button.
+func (m *Menu) Run(d display.Display, enc *encoder.Encoder) (choice int, err error) {
+	m.Render(d)
+	if err = d.Draw(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case n, ok := <-enc.C:
+			if !ok {
+				return -1, nil
+			}
+			m.Move(n)
+		case ev, ok := <-enc.Button.C:
+			if !ok {
+				return -1, nil
+			}
+			switch ev {
+			case button.Click:
+				return m.selected, nil
+			case button.LongPress:
+				return -1, nil
+			}
+			continue
+		}
+
+		m.Render(d)
+		if err = d.Draw(); err != nil {
+			return
+		}
+	}
+}
+
+// RunButtons is like Run, but uses two buttons: clicking next moves the
+// selection down (wrapping if Wrap is set), double clicking it moves up, and
+// clicking choose chooses the selected item.  A long press of either backs
+// out.
+func (m *Menu) RunButtons(d display.Display, next, choose *button.Button) (choice int, err error) {
+	m.Render(d)
+	if err = d.Draw(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case ev, ok := <-next.C:
+			if !ok {
+				return -1, nil
+			}
+			switch ev {
+			case button.Click:
+				m.Move(1)
+			case button.DoubleClick:
+				m.Move(-1)
+			case button.LongPress:
+				return -1, nil
+			default:
+				continue
+			}
+		case ev, ok := <-choose.C:
+			if !ok {
+				return -1, nil
+			}
+			switch ev {
+			case button.Click:
+				return m.selected, nil
+			case button.LongPress:
+				return -1, nil
+			}
+			continue
+		}
+
+		m.Render(d)
+		if err = d.Draw(); err != nil {
+			return
+		}
+	}
+}
+
+// RunInput is like Run, but takes its input from any input.Source, so the
+// same menu can be driven by a keypad, a GPIO encoder adapted with
+// input.FromEncoder, or both merged.  The up and left keys move the
+// selection up, the down and right keys and relative motion move it by the
+// distance moved, releasing enter, OK or select chooses the selected item,
+// and escape, back, or holding one of the choosing keys until it repeats
+// backs out.  It returns -1 if the user backed out or the source was closed.
+func (m *Menu) RunInput(d display.Display, src input.Source) (choice int, err error) {
+	m.Render(d)
+	if err = d.Draw(); err != nil {
+		return
+	}
+
+	// Only releasing a key pressed while the menu is shown chooses
+	held := make(map[uint16]bool)
+
+	for ev := range src.Events() {
+		switch ev.Type {
+		case input.Move:
+			m.Move(ev.Value)
+		case input.Press, input.Repeat:
+			switch ev.Code {
+			case input.KEY_UP, input.KEY_LEFT:
+				m.Move(-1)
+			case input.KEY_DOWN, input.KEY_RIGHT:
+				m.Move(1)
+			case input.KEY_ESC, input.KEY_BACK:
+				return -1, nil
+			case input.KEY_ENTER, input.KEY_KPENTER, input.KEY_OK, input.KEY_SELECT:
+				if ev.Type == input.Repeat {
+					return -1, nil
+				}
+				held[ev.Code] = true
+				continue
+			default:
+				continue
+			}
+		case input.Release:
+			switch ev.Code {
+			case input.KEY_ENTER, input.KEY_KPENTER, input.KEY_OK, input.KEY_SELECT:
+				if held[ev.Code] {
+					return m.selected, nil
+				}
+			}
+			continue
+		default:
+			continue
+		}
+
+		m.Render(d)
+		if err = d.Draw(); err != nil {
+			return
+		}
+	}
+
+	return -1, nil
+}
@@ -0,0 +1,238 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package ui provides simple widgets for monochrome displays: progress bars,
+// gauges, sparklines, graphs and menus.  Each widget draws itself into a rectangle of
+// any display.Display when its Render method is called; call the display's
+// Draw afterwards to show the result.  Widgets draw white on black.
+package ui
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/display"
+	"image"
+	"image/color"
+	"math"
+)
+
+// A Widget draws itself onto a display.
+type Widget interface {
+	Render(d display.Display)
+}
+
+var (
+	_ Widget = (*ProgressBar)(nil)
+	_ Widget = (*Gauge)(nil)
+	_ Widget = (*Sparkline)(nil)
+	_ Widget = (*Graph)(nil)
+	_ Widget = (*Menu)(nil)
+)
+
+// clamp limits v to the range [min, max], returning the fraction of the way
+// it is through the range.
+func clamp(v, min, max float64) float64 {
+	if max <= min {
+		return 0
+	}
+	f := (v - min) / (max - min)
+	return math.Max(0, math.Min(1, f))
+}
+
+// A ProgressBar is a horizontal bar, filled from the left in proportion to
+// Value, which runs from 0 to 1.
+type ProgressBar struct {
+	Bounds image.Rectangle
+	Value  float64
+	// ShowPercent draws the percentage in the middle of the bar, in the
+	// opposite colour to what is behind it.  The bar must be at least
+	// display.CharHeight+2 pixels high.
+	ShowPercent bool
+}
+
+// Render draws the bar.
+func (bar *ProgressBar) Render(d display.Display) {
+	r := bar.Bounds.Canon()
+	display.Fill(d, r, color.Black)
+	display.Rect(d, r, color.White)
+
+	inner := r.Inset(2)
+	if inner.Empty() {
+		return
+	}
+	fill := inner
+	fill.Max.X = inner.Min.X + int(math.Round(clamp(bar.Value, 0, 1)*float64(inner.Dx())))
+	display.Fill(d, fill, color.White)
+
+	if !bar.ShowPercent || r.Dy() < display.CharHeight+2 {
+		return
+	}
+	text := fmt.Sprintf("%d%%", int(math.Round(clamp(bar.Value, 0, 1)*100)))
+	x := r.Min.X + (r.Dx()-len(text)*display.CharWidth)/2
+	y := r.Min.Y + (r.Dy()-display.CharHeight)/2
+	for _, ch := range text {
+		// Draw each pixel of the text in the colour opposite to the
+		// bar behind it, so that it can be read wherever the bar ends
+		display.Char(invert{d, fill}, x, y, color.White, nil, ch)
+		x += display.CharWidth
+	}
+}
+
+// invert is a display.Display which swaps white and black inside a
+// rectangle, for drawing text over a progress bar.
+type invert struct {
+	display.Display
+	r image.Rectangle
+}
+
+func (inv invert) Point(x, y int, c color.Gray16) {
+	if image.Pt(x, y).In(inv.r) {
+		if c == color.White {
+			c = color.Black
+		} else {
+			c = color.White
+		}
+	}
+	inv.Display.Point(x, y, c)
+}
+
+// A Gauge is a dial, a semicircle with a needle pointing to Value on a scale
+// running from Min on the left to Max on the right.
+type Gauge struct {
+	Bounds   image.Rectangle
+	Min, Max float64
+	Value    float64
+	// Ticks is the number of divisions marked on the scale, or 0 for none.
+	Ticks int
+	// Label, if not empty, is drawn beneath the needle's pivot when there
+	// is room.
+	Label string
+}
+
+// Render draws the gauge, as large as fits in its bounds.
+func (g *Gauge) Render(d display.Display) {
+	r := g.Bounds.Canon()
+	display.Fill(d, r, color.Black)
+
+	labelled := g.Label != "" && r.Dy() > display.CharHeight*2
+	h := r.Dy()
+	if labelled {
+		h -= display.CharHeight
+	}
+	radius := r.Dx()/2 - 1
+	if h-1 < radius {
+		radius = h - 1
+	}
+	if radius < 2 {
+		return
+	}
+	cx := r.Min.X + r.Dx()/2
+	cy := r.Min.Y + radius
+
+	// The arc, drawn as many short lines
+	const segments = 32
+	px, py := cx-radius, cy
+	for i := 1; i <= segments; i++ {
+		a := math.Pi * float64(i) / segments
+		x := cx - int(math.Round(float64(radius)*math.Cos(a)))
+		y := cy - int(math.Round(float64(radius)*math.Sin(a)))
+		display.Line(d, px, py, x, y, color.White)
+		px, py = x, y
+	}
+
+	for i := 0; g.Ticks > 0 && i <= g.Ticks; i++ {
+		a := math.Pi * float64(i) / float64(g.Ticks)
+		inner := float64(radius) * 0.8
+		display.Line(d,
+			cx-int(math.Round(inner*math.Cos(a))), cy-int(math.Round(inner*math.Sin(a))),
+			cx-int(math.Round(float64(radius)*math.Cos(a))), cy-int(math.Round(float64(radius)*math.Sin(a))),
+			color.White)
+	}
+
+	a := math.Pi * clamp(g.Value, g.Min, g.Max)
+	needle := float64(radius) * 0.9
+	display.Line(d, cx, cy,
+		cx-int(math.Round(needle*math.Cos(a))), cy-int(math.Round(needle*math.Sin(a))),
+		color.White)
+
+	if labelled {
+		x := cx - len(g.Label)*display.CharWidth/2
+		display.String(d, x, cy+2, color.White, nil, g.Label)
+	}
+}
+
+// A Sparkline is a small line graph of the most recent values added to it,
+// one per pixel of its width.  Unless Min and Max are set, it scales itself
+// to fit the values shown.
+type Sparkline struct {
+	Bounds image.Rectangle
+	// Min and Max fix the scale; if they are equal, it is automatic.
+	Min, Max float64
+
+	values []float64
+}
+
+// Add appends a value, discarding the oldest once there are more than fit.
+func (s *Sparkline) Add(v float64) {
+	s.values = append(s.values, v)
+	if n := s.Bounds.Dx(); n > 0 && len(s.values) > n {
+		s.values = s.values[len(s.values)-n:]
+	}
+}
+
+// Values returns the values shown, oldest first.
+func (s *Sparkline) Values() []float64 {
+	return s.values
+}
+
+// Render draws the line, with the newest value at the right.
+func (s *Sparkline) Render(d display.Display) {
+	r := s.Bounds.Canon()
+	display.Fill(d, r, color.Black)
+	if len(s.values) == 0 || r.Dy() < 1 {
+		return
+	}
+
+	min, max := s.Min, s.Max
+	if min == max {
+		min, max = math.Inf(1), math.Inf(-1)
+		for _, v := range s.values {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+		if min == max {
+			// Show a flat line in the middle
+			min, max = min-1, max+1
+		}
+	}
+
+	x := r.Max.X - len(s.values)
+	var px, py int
+	for i, v := range s.values {
+		y := r.Max.Y - 1 - int(math.Round(clamp(v, min, max)*float64(r.Dy()-1)))
+		if i == 0 {
+			d.Point(x, y, color.White)
+		} else {
+			display.Line(d, px, py, x, y, color.White)
+		}
+		px, py = x, y
+		x++
+	}
+}
@@ -0,0 +1,5 @@
+module github.com/Ratfink/gopherbone/v2
+
+go 1.21
+
+require github.com/Ratfink/gopherbone v1.0.0
@@ -0,0 +1,90 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package gopherbone is the root of version 2 of GopherBone, whose drivers
+// are opened with functional options and return errors saying which device
+// failed.  Version 2 is built on version 1, which stays importable without
+// the /v2 suffix, and its types are aliases of version 1's, so that programs
+// can move over one package at a time:
+//
+//	import (
+//		"github.com/Ratfink/gopherbone/v2/gpio"
+//		"github.com/Ratfink/gopherbone/v2/i2c"
+//		"github.com/Ratfink/gopherbone/v2/ssd1306"
+//	)
+//
+//	led, err := gpio.Open("P8_10", gpio.Output(0))
+//	...
+//	dev, err := i2c.Open(2, 0x3c)
+//	...
+//	oled, err := ssd1306.Open(dev, ssd1306.ExternalVCC())
+//
+// Packages not yet in version 2 are used from version 1 as before.  This
+// package also re-exports version 1's cleanup of what the program leaves
+// set up.
+package gopherbone
+
+import (
+	"fmt"
+	v1 "github.com/Ratfink/gopherbone"
+)
+
+// An Error records which device an operation failed on, and why.  Errors
+// returned by version 2's constructors are Errors, which unwrap to version
+// 1's errors, so errors.Is and errors.As work as they did.
+type Error struct {
+	// Op is the operation, such as "open" or "set up".
+	Op string
+	// Device names the device, such as "GPIO P8_10" or "I2C 2:0x3c".
+	Device string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Device, e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns err in an Error, or nil if err is nil.
+func Wrap(op, device string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Op: op, Device: device, Err: err}
+}
+
+// A PermissionError is returned when the program may not use a device.
+type PermissionError = v1.PermissionError
+
+// Version 1's cleanup, which version 2's drivers register with in the same
+// way.  CleanupOnPanic is the same function rather than a wrapper, since it
+// must be deferred directly to recover.
+var (
+	Cleanup        = v1.Cleanup
+	HandleSignals  = v1.HandleSignals
+	CleanupOnPanic = v1.CleanupOnPanic
+	Registered     = v1.Registered
+)
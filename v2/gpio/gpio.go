@@ -0,0 +1,166 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package gpio opens GPIO pins through the kernel's sysfs interface, set up
+// by functional options:
+//
+//	led, err := gpio.Open("P8_10", gpio.Output(0))
+//	button, err := gpio.Open("P8_12", gpio.OnEdge(gpio.Falling), gpio.Debounce(20*time.Millisecond))
+//
+// Pins are version 1's *gpio.GPIO, so everything else about them is as
+// documented there.
+package gpio
+
+import (
+	"fmt"
+	v1 "github.com/Ratfink/gopherbone/gpio"
+	"github.com/Ratfink/gopherbone/v2"
+	"strconv"
+	"time"
+)
+
+// Types shared with version 1.
+type (
+	GPIO       = v1.GPIO
+	DigitalPin = v1.DigitalPin
+	Direction  = v1.Direction
+	Edge       = v1.Edge
+	Watcher    = v1.Watcher
+	PinError   = v1.PinError
+)
+
+// Directions and edges.
+const (
+	In      = v1.In
+	Out     = v1.Out
+	None    = v1.None
+	Rising  = v1.Rising
+	Falling = v1.Falling
+	Both    = v1.Both
+)
+
+// Errors which may be matched with errors.Is.
+var (
+	ErrNotExported      = v1.ErrNotExported
+	ErrBusy             = v1.ErrBusy
+	ErrInvalidDirection = v1.ErrInvalidDirection
+	ErrInvalidEdge      = v1.ErrInvalidEdge
+	ErrInvalidValue     = v1.ErrInvalidValue
+)
+
+type options struct {
+	dir       Direction
+	value     int
+	activeLow bool
+	edge      Edge
+	debounce  time.Duration
+	mux       string
+}
+
+// An Option sets up a pin as it is opened.
+type Option func(*options)
+
+// Input makes the pin an input, which is the default.
+func Input() Option {
+	return func(o *options) { o.dir = In }
+}
+
+// Output makes the pin an output, starting at value.
+func Output(value int) Option {
+	return func(o *options) { o.dir, o.value = Out, value }
+}
+
+// ActiveLow inverts the pin's value, for things which are on when low.
+func ActiveLow() Option {
+	return func(o *options) { o.activeLow = true }
+}
+
+// OnEdge makes an input report edges, for Watch.
+func OnEdge(edge Edge) Option {
+	return func(o *options) { o.edge = edge }
+}
+
+// Debounce ignores edges within d of the last, as for GPIO.Debounce.
+func Debounce(d time.Duration) Option {
+	return func(o *options) { o.debounce = d }
+}
+
+// Mux sets the pin's mode with the pinmux helper first, such as
+// "gpio_pu" for an input with its pull-up on.  The pin must be given by
+// name.
+func Mux(mode string) Option {
+	return func(o *options) { o.mux = mode }
+}
+
+// Open exports and sets up a pin, given by header name such as "P8_10",
+// signal name as understood by version 1's LookupPin, or GPIO number.  If
+// setting it up fails, it is unexported again.
+func Open(name string, opts ...Option) (g *GPIO, err error) {
+	o := options{dir: In}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	defer func() {
+		err = gopherbone.Wrap("open", "GPIO "+name, err)
+	}()
+
+	n, err := strconv.Atoi(name)
+	if err != nil {
+		var pin *v1.Pin
+		if pin, err = v1.LookupPin(name); err != nil {
+			return
+		}
+		if o.mux != "" {
+			if err = pin.Mux(o.mux); err != nil {
+				return
+			}
+		}
+		n = pin.GPIO
+	} else if o.mux != "" {
+		err = fmt.Errorf("Pin must be given by name to be muxed")
+		return
+	}
+
+	if g, err = v1.Export(n); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			g.Unexport()
+			g = nil
+		}
+	}()
+	if err = g.SetActiveLow(o.activeLow); err != nil {
+		return
+	}
+	if err = g.SetDirection(o.dir); err != nil {
+		return
+	}
+	if o.dir == Out {
+		err = g.SetValue(o.value)
+	} else if o.edge != "" {
+		err = g.SetEdge(o.edge)
+	}
+	g.Debounce(o.debounce)
+
+	return
+}
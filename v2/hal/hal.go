@@ -0,0 +1,51 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package hal names the interfaces version 2's drivers are written against,
+// for anyone porting them to other hardware.  They are aliases of version
+// 1's, so implementations of either satisfy both.
+package hal
+
+import (
+	"github.com/Ratfink/gopherbone/adc"
+	"github.com/Ratfink/gopherbone/display"
+	v1 "github.com/Ratfink/gopherbone/hal"
+)
+
+// A DigitalPin is a GPIO which can be read, written and watched for edges.
+type DigitalPin = v1.DigitalPin
+
+// An I2CBus is a connection to a single device on an I2C bus.
+type I2CBus = v1.I2CBus
+
+// An SPIBus is a connection to a single device on an SPI bus.
+type SPIBus = v1.SPIBus
+
+// A PWMPin is a PWM output.
+type PWMPin = v1.PWMPin
+
+// An AnalogPin is an analog input read in volts, or in other units if
+// calibrated.
+type AnalogPin = adc.AnalogPin
+
+// A Display is a monochrome display with a framebuffer.
+type Display = display.Display
@@ -0,0 +1,109 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package i2c opens devices on I2C buses through the kernel's i2c-dev
+// interface, set up by functional options:
+//
+//	dev, err := i2c.Open(2, 0x68, i2c.Retries(3, time.Millisecond))
+//
+// Devices are version 1's *i2c.Device, so everything else about them is as
+// documented there.
+package i2c
+
+import (
+	"fmt"
+	v1 "github.com/Ratfink/gopherbone/i2c"
+	"github.com/Ratfink/gopherbone/v2"
+	"time"
+)
+
+// Types shared with version 1.
+type (
+	Device     = v1.Device
+	Bus        = v1.Bus
+	Conn       = v1.Conn
+	RawWriter  = v1.RawWriter
+	Transactor = v1.Transactor
+)
+
+// ErrNAK is returned when a device doesn't acknowledge its address.
+var ErrNAK = v1.ErrNAK
+
+type options struct {
+	retries int
+	backoff time.Duration
+	probe   bool
+}
+
+// An Option sets up a device as it is opened.
+type Option func(*options)
+
+// Retries retries transfers which lose arbitration up to n times, as for
+// Bus.SetRetries.  It applies to every device on the bus.
+func Retries(n int, backoff time.Duration) Option {
+	return func(o *options) { o.retries, o.backoff = n, backoff }
+}
+
+// Probe checks that the device acknowledges its address, so that a missing
+// device fails Open rather than its first transfer.
+func Probe() Option {
+	return func(o *options) { o.probe = true }
+}
+
+// Open opens the device at addr on the given bus.  The bus is shared with
+// any other devices already open on it.
+func Open(bus, addr byte, opts ...Option) (dev *Device, err error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	defer func() {
+		err = gopherbone.Wrap("open", fmt.Sprintf("I2C %d:%#02x", bus, addr), err)
+	}()
+
+	if o.probe {
+		var found bool
+		if found, err = v1.Probe(bus, addr); err != nil {
+			return
+		}
+		if !found {
+			err = ErrNAK
+			return
+		}
+	}
+	if dev, err = v1.NewDevice(addr, bus); err != nil {
+		return
+	}
+	if o.retries > 0 {
+		if err = dev.Bus().SetRetries(o.retries, o.backoff); err != nil {
+			dev.Close()
+			dev = nil
+		}
+	}
+
+	return
+}
+
+// Scan returns the addresses which respond on a bus, as i2cdetect does.
+func Scan(bus byte) ([]byte, error) {
+	return v1.Scan(bus)
+}
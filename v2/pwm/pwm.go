@@ -0,0 +1,129 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package pwm opens channels of the kernel's sysfs PWM chips, set up by
+// functional options:
+//
+//	fan, err := pwm.Open(4, 0, pwm.Pin("P9_14"), pwm.Frequency(25000), pwm.Duty(0.5), pwm.Enabled())
+//
+// Channels are version 1's *pwm.PWM, so everything else about them is as
+// documented there.
+package pwm
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/gpio"
+	v1 "github.com/Ratfink/gopherbone/pwm"
+	"github.com/Ratfink/gopherbone/v2"
+)
+
+// Types shared with version 1.
+type (
+	PWM     = v1.PWM
+	Channel = v1.Channel
+)
+
+type options struct {
+	pin       string
+	frequency float64
+	duty      float64
+	enable    bool
+	inverted  bool
+}
+
+// An Option sets up a channel as it is opened.
+type Option func(*options)
+
+// Pin muxes a header pin, such as "P9_14", to the channel first.
+func Pin(name string) Option {
+	return func(o *options) { o.pin = name }
+}
+
+// Frequency sets the channel's frequency in hertz.
+func Frequency(hz float64) Option {
+	return func(o *options) { o.frequency = hz }
+}
+
+// Duty sets the fraction of each period the output is high, from 0 to 1.
+func Duty(fraction float64) Option {
+	return func(o *options) { o.duty = fraction }
+}
+
+// Enabled starts the output once the channel is set up.
+func Enabled() Option {
+	return func(o *options) { o.enable = true }
+}
+
+// Inverted makes the output low for the duty cycle rather than high.
+func Inverted() Option {
+	return func(o *options) { o.inverted = true }
+}
+
+// Open exports and sets up a channel of a PWM chip.  If setting it up
+// fails, it is unexported again.
+func Open(chip, channel int, opts ...Option) (p *PWM, err error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	defer func() {
+		err = gopherbone.Wrap("open", fmt.Sprintf("PWM %d:%d", chip, channel), err)
+	}()
+
+	if o.pin != "" {
+		var pin *gpio.Pin
+		if pin, err = gpio.LookupPin(o.pin); err != nil {
+			return
+		}
+		if err = pin.Mux("pwm"); err != nil {
+			return
+		}
+	}
+	if p, err = v1.Export(chip, channel); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			p.Unexport()
+			p = nil
+		}
+	}()
+
+	if o.frequency > 0 {
+		if err = p.SetFrequency(o.frequency); err != nil {
+			return
+		}
+	}
+	if err = p.SetDuty(o.duty); err != nil {
+		return
+	}
+	if o.inverted {
+		if err = p.SetInverted(true); err != nil {
+			return
+		}
+	}
+	if o.enable {
+		err = p.Enable()
+	}
+
+	return
+}
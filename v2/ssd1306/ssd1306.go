@@ -0,0 +1,123 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package ssd1306 drives SSD1306 OLED displays, set up by functional
+// options:
+//
+//	dev, err := i2c.Open(2, 0x3c)
+//	...
+//	oled, err := ssd1306.Open(dev, ssd1306.Size(128, 32), ssd1306.ExternalVCC())
+//
+// Displays are version 1's *ssd1306.SSD1306, so everything else about them
+// is as documented there.
+package ssd1306
+
+import (
+	v1 "github.com/Ratfink/gopherbone/ssd1306"
+	"github.com/Ratfink/gopherbone/v2"
+	"github.com/Ratfink/gopherbone/v2/hal"
+)
+
+// SSD1306 is a display.
+type SSD1306 = v1.SSD1306
+
+// ErrUnsupportedInterface is returned for displays on buses other than I2C.
+var ErrUnsupportedInterface = v1.ErrUnsupportedInterface
+
+type options struct {
+	width, height int
+	reset         hal.DigitalPin
+	panel         v1.Options
+	noSetup       bool
+}
+
+// An Option sets up a display as it is opened.
+type Option func(*options)
+
+// Size sets the display's size in pixels, 128x64 by default.
+func Size(width, height int) Option {
+	return func(o *options) { o.width, o.height = width, height }
+}
+
+// Reset gives the display's reset line, which Open pulses.
+func Reset(pin hal.DigitalPin) Option {
+	return func(o *options) { o.reset = pin }
+}
+
+// ExternalVCC is for panels powered from an external supply rather than
+// the charge pump.
+func ExternalVCC() Option {
+	return func(o *options) { o.panel.ExternalVCC = true }
+}
+
+// Contrast sets the initial contrast.
+func Contrast(contrast byte) Option {
+	return func(o *options) { o.panel.Contrast = contrast }
+}
+
+// Precharge sets the precharge periods, as for version 1's Options.
+func Precharge(periods byte) Option {
+	return func(o *options) { o.panel.Precharge = periods }
+}
+
+// Clock sets the clock divider and oscillator frequency, as for version 1's
+// Options.
+func Clock(clock byte) Option {
+	return func(o *options) { o.panel.Clock = clock }
+}
+
+// VCOMH sets the VCOMH deselect level.
+func VCOMH(level byte) Option {
+	return func(o *options) { o.panel.VCOMH = level }
+}
+
+// NoSetup leaves the display as it is rather than resetting and
+// configuring it, to draw on a display another program has set up.
+func NoSetup() Option {
+	return func(o *options) { o.noSetup = true }
+}
+
+// Open returns the display connected through conn, reset and configured
+// unless NoSetup is given.  The connection is not closed by the display's
+// Close, but its reset pin is unexported.
+func Open(conn hal.I2CBus, opts ...Option) (d *SSD1306, err error) {
+	o := options{width: 128, height: 64}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	defer func() {
+		err = gopherbone.Wrap("open", "SSD1306", err)
+	}()
+
+	if d, err = v1.NewConn(conn, o.reset, o.width, o.height); err != nil {
+		return
+	}
+	d.SetOptions(o.panel)
+	if !o.noSetup {
+		if err = d.Setup(); err != nil {
+			d.Close()
+			d = nil
+		}
+	}
+
+	return
+}
@@ -0,0 +1,101 @@
+package watchdog
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// A Keeper kicks a watchdog periodically, as long as the application's
+// liveness callback says it is working.  Once the callback reports a
+// problem, or a kick fails, it stops kicking, leaving the watchdog to reset
+// the board.  Done is closed when it stops, after which Err says why.
+type Keeper struct {
+	Err error
+
+	wd   *Watchdog
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Keep starts kicking the watchdog every interval, or every third of its
+// timeout if interval is zero.  Before each kick alive is called, if it is
+// not nil; it should check that the application is making progress, for
+// instance that its main loop has run since the last call, and return false
+// if it isn't.  It must return promptly.
+func (wd *Watchdog) Keep(interval time.Duration, alive func() bool) (k *Keeper, err error) {
+	if interval <= 0 {
+		var timeout time.Duration
+		if timeout, err = wd.Timeout(); err != nil {
+			return
+		}
+		if interval = timeout / 3; interval <= 0 {
+			err = fmt.Errorf("Invalid watchdog timeout: %v", timeout)
+			return
+		}
+	}
+	if err = wd.Kick(); err != nil {
+		return
+	}
+
+	k = &Keeper{wd: wd, stop: make(chan struct{}), done: make(chan struct{})}
+	go k.run(interval, alive)
+	return
+}
+
+func (k *Keeper) run(interval time.Duration, alive func() bool) {
+	defer close(k.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if alive != nil && !alive() {
+				k.Err = ErrNotAlive
+				return
+			}
+			if err := k.wd.Kick(); err != nil {
+				k.Err = err
+				return
+			}
+		case <-k.stop:
+			return
+		}
+	}
+}
+
+// Done returns a channel which is closed when the keeper stops kicking.
+func (k *Keeper) Done() <-chan struct{} {
+	return k.done
+}
+
+// Stop stops kicking the watchdog, without closing it, and returns the
+// error which stopped the keeper already, if any.
+func (k *Keeper) Stop() error {
+	select {
+	case <-k.done:
+	default:
+		close(k.stop)
+		<-k.done
+	}
+	return k.Err
+}
+
+// A Heartbeat is a simple liveness callback: the application's main loop
+// calls Beat each time round, and Alive, passed to Keep, reports whether it
+// has been called since Alive last was.  The Keep interval must then be
+// longer than the loop ever takes.
+type Heartbeat struct {
+	beat int32
+}
+
+// Beat records that the application is alive.
+func (h *Heartbeat) Beat() {
+	atomic.StoreInt32(&h.beat, 1)
+}
+
+// Alive returns whether Beat has been called since the last call to Alive.
+func (h *Heartbeat) Alive() bool {
+	return atomic.SwapInt32(&h.beat, 0) != 0
+}
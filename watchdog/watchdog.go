@@ -0,0 +1,245 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+// Package watchdog drives the hardware watchdog timer through
+// /dev/watchdog, which resets the board if it isn't kicked in time, so that
+// an unattended BeagleBone recovers from a hung program or kernel.  A
+// Keeper kicks it periodically for as long as the program reports itself
+// alive.
+package watchdog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Ratfink/gopherbone"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Path is the default watchdog device.
+var Path = "/dev/watchdog"
+
+// ioctl requests, as defined in /usr/include/linux/watchdog.h
+const (
+	WDIOC_GETSUPPORT    = 0x80285700
+	WDIOC_GETBOOTSTATUS = 0x80045702
+	WDIOC_SETOPTIONS    = 0x80045704
+	WDIOC_KEEPALIVE     = 0x80045705
+	WDIOC_SETTIMEOUT    = 0xc0045706
+	WDIOC_GETTIMEOUT    = 0x80045707
+	WDIOC_GETTIMELEFT   = 0x8004570a
+)
+
+// Option flags, as reported in Info.Options and by BootStatus
+const (
+	WDIOF_OVERHEAT      = 0x0001
+	WDIOF_FANFAULT      = 0x0002
+	WDIOF_EXTERN1       = 0x0004
+	WDIOF_EXTERN2       = 0x0008
+	WDIOF_POWERUNDER    = 0x0010
+	WDIOF_CARDRESET     = 0x0020
+	WDIOF_POWEROVER     = 0x0040
+	WDIOF_SETTIMEOUT    = 0x0080
+	WDIOF_MAGICCLOSE    = 0x0100
+	WDIOF_PRETIMEOUT    = 0x0200
+	WDIOF_ALARMONLY     = 0x0400
+	WDIOF_KEEPALIVEPING = 0x8000
+)
+
+// Options for WDIOC_SETOPTIONS
+const (
+	WDIOS_DISABLECARD = 0x0001
+	WDIOS_ENABLECARD  = 0x0002
+)
+
+// ErrClosed is returned when using a Watchdog which has been closed.
+var ErrClosed = errors.New("Watchdog closed")
+
+// ErrNotAlive is the error a Keeper stops with when its liveness callback
+// returns false.
+var ErrNotAlive = errors.New("Application not alive")
+
+// as defined in /usr/include/linux/watchdog.h
+type watchdog_info struct {
+	options          uint32
+	firmware_version uint32
+	identity         [32]byte
+}
+
+// Info describes a watchdog's driver.
+type Info struct {
+	// Identity is the driver's name, such as "OMAP Watchdog".
+	Identity        string
+	FirmwareVersion uint32
+	// Options is the WDIOF_ flags the driver supports.
+	Options uint32
+}
+
+// A Watchdog is an open watchdog device.  Opening it starts the timer, on
+// most drivers, so it must be kicked from then on.
+type Watchdog struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+// Open opens the watchdog at Path.
+func Open() (wd *Watchdog, err error) {
+	return OpenDevice(Path)
+}
+
+// OpenDevice opens the watchdog at path, such as /dev/watchdog1.
+func OpenDevice(path string) (wd *Watchdog, err error) {
+	f, err := gopherbone.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	wd = &Watchdog{file: f}
+	return
+}
+
+// ioctl passes a pointer to an int to a watchdog ioctl.
+func (wd *Watchdog) ioctl(req uintptr, arg *int32) (err error) {
+	wd.lock.Lock()
+	defer wd.lock.Unlock()
+	if wd.file == nil {
+		return ErrClosed
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, wd.file.Fd(), req, uintptr(unsafe.Pointer(arg))); errno != 0 {
+		err = fmt.Errorf("Watchdog ioctl %#x: %w", req, errno)
+	}
+	return
+}
+
+// Info returns the identity and capabilities of the watchdog's driver.
+func (wd *Watchdog) Info() (info Info, err error) {
+	var wi watchdog_info
+	wd.lock.Lock()
+	if wd.file == nil {
+		err = ErrClosed
+	} else if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, wd.file.Fd(), WDIOC_GETSUPPORT, uintptr(unsafe.Pointer(&wi))); errno != 0 {
+		err = fmt.Errorf("Watchdog ioctl %#x: %w", WDIOC_GETSUPPORT, errno)
+	}
+	wd.lock.Unlock()
+	if err != nil {
+		return
+	}
+
+	info.Options = wi.options
+	info.FirmwareVersion = wi.firmware_version
+	if i := bytes.IndexByte(wi.identity[:], 0); i >= 0 {
+		info.Identity = string(wi.identity[:i])
+	} else {
+		info.Identity = string(wi.identity[:])
+	}
+	return
+}
+
+// Kick resets the timer, postponing the reset by another timeout.
+func (wd *Watchdog) Kick() (err error) {
+	var dummy int32
+	return wd.ioctl(WDIOC_KEEPALIVE, &dummy)
+}
+
+// Timeout returns the time the watchdog waits for a kick before resetting
+// the board.
+func (wd *Watchdog) Timeout() (timeout time.Duration, err error) {
+	var secs int32
+	err = wd.ioctl(WDIOC_GETTIMEOUT, &secs)
+	timeout = time.Duration(secs) * time.Second
+	return
+}
+
+// SetTimeout sets the timeout, which is rounded up to whole seconds.  The
+// driver may not support the exact timeout asked for; the timeout it chose
+// is returned.  It also kicks the watchdog.
+func (wd *Watchdog) SetTimeout(timeout time.Duration) (actual time.Duration, err error) {
+	secs := int32((timeout + time.Second - 1) / time.Second)
+	err = wd.ioctl(WDIOC_SETTIMEOUT, &secs)
+	actual = time.Duration(secs) * time.Second
+	return
+}
+
+// TimeLeft returns how long remains before the board is reset, if the
+// driver supports it.
+func (wd *Watchdog) TimeLeft() (left time.Duration, err error) {
+	var secs int32
+	err = wd.ioctl(WDIOC_GETTIMELEFT, &secs)
+	left = time.Duration(secs) * time.Second
+	return
+}
+
+// BootStatus returns the WDIOF_ flags giving the cause of the last reset.
+// WDIOF_CARDRESET means the watchdog reset the board.
+func (wd *Watchdog) BootStatus() (status uint32, err error) {
+	var flags int32
+	err = wd.ioctl(WDIOC_GETBOOTSTATUS, &flags)
+	status = uint32(flags)
+	return
+}
+
+// Enable starts or stops the timer without closing the watchdog, if the
+// driver supports it.
+func (wd *Watchdog) Enable(enable bool) (err error) {
+	var opt int32 = WDIOS_DISABLECARD
+	if enable {
+		opt = WDIOS_ENABLECARD
+	}
+	return wd.ioctl(WDIOC_SETOPTIONS, &opt)
+}
+
+// Close stops the timer and closes the watchdog, by writing the magic
+// character 'V' before closing it.  If the driver doesn't support magic
+// close (see WDIOF_MAGICCLOSE), or the kernel was built with
+// CONFIG_WATCHDOG_NOWAYOUT, the timer keeps running and the board is reset
+// unless something else opens and kicks it.
+func (wd *Watchdog) Close() (err error) {
+	wd.lock.Lock()
+	defer wd.lock.Unlock()
+	if wd.file == nil {
+		return ErrClosed
+	}
+	_, err = wd.file.Write([]byte("V"))
+	if e := wd.file.Close(); err == nil {
+		err = e
+	}
+	wd.file = nil
+	return
+}
+
+// Abandon closes the watchdog without stopping the timer, so that the board
+// is reset unless something else opens and kicks it in time.  A program
+// shutting down because it has found itself in a bad state may use this to
+// have the board reset.
+func (wd *Watchdog) Abandon() (err error) {
+	wd.lock.Lock()
+	defer wd.lock.Unlock()
+	if wd.file == nil {
+		return ErrClosed
+	}
+	err = wd.file.Close()
+	wd.file = nil
+	return
+}
@@ -0,0 +1,78 @@
+package ws2812
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/pru"
+	"runtime"
+	"time"
+)
+
+// Offsets into PRU shared RAM of the interface to PRU firmware.  The firmware
+// waits for the flag word to become 1, sends length bytes of GRB data from
+// data on its output pin, and then sets the flag back to 0.
+const (
+	PRU_FLAG   = 0x0
+	PRU_LENGTH = 0x4
+	PRU_DATA   = 0x8
+)
+
+type pruDriver struct {
+	pru *pru.PRU
+	mem *pru.Memory
+}
+
+// NewPRU returns a strip of n LEDs driven by firmware running on PRU core
+// (0 or 1), which is loaded from the named file in /lib/firmware and started.
+// The firmware must implement the interface described at PRU_FLAG; with the
+// strip's data in PRU shared RAM, at most about 4000 LEDs can be driven.
+func NewPRU(core int, firmware string, n int) (strip *Strip, err error) {
+	if PRU_DATA+3*n > pru.SHARED_RAM_SIZE {
+		err = fmt.Errorf("Too many LEDs for PRU shared RAM: %d", n)
+		return
+	}
+
+	p, err := pru.Open(core)
+	if err != nil {
+		return
+	}
+	mem, err := pru.SharedRAM()
+	if err != nil {
+		return
+	}
+	mem.SetUint32(PRU_FLAG, 0)
+	if err = p.Load(firmware); err == nil {
+		err = p.Start()
+	}
+	if err != nil {
+		mem.Close()
+		return
+	}
+
+	strip = newStrip(n, &pruDriver{pru: p, mem: mem})
+
+	return
+}
+
+func (d *pruDriver) show(grb []byte) error {
+	// Wait for the previous frame to finish
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for d.mem.Uint32(PRU_FLAG) != 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("PRU firmware not responding")
+		}
+		runtime.Gosched()
+	}
+
+	copy(d.mem.Bytes()[PRU_DATA:], grb)
+	d.mem.SetUint32(PRU_LENGTH, uint32(len(grb)))
+	d.mem.SetUint32(PRU_FLAG, 1)
+
+	return nil
+}
+
+func (d *pruDriver) close() (err error) {
+	err = d.pru.Stop()
+	d.mem.Close()
+
+	return
+}
@@ -0,0 +1,179 @@
+/* GopherBone - A collection of packages for working with the BeagleBone in Go
+ * Copyright (c) 2013 Clayton G. Hobbs
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to
+ * deal in the Software without restriction, including without limitation the
+ * rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+ * sell copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in
+ * all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+ * FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+ * IN THE SOFTWARE.
+ */
+
+/* WS2812 ("NeoPixel") LEDs are controlled by an 800kHz signal in which each
+ * bit is a high pulse of about 400ns for a 0 or 800ns for a 1.  That's far
+ * too fast to bit-bang from Linux, so this package generates the waveform
+ * with hardware instead.  The SPI trick runs the bus at 2.4MHz and sends each
+ * LED bit as three SPI bits, 100 for a 0 and 110 for a 1, on MOSI; the other
+ * SPI pins go unused.  Alternatively, firmware on one of the PRUs can
+ * generate the waveform on any PRU output pin; see NewPRU.
+ */
+package ws2812
+
+import (
+	"fmt"
+	"github.com/Ratfink/gopherbone/spi"
+	"image/color"
+)
+
+// spiSpeed gives SPI bits of 417ns, three to an LED bit.
+const spiSpeed = 2400000
+
+// resetBytes of zeros hold MOSI low for over 50us, latching the data.
+const resetBytes = 20
+
+// A driver sends GRB data to the strip.
+type driver interface {
+	show(grb []byte) error
+	close() error
+}
+
+// A Strip is a chain of WS2812 LEDs.  Colours are set in a buffer with Set and
+// sent to the LEDs with Show.
+type Strip struct {
+	pixels     []color.RGBA
+	brightness uint8
+	driver     driver
+	grb        []byte
+}
+
+func newStrip(n int, d driver) *Strip {
+	return &Strip{
+		pixels:     make([]color.RGBA, n),
+		brightness: 255,
+		driver:     d,
+		grb:        make([]byte, 3*n),
+	}
+}
+
+// NewSPI returns a strip of n LEDs whose data input is connected to the MOSI
+// pin of the given spidev bus and chip select.
+func NewSPI(bus, cs, n int) (strip *Strip, err error) {
+	s, err := spi.Open(bus, cs)
+	if err != nil {
+		return
+	}
+	if err = s.SetSpeed(spiSpeed); err != nil {
+		s.Close()
+		return
+	}
+
+	strip = newStrip(n, &spiDriver{spi: s})
+
+	return
+}
+
+// Len returns the number of LEDs in the strip.
+func (strip *Strip) Len() int {
+	return len(strip.pixels)
+}
+
+// Set sets the colour of LED i in the buffer.  Alpha is ignored.
+func (strip *Strip) Set(i int, c color.Color) (err error) {
+	if i < 0 || i >= len(strip.pixels) {
+		err = fmt.Errorf("Invalid LED: %d", i)
+		return
+	}
+	r, g, b, _ := c.RGBA()
+	strip.pixels[i] = color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff}
+
+	return
+}
+
+// At returns the colour of LED i in the buffer.
+func (strip *Strip) At(i int) color.Color {
+	return strip.pixels[i]
+}
+
+// Fill sets every LED in the buffer to the same colour.
+func (strip *Strip) Fill(c color.Color) {
+	for i := range strip.pixels {
+		strip.Set(i, c)
+	}
+}
+
+// Clear turns every LED in the buffer off.
+func (strip *Strip) Clear() {
+	strip.Fill(color.Black)
+}
+
+// SetBrightness scales every colour by brightness/255 when it is shown,
+// without changing the buffer.
+func (strip *Strip) SetBrightness(brightness uint8) {
+	strip.brightness = brightness
+}
+
+// Show sends the buffer to the LEDs.
+func (strip *Strip) Show() error {
+	scale := func(v uint8) byte {
+		return byte(uint16(v) * (uint16(strip.brightness) + 1) >> 8)
+	}
+	for i, p := range strip.pixels {
+		strip.grb[3*i] = scale(p.G)
+		strip.grb[3*i+1] = scale(p.R)
+		strip.grb[3*i+2] = scale(p.B)
+	}
+
+	return strip.driver.show(strip.grb)
+}
+
+// Close releases the hardware driving the strip.  The LEDs keep showing the
+// last colours sent.
+func (strip *Strip) Close() error {
+	return strip.driver.close()
+}
+
+type spiDriver struct {
+	spi spi.Conn
+	buf []byte
+}
+
+func (d *spiDriver) show(grb []byte) error {
+	size := resetBytes + 3*len(grb) + resetBytes
+	if len(d.buf) != size {
+		d.buf = make([]byte, size)
+	}
+
+	// Every byte of GRB data becomes 24 bits, or three bytes, of SPI data
+	out := d.buf[resetBytes:]
+	for i, v := range grb {
+		var bits uint32
+		for b := 7; b >= 0; b-- {
+			bits <<= 3
+			if v&(1<<uint(b)) != 0 {
+				bits |= 6
+			} else {
+				bits |= 4
+			}
+		}
+		out[3*i] = byte(bits >> 16)
+		out[3*i+1] = byte(bits >> 8)
+		out[3*i+2] = byte(bits)
+	}
+
+	return d.spi.Write(d.buf)
+}
+
+func (d *spiDriver) close() error {
+	return d.spi.Close()
+}